@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"notification-svc/startup"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// deliveredHistoryTTL is how long the per-order delivery history is kept
+const deliveredHistoryTTL = 24 * time.Hour
+
+// deferredNotificationsKey is a sorted set of serialized notifications scored by release time (unix seconds)
+const deferredNotificationsKey = "notifications:deferred"
+
+func InitRedis(logger *zap.Logger) (*redis.Client, error) {
+	host := getEnv("REDIS_HOST", "localhost")
+	port := getEnv("REDIS_PORT", "6379")
+	password := getEnv("REDIS_PASSWORD", "")
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Password: password,
+		DB:       0,
+	})
+
+	err := startup.Wait(logger, "redis", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return rdb.Ping(ctx).Err()
+	})
+	if err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	logger.Info("Redis connection established")
+	return rdb, nil
+}
+
+// DeferNotification schedules a serialized notification for delivery at releaseAt
+func DeferNotification(ctx context.Context, rdb *redis.Client, payload []byte, releaseAt time.Time) error {
+	return rdb.ZAdd(ctx, deferredNotificationsKey, redis.Z{
+		Score:  float64(releaseAt.Unix()),
+		Member: payload,
+	}).Err()
+}
+
+// DueNotifications returns up to limit deferred notifications whose release time has passed
+func DueNotifications(ctx context.Context, rdb *redis.Client, now time.Time, limit int64) ([]string, error) {
+	return rdb.ZRangeByScore(ctx, deferredNotificationsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// ClaimNotification removes a due notification from the schedule. Only the caller that
+// gets true may deliver it, so several replicas can drain the same schedule.
+func ClaimNotification(ctx context.Context, rdb *redis.Client, payload string) (bool, error) {
+	removed, err := rdb.ZRem(ctx, deferredNotificationsKey, payload).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed == 1, nil
+}
+
+// RecordDelivered appends a serialized delivered notification to the order's history
+func RecordDelivered(ctx context.Context, rdb *redis.Client, orderID int, payload []byte) error {
+	key := fmt.Sprintf("notifications:order:%d", orderID)
+	pipe := rdb.TxPipeline()
+	pipe.RPush(ctx, key, payload)
+	pipe.Expire(ctx, key, deliveredHistoryTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetDelivered returns the serialized notifications delivered for an order, oldest first
+func GetDelivered(ctx context.Context, rdb *redis.Client, orderID int) ([]string, error) {
+	key := fmt.Sprintf("notifications:order:%d", orderID)
+	return rdb.LRange(ctx, key, 0, -1).Result()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"notification-svc/cache"
+	"notification-svc/database"
+	"notification-svc/kafka"
+	"notification-svc/lock"
+	"notification-svc/middleware"
+	"notification-svc/notifier"
+	"notification-svc/preferences"
+	"notification-svc/templates"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newConsumeCmd() *cobra.Command {
+	var replay bool
+	cmd := &cobra.Command{
+		Use:   "consume",
+		Short: "Run only the Kafka consumer and the quiet-hours scheduler, without the REST API",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return consume(logger, replay)
+		}),
+	}
+	cmd.Flags().BoolVar(&replay, "replay", false, "start from the oldest retained event; every notification is sent again")
+	return cmd
+}
+
+// consume delivers notifications for order and payment events until SIGINT/SIGTERM.
+// The scheduler runs too, so notifications deferred by quiet hours still go out.
+func consume(logger *zap.Logger, replay bool) error {
+	shutdownTracing, err := middleware.InitTracing("notification-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	defer redisClient.Close()
+
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	registry := templates.NewRegistry()
+	if path := os.Getenv("NOTIFICATION_TEMPLATES_FILE"); path != "" {
+		if err := registry.LoadFile(path); err != nil {
+			return fmt.Errorf("failed to load notification templates: %w", err)
+		}
+	}
+
+	consumer, err := kafka.InitConsumer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	n := notifier.NewNotifier(preferences.NewClient(), registry, redisClient, db, lock.NewLocker(redisClient, logger), logger)
+	go n.Start(ctx)
+
+	return kafka.StartConsumer(ctx, consumer, replay, n, logger)
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"notification-svc/startup"
+
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// InitDB opens the database and applies pending migrations, as the service does on startup
+func InitDB(logger *zap.Logger) (*sql.DB, error) {
+	db, err := Open(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrateOnStart {
+		if err := Migrate(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else {
+		logger.Info("Skipping database migrations on start", zap.String("MIGRATE_ON_START", "false"))
+	}
+
+	logger.Info("Database connection established")
+	return db, nil
+}
+
+// Open connects to the database without touching the schema, waiting for it to
+// accept connections
+func Open(logger *zap.Logger) (*sql.DB, error) {
+	host := getEnv("DB_HOST", "localhost")
+	port := getEnv("DB_PORT", "5432")
+	user := getEnv("DB_USER", "postgres")
+	password := getEnv("DB_PASSWORD", "postgres")
+	dbname := getEnv("DB_NAME", "notificationdb")
+
+	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbname)
+
+	db, err := sql.Open("postgres", psqlInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	configurePool(db, dbname)
+
+	if err := startup.Wait(logger, "postgres", db.Ping); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Migrate applies the pending migrations in migrations/. It runs on each start unless
+// MIGRATE_ON_START is false, and from the migrate command.
+func Migrate(db *sql.DB) error {
+	return applyMigrations(db)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrations holds the schema as numbered NNNNNN_name.up.sql / .down.sql pairs. Add a
+// new pair for every schema change; never edit one that has been released.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrateOnStart applies pending migrations in InitDB. Set MIGRATE_ON_START=false to
+// apply them only with the migrate command, e.g. as a deploy step.
+var migrateOnStart = getEnv("MIGRATE_ON_START", "true") != "false"
+
+// applyMigrations brings the schema up to the latest embedded version. Replicas starting
+// together are serialized by the migrator's advisory lock.
+func applyMigrations(db *sql.DB) error {
+	return withMigrator(db, func(m *migrate.Migrate) error {
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the last steps migrations
+func MigrateDown(db *sql.DB, steps int) error {
+	return withMigrator(db, func(m *migrate.Migrate) error {
+		if err := m.Steps(-steps); err != nil {
+			return fmt.Errorf("failed to roll back migrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// MigrationVersion returns the applied schema version, 0 if none is, and whether a
+// migration failed partway and left the schema dirty
+func MigrationVersion(db *sql.DB) (version uint, dirty bool, err error) {
+	err = withMigrator(db, func(m *migrate.Migrate) error {
+		version, dirty, err = m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+		return err
+	})
+	return version, dirty, err
+}
+
+// ForceMigrationVersion records version as applied without running anything, to clear
+// the dirty flag once a failed migration has been fixed by hand
+func ForceMigrationVersion(db *sql.DB, version int) error {
+	return withMigrator(db, func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+// withMigrator runs fn with a migrator over the embedded migrations. It uses a single
+// connection from db, so closing the migrator leaves the pool open.
+func withMigrator(db *sql.DB, fn func(m *migrate.Migrate) error) error {
+	ctx := context.Background()
+
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to prepare migrations: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to prepare migrations: %w", err)
+	}
+	defer m.Close()
+
+	return fn(m)
+}
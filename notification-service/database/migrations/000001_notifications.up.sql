@@ -0,0 +1,19 @@
+-- Every notification sent or scheduled for a user, backing their inbox
+CREATE TABLE IF NOT EXISTS notifications (
+	id VARCHAR(255) PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	type VARCHAR(64) NOT NULL,
+	channel VARCHAR(20) NOT NULL,
+	-- The rendered message: order_id, subject, body and template variant
+	payload JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL CHECK (status IN ('scheduled', 'sent')),
+	trace_id VARCHAR(64),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	sent_at TIMESTAMP,
+	read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at DESC);
+
+-- Unread counts only look at the user's unread notifications
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications (user_id) WHERE status = 'sent' AND read_at IS NULL;
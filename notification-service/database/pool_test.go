@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGetEnvInt(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"missing", "", 25},
+		{"set", "40", 40},
+		{"not a number", "many", 25},
+		{"zero", "0", 25},
+		{"negative", "-3", 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_MAX_OPEN_CONNS", tt.value)
+			if got := getEnvInt("DB_MAX_OPEN_CONNS", 25); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 5 * time.Minute},
+		{"set", "90s", 90 * time.Second},
+		{"no unit", "30", 5 * time.Minute},
+		{"not a duration", "soon", 5 * time.Minute},
+		{"zero", "0s", 5 * time.Minute},
+		{"negative", "-1m", 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_CONN_MAX_LIFETIME", tt.value)
+			if got := getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	const dbName = "pool_test"
+	t.Cleanup(func() {
+		statsMu.Lock()
+		defer statsMu.Unlock()
+		prometheus.Unregister(statsCollectors[dbName])
+		delete(statsCollectors, dbName)
+	})
+
+	// Opening doesn't connect, and the stats don't need a connection
+	open := func() *sql.DB {
+		db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	db := open()
+	configurePool(db, dbName)
+	if got := db.Stats().MaxOpenConnections; got != maxOpenConns {
+		t.Errorf("Expected the pool capped at %d connections, got %d", maxOpenConns, got)
+	}
+
+	// Reopening under the same name replaces the collector instead of failing to register
+	configurePool(open(), dbName)
+
+	expected := fmt.Sprintf(`
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name=%q} %d
+# HELP go_sql_open_connections The number of established connections both in use and idle.
+# TYPE go_sql_open_connections gauge
+go_sql_open_connections{db_name=%q} 0
+`, dbName, maxOpenConns, dbName)
+	if err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected),
+		"go_sql_max_open_connections", "go_sql_open_connections"); err != nil {
+		t.Errorf("Unexpected pool metrics: %v", err)
+	}
+}
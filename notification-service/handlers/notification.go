@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notification-svc/cache"
+	"notification-svc/middleware"
+	"notification-svc/notifier"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// inboxColumns is the column list scanned by scanInboxNotification
+	inboxColumns = "id, user_id, type, channel, payload, status, created_at, sent_at, read_at"
+
+	defaultInboxPageSize = 20
+)
+
+type NotificationHandler struct {
+	redisClient *redis.Client
+	db          *sql.DB
+	logger      *zap.Logger
+}
+
+func NewNotificationHandler(redisClient *redis.Client, db *sql.DB, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		redisClient: redisClient,
+		db:          db,
+		logger:      logger,
+	}
+}
+
+// InboxNotification is a sent notification as the user's inbox shows it
+type InboxNotification struct {
+	ID      string          `json:"id"`
+	UserID  int             `json:"user_id"`
+	Type    string          `json:"type"`
+	Channel string          `json:"channel"`
+	Payload json.RawMessage `json:"payload"`
+	Status  notifier.Status `json:"status"`
+	Read    bool            `json:"read"`
+	// CreatedAt is when the event was handled; SentAt is later for notifications held
+	// back by quiet hours
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// InboxQuery holds the pagination of the inbox, and whether to list only unread notifications
+type InboxQuery struct {
+	Page   int  `form:"page" binding:"omitempty,gte=1"`
+	Limit  int  `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Unread bool `form:"unread"`
+}
+
+// InboxResponse is a page of the inbox. Unread counts all the user's unread
+// notifications, whichever page is listed.
+type InboxResponse struct {
+	Data       []InboxNotification `json:"data"`
+	Page       int                 `json:"page"`
+	Limit      int                 `json:"limit"`
+	Total      int                 `json:"total"`
+	TotalPages int                 `json:"total_pages"`
+	Unread     int                 `json:"unread"`
+}
+
+// ListOrderNotifications returns the notifications delivered for an order in the last 24
+// hours. Other services and admins see any order's; users only see their own orders'.
+func (h *NotificationHandler) ListOrderNotifications(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	payloads, err := cache.GetDelivered(c.Request.Context(), h.redisClient, orderID)
+	if err != nil {
+		h.logger.Error("Failed to load delivered notifications", zap.Int("order_id", orderID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	notifications := make([]notifier.Notification, 0, len(payloads))
+	for _, payload := range payloads {
+		var n notifier.Notification
+		if err := json.Unmarshal([]byte(payload), &n); err != nil {
+			h.logger.Warn("Skipping malformed notification record", zap.Error(err))
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+
+	if !c.GetBool("service") && c.GetString("role") != middleware.RoleAdmin {
+		value, _ := c.Get("user_id")
+		claimed, ok := value.(float64)
+		if !ok || claimed <= 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+		// Every notification for an order goes to the order's owner
+		for _, n := range notifications {
+			if n.UserID != int(claimed) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "notifications": notifications})
+}
+
+// ListUserNotifications returns a page of the user's inbox, newest first, with their
+// unread count. Notifications held back by quiet hours show up once they are sent.
+func (h *NotificationHandler) ListUserNotifications(c *gin.Context) {
+	ctx, span := otel.Tracer("notification-service").Start(c.Request.Context(), "ListUserNotifications")
+	defer span.End()
+
+	userID, ok := inboxUserID(c)
+	if !ok {
+		return
+	}
+
+	var query InboxQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultInboxPageSize
+	}
+	span.SetAttributes(
+		attribute.Int("user.id", userID),
+		attribute.Int("page", query.Page),
+		attribute.Int("limit", query.Limit),
+	)
+
+	resp := InboxResponse{Data: []InboxNotification{}, Page: query.Page, Limit: query.Limit}
+	if err := h.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(*) FILTER (WHERE read_at IS NULL) FROM notifications WHERE user_id = $1 AND status = 'sent'",
+		userID,
+	).Scan(&resp.Total, &resp.Unread); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to count notifications", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	where := " WHERE user_id = $1 AND status = 'sent'"
+	if query.Unread {
+		where += " AND read_at IS NULL"
+		resp.Total = resp.Unread
+	}
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT "+inboxColumns+" FROM notifications"+where+" ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3",
+		userID, query.Limit, (query.Page-1)*query.Limit,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list notifications", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n InboxNotification
+		if err := scanInboxNotification(rows, &n); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan notification", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		resp.Data = append(resp.Data, n)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list notifications", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	resp.TotalPages = (resp.Total + query.Limit - 1) / query.Limit
+	span.SetAttributes(
+		attribute.Int("notifications.count", len(resp.Data)),
+		attribute.Int("notifications.unread", resp.Unread),
+	)
+	c.JSON(http.StatusOK, resp)
+}
+
+// MarkNotificationRead marks one of the user's sent notifications read and returns it.
+// Marking it again keeps the first read time.
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	ctx, span := otel.Tracer("notification-service").Start(c.Request.Context(), "MarkNotificationRead")
+	defer span.End()
+
+	userID, ok := inboxUserID(c)
+	if !ok {
+		return
+	}
+	notificationID := c.Param("notification_id")
+	span.SetAttributes(
+		attribute.Int("user.id", userID),
+		attribute.String("notification.id", notificationID),
+	)
+
+	var n InboxNotification
+	err := scanInboxNotification(h.db.QueryRowContext(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2 AND status = 'sent'
+		RETURNING `+inboxColumns,
+		notificationID, userID,
+	), &n)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to mark notification read", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+// inboxUserID returns the user in the path, answering 403 unless the bearer token is
+// theirs
+func inboxUserID(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	value, _ := c.Get("user_id")
+	claimed, ok := value.(float64)
+	if !ok || claimed <= 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return 0, false
+	}
+	if int(claimed) != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user ID does not match the authenticated user"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInboxNotification(row rowScanner, n *InboxNotification) error {
+	var payload []byte
+	var sentAt, readAt sql.NullTime
+	if err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.Channel, &payload, &n.Status, &n.CreatedAt, &sentAt, &readAt); err != nil {
+		return err
+	}
+	n.Payload = payload
+	if sentAt.Valid {
+		n.SentAt = &sentAt.Time
+	}
+	if readAt.Valid {
+		n.ReadAt = &readAt.Time
+	}
+	n.Read = readAt.Valid
+	return nil
+}
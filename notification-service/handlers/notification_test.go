@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"notification-svc/cache"
+	"notification-svc/notifier"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var inboxRowColumns = []string{"id", "user_id", "type", "channel", "payload", "status", "created_at", "sent_at", "read_at"}
+
+// setupInboxRouter serves the inbox routes as user 3, standing in for AuthMiddleware
+func setupInboxRouter(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	handler := NewNotificationHandler(nil, db, zap.NewNop())
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", float64(3)) })
+	router.GET("/users/:id/notifications", handler.ListUserNotifications)
+	router.PUT("/users/:id/notifications/:notification_id/read", handler.MarkNotificationRead)
+	return mock, router
+}
+
+func TestNotificationHandler_ListUserNotifications(t *testing.T) {
+	mock, router := setupInboxRouter(t)
+
+	sent := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COUNT\\(\\*\\) FILTER \\(WHERE read_at IS NULL\\) FROM notifications WHERE user_id = \\$1 AND status = 'sent'").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "unread"}).AddRow(3, 1))
+	mock.ExpectQuery("SELECT .* FROM notifications WHERE user_id = \\$1 AND status = 'sent' ORDER BY created_at DESC, id DESC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(3, 2, 2).
+		WillReturnRows(sqlmock.NewRows(inboxRowColumns).
+			AddRow("order_created:7:1", 3, "order_created", "email", []byte(`{"order_id":7,"subject":"Order Confirmation","body":"Your order #7 has been created"}`), "sent", sent, sent, sent))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/3/notifications?page=2&limit=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got InboxResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.Page != 2 || got.Total != 3 || got.TotalPages != 2 || got.Unread != 1 || len(got.Data) != 1 {
+		t.Fatalf("Unexpected page: %s", w.Body.String())
+	}
+	if n := got.Data[0]; n.Type != "order_created" || n.Channel != "email" || !n.Read || string(n.Payload) != `{"order_id":7,"subject":"Order Confirmation","body":"Your order #7 has been created"}` {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestNotificationHandler_ListUserNotifications_Unread(t *testing.T) {
+	mock, router := setupInboxRouter(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COUNT\\(\\*\\) FILTER").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "unread"}).AddRow(3, 0))
+	mock.ExpectQuery("SELECT .* FROM notifications WHERE user_id = \\$1 AND status = 'sent' AND read_at IS NULL ORDER BY").
+		WithArgs(3, defaultInboxPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(inboxRowColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/3/notifications?unread=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got InboxResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.Total != 0 || got.TotalPages != 0 || got.Data == nil {
+		t.Errorf("Expected an empty page of unread notifications, got %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestNotificationHandler_InboxRejects(t *testing.T) {
+	_, router := setupInboxRouter(t)
+
+	for path, want := range map[string]int{
+		"/users/4/notifications":           http.StatusForbidden,
+		"/users/abc/notifications":         http.StatusBadRequest,
+		"/users/3/notifications?limit=500": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestNotificationHandler_MarkNotificationRead(t *testing.T) {
+	mock, router := setupInboxRouter(t)
+
+	sent := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("UPDATE notifications SET read_at = COALESCE\\(read_at, CURRENT_TIMESTAMP\\) WHERE id = \\$1 AND user_id = \\$2 AND status = 'sent' RETURNING").
+		WithArgs("payment_success:7:1", 3).
+		WillReturnRows(sqlmock.NewRows(inboxRowColumns).
+			AddRow("payment_success:7:1", 3, "payment_success", "email", []byte(`{"order_id":7}`), "sent", sent, sent, sent.Add(time.Minute)))
+	mock.ExpectQuery("UPDATE notifications SET read_at").
+		WithArgs("payment_success:7:2", 3).
+		WillReturnRows(sqlmock.NewRows(inboxRowColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/3/notifications/payment_success:7:1/read", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got InboxNotification
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !got.Read || got.ReadAt == nil {
+		t.Errorf("Expected the notification read, got %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/3/notifications/payment_success:7:2/read", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another user's or unknown notification, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestNotificationHandler_ListOrderNotifications_OwnerAdminOrService(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	payload, _ := json.Marshal(notifier.NewNotification("payment_success", 3, 42, "Paid", "Thanks", ""))
+	if err := cache.RecordDelivered(context.Background(), rdb, 42, payload); err != nil {
+		t.Fatalf("Failed to record notification: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	handler := NewNotificationHandler(rdb, nil, zap.NewNop())
+	router := gin.New()
+	// Stands in for ServiceTokenOrAuthMiddleware
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Service") != "" {
+			c.Set("service", true)
+		}
+		if id := c.GetHeader("X-User-ID"); id != "" {
+			userID, _ := strconv.Atoi(id)
+			c.Set("user_id", float64(userID))
+			c.Set("role", c.GetHeader("X-Role"))
+		}
+	})
+	router.GET("/orders/:id/notifications", handler.ListOrderNotifications)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"owner", map[string]string{"X-User-ID": "3", "X-Role": "customer"}, http.StatusOK},
+		{"admin", map[string]string{"X-User-ID": "1", "X-Role": "admin"}, http.StatusOK},
+		{"service", map[string]string{"X-Service": "true"}, http.StatusOK},
+		{"other user", map[string]string{"X-User-ID": "4", "X-Role": "customer"}, http.StatusForbidden},
+		{"no claims", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/orders/42/notifications", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var got struct {
+			Notifications []notifier.Notification `json:"notifications"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(got.Notifications) != 1 || got.Notifications[0].EventType != "payment_success" {
+			t.Errorf("%s: unexpected notifications %+v", tt.name, got.Notifications)
+		}
+	}
+}
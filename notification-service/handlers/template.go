@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"notification-svc/templates"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type TemplateHandler struct {
+	registry *templates.Registry
+	logger   *zap.Logger
+}
+
+func NewTemplateHandler(registry *templates.Registry, logger *zap.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+type registerVariantsRequest struct {
+	Variants []templates.Variant `json:"variants" binding:"required"`
+}
+
+// ListTemplates returns the registered template variants for every event type
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"templates": h.registry.All()})
+}
+
+// RegisterVariants replaces the template variants for an event type. Registrations are
+// held in memory, so each replica needs the same call (or NOTIFICATION_TEMPLATES_FILE).
+func (h *TemplateHandler) RegisterVariants(c *gin.Context) {
+	eventType := c.Param("event_type")
+
+	var req registerVariantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.registry.Register(eventType, req.Variants); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Notification template variants registered",
+		zap.String("event_type", eventType),
+		zap.Int("variants", len(req.Variants)),
+	)
+	c.JSON(http.StatusOK, gin.H{"event_type": eventType, "variants": req.Variants})
+}
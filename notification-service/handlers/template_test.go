@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notification-svc/templates"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func setupTemplateRouter(registry *templates.Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewTemplateHandler(registry, zap.NewNop())
+
+	router := gin.New()
+	router.GET("/admin/templates", handler.ListTemplates)
+	router.PUT("/admin/templates/:event_type", handler.RegisterVariants)
+	return router
+}
+
+func TestTemplateHandler_RegisterVariants_SplitsTraffic(t *testing.T) {
+	registry := templates.NewRegistry()
+	router := setupTemplateRouter(registry)
+
+	body := `{"variants": [
+		{"name": "control", "weight": 50, "subject": "Payment Successful", "body": "Order #{{.OrderID}} is paid"},
+		{"name": "emoji", "weight": 50, "subject": "You're all set!", "body": "Order #{{.OrderID}} is paid ({{.TransactionID}})"}
+	]}`
+	req := httptest.NewRequest(http.MethodPut, "/admin/templates/payment_success", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	counts := map[string]int{}
+	for userID := 1; userID <= 1000; userID++ {
+		rendered, err := registry.Render("payment_success", templates.Data{UserID: userID, OrderID: 7, TransactionID: "TXN-1"})
+		if err != nil {
+			t.Fatalf("Unexpected render error: %v", err)
+		}
+		counts[rendered.Variant]++
+
+		// A user stays in the same arm
+		again, _ := registry.Render("payment_success", templates.Data{UserID: userID, OrderID: 8})
+		if again.Variant != rendered.Variant {
+			t.Fatalf("User %d moved from %s to %s", userID, rendered.Variant, again.Variant)
+		}
+	}
+
+	for _, variant := range []string{"control", "emoji"} {
+		if counts[variant] < 400 || counts[variant] > 600 {
+			t.Errorf("Expected roughly half the users on %s, got %d", variant, counts[variant])
+		}
+	}
+}
+
+func TestTemplateHandler_RegisterVariants_Invalid(t *testing.T) {
+	registry := templates.NewRegistry()
+	router := setupTemplateRouter(registry)
+
+	for name, body := range map[string]string{
+		"zero weight":  `{"variants": [{"name": "a", "weight": 0, "subject": "s", "body": "b"}]}`,
+		"duplicate":    `{"variants": [{"name": "a", "weight": 1, "subject": "s", "body": "b"}, {"name": "a", "weight": 1, "subject": "s", "body": "b"}]}`,
+		"bad template": `{"variants": [{"name": "a", "weight": 1, "subject": "{{.OrderID", "body": "b"}]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/admin/templates/order_created", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	// The built-in variant is still in place
+	rendered, err := registry.Render("order_created", templates.Data{UserID: 1, OrderID: 42})
+	if err != nil {
+		t.Fatalf("Unexpected render error: %v", err)
+	}
+	if rendered.Variant != "control" || !strings.Contains(rendered.Body, "#42") {
+		t.Errorf("Unexpected rendering: %+v", rendered)
+	}
+}
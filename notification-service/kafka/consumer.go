@@ -8,6 +8,9 @@ import (
 	"time"
 
 	"notification-svc/middleware"
+	"notification-svc/notifier"
+	"notification-svc/startup"
+	"notification-svc/templates"
 
 	"github.com/IBM/sarama"
 	"go.opentelemetry.io/otel"
@@ -21,10 +24,16 @@ func InitConsumer(logger *zap.Logger) (sarama.Consumer, error) {
 	config := sarama.NewConfig()
 	config.Consumer.Return.Errors = true
 	config.Consumer.Retry.Backoff = 1 * time.Second
+	// Skip payment events from aborted payment-service transactions
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
 
 	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	var consumer sarama.Consumer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumer, err = sarama.NewConsumer(brokers, config)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
 	}
@@ -33,32 +42,87 @@ func InitConsumer(logger *zap.Logger) (sarama.Consumer, error) {
 	return consumer, nil
 }
 
-func StartConsumer(consumer sarama.Consumer, logger *zap.Logger) error {
-	topic := getEnv("KAFKA_TOPIC", "order_events")
-	partitionConsumer, err := consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
-	if err != nil {
-		return fmt.Errorf("failed to consume partition: %w", err)
+// subscribedTopics are the topics notifications are sent for: order and payment events,
+// and product events for stock alerts
+func subscribedTopics() []string {
+	return []string{
+		getEnv("KAFKA_ORDERS_TOPIC", "orders"),
+		getEnv("KAFKA_PAYMENTS_TOPIC", "payments"),
+		getEnv("KAFKA_PRODUCTS_TOPIC", "products"),
+	}
+}
+
+// StartConsumer handles events from every subscribed topic, one at a time, until ctx is
+// done. It starts from new events only, or from the oldest retained event with replay.
+func StartConsumer(ctx context.Context, consumer sarama.Consumer, replay bool, n *notifier.Notifier, logger *zap.Logger) error {
+	topics := subscribedTopics()
+	offset := sarama.OffsetNewest
+	if replay {
+		offset = sarama.OffsetOldest
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages := make(chan *sarama.ConsumerMessage)
+	errs := make(chan *sarama.ConsumerError)
+	for _, topic := range topics {
+		partitionConsumer, err := consumer.ConsumePartition(topic, 0, offset)
+		if err != nil {
+			return fmt.Errorf("failed to consume partition of %s: %w", topic, err)
+		}
+		defer partitionConsumer.Close()
+		go forward(ctx, partitionConsumer, messages, errs)
 	}
-	defer partitionConsumer.Close()
 
-	logger.Info("Kafka consumer started", zap.String("topic", topic))
+	logger.Info("Kafka consumer started", zap.Strings("topics", topics), zap.Bool("replay", replay))
 
 	for {
 		select {
-		case message := <-partitionConsumer.Messages():
-			if err := handleMessageWithRetry(message, logger, 3); err != nil {
-				logger.Error("Failed to handle message after retries", zap.Error(err))
+		case <-ctx.Done():
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		case message := <-messages:
+			if err := handleMessageWithRetry(message, n, logger, 3); err != nil {
+				logger.Error("Failed to handle message after retries", zap.String("topic", message.Topic), zap.Error(err))
 			}
-		case err := <-partitionConsumer.Errors():
+		case err := <-errs:
 			logger.Error("Kafka consumer error", zap.Error(err))
 		}
 	}
 }
 
-func handleMessageWithRetry(message *sarama.ConsumerMessage, logger *zap.Logger, maxRetries int) error {
+// forward passes a partition consumer's messages and errors on until ctx is done
+func forward(ctx context.Context, pc sarama.PartitionConsumer, messages chan<- *sarama.ConsumerMessage, errs chan<- *sarama.ConsumerError) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return
+			}
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				return
+			}
+		case err, ok := <-pc.Errors():
+			if !ok {
+				return
+			}
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func handleMessageWithRetry(message *sarama.ConsumerMessage, n *notifier.Notifier, logger *zap.Logger, maxRetries int) error {
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := handleMessage(message, logger)
+		err := handleMessage(message, n, logger)
 		if err == nil {
 			return nil
 		}
@@ -76,7 +140,7 @@ func handleMessageWithRetry(message *sarama.ConsumerMessage, logger *zap.Logger,
 	return fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-func handleMessage(message *sarama.ConsumerMessage, logger *zap.Logger) error {
+func handleMessage(message *sarama.ConsumerMessage, n *notifier.Notifier, logger *zap.Logger) error {
 	// Extract trace context from Kafka message headers
 	var propagator propagation.TextMapPropagator = otel.GetTextMapPropagator()
 	carrier := saramaHeaderCarrierConsumer(message.Headers)
@@ -103,14 +167,30 @@ func handleMessage(message *sarama.ConsumerMessage, logger *zap.Logger) error {
 
 	span.SetAttributes(attribute.String("event.type", eventType))
 
+	// Retrying can't help with a version this service doesn't read yet; once it does,
+	// the event can be replayed
+	header, err := readEventHeader(message.Value)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if err := header.checkVersion(); err != nil {
+		logger.Error("Skipping event", zap.String("event_id", header.EventID), zap.Int("order_id", header.OrderID), zap.Error(err))
+		return nil
+	}
+
 	// Handle different event types
 	switch eventType {
 	case "order_created":
-		handleOrderCreated(ctx, event, logger, span)
+		handleOrderCreated(ctx, event, n, span)
 	case "payment_success":
-		handlePaymentSuccess(ctx, event, logger, span)
+		handlePaymentSuccess(ctx, event, n, span)
 	case "payment_failed":
-		handlePaymentFailed(ctx, event, logger, span)
+		handlePaymentFailed(ctx, event, n, span)
+	case "refund_completed", "refund_failed":
+		handleRefund(ctx, eventType, event, n, span)
+	case "product_low_stock":
+		handleProductLowStock(ctx, event, span, logger)
 	default:
 		logger.Debug("Unknown event type", zap.String("event_type", eventType))
 	}
@@ -118,33 +198,30 @@ func handleMessage(message *sarama.ConsumerMessage, logger *zap.Logger) error {
 	return nil
 }
 
-func handleOrderCreated(ctx context.Context, event map[string]interface{}, logger *zap.Logger, span trace.Span) {
-	middleware.RecordNotificationSent("order_created")
-	orderID, _ := event["order_id"].(float64)
-	userID, _ := event["user_id"].(float64)
+func handleOrderCreated(ctx context.Context, event map[string]interface{}, n *notifier.Notifier, span trace.Span) {
+	data := orderCreatedData(event)
 
 	span.SetAttributes(
-		attribute.Int("order.id", int(orderID)),
-		attribute.Int("user.id", int(userID)),
+		attribute.Int("order.id", data.OrderID),
+		attribute.Int("user.id", data.UserID),
 	)
 
-	message := fmt.Sprintf("Your order #%.0f has been placed successfully! We'll notify you once it's confirmed.", orderID)
-	traceID := middleware.GetTraceID(ctx)
-	logger.Info("Order notification sent",
-		zap.String("trace_id", traceID),
-		zap.Float64("order_id", orderID),
-		zap.Float64("user_id", userID),
-		zap.String("message", message),
-	)
+	n.NotifyEvent(ctx, "order_created", data, middleware.GetTraceID(ctx))
+}
 
-	// Simulate email sending
-	fmt.Printf("[EMAIL] To: user_%.0f@example.com\n", userID)
-	fmt.Printf("[EMAIL] Subject: Order Confirmation\n")
-	fmt.Printf("[EMAIL] Body: %s\n\n", message)
+// orderCreatedData reads the order_created fields this service relies on; they are
+// declared in contracts/order_events/order_created.notification-service.json
+func orderCreatedData(event map[string]interface{}) templates.Data {
+	orderID, _ := event["order_id"].(float64)
+	userID, _ := event["user_id"].(float64)
+
+	return templates.Data{
+		UserID:  int(userID),
+		OrderID: int(orderID),
+	}
 }
 
-func handlePaymentSuccess(ctx context.Context, event map[string]interface{}, logger *zap.Logger, span trace.Span) {
-	middleware.RecordNotificationSent("payment_success")
+func handlePaymentSuccess(ctx context.Context, event map[string]interface{}, n *notifier.Notifier, span trace.Span) {
 	orderID, _ := event["order_id"].(float64)
 	userID, _ := event["user_id"].(float64)
 	transactionID, _ := event["transaction_id"].(string)
@@ -155,24 +232,14 @@ func handlePaymentSuccess(ctx context.Context, event map[string]interface{}, log
 		attribute.String("transaction.id", transactionID),
 	)
 
-	message := fmt.Sprintf("Payment for order #%.0f was successful! Transaction ID: %s", orderID, transactionID)
-	traceID := middleware.GetTraceID(ctx)
-	logger.Info("Payment success notification sent",
-		zap.String("trace_id", traceID),
-		zap.Float64("order_id", orderID),
-		zap.Float64("user_id", userID),
-		zap.String("transaction_id", transactionID),
-		zap.String("message", message),
-	)
-
-	// Simulate email sending
-	fmt.Printf("[EMAIL] To: user_%.0f@example.com\n", userID)
-	fmt.Printf("[EMAIL] Subject: Payment Successful\n")
-	fmt.Printf("[EMAIL] Body: %s\n\n", message)
+	n.NotifyEvent(ctx, "payment_success", templates.Data{
+		UserID:        int(userID),
+		OrderID:       int(orderID),
+		TransactionID: transactionID,
+	}, middleware.GetTraceID(ctx))
 }
 
-func handlePaymentFailed(ctx context.Context, event map[string]interface{}, logger *zap.Logger, span trace.Span) {
-	middleware.RecordNotificationSent("payment_failed")
+func handlePaymentFailed(ctx context.Context, event map[string]interface{}, n *notifier.Notifier, span trace.Span) {
 	orderID, _ := event["order_id"].(float64)
 	userID, _ := event["user_id"].(float64)
 
@@ -181,19 +248,55 @@ func handlePaymentFailed(ctx context.Context, event map[string]interface{}, logg
 		attribute.Int("user.id", int(userID)),
 	)
 
-	message := fmt.Sprintf("Payment for order #%.0f failed. Please try again or contact support.", orderID)
-	traceID := middleware.GetTraceID(ctx)
-	logger.Info("Payment failure notification sent",
-		zap.String("trace_id", traceID),
-		zap.Float64("order_id", orderID),
-		zap.Float64("user_id", userID),
-		zap.String("message", message),
+	n.NotifyEvent(ctx, "payment_failed", templates.Data{
+		UserID:  int(userID),
+		OrderID: int(orderID),
+	}, middleware.GetTraceID(ctx))
+}
+
+// handleRefund tells the user how the refund of their order went
+func handleRefund(ctx context.Context, eventType string, event map[string]interface{}, n *notifier.Notifier, span trace.Span) {
+	data := refundData(event)
+
+	span.SetAttributes(
+		attribute.Int("order.id", data.OrderID),
+		attribute.Int("user.id", data.UserID),
+	)
+
+	n.NotifyEvent(ctx, eventType, data, middleware.GetTraceID(ctx))
+}
+
+// refundData reads the refund_completed and refund_failed fields this service relies
+// on; they are declared in contracts/order_events/refund_*.notification-service.json
+func refundData(event map[string]interface{}) templates.Data {
+	orderID, _ := event["order_id"].(float64)
+	userID, _ := event["user_id"].(float64)
+
+	return templates.Data{
+		UserID:  int(userID),
+		OrderID: int(orderID),
+	}
+}
+
+// handleProductLowStock alerts operators; there is no customer to notify
+func handleProductLowStock(ctx context.Context, event map[string]interface{}, span trace.Span, logger *zap.Logger) {
+	productID, _ := event["product_id"].(float64)
+	name, _ := event["name"].(string)
+	stock, _ := event["stock"].(float64)
+	threshold, _ := event["threshold"].(float64)
+
+	span.SetAttributes(
+		attribute.Int("product.id", int(productID)),
+		attribute.Int("product.stock", int(stock)),
 	)
 
-	// Simulate email sending
-	fmt.Printf("[EMAIL] To: user_%.0f@example.com\n", userID)
-	fmt.Printf("[EMAIL] Subject: Payment Failed\n")
-	fmt.Printf("[EMAIL] Body: %s\n\n", message)
+	logger.Warn("Operator alert: product stock is low",
+		zap.String("trace_id", middleware.GetTraceID(ctx)),
+		zap.Int("product_id", int(productID)),
+		zap.String("name", name),
+		zap.Int("stock", int(stock)),
+		zap.Int("threshold", int(threshold)),
+	)
 }
 
 // saramaHeaderCarrierConsumer implements the TextMapCarrier interface for Kafka headers (for consumer)
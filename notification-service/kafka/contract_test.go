@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// eventContract declares the fields of an event a consumer relies on. The provider
+// verifies the same file against its event models.
+type eventContract struct {
+	Provider  string            `json:"provider"`
+	Consumer  string            `json:"consumer"`
+	EventType string            `json:"event_type"`
+	Fields    map[string]string `json:"fields"`
+}
+
+func loadContract(t *testing.T, name string) eventContract {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "contracts", "order_events", name))
+	if err != nil {
+		t.Fatalf("Failed to read contract: %v", err)
+	}
+
+	var contract eventContract
+	if err := json.Unmarshal(data, &contract); err != nil {
+		t.Fatalf("Failed to parse contract: %v", err)
+	}
+	return contract
+}
+
+// samplePayload builds the smallest event the provider may send under the contract:
+// only the declared fields, each with a non-zero value of its declared type
+func samplePayload(t *testing.T, contract eventContract) []byte {
+	t.Helper()
+
+	event := map[string]interface{}{}
+	for field, kind := range contract.Fields {
+		switch kind {
+		case "string":
+			event[field] = "sample"
+		case "number":
+			event[field] = 7
+		case "boolean":
+			event[field] = true
+		default:
+			t.Fatalf("Unsupported type %q for field %s", kind, field)
+		}
+	}
+	event["event_type"] = contract.EventType
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal sample event: %v", err)
+	}
+	return data
+}
+
+func TestContract_OrderCreated(t *testing.T) {
+	contract := loadContract(t, "order_created.notification-service.json")
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(samplePayload(t, contract), &event); err != nil {
+		t.Fatalf("Failed to unmarshal sample event: %v", err)
+	}
+
+	// Any field read but not declared would come back zero here
+	data := orderCreatedData(event)
+	if data.OrderID == 0 || data.UserID == 0 {
+		t.Errorf("Contract is missing fields the consumer reads: %+v", data)
+	}
+}
+
+func TestSchema_SupportedVersion(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "contracts", "schemas", "order_events.json"))
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var schema struct {
+		CurrentVersion int `json:"current_version"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	// Providers must not publish a version this consumer would skip
+	if supportedEventVersion < schema.CurrentVersion {
+		t.Errorf("notification-service reads up to version %d, the schema is at %d", supportedEventVersion, schema.CurrentVersion)
+	}
+}
+
+func TestContract_Refunds(t *testing.T) {
+	for _, name := range []string{"refund_completed.notification-service.json", "refund_failed.notification-service.json"} {
+		contract := loadContract(t, name)
+
+		var event map[string]interface{}
+		if err := json.Unmarshal(samplePayload(t, contract), &event); err != nil {
+			t.Fatalf("Failed to unmarshal sample event: %v", err)
+		}
+
+		data := refundData(event)
+		if data.OrderID == 0 || data.UserID == 0 {
+			t.Errorf("%s is missing fields the consumer reads: %+v", name, data)
+		}
+	}
+}
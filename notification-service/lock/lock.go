@@ -0,0 +1,257 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"notification-svc/middleware"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock not acquired")
+	ErrNotHeld     = errors.New("lock not held")
+)
+
+// retryInterval is how often Acquire retries while another holder owns the lock
+const retryInterval = 100 * time.Millisecond
+
+// releaseScript deletes the key only if it still holds our token
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends the TTL only if the key still holds our token
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker hands out Redis-backed mutual exclusion locks (SET NX PX with a
+// random token) so only one replica runs a given job at a time.
+type Locker struct {
+	rdb    *redis.Client
+	logger *zap.Logger
+}
+
+func NewLocker(rdb *redis.Client, logger *zap.Logger) *Locker {
+	return &Locker{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+// Lock is a held lock. Its TTL is renewed in the background until Release is
+// called; Lost is closed if renewal fails and ownership can no longer be assumed.
+type Lock struct {
+	locker     *Locker
+	name       string
+	key        string
+	token      string
+	ttl        time.Duration
+	acquiredAt time.Time
+	stopRenew  context.CancelFunc
+	lost       chan struct{}
+	lostOnce   sync.Once
+	renewDone  chan struct{}
+}
+
+// TryAcquire makes a single attempt to take the lock and returns ErrNotAcquired if it is held elsewhere
+func (l *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	return l.tryAcquire(ctx, name, "lock:"+name, ttl)
+}
+
+// tryAcquire takes the lock stored at key. name labels metrics and logs, so locks on
+// many resources of one kind (every product's stock) share a label.
+func (l *Locker) tryAcquire(ctx context.Context, name, key string, ttl time.Duration) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := l.rdb.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		middleware.RecordLockAcquisition(name, "error")
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !ok {
+		middleware.RecordLockAcquisition(name, "contended")
+		return nil, ErrNotAcquired
+	}
+
+	middleware.RecordLockAcquisition(name, "acquired")
+	renewCtx, stopRenew := context.WithCancel(context.Background())
+	lk := &Lock{
+		locker:     l,
+		name:       name,
+		key:        key,
+		token:      token,
+		ttl:        ttl,
+		acquiredAt: time.Now(),
+		stopRenew:  stopRenew,
+		lost:       make(chan struct{}),
+		renewDone:  make(chan struct{}),
+	}
+	go lk.renew(renewCtx)
+
+	return lk, nil
+}
+
+// Acquire blocks until the lock is taken or ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	return l.acquire(ctx, name, "lock:"+name, ttl)
+}
+
+func (l *Locker) acquire(ctx context.Context, name, key string, ttl time.Duration) (*Lock, error) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		lk, err := l.tryAcquire(ctx, name, key, ttl)
+		if err == nil {
+			return lk, nil
+		}
+		if !errors.Is(err, ErrNotAcquired) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			middleware.RecordLockAcquisition(name, "timeout")
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithLock runs fn while holding the named lock. It does not wait: if another
+// holder owns the lock it returns ErrNotAcquired without running fn. The
+// context passed to fn is cancelled if the lock is lost mid-run.
+func (l *Locker) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lk, err := l.TryAcquire(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	return lk.run(ctx, fn)
+}
+
+// Guard runs fn while holding the lock on one resource of a kind, e.g. the stock of
+// product 42, waiting up to wait for another holder to finish. It returns ErrNotAcquired
+// if the wait runs out. Guard is a serialization aid on top of database guards, so a
+// nil Locker or a failing Redis runs fn unlocked rather than failing the call.
+func (l *Locker) Guard(ctx context.Context, kind, resource string, ttl, wait time.Duration, fn func(ctx context.Context) error) error {
+	if l == nil {
+		return fn(ctx)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	lk, err := l.acquire(waitCtx, kind, "lock:"+kind+":"+resource, ttl)
+	cancel()
+	switch {
+	case err == nil:
+		return lk.run(ctx, fn)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrNotAcquired
+	default:
+		l.logger.Warn("Lock unavailable, continuing without it",
+			zap.String("lock", kind),
+			zap.String("resource", resource),
+			zap.Error(err),
+		)
+		return fn(ctx)
+	}
+}
+
+// run calls fn and releases the lock afterwards. The context passed to fn is cancelled
+// if the lock is lost mid-run.
+func (lk *Lock) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	defer func() {
+		if err := lk.Release(context.Background()); err != nil && !errors.Is(err, ErrNotHeld) {
+			lk.locker.logger.Warn("Failed to release lock", zap.String("lock", lk.name), zap.Error(err))
+		}
+	}()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lk.Lost():
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	return fn(runCtx)
+}
+
+// Lost is closed when background renewal fails and the lock may be held by someone else
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Release stops renewal and deletes the lock if it is still ours
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.stopRenew()
+	<-lk.renewDone
+
+	middleware.ObserveLockHeld(lk.name, time.Since(lk.acquiredAt))
+
+	res, err := releaseScript.Run(ctx, lk.locker.rdb, []string{lk.key}, lk.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", lk.name, err)
+	}
+	if res == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) renew(ctx context.Context) {
+	defer close(lk.renewDone)
+
+	ticker := time.NewTicker(lk.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := renewScript.Run(ctx, lk.locker.rdb, []string{lk.key}, lk.token, lk.ttl.Milliseconds()).Int()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil || res == 0 {
+				middleware.RecordLockRenewalFailure(lk.name)
+				lk.locker.logger.Warn("Lost distributed lock",
+					zap.String("lock", lk.name),
+					zap.Error(err),
+				)
+				lk.lostOnce.Do(func() { close(lk.lost) })
+				return
+			}
+		}
+	}
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleAdmin is the role claim user-service gives administrators
+const RoleAdmin = "admin"
+
+// jwtSecret verifies tokens issued by user-service's login, so JWT_SECRET must match
+// the one user-service signs with
+var jwtSecret = []byte(jwtSecretFromEnv())
+
+// devJWTSecret is the key used without JWT_SECRET in DEV_MODE, the one docker-compose sets
+const devJWTSecret = "dev-jwt-secret"
+
+// errNoJWTSecret rejects every token while no key is configured
+var errNoJWTSecret = errors.New("JWT_SECRET is not set; set it, or DEV_MODE=true to use a development key")
+
+func jwtSecretFromEnv() string {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return secret
+	}
+	if DevMode() {
+		return devJWTSecret
+	}
+	return ""
+}
+
+// CheckJWTSecret fails when JWT_SECRET is unset outside DEV_MODE. serve exits on it rather
+// than verify tokens with a key anyone could guess.
+func CheckJWTSecret() error {
+	if len(jwtSecret) == 0 {
+		return errNoJWTSecret
+	}
+	return nil
+}
+
+// AuthMiddleware verifies the bearer token user-service issued at login and sets its
+// user_id, email and role claims on the context. Requests without a valid token get 401.
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			return
+		}
+
+		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			if len(jwtSecret) == 0 {
+				return nil, errNoJWTSecret
+			}
+			return jwtSecret, nil
+		})
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+
+		c.Set("user_id", claims["user_id"])
+		c.Set("email", claims["email"])
+		c.Set("role", claims["role"])
+		c.Next()
+	}
+}
+
+// RequireRole rejects authenticated users without role with 403. It must run after
+// AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if got, _ := c.Get("role"); got != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestMain gives the tests a key to sign tokens with, as JWT_SECRET would
+func TestMain(m *testing.M) {
+	jwtSecret = []byte("test-jwt-secret")
+	os.Exit(m.Run())
+}
+
+func signedTokenWithRole(t *testing.T, role string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7,
+		"email":   "customer@example.com",
+		"role":    role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/admin/templates/:event_type", AuthMiddleware(), RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized},
+		{"customer", "Bearer " + signedTokenWithRole(t, "customer"), http.StatusForbidden},
+		{"admin", "Bearer " + signedTokenWithRole(t, RoleAdmin), http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/admin/templates/payment_success", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
@@ -32,14 +32,60 @@ var (
 			Name: "notifications_sent_total",
 			Help: "Total number of notifications sent",
 		},
+		[]string{"event_type", "variant"},
+	)
+
+	templateVariantSelectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notification_template_variant_selected_total",
+			Help: "Total number of times each notification template variant was picked",
+		},
+		[]string{"event_type", "variant"},
+	)
+
+	notificationsDeferredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notifications_deferred_total",
+			Help: "Total number of notifications deferred until quiet hours end",
+		},
 		[]string{"event_type"},
 	)
+
+	lockAcquisitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_acquisitions_total",
+			Help: "Total number of distributed lock acquisition attempts",
+		},
+		[]string{"lock", "result"},
+	)
+
+	lockHeldDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "distributed_lock_held_seconds",
+			Help:    "How long distributed locks were held in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lock"},
+	)
+
+	lockRenewalFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_renewal_failures_total",
+			Help: "Total number of distributed locks lost because TTL renewal failed",
+		},
+		[]string{"lock"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
 	prometheus.MustRegister(notificationsSentTotal)
+	prometheus.MustRegister(notificationsDeferredTotal)
+	prometheus.MustRegister(templateVariantSelectedTotal)
+	prometheus.MustRegister(lockAcquisitionsTotal)
+	prometheus.MustRegister(lockHeldDuration)
+	prometheus.MustRegister(lockRenewalFailuresTotal)
 }
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -64,6 +110,26 @@ func PrometheusHandler() gin.HandlerFunc {
 	return gin.WrapH(promhttp.Handler())
 }
 
-func RecordNotificationSent(eventType string) {
-	notificationsSentTotal.WithLabelValues(eventType).Inc()
+func RecordNotificationSent(eventType, variant string) {
+	notificationsSentTotal.WithLabelValues(eventType, variant).Inc()
+}
+
+func RecordTemplateVariantSelected(eventType, variant string) {
+	templateVariantSelectedTotal.WithLabelValues(eventType, variant).Inc()
+}
+
+func RecordNotificationDeferred(eventType string) {
+	notificationsDeferredTotal.WithLabelValues(eventType).Inc()
+}
+
+func RecordLockAcquisition(lock, result string) {
+	lockAcquisitionsTotal.WithLabelValues(lock, result).Inc()
+}
+
+func ObserveLockHeld(lock string, d time.Duration) {
+	lockHeldDuration.WithLabelValues(lock).Observe(d.Seconds())
+}
+
+func RecordLockRenewalFailure(lock string) {
+	lockRenewalFailuresTotal.WithLabelValues(lock).Inc()
 }
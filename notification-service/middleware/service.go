@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceTokenOrAuthMiddleware lets other services in with a shared X-Service-Token header
+// and everyone else with a login token, as AuthMiddleware does. Service callers are marked
+// with "service" on the context. An empty token only turns the service path off.
+func ServiceTokenOrAuthMiddleware(token string) gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Service-Token")
+		if token != "" && provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			c.Set("service", true)
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
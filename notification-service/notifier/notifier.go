@@ -0,0 +1,299 @@
+package notifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"notification-svc/cache"
+	"notification-svc/lock"
+	"notification-svc/middleware"
+	"notification-svc/preferences"
+	"notification-svc/templates"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// releaseBatchSize caps how many deferred notifications are released per tick
+	releaseBatchSize = 100
+
+	// releaseLock keeps the release of deferred notifications to one replica per tick
+	releaseLock    = "notification-release"
+	releaseLockTTL = 30 * time.Second
+)
+
+type Urgency string
+
+const (
+	// UrgencyUrgent notifications are delivered immediately, even during quiet hours
+	UrgencyUrgent Urgency = "urgent"
+	// UrgencyNormal notifications are held until the user's quiet hours end
+	UrgencyNormal Urgency = "normal"
+)
+
+// urgencyByEventType classifies events; anything not listed is normal
+var urgencyByEventType = map[string]Urgency{
+	"order_created":    UrgencyNormal,
+	"payment_success":  UrgencyNormal,
+	"payment_failed":   UrgencyUrgent,
+	"refund_completed": UrgencyNormal,
+	"refund_failed":    UrgencyUrgent,
+}
+
+func ClassifyUrgency(eventType string) Urgency {
+	if urgency, ok := urgencyByEventType[eventType]; ok {
+		return urgency
+	}
+	return UrgencyNormal
+}
+
+// Notification is a rendered message ready to deliver, serialized as-is when deferred
+type Notification struct {
+	ID        string    `json:"id"`
+	EventType string    `json:"event_type"`
+	UserID    int       `json:"user_id"`
+	OrderID   int       `json:"order_id"`
+	Channel   string    `json:"channel"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Variant   string    `json:"variant,omitempty"`
+	TraceID   string    `json:"trace_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewNotification(eventType string, userID, orderID int, subject, body, traceID string) Notification {
+	now := time.Now()
+	return Notification{
+		ID:        fmt.Sprintf("%s:%d:%d", eventType, orderID, now.UnixNano()),
+		EventType: eventType,
+		UserID:    userID,
+		OrderID:   orderID,
+		Channel:   ChannelEmail,
+		Subject:   subject,
+		Body:      body,
+		TraceID:   traceID,
+		CreatedAt: now,
+	}
+}
+
+// Notifier delivers notifications, deferring non-urgent ones that fall inside the
+// recipient's quiet hours to a Redis-backed schedule. Every notification is also kept in
+// Postgres for the recipient's inbox.
+type Notifier struct {
+	preferences *preferences.Client
+	templates   *templates.Registry
+	redisClient *redis.Client
+	db          *sql.DB
+	locker      *lock.Locker
+	logger      *zap.Logger
+	interval    time.Duration
+}
+
+func NewNotifier(prefs *preferences.Client, registry *templates.Registry, redisClient *redis.Client, db *sql.DB, locker *lock.Locker, logger *zap.Logger) *Notifier {
+	return &Notifier{
+		preferences: prefs,
+		templates:   registry,
+		redisClient: redisClient,
+		db:          db,
+		locker:      locker,
+		logger:      logger,
+		interval:    getEnvDuration("NOTIFICATION_SCHEDULER_INTERVAL", 30*time.Second),
+	}
+}
+
+// NotifyEvent renders the recipient's template variant for eventType and notifies them.
+// The chosen variant is kept on the notification so experiments can be compared.
+func (n *Notifier) NotifyEvent(ctx context.Context, eventType string, data templates.Data, traceID string) {
+	rendered, err := n.templates.Render(eventType, data)
+	if err != nil {
+		n.logger.Error("Failed to render notification",
+			zap.String("trace_id", traceID),
+			zap.String("event_type", eventType),
+			zap.Int("order_id", data.OrderID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	middleware.RecordTemplateVariantSelected(eventType, rendered.Variant)
+
+	notification := NewNotification(eventType, data.UserID, data.OrderID, rendered.Subject, rendered.Body, traceID)
+	notification.Variant = rendered.Variant
+	n.Notify(ctx, notification)
+}
+
+// Notify delivers n now or schedules it for when the user's quiet hours end.
+// Preference lookup or scheduling failures fall back to immediate delivery.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) {
+	ctx, span := otel.Tracer("notification-service").Start(ctx, "Notify")
+	defer span.End()
+
+	urgency := ClassifyUrgency(notification.EventType)
+	span.SetAttributes(
+		attribute.String("notification.urgency", string(urgency)),
+		attribute.Int("user.id", notification.UserID),
+	)
+
+	if urgency == UrgencyUrgent {
+		n.deliver(ctx, notification)
+		return
+	}
+
+	prefs, err := n.preferences.Get(ctx, notification.UserID)
+	if err != nil {
+		span.RecordError(err)
+		n.logger.Warn("Failed to load notification preferences, delivering immediately",
+			zap.String("trace_id", notification.TraceID),
+			zap.Int("user_id", notification.UserID),
+			zap.Error(err),
+		)
+		n.deliver(ctx, notification)
+		return
+	}
+
+	releaseAt, quiet := prefs.QuietUntil(time.Now())
+	if !quiet {
+		n.deliver(ctx, notification)
+		return
+	}
+
+	if err := n.schedule(ctx, notification, releaseAt); err != nil {
+		span.RecordError(err)
+		n.logger.Error("Failed to defer notification, delivering immediately",
+			zap.String("trace_id", notification.TraceID),
+			zap.String("notification_id", notification.ID),
+			zap.Error(err),
+		)
+		n.deliver(ctx, notification)
+		return
+	}
+
+	span.SetAttributes(attribute.String("notification.release_at", releaseAt.UTC().Format(time.RFC3339)))
+	middleware.RecordNotificationDeferred(notification.EventType)
+	n.store(ctx, notification, StatusScheduled)
+	n.logger.Info("Notification deferred until quiet hours end",
+		zap.String("trace_id", notification.TraceID),
+		zap.String("notification_id", notification.ID),
+		zap.String("event_type", notification.EventType),
+		zap.Int("user_id", notification.UserID),
+		zap.Time("release_at", releaseAt),
+	)
+}
+
+// Start releases due deferred notifications on every tick until ctx is cancelled. Each
+// release runs under a distributed lock, so only one replica sends per tick.
+func (n *Notifier) Start(ctx context.Context) {
+	n.logger.Info("Notification scheduler started", zap.Duration("interval", n.interval))
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Notification scheduler stopped")
+			return
+		case <-ticker.C:
+			err := n.locker.WithLock(ctx, releaseLock, releaseLockTTL, n.releaseDue)
+			switch {
+			case errors.Is(err, lock.ErrNotAcquired):
+				n.logger.Debug("Deferred notifications already being released on another replica")
+			case err != nil && ctx.Err() == nil:
+				n.logger.Error("Failed to load due notifications", zap.Error(err))
+			}
+		}
+	}
+}
+
+// releaseDue delivers one batch of due deferred notifications. Each is claimed before
+// delivery, so a release racing it elsewhere can't send it twice.
+func (n *Notifier) releaseDue(ctx context.Context) error {
+	payloads, err := cache.DueNotifications(ctx, n.redisClient, time.Now(), releaseBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range payloads {
+		claimed, err := cache.ClaimNotification(ctx, n.redisClient, payload)
+		if err != nil {
+			n.logger.Error("Failed to claim deferred notification", zap.Error(err))
+			continue
+		}
+		if !claimed {
+			// Another replica released it first
+			continue
+		}
+
+		var notification Notification
+		if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+			n.logger.Error("Dropping malformed deferred notification", zap.Error(err))
+			continue
+		}
+		n.deliver(ctx, notification)
+	}
+	return nil
+}
+
+func (n *Notifier) schedule(ctx context.Context, notification Notification, releaseAt time.Time) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return cache.DeferNotification(ctx, n.redisClient, payload, releaseAt)
+}
+
+func (n *Notifier) deliver(ctx context.Context, notification Notification) {
+	middleware.RecordNotificationSent(notification.EventType, notification.Variant)
+	middleware.RecordBusinessEvent(ctx, middleware.EventNotificationSent,
+		attribute.String("notification.id", notification.ID),
+		attribute.String("notification.event_type", notification.EventType),
+		attribute.String("notification.variant", notification.Variant),
+		attribute.Int("order.id", notification.OrderID),
+		attribute.Int("user.id", notification.UserID),
+	)
+	n.logger.Info("Notification sent",
+		zap.String("trace_id", notification.TraceID),
+		zap.String("notification_id", notification.ID),
+		zap.String("event_type", notification.EventType),
+		zap.String("variant", notification.Variant),
+		zap.Int("order_id", notification.OrderID),
+		zap.Int("user_id", notification.UserID),
+		zap.Duration("delay", time.Since(notification.CreatedAt)),
+		zap.String("message", notification.Body),
+	)
+
+	// Simulate email sending
+	fmt.Printf("[EMAIL] To: user_%d@example.com\n", notification.UserID)
+	fmt.Printf("[EMAIL] Subject: %s\n", notification.Subject)
+	fmt.Printf("[EMAIL] Body: %s\n\n", notification.Body)
+
+	n.store(ctx, notification, StatusSent)
+
+	// Keep a short delivery history per order for lookups such as the order-service self-test
+	payload, err := json.Marshal(notification)
+	if err == nil {
+		err = cache.RecordDelivered(ctx, n.redisClient, notification.OrderID, payload)
+	}
+	if err != nil {
+		n.logger.Warn("Failed to record delivered notification",
+			zap.String("notification_id", notification.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"notification-svc/cache"
+	"notification-svc/preferences"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// deferredKey is the sorted set cache keeps deferred notifications in
+const deferredKey = "notifications:deferred"
+
+// setupNotifier serves user 3's preferences, with quiet hours running from a minute ago to
+// an hour from now when quiet is set
+func setupNotifier(t *testing.T, quiet bool) (*Notifier, *miniredis.Miniredis, sqlmock.Sqlmock) {
+	t.Helper()
+
+	prefs := preferences.Preferences{UserID: 3, Timezone: "UTC"}
+	if quiet {
+		// A window that started a minute ago and ends in an hour
+		now := time.Now().UTC()
+		start := now.Add(-time.Minute).Format("15:04")
+		end := now.Add(time.Hour).Format("15:04")
+		prefs.QuietHoursStart, prefs.QuietHoursEnd = &start, &end
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(prefs)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("USER_SERVICE_URL", server.URL)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewNotifier(preferences.NewClient(), nil, rdb, db, nil, zap.NewNop()), mr, mock
+}
+
+func delivered(t *testing.T, n *Notifier, orderID int) []string {
+	t.Helper()
+	payloads, err := cache.GetDelivered(context.Background(), n.redisClient, orderID)
+	if err != nil {
+		t.Fatalf("Failed to read delivered notifications: %v", err)
+	}
+	return payloads
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	tests := []struct {
+		name         string
+		eventType    string
+		quiet        bool
+		wantDeferred bool
+		wantStatus   string
+	}{
+		{"normal outside quiet hours", "payment_success", false, false, string(StatusSent)},
+		{"normal inside quiet hours", "payment_success", true, true, string(StatusScheduled)},
+		{"urgent inside quiet hours", "payment_failed", true, false, string(StatusSent)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, mr, mock := setupNotifier(t, tt.quiet)
+			mock.ExpectExec("INSERT INTO notifications").
+				WithArgs(sqlmock.AnyArg(), 3, tt.eventType, ChannelEmail, sqlmock.AnyArg(), tt.wantStatus, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			n.Notify(context.Background(), NewNotification(tt.eventType, 3, 42, "Subject", "Body", ""))
+
+			deferred, _ := mr.ZMembers(deferredKey)
+			if tt.wantDeferred {
+				if len(deferred) != 1 || len(delivered(t, n, 42)) != 0 {
+					t.Errorf("Expected the notification deferred and not delivered, got %d deferred, %d delivered", len(deferred), len(delivered(t, n, 42)))
+				}
+			} else if len(deferred) != 0 || len(delivered(t, n, 42)) != 1 {
+				t.Errorf("Expected the notification delivered now, got %d deferred, %d delivered", len(deferred), len(delivered(t, n, 42)))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestNotifier_ReleaseDue(t *testing.T) {
+	n, mr, mock := setupNotifier(t, false)
+	ctx := context.Background()
+
+	due := NewNotification("order_created", 3, 1, "Due", "Body", "")
+	later := NewNotification("order_created", 3, 2, "Later", "Body", "")
+	for _, s := range []struct {
+		notification Notification
+		releaseAt    time.Time
+	}{
+		{due, time.Now().Add(-time.Minute)},
+		{later, time.Now().Add(time.Hour)},
+	} {
+		payload, _ := json.Marshal(s.notification)
+		if err := cache.DeferNotification(ctx, n.redisClient, payload, s.releaseAt); err != nil {
+			t.Fatalf("Failed to defer notification: %v", err)
+		}
+	}
+	// A malformed entry is claimed and dropped rather than retried forever
+	if err := cache.DeferNotification(ctx, n.redisClient, []byte("not json"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to defer notification: %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs(due.ID, 3, "order_created", ChannelEmail, sqlmock.AnyArg(), string(StatusSent), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := n.releaseDue(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := len(delivered(t, n, 1)); got != 1 {
+		t.Errorf("Expected the due notification delivered once, got %d", got)
+	}
+	if got := len(delivered(t, n, 2)); got != 0 {
+		t.Errorf("Expected the later notification held back, got %d deliveries", got)
+	}
+	remaining, _ := mr.ZMembers(deferredKey)
+	if len(remaining) != 1 {
+		t.Errorf("Expected only the later notification left scheduled, got %d", len(remaining))
+	}
+
+	// A second release finds nothing due and sends nothing twice
+	if err := n.releaseDue(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := len(delivered(t, n, 1)); got != 1 {
+		t.Errorf("Expected the due notification still delivered once, got %d", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
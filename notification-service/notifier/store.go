@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ChannelEmail is the channel every notification is currently sent on
+const ChannelEmail = "email"
+
+type Status string
+
+const (
+	// StatusScheduled notifications wait for the recipient's quiet hours to end
+	StatusScheduled Status = "scheduled"
+	StatusSent      Status = "sent"
+)
+
+// Payload is the rendered message a stored notification carries
+type Payload struct {
+	OrderID int    `json:"order_id"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Variant string `json:"variant,omitempty"`
+}
+
+// store keeps notification in the notifications table with status. A scheduled
+// notification's row is updated once it is sent. Failing to store it is logged and
+// doesn't hold up delivery.
+func (n *Notifier) store(ctx context.Context, notification Notification, status Status) {
+	payload, err := json.Marshal(Payload{
+		OrderID: notification.OrderID,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+		Variant: notification.Variant,
+	})
+	if err == nil {
+		// Notifications deferred before channels were recorded went out by email
+		channel := notification.Channel
+		if channel == "" {
+			channel = ChannelEmail
+		}
+		var sentAt sql.NullTime
+		if status == StatusSent {
+			sentAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+		_, err = n.db.ExecContext(ctx,
+			`INSERT INTO notifications (id, user_id, type, channel, payload, status, trace_id, created_at, sent_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+			ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, sent_at = EXCLUDED.sent_at`,
+			notification.ID, notification.UserID, notification.EventType, channel, payload, status, notification.TraceID,
+			notification.CreatedAt, sentAt,
+		)
+	}
+	if err != nil {
+		n.logger.Warn("Failed to store notification",
+			zap.String("trace_id", notification.TraceID),
+			zap.String("notification_id", notification.ID),
+			zap.String("status", string(status)),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,119 @@
+package preferences
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// quietHoursLayout matches the "HH:MM" format stored by user-service
+const quietHoursLayout = "15:04"
+
+// Preferences mirrors user-service's notification preferences
+type Preferences struct {
+	UserID          int     `json:"user_id"`
+	Timezone        string  `json:"timezone"`
+	QuietHoursStart *string `json:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+}
+
+// QuietUntil reports whether now falls inside the user's quiet hours and, if so,
+// when the window opens again. Windows may wrap past midnight (e.g. 22:00-07:00).
+func (p *Preferences) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+	start, err := time.Parse(quietHoursLayout, *p.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(quietHoursLayout, *p.QuietHoursEnd)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	curMin := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	switch {
+	case startMin == endMin:
+		quiet = false
+	case startMin < endMin:
+		quiet = curMin >= startMin && curMin < endMin
+	default:
+		quiet = curMin >= startMin || curMin < endMin
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	opensAt := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !opensAt.After(local) {
+		opensAt = opensAt.AddDate(0, 0, 1)
+	}
+	return opensAt, true
+}
+
+// Client fetches notification preferences from user-service's internal endpoint, which
+// takes the shared service token
+type Client struct {
+	baseURL      string
+	serviceToken string
+	httpClient   *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		baseURL:      getEnv("USER_SERVICE_URL", "http://localhost:8080"),
+		serviceToken: os.Getenv("SERVICE_TOKEN"),
+		httpClient:   &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (c *Client) Get(ctx context.Context, userID int) (*Preferences, error) {
+	url := fmt.Sprintf("%s/internal/users/%d/notification-preferences", c.baseURL, userID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build preferences request: %w", err)
+	}
+	req.Header.Set("X-Service-Token", c.serviceToken)
+	// Propagate trace context so the lookup shows up under the notification span
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch preferences: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user-service returned status %d", resp.StatusCode)
+	}
+
+	var prefs Preferences
+	if err := json.NewDecoder(resp.Body).Decode(&prefs); err != nil {
+		return nil, fmt.Errorf("failed to decode preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,125 @@
+package preferences
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPreferences_QuietUntil(t *testing.T) {
+	hhmm := func(s string) *string { return &s }
+	at := func(value string) time.Time {
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("Bad test time %q: %v", value, err)
+		}
+		return ts
+	}
+
+	tests := []struct {
+		name      string
+		prefs     Preferences
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{
+			name:  "no quiet hours",
+			prefs: Preferences{Timezone: "UTC"},
+			now:   at("2024-03-10T23:00:00Z"),
+		},
+		{
+			name:      "inside a same-day window",
+			prefs:     Preferences{Timezone: "UTC", QuietHoursStart: hhmm("13:00"), QuietHoursEnd: hhmm("15:30")},
+			now:       at("2024-03-10T14:00:00Z"),
+			wantQuiet: true,
+			wantUntil: at("2024-03-10T15:30:00Z"),
+		},
+		{
+			name:  "window end is exclusive",
+			prefs: Preferences{Timezone: "UTC", QuietHoursStart: hhmm("13:00"), QuietHoursEnd: hhmm("15:30")},
+			now:   at("2024-03-10T15:30:00Z"),
+		},
+		{
+			name:      "before midnight in a window crossing midnight",
+			prefs:     Preferences{Timezone: "UTC", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("07:00")},
+			now:       at("2024-03-10T23:15:00Z"),
+			wantQuiet: true,
+			wantUntil: at("2024-03-11T07:00:00Z"),
+		},
+		{
+			name:      "after midnight in a window crossing midnight",
+			prefs:     Preferences{Timezone: "UTC", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("07:00")},
+			now:       at("2024-03-11T02:00:00Z"),
+			wantQuiet: true,
+			wantUntil: at("2024-03-11T07:00:00Z"),
+		},
+		{
+			name:  "outside a window crossing midnight",
+			prefs: Preferences{Timezone: "UTC", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("07:00")},
+			now:   at("2024-03-11T12:00:00Z"),
+		},
+		{
+			name:      "in the user's timezone",
+			prefs:     Preferences{Timezone: "America/New_York", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("07:00")},
+			now:       at("2024-06-11T03:00:00Z"), // 23:00 in New York
+			wantQuiet: true,
+			wantUntil: at("2024-06-11T11:00:00Z"),
+		},
+		{
+			name:  "equal start and end",
+			prefs: Preferences{Timezone: "UTC", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("22:00")},
+			now:   at("2024-03-10T22:00:00Z"),
+		},
+		{
+			name:  "malformed time",
+			prefs: Preferences{Timezone: "UTC", QuietHoursStart: hhmm("late"), QuietHoursEnd: hhmm("07:00")},
+			now:   at("2024-03-10T23:00:00Z"),
+		},
+		{
+			name:      "unknown timezone falls back to UTC",
+			prefs:     Preferences{Timezone: "Mars/Olympus", QuietHoursStart: hhmm("22:00"), QuietHoursEnd: hhmm("07:00")},
+			now:       at("2024-03-10T23:00:00Z"),
+			wantQuiet: true,
+			wantUntil: at("2024-03-11T07:00:00Z"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.prefs.QuietUntil(tt.now)
+			if quiet != tt.wantQuiet {
+				t.Fatalf("Expected quiet=%v, got %v", tt.wantQuiet, quiet)
+			}
+			if quiet && !until.Equal(tt.wantUntil) {
+				t.Errorf("Expected quiet until %s, got %s", tt.wantUntil, until.UTC())
+			}
+		})
+	}
+}
+
+func TestClient_GetSendsServiceToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/users/3/notification-preferences" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("X-Service-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(Preferences{UserID: 3, Timezone: "UTC"})
+	}))
+	defer server.Close()
+	t.Setenv("USER_SERVICE_URL", server.URL)
+	t.Setenv("SERVICE_TOKEN", "secret")
+
+	prefs, err := NewClient().Get(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prefs.UserID != 3 {
+		t.Errorf("Unexpected preferences %+v", prefs)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"notification-svc/cache"
+	"notification-svc/database"
+	"notification-svc/handlers"
+	"notification-svc/kafka"
+	"notification-svc/lock"
+	"notification-svc/middleware"
+	"notification-svc/notifier"
+	"notification-svc/preferences"
+	"notification-svc/templates"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/zap"
+)
+
+// serve runs the Kafka consumer, the quiet-hours scheduler and the REST API until
+// SIGINT/SIGTERM
+func serve(logger *zap.Logger) error {
+	if err := middleware.CheckJWTSecret(); err != nil {
+		logger.Fatal("Invalid auth configuration", zap.Error(err))
+	}
+
+	// Initialize OpenTelemetry
+	shutdown, err := middleware.InitTracing("notification-service")
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer shutdown()
+
+	// Initialize Redis (holds notifications deferred by quiet hours)
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis", zap.Error(err))
+	}
+
+	// Initialize Postgres (holds every notification for the users' inboxes)
+	db, err := database.InitDB(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+
+	// Template variants for A/B tests; the built-in messages are the "control" variants
+	registry := templates.NewRegistry()
+	if path := os.Getenv("NOTIFICATION_TEMPLATES_FILE"); path != "" {
+		if err := registry.LoadFile(path); err != nil {
+			logger.Fatal("Failed to load notification templates", zap.Error(err))
+		}
+	}
+
+	// Notifier checks user quiet hours; its scheduler releases deferred notifications
+	n := notifier.NewNotifier(preferences.NewClient(), registry, redisClient, db, lock.NewLocker(redisClient, logger), logger)
+	schedulerCtx, schedulerCancel := context.WithCancel(context.Background())
+	go n.Start(schedulerCtx)
+
+	// Initialize Kafka consumer
+	consumer, err := kafka.InitConsumer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
+	}
+	// defer consumer.Close()
+
+	// Start Kafka consumer in background
+	go func() {
+		if err := kafka.StartConsumer(context.Background(), consumer, false, n, logger); err != nil {
+			logger.Error("Kafka consumer error", zap.Error(err))
+		}
+	}()
+
+	// Setup REST API with Gin
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// OpenTelemetry middleware must be first to extract trace context
+	router.Use(otelgin.Middleware("notification-service"))
+	router.Use(middleware.LoggerMiddleware(logger))
+	router.Use(middleware.MetricsMiddleware())
+
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// Delivered notification history, for the order's owner, admins and other services
+	notificationHandler := handlers.NewNotificationHandler(redisClient, db, logger)
+	router.GET("/api/v1/orders/:id/notifications",
+		middleware.ServiceTokenOrAuthMiddleware(os.Getenv("SERVICE_TOKEN")),
+		notificationHandler.ListOrderNotifications,
+	)
+
+	// Users' inboxes, read with their login token
+	inbox := router.Group("/api/v1/users/:id/notifications", middleware.AuthMiddleware())
+	inbox.GET("", notificationHandler.ListUserNotifications)
+	inbox.PUT("/:notification_id/read", notificationHandler.MarkNotificationRead)
+
+	// Template variant registration for subject-line experiments, for users with the
+	// admin role
+	templateHandler := handlers.NewTemplateHandler(registry, logger)
+	admin := router.Group("/admin", middleware.AuthMiddleware(), middleware.RequireRole(middleware.RoleAdmin))
+	{
+		admin.GET("/templates", templateHandler.ListTemplates)
+		admin.PUT("/templates/:event_type", templateHandler.RegisterVariants)
+	}
+
+	// Metrics endpoint
+	router.GET("/metrics", middleware.PrometheusHandler())
+
+	// Start REST server
+	srv := &http.Server{
+		Addr:    ":8084",
+		Handler: router,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start REST server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Notification Service started on :8084")
+
+	gracefulShutdown(srv, consumer, schedulerCancel, redisClient, db, logger)
+	return nil
+}
+
+// gracefulShutdown waits for SIGINT/SIGTERM and shuts down HTTP server and Kafka consumer gracefully
+func gracefulShutdown(srv *http.Server, consumer sarama.Consumer, schedulerCancel context.CancelFunc, redisClient *redis.Client, db *sql.DB, logger *zap.Logger) {
+	// Channel to listen for interrupt signals
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// Block until a signal is received
+	<-quit
+	logger.Info("Received shutdown signal. Shutting down...")
+
+	// Context with timeout for shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Shutdown HTTP server gracefully
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server forced to shutdown", zap.Error(err))
+	} else {
+		logger.Info("HTTP server shut down gracefully")
+	}
+
+	// Stop releasing deferred notifications
+	schedulerCancel()
+
+	// Close Kafka consumer gracefully
+	if consumer != nil {
+		if err := consumer.Close(); err != nil {
+			logger.Error("Failed to close Kafka consumer", zap.Error(err))
+		} else {
+			logger.Info("Kafka consumer closed gracefully")
+		}
+	}
+
+	// Close database connection
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database", zap.Error(err))
+	} else {
+		logger.Info("Database connection closed gracefully")
+	}
+
+	// Close Redis connection
+	if err := redisClient.Close(); err != nil {
+		logger.Error("Failed to close Redis", zap.Error(err))
+	} else {
+		logger.Info("Redis connection closed gracefully")
+	}
+
+	logger.Info("Service exited gracefully")
+}
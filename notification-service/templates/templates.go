@@ -0,0 +1,225 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Variant is one version of an event's subject and body. Weight is its relative share of
+// traffic; a variant with weight 0 stays registered but is never picked.
+type Variant struct {
+	Name    string `json:"name"`
+	Weight  int    `json:"weight"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Data is what subject and body templates can reference, e.g. {{.OrderID}}
+type Data struct {
+	UserID        int
+	OrderID       int
+	TransactionID string
+}
+
+// Rendered is the message produced by the variant picked for a recipient
+type Rendered struct {
+	Variant string
+	Subject string
+	Body    string
+}
+
+type compiledVariant struct {
+	Variant
+	subject *template.Template
+	body    *template.Template
+}
+
+// Registry holds the template variants for each event type. Variants are assigned by
+// hashing the user ID, so a user keeps seeing the same variant for an event while the
+// registration is unchanged.
+type Registry struct {
+	mu       sync.RWMutex
+	variants map[string][]compiledVariant
+}
+
+// defaultVariants is the single "control" message per event, used until other variants
+// are registered
+var defaultVariants = map[string][]Variant{
+	"order_created": {{
+		Name:    "control",
+		Weight:  100,
+		Subject: "Order Confirmation",
+		Body:    "Your order #{{.OrderID}} has been placed successfully! We'll notify you once it's confirmed.",
+	}},
+	"payment_success": {{
+		Name:    "control",
+		Weight:  100,
+		Subject: "Payment Successful",
+		Body:    "Payment for order #{{.OrderID}} was successful! Transaction ID: {{.TransactionID}}",
+	}},
+	"payment_failed": {{
+		Name:    "control",
+		Weight:  100,
+		Subject: "Payment Failed",
+		Body:    "Payment for order #{{.OrderID}} failed. Please try again or contact support.",
+	}},
+	"refund_completed": {{
+		Name:    "control",
+		Weight:  100,
+		Subject: "Refund Issued",
+		Body:    "Your payment for order #{{.OrderID}} has been refunded. It may take a few days to appear on your statement.",
+	}},
+	"refund_failed": {{
+		Name:    "control",
+		Weight:  100,
+		Subject: "Refund Failed",
+		Body:    "We couldn't refund your payment for order #{{.OrderID}}. Our support team will follow up.",
+	}},
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{variants: make(map[string][]compiledVariant)}
+	for eventType, variants := range defaultVariants {
+		if err := r.Register(eventType, variants); err != nil {
+			panic(fmt.Sprintf("invalid default templates for %s: %v", eventType, err))
+		}
+	}
+	return r
+}
+
+// Register replaces the variants for eventType. Names must be unique, weights must not
+// be negative and at least one variant must receive traffic.
+func (r *Registry) Register(eventType string, variants []Variant) error {
+	if eventType == "" {
+		return errors.New("event type is required")
+	}
+	if len(variants) == 0 {
+		return errors.New("at least one variant is required")
+	}
+
+	compiled := make([]compiledVariant, 0, len(variants))
+	seen := make(map[string]bool, len(variants))
+	total := 0
+	for _, v := range variants {
+		if strings.TrimSpace(v.Name) == "" {
+			return errors.New("variant name is required")
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight < 0 {
+			return fmt.Errorf("variant %q has a negative weight", v.Name)
+		}
+		total += v.Weight
+
+		subject, err := template.New(v.Name + ".subject").Option("missingkey=error").Parse(v.Subject)
+		if err != nil {
+			return fmt.Errorf("variant %q subject: %w", v.Name, err)
+		}
+		body, err := template.New(v.Name + ".body").Option("missingkey=error").Parse(v.Body)
+		if err != nil {
+			return fmt.Errorf("variant %q body: %w", v.Name, err)
+		}
+		compiled = append(compiled, compiledVariant{Variant: v, subject: subject, body: body})
+	}
+	if total == 0 {
+		return errors.New("total variant weight must be greater than zero")
+	}
+
+	r.mu.Lock()
+	r.variants[eventType] = compiled
+	r.mu.Unlock()
+	return nil
+}
+
+// LoadFile registers the variants in a JSON file mapping event types to variant lists.
+// Event types not in the file keep their current variants.
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read templates file: %w", err)
+	}
+
+	var byEvent map[string][]Variant
+	if err := json.Unmarshal(data, &byEvent); err != nil {
+		return fmt.Errorf("failed to parse templates file: %w", err)
+	}
+	for eventType, variants := range byEvent {
+		if err := r.Register(eventType, variants); err != nil {
+			return fmt.Errorf("invalid templates for %s: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// All returns the registered variants keyed by event type
+func (r *Registry) All() map[string][]Variant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make(map[string][]Variant, len(r.variants))
+	for eventType, compiled := range r.variants {
+		variants := make([]Variant, len(compiled))
+		for i, v := range compiled {
+			variants[i] = v.Variant
+		}
+		all[eventType] = variants
+	}
+	return all
+}
+
+// Render picks the recipient's variant for eventType and executes its templates
+func (r *Registry) Render(eventType string, data Data) (Rendered, error) {
+	r.mu.RLock()
+	variants := r.variants[eventType]
+	r.mu.RUnlock()
+
+	if len(variants) == 0 {
+		return Rendered{}, fmt.Errorf("no templates registered for %s", eventType)
+	}
+
+	v := pick(variants, eventType, data.UserID)
+
+	var subject, body bytes.Buffer
+	if err := v.subject.Execute(&subject, data); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render %s/%s subject: %w", eventType, v.Name, err)
+	}
+	if err := v.body.Execute(&body, data); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render %s/%s body: %w", eventType, v.Name, err)
+	}
+
+	return Rendered{Variant: v.Name, Subject: subject.String(), Body: body.String()}, nil
+}
+
+// pick maps the user onto the cumulative weights. Variants are walked in name order so
+// the assignment doesn't depend on registration order.
+func pick(variants []compiledVariant, eventType string, userID int) compiledVariant {
+	ordered := append([]compiledVariant(nil), variants...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Name < ordered[j].Name })
+
+	total := 0
+	for _, v := range ordered {
+		total += v.Weight
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", eventType, userID)
+	point := int(h.Sum32() % uint32(total))
+
+	for _, v := range ordered {
+		if point < v.Weight {
+			return v
+		}
+		point -= v.Weight
+	}
+	return ordered[len(ordered)-1]
+}
@@ -0,0 +1,171 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// After is how old a settled order must be before it is archived
+	After = getEnvDuration("ORDER_ARCHIVE_AFTER", 90*24*time.Hour)
+	// interval is how often old orders are looked for
+	interval = getEnvDuration("ORDER_ARCHIVE_INTERVAL", time.Hour)
+	// batchSize bounds how many orders one transaction locks and moves
+	batchSize = getEnvInt("ORDER_ARCHIVE_BATCH_SIZE", 500)
+)
+
+// Archiver moves settled orders older than After, with their status history, from the
+// orders tables to orders_archive and order_status_history_archive. Reads by ID fall
+// back to the archive, so archived orders stay retrievable.
+type Archiver struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewArchiver(db *sql.DB, logger *zap.Logger) *Archiver {
+	return &Archiver{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Start archives old orders on every tick until ctx is cancelled, moving batches until
+// none are left. Every replica may run it: orders are claimed with FOR UPDATE SKIP
+// LOCKED, so replicas take disjoint batches.
+func (a *Archiver) Start(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				archived, err := a.ArchiveOld(ctx)
+				if err != nil {
+					a.logger.Error("Failed to archive old orders", zap.Error(err))
+					break
+				}
+				if archived < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// ArchiveOld moves one batch of settled orders created more than After ago to the
+// archive tables and returns how many it moved. Orders still waiting on a payment or a
+// refund are left alone, whatever their age.
+func (a *Archiver) ArchiveOld(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "ArchiveOrders")
+	defer span.End()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := claimOld(ctx, tx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// History goes first: deleting the orders cascades to it
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO order_status_history_archive SELECT * FROM order_status_history WHERE order_id = ANY($1)",
+		pq.Array(ids),
+	); err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to archive order history: %w", err)
+	}
+	// archived_at, the archive's last column, takes its default
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO orders_archive SELECT * FROM orders WHERE id = ANY($1)",
+		pq.Array(ids),
+	); err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to archive orders: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM orders WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to delete archived orders: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	span.SetAttributes(attribute.Int("orders.archived", len(ids)))
+	middleware.RecordOrdersArchived(len(ids))
+	a.logger.Info("Archived old orders",
+		zap.Int("count", len(ids)),
+		zap.Int64("first_order_id", ids[0]),
+		zap.Duration("after", After),
+	)
+	return len(ids), nil
+}
+
+// claimOld locks a batch of settled orders older than After, skipping rows another
+// replica or a status change already holds
+func claimOld(ctx context.Context, tx *sql.Tx) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id
+		FROM orders
+		WHERE status NOT IN ($1, $2) AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $3)
+		ORDER BY created_at
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED`,
+		models.OrderStatusPending, models.OrderStatusRefundPending, After.Seconds(), batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
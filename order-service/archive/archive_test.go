@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"context"
+	"testing"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestArchiveOld_MovesOrdersWithHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM orders .* FOR UPDATE SKIP LOCKED").
+		WithArgs(models.OrderStatusPending, models.OrderStatusRefundPending, After.Seconds(), batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(8))
+	mock.ExpectExec("INSERT INTO order_status_history_archive SELECT \\* FROM order_status_history WHERE order_id = ANY\\(\\$1\\)").
+		WithArgs("{3,8}").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("INSERT INTO orders_archive SELECT \\* FROM orders WHERE id = ANY\\(\\$1\\)").
+		WithArgs("{3,8}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM orders WHERE id = ANY\\(\\$1\\)").
+		WithArgs("{3,8}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	archived, err := NewArchiver(db, zaptest.NewLogger(t)).ArchiveOld(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if archived != 2 {
+		t.Errorf("Expected 2 archived orders, got %d", archived)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestArchiveOld_NothingToArchive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM orders .* FOR UPDATE SKIP LOCKED").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	archived, err := NewArchiver(db, zaptest.NewLogger(t)).ArchiveOld(context.Background())
+	if err != nil || archived != 0 {
+		t.Errorf("Expected nothing archived, got %d and %v", archived, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"order-svc/startup"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func InitRedis(logger *zap.Logger) (*redis.Client, error) {
+	host := getEnv("REDIS_HOST", "localhost")
+	port := getEnv("REDIS_PORT", "6379")
+	password := getEnv("REDIS_PASSWORD", "")
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Password: password,
+		DB:       0,
+	})
+
+	err := startup.Wait(logger, "redis", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return rdb.Ping(ctx).Err()
+	})
+	if err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	logger.Info("Redis connection established")
+	return rdb, nil
+}
+
+// OrderTTL is how long an order read from Postgres is served from Redis
+var OrderTTL = getEnvDuration("ORDER_CACHE_TTL", 5*time.Minute)
+
+func GetOrder(ctx context.Context, rdb *redis.Client, id int) ([]byte, error) {
+	key := fmt.Sprintf("order:%d", id)
+	return rdb.Get(ctx, key).Bytes()
+}
+
+func SetOrder(ctx context.Context, rdb *redis.Client, id int, order interface{}, ttl time.Duration) error {
+	key := fmt.Sprintf("order:%d", id)
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	return rdb.Set(ctx, key, data, ttl).Err()
+}
+
+func DeleteOrder(ctx context.Context, rdb *redis.Client, id int) error {
+	key := fmt.Sprintf("order:%d", id)
+	return rdb.Del(ctx, key).Err()
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
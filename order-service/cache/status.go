@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Order status changes are fanned out over Redis pub/sub, since the replica that settles
+// an order is rarely the one serving its WatchOrder stream. Delivery is at most once:
+// watchers that need certainty re-read the order.
+
+func orderStatusChannel(id int) string {
+	return fmt.Sprintf("order:%d:status", id)
+}
+
+// PublishOrderStatus announces a committed status change to the order's watchers
+func PublishOrderStatus(ctx context.Context, rdb *redis.Client, id int, change interface{}) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return rdb.Publish(ctx, orderStatusChannel(id), data).Err()
+}
+
+// SubscribeOrderStatus subscribes to an order's status changes. It returns once Redis has
+// confirmed the subscription, so no change published afterwards is missed.
+func SubscribeOrderStatus(ctx context.Context, rdb *redis.Client, id int) (*redis.PubSub, error) {
+	sub := rdb.Subscribe(ctx, orderStatusChannel(id))
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
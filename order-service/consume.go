@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"order-svc/cache"
+	"order-svc/database"
+	"order-svc/grpc"
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/saga"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newConsumeCmd() *cobra.Command {
+	var replay bool
+	cmd := &cobra.Command{
+		Use:   "consume",
+		Short: "Run only the Kafka consumer that settles orders from payment events",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return consume(logger, replay)
+		}),
+	}
+	cmd.Flags().BoolVar(&replay, "replay", false, "start from the oldest retained event instead of only new ones")
+	return cmd
+}
+
+// consume runs the payment event consumer without the APIs until SIGINT/SIGTERM
+func consume(logger *zap.Logger, replay bool) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	defer redisClient.Close()
+
+	shutdownTracing, err := middleware.InitTracing("order-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	productClient, err := grpc.InitProductClient(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Product gRPC client: %w", err)
+	}
+	defer productClient.Close()
+
+	// Events that fail handling are moved to the DLQ topic
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	consumerGroup, err := kafka.InitConsumer(logger, replay)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	orderSaga := saga.New(db, redisClient, productClient, kafka.SagaPublisher(producer, logger), logger)
+	return kafka.StartConsumer(ctx, consumerGroup, orderSaga, producer, logger)
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestMigrations_ArePaired(t *testing.T) {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+
+	// Every up migration can be rolled back, and nothing else is embedded
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[file] = true
+	}
+	for _, file := range files {
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			if down := strings.TrimSuffix(file, ".up.sql") + ".down.sql"; !names[down] {
+				t.Errorf("Expected %s for %s", down, file)
+			}
+		case strings.HasSuffix(file, ".down.sql"):
+			if up := strings.TrimSuffix(file, ".down.sql") + ".up.sql"; !names[up] {
+				t.Errorf("Expected %s for %s", up, file)
+			}
+		default:
+			t.Errorf("Unexpected migration file %s", file)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS order_sagas;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS order_status_history;
+DROP TABLE IF EXISTS processed_events;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,125 @@
+-- Baseline: the schema as services created it on startup before versioned migrations.
+-- Every statement is idempotent, so databases created that way adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS orders (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'pending',
+	total_price DECIMAL(10, 2) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Stock reservation held in product-service for this order, released if payment fails
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reservation_id VARCHAR(64);
+
+-- Product variant ordered, when the order is for a variant rather than the product itself
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS variant_id INTEGER;
+
+-- Data residency region the order is stored for
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS region VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_orders_region ON orders (region, created_at);
+
+-- Lets the expiry job find stale pending orders without scanning settled ones
+CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders (created_at) WHERE status = 'pending';
+
+-- Back the admin order search filters, each sorted the way results are returned
+CREATE INDEX IF NOT EXISTS idx_orders_created ON orders (created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user ON orders (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_product ON orders (product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_total ON orders (total_price);
+
+-- Product data as it was when the order was placed; NULL for older orders
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS product_name VARCHAR(255);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS unit_price DECIMAL(10, 2);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(6, 4);
+
+-- How total_price was computed; NULL for orders placed before the breakdown was stored
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal DECIMAL(10, 2);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_code VARCHAR(64);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10, 2);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10, 2);
+
+-- Payment events already handled, so redeliveries after a rebalance are skipped
+CREATE TABLE IF NOT EXISTS processed_events (
+	event_id VARCHAR(255) PRIMARY KEY,
+	event_type VARCHAR(50) NOT NULL,
+	order_id INTEGER NOT NULL,
+	processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every status transition, with the event that caused it
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id SERIAL PRIMARY KEY,
+	order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	from_status VARCHAR(50) NOT NULL,
+	to_status VARCHAR(50) NOT NULL,
+	source_event VARCHAR(64) NOT NULL,
+	trace_id VARCHAR(32),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history (order_id, id);
+
+-- Merchant endpoints notified of status changes, and one delivery row per change and
+-- webhook, retried until delivered or out of attempts
+CREATE TABLE IF NOT EXISTS webhooks (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret VARCHAR(128) NOT NULL,
+	statuses TEXT[] NOT NULL DEFAULT '{}',
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id SERIAL PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	order_id INTEGER NOT NULL,
+	to_status VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_status_code INTEGER,
+	last_error TEXT,
+	next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	delivered_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+
+-- Events Kafka didn't accept, kept with their key and headers to be published again
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	message_key TEXT,
+	payload TEXT NOT NULL,
+	headers JSONB NOT NULL DEFAULT '{}',
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE status = 'pending';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_dead ON outbox (id) WHERE status = 'dead';
+
+-- Saga state of each order, from the stock reservation to its confirmation or release.
+-- Rows are written before stock is reserved, so order_id is set once the order exists.
+CREATE TABLE IF NOT EXISTS order_sagas (
+	id SERIAL PRIMARY KEY,
+	order_id INTEGER UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+	reservation_id VARCHAR(64) UNIQUE NOT NULL,
+	step VARCHAR(32) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	deadline_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_order_sagas_due ON order_sagas (deadline_at) WHERE step IN ('reserve_stock', 'confirm_stock', 'release_stock');
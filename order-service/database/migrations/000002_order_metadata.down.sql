@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS notes;
+ALTER TABLE orders DROP COLUMN IF EXISTS metadata;
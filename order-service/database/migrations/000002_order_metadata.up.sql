@@ -0,0 +1,4 @@
+-- Integrator references (cart ID, campaign ID, ...) as string key/value pairs, and a
+-- free-text note, both carried on the order's events
+ALTER TABLE orders ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE orders ADD COLUMN notes TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_status_history_archive;
+DROP TABLE IF EXISTS orders_archive;
@@ -0,0 +1,14 @@
+-- Settled orders older than ORDER_ARCHIVE_AFTER, moved out of orders with their status
+-- history by the archiver so the hot tables stay small. The columns mirror orders and
+-- order_status_history, so a migration adding a column to either adds it here too.
+CREATE TABLE orders_archive (
+	LIKE orders,
+	archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE order_status_history_archive (
+	LIKE order_status_history,
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_order_status_history_archive_order ON order_status_history_archive (order_id, id);
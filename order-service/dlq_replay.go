@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"order-svc/cache"
+	"order-svc/database"
+	"order-svc/grpc"
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/saga"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newDLQReplayCmd() *cobra.Command {
+	var idle time.Duration
+	cmd := &cobra.Command{
+		Use:   "dlq-replay",
+		Short: "Handle dead-lettered order events again, then exit once the DLQ is drained",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return dlqReplay(logger, idle)
+		}),
+	}
+	cmd.Flags().DurationVar(&idle, "idle", 10*time.Second, "exit after no dead letter has arrived for this long")
+	return cmd
+}
+
+// dlqReplay runs the DLQ events through the same handler as the live consumer. Events
+// are handled here rather than republished to payments, which other services consume
+// too and would act on a second time.
+func dlqReplay(logger *zap.Logger, idle time.Duration) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	defer redisClient.Close()
+
+	shutdownTracing, err := middleware.InitTracing("order-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	productClient, err := grpc.InitProductClient(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Product gRPC client: %w", err)
+	}
+	defer productClient.Close()
+
+	// Events that fail again go back to the DLQ
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	consumerGroup, err := kafka.InitDLQConsumer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka DLQ consumer: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	orderSaga := saga.New(db, redisClient, productClient, kafka.SagaPublisher(producer, logger), logger)
+	return kafka.ReplayDeadLetters(ctx, consumerGroup, orderSaga, producer, idle, logger)
+}
@@ -0,0 +1,250 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"order-svc/cache"
+	"order-svc/grpc"
+	"order-svc/kafka"
+	"order-svc/lock"
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/saga"
+	"order-svc/webhook"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// EventOrderExpired is published on the orders topic for every order the job cancels
+	EventOrderExpired = "order_expired"
+
+	// lockName keeps the sweep to one replica per tick
+	lockName = "order-expiry"
+	lockTTL  = 30 * time.Second
+)
+
+var (
+	// PendingTimeout is how long an order may wait for its payment before it is
+	// cancelled and its stock is released, the timeout of the saga's charge_payment step
+	PendingTimeout = saga.PaymentTimeout
+	// interval is how often pending orders are checked for expiry
+	interval = getEnvDuration("ORDER_TIMEOUT_SWEEP_INTERVAL", time.Minute)
+	// batchSize bounds how many orders one sweep locks and cancels
+	batchSize = getEnvInt("ORDER_EXPIRY_BATCH_SIZE", 100)
+)
+
+// Expirer cancels orders whose payment never arrived and gives their stock back
+type Expirer struct {
+	db            *sql.DB
+	redisClient   *redis.Client
+	productClient *grpc.ProductClient
+	producer      kafka.Producer
+	locker        *lock.Locker
+	logger        *zap.Logger
+}
+
+func NewExpirer(db *sql.DB, redisClient *redis.Client, productClient *grpc.ProductClient, producer kafka.Producer, locker *lock.Locker, logger *zap.Logger) *Expirer {
+	return &Expirer{
+		db:            db,
+		redisClient:   redisClient,
+		productClient: productClient,
+		producer:      producer,
+		locker:        locker,
+		logger:        logger,
+	}
+}
+
+// Start expires stale orders on every tick until ctx is cancelled. Each sweep runs under
+// a distributed lock, so only one replica releases stock per tick. Orders are also
+// claimed with FOR UPDATE SKIP LOCKED, which keeps sweeps disjoint when Redis is down.
+func (e *Expirer) Start(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := e.locker.WithLock(ctx, lockName, lockTTL, func(ctx context.Context) error {
+				_, err := e.ExpireStale(ctx)
+				return err
+			})
+			switch {
+			case errors.Is(err, lock.ErrNotAcquired):
+				e.logger.Debug("Order expiry already running on another replica")
+			case err != nil:
+				e.logger.Error("Failed to expire pending orders", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ExpireStale cancels one batch of orders still pending after PendingTimeout and returns
+// how many it cancelled. Each reservation is released before the cancellation commits,
+// so an order whose release fails stays pending and is retried on the next sweep. The
+// rows stay locked until then, so a payment arriving meanwhile waits and then finds the
+// order cancelled.
+func (e *Expirer) ExpireStale(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "ExpireOrders")
+	defer span.End()
+
+	traceID := ""
+	if span.SpanContext().IsValid() {
+		traceID = span.SpanContext().TraceID().String()
+	}
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	orders, err := claimStale(ctx, tx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	var expired []staleOrder
+	for _, order := range orders {
+		// Orders created before reservations have none
+		if order.reservationID.Valid {
+			if _, err := e.productClient.ReleaseStock(ctx, order.reservationID.String); err != nil {
+				e.logger.Error("Failed to release stock for expired order, retrying next sweep",
+					zap.String("trace_id", traceID),
+					zap.Int("order_id", order.OrderID),
+					zap.String("reservation_id", order.reservationID.String),
+					zap.Error(err),
+				)
+				continue
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			models.OrderStatusCancelled, order.OrderID,
+		); err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("failed to cancel order %d: %w", order.OrderID, err)
+		}
+		order.change = models.OrderStatusChange{
+			OrderID:     order.OrderID,
+			FromStatus:  models.OrderStatusPending,
+			ToStatus:    models.OrderStatusCancelled,
+			SourceEvent: EventOrderExpired,
+			TraceID:     traceID,
+		}
+		if err := tx.QueryRowContext(ctx,
+			"INSERT INTO order_status_history (order_id, from_status, to_status, source_event, trace_id) VALUES ($1, $2, $3, $4, NULLIF($5, '')) RETURNING id, created_at",
+			order.OrderID, models.OrderStatusPending, models.OrderStatusCancelled, EventOrderExpired, traceID,
+		).Scan(&order.change.ID, &order.change.CreatedAt); err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("failed to record history for order %d: %w", order.OrderID, err)
+		}
+		if err := webhook.Enqueue(ctx, tx, order.change); err != nil {
+			span.RecordError(err)
+			return 0, err
+		}
+		if order.reservationID.Valid {
+			// The stock is already released, so the saga is done
+			if err := saga.Advance(ctx, tx, order.reservationID.String, models.SagaStepChargePayment, models.SagaStepCompensated); err != nil {
+				span.RecordError(err)
+				return 0, fmt.Errorf("failed to compensate saga of order %d: %w", order.OrderID, err)
+			}
+		}
+		expired = append(expired, order)
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("orders.expired", len(expired)))
+
+	for _, order := range expired {
+		middleware.RecordOrderExpired()
+		if err := cache.DeleteOrder(ctx, e.redisClient, order.OrderID); err != nil {
+			e.logger.Warn("Failed to invalidate order cache", zap.String("trace_id", traceID), zap.Int("order_id", order.OrderID), zap.Error(err))
+		}
+		if err := cache.PublishOrderStatus(ctx, e.redisClient, order.OrderID, order.change); err != nil {
+			e.logger.Warn("Failed to publish order status change", zap.String("trace_id", traceID), zap.Int("order_id", order.OrderID), zap.Error(err))
+		}
+
+		event := order.OrderEvent
+		event.Status = models.OrderStatusCancelled
+		event.EventType = EventOrderExpired
+		if err := kafka.PublishOrderEvent(ctx, e.producer, kafka.OrdersTopic, event, e.logger); err != nil {
+			// The order is already cancelled; only the notification is lost
+			e.logger.Error("Failed to publish order_expired event", zap.String("trace_id", traceID), zap.Int("order_id", order.OrderID), zap.Error(err))
+		}
+
+		e.logger.Info("Expired pending order",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", order.OrderID),
+			zap.Duration("timeout", PendingTimeout),
+		)
+	}
+	return len(expired), nil
+}
+
+// staleOrder is an order claimed for expiry, with the fields its event carries
+type staleOrder struct {
+	models.OrderEvent
+	reservationID sql.NullString
+	change        models.OrderStatusChange
+}
+
+// claimStale locks a batch of expired pending orders, skipping rows another replica or
+// a payment event already holds
+func claimStale(ctx context.Context, tx *sql.Tx) ([]staleOrder, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, user_id, product_id, quantity, total_price, region, reservation_id
+		FROM orders
+		WHERE status = $1 AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $2)
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED`,
+		models.OrderStatusPending, PendingTimeout.Seconds(), batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []staleOrder
+	for rows.Next() {
+		var order staleOrder
+		if err := rows.Scan(&order.OrderID, &order.UserID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Region, &order.reservationID); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestExpireStale_CancelsAndPublishes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	var event models.OrderEvent
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, &event)
+	})
+
+	// Cache misses fall through; the invalidation failure is only logged
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+
+	// The order predates reservations, so no stock is released
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, product_id, quantity, total_price, region, reservation_id FROM orders .* FOR UPDATE SKIP LOCKED").
+		WithArgs(models.OrderStatusPending, PendingTimeout.Seconds(), batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "quantity", "total_price", "region", "reservation_id"}).
+			AddRow(7, 3, 5, 2, 19.98, "us-east-1", nil))
+	mock.ExpectExec("UPDATE orders SET status = \\$1").
+		WithArgs(models.OrderStatusCancelled, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO order_status_history").
+		WithArgs(7, models.OrderStatusPending, models.OrderStatusCancelled, EventOrderExpired, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	expirer := NewExpirer(db, redisClient, nil, producer, nil, zaptest.NewLogger(t))
+	expired, err := expirer.ExpireStale(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("Expected 1 expired order, got %d", expired)
+	}
+
+	if event.EventType != EventOrderExpired || event.OrderID != 7 || event.Status != models.OrderStatusCancelled {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.UserID != 3 || event.ProductID != 5 || event.Quantity != 2 {
+		t.Errorf("Expected event to carry the order details, got %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-svc/circuitbreaker"
+	"order-svc/middleware"
+	"order-svc/proto/payment"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ErrPaymentNotFound is returned by GetPaymentByOrder when the order has no payment yet
+var ErrPaymentNotFound = errors.New("payment not found")
+
+type PaymentClient struct {
+	conn           *grpc.ClientConn
+	client         payment.PaymentServiceClient
+	circuitBreaker *circuitbreaker.CircuitBreaker
+	logger         *zap.Logger
+}
+
+func InitPaymentClient(logger *zap.Logger) (*PaymentClient, error) {
+	address := getEnv("PAYMENT_SERVICE_GRPC", "localhost:50054")
+
+	conn, err := grpc.NewClient(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(middleware.UnaryServiceTokenClientInterceptor(getEnv("GRPC_SERVICE_TOKEN", ""))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Payment Service: %w", err)
+	}
+
+	return &PaymentClient{
+		conn:           conn,
+		client:         payment.NewPaymentServiceClient(conn),
+		circuitBreaker: circuitbreaker.NewCircuitBreaker("payment_service_grpc", 5, 30*time.Second, circuitbreaker.WithStateChange(circuitbreaker.LogStateChanges(logger))),
+		logger:         logger,
+	}, nil
+}
+
+// GetPaymentByOrder returns the payment of an order. Orders that haven't been
+// charged yet have none, which doesn't count against the circuit breaker and is
+// returned as ErrPaymentNotFound.
+func (pc *PaymentClient) GetPaymentByOrder(ctx context.Context, orderID int32) (*payment.GetPaymentByOrderResponse, error) {
+	var resp *payment.GetPaymentByOrderResponse
+	found := true
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		var err error
+		resp, err = pc.client.GetPaymentByOrder(ctx, &payment.GetPaymentByOrderRequest{
+			OrderId: orderID,
+		})
+		if status.Code(err) == codes.NotFound {
+			found = false
+			return nil
+		}
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrPaymentNotFound
+	}
+
+	return resp, nil
+}
+
+// ListPaymentsByUser returns a page of the user's payments, newest first, and how many
+// they have in total. page and limit of 0 take payment-service's defaults.
+func (pc *PaymentClient) ListPaymentsByUser(ctx context.Context, userID, page, limit int32) (*payment.ListPaymentsByUserResponse, error) {
+	var resp *payment.ListPaymentsByUserResponse
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		var err error
+		resp, err = pc.client.ListPaymentsByUser(ctx, &payment.ListPaymentsByUserRequest{
+			UserId: userID,
+			Page:   page,
+			Limit:  limit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (pc *PaymentClient) Close() error {
+	return pc.conn.Close()
+}
@@ -3,10 +3,12 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"order-svc/circuitbreaker"
+	"order-svc/middleware"
 	"order-svc/proto/product"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
@@ -19,6 +21,7 @@ type ProductClient struct {
 	conn           *grpc.ClientConn
 	client         product.ProductServiceClient
 	circuitBreaker *circuitbreaker.CircuitBreaker
+	retry          RetryPolicy
 	logger         *zap.Logger
 }
 
@@ -29,6 +32,8 @@ func InitProductClient(logger *zap.Logger) (*ProductClient, error) {
 		address,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(middleware.UnaryServiceTokenClientInterceptor(getEnv("GRPC_SERVICE_TOKEN", ""))),
+		grpc.WithStreamInterceptor(middleware.StreamServiceTokenClientInterceptor(getEnv("GRPC_SERVICE_TOKEN", ""))),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Product Service: %w", err)
@@ -39,26 +44,32 @@ func InitProductClient(logger *zap.Logger) (*ProductClient, error) {
 	return &ProductClient{
 		conn:           conn,
 		client:         client,
-		circuitBreaker: circuitbreaker.NewCircuitBreaker(5, 30*time.Second),
+		circuitBreaker: circuitbreaker.NewCircuitBreaker("product_service_grpc", 5, 30*time.Second, circuitbreaker.WithStateChange(circuitbreaker.LogStateChanges(logger))),
+		retry:          LoadRetryPolicy(),
 		logger:         logger,
 	}, nil
 }
 
-func (pc *ProductClient) CheckAvailability(ctx context.Context, productID int32, quantity int32) (bool, int32, error) {
+// CheckAvailability checks the stock of the product, or of its variant variantID when
+// that is not 0
+func (pc *ProductClient) CheckAvailability(ctx context.Context, productID int32, variantID int32, quantity int32) (bool, int32, error) {
 	var available bool
 	var stock int32
 
 	err := pc.circuitBreaker.Execute(ctx, func() error {
-		resp, err := pc.client.CheckAvailability(ctx, &product.CheckAvailabilityRequest{
-			ProductId: productID,
-			Quantity:  quantity,
+		return pc.retry.Do(ctx, "CheckAvailability", func() error {
+			resp, err := pc.client.CheckAvailability(ctx, &product.CheckAvailabilityRequest{
+				ProductId: productID,
+				VariantId: variantID,
+				Quantity:  quantity,
+			})
+			if err != nil {
+				return err
+			}
+			available = resp.GetAvailable()
+			stock = resp.GetStock()
+			return nil
 		})
-		if err != nil {
-			return err
-		}
-		available = resp.GetAvailable()
-		stock = resp.GetStock()
-		return nil
 	})
 
 	if err != nil {
@@ -72,11 +83,34 @@ func (pc *ProductClient) GetProduct(ctx context.Context, productID int32) (*prod
 	var resp *product.GetProductResponse
 
 	err := pc.circuitBreaker.Execute(ctx, func() error {
-		var err error
-		resp, err = pc.client.GetProduct(ctx, &product.GetProductRequest{
-			ProductId: productID,
+		return pc.retry.Do(ctx, "GetProduct", func() error {
+			var err error
+			resp, err = pc.client.GetProduct(ctx, &product.GetProductRequest{
+				ProductId: productID,
+			})
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// GetVariant returns a product variant with its own price and stock
+func (pc *ProductClient) GetVariant(ctx context.Context, variantID int32) (*product.ProductVariant, error) {
+	var resp *product.ProductVariant
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		return pc.retry.Do(ctx, "GetVariant", func() error {
+			var err error
+			resp, err = pc.client.GetVariant(ctx, &product.GetVariantRequest{
+				VariantId: variantID,
+			})
+			return err
 		})
-		return err
 	})
 
 	if err != nil {
@@ -86,6 +120,110 @@ func (pc *ProductClient) GetProduct(ctx context.Context, productID int32) (*prod
 	return resp, nil
 }
 
+// ReserveStock takes quantity units out of stock under reservationID, from the variant
+// variantID when that is not 0. reserved is false when there isn't enough stock, in
+// which case stock holds what is left.
+func (pc *ProductClient) ReserveStock(ctx context.Context, reservationID string, productID int32, variantID int32, quantity int32) (bool, int32, error) {
+	var reserved bool
+	var stock int32
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		resp, err := pc.client.ReserveStock(ctx, &product.ReserveStockRequest{
+			ReservationId: reservationID,
+			ProductId:     productID,
+			VariantId:     variantID,
+			Quantity:      quantity,
+		})
+		if err != nil {
+			return err
+		}
+		reserved = resp.GetReserved()
+		stock = resp.GetStock()
+		return nil
+	})
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	return reserved, stock, nil
+}
+
+// ReleaseStock returns a reservation's units to stock. It is safe to call more than once.
+func (pc *ProductClient) ReleaseStock(ctx context.Context, reservationID string) (bool, error) {
+	var released bool
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		resp, err := pc.client.ReleaseStock(ctx, &product.ReleaseStockRequest{
+			ReservationId: reservationID,
+		})
+		if err != nil {
+			return err
+		}
+		released = resp.GetReleased()
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return released, nil
+}
+
+// ConfirmStock settles a reservation once its order is paid. It is safe to call more
+// than once; confirmed is false if the reservation was already released.
+func (pc *ProductClient) ConfirmStock(ctx context.Context, reservationID string) (bool, error) {
+	var confirmed bool
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		resp, err := pc.client.ConfirmStock(ctx, &product.ConfirmStockRequest{
+			ReservationId: reservationID,
+		})
+		if err != nil {
+			return err
+		}
+		confirmed = resp.GetConfirmed()
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return confirmed, nil
+}
+
+// ListProducts streams the catalog after afterID, calling handle for each product in id
+// order. It returns the id of the last product handled, so a caller whose stream broke
+// can resume from there.
+func (pc *ProductClient) ListProducts(ctx context.Context, afterID int32, handle func(*product.GetProductResponse) error) (int32, error) {
+	lastID := afterID
+
+	err := pc.circuitBreaker.Execute(ctx, func() error {
+		stream, err := pc.client.ListProducts(ctx, &product.ListProductsRequest{AfterId: afterID})
+		if err != nil {
+			return err
+		}
+
+		for {
+			p, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := handle(p); err != nil {
+				return err
+			}
+			lastID = p.GetId()
+		}
+	})
+
+	return lastID, err
+}
+
 func (pc *ProductClient) Close() error {
 	return pc.conn.Close()
 }
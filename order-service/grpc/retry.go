@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy retries idempotent product-service reads that failed with a transient
+// status code. It runs inside the circuit breaker, so a call that succeeds on retry
+// doesn't count as a failure and one that exhausts its attempts counts once.
+type RetryPolicy struct {
+	// MaxAttempts includes the first call; 1 disables retries
+	MaxAttempts int
+	// BaseBackoff is the backoff ceiling after the first failure, doubled each retry up
+	// to MaxBackoff. The actual wait is a random duration below the ceiling (full
+	// jitter), so callers that failed together don't retry together.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// LoadRetryPolicy reads PRODUCT_GRPC_RETRY_ATTEMPTS (default 3),
+// PRODUCT_GRPC_RETRY_BACKOFF (default 50ms) and PRODUCT_GRPC_RETRY_MAX_BACKOFF
+// (default 400ms)
+func LoadRetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 50 * time.Millisecond,
+		MaxBackoff:  400 * time.Millisecond,
+	}
+	if n, err := strconv.Atoi(os.Getenv("PRODUCT_GRPC_RETRY_ATTEMPTS")); err == nil && n > 0 {
+		policy.MaxAttempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("PRODUCT_GRPC_RETRY_BACKOFF")); err == nil && d > 0 {
+		policy.BaseBackoff = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("PRODUCT_GRPC_RETRY_MAX_BACKOFF")); err == nil && d > 0 {
+		policy.MaxBackoff = d
+	}
+	return policy
+}
+
+// retryableCodes are the statuses a repeated read can plausibly get past. Deadline
+// errors aren't retried: calls carry the caller's deadline, which has already passed.
+var retryableCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+}
+
+// Do calls fn until it succeeds, fails with a non-retryable code, runs out of attempts,
+// or ctx is done, and returns the last error
+func (p RetryPolicy) Do(ctx context.Context, method string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryableCodes[status.Code(err)] {
+			return err
+		}
+
+		middleware.RecordGRPCRetry(method, status.Code(err).String())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.backoff(attempt)):
+		}
+	}
+}
+
+// backoff returns the jittered wait after the given failed attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxBackoff
+	if shift := attempt - 1; shift < 31 {
+		if d := p.BaseBackoff << shift; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
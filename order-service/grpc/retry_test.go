@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	calls := 0
+	err := policy.Do(context.Background(), "GetProduct", func() error {
+		calls++
+		if calls < 3 {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success on the third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryPolicy_StopsOnPermanentErrorsAndMaxAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	calls := 0
+	err := policy.Do(context.Background(), "GetProduct", func() error {
+		calls++
+		return status.Error(codes.NotFound, "product not found")
+	})
+	if status.Code(err) != codes.NotFound || calls != 1 {
+		t.Errorf("Expected one NotFound call, got %d calls and %v", calls, err)
+	}
+
+	calls = 0
+	err = policy.Do(context.Background(), "GetProduct", func() error {
+		calls++
+		return status.Error(codes.Unavailable, "connection refused")
+	})
+	if status.Code(err) != codes.Unavailable || calls != 3 {
+		t.Errorf("Expected 3 Unavailable calls, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryPolicy_BackoffIsCapped(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseBackoff: 50 * time.Millisecond, MaxBackoff: 400 * time.Millisecond}
+
+	for attempt := 1; attempt <= 40; attempt++ {
+		if d := policy.backoff(attempt); d < 0 || d > policy.MaxBackoff {
+			t.Fatalf("Backoff %s after attempt %d is outside [0, %s]", d, attempt, policy.MaxBackoff)
+		}
+	}
+	if d := policy.backoff(1); d > policy.BaseBackoff {
+		t.Errorf("Expected the first backoff to be at most %s, got %s", policy.BaseBackoff, d)
+	}
+}
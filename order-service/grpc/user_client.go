@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-svc/circuitbreaker"
+	"order-svc/middleware"
+	"order-svc/proto/user"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUserNotFound is returned by GetUser when user-service has no user with the ID
+var ErrUserNotFound = errors.New("user not found")
+
+type UserClient struct {
+	conn           *grpc.ClientConn
+	client         user.UserServiceClient
+	circuitBreaker *circuitbreaker.CircuitBreaker
+	logger         *zap.Logger
+}
+
+func InitUserClient(logger *zap.Logger) (*UserClient, error) {
+	address := getEnv("USER_SERVICE_GRPC", "localhost:50053")
+
+	conn, err := grpc.NewClient(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(middleware.UnaryServiceTokenClientInterceptor(getEnv("GRPC_SERVICE_TOKEN", ""))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to User Service: %w", err)
+	}
+
+	return &UserClient{
+		conn:           conn,
+		client:         user.NewUserServiceClient(conn),
+		circuitBreaker: circuitbreaker.NewCircuitBreaker("user_service_grpc", 5, 30*time.Second, circuitbreaker.WithStateChange(circuitbreaker.LogStateChanges(logger))),
+		logger:         logger,
+	}, nil
+}
+
+// GetRiskScore returns the user's payment risk score in [0, 1)
+func (uc *UserClient) GetRiskScore(ctx context.Context, userID int32) (float64, error) {
+	var score float64
+
+	err := uc.circuitBreaker.Execute(ctx, func() error {
+		resp, err := uc.client.GetRiskScore(ctx, &user.GetRiskScoreRequest{
+			UserId: userID,
+		})
+		if err != nil {
+			return err
+		}
+		score = resp.GetRiskScore()
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return score, nil
+}
+
+// GetUser looks up a user. An unknown user is an answer rather than a failing service,
+// so it doesn't count against the circuit breaker and is returned as ErrUserNotFound.
+func (uc *UserClient) GetUser(ctx context.Context, userID int32) (*user.GetUserResponse, error) {
+	var resp *user.GetUserResponse
+	found := true
+
+	err := uc.circuitBreaker.Execute(ctx, func() error {
+		var err error
+		resp, err = uc.client.GetUser(ctx, &user.GetUserRequest{
+			UserId: userID,
+		})
+		if status.Code(err) == codes.NotFound {
+			found = false
+			return nil
+		}
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrUserNotFound
+	}
+
+	return resp, nil
+}
+
+func (uc *UserClient) Close() error {
+	return uc.conn.Close()
+}
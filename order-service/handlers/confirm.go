@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ConfirmOrder tells payment-service to capture a pending order's payment with an
+// order_confirmed event. It only matters when payment-service holds payments as
+// authorizations (PAYMENT_CAPTURE_MODE=deferred); the payment_success that follows the
+// capture moves the order to paid as usual.
+func (h *OrderHandler) ConfirmOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ConfirmOrder")
+	defer span.End()
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("order.id", orderID))
+	traceID := middleware.GetTraceID(ctx)
+
+	var order models.Order
+	err = scanOrder(h.db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders WHERE id = $1", orderID), &order)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if order.Status != models.OrderStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only pending orders can be confirmed", "status": order.Status})
+		return
+	}
+
+	// One event ID per order, so confirming twice captures once
+	event := models.OrderEvent{
+		EventID:    fmt.Sprintf("confirm-%d", order.ID),
+		OrderID:    order.ID,
+		UserID:     order.UserID,
+		ProductID:  order.ProductID,
+		Quantity:   order.Quantity,
+		Status:     order.Status,
+		TotalPrice: order.TotalPrice,
+		Region:     order.Region,
+		Metadata:   order.Metadata,
+		Notes:      order.Notes,
+		EventType:  "order_confirmed",
+	}
+	if err := kafka.PublishOrderEvent(ctx, h.producer, kafka.OrdersTopic, event, h.logger); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to publish order_confirmed event", zap.String("trace_id", traceID), zap.Int("order_id", orderID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Order could not be confirmed, try again later"})
+		return
+	}
+
+	h.logger.Info("Order confirmed", zap.String("trace_id", traceID), zap.Int("order_id", orderID))
+	c.JSON(http.StatusAccepted, order)
+}
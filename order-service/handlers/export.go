@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/region"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultExportLimit = 1000
+	maxExportLimit     = 10000
+)
+
+// ExportOrders returns the orders stored for one region, newest first. The region is
+// required so an export never mixes data from different residency regions.
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ExportOrders")
+	defer span.End()
+
+	exportRegion := c.Query("region")
+	if exportRegion == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "region is required", "allowed_regions": h.regions.Allowed})
+		return
+	}
+	if !h.regions.Allows(exportRegion) {
+		c.JSON(http.StatusForbidden, gin.H{"error": region.ErrNotAllowed.Error(), "allowed_regions": h.regions.Allowed})
+		return
+	}
+
+	limit := defaultExportLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxExportLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxExportLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	span.SetAttributes(attribute.String("region", exportRegion))
+
+	query := "SELECT " + orderColumns + " FROM orders WHERE region = $1"
+	args := []interface{}{exportRegion}
+	if status := c.Query("status"); status != "" {
+		args = append(args, status)
+		query += " AND status = $" + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	query += " ORDER BY created_at DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to export orders", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan order", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to export orders", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("orders.count", len(orders)))
+	h.logger.Info("Orders exported", zap.String("region", exportRegion), zap.Int("count", len(orders)))
+	c.JSON(http.StatusOK, gin.H{"region": exportRegion, "count": len(orders), "data": orders})
+}
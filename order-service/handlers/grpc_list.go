@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"order-svc/models"
+	order "order-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const defaultListPageSize = 20
+
+// ListOrders returns one page of a user's orders, newest first
+func (s *OrderService) ListOrders(ctx context.Context, req *order.ListOrdersRequest) (*order.ListOrdersResponse, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "ListOrders_gRPC")
+	defer span.End()
+
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+	span.SetAttributes(
+		attribute.Int("user_id", int(req.GetUserId())),
+		attribute.Int("list.before_id", int(req.GetBeforeId())),
+		attribute.Int("list.page_size", pageSize),
+	)
+
+	// Fetch one extra row to tell whether another page follows
+	orders, err := s.listOrdersBefore(ctx, int(req.GetUserId()), int(req.GetBeforeId()), pageSize+1)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	resp := &order.ListOrdersResponse{}
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		resp.NextBeforeId = int32(orders[pageSize-1].ID)
+	}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, orderToProto(o))
+	}
+	span.SetAttributes(attribute.Int("list.returned", len(resp.Orders)))
+	return resp, nil
+}
+
+// listOrdersBefore reads a user's orders with an id below the cursor, newest first. A
+// cursor of 0 starts from the newest order.
+func (s *OrderService) listOrdersBefore(ctx context.Context, userID, beforeID, limit int) ([]models.Order, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE user_id = $1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3",
+		userID, beforeID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
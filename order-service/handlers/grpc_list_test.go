@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-svc/models"
+	order "order-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func listRows(ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"})
+	for _, id := range ids {
+		rows.AddRow(id, 5, 1, nil, 1, models.OrderStatusPaid, 10.99, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now())
+	}
+	return rows
+}
+
+func TestOrderService_ListOrders_PagesNewestFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	service := &OrderService{db: db, logger: zaptest.NewLogger(t)}
+
+	query := "SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE user_id = \\$1 AND \\(\\$2 = 0 OR id < \\$2\\) ORDER BY id DESC LIMIT \\$3"
+	// One extra row is read to detect the next page
+	mock.ExpectQuery(query).WithArgs(5, 0, 3).WillReturnRows(listRows(9, 8, 6))
+	mock.ExpectQuery(query).WithArgs(5, 8, 3).WillReturnRows(listRows(6))
+
+	first, err := service.ListOrders(context.Background(), &order.ListOrdersRequest{UserId: 5, PageSize: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(first.GetOrders()) != 2 || first.GetOrders()[0].GetId() != 9 || first.GetOrders()[1].GetId() != 8 {
+		t.Fatalf("Expected orders 9 and 8, got %v", first.GetOrders())
+	}
+	if first.GetNextBeforeId() != 8 {
+		t.Errorf("Expected next_before_id 8, got %d", first.GetNextBeforeId())
+	}
+
+	last, err := service.ListOrders(context.Background(), &order.ListOrdersRequest{UserId: 5, PageSize: 2, BeforeId: first.GetNextBeforeId()})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(last.GetOrders()) != 1 || last.GetOrders()[0].GetId() != 6 {
+		t.Fatalf("Expected order 6, got %v", last.GetOrders())
+	}
+	if last.GetNextBeforeId() != 0 {
+		t.Errorf("Expected the last page to have no cursor, got %d", last.GetNextBeforeId())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderService_GetOrder_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	service := &OrderService{db: db, logger: zaptest.NewLogger(t)}
+
+	// Neither live nor archived
+	mock.ExpectQuery("FROM orders WHERE id = \\$1").WithArgs(42).WillReturnRows(listRows())
+	mock.ExpectQuery("FROM orders_archive WHERE id = \\$1").WithArgs(42).WillReturnRows(listRows())
+
+	_, err = service.GetOrder(context.Background(), &order.GetOrderRequest{OrderId: 42})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
@@ -3,37 +3,58 @@ package handlers
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"order-svc/grpc"
 	"order-svc/kafka"
 	"order-svc/models"
 	order "order-svc/proto"
+	"order-svc/region"
+	"order-svc/saga"
 
-	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// regionMetadataKey is the gRPC counterpart of the X-Region header
+const regionMetadataKey = "x-region"
+
 type OrderService struct {
 	order.UnimplementedOrderServiceServer
 
 	db            *sql.DB
-	producer      sarama.SyncProducer
+	redisClient   *redis.Client
+	producer      kafka.Producer
 	productClient *grpc.ProductClient
+	saga          *saga.Saga
+	validator     *orderValidator
+	regions       region.Config
 	logger        *zap.Logger
 }
 
 func NewOrderService(
 	db *sql.DB,
-	producer sarama.SyncProducer,
+	redisClient *redis.Client,
+	producer kafka.Producer,
 	productClient *grpc.ProductClient,
+	userClient *grpc.UserClient,
 	logger *zap.Logger,
 ) *OrderService {
 	return &OrderService{
 		db:            db,
+		redisClient:   redisClient,
 		producer:      producer,
 		productClient: productClient,
+		saga:          saga.New(db, redisClient, productClient, kafka.SagaPublisher(producer, logger), logger),
+		validator:     newOrderValidator(productClient, userClient, logger),
+		regions:       region.Load(),
 		logger:        logger,
 	}
 }
@@ -45,70 +66,131 @@ func (s *OrderService) CreateOrder(
 	ctx, span := otel.Tracer("order-service").Start(ctx, "CreateOrder_gRPC")
 	defer span.End()
 
+	var requestedRegion string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(regionMetadataKey); len(values) > 0 {
+			requestedRegion = values[0]
+		}
+	}
+	orderRegion, err := s.regions.Resolve(requestedRegion)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	span.SetAttributes(
 		attribute.Int("user_id", int(req.GetUserId())),
 		attribute.Int("product_id", int(req.GetProductId())),
+		attribute.Int("variant_id", int(req.GetVariantId())),
 		attribute.Int("quantity", int(req.GetQuantity())),
+		attribute.String("region", orderRegion),
 	)
 
-	// Check product availability
-	available, stock, err := s.productClient.CheckAvailability(ctx, req.GetProductId(), req.GetQuantity())
+	// Validate and price the order; the stock reservation below is the availability check
+	validation, err := s.validator.Validate(ctx, models.CreateOrderRequest{
+		UserID:       int(req.GetUserId()),
+		ProductID:    int(req.GetProductId()),
+		VariantID:    int(req.GetVariantId()),
+		Quantity:     int(req.GetQuantity()),
+		DiscountCode: req.GetDiscountCode(),
+		Metadata:     req.GetMetadata(),
+		Notes:        req.GetNotes(),
+		Currency:     req.GetCurrency(),
+	}, orderRegion, false)
 	if err != nil {
 		span.RecordError(err)
 		return nil, err
 	}
-
-	if !available {
-		span.SetAttributes(
-			attribute.Bool("available", false),
-			attribute.Int("stock", int(stock)),
-		)
+	if !validation.Valid {
+		span.SetAttributes(attribute.Bool("valid", false))
 		return &order.CreateOrderResponse{
 			Success: false,
-			Message: "Product not available",
+			Message: validation.Errors[0].Message,
 		}, nil
 	}
 
-	// Get product details
-	productResp, err := s.productClient.GetProduct(ctx, req.GetProductId())
+	totalPrice := validation.TotalPrice
+
+	// Reserve stock; every failure after this point releases the reservation
+	reservationID := uuid.NewString()
+	span.SetAttributes(attribute.String("reservation.id", reservationID))
+
+	if err := s.saga.Begin(ctx, reservationID); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	reserved, stock, err := s.productClient.ReserveStock(ctx, reservationID, req.GetProductId(), req.GetVariantId(), req.GetQuantity())
 	if err != nil {
 		span.RecordError(err)
+		s.saga.Abort(ctx, reservationID)
 		return nil, err
 	}
 
-	totalPrice := float64(req.GetQuantity()) * float64(productResp.GetPrice())
+	if !reserved {
+		s.saga.Rejected(ctx, reservationID)
+		span.SetAttributes(
+			attribute.Bool("available", false),
+			attribute.Int("stock", int(stock)),
+		)
+		return &order.CreateOrderResponse{
+			Success: false,
+			Message: "Product not available",
+		}, nil
+	}
 
 	// Create order
 	var orderModel models.Order
-	err = s.db.QueryRowContext(
+	err = scanOrder(s.db.QueryRowContext(
 		ctx,
-		"INSERT INTO orders (user_id, product_id, quantity, status, total_price) VALUES ($1, $2, $3, $4, $5) RETURNING id, user_id, product_id, quantity, status, total_price, created_at, updated_at",
+		"INSERT INTO orders (user_id, product_id, quantity, status, total_price, reservation_id, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, variant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, NULLIF($16, ''), NULLIF($17, 0)) RETURNING "+orderColumns,
 		req.GetUserId(),
 		req.GetProductId(),
 		req.GetQuantity(),
 		models.OrderStatusPending,
 		totalPrice,
-	).Scan(&orderModel.ID, &orderModel.UserID, &orderModel.ProductID, &orderModel.Quantity, &orderModel.Status, &orderModel.TotalPrice, &orderModel.CreatedAt, &orderModel.UpdatedAt)
+		reservationID,
+		orderRegion,
+		validation.ProductName,
+		validation.UnitPrice,
+		validation.TaxRate,
+		validation.Pricing.Subtotal,
+		validation.Pricing.DiscountCode,
+		validation.Pricing.Discount,
+		validation.Pricing.Tax,
+		metadataJSON(req.GetMetadata()),
+		req.GetNotes(),
+		req.GetVariantId(),
+	), &orderModel)
 
 	if err != nil {
 		span.RecordError(err)
+		s.saga.Abort(ctx, reservationID)
 		return nil, err
 	}
 
 	span.SetAttributes(attribute.Int("order.id", orderModel.ID))
+	recordOrderCreated(ctx, orderModel)
+	s.saga.Reserved(ctx, reservationID, orderModel.ID)
 
 	// Publish event
 	event := models.OrderEvent{
-		OrderID:    orderModel.ID,
-		UserID:     orderModel.UserID,
-		ProductID:  orderModel.ProductID,
-		Quantity:   orderModel.Quantity,
-		Status:     orderModel.Status,
-		TotalPrice: orderModel.TotalPrice,
-		EventType:  "order_created",
+		OrderID:         orderModel.ID,
+		UserID:          orderModel.UserID,
+		ProductID:       orderModel.ProductID,
+		Quantity:        orderModel.Quantity,
+		Status:          orderModel.Status,
+		TotalPrice:      orderModel.TotalPrice,
+		Pricing:         orderModel.Pricing,
+		Region:          orderModel.Region,
+		Metadata:        orderModel.Metadata,
+		Notes:           orderModel.Notes,
+		PaymentMethodID: int(req.GetPaymentMethodId()),
+		Currency:        validation.Currency,
+		MerchantID:      req.GetMerchantId(),
+		EventType:       "order_created",
 	}
 
-	if err := kafka.PublishOrderEvent(ctx, s.producer, "order_events", event, s.logger); err != nil {
+	if err := kafka.PublishOrderEvent(ctx, s.producer, kafka.OrdersTopic, event, s.logger); err != nil {
 		span.RecordError(err)
 		s.logger.Error("Failed to publish order_created event", zap.Error(err))
 		// Don't fail the request, but log the error
@@ -128,26 +210,62 @@ func (s *OrderService) GetOrder(ctx context.Context, req *order.GetOrderRequest)
 	span.SetAttributes(attribute.Int("order.id", int(req.GetOrderId())))
 
 	var orderModel models.Order
-	err := s.db.QueryRowContext(ctx,
-		"SELECT id, user_id, product_id, quantity, status, total_price FROM orders WHERE id = $1",
-		req.GetOrderId(),
-	).Scan(&orderModel.ID, &orderModel.UserID, &orderModel.ProductID, &orderModel.Quantity, &orderModel.Status, &orderModel.TotalPrice)
-
+	archived, err := getOrder(ctx, s.db, int(req.GetOrderId()), &orderModel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			span.RecordError(err)
-			return nil, err
-		}
 		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttributes(attribute.Bool("order.archived", archived))
 
-	return &order.GetOrderResponse{
-		Id:         int32(orderModel.ID),
-		UserId:     int32(orderModel.UserID),
-		ProductId:  int32(orderModel.ProductID),
-		Quantity:   int32(orderModel.Quantity),
-		Status:     string(orderModel.Status),
-		TotalPrice: float32(orderModel.TotalPrice),
-	}, nil
+	return orderToProto(orderModel), nil
+}
+
+// orderToProto converts an order for gRPC and protobuf REST responses
+func orderToProto(o models.Order) *order.GetOrderResponse {
+	resp := &order.GetOrderResponse{
+		Id:         int32(o.ID),
+		UserId:     int32(o.UserID),
+		ProductId:  int32(o.ProductID),
+		Quantity:   int32(o.Quantity),
+		Status:     string(o.Status),
+		TotalPrice: float32(o.TotalPrice),
+		Region:     o.Region,
+	}
+	if o.VariantID != nil {
+		resp.VariantId = int32(*o.VariantID)
+	}
+	if o.Snapshot != nil {
+		resp.ProductSnapshot = &order.ProductSnapshot{
+			ProductName: o.Snapshot.ProductName,
+			UnitPrice:   float32(o.Snapshot.UnitPrice),
+			TaxRate:     float32(o.Snapshot.TaxRate),
+		}
+	}
+	if o.Pricing != nil {
+		resp.Pricing = &order.PriceBreakdown{
+			Subtotal:     float32(o.Pricing.Subtotal),
+			DiscountCode: o.Pricing.DiscountCode,
+			Discount:     float32(o.Pricing.Discount),
+			TaxRate:      float32(o.Pricing.TaxRate),
+			Tax:          float32(o.Pricing.Tax),
+			Total:        float32(o.Pricing.Total),
+		}
+	}
+	if o.Payment != nil {
+		resp.Payment = &order.PaymentDetails{
+			PaymentId:     int32(o.Payment.PaymentID),
+			TransactionId: o.Payment.TransactionID,
+			Status:        o.Payment.Status,
+			Amount:        float32(o.Payment.Amount),
+			Currency:      o.Payment.Currency,
+			CreatedAt:     o.Payment.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:     o.Payment.UpdatedAt.Format(time.RFC3339),
+		}
+	}
+	resp.Metadata = o.Metadata
+	resp.Notes = o.Notes
+	return resp
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"order-svc/cache"
+	"order-svc/models"
+	order "order-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchOrder streams an order's status changes until it settles or the client leaves
+func (s *OrderService) WatchOrder(req *order.WatchOrderRequest, stream grpc.ServerStreamingServer[order.OrderStatusUpdate]) error {
+	ctx, span := otel.Tracer("order-service").Start(stream.Context(), "WatchOrder_gRPC")
+	defer span.End()
+
+	orderID := int(req.GetOrderId())
+	span.SetAttributes(attribute.Int("order.id", orderID))
+
+	// Subscribe before reading the status, so a change in between isn't missed
+	sub, err := cache.SubscribeOrderStatus(ctx, s.redisClient, orderID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to subscribe to order status", zap.Int("order_id", orderID), zap.Error(err))
+		return status.Error(codes.Unavailable, "order status updates are unavailable")
+	}
+	defer sub.Close()
+
+	var current models.OrderStatus
+	err = s.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return status.Error(codes.NotFound, "order not found")
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if err := stream.Send(&order.OrderStatusUpdate{OrderId: int32(orderID), Status: string(current)}); err != nil {
+		return err
+	}
+
+	updates := sub.Channel()
+	sent := 1
+	for !current.Settled() {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case msg, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "order status updates stopped")
+			}
+
+			var change models.OrderStatusChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				s.logger.Warn("Ignoring malformed order status change", zap.Int("order_id", orderID), zap.Error(err))
+				continue
+			}
+			// Already reflected in the status read above
+			if change.FromStatus != current {
+				continue
+			}
+
+			if err := stream.Send(&order.OrderStatusUpdate{
+				OrderId:        int32(orderID),
+				Status:         string(change.ToStatus),
+				PreviousStatus: string(change.FromStatus),
+				SourceEvent:    change.SourceEvent,
+				ChangedAt:      change.CreatedAt.UTC().Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+			current = change.ToStatus
+			sent++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("watch.sent", sent), attribute.String("order.status", string(current)))
+	return nil
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/protobuf/proto"
+)
+
+// protobufResponses enables Accept: application/x-protobuf on REST endpoints.
+// Set REST_PROTOBUF_ENABLED=false to always answer with JSON.
+var protobufResponses = os.Getenv("REST_PROTOBUF_ENABLED") != "false"
+
+// respond writes body as JSON, or the message built by toProto when the client prefers
+// protobuf. JSON stays the default for clients that send no Accept header or */*.
+// Error responses are always JSON.
+func respond(c *gin.Context, status int, body any, toProto func() (proto.Message, error)) {
+	c.Header("Vary", "Accept")
+	if protobufResponses && c.NegotiateFormat(binding.MIMEJSON, binding.MIMEPROTOBUF) == binding.MIMEPROTOBUF {
+		msg, err := toProto()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		c.ProtoBuf(status, msg)
+		return
+	}
+	c.JSON(status, body)
+}
@@ -1,40 +1,70 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"order-svc/cache"
 	"order-svc/grpc"
 	"order-svc/kafka"
 	"order-svc/middleware"
 	"order-svc/models"
+	"order-svc/proto/payment"
+	"order-svc/region"
+	"order-svc/saga"
 
-	"github.com/IBM/sarama"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
 type OrderHandler struct {
 	db            *sql.DB
-	producer      sarama.SyncProducer
+	redisClient   *redis.Client
+	producer      kafka.Producer
 	productClient *grpc.ProductClient
+	paymentClient *grpc.PaymentClient
+	saga          *saga.Saga
+	validator     *orderValidator
+	regions       region.Config
 	logger        *zap.Logger
 }
 
+// orderColumns is the column list scanned by scanOrder
+const orderColumns = "id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at"
+
+// regionHeader lets clients pin where an order's data is stored; the home region is used otherwise
+const regionHeader = "X-Region"
+
 func NewOrderHandler(
 	db *sql.DB,
-	producer sarama.SyncProducer,
+	redisClient *redis.Client,
+	producer kafka.Producer,
 	productClient *grpc.ProductClient,
+	userClient *grpc.UserClient,
+	paymentClient *grpc.PaymentClient,
 	logger *zap.Logger,
 ) *OrderHandler {
 	return &OrderHandler{
 		db:            db,
+		redisClient:   redisClient,
 		producer:      producer,
 		productClient: productClient,
+		paymentClient: paymentClient,
+		saga:          saga.New(db, redisClient, productClient, kafka.SagaPublisher(producer, logger), logger),
+		validator:     newOrderValidator(productClient, userClient, logger),
+		regions:       region.Load(),
 		logger:        logger,
 	}
 }
@@ -48,78 +78,151 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.MerchantID != "" && !models.ValidMerchantID(req.MerchantID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant_id"})
+		return
+	}
+
+	// Orders are placed for the authenticated user, never on behalf of someone else
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user_id does not match the authenticated user"})
+		return
+	}
+	req.UserID = userID
+
+	orderRegion, err := h.regions.Resolve(c.GetHeader(regionHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_regions": h.regions.Allowed})
+		return
+	}
 
 	span.SetAttributes(
 		attribute.Int("user_id", req.UserID),
 		attribute.Int("product_id", req.ProductID),
+		attribute.Int("variant_id", req.VariantID),
 		attribute.Int("quantity", req.Quantity),
+		attribute.String("region", orderRegion),
 	)
 
-	// Check product availability via gRPC
-	available, stock, err := h.productClient.CheckAvailability(ctx, int32(req.ProductID), int32(req.Quantity))
+	// Validate and price the order; the stock reservation below is the availability check
+	validation, err := h.validator.Validate(ctx, req, orderRegion, false)
 	if err != nil {
 		traceID := middleware.GetTraceID(ctx)
 		span.RecordError(err)
-		h.logger.Error("Failed to check product availability", zap.String("trace_id", traceID), zap.Error(err))
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Product service unavailable"})
+		h.logger.Error("Failed to validate order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": unavailableMessage(err)})
 		return
 	}
-
-	if !available {
-		span.SetAttributes(attribute.Bool("available", false))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Product not available",
-			"stock": stock,
+	if !validation.Valid {
+		span.SetAttributes(attribute.Bool("valid", false))
+		// An unknown user is well-formed but can't be ordered for
+		code := http.StatusBadRequest
+		if validation.HasError(validationUserNotFound) {
+			code = http.StatusUnprocessableEntity
+		}
+		c.JSON(code, gin.H{
+			"error":  validation.Errors[0].Message,
+			"errors": validation.Errors,
 		})
 		return
 	}
 
-	// Get product details to calculate total price
-	productResp, err := h.productClient.GetProduct(ctx, int32(req.ProductID))
+	totalPrice := validation.TotalPrice
+
+	// Reserve stock first so concurrent orders cannot oversell; every failure after this point releases it
+	reservationID := uuid.NewString()
+	span.SetAttributes(attribute.String("reservation.id", reservationID))
+
+	if err := h.saga.Begin(ctx, reservationID); err != nil {
+		traceID := middleware.GetTraceID(ctx)
+		span.RecordError(err)
+		h.logger.Error("Failed to start order saga", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	reserved, stock, err := h.productClient.ReserveStock(ctx, reservationID, int32(req.ProductID), int32(req.VariantID), int32(req.Quantity))
 	if err != nil {
 		traceID := middleware.GetTraceID(ctx)
 		span.RecordError(err)
-		h.logger.Error("Failed to get product details", zap.String("trace_id", traceID), zap.Error(err))
+		h.logger.Error("Failed to reserve stock", zap.String("trace_id", traceID), zap.Error(err))
+		// The reservation may have gone through before the error, so release it to be safe
+		h.saga.Abort(ctx, reservationID)
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Product service unavailable"})
 		return
 	}
 
-	totalPrice := float64(req.Quantity) * float64(productResp.GetPrice())
+	if !reserved {
+		h.saga.Rejected(ctx, reservationID)
+		span.SetAttributes(attribute.Bool("available", false))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Product not available",
+			"stock": stock,
+		})
+		return
+	}
 
 	// Create order in database
 	var order models.Order
-	err = h.db.QueryRowContext(
+	err = scanOrder(h.db.QueryRowContext(
 		ctx,
-		"INSERT INTO orders (user_id, product_id, quantity, status, total_price) VALUES ($1, $2, $3, $4, $5) RETURNING id, user_id, product_id, quantity, status, total_price, created_at, updated_at",
+		"INSERT INTO orders (user_id, product_id, quantity, status, total_price, reservation_id, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, variant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, NULLIF($16, ''), NULLIF($17, 0)) RETURNING "+orderColumns,
 		req.UserID,
 		req.ProductID,
 		req.Quantity,
 		models.OrderStatusPending,
 		totalPrice,
-	).Scan(&order.ID, &order.UserID, &order.ProductID, &order.Quantity, &order.Status, &order.TotalPrice, &order.CreatedAt, &order.UpdatedAt)
+		reservationID,
+		orderRegion,
+		validation.ProductName,
+		validation.UnitPrice,
+		validation.TaxRate,
+		validation.Pricing.Subtotal,
+		validation.Pricing.DiscountCode,
+		validation.Pricing.Discount,
+		validation.Pricing.Tax,
+		metadataJSON(req.Metadata),
+		req.Notes,
+		req.VariantID,
+	), &order)
 
 	if err != nil {
 		traceID := middleware.GetTraceID(ctx)
 		span.RecordError(err)
 		h.logger.Error("Failed to create order", zap.String("trace_id", traceID), zap.Error(err))
+		h.saga.Abort(ctx, reservationID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	span.SetAttributes(attribute.Int("order.id", order.ID))
+	recordOrderCreated(ctx, order)
+	h.saga.Reserved(ctx, reservationID, order.ID)
 
 	// Publish order_created event to Kafka
 	event := models.OrderEvent{
-		OrderID:    order.ID,
-		UserID:     order.UserID,
-		ProductID:  order.ProductID,
-		Quantity:   order.Quantity,
-		Status:     order.Status,
-		TotalPrice: order.TotalPrice,
-		EventType:  "order_created",
+		OrderID:         order.ID,
+		UserID:          order.UserID,
+		ProductID:       order.ProductID,
+		Quantity:        order.Quantity,
+		Status:          order.Status,
+		TotalPrice:      order.TotalPrice,
+		Pricing:         order.Pricing,
+		Region:          order.Region,
+		Metadata:        order.Metadata,
+		Notes:           order.Notes,
+		PaymentMethodID: req.PaymentMethodID,
+		Currency:        validation.Currency,
+		MerchantID:      req.MerchantID,
+		EventType:       "order_created",
 	}
 
-	if err := kafka.PublishOrderEvent(ctx, h.producer, "order_events", event, h.logger); err != nil {
+	if err := kafka.PublishOrderEvent(ctx, h.producer, kafka.OrdersTopic, event, h.logger); err != nil {
 		traceID := middleware.GetTraceID(ctx)
 		h.logger.Error("Failed to publish order_created event", zap.String("trace_id", traceID), zap.Error(err))
 		// Don't fail the request, but log the error
@@ -127,7 +230,59 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	traceID := middleware.GetTraceID(ctx)
 	h.logger.Info("Order created", zap.String("trace_id", traceID), zap.Int("order_id", order.ID))
-	c.JSON(http.StatusCreated, order)
+	respond(c, http.StatusCreated, order, func() (proto.Message, error) { return orderToProto(order), nil })
+}
+
+// ValidateOrder is a dry-run checkout: it runs the same validation pipeline as CreateOrder,
+// with a non-reserving availability check, and returns the computed totals and any
+// blocking errors without reserving stock or persisting anything.
+func (h *OrderHandler) ValidateOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ValidateOrder")
+	defer span.End()
+
+	var req models.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Like CreateOrder, the dry run is for the authenticated user only
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user_id does not match the authenticated user"})
+		return
+	}
+	req.UserID = userID
+
+	// The region decides the tax rate, so it is resolved as in CreateOrder
+	orderRegion, err := h.regions.Resolve(c.GetHeader(regionHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_regions": h.regions.Allowed})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("user_id", req.UserID),
+		attribute.Int("product_id", req.ProductID),
+		attribute.Int("variant_id", req.VariantID),
+		attribute.Int("quantity", req.Quantity),
+		attribute.String("region", orderRegion),
+	)
+
+	validation, err := h.validator.Validate(ctx, req, orderRegion, true)
+	if err != nil {
+		traceID := middleware.GetTraceID(ctx)
+		span.RecordError(err)
+		h.logger.Error("Failed to validate order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": unavailableMessage(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, validation)
 }
 
 func (h *OrderHandler) GetOrder(c *gin.Context) {
@@ -141,15 +296,37 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
+	includePayment, err := parseInclude(c.Query("include"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	span.SetAttributes(attribute.Int("order.id", orderID))
 
-	var order models.Order
-	err = h.db.QueryRowContext(
-		ctx,
-		"SELECT id, user_id, product_id, quantity, status, total_price, created_at, updated_at FROM orders WHERE id = $1",
-		orderID,
-	).Scan(&order.ID, &order.UserID, &order.ProductID, &order.Quantity, &order.Status, &order.TotalPrice, &order.CreatedAt, &order.UpdatedAt)
+	// Try to get from cache first
+	cachedData, err := cache.GetOrder(ctx, h.redisClient, orderID)
+	if err == nil {
+		var order models.Order
+		if err := json.Unmarshal(cachedData, &order); err == nil {
+			span.SetAttributes(attribute.Bool("cache.hit", true))
+			middleware.RecordCacheHit()
+			h.logger.Info("Cache hit", zap.Int("order_id", orderID))
+			if includePayment {
+				if !authorizeOrderAccess(c, order.UserID) {
+					return
+				}
+				h.attachPayment(ctx, &order)
+			}
+			respond(c, http.StatusOK, order, func() (proto.Message, error) { return orderToProto(order), nil })
+			return
+		}
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	middleware.RecordCacheMiss()
 
+	var order models.Order
+	archived, err := getOrder(ctx, h.db, orderID, &order)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
@@ -162,7 +339,258 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
+	span.SetAttributes(attribute.Bool("order.archived", archived))
+
+	// Status changes invalidate the cached order from the Kafka consumer
+	cache.SetOrder(ctx, h.redisClient, order.ID, order, cache.OrderTTL)
+
+	// The payment is attached after caching, so a cached order never carries a stale one.
+	// Only the customer who placed the order and admins get it.
+	if includePayment {
+		if !authorizeOrderAccess(c, order.UserID) {
+			return
+		}
+		h.attachPayment(ctx, &order)
+	}
+
 	traceID := middleware.GetTraceID(ctx)
 	h.logger.Info("Order retrieved", zap.String("trace_id", traceID), zap.Int("order_id", order.ID))
-	c.JSON(http.StatusOK, order)
+	respond(c, http.StatusOK, order, func() (proto.Message, error) { return orderToProto(order), nil })
+}
+
+// parseInclude reads GetOrder's comma-separated include parameter and reports whether
+// the payment was asked for. Unknown values are rejected so typos don't go unnoticed.
+func parseInclude(include string) (bool, error) {
+	includePayment := false
+	for _, value := range strings.Split(include, ",") {
+		switch strings.TrimSpace(value) {
+		case "":
+		case "payment":
+			includePayment = true
+		default:
+			return false, fmt.Errorf("unknown include %q, supported: payment", value)
+		}
+	}
+	return includePayment, nil
+}
+
+// attachPayment embeds the order's latest payment from payment-service. The order is
+// still served without it when it hasn't been paid yet or payment-service is down.
+func (h *OrderHandler) attachPayment(ctx context.Context, order *models.Order) {
+	if h.paymentClient == nil {
+		return
+	}
+
+	resp, err := h.paymentClient.GetPaymentByOrder(ctx, int32(order.ID))
+	if errors.Is(err, grpc.ErrPaymentNotFound) {
+		return
+	}
+	if err != nil {
+		h.logger.Warn("Failed to get payment, serving order without it",
+			zap.String("trace_id", middleware.GetTraceID(ctx)),
+			zap.Int("order_id", order.ID),
+			zap.Error(err),
+		)
+		return
+	}
+	order.Payment = paymentDetails(resp)
+}
+
+func paymentDetails(resp *payment.GetPaymentByOrderResponse) *models.PaymentDetails {
+	createdAt, _ := time.Parse(time.RFC3339, resp.GetCreatedAt())
+	updatedAt, _ := time.Parse(time.RFC3339, resp.GetUpdatedAt())
+	return &models.PaymentDetails{
+		PaymentID:     int(resp.GetPaymentId()),
+		TransactionID: resp.GetTransactionId(),
+		Status:        resp.GetStatus(),
+		Amount:        resp.GetAmount(),
+		Currency:      resp.GetCurrency(),
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+	}
+}
+
+// GetOrderHistory lists an order's status transitions, oldest first, with the event
+// that caused each one and the trace it was handled in. Only the order's owner and
+// admins can read it.
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "GetOrderHistory")
+	defer span.End()
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("order.id", orderID))
+
+	ownerID, status, archived, err := getOrderStatus(ctx, h.db, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		traceID := middleware.GetTraceID(ctx)
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !authorizeOrderAccess(c, ownerID) {
+		return
+	}
+
+	historyTable := "order_status_history"
+	if archived {
+		historyTable = "order_status_history_archive"
+	}
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT id, order_id, from_status, to_status, source_event, COALESCE(trace_id, ''), created_at FROM "+historyTable+" WHERE order_id = $1 ORDER BY id",
+		orderID,
+	)
+	if err != nil {
+		traceID := middleware.GetTraceID(ctx)
+		span.RecordError(err)
+		h.logger.Error("Failed to get order history", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+	for rows.Next() {
+		var change models.OrderStatusChange
+		if err := rows.Scan(&change.ID, &change.OrderID, &change.FromStatus, &change.ToStatus, &change.SourceEvent, &change.TraceID, &change.CreatedAt); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan order history", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		history = append(history, change)
+	}
+
+	span.SetAttributes(attribute.Int("history.count", len(history)))
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "status": status, "data": history})
+}
+
+// recordOrderCreated marks the order.created milestone on the request span
+func recordOrderCreated(ctx context.Context, o models.Order) {
+	middleware.RecordBusinessEvent(ctx, middleware.EventOrderCreated,
+		attribute.Int("order.id", o.ID),
+		attribute.Int("user.id", o.UserID),
+		attribute.Int("product.id", o.ProductID),
+		attribute.Int("order.quantity", o.Quantity),
+		attribute.Float64("amount", o.TotalPrice),
+		attribute.String("region", o.Region),
+	)
+}
+
+// authenticatedUserID reads the user ID set by AuthMiddleware. JWT numeric claims decode as float64.
+func authenticatedUserID(c *gin.Context) (int, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := value.(float64)
+	if !ok || id <= 0 {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// authorizeOrderAccess lets the user who placed an order and admins through. Anyone else
+// gets 403, and a request without AuthMiddleware's user_id claim gets 401.
+func authorizeOrderAccess(c *gin.Context, ownerID int) bool {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return false
+	}
+	if role, _ := c.Get("role"); userID != ownerID && role != middleware.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+	return true
+}
+
+// metadataJSON is the value stored in the metadata column; orders without metadata get {}
+func metadataJSON(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return "{}"
+	}
+	// A string map always marshals
+	data, _ := json.Marshal(metadata)
+	return string(data)
+}
+
+// getOrder reads an order, falling back to orders_archive for one the archiver has
+// moved. archived reports which table it came from; sql.ErrNoRows means neither has it.
+func getOrder(ctx context.Context, db *sql.DB, orderID int, o *models.Order) (archived bool, err error) {
+	err = scanOrder(db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders WHERE id = $1", orderID), o)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	err = scanOrder(db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders_archive WHERE id = $1", orderID), o)
+	return err == nil, err
+}
+
+// getOrderStatus reads an order's owner and status like getOrder reads the order
+func getOrderStatus(ctx context.Context, db *sql.DB, orderID int) (ownerID int, status models.OrderStatus, archived bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT user_id, status FROM orders WHERE id = $1", orderID).Scan(&ownerID, &status)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ownerID, status, false, err
+	}
+	err = db.QueryRowContext(ctx, "SELECT user_id, status FROM orders_archive WHERE id = $1", orderID).Scan(&ownerID, &status)
+	return ownerID, status, err == nil, err
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder scans a row selected with orderColumns
+func scanOrder(row rowScanner, o *models.Order) error {
+	var variantID sql.NullInt64
+	var productName, discountCode, notes sql.NullString
+	var unitPrice, taxRate, subtotal, discount, tax sql.NullFloat64
+	var metadata []byte
+	if err := row.Scan(&o.ID, &o.UserID, &o.ProductID, &variantID, &o.Quantity, &o.Status, &o.TotalPrice, &o.Region,
+		&productName, &unitPrice, &taxRate, &subtotal, &discountCode, &discount, &tax, &metadata, &notes,
+		&o.CreatedAt, &o.UpdatedAt); err != nil {
+		return err
+	}
+	o.VariantID = nil
+	if variantID.Valid {
+		id := int(variantID.Int64)
+		o.VariantID = &id
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &o.Metadata); err != nil {
+			return fmt.Errorf("failed to decode order metadata: %w", err)
+		}
+		if len(o.Metadata) == 0 {
+			o.Metadata = nil
+		}
+	}
+	o.Notes = notes.String
+	if productName.Valid {
+		o.Snapshot = &models.ProductSnapshot{
+			ProductName: productName.String,
+			UnitPrice:   unitPrice.Float64,
+			TaxRate:     taxRate.Float64,
+		}
+	}
+	if subtotal.Valid {
+		o.Pricing = &models.PriceBreakdown{
+			Subtotal:     subtotal.Float64,
+			DiscountCode: discountCode.String,
+			Discount:     discount.Float64,
+			TaxRate:      taxRate.Float64,
+			Tax:          tax.Float64,
+			Total:        o.TotalPrice,
+		}
+	}
+	return nil
 }
@@ -3,19 +3,34 @@ package handlers
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"order-svc/grpc"
+	"order-svc/middleware"
 	"order-svc/models"
+	order "order-svc/proto"
+	"order-svc/proto/payment"
+	"order-svc/region"
+	"order-svc/saga"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	grpcLib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // Mock ProductClient for testing.
@@ -67,6 +82,11 @@ func setupOrderTest(t *testing.T) (*OrderHandler, sqlmock.Sqlmock, *gin.Engine)
 		t.Fatalf("Failed to create mock database: %v", err)
 	}
 
+	// Use a real Redis client; cache misses fall through to the database mock
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+
 	logger := zaptest.NewLogger(t, zaptest.Level(zap.InfoLevel))
 	// For GetOrder tests, we can use nil for producer and productClient
 	// since GetOrder doesn't use them
@@ -74,18 +94,51 @@ func setupOrderTest(t *testing.T) (*OrderHandler, sqlmock.Sqlmock, *gin.Engine)
 	var productClient *grpc.ProductClient = nil
 	handler := &OrderHandler{
 		db:            db,
+		redisClient:   redisClient,
 		producer:      producer,
 		productClient: productClient,
+		regions:       region.Config{Home: "us-east-1", Allowed: []string{"us-east-1", "eu-west-1"}},
 		logger:        logger,
 	}
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.GET("/orders/:id", handler.GetOrder)
+	router.GET("/orders/:id", fakeAuth, handler.GetOrder)
+	router.GET("/orders/:id/history", fakeAuth, handler.GetOrderHistory)
+	router.GET("/orders/:id/events", fakeAuth, handler.StreamOrderEvents)
+	router.POST("/orders/:id/refund", fakeAuth, handler.RefundOrder)
+	router.PATCH("/admin/orders/:id/status", handler.UpdateOrderStatus)
+	router.POST("/admin/orders/:id/confirm", handler.ConfirmOrder)
+	router.GET("/admin/orders", handler.SearchOrders)
+	router.GET("/admin/orders/export", handler.ExportOrders)
 
 	return handler, mock, router
 }
 
+// fakeAuth stands in for AuthMiddleware: the X-User-ID and X-Role headers become the
+// token's user_id and role claims
+func fakeAuth(c *gin.Context) {
+	if id, err := strconv.Atoi(c.GetHeader("X-User-ID")); err == nil {
+		c.Set("user_id", float64(id))
+		c.Set("role", c.GetHeader("X-Role"))
+	}
+}
+
+// userRequest is a request from the given user, as seen after fakeAuth
+func userRequest(method, target string, userID int, role string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("X-User-ID", strconv.Itoa(userID))
+	req.Header.Set("X-Role", role)
+	return req
+}
+
+// expectOrderOwner expects the lookup of an order's owner before it is acted on
+func expectOrderOwner(mock sqlmock.Sqlmock, orderID, userID int) {
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE id = \\$1").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+}
+
 // Note: CreateOrder tests are skipped because the handler uses concrete types
 // (ProductClient) that are difficult to mock without refactoring to use interfaces.
 // The GetOrder tests below work because they only require database mocking.
@@ -95,10 +148,10 @@ func TestOrderHandler_GetOrder_Success(t *testing.T) {
 	defer handler.db.Close()
 
 	// Mock: Get order by ID
-	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "quantity", "status", "total_price", "created_at", "updated_at"}).
-		AddRow(1, 1, 1, 2, models.OrderStatusPending, 21.98, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+		AddRow(1, 1, 1, nil, 2, models.OrderStatusPending, 23.74, "us-east-1", "Mug", 10.99, 0.08, 21.98, nil, 0, 1.76, []byte(`{"cart_id": "cart-81"}`), "Leave at the door", time.Now(), time.Now())
 
-	mock.ExpectQuery("SELECT id, user_id, product_id, quantity, status, total_price, created_at, updated_at FROM orders WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE id = \\$1").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -111,17 +164,214 @@ func TestOrderHandler_GetOrder_Success(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
+	// The product data captured at purchase time is returned with the order
+	var got models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	want := models.ProductSnapshot{ProductName: "Mug", UnitPrice: 10.99, TaxRate: 0.08}
+	if got.Snapshot == nil || *got.Snapshot != want {
+		t.Errorf("Expected product snapshot %+v, got %+v", want, got.Snapshot)
+	}
+	wantPricing := models.PriceBreakdown{Subtotal: 21.98, TaxRate: 0.08, Tax: 1.76, Total: 23.74}
+	if got.Pricing == nil || *got.Pricing != wantPricing {
+		t.Errorf("Expected pricing %+v, got %+v", wantPricing, got.Pricing)
+	}
+	if got.Metadata["cart_id"] != "cart-81" || got.Notes != "Leave at the door" {
+		t.Errorf("Expected the order's metadata and notes, got %v and %q", got.Metadata, got.Notes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrder_Protobuf(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+		AddRow(1, 3, 5, nil, 2, models.OrderStatusPaid, 21.98, "eu-west-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now())
+
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("Expected protobuf content type, got %q", ct)
+	}
+
+	var resp order.GetOrderResponse
+	if err := proto.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode protobuf response: %v", err)
+	}
+	if resp.GetId() != 1 || resp.GetUserId() != 3 || resp.GetStatus() != string(models.OrderStatusPaid) || resp.GetRegion() != "eu-west-1" {
+		t.Errorf("Unexpected order: %+v", &resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// fakePaymentServer has a payment for order 1 only
+type fakePaymentServer struct {
+	payment.UnimplementedPaymentServiceServer
+}
+
+func (fakePaymentServer) GetPaymentByOrder(_ context.Context, req *payment.GetPaymentByOrderRequest) (*payment.GetPaymentByOrderResponse, error) {
+	if req.GetOrderId() != 1 {
+		return nil, status.Error(codes.NotFound, "payment not found")
+	}
+	return &payment.GetPaymentByOrderResponse{
+		PaymentId:     7,
+		OrderId:       1,
+		Amount:        21.98,
+		Currency:      "USD",
+		Status:        "success",
+		TransactionId: "TXN-123",
+		CreatedAt:     "2026-01-02T03:04:05Z",
+		UpdatedAt:     "2026-01-02T03:04:06Z",
+	}, nil
+}
+
+func TestOrderHandler_GetOrder_IncludePayment(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	t.Setenv("PAYMENT_SERVICE_GRPC", serveGRPC(t, func(s *grpcLib.Server) {
+		payment.RegisterPaymentServiceServer(s, fakePaymentServer{})
+	}))
+	paymentClient, err := grpc.InitPaymentClient(handler.logger)
+	if err != nil {
+		t.Fatalf("Failed to create payment client: %v", err)
+	}
+	defer paymentClient.Close()
+	handler.paymentClient = paymentClient
+
+	for _, id := range []int{1, 2} {
+		rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(id, 3, 5, nil, 2, models.OrderStatusPaid, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now())
+		mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE id = \\$1").
+			WithArgs(id).
+			WillReturnRows(rows)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, userRequest(http.MethodGet, "/orders/1?include=payment", 3, "customer"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	want := models.PaymentDetails{
+		PaymentID:     7,
+		TransactionID: "TXN-123",
+		Status:        "success",
+		Amount:        21.98,
+		Currency:      "USD",
+		CreatedAt:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt:     time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC),
+	}
+	if got.Payment == nil || *got.Payment != want {
+		t.Errorf("Expected payment %+v, got %+v", want, got.Payment)
+	}
+
+	// An order without a payment is still served, just without one
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, userRequest(http.MethodGet, "/orders/2?include=payment", 3, "customer"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `"payment"`) {
+		t.Errorf("Expected no payment, got %s", w.Body.String())
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Database expectations were not met: %v", err)
 	}
 }
 
+func TestOrderHandler_GetOrder_IncludePaymentOwnerOrAdmin(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	t.Setenv("PAYMENT_SERVICE_GRPC", serveGRPC(t, func(s *grpcLib.Server) {
+		payment.RegisterPaymentServiceServer(s, fakePaymentServer{})
+	}))
+	paymentClient, err := grpc.InitPaymentClient(handler.logger)
+	if err != nil {
+		t.Fatalf("Failed to create payment client: %v", err)
+	}
+	defer paymentClient.Close()
+	handler.paymentClient = paymentClient
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"no token", httptest.NewRequest(http.MethodGet, "/orders/1?include=payment", nil), http.StatusUnauthorized},
+		{"other customer", userRequest(http.MethodGet, "/orders/1?include=payment", 4, "customer"), http.StatusForbidden},
+		{"admin", userRequest(http.MethodGet, "/orders/1?include=payment", 4, "admin"), http.StatusOK},
+		// Without the payment, the order stays public
+		{"no include", httptest.NewRequest(http.MethodGet, "/orders/1", nil), http.StatusOK},
+	}
+	for range tests {
+		mock.ExpectQuery("SELECT id, user_id, .* FROM orders WHERE id = \\$1").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+				AddRow(1, 3, 5, nil, 2, models.OrderStatusPaid, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now()))
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+			continue
+		}
+		if leaked := strings.Contains(w.Body.String(), "TXN-123"); leaked != (tt.name == "admin") {
+			t.Errorf("%s: unexpected payment in response: %s", tt.name, w.Body.String())
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrder_UnknownInclude(t *testing.T) {
+	handler, _, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1?include=payment,refunds", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestOrderHandler_GetOrder_NotFound(t *testing.T) {
 	handler, mock, router := setupOrderTest(t)
 	defer handler.db.Close()
 
-	// Mock: Order not found
-	mock.ExpectQuery("SELECT id, user_id, product_id, quantity, status, total_price, created_at, updated_at FROM orders WHERE id = \\$1").
+	// Mock: Order not found, nor archived
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders_archive WHERE id = \\$1").
 		WithArgs(999).
 		WillReturnError(sql.ErrNoRows)
 
@@ -138,3 +388,596 @@ func TestOrderHandler_GetOrder_NotFound(t *testing.T) {
 		t.Errorf("Database expectations were not met: %v", err)
 	}
 }
+
+func TestOrderHandler_GetOrder_Archived(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// Moved out of orders by the archiver, so it is read from the archive
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders_archive WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(3, 1, 1, nil, 1, models.OrderStatusPaid, 10.99, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now().AddDate(-1, 0, 0), time.Now().AddDate(-1, 0, 0)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/3", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.ID != 3 || got.Status != models.OrderStatusPaid {
+		t.Errorf("Expected archived order 3, got %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_ExportOrders_ScopedToRegion(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+		AddRow(7, 1, 1, nil, 1, models.OrderStatusPaid, 10.99, "eu-west-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now())
+
+	mock.ExpectQuery("SELECT id, user_id, product_id, variant_id, quantity, status, total_price, region, product_name, unit_price, tax_rate, subtotal, discount_code, discount_amount, tax_amount, metadata, notes, created_at, updated_at FROM orders WHERE region = \\$1 AND status = \\$2 ORDER BY created_at DESC LIMIT \\$3").
+		WithArgs("eu-west-1", "paid", 50).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orders/export?region=eu-west-1&status=paid&limit=50", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Region string         `json:"region"`
+		Count  int            `json:"count"`
+		Data   []models.Order `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Region != "eu-west-1" || resp.Count != 1 || resp.Data[0].Region != "eu-west-1" {
+		t.Errorf("Unexpected export: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_ExportOrders_RegionRequiredAndEnforced(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusBadRequest},
+		{"?region=ap-south-1", http.StatusForbidden},
+		{"?region=us-east-1&limit=0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/orders/export"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET /admin/orders/export%s: expected status %d, got %d", tt.query, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
+
+func TestOrderHandler_SearchOrders_Filters(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	where := "WHERE status = \\$1 AND user_id = \\$2 AND product_id = \\$3 AND created_at >= \\$4 AND created_at < \\$5 AND total_price >= \\$6 AND total_price <= \\$7"
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM orders "+where).
+		WithArgs("paid", 5, 3, from, to, 10.0, 50.0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	rows := sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+		AddRow(9, 5, 3, nil, 1, models.OrderStatusPaid, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now())
+	mock.ExpectQuery("SELECT id, user_id, .* FROM orders "+where+" ORDER BY created_at DESC, id DESC LIMIT \\$8 OFFSET \\$9").
+		WithArgs("paid", 5, 3, from, to, 10.0, 50.0, 2, 2).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orders?status=paid&user_id=5&product_id=3&from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z&min_total=10&max_total=50&page=2&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp models.OrderListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Total != 3 || resp.TotalPages != 2 || resp.Page != 2 || len(resp.Data) != 1 || resp.Data[0].ID != 9 {
+		t.Errorf("Unexpected page: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_SearchOrders_InvalidFilters(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?status=shipped", http.StatusBadRequest},
+		{"?region=ap-south-1", http.StatusForbidden},
+		{"?from=2024-06-01T00:00:00Z&to=2024-05-01T00:00:00Z", http.StatusBadRequest},
+		{"?from=yesterday", http.StatusBadRequest},
+		{"?min_total=50&max_total=10", http.StatusBadRequest},
+		{"?limit=500", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/orders"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET /admin/orders%s: expected status %d, got %d", tt.query, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
+
+func TestOrderHandler_SearchOrders_RequiresAdmin(t *testing.T) {
+	handler, mock, _ := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// Guarded like serve's /admin group
+	router := gin.New()
+	admin := router.Group("/admin", fakeAuth, middleware.RequireRole(middleware.RoleAdmin))
+	admin.GET("/orders", handler.SearchOrders)
+
+	// The admin's request reaches the handler, which rejects the filter before querying
+	for _, tt := range []struct {
+		role string
+		want int
+	}{
+		{"customer", http.StatusForbidden},
+		{"", http.StatusForbidden},
+		{middleware.RoleAdmin, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, userRequest(http.MethodGet, "/admin/orders?status=shipped", 5, tt.role))
+		if w.Code != tt.want {
+			t.Errorf("role %q: expected status %d, got %d", tt.role, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrderHistory(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT user_id, status FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(3, models.OrderStatusPaid))
+	mock.ExpectQuery("SELECT id, order_id, from_status, to_status, source_event, COALESCE\\(trace_id, ''\\), created_at FROM order_status_history WHERE order_id = \\$1 ORDER BY id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "from_status", "to_status", "source_event", "trace_id", "created_at"}).
+			AddRow(7, 1, models.OrderStatusPending, models.OrderStatusPaid, "payment_success", "4bf92f3577b34da6a3ce929d0e0e4736", time.Now()))
+
+	req := userRequest(http.MethodGet, "/orders/1/history", 3, "customer")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var got struct {
+		Status models.OrderStatus         `json:"status"`
+		Data   []models.OrderStatusChange `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.Status != models.OrderStatusPaid || len(got.Data) != 1 {
+		t.Fatalf("Unexpected history response: %s", w.Body.String())
+	}
+	if change := got.Data[0]; change.FromStatus != models.OrderStatusPending || change.ToStatus != models.OrderStatusPaid || change.SourceEvent != "payment_success" || change.TraceID == "" {
+		t.Errorf("Unexpected status change: %+v", change)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrderHistory_Archived(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// An archived order's history was archived with it
+	mock.ExpectQuery("SELECT user_id, status FROM orders WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT user_id, status FROM orders_archive WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(3, models.OrderStatusPaid))
+	mock.ExpectQuery("SELECT .* FROM order_status_history_archive WHERE order_id = \\$1 ORDER BY id").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "from_status", "to_status", "source_event", "trace_id", "created_at"}).
+			AddRow(4, 3, models.OrderStatusPending, models.OrderStatusPaid, "payment_success", "", time.Now()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, userRequest(http.MethodGet, "/orders/3/history", 9, middleware.RoleAdmin))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrderHistory_NotFound(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT user_id, status FROM orders WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT user_id, status FROM orders_archive WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+
+	req := userRequest(http.MethodGet, "/orders/999/history", 3, "customer")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_GetOrderHistory_OwnerOrAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"no token", httptest.NewRequest(http.MethodGet, "/orders/1/history", nil), http.StatusUnauthorized},
+		{"another customer", userRequest(http.MethodGet, "/orders/1/history", 4, "customer"), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, router := setupOrderTest(t)
+			defer handler.db.Close()
+
+			// The history itself is never read
+			mock.ExpectQuery("SELECT user_id, status FROM orders WHERE id = \\$1").
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(3, models.OrderStatusPaid))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestOrderHandler_RefundOrder(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	handler.producer = producer
+	handler.saga = saga.New(handler.db, handler.redisClient, nil, nil, handler.logger)
+
+	expectOrderOwner(mock, 1, 5)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPaid, "res-1"))
+	mock.ExpectExec("UPDATE orders SET status = \\$1").
+		WithArgs(models.OrderStatusRefundPending, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO order_status_history").
+		WithArgs(1, models.OrderStatusPaid, models.OrderStatusRefundPending, "refund_requested", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(12, time.Now()))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, user_id, .* FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(1, 5, 3, nil, 2, models.OrderStatusRefundPending, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now()))
+
+	var event models.OrderEvent
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		// Keyed by order, so payment-service sees it after the order's earlier events
+		if msg.Key == nil {
+			return fmt.Errorf("expected the message to be keyed by order ID")
+		}
+		if key, _ := msg.Key.Encode(); string(key) != "1" {
+			return fmt.Errorf("expected message key 1, got %q", key)
+		}
+		payload, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, &event)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, userRequest(http.MethodPost, "/orders/1/refund", 5, "customer"))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var got models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.Status != models.OrderStatusRefundPending {
+		t.Errorf("Expected status refund_pending, got %q", got.Status)
+	}
+	if event.EventType != "refund_requested" || event.EventID != "refund-12" || event.OrderID != 1 || event.TotalPrice != 21.98 {
+		t.Errorf("Unexpected refund_requested event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_RefundOrder_NotPaid(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	handler.saga = saga.New(handler.db, handler.redisClient, nil, nil, handler.logger)
+
+	// Pending orders haven't been charged, so nothing is published
+	expectOrderOwner(mock, 1, 5)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, "res-1"))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, userRequest(http.MethodPost, "/orders/1/refund", 5, "customer"))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_RefundOrder_Unauthorized(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	handler.saga = saga.New(handler.db, handler.redisClient, nil, nil, handler.logger)
+
+	// Another customer's request is refused before the order is touched; an admin's
+	// goes through to the refund
+	for i := 0; i < 4; i++ {
+		expectOrderOwner(mock, 1, 5)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, "res-1"))
+	mock.ExpectRollback()
+
+	for _, tt := range []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"no token", httptest.NewRequest(http.MethodPost, "/orders/1/refund", nil), http.StatusUnauthorized},
+		{"other customer", userRequest(http.MethodPost, "/orders/1/refund", 6, "customer"), http.StatusForbidden},
+		{"support role", userRequest(http.MethodPost, "/orders/1/refund", 6, "support"), http.StatusForbidden},
+		{"admin", userRequest(http.MethodPost, "/orders/1/refund", 6, "admin"), http.StatusConflict},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_ConfirmOrder(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	handler.producer = producer
+
+	mock.ExpectQuery("SELECT id, user_id, .* FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(1, 5, 3, nil, 2, models.OrderStatusPending, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte(`{"po": "PO-1"}`), nil, time.Now(), time.Now()))
+
+	var event models.OrderEvent
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		payload, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, &event)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/orders/1/confirm", nil))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if event.EventType != "order_confirmed" || event.EventID != "confirm-1" || event.OrderID != 1 || event.Metadata["po"] != "PO-1" {
+		t.Errorf("Unexpected order_confirmed event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_ConfirmOrder_NotPending(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// Paid orders were captured already, so nothing is published
+	mock.ExpectQuery("SELECT id, user_id, .* FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(1, 5, 3, nil, 2, models.OrderStatusPaid, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/orders/1/confirm", nil))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_UpdateOrderStatus(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	handler.producer = producer
+	handler.saga = saga.New(handler.db, handler.redisClient, nil, nil, handler.logger)
+
+	// A stuck pending order without a reservation, so nothing is released
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusPending))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, nil))
+	mock.ExpectExec("UPDATE orders SET status = \\$1").
+		WithArgs(models.OrderStatusCancelled, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO order_status_history").
+		WithArgs(1, models.OrderStatusPending, models.OrderStatusCancelled, "admin_override", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, user_id, .* FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "product_id", "variant_id", "quantity", "status", "total_price", "region", "product_name", "unit_price", "tax_rate", "subtotal", "discount_code", "discount_amount", "tax_amount", "metadata", "notes", "created_at", "updated_at"}).
+			AddRow(1, 5, 3, nil, 2, models.OrderStatusCancelled, 21.98, "us-east-1", nil, nil, nil, nil, nil, nil, nil, []byte("{}"), nil, time.Now(), time.Now()))
+
+	var event models.OrderEvent
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		payload, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, &event)
+	})
+
+	body := `{"status": "cancelled", "reason": "customer asked by phone"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/admin/orders/1/status", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if event.EventType != "order_status_overridden" || event.PreviousStatus != models.OrderStatusPending ||
+		event.Status != models.OrderStatusCancelled || event.Reason != "customer asked by phone" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOrderHandler_UpdateOrderStatus_Rejected(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// Failed orders are final
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusFailed))
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"status": "paid"}`, http.StatusBadRequest},
+		{`{"status": "shipped", "reason": "x"}`, http.StatusBadRequest},
+		{`{"status": "refund_pending", "reason": "x"}`, http.StatusBadRequest},
+		{`{"status": "paid", "reason": "payment went through"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/admin/orders/1/status", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("PATCH %s: expected status %d, got %d", tt.body, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
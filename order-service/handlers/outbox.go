@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxOutboxMessages bounds the outbox rows returned by one listing
+const maxOutboxMessages = 100
+
+// outboxColumns is the column list scanned by scanOutboxMessage
+const outboxColumns = "id, topic, message_key, payload, headers, status, attempts, COALESCE(last_error, ''), next_attempt_at, created_at, published_at"
+
+// OutboxHandler lets admins inspect the outbox and requeue rows the relay gave up on
+type OutboxHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewOutboxHandler(db *sql.DB, logger *zap.Logger) *OutboxHandler {
+	return &OutboxHandler{db: db, logger: logger}
+}
+
+// ListOutbox returns the oldest unpublished rows, or only those with ?status=
+func (h *OutboxHandler) ListOutbox(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ListOutbox")
+	defer span.End()
+
+	query := "SELECT " + outboxColumns + " FROM outbox"
+	args := []interface{}{}
+	switch status := models.OutboxStatus(c.Query("status")); status {
+	case "":
+		args = append(args, models.OutboxPending, models.OutboxDead)
+		query += " WHERE status IN ($1, $2)"
+	case models.OutboxPending, models.OutboxDead, models.OutboxPublished:
+		args = append(args, status)
+		query += " WHERE status = $1"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	args = append(args, maxOutboxMessages)
+	query += " ORDER BY id LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list outbox", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	messages := []models.OutboxMessage{}
+	for rows.Next() {
+		var msg models.OutboxMessage
+		if err := scanOutboxMessage(rows, &msg); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan outbox message", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	span.SetAttributes(attribute.Int("outbox.count", len(messages)))
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
+// RequeueOutboxMessage gives a dead row a fresh set of attempts, starting now. Rows that
+// aren't dead return 409 with their status.
+func (h *OutboxHandler) RequeueOutboxMessage(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "RequeueOutboxMessage")
+	defer span.End()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outbox ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int64("outbox.id", id))
+
+	var msg models.OutboxMessage
+	err = scanOutboxMessage(h.db.QueryRowContext(ctx,
+		"UPDATE outbox SET status = $1, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3 RETURNING "+outboxColumns,
+		models.OutboxPending, id, models.OutboxDead,
+	), &msg)
+	if errors.Is(err, sql.ErrNoRows) {
+		var status models.OutboxStatus
+		err = h.db.QueryRowContext(ctx, "SELECT status FROM outbox WHERE id = $1", id).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Outbox message not found"})
+			return
+		}
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only dead outbox messages can be requeued", "status": status})
+			return
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to requeue outbox message", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Outbox message requeued", zap.Int64("outbox_id", id))
+	c.JSON(http.StatusOK, msg)
+}
+
+// RequeueDeadOutbox requeues every dead row and returns how many there were
+func (h *OutboxHandler) RequeueDeadOutbox(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "RequeueDeadOutbox")
+	defer span.End()
+
+	result, err := h.db.ExecContext(ctx,
+		"UPDATE outbox SET status = $1, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE status = $2",
+		models.OutboxPending, models.OutboxDead,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to requeue outbox", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	requeued, _ := result.RowsAffected()
+
+	span.SetAttributes(attribute.Int64("outbox.requeued", requeued))
+	h.logger.Info("Dead outbox messages requeued", zap.Int64("count", requeued))
+	c.JSON(http.StatusOK, gin.H{"requeued": requeued})
+}
+
+func scanOutboxMessage(row rowScanner, msg *models.OutboxMessage) error {
+	var key sql.NullString
+	var headers []byte
+	var nextAttemptAt, publishedAt sql.NullTime
+	err := row.Scan(&msg.ID, &msg.Topic, &key, &msg.Payload, &headers, &msg.Status, &msg.Attempts, &msg.LastError, &nextAttemptAt, &msg.CreatedAt, &publishedAt)
+	if err != nil {
+		return err
+	}
+	if key.Valid {
+		msg.Key = &key.String
+	}
+	if nextAttemptAt.Valid {
+		msg.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if publishedAt.Valid {
+		msg.PublishedAt = &publishedAt.Time
+	}
+	return json.Unmarshal(headers, &msg.Headers)
+}
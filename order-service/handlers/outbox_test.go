@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+func setupOutboxTest(t *testing.T) (*OutboxHandler, sqlmock.Sqlmock, *gin.Engine) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+
+	handler := NewOutboxHandler(db, zaptest.NewLogger(t))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/outbox", handler.ListOutbox)
+	router.POST("/admin/outbox/:id/requeue", handler.RequeueOutboxMessage)
+
+	return handler, mock, router
+}
+
+func TestOutboxHandler_ListOutbox(t *testing.T) {
+	handler, mock, router := setupOutboxTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM outbox WHERE status = \\$1 ORDER BY id LIMIT \\$2").
+		WithArgs(models.OutboxDead, maxOutboxMessages).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "message_key", "payload", "headers", "status", "attempts", "last_error", "next_attempt_at", "created_at", "published_at"}).
+			AddRow(5, "orders", nil, `{"order_id":1}`, `{"traceparent":"00-abc"}`, "dead", 10, "broker unavailable", nil, time.Now(), nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/outbox?status=dead", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"last_error":"broker unavailable"`) {
+		t.Errorf("Expected the row's last error, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/outbox?status=lost", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestOutboxHandler_RequeueOutboxMessage(t *testing.T) {
+	handler, mock, router := setupOutboxTest(t)
+	defer handler.db.Close()
+
+	// A row the relay is still retrying isn't requeued
+	mock.ExpectQuery("UPDATE outbox SET status = \\$1, attempts = 0").
+		WithArgs(models.OutboxPending, int64(6), models.OutboxDead).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT status FROM outbox WHERE id = \\$1").
+		WithArgs(int64(6)).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/outbox/6/requeue", nil))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"status":"pending"`) {
+		t.Errorf("Expected the current status, got %s", w.Body.String())
+	}
+
+	// Unknown rows are not found
+	mock.ExpectQuery("UPDATE outbox SET status = \\$1, attempts = 0").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT status FROM outbox WHERE id = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/outbox/99/requeue", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
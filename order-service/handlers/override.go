@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// sourceAdminOverride is the status history source of support overrides
+const sourceAdminOverride = "admin_override"
+
+// UpdateOrderStatus lets support staff move an order stuck mid-saga. Only moves the
+// state machine allows are accepted, and each runs the same saga step as the event it
+// stands in for, so stock is confirmed or released as usual. An
+// order_status_overridden event is published afterwards.
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "UpdateOrderStatus")
+	defer span.End()
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req models.UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if req.Status == models.OrderStatusRefundPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use POST /api/v1/orders/:id/refund to request a refund"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("order.id", orderID), attribute.String("order.to_status", string(req.Status)))
+	traceID := middleware.GetTraceID(ctx)
+
+	var current models.OrderStatus
+	err = h.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !current.CanTransitionTo(req.Status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Transition not allowed",
+			"status":  current,
+			"allowed": current.NextStatuses(),
+		})
+		return
+	}
+
+	if err := h.overrideStep(current, req.Status)(ctx, orderID, sourceAdminOverride, traceID); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to override order status",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("to_status", string(req.Status)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var order models.Order
+	if err := scanOrder(h.db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders WHERE id = $1", orderID), &order); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if order.Status != req.Status {
+		// An event settled the order between the check and the override
+		c.JSON(http.StatusConflict, gin.H{"error": "Order status changed concurrently", "status": order.Status})
+		return
+	}
+
+	event := models.OrderEvent{
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		ProductID:      order.ProductID,
+		Quantity:       order.Quantity,
+		Status:         order.Status,
+		TotalPrice:     order.TotalPrice,
+		Region:         order.Region,
+		Metadata:       order.Metadata,
+		Notes:          order.Notes,
+		PreviousStatus: current,
+		Reason:         req.Reason,
+		EventType:      "order_status_overridden",
+	}
+	if err := kafka.PublishOrderEvent(ctx, h.producer, kafka.OrdersTopic, event, h.logger); err != nil {
+		// The override is already recorded in the status history
+		h.logger.Error("Failed to publish order_status_overridden event", zap.String("trace_id", traceID), zap.Error(err))
+	}
+
+	h.logger.Warn("Order status overridden",
+		zap.String("trace_id", traceID),
+		zap.Int("order_id", orderID),
+		zap.String("from_status", string(current)),
+		zap.String("to_status", string(order.Status)),
+		zap.String("reason", req.Reason),
+	)
+	c.JSON(http.StatusOK, order)
+}
+
+// overrideStep is the saga step that moves an order from current to next, with the
+// same side effects as the event it stands in for
+func (h *OrderHandler) overrideStep(current, next models.OrderStatus) func(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	switch next {
+	case models.OrderStatusPaid:
+		if current == models.OrderStatusRefundPending {
+			return h.saga.RefundFailed
+		}
+		return h.saga.Pay
+	case models.OrderStatusFailed:
+		return h.saga.Fail
+	case models.OrderStatusCancelled:
+		return h.saga.Cancel
+	default: // refunded
+		return h.saga.Refund
+	}
+}
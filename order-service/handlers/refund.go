@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/saga"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// RefundOrder moves a paid order to refund_pending and asks payment-service for the
+// refund with a refund_requested event. Its refund_completed or refund_failed reply
+// moves the order on to refunded, or back to paid. Only the order's owner or an admin
+// can ask for it.
+func (h *OrderHandler) RefundOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "RefundOrder")
+	defer span.End()
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("order.id", orderID))
+	traceID := middleware.GetTraceID(ctx)
+
+	// Only the customer who paid, or an admin, can send the money back
+	var ownerID int
+	err = h.db.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE id = $1", orderID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !authorizeOrderAccess(c, ownerID) {
+		return
+	}
+
+	outcome, err := h.saga.RequestRefund(ctx, orderID, traceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if errors.Is(err, saga.ErrNotRefundable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": outcome.Status})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to request refund", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var order models.Order
+	err = scanOrder(h.db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders WHERE id = $1", orderID), &order)
+	if err == nil {
+		event := models.OrderEvent{
+			EventID:    fmt.Sprintf("refund-%d", outcome.Change.ID),
+			OrderID:    order.ID,
+			UserID:     order.UserID,
+			ProductID:  order.ProductID,
+			Quantity:   order.Quantity,
+			Status:     order.Status,
+			TotalPrice: order.TotalPrice,
+			Region:     order.Region,
+			Metadata:   order.Metadata,
+			Notes:      order.Notes,
+			EventType:  "refund_requested",
+		}
+		err = kafka.PublishOrderEvent(ctx, h.producer, kafka.OrdersTopic, event, h.logger)
+	}
+	if err != nil {
+		// Nothing will answer the refund, so put the order back to paid and let the
+		// caller try again
+		span.RecordError(err)
+		h.logger.Error("Failed to publish refund_requested event", zap.String("trace_id", traceID), zap.Int("order_id", orderID), zap.Error(err))
+		if revertErr := h.saga.RefundFailed(ctx, orderID, "refund_request_failed", traceID); revertErr != nil {
+			h.logger.Error("Failed to revert refund request", zap.String("trace_id", traceID), zap.Int("order_id", orderID), zap.Error(revertErr))
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Refund could not be requested, try again later"})
+		return
+	}
+
+	span.SetAttributes(attribute.String("order.status", string(order.Status)))
+	h.logger.Info("Refund requested for order", zap.String("trace_id", traceID), zap.Int("order_id", orderID))
+	respond(c, http.StatusAccepted, order, func() (proto.Message, error) { return orderToProto(order), nil })
+}
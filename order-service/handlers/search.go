@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/region"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// SearchOrders returns a page of orders matching the admin's filters, newest first.
+// Each filter has an index to back it, so searches stay cheap as the table grows.
+func (h *OrderHandler) SearchOrders(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "SearchOrders")
+	defer span.End()
+
+	var query models.SearchOrdersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListPageSize
+	}
+	if query.Status != "" && !query.Status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if query.Region != "" && !h.regions.Allows(query.Region) {
+		c.JSON(http.StatusForbidden, gin.H{"error": region.ErrNotAllowed.Error(), "allowed_regions": h.regions.Allowed})
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if query.MinTotal != nil && query.MaxTotal != nil && *query.MinTotal > *query.MaxTotal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_total cannot be greater than max_total"})
+		return
+	}
+
+	// Build filter clause dynamically
+	conditions := []string{}
+	args := []interface{}{}
+	addCondition := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, clause+" $"+strconv.Itoa(len(args)))
+	}
+
+	if query.Status != "" {
+		addCondition("status =", string(query.Status))
+	}
+	if query.UserID != 0 {
+		addCondition("user_id =", query.UserID)
+	}
+	if query.ProductID != 0 {
+		addCondition("product_id =", query.ProductID)
+	}
+	if query.Region != "" {
+		addCondition("region =", query.Region)
+	}
+	if !query.From.IsZero() {
+		addCondition("created_at >=", query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		addCondition("created_at <", query.To.UTC())
+	}
+	if query.MinTotal != nil {
+		addCondition("total_price >=", *query.MinTotal)
+	}
+	if query.MaxTotal != nil {
+		addCondition("total_price <=", *query.MaxTotal)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	span.SetAttributes(
+		attribute.Int("page", query.Page),
+		attribute.Int("limit", query.Limit),
+		attribute.Int("filters", len(conditions)),
+	)
+
+	var total int
+	if err := h.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders"+where, args...).Scan(&total); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to count orders", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	listQuery := "SELECT " + orderColumns + " FROM orders" + where +
+		" ORDER BY created_at DESC, id DESC" +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	listArgs := append(args, query.Limit, (query.Page-1)*query.Limit)
+
+	rows, err := h.db.QueryContext(ctx, listQuery, listArgs...)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to search orders", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan order", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to search orders", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("orders.count", len(orders)),
+		attribute.Int("orders.total", total),
+	)
+	c.JSON(http.StatusOK, models.OrderListResponse{
+		Data:       orders,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Total:      total,
+		TotalPages: (total + query.Limit - 1) / query.Limit,
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"order-svc/selftest"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SelfTestHandler struct {
+	runner  *selftest.Runner
+	running sync.Mutex
+	logger  *zap.Logger
+}
+
+func NewSelfTestHandler(runner *selftest.Runner, logger *zap.Logger) *SelfTestHandler {
+	return &SelfTestHandler{
+		runner: runner,
+		logger: logger,
+	}
+}
+
+// RunSelfTest drives a synthetic order through the whole system and reports each step.
+// Only one run is allowed at a time so repeated calls don't pile up test data.
+func (h *SelfTestHandler) RunSelfTest(c *gin.Context) {
+	if !h.running.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Self-test already running"})
+		return
+	}
+	defer h.running.Unlock()
+
+	h.logger.Info("Self-test started")
+	report := h.runner.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != selftest.StatusPassed {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
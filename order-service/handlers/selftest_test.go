@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"order-svc/middleware"
+	"order-svc/selftest"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeSystem stands in for user, product, order and notification services.
+func fakeSystem(t *testing.T, paymentStatus string, notified bool) (*httptest.Server, *bool) {
+	t.Helper()
+	productDeleted := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/register", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	})
+	mux.HandleFunc("POST /api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		var login struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&login)
+		if strings.HasPrefix(login.Email, "selftest+") {
+			w.Write([]byte(`{"token":"user-jwt"}`))
+			return
+		}
+		if login.Password != "selftest-admin-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"token":"admin-jwt"}`))
+	})
+	mux.HandleFunc("POST /api/v1/products", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":11}`))
+	})
+	mux.HandleFunc("DELETE /api/v1/products/11", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		productDeleted = true
+		w.Write([]byte(`{"message":"Product deleted successfully"}`))
+	})
+	mux.HandleFunc("POST /api/v1/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":42,"status":"pending"}`))
+	})
+	mux.HandleFunc("GET /api/v1/orders/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":42,"status":"` + paymentStatus + `"}`))
+	})
+	mux.HandleFunc("GET /api/v1/orders/42/notifications", func(w http.ResponseWriter, r *http.Request) {
+		if !notified {
+			w.Write([]byte(`{"order_id":42,"notifications":[]}`))
+			return
+		}
+		w.Write([]byte(`{"order_id":42,"notifications":[{"event_type":"payment_success"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	for _, key := range []string{"USER_SERVICE_URL", "PRODUCT_SERVICE_URL", "SELFTEST_ORDER_URL", "NOTIFICATION_SERVICE_URL"} {
+		t.Setenv(key, srv.URL)
+	}
+	t.Setenv("SELFTEST_ADMIN_PASSWORD", "selftest-admin-password")
+	t.Setenv("SELFTEST_STEP_TIMEOUT", "1s")
+	return srv, &productDeleted
+}
+
+func setupSelfTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := zaptest.NewLogger(t)
+	h := NewSelfTestHandler(selftest.NewRunner(logger), logger)
+	router := gin.New()
+	router.POST("/admin/selftest", fakeAuth, middleware.RequireRole(middleware.RoleAdmin), h.RunSelfTest)
+	return router
+}
+
+// runSelfTest runs the self-test as a user with role
+func runSelfTest(t *testing.T, router *gin.Engine, role string) (*httptest.ResponseRecorder, selftest.Report) {
+	t.Helper()
+	req := userRequest(http.MethodPost, "/admin/selftest", 1, role)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var report selftest.Report
+	if w.Code == http.StatusOK || w.Code == http.StatusServiceUnavailable {
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to decode report: %v", err)
+		}
+	}
+	return w, report
+}
+
+func TestRunSelfTest_Passed(t *testing.T) {
+	_, deleted := fakeSystem(t, "paid", true)
+	router := setupSelfTestRouter(t)
+
+	w, report := runSelfTest(t, router, middleware.RoleAdmin)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if report.Status != selftest.StatusPassed {
+		t.Errorf("expected report status passed, got %s", report.Status)
+	}
+	if len(report.Steps) != 6 {
+		t.Fatalf("expected 6 steps, got %d", len(report.Steps))
+	}
+	for _, step := range report.Steps {
+		if step.Status != selftest.StatusPassed {
+			t.Errorf("expected step %s to pass, got %s (%s)", step.Name, step.Status, step.Error)
+		}
+	}
+	if !*deleted {
+		t.Error("expected test product to be deleted")
+	}
+}
+
+func TestRunSelfTest_MissingNotificationFailsAndCleansUp(t *testing.T) {
+	_, deleted := fakeSystem(t, "paid", false)
+	router := setupSelfTestRouter(t)
+
+	w, report := runSelfTest(t, router, middleware.RoleAdmin)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if report.Status != selftest.StatusFailed {
+		t.Errorf("expected report status failed, got %s", report.Status)
+	}
+
+	statuses := map[string]string{}
+	for _, step := range report.Steps {
+		statuses[step.Name] = step.Status
+	}
+	if statuses["verify_notification"] != selftest.StatusFailed {
+		t.Errorf("expected verify_notification to fail, got %s", statuses["verify_notification"])
+	}
+	if statuses["delete_test_product"] != selftest.StatusPassed {
+		t.Errorf("expected cleanup to run, got %s", statuses["delete_test_product"])
+	}
+	if !*deleted {
+		t.Error("expected test product to be deleted")
+	}
+}
+
+func TestRunSelfTest_RequiresAdmin(t *testing.T) {
+	router := setupSelfTestRouter(t)
+
+	w, _ := runSelfTest(t, router, "customer")
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
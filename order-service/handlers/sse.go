@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-svc/cache"
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// sseHeartbeat is how often an idle stream sends a comment, so proxies and load
+// balancers don't close it while the saga is waiting on payment
+const sseHeartbeat = 15 * time.Second
+
+// orderStatusEvent is the data of the "status" and "done" events
+type orderStatusEvent struct {
+	OrderID        int                `json:"order_id"`
+	Status         models.OrderStatus `json:"status"`
+	PreviousStatus models.OrderStatus `json:"previous_status,omitempty"`
+	SourceEvent    string             `json:"source_event,omitempty"`
+	ChangedAt      *time.Time         `json:"changed_at,omitempty"`
+}
+
+// StreamOrderEvents streams an order's status over Server-Sent Events: a "status" event
+// with the current status, one per change the Kafka consumer applies, and a final "done"
+// event once the order settles. The REST counterpart of the gRPC WatchOrder stream. Only
+// the order's owner and admins can follow it.
+func (h *OrderHandler) StreamOrderEvents(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "StreamOrderEvents")
+	defer span.End()
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("order.id", orderID))
+
+	// Only the customer who placed the order, or an admin, may follow it
+	var ownerID int
+	err = h.db.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE id = $1", orderID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !authorizeOrderAccess(c, ownerID) {
+		return
+	}
+
+	// Subscribe before reading the status, so a change in between isn't missed
+	sub, err := cache.SubscribeOrderStatus(ctx, h.redisClient, orderID)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to subscribe to order status", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Int("order_id", orderID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Order status updates are unavailable"})
+		return
+	}
+	defer sub.Close()
+
+	var current models.OrderStatus
+	err = h.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get order", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	sent, final := h.streamOrderStatus(c, orderID, current, sub.Channel())
+	span.SetAttributes(attribute.Int("watch.sent", sent), attribute.String("order.status", string(final)))
+}
+
+// streamOrderStatus writes the SSE stream until the order settles, the client leaves or
+// the updates channel closes. It returns how many status events were sent and the last
+// status sent.
+func (h *OrderHandler) streamOrderStatus(c *gin.Context, orderID int, current models.OrderStatus, updates <-chan *redis.Message) (int, models.OrderStatus) {
+	// c.SSEvent sets the text/event-stream content type
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Keeps nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent("status", orderStatusEvent{OrderID: orderID, Status: current})
+	c.Writer.Flush()
+	sent := 1
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for !current.Settled() {
+		select {
+		case <-c.Request.Context().Done():
+			return sent, current
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case msg, ok := <-updates:
+			if !ok {
+				// The client reconnects and gets the current status again
+				return sent, current
+			}
+
+			var change models.OrderStatusChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				h.logger.Warn("Ignoring malformed order status change", zap.Int("order_id", orderID), zap.Error(err))
+				continue
+			}
+			// Already reflected in the status sent first
+			if change.FromStatus != current {
+				continue
+			}
+
+			changedAt := change.CreatedAt.UTC()
+			c.SSEvent("status", orderStatusEvent{
+				OrderID:        orderID,
+				Status:         change.ToStatus,
+				PreviousStatus: change.FromStatus,
+				SourceEvent:    change.SourceEvent,
+				ChangedAt:      &changedAt,
+			})
+			c.Writer.Flush()
+			current = change.ToStatus
+			sent++
+		}
+	}
+
+	// EventSource reconnects whenever a stream ends; "done" tells the browser to close it
+	c.SSEvent("done", orderStatusEvent{OrderID: orderID, Status: current})
+	c.Writer.Flush()
+	return sent, current
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zaptest"
+)
+
+func statusMessage(t *testing.T, from, to models.OrderStatus) *redis.Message {
+	t.Helper()
+	payload, err := json.Marshal(models.OrderStatusChange{
+		OrderID:     1,
+		FromStatus:  from,
+		ToStatus:    to,
+		SourceEvent: "payment_success",
+		CreatedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal status change: %v", err)
+	}
+	return &redis.Message{Payload: string(payload)}
+}
+
+func TestOrderHandler_StreamOrderStatus_UntilSettled(t *testing.T) {
+	handler := &OrderHandler{logger: zaptest.NewLogger(t)}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/orders/1/events", nil)
+
+	updates := make(chan *redis.Message, 3)
+	updates <- &redis.Message{Payload: "not json"}
+	// A change the first event already reflects is skipped
+	updates <- statusMessage(t, models.OrderStatusCancelled, models.OrderStatusPending)
+	updates <- statusMessage(t, models.OrderStatusPending, models.OrderStatusPaid)
+
+	sent, final := handler.streamOrderStatus(c, 1, models.OrderStatusPending, updates)
+	if sent != 2 || final != models.OrderStatusPaid {
+		t.Fatalf("Expected 2 events ending in paid, got %d ending in %s", sent, final)
+	}
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Expected event-stream content type, got %q", ct)
+	}
+	want := "event:status\n" +
+		`data:{"order_id":1,"status":"pending"}` + "\n\n" +
+		"event:status\n" +
+		`data:{"order_id":1,"status":"paid","previous_status":"pending","source_event":"payment_success","changed_at":"2026-01-02T03:04:05Z"}` + "\n\n" +
+		"event:done\n" +
+		`data:{"order_id":1,"status":"paid"}` + "\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Unexpected stream:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOrderHandler_StreamOrderStatus_SettledOrder(t *testing.T) {
+	handler := &OrderHandler{logger: zaptest.NewLogger(t)}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/orders/1/events", nil)
+
+	sent, _ := handler.streamOrderStatus(c, 1, models.OrderStatusFailed, nil)
+	if sent != 1 {
+		t.Errorf("Expected only the current status, got %d events", sent)
+	}
+	if !strings.HasSuffix(w.Body.String(), "event:done\n"+`data:{"order_id":1,"status":"failed"}`+"\n\n") {
+		t.Errorf("Expected the stream to end with done, got %q", w.Body.String())
+	}
+}
+
+func TestOrderHandler_StreamOrderEvents_OwnerOrAdmin(t *testing.T) {
+	handler, mock, router := setupOrderTest(t)
+	defer handler.db.Close()
+
+	// Requests that get past the ownership check fail to subscribe instead of streaming
+	handler.redisClient = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer handler.redisClient.Close()
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"no token", httptest.NewRequest(http.MethodGet, "/orders/1/events", nil), http.StatusUnauthorized},
+		{"other customer", userRequest(http.MethodGet, "/orders/1/events", 6, "customer"), http.StatusForbidden},
+		{"owner", userRequest(http.MethodGet, "/orders/1/events", 5, "customer"), http.StatusServiceUnavailable},
+		{"admin", userRequest(http.MethodGet, "/orders/1/events", 6, "admin"), http.StatusServiceUnavailable},
+	}
+	for range tests {
+		expectOrderOwner(mock, 1, 5)
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			t.Errorf("%s: expected no stream to start", tt.name)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"order-svc/grpc"
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/pricing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	validationQuantityLimit     = "quantity_limit"
+	validationProductNotFound   = "product_not_found"
+	validationVariantNotFound   = "variant_not_found"
+	validationInsufficientStock = "insufficient_stock"
+	validationUserNotFound      = "user_not_found"
+	validationRiskTooHigh       = "risk_too_high"
+	validationInvalidDiscount   = "invalid_discount_code"
+	validationInvalidMetadata   = "invalid_metadata"
+	validationNotesTooLong      = "notes_too_long"
+	validationCurrencyMismatch  = "currency_mismatch"
+)
+
+// defaultCurrency is the currency of products priced before product-service recorded
+// currencies
+const defaultCurrency = "USD"
+
+// Limits on what integrators can attach to an order, which travels on every order event
+const (
+	maxMetadataKeys        = 20
+	maxMetadataKeyLength   = 40
+	maxMetadataValueLength = 500
+	maxNotesLength         = 1000
+)
+
+// errUserServiceUnavailable wraps lookup failures that leave the ordering user unconfirmed
+var errUserServiceUnavailable = errors.New("user service unavailable")
+
+// orderValidator is the checkout validation pipeline shared by order creation and the
+// dry-run endpoint: order limits, pricing, availability, the user's existence and a
+// fraud pre-check.
+type orderValidator struct {
+	productClient *grpc.ProductClient
+	userClient    *grpc.UserClient
+	maxQuantity   int
+	maxRiskScore  float64
+	pricer        *pricing.Pricer
+	logger        *zap.Logger
+}
+
+func newOrderValidator(productClient *grpc.ProductClient, userClient *grpc.UserClient, logger *zap.Logger) *orderValidator {
+	return &orderValidator{
+		productClient: productClient,
+		userClient:    userClient,
+		maxQuantity:   getEnvInt("MAX_ORDER_QUANTITY", 100),
+		maxRiskScore:  getEnvFloat("FRAUD_MAX_RISK_SCORE", 0.8),
+		pricer:        pricing.Load(),
+		logger:        logger,
+	}
+}
+
+// Validate runs every check and collects all blocking errors rather than stopping at the
+// first, so a checkout summary can show them together. The order is taxed at orderRegion's
+// rate. checkStock is false when the caller reserves stock itself, since the reservation
+// is the authoritative availability check. An error is returned only when a dependency
+// needed to decide is unavailable.
+func (v *orderValidator) Validate(ctx context.Context, req models.CreateOrderRequest, orderRegion string, checkStock bool) (*models.OrderValidation, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "ValidateOrder")
+	defer span.End()
+
+	result := &models.OrderValidation{
+		UserID:    req.UserID,
+		ProductID: req.ProductID,
+		VariantID: req.VariantID,
+		Quantity:  req.Quantity,
+		Errors:    []models.ValidationError{},
+	}
+
+	if req.Quantity > v.maxQuantity {
+		result.AddError(validationQuantityLimit, fmt.Sprintf("At most %d units can be ordered at once", v.maxQuantity))
+	}
+	checkAttachments(req, result)
+
+	productResp, err := v.productClient.GetProduct(ctx, int32(req.ProductID))
+	switch {
+	case status.Code(err) == codes.NotFound:
+		result.AddError(validationProductNotFound, "Product not found")
+	case err != nil:
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get product details: %w", err)
+	default:
+		result.ProductName = productResp.GetName()
+		result.UnitPrice = float64(productResp.GetPrice())
+		result.Currency = productResp.GetCurrency()
+		if result.Currency == "" {
+			result.Currency = defaultCurrency
+		}
+		if req.Currency != "" && req.Currency != result.Currency {
+			result.AddError(validationCurrencyMismatch, fmt.Sprintf("Product is priced in %s, not %s", result.Currency, req.Currency))
+		}
+	}
+
+	// A variant has its own price, and must belong to the ordered product
+	if req.VariantID != 0 && productResp != nil {
+		variantResp, err := v.productClient.GetVariant(ctx, int32(req.VariantID))
+		switch {
+		case status.Code(err) == codes.NotFound, err == nil && int(variantResp.GetProductId()) != req.ProductID:
+			result.AddError(validationVariantNotFound, "Product variant not found")
+			productResp = nil
+		case err != nil:
+			span.RecordError(err)
+			return nil, fmt.Errorf("failed to get variant details: %w", err)
+		default:
+			result.UnitPrice = float64(variantResp.GetPrice())
+		}
+	}
+
+	if productResp != nil {
+		breakdown, err := v.pricer.Price(result.UnitPrice, req.Quantity, orderRegion, req.DiscountCode)
+		if errors.Is(err, pricing.ErrUnknownDiscount) {
+			result.AddError(validationInvalidDiscount, "Discount code is not valid")
+		}
+		result.Pricing = &breakdown
+		result.TaxRate = breakdown.TaxRate
+		result.TotalPrice = breakdown.Total
+	}
+
+	if checkStock && productResp != nil {
+		available, stock, err := v.productClient.CheckAvailability(ctx, int32(req.ProductID), int32(req.VariantID), int32(req.Quantity))
+		if err != nil {
+			span.RecordError(err)
+			return nil, fmt.Errorf("failed to check availability: %w", err)
+		}
+		stockLeft := int(stock)
+		result.Stock = &stockLeft
+		if !available {
+			result.AddError(validationInsufficientStock, "Product not available")
+		}
+	}
+
+	exists, err := v.checkUser(ctx, req.UserID, result)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if exists {
+		v.checkRisk(ctx, req.UserID, result)
+	}
+
+	result.Valid = len(result.Errors) == 0
+	span.SetAttributes(
+		attribute.Bool("order.valid", result.Valid),
+		attribute.Int("order.validation_errors", len(result.Errors)),
+	)
+	return result, nil
+}
+
+// checkUser confirms the ordering user exists. Unlike the fraud pre-check it fails
+// closed, so orders are never created for unknown users while user-service is down.
+func (v *orderValidator) checkUser(ctx context.Context, userID int, result *models.OrderValidation) (bool, error) {
+	if v.userClient == nil {
+		return true, nil
+	}
+
+	_, err := v.userClient.GetUser(ctx, int32(userID))
+	if errors.Is(err, grpc.ErrUserNotFound) {
+		result.AddError(validationUserNotFound, "User not found")
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errUserServiceUnavailable, err)
+	}
+	return true, nil
+}
+
+// unavailableMessage is the 503 error for a failed validation, naming the service
+func unavailableMessage(err error) string {
+	if errors.Is(err, errUserServiceUnavailable) {
+		return "User service unavailable"
+	}
+	return "Product service unavailable"
+}
+
+// checkRisk blocks users whose payment history makes them too risky. The pre-check fails
+// open: if user-service can't be reached the order proceeds and the miss is logged.
+func (v *orderValidator) checkRisk(ctx context.Context, userID int, result *models.OrderValidation) {
+	if v.userClient == nil {
+		return
+	}
+
+	score, err := v.userClient.GetRiskScore(ctx, int32(userID))
+	if status.Code(err) == codes.NotFound {
+		result.AddError(validationUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		v.logger.Warn("Skipping fraud pre-check, user service unavailable",
+			zap.String("trace_id", middleware.GetTraceID(ctx)),
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if score >= v.maxRiskScore {
+		result.AddError(validationRiskTooHigh, "Order blocked by fraud pre-check")
+	}
+}
+
+// checkAttachments enforces the limits on the order's metadata and notes
+func checkAttachments(req models.CreateOrderRequest, result *models.OrderValidation) {
+	if len(req.Metadata) > maxMetadataKeys {
+		result.AddError(validationInvalidMetadata, fmt.Sprintf("Metadata can have at most %d keys", maxMetadataKeys))
+	}
+	for key, value := range req.Metadata {
+		if key == "" || len(key) > maxMetadataKeyLength {
+			result.AddError(validationInvalidMetadata, fmt.Sprintf("Metadata keys must be 1-%d characters", maxMetadataKeyLength))
+			break
+		}
+		if len(value) > maxMetadataValueLength {
+			result.AddError(validationInvalidMetadata, fmt.Sprintf("Metadata values can be at most %d characters", maxMetadataValueLength))
+			break
+		}
+	}
+	if len(req.Notes) > maxNotesLength {
+		result.AddError(validationNotesTooLong, fmt.Sprintf("Notes can be at most %d characters", maxNotesLength))
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
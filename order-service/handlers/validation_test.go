@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"order-svc/grpc"
+	"order-svc/models"
+	"order-svc/proto/product"
+	"order-svc/proto/user"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+	grpcLib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeProductServer struct {
+	product.UnimplementedProductServiceServer
+}
+
+func (fakeProductServer) GetProduct(_ context.Context, req *product.GetProductRequest) (*product.GetProductResponse, error) {
+	if req.GetProductId() != 1 {
+		return nil, status.Error(codes.NotFound, "product not found")
+	}
+	return &product.GetProductResponse{Id: 1, Name: "Widget", Price: 10, Stock: 3}, nil
+}
+
+func (fakeProductServer) CheckAvailability(_ context.Context, req *product.CheckAvailabilityRequest) (*product.CheckAvailabilityResponse, error) {
+	return &product.CheckAvailabilityResponse{Available: req.GetQuantity() <= 3, Stock: 3}, nil
+}
+
+// Variant 7 is a pricier size of product 1; variant 8 belongs to another product
+func (fakeProductServer) GetVariant(_ context.Context, req *product.GetVariantRequest) (*product.ProductVariant, error) {
+	switch req.GetVariantId() {
+	case 7:
+		return &product.ProductVariant{Id: 7, ProductId: 1, Sku: "WIDGET-XL", Size: "XL", Price: 12.5, Stock: 3}, nil
+	case 8:
+		return &product.ProductVariant{Id: 8, ProductId: 2, Sku: "GADGET-S", Size: "S", Price: 4, Stock: 3}, nil
+	}
+	return nil, status.Error(codes.NotFound, "variant not found")
+}
+
+type fakeUserServer struct {
+	user.UnimplementedUserServiceServer
+}
+
+// User 1 is trusted and user 2 has a long history of failed payments
+func (fakeUserServer) GetRiskScore(_ context.Context, req *user.GetRiskScoreRequest) (*user.GetRiskScoreResponse, error) {
+	switch req.GetUserId() {
+	case 1:
+		return &user.GetRiskScoreResponse{UserId: 1, RiskScore: 0.1}, nil
+	case 2:
+		return &user.GetRiskScoreResponse{UserId: 2, RiskScore: 0.9}, nil
+	}
+	return nil, status.Error(codes.NotFound, "user not found")
+}
+
+// Users 1 and 2 exist
+func (fakeUserServer) GetUser(_ context.Context, req *user.GetUserRequest) (*user.GetUserResponse, error) {
+	if id := req.GetUserId(); id == 1 || id == 2 {
+		return &user.GetUserResponse{UserId: id}, nil
+	}
+	return nil, status.Error(codes.NotFound, "user not found")
+}
+
+// serveGRPC starts a gRPC server on a free local port and returns its address
+func serveGRPC(t *testing.T, register func(*grpcLib.Server)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := grpcLib.NewServer()
+	register(srv)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+	return listener.Addr().String()
+}
+
+func setupValidationTest(t *testing.T) *gin.Engine {
+	t.Setenv("PRODUCT_SERVICE_GRPC", serveGRPC(t, func(s *grpcLib.Server) {
+		product.RegisterProductServiceServer(s, fakeProductServer{})
+	}))
+	t.Setenv("USER_SERVICE_GRPC", serveGRPC(t, func(s *grpcLib.Server) {
+		user.RegisterUserServiceServer(s, fakeUserServer{})
+	}))
+	t.Setenv("MAX_ORDER_QUANTITY", "5")
+
+	logger := zaptest.NewLogger(t)
+	productClient, err := grpc.InitProductClient(logger)
+	if err != nil {
+		t.Fatalf("Failed to create product client: %v", err)
+	}
+	t.Cleanup(func() { productClient.Close() })
+	userClient, err := grpc.InitUserClient(logger)
+	if err != nil {
+		t.Fatalf("Failed to create user client: %v", err)
+	}
+	t.Cleanup(func() { userClient.Close() })
+
+	handler := NewOrderHandler(nil, nil, nil, productClient, userClient, nil, logger)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders/validate", fakeAuth, handler.ValidateOrder)
+	router.POST("/orders", fakeAuth, handler.CreateOrder)
+	return router
+}
+
+// validateOrder dry-runs req as its user_id, who is authenticated as after fakeAuth
+func validateOrder(t *testing.T, router *gin.Engine, req models.CreateOrderRequest) models.OrderValidation {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/orders/validate", bytes.NewBuffer(body))
+	httpReq.Header.Set("X-User-ID", strconv.Itoa(req.UserID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httpReq)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "risk_score") {
+		t.Errorf("Expected the user's risk score to stay out of the response, got %s", w.Body.String())
+	}
+	var result models.OrderValidation
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+func errorCodes(result models.OrderValidation) map[string]bool {
+	found := map[string]bool{}
+	for _, e := range result.Errors {
+		found[e.Code] = true
+	}
+	return found
+}
+
+func TestOrderHandler_ValidateOrder_Valid(t *testing.T) {
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 2})
+
+	if !result.Valid || len(result.Errors) != 0 {
+		t.Fatalf("Expected a valid order, got errors %+v", result.Errors)
+	}
+	if result.UnitPrice != 10 || result.TotalPrice != 20 {
+		t.Errorf("Expected unit price 10 and total 20, got %v and %v", result.UnitPrice, result.TotalPrice)
+	}
+	if result.Stock == nil || *result.Stock != 3 {
+		t.Errorf("Expected stock 3, got %v", result.Stock)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_AuthenticatedUserOnly(t *testing.T) {
+	router := setupValidationTest(t)
+
+	tests := []struct {
+		name   string
+		userID string
+		body   string
+		want   int
+	}{
+		{"no token", "", `{"product_id":1,"quantity":1}`, http.StatusUnauthorized},
+		{"another user", "1", `{"user_id":2,"product_id":1,"quantity":1}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders/validate", strings.NewReader(tt.body))
+			if tt.userID != "" {
+				req.Header.Set("X-User-ID", tt.userID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The body may still name the token's own user
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 2, ProductID: 1, Quantity: 1})
+	if result.UserID != 2 || !errorCodes(result)[validationRiskTooHigh] {
+		t.Errorf("Expected user 2's order to be blocked by the fraud pre-check, got %+v", result)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_CollectsAllErrors(t *testing.T) {
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 2, ProductID: 1, Quantity: 6})
+
+	if result.Valid {
+		t.Fatal("Expected the order to be invalid")
+	}
+	found := errorCodes(result)
+	for _, code := range []string{validationQuantityLimit, validationInsufficientStock, validationRiskTooHigh} {
+		if !found[code] {
+			t.Errorf("Expected error %s, got %+v", code, result.Errors)
+		}
+	}
+	// Totals are still computed so the summary can show them
+	if result.TotalPrice != 60 {
+		t.Errorf("Expected total 60, got %v", result.TotalPrice)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_UnknownProductAndUser(t *testing.T) {
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 99, ProductID: 42, Quantity: 1})
+
+	found := errorCodes(result)
+	if result.Valid || !found[validationProductNotFound] || !found[validationUserNotFound] {
+		t.Errorf("Expected product_not_found and user_not_found, got %+v", result.Errors)
+	}
+	if result.Stock != nil {
+		t.Errorf("Expected no stock check for a missing product, got %v", *result.Stock)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_Variant(t *testing.T) {
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, VariantID: 7, Quantity: 2})
+	if !result.Valid || result.VariantID != 7 {
+		t.Fatalf("Expected a valid variant order, got %+v", result)
+	}
+	if result.UnitPrice != 12.5 || result.TotalPrice != 25 {
+		t.Errorf("Expected the variant's unit price 12.5 and total 25, got %v and %v", result.UnitPrice, result.TotalPrice)
+	}
+
+	for _, variantID := range []int{8, 99} {
+		result := validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, VariantID: variantID, Quantity: 1})
+		if result.Valid || !errorCodes(result)[validationVariantNotFound] {
+			t.Errorf("Variant %d: expected variant_not_found, got %+v", variantID, result.Errors)
+		}
+		if result.Stock != nil {
+			t.Errorf("Variant %d: expected no stock check, got %v", variantID, *result.Stock)
+		}
+	}
+}
+
+func TestOrderHandler_ValidateOrder_MetadataAndNotesLimits(t *testing.T) {
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{
+		UserID:    1,
+		ProductID: 1,
+		Quantity:  1,
+		Metadata:  map[string]string{"cart_id": strings.Repeat("x", maxMetadataValueLength+1)},
+		Notes:     strings.Repeat("n", maxNotesLength+1),
+	})
+
+	found := errorCodes(result)
+	if result.Valid || !found[validationInvalidMetadata] || !found[validationNotesTooLong] {
+		t.Errorf("Expected invalid_metadata and notes_too_long, got %+v", result.Errors)
+	}
+
+	result = validateOrder(t, router, models.CreateOrderRequest{
+		UserID:    1,
+		ProductID: 1,
+		Quantity:  1,
+		Metadata:  map[string]string{"cart_id": "cart-81", "campaign_id": "spring"},
+		Notes:     "Gift wrap, please",
+	})
+	if !result.Valid {
+		t.Errorf("Expected a valid order, got errors %+v", result.Errors)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_TaxAndDiscount(t *testing.T) {
+	t.Setenv("ORDER_TAX_RATE", "0.1")
+	t.Setenv("ORDER_DISCOUNT_CODES", "SAVE10=10%")
+	router := setupValidationTest(t)
+
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 2, DiscountCode: "save10"})
+
+	if !result.Valid {
+		t.Fatalf("Expected a valid order, got errors %+v", result.Errors)
+	}
+	want := models.PriceBreakdown{Subtotal: 20, DiscountCode: "SAVE10", Discount: 2, TaxRate: 0.1, Tax: 1.8, Total: 19.8}
+	if result.Pricing == nil || *result.Pricing != want {
+		t.Errorf("Expected pricing %+v, got %+v", want, result.Pricing)
+	}
+	if result.TotalPrice != want.Total {
+		t.Errorf("Expected total_price %v, got %v", want.Total, result.TotalPrice)
+	}
+
+	result = validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 2, DiscountCode: "BOGUS"})
+	if result.Valid || !errorCodes(result)[validationInvalidDiscount] {
+		t.Errorf("Expected invalid_discount_code, got %+v", result.Errors)
+	}
+}
+
+func TestOrderHandler_ValidateOrder_Currency(t *testing.T) {
+	router := setupValidationTest(t)
+
+	// The fake product predates currencies, so it is priced in the default
+	result := validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 1, Currency: "USD"})
+	if !result.Valid || result.Currency != "USD" {
+		t.Fatalf("Expected a valid USD order, got currency %q and errors %+v", result.Currency, result.Errors)
+	}
+
+	result = validateOrder(t, router, models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 1, Currency: "EUR"})
+	if result.Valid || !errorCodes(result)[validationCurrencyMismatch] {
+		t.Errorf("Expected currency_mismatch, got %+v", result.Errors)
+	}
+}
+
+func TestOrderHandler_CreateOrder_UnknownUser(t *testing.T) {
+	router := setupValidationTest(t)
+
+	// Rejected before any stock is reserved or the order is stored
+	body, _ := json.Marshal(models.CreateOrderRequest{ProductID: 1, Quantity: 1})
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBuffer(body))
+	req.Header.Set("X-User-ID", "99")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	var response struct {
+		Errors []models.ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Code != validationUserNotFound {
+		t.Errorf("Expected only user_not_found, got %+v", response.Errors)
+	}
+}
+
+func TestOrderHandler_CreateOrder_UserFromToken(t *testing.T) {
+	router := setupValidationTest(t)
+
+	tests := []struct {
+		name   string
+		userID string
+		body   models.CreateOrderRequest
+		want   int
+	}{
+		{"no user claim", "", models.CreateOrderRequest{UserID: 1, ProductID: 1, Quantity: 1}, http.StatusUnauthorized},
+		{"someone else's order", "1", models.CreateOrderRequest{UserID: 2, ProductID: 1, Quantity: 1}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.body)
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBuffer(body))
+		if tt.userID != "" {
+			req.Header.Set("X-User-ID", tt.userID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"order-svc/middleware"
+	"order-svc/models"
+	"order-svc/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxWebhookDeliveries bounds the delivery log returned for a webhook
+const maxWebhookDeliveries = 100
+
+// webhookColumns is the column list scanned by scanWebhook
+const webhookColumns = "id, url, statuses, active, created_at, updated_at"
+
+// WebhookHandler manages the webhooks notified of order status changes
+type WebhookHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewWebhookHandler(db *sql.DB, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{db: db, logger: logger}
+}
+
+// CreateWebhook registers a webhook and returns it with its signing secret, which is
+// not shown again
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "CreateWebhook")
+	defer span.End()
+
+	req, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to generate webhook secret", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var hook models.Webhook
+	err = scanWebhook(h.db.QueryRowContext(ctx,
+		"INSERT INTO webhooks (url, secret, statuses, active) VALUES ($1, $2, $3, $4) RETURNING "+webhookColumns,
+		req.URL, secret, pq.Array(req.Statuses), *req.Active,
+	), &hook)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to create webhook", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	hook.Secret = secret
+
+	span.SetAttributes(attribute.Int("webhook.id", hook.ID))
+	h.logger.Info("Webhook created", zap.Int("webhook_id", hook.ID), zap.String("url", hook.URL))
+	c.JSON(http.StatusCreated, hook)
+}
+
+// ListWebhooks returns every registered webhook, oldest first
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ListWebhooks")
+	defer span.End()
+
+	rows, err := h.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list webhooks", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		if err := scanWebhook(rows, &hook); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan webhook", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		hooks = append(hooks, hook)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": hooks})
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "GetWebhook")
+	defer span.End()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("webhook.id", id))
+
+	var hook models.Webhook
+	err := scanWebhook(h.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id), &hook)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get webhook", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// UpdateWebhook replaces a webhook's URL, statuses and active flag. The secret is kept.
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "UpdateWebhook")
+	defer span.End()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("webhook.id", id))
+
+	req, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	var hook models.Webhook
+	err := scanWebhook(h.db.QueryRowContext(ctx,
+		"UPDATE webhooks SET url = $1, statuses = $2, active = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4 RETURNING "+webhookColumns,
+		req.URL, pq.Array(req.Statuses), *req.Active, id,
+	), &hook)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to update webhook", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Webhook updated", zap.Int("webhook_id", hook.ID))
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook removes a webhook together with its delivery log and pending deliveries
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "DeleteWebhook")
+	defer span.End()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("webhook.id", id))
+
+	result, err := h.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to delete webhook", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	h.logger.Info("Webhook deleted", zap.Int("webhook_id", id))
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// ListWebhookDeliveries returns a webhook's most recent deliveries, newest first
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	ctx, span := otel.Tracer("order-service").Start(c.Request.Context(), "ListWebhookDeliveries")
+	defer span.End()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("webhook.id", id))
+
+	var exists bool
+	if err := h.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)", id).Scan(&exists); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get webhook", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	query := `SELECT id, webhook_id, order_id, to_status, status, attempts, last_status_code, COALESCE(last_error, ''), next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries WHERE webhook_id = $1`
+	args := []interface{}{id}
+	if status := c.Query("status"); status != "" {
+		args = append(args, status)
+		query += " AND status = $" + strconv.Itoa(len(args))
+	}
+	args = append(args, maxWebhookDeliveries)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list webhook deliveries", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var statusCode sql.NullInt64
+		var nextAttemptAt, deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.OrderID, &d.ToStatus, &d.Status, &d.Attempts, &statusCode, &d.LastError, &nextAttemptAt, &d.CreatedAt, &deliveredAt); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan webhook delivery", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			d.LastStatusCode = &code
+		}
+		if nextAttemptAt.Valid {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	span.SetAttributes(attribute.Int("deliveries.count", len(deliveries)))
+	c.JSON(http.StatusOK, gin.H{"webhook_id": id, "data": deliveries})
+}
+
+// bindWebhookRequest parses and checks a create or update body, responding 400 if it
+// is invalid
+func bindWebhookRequest(c *gin.Context) (models.WebhookRequest, bool) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http or https URL"})
+		return req, false
+	}
+	for _, status := range req.Statuses {
+		if !status.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status " + string(status)})
+			return req, false
+		}
+	}
+	if req.Statuses == nil {
+		req.Statuses = []models.OrderStatus{}
+	}
+	if req.Active == nil {
+		active := true
+		req.Active = &active
+	}
+	return req, true
+}
+
+func webhookID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func scanWebhook(row rowScanner, hook *models.Webhook) error {
+	var statuses []string
+	if err := row.Scan(&hook.ID, &hook.URL, pq.Array(&statuses), &hook.Active, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		return err
+	}
+	hook.Statuses = make([]models.OrderStatus, len(statuses))
+	for i, status := range statuses {
+		hook.Statuses[i] = models.OrderStatus(status)
+	}
+	return nil
+}
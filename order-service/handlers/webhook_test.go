@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+func setupWebhookTest(t *testing.T) (*WebhookHandler, sqlmock.Sqlmock, *gin.Engine) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+
+	handler := NewWebhookHandler(db, zaptest.NewLogger(t))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhooks", handler.CreateWebhook)
+	router.PUT("/webhooks/:id", handler.UpdateWebhook)
+	router.DELETE("/webhooks/:id", handler.DeleteWebhook)
+
+	return handler, mock, router
+}
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	handler, mock, router := setupWebhookTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("INSERT INTO webhooks \\(url, secret, statuses, active\\)").
+		WithArgs("https://merchant.example/hooks", sqlmock.AnyArg(), sqlmock.AnyArg(), true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "statuses", "active", "created_at", "updated_at"}).
+			AddRow(1, "https://merchant.example/hooks", "{paid,refunded}", true, time.Now(), time.Now()))
+
+	body := `{"url": "https://merchant.example/hooks", "statuses": ["paid", "refunded"]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// The secret is only ever returned here
+	var hook models.Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &hook); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !strings.HasPrefix(hook.Secret, "whsec_") {
+		t.Errorf("Expected a generated secret, got %q", hook.Secret)
+	}
+	if len(hook.Statuses) != 2 || hook.Statuses[1] != models.OrderStatusRefunded {
+		t.Errorf("Expected statuses [paid refunded], got %v", hook.Statuses)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestWebhookHandler_InvalidRequests(t *testing.T) {
+	handler, mock, router := setupWebhookTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectExec("DELETE FROM webhooks WHERE id = \\$1").
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/webhooks", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/webhooks", `{"url": "ftp://merchant.example/hooks"}`, http.StatusBadRequest},
+		{http.MethodPost, "/webhooks", `{"url": "https://merchant.example/hooks", "statuses": ["shipped"]}`, http.StatusBadRequest},
+		{http.MethodPut, "/webhooks/abc", `{"url": "https://merchant.example/hooks"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/webhooks/9", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s %s: expected status %d, got %d", tt.method, tt.path, tt.body, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
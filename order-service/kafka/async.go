@@ -0,0 +1,118 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/outbox"
+	"order-svc/startup"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// outboxWriteTimeout bounds saving one failed message to the outbox
+const outboxWriteTimeout = 5 * time.Second
+
+// AsyncProducer batches order events instead of waiting for every acknowledgement, so
+// publishing doesn't add broker latency to requests. Messages Kafka rejects are saved to
+// the outbox; if that fails too, the event is dropped and counted.
+type AsyncProducer struct {
+	producer sarama.AsyncProducer
+	db       *sql.DB
+	logger   *zap.Logger
+	done     chan struct{}
+}
+
+// InitAsyncProducer connects a producer that flushes a batch after
+// KAFKA_PRODUCER_LINGER or once KAFKA_PRODUCER_BATCH_SIZE messages are waiting
+func InitAsyncProducer(db *sql.DB, logger *zap.Logger) (*AsyncProducer, error) {
+	linger := 10 * time.Millisecond
+	if value, err := time.ParseDuration(getEnv("KAFKA_PRODUCER_LINGER", "")); err == nil && value > 0 {
+		linger = value
+	}
+	batchSize := 100
+	if value, err := strconv.Atoi(getEnv("KAFKA_PRODUCER_BATCH_SIZE", "")); err == nil && value > 0 {
+		batchSize = value
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = false
+	config.Producer.Return.Errors = true
+	config.Producer.Flush.Frequency = linger
+	config.Producer.Flush.Messages = batchSize
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+
+	var producer sarama.AsyncProducer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		producer, err = sarama.NewAsyncProducer(brokers, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create async Kafka producer: %w", err)
+	}
+
+	logger.Info("Async Kafka producer initialized",
+		zap.Duration("linger", linger),
+		zap.Int("batch_size", batchSize),
+	)
+	return NewAsyncProducer(producer, db, logger), nil
+}
+
+// NewAsyncProducer wraps producer and starts handling its delivery failures
+func NewAsyncProducer(producer sarama.AsyncProducer, db *sql.DB, logger *zap.Logger) *AsyncProducer {
+	p := &AsyncProducer{
+		producer: producer,
+		db:       db,
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+	go p.handleErrors()
+	return p
+}
+
+// SendMessage queues msg for the next batch. It reports no partition or offset (-1),
+// since the message hasn't been written yet.
+func (p *AsyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.producer.Input() <- msg
+	return -1, -1, nil
+}
+
+// Close flushes the queued messages and waits until every failure has been handled
+func (p *AsyncProducer) Close() error {
+	p.producer.AsyncClose()
+	<-p.done
+	return nil
+}
+
+func (p *AsyncProducer) handleErrors() {
+	defer close(p.done)
+
+	for perr := range p.producer.Errors() {
+		ctx, cancel := context.WithTimeout(context.Background(), outboxWriteTimeout)
+		err := outbox.Save(ctx, p.db, perr.Msg, perr.Err)
+		cancel()
+
+		if err != nil {
+			middleware.RecordPublishFailure("dropped")
+			p.logger.Error("Failed to publish event and to save it to the outbox, event dropped",
+				zap.String("topic", perr.Msg.Topic),
+				zap.NamedError("publish_error", perr.Err),
+				zap.Error(err),
+			)
+			continue
+		}
+		middleware.RecordPublishFailure("outboxed")
+		p.logger.Warn("Failed to publish event, saved to the outbox",
+			zap.String("topic", perr.Msg.Topic),
+			zap.Error(perr.Err),
+		)
+	}
+}
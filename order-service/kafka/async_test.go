@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAsyncProducer_SavesFailedMessagesToOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mockProducer := mocks.NewAsyncProducer(t, nil)
+	mockProducer.ExpectInputAndSucceed()
+	mockProducer.ExpectInputAndFail(errors.New("broker unavailable"))
+
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("orders", sqlmock.AnyArg(), `{"order_id":2}`, `{"traceparent":"00-abc"}`, "broker unavailable").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	producer := NewAsyncProducer(mockProducer, db, zaptest.NewLogger(t))
+
+	for _, payload := range []string{`{"order_id":1}`, `{"order_id":2}`} {
+		partition, offset, err := producer.SendMessage(&sarama.ProducerMessage{
+			Topic:   "orders",
+			Value:   sarama.StringEncoder(payload),
+			Headers: []sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("00-abc")}},
+		})
+		if err != nil {
+			t.Fatalf("Expected the message to be queued, got %v", err)
+		}
+		if partition != -1 || offset != -1 {
+			t.Errorf("Expected no partition or offset for a queued message, got %d/%d", partition, offset)
+		}
+	}
+
+	// Close waits until the failed message has been handled
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Failed to close producer: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -2,11 +2,17 @@ package kafka
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"order-svc/middleware"
 	"order-svc/models"
+	"order-svc/saga"
+	"order-svc/startup"
 
 	"github.com/IBM/sarama"
 	"go.opentelemetry.io/otel"
@@ -16,46 +22,246 @@ import (
 	"go.uber.org/zap"
 )
 
-func InitConsumer(logger *zap.Logger) (sarama.Consumer, error) {
+const (
+	minRejoinBackoff = 1 * time.Second
+	maxRejoinBackoff = 30 * time.Second
+)
+
+// errUnsettledMessage ends a session in which a failed message could be neither retried
+// nor dead-lettered, so the group rejoins after a backoff and claims it again
+var errUnsettledMessage = errors.New("failed message could not be forwarded")
+
+// rejoinAfter is swapped in tests so the rejoin backoff doesn't block
+var rejoinAfter = time.After
+
+// paymentsTopic is where payment-service publishes the payment events this service
+// consumes
+var paymentsTopic = getEnv("KAFKA_PAYMENTS_TOPIC", "payments")
+
+// InitConsumer joins the order consumer group. With replay it joins a fresh, throwaway
+// group instead, which starts from the oldest retained event without moving the live
+// group's committed offsets.
+func InitConsumer(logger *zap.Logger, replay bool) (sarama.ConsumerGroup, error) {
+	groupID := getEnv("KAFKA_CONSUMER_GROUP", "order-service")
+	if replay {
+		groupID = fmt.Sprintf("%s-replay-%d", groupID, time.Now().Unix())
+	}
+	return newConsumerGroup(logger, groupID)
+}
+
+// InitDLQConsumer joins the group that replays dead letters. It is separate from the
+// live group, so replaying never moves the payments offsets.
+func InitDLQConsumer(logger *zap.Logger) (sarama.ConsumerGroup, error) {
+	return newConsumerGroup(logger, getEnv("KAFKA_CONSUMER_GROUP", "order-service")+"-dlq")
+}
+
+func newConsumerGroup(logger *zap.Logger, groupID string) (sarama.ConsumerGroup, error) {
 	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	config.Consumer.Return.Errors = true
+	// Skip payment events from aborted payment-service transactions
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
 
 	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	var consumerGroup sarama.ConsumerGroup
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumerGroup, err = sarama.NewConsumerGroup(brokers, groupID, config)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
+		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
 	}
 
-	logger.Info("Kafka consumer initialized")
-	return consumer, nil
+	logger.Info("Kafka consumer group initialized",
+		zap.Strings("brokers", brokers),
+		zap.String("group_id", groupID),
+	)
+	return consumerGroup, nil
 }
 
-func StartConsumerWithContext(ctx context.Context, consumer sarama.Consumer, db *sql.DB, logger *zap.Logger) error {
-	topic := getEnv("KAFKA_TOPIC", "order_events")
-	partitionConsumer, err := consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
-	if err != nil {
-		return fmt.Errorf("failed to consume partition: %w", err)
+// StartConsumer handles payment events until ctx is cancelled, rejoining the group
+// after rebalances and broker errors. Events that fail are retried through the retry
+// topics, which the same group reads, and then moved to the DLQ topic. A session that
+// couldn't forward a failed event is rejoined after a backoff, like a broker error.
+func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, orderSaga *saga.Saga, producer sarama.SyncProducer, logger *zap.Logger) error {
+	topics := append([]string{paymentsTopic}, retryTopics()...)
+	handler := &orderConsumerGroupHandler{
+		saga:     orderSaga,
+		producer: producer,
+		logger:   logger,
 	}
-	logger.Info("Kafka consumer started", zap.String("topic", topic))
 
-	// Listen for messages and errors, exit if context is cancelled
+	logger.Info("Kafka consumer loop started", zap.Strings("topics", topics))
+
+	// Handle errors in a separate goroutine
+	go func() {
+		for err := range consumerGroup.Errors() {
+			logger.Error("Kafka consumer group error", zap.Error(err))
+		}
+	}()
+
+	backoff := minRejoinBackoff
 	for {
+		err := consumerGroup.Consume(ctx, topics, handler)
+		if ctx.Err() != nil {
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		}
+		if err == nil && handler.unsettled.Swap(false) {
+			err = errUnsettledMessage
+		}
+
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return fmt.Errorf("failed to consume topic: %w", err)
+		}
+
+		if err == nil {
+			// Session ended normally (rebalance); rejoin immediately
+			backoff = minRejoinBackoff
+			continue
+		}
+
+		logger.Warn("Kafka consumer session failed, rejoining group",
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
 		select {
 		case <-ctx.Done():
-			logger.Info("Kafka consumer stopping due to context cancellation")
-			return partitionConsumer.Close()
-		case message := <-partitionConsumer.Messages():
-			if err := handleMessage(message, db, logger); err != nil {
-				logger.Error("Failed to handle message", zap.Error(err))
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		case <-rejoinAfter(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRejoinBackoff {
+			backoff = maxRejoinBackoff
+		}
+	}
+}
+
+type orderConsumerGroupHandler struct {
+	saga     *saga.Saga
+	producer sarama.SyncProducer
+	// replay is set when consuming the DLQ topic; handled, if set, is called after
+	// each message
+	replay  bool
+	handled func()
+	logger  *zap.Logger
+	// unsettled is set when a session stopped at a message it couldn't forward
+	unsettled atomic.Bool
+}
+
+func (h *orderConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.logger.Info("Joined Kafka consumer group",
+		zap.String("member_id", session.MemberID()),
+		zap.Int32("generation_id", session.GenerationID()),
+		zap.Any("partitions", session.Claims()),
+	)
+	return nil
+}
+
+// Cleanup runs once every ConsumeClaim has returned, before the session's partitions are
+// released. Offsets are committed here rather than left to the next auto-commit tick, so
+// the member taking over, or this one after a restart, resumes right after the last
+// settled event.
+func (h *orderConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	h.logger.Info("Committed Kafka offsets, leaving consumer group session",
+		zap.String("member_id", session.MemberID()),
+		zap.Int32("generation_id", session.GenerationID()),
+	)
+	return nil
+}
+
+// ConsumeClaim marks each message once it has been handled, retried or dead-lettered, so
+// a restart resumes after the last settled event instead of skipping to the newest one.
+// When the session ends, on shutdown or a rebalance, it stops claiming messages; the one
+// in flight is finished first, since handling doesn't use the session's context. A retry
+// copy still waiting out its delay is left unmarked, to be claimed again. So is a failed
+// message that couldn't be forwarded, and the claim stops there: marking any later
+// message would commit past it.
+func (h *orderConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		// Checked first, so buffered messages aren't picked over a finished session
+		if session.Context().Err() != nil {
+			return nil
+		}
+
+		select {
+		case <-session.Context().Done():
+			return nil
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !waitUntilDue(session.Context(), message) {
+				return nil
 			}
-		case err := <-partitionConsumer.Errors():
-			logger.Error("Kafka consumer error", zap.Error(err))
+			if !h.consume(session, message) {
+				return nil
+			}
+		}
+	}
+}
+
+// consume handles one message, forwarding it if handling fails, and marks it. It reports
+// false, leaving the message unmarked, when a failed message couldn't be forwarded.
+func (h *orderConsumerGroupHandler) consume(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) bool {
+	err := handleMessage(message, h.saga, h.logger)
+	if h.replay {
+		middleware.RecordDeadLetterReplay(err == nil)
+	}
+	if err != nil {
+		if fwdErr := h.forward(message, err); fwdErr != nil {
+			h.logger.Error("Failed to forward failed message, stopping the partition until the group rejoins",
+				zap.String("topic", message.Topic),
+				zap.Int32("partition", message.Partition),
+				zap.Int64("offset", message.Offset),
+				zap.Error(fwdErr),
+			)
+			h.unsettled.Store(true)
+			return false
+		}
+	}
+	session.MarkMessage(message, "")
+	if h.handled != nil {
+		h.handled()
+	}
+	return true
+}
+
+// forward moves a message that failed handling to the next retry tier if the failure
+// may be transient, and to the DLQ otherwise. dlq-replay sends failures straight back
+// to the DLQ.
+func (h *orderConsumerGroupHandler) forward(message *sarama.ConsumerMessage, cause error) error {
+	if !h.replay && retryable(cause) {
+		if tier, ok := nextRetryTier(message.Topic); ok {
+			h.logger.Warn("Failed to handle message, scheduling a retry",
+				zap.String("topic", message.Topic),
+				zap.Int32("partition", message.Partition),
+				zap.Int64("offset", message.Offset),
+				zap.String("retry_topic", tier.topic),
+				zap.Duration("delay", tier.delay),
+				zap.Error(cause),
+			)
+			return scheduleRetry(h.producer, message, tier, cause)
 		}
 	}
+
+	h.logger.Error("Failed to handle message, moving it to the DLQ",
+		zap.String("topic", message.Topic),
+		zap.Int32("partition", message.Partition),
+		zap.Int64("offset", message.Offset),
+		zap.Error(cause),
+	)
+	return deadLetter(h.producer, message, cause)
 }
 
-func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, logger *zap.Logger) error {
+func handleMessage(message *sarama.ConsumerMessage, orderSaga *saga.Saga, logger *zap.Logger) error {
 	// Extract trace context from Kafka message headers
 	var propagator propagation.TextMapPropagator = otel.GetTextMapPropagator()
 	carrier := saramaHeaderCarrierConsumer(message.Headers)
@@ -71,52 +277,108 @@ func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, logger *zap.Logg
 		traceID = span.SpanContext().TraceID().String()
 	}
 
-	var event models.OrderEvent
-	if err := json.Unmarshal(message.Value, &event); err != nil {
+	header, err := readEventHeader(message.Value)
+	if err != nil {
 		span.RecordError(err)
-		return fmt.Errorf("failed to unmarshal event: %w", err)
+		return err
 	}
 
 	span.SetAttributes(
-		attribute.String("event.type", event.EventType),
-		attribute.Int("order.id", event.OrderID),
+		attribute.String("event.type", header.EventType),
+		attribute.Int("event.version", header.EventVersion),
+		attribute.Int("order.id", header.OrderID),
 	)
 
 	logger.Info("Received event",
 		zap.String("trace_id", traceID),
-		zap.String("event_type", event.EventType),
-		zap.Int("order_id", event.OrderID),
+		zap.String("event_type", header.EventType),
+		zap.Int("event_version", header.EventVersion),
+		zap.Int("order_id", header.OrderID),
 	)
 
 	// Handle different event types for Saga pattern
-	switch event.EventType {
-	case "order_failed", "payment_failed":
-		// Rollback order status
-		_, err := db.ExecContext(ctx,
-			"UPDATE orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
-			models.OrderStatusFailed, event.OrderID,
-		)
-		if err != nil {
-			span.RecordError(err)
-			return fmt.Errorf("failed to update order status: %w", err)
-		}
-		logger.Info("Order status updated to failed", zap.String("trace_id", traceID), zap.Int("order_id", event.OrderID))
+	var settle func(ctx context.Context, orderID int, sourceEvent, traceID string) error
+	switch header.EventType {
+	case "order_failed", "payment_failed", "payment_timeout":
+		// A payment that timed out is left for review; the order doesn't wait for it
+		settle = orderSaga.Fail
 	case "order_paid", "payment_success":
-		// Update order status to paid
-		_, err := db.ExecContext(ctx,
-			"UPDATE orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
-			models.OrderStatusPaid, event.OrderID,
+		settle = orderSaga.Pay
+	case "refund_completed":
+		settle = orderSaga.Refund
+	case "refund_failed":
+		settle = orderSaga.RefundFailed
+	default:
+		return nil
+	}
+
+	// A newer version is dead-lettered, to be replayed once this service reads it
+	if err := header.checkVersion(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	var event models.OrderEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("%w: %w", errMalformedEvent, err)
+	}
+
+	eventID := eventKey(message, event)
+	span.SetAttributes(attribute.String("event.id", eventID))
+	processed, err := orderSaga.Processed(ctx, eventID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to check processed events: %w", err)
+	}
+	if processed {
+		middleware.RecordDuplicateEvent(event.EventType)
+		logger.Info("Skipping already processed event",
+			zap.String("trace_id", traceID),
+			zap.String("event_id", eventID),
+			zap.Int("order_id", event.OrderID),
+		)
+		return nil
+	}
+
+	if err := settle(ctx, event.OrderID, event.EventType, traceID); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if err := orderSaga.MarkProcessed(ctx, eventID, event.EventType, event.OrderID); err != nil {
+		// Settling is idempotent, so a redelivery only repeats it
+		logger.Warn("Failed to record processed event",
+			zap.String("trace_id", traceID),
+			zap.String("event_id", eventID),
+			zap.Error(err),
 		)
-		if err != nil {
-			span.RecordError(err)
-			return fmt.Errorf("failed to update order status: %w", err)
-		}
-		logger.Info("Order status updated to paid", zap.String("trace_id", traceID), zap.Int("order_id", event.OrderID))
 	}
 
 	return nil
 }
 
+// eventKey identifies an event in the inbox. Events without an ID are keyed by where
+// they were first published; a dead-lettered copy carries that position in its headers,
+// so a replay matches the original delivery.
+func eventKey(message *sarama.ConsumerMessage, event models.OrderEvent) string {
+	if event.EventID != "" {
+		return event.EventID
+	}
+
+	topic, partition, offset := message.Topic, strconv.Itoa(int(message.Partition)), strconv.FormatInt(message.Offset, 10)
+	for _, h := range message.Headers {
+		switch string(h.Key) {
+		case headerDLQSourceTopic:
+			topic = string(h.Value)
+		case headerDLQPartition:
+			partition = string(h.Value)
+		case headerDLQOffset:
+			offset = string(h.Value)
+		}
+	}
+	return fmt.Sprintf("%s/%s/%s", topic, partition, offset)
+}
+
 // saramaHeaderCarrierConsumer implements the TextMapCarrier interface for Kafka headers (for consumer)
 type saramaHeaderCarrierConsumer []*sarama.RecordHeader
 
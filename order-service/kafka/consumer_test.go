@@ -0,0 +1,405 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-svc/models"
+	"order-svc/saga"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEventKey(t *testing.T) {
+	message := &sarama.ConsumerMessage{Topic: "payments", Partition: 1, Offset: 10}
+
+	if key := eventKey(message, models.OrderEvent{EventID: "payment-7"}); key != "payment-7" {
+		t.Errorf("Expected the event ID, got %q", key)
+	}
+	if key := eventKey(message, models.OrderEvent{}); key != "payments/1/10" {
+		t.Errorf("Expected the Kafka position, got %q", key)
+	}
+
+	// A dead-lettered copy is keyed by its original position
+	dlqMessage := &sarama.ConsumerMessage{
+		Topic:     "order_events_dlq",
+		Partition: 0,
+		Offset:    3,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(headerDLQSourceTopic), Value: []byte("payments")},
+			{Key: []byte(headerDLQPartition), Value: []byte("1")},
+			{Key: []byte(headerDLQOffset), Value: []byte("10")},
+		},
+	}
+	if key := eventKey(dlqMessage, models.OrderEvent{}); key != "payments/1/10" {
+		t.Errorf("Expected the source position, got %q", key)
+	}
+}
+
+func TestHandleMessage_SkipsProcessedEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Already in the inbox, so the order isn't touched and no stock call is made
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM processed_events WHERE event_id = \\$1\\)").
+		WithArgs("payment-7").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	logger := zaptest.NewLogger(t)
+	message := &sarama.ConsumerMessage{
+		Topic: "payments",
+		Value: []byte(`{"event_id":"payment-7","event_type":"payment_failed","order_id":1}`),
+	}
+	if err := handleMessage(message, saga.New(db, nil, nil, nil, logger), logger); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestHandleMessage_PaymentTimeoutFailsOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM processed_events WHERE event_id = \\$1\\)").
+		WithArgs("timeout-7").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	// Without a reservation, so no stock call is made
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, nil))
+	mock.ExpectExec("UPDATE orders SET status = \\$1").
+		WithArgs(models.OrderStatusFailed, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO order_status_history").
+		WithArgs(1, models.OrderStatusPending, models.OrderStatusFailed, "payment_timeout", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO processed_events").
+		WithArgs("timeout-7", "payment_timeout", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Cache misses and publish failures are only logged
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1})
+	defer redisClient.Close()
+
+	logger := zaptest.NewLogger(t)
+	message := &sarama.ConsumerMessage{
+		Topic: "payments",
+		Value: []byte(`{"event_id":"timeout-7","event_type":"payment_timeout","order_id":1,"failure_reason":"payment processing timed out"}`),
+	}
+	if err := handleMessage(message, saga.New(db, redisClient, nil, nil, logger), logger); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestHandleMessage_PaymentFailedRequestsStockRelease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	expectFailure := func() {
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM processed_events WHERE event_id = \\$1\\)").
+			WithArgs("failed-7").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, "res-1"))
+		mock.ExpectExec("UPDATE orders SET status = \\$1").
+			WithArgs(models.OrderStatusFailed, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO order_status_history").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+		mock.ExpectExec("INSERT INTO webhook_deliveries").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE order_sagas").
+			WithArgs(models.SagaStepReleaseStock, sqlmock.AnyArg(), "res-1", models.SagaStepChargePayment).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1})
+	defer redisClient.Close()
+
+	logger := zaptest.NewLogger(t)
+	message := &sarama.ConsumerMessage{
+		Topic: "payments",
+		Value: []byte(`{"event_id":"failed-7","event_type":"payment_failed","order_id":1}`),
+	}
+
+	// Without the event the failure isn't recorded as processed, so it is redelivered
+	expectFailure()
+	unpublished := func(context.Context, models.OrderEvent) error { return errors.New("broker down") }
+	if err := handleMessage(message, saga.New(db, redisClient, nil, unpublished, logger), logger); err == nil {
+		t.Fatal("Expected the unpublished stock_release to fail the message")
+	}
+
+	var published []models.OrderEvent
+	publish := func(_ context.Context, event models.OrderEvent) error {
+		published = append(published, event)
+		return nil
+	}
+	expectFailure()
+	mock.ExpectExec("INSERT INTO processed_events").
+		WithArgs("failed-7", "payment_failed", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := handleMessage(message, saga.New(db, redisClient, nil, publish, logger), logger); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(published) != 1 {
+		t.Fatalf("Expected one stock_release event, got %d", len(published))
+	}
+	if event := published[0]; event.EventType != "stock_release" || event.EventID != "stock-release-1" || event.ReservationID != "res-1" {
+		t.Errorf("Expected stock_release for res-1, got %+v", event)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestHandleMessage_EventVersions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	logger := zaptest.NewLogger(t)
+	orderSaga := saga.New(db, nil, nil, nil, logger)
+
+	// A newer version of an event the saga handles is refused before anything is read
+	// from it, so it is dead-lettered instead of misapplied
+	message := &sarama.ConsumerMessage{
+		Topic: "payments",
+		Value: []byte(`{"event_id":"payment-7","event_version":2,"event_type":"payment_failed","order_id":1}`),
+	}
+	if err := handleMessage(message, orderSaga, logger); !errors.Is(err, errUnsupportedEventVersion) {
+		t.Errorf("Expected errUnsupportedEventVersion, got %v", err)
+	}
+
+	// Events the saga ignores are skipped whatever their version
+	message.Value = []byte(`{"event_id":"e-1","event_version":2,"event_type":"order_created","order_id":1}`)
+	if err := handleMessage(message, orderSaga, logger); err != nil {
+		t.Errorf("Expected an ignored event to be skipped, got %v", err)
+	}
+
+	// Unversioned events are version 1, and unknown fields are ignored
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM processed_events WHERE event_id = \\$1\\)").
+		WithArgs("payment-8").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	message.Value = []byte(`{"event_id":"payment-8","event_type":"payment_failed","order_id":1,"gateway":{"name":"sim"}}`)
+	if err := handleMessage(message, orderSaga, logger); err != nil {
+		t.Errorf("Expected an unversioned event to be read, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// fakeSession records the messages marked and whether offsets were committed
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx       context.Context
+	marked    []int64
+	committed bool
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+func (s *fakeSession) Commit()             { s.committed = true }
+func (s *fakeSession) MemberID() string    { return "member-1" }
+func (s *fakeSession) GenerationID() int32 { return 1 }
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestConsumeClaim_StopsClaimingWhenSessionEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := &fakeSession{ctx: ctx}
+
+	claim := fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for offset := int64(0); offset < 3; offset++ {
+		// Ignored event types are settled without touching the saga
+		claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: offset, Value: []byte(`{"event_type":"order_created","order_id":1}`)}
+	}
+
+	// The session ends while the first message is being handled
+	handler := &orderConsumerGroupHandler{logger: zaptest.NewLogger(t), handled: cancel}
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(session.marked) != 1 || session.marked[0] != 0 {
+		t.Errorf("Expected only the in-flight message to be marked, got offsets %v", session.marked)
+	}
+	if len(claim.messages) != 2 {
+		t.Errorf("Expected 2 messages left unclaimed, got %d", len(claim.messages))
+	}
+
+	if err := handler.Cleanup(session); err != nil || !session.committed {
+		t.Errorf("Expected Cleanup to commit offsets, got committed=%v err=%v", session.committed, err)
+	}
+}
+
+// fakeConsumerGroup runs one scripted session per Consume call and cancels the consumer
+// once the script is done
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+	sessions []func(handler sarama.ConsumerGroupHandler) error
+	calls    int
+	cancel   context.CancelFunc
+	errors   chan error
+}
+
+func (g *fakeConsumerGroup) Consume(ctx context.Context, _ []string, handler sarama.ConsumerGroupHandler) error {
+	if g.calls >= len(g.sessions) {
+		g.cancel()
+		return nil
+	}
+	session := g.sessions[g.calls]
+	g.calls++
+	return session(handler)
+}
+
+func (g *fakeConsumerGroup) Errors() <-chan error { return g.errors }
+
+// recordRejoinBackoffs makes the rejoin backoff return at once and records each delay
+func recordRejoinBackoffs(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var backoffs []time.Duration
+	rejoinAfter = func(d time.Duration) <-chan time.Time {
+		backoffs = append(backoffs, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { rejoinAfter = time.After })
+	return &backoffs
+}
+
+func TestStartConsumer_RejoinsWithBackoff(t *testing.T) {
+	backoffs := recordRejoinBackoffs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	brokerDown := func(sarama.ConsumerGroupHandler) error { return errors.New("broker unavailable") }
+	rebalance := func(sarama.ConsumerGroupHandler) error { return nil }
+	unforwarded := func(handler sarama.ConsumerGroupHandler) error {
+		handler.(*orderConsumerGroupHandler).unsettled.Store(true)
+		return nil
+	}
+
+	var sessions []func(sarama.ConsumerGroupHandler) error
+	// Failures double the backoff up to the cap; a clean rebalance resets it, and a
+	// session stopped at an unforwarded message backs off like a failure
+	for i := 0; i < 7; i++ {
+		sessions = append(sessions, brokerDown)
+	}
+	sessions = append(sessions, rebalance, unforwarded, brokerDown)
+
+	errs := make(chan error)
+	defer close(errs)
+	group := &fakeConsumerGroup{sessions: sessions, cancel: cancel, errors: errs}
+
+	if err := StartConsumer(ctx, group, nil, nil, zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected a clean stop on cancellation, got %v", err)
+	}
+
+	want := []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second,
+		time.Second, 2 * time.Second,
+	}
+	if len(*backoffs) != len(want) {
+		t.Fatalf("Expected backoffs %v, got %v", want, *backoffs)
+	}
+	for i := range want {
+		if (*backoffs)[i] != want[i] {
+			t.Errorf("Expected backoffs %v, got %v", want, *backoffs)
+			break
+		}
+	}
+	if group.calls != len(sessions) {
+		t.Errorf("Expected %d sessions, got %d", len(sessions), group.calls)
+	}
+}
+
+func TestStartConsumer_StopsWhenGroupClosed(t *testing.T) {
+	recordRejoinBackoffs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error)
+	defer close(errs)
+	group := &fakeConsumerGroup{
+		sessions: []func(sarama.ConsumerGroupHandler) error{
+			func(sarama.ConsumerGroupHandler) error { return sarama.ErrClosedConsumerGroup },
+		},
+		cancel: cancel,
+		errors: errs,
+	}
+
+	if err := StartConsumer(ctx, group, nil, nil, zaptest.NewLogger(t)); !errors.Is(err, sarama.ErrClosedConsumerGroup) {
+		t.Errorf("Expected ErrClosedConsumerGroup, got %v", err)
+	}
+}
+
+func TestConsumeClaim_StopsAtUnforwardedMessage(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	// The DLQ can't be written to, so the malformed event can't be settled
+	producer.ExpectSendMessageAndFail(errors.New("kafka unavailable"))
+
+	session := &fakeSession{ctx: context.Background()}
+	claim := fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: 0, Value: []byte(`{"event_type":"order_created","order_id":1}`)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: 1, Value: []byte(`{`)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: 2, Value: []byte(`{"event_type":"order_created","order_id":2}`)}
+
+	handler := &orderConsumerGroupHandler{producer: producer, logger: zaptest.NewLogger(t)}
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Marking offset 2 would commit past the unforwarded message at offset 1
+	if len(session.marked) != 1 || session.marked[0] != 0 {
+		t.Errorf("Expected only offset 0 marked, got %v", session.marked)
+	}
+	if len(claim.messages) != 1 {
+		t.Errorf("Expected the message after the unforwarded one left unclaimed, got %d left", len(claim.messages))
+	}
+	if !handler.unsettled.Load() {
+		t.Error("Expected the handler to report the unsettled message")
+	}
+}
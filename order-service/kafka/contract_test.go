@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"order-svc/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+// eventContract declares the fields of an event a consumer relies on. Consumers keep
+// their contracts in contracts/order_events; this side verifies them.
+type eventContract struct {
+	Provider  string            `json:"provider"`
+	Consumer  string            `json:"consumer"`
+	EventType string            `json:"event_type"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// providedEvents are the events order-service publishes, as built by the handlers
+var providedEvents = map[string]models.OrderEvent{
+	"order_created": models.OrderEvent{
+		OrderID:         1,
+		UserID:          2,
+		ProductID:       3,
+		Quantity:        4,
+		Status:          models.OrderStatusPending,
+		TotalPrice:      99.99,
+		Region:          "eu-west",
+		Metadata:        map[string]string{"cart_id": "cart-81"},
+		PaymentMethodID: 6,
+		Currency:        "EUR",
+		MerchantID:      "acme",
+		EventType:       "order_created",
+	},
+	"refund_requested": models.OrderEvent{
+		EventID:    "refund-5",
+		OrderID:    1,
+		UserID:     2,
+		ProductID:  3,
+		Quantity:   4,
+		Status:     models.OrderStatusRefundPending,
+		TotalPrice: 99.99,
+		Region:     "eu-west",
+		EventType:  "refund_requested",
+	},
+}
+
+func TestContracts_OrderEvents(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "contracts", "order_events", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list contracts: %v", err)
+	}
+
+	verified := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+
+		var contract eventContract
+		if err := json.Unmarshal(data, &contract); err != nil {
+			t.Fatalf("Failed to parse %s: %v", path, err)
+		}
+		if contract.Provider != "order-service" {
+			continue
+		}
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			event, ok := providedEvents[contract.EventType]
+			if !ok {
+				t.Fatalf("%s expects %s events, which order-service doesn't publish", contract.Consumer, contract.EventType)
+			}
+
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				t.Fatalf("Failed to unmarshal event: %v", err)
+			}
+
+			for field, kind := range contract.Fields {
+				value, ok := fields[field]
+				if !ok {
+					t.Errorf("%s relies on %q, which the %s event no longer has", contract.Consumer, field, contract.EventType)
+					continue
+				}
+				if got := jsonType(value); got != kind {
+					t.Errorf("%s expects %q to be a %s, got %s", contract.Consumer, field, kind, got)
+				}
+			}
+		})
+		verified++
+	}
+
+	if verified == 0 {
+		t.Fatal("No contracts found for order-service")
+	}
+}
+
+// eventSchema is the order_events schema shared by every service
+type eventSchema struct {
+	// Topics maps each topic to the service publishing on it
+	Topics         map[string]string `json:"topics"`
+	CurrentVersion int               `json:"current_version"`
+	Envelope       map[string]string `json:"envelope"`
+	Events         map[string]string `json:"events"`
+}
+
+func TestSchema_OrderEvents(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "contracts", "schemas", "order_events.json"))
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var schema eventSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	if models.EventVersion != schema.CurrentVersion {
+		t.Errorf("order-service publishes version %d, the schema is at %d", models.EventVersion, schema.CurrentVersion)
+	}
+	if supportedEventVersion < schema.CurrentVersion {
+		t.Errorf("order-service reads up to version %d, the schema is at %d", supportedEventVersion, schema.CurrentVersion)
+	}
+	if publisher := schema.Topics[OrdersTopic]; publisher != "order-service" {
+		t.Errorf("order-service publishes on %s, which the schema lists as %q's", OrdersTopic, publisher)
+	}
+	if publisher := schema.Topics[paymentsTopic]; publisher != "payment-service" {
+		t.Errorf("order-service consumes %s, which the schema lists as %q's", paymentsTopic, publisher)
+	}
+
+	for eventType, event := range providedEvents {
+		if provider := schema.Events[eventType]; provider != "order-service" {
+			t.Errorf("The schema lists %s as published by %q", eventType, provider)
+		}
+
+		// The envelope is stamped on publish, so check what reaches Kafka
+		var payload []byte
+		producer := mocks.NewSyncProducer(t, nil)
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			payload, err = msg.Value.Encode()
+			return err
+		})
+		if err := PublishOrderEvent(context.Background(), producer, OrdersTopic, event, zaptest.NewLogger(t)); err != nil {
+			t.Fatalf("Failed to publish %s: %v", eventType, err)
+		}
+		producer.Close()
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %v", eventType, err)
+		}
+		for field, kind := range schema.Envelope {
+			if got := jsonType(fields[field]); got != kind {
+				t.Errorf("%s: envelope field %q should be a %s, got %s", eventType, field, kind, got)
+			}
+		}
+		if version := fields["event_version"]; version != float64(schema.CurrentVersion) {
+			t.Errorf("%s: expected event_version %d, got %v", eventType, schema.CurrentVersion, version)
+		}
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/saga"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Headers added to dead-lettered messages. The original headers, including the trace
+// context, are kept alongside them.
+const (
+	headerDLQError       = "x-dlq-error"
+	headerDLQSourceTopic = "x-dlq-source-topic"
+	headerDLQPartition   = "x-dlq-partition"
+	headerDLQOffset      = "x-dlq-offset"
+	headerDLQFailedAt    = "x-dlq-failed-at"
+	headerDLQAttempts    = "x-dlq-attempts"
+)
+
+var dlqTopic = getEnv("KAFKA_DLQ_TOPIC", "order_events_dlq")
+
+// deadLetter parks a message that failed handling on the DLQ topic with the failure
+// attached. A message that fails again on replay, or on its last retry tier, keeps its
+// original source position; a replayed one has its attempt count bumped.
+func deadLetter(producer sarama.SyncProducer, message *sarama.ConsumerMessage, cause error) error {
+	attempts := 1
+	if n, err := strconv.Atoi(saramaHeaderCarrierConsumer(message.Headers).Get(headerDLQAttempts)); err == nil {
+		attempts = n + 1
+	}
+
+	headers := append(forwardedHeaders(message),
+		sarama.RecordHeader{Key: []byte(headerDLQError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(headerDLQFailedAt), Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		sarama.RecordHeader{Key: []byte(headerDLQAttempts), Value: []byte(strconv.Itoa(attempts))},
+	)
+
+	_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   dlqTopic,
+		Key:     sarama.ByteEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		middleware.RecordDeadLetter("publish_failed")
+		return fmt.Errorf("failed to publish to %s: %w", dlqTopic, err)
+	}
+	middleware.RecordDeadLetter("published")
+	return nil
+}
+
+// forwardedHeaders returns the headers for a retry or DLQ copy of message: its own
+// headers, including the trace context, followed by where it was originally read. The
+// failure headers a retry or an earlier DLQ copy carried are dropped, for the caller to
+// set afresh.
+func forwardedHeaders(message *sarama.ConsumerMessage) []sarama.RecordHeader {
+	source := map[string]string{
+		headerDLQSourceTopic: message.Topic,
+		headerDLQPartition:   strconv.Itoa(int(message.Partition)),
+		headerDLQOffset:      strconv.FormatInt(message.Offset, 10),
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+6)
+	for _, h := range message.Headers {
+		switch key := string(h.Key); key {
+		case headerDLQSourceTopic, headerDLQPartition, headerDLQOffset:
+			source[key] = string(h.Value)
+		case headerDLQError, headerDLQFailedAt, headerDLQAttempts, headerRetryError, headerRetryNotBefore:
+		default:
+			headers = append(headers, *h)
+		}
+	}
+	for _, key := range []string{headerDLQSourceTopic, headerDLQPartition, headerDLQOffset} {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(source[key])})
+	}
+	return headers
+}
+
+// ReplayDeadLetters handles the messages parked on the DLQ topic again, in its own
+// consumer group so each one is replayed once. Messages that still fail go back to the
+// DLQ. It returns once no message has arrived for idle, or when ctx is cancelled.
+func ReplayDeadLetters(ctx context.Context, consumerGroup sarama.ConsumerGroup, orderSaga *saga.Saga, producer sarama.SyncProducer, idle time.Duration, logger *zap.Logger) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	activity := make(chan struct{}, 1)
+	handler := &orderConsumerGroupHandler{
+		saga:     orderSaga,
+		producer: producer,
+		replay:   true,
+		handled: func() {
+			select {
+			case activity <- struct{}{}:
+			default:
+			}
+		},
+		logger: logger,
+	}
+
+	go func() {
+		for err := range consumerGroup.Errors() {
+			logger.Error("Kafka DLQ consumer group error", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		timer := time.NewTimer(idle)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-activity:
+				timer.Reset(idle)
+			case <-timer.C:
+				logger.Info("No dead letters left to replay", zap.Duration("idle", idle))
+				cancel()
+				return
+			}
+		}
+	}()
+
+	logger.Info("Replaying dead letters", zap.String("topic", dlqTopic))
+	for ctx.Err() == nil {
+		if err := consumerGroup.Consume(ctx, []string{dlqTopic}, handler); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to consume %s: %w", dlqTopic, err)
+		}
+	}
+	return nil
+}
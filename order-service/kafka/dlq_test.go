@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+func headerValue(headers []sarama.RecordHeader, key string) string {
+	for _, h := range headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestDeadLetter_AddsFailureHeaders(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	var sent []*sarama.ProducerMessage
+	capture := func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+
+	message := &sarama.ConsumerMessage{
+		Topic:     "payments",
+		Partition: 2,
+		Offset:    41,
+		Value:     []byte(`{"event_type":"payment_failed","order_id":7}`),
+		Headers:   []*sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("00-abc-def-01")}},
+	}
+	if err := deadLetter(producer, message, errors.New("database unavailable")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	first := sent[0]
+	if first.Topic != dlqTopic {
+		t.Errorf("Expected topic %s, got %s", dlqTopic, first.Topic)
+	}
+	for key, want := range map[string]string{
+		"traceparent":        "00-abc-def-01",
+		headerDLQSourceTopic: "payments",
+		headerDLQPartition:   "2",
+		headerDLQOffset:      "41",
+		headerDLQError:       "database unavailable",
+		headerDLQAttempts:    "1",
+	} {
+		if got := headerValue(first.Headers, key); got != want {
+			t.Errorf("Expected header %s=%q, got %q", key, want, got)
+		}
+	}
+
+	// Failing again on replay keeps the original position and bumps the attempt count
+	replayed := &sarama.ConsumerMessage{Topic: dlqTopic, Partition: 0, Offset: 3, Value: message.Value}
+	for _, h := range first.Headers {
+		h := h
+		replayed.Headers = append(replayed.Headers, &h)
+	}
+	if err := deadLetter(producer, replayed, errors.New("still unavailable")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second := sent[1]
+	if got := headerValue(second.Headers, headerDLQOffset); got != "41" {
+		t.Errorf("Expected original offset 41, got %q", got)
+	}
+	if got := headerValue(second.Headers, headerDLQAttempts); got != "2" {
+		t.Errorf("Expected 2 attempts, got %q", got)
+	}
+	if got := headerValue(second.Headers, headerDLQError); got != "still unavailable" {
+		t.Errorf("Expected latest error, got %q", got)
+	}
+}
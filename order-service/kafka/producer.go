@@ -2,15 +2,25 @@ package kafka
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strconv"
+
+	"order-svc/models"
+	"order-svc/saga"
+	"order-svc/startup"
 
 	"github.com/IBM/sarama"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// OrdersTopic is where this service publishes its order events
+var OrdersTopic = getEnv("KAFKA_ORDERS_TOPIC", "orders")
+
 func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
@@ -19,7 +29,11 @@ func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 
 	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	var producer sarama.SyncProducer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		producer, err = sarama.NewSyncProducer(brokers, config)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
 	}
@@ -28,7 +42,33 @@ func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 	return producer, nil
 }
 
-func PublishOrderEvent(ctx context.Context, producer sarama.SyncProducer, topic string, event any, logger *zap.Logger) error {
+// Producer publishes order events. A sarama.SyncProducer returns once the brokers have
+// acknowledged each message; an AsyncProducer queues it for the next batch instead.
+type Producer interface {
+	SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error)
+	Close() error
+}
+
+// InitEventProducer returns the producer for order events. KAFKA_PRODUCER_MODE=async
+// selects a batching AsyncProducer; otherwise events go through sync, which the caller
+// still owns.
+func InitEventProducer(db *sql.DB, sync sarama.SyncProducer, logger *zap.Logger) (Producer, error) {
+	if getEnv("KAFKA_PRODUCER_MODE", "sync") != "async" {
+		return sync, nil
+	}
+	return InitAsyncProducer(db, logger)
+}
+
+// PublishOrderEvent publishes event keyed by its order ID, so every event of one order
+// lands on the same partition and is consumed in the order it was published. It stamps
+// the current schema version and gives events without an ID a random one; events that
+// need the same ID when retried, like refund_requested, set their own.
+func PublishOrderEvent(ctx context.Context, producer Producer, topic string, event models.OrderEvent, logger *zap.Logger) error {
+	event.EventVersion = models.EventVersion
+	if event.EventID == "" {
+		event.EventID = uuid.NewString()
+	}
+
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
@@ -36,6 +76,7 @@ func PublishOrderEvent(ctx context.Context, producer sarama.SyncProducer, topic
 
 	msg := &sarama.ProducerMessage{
 		Topic:   topic,
+		Key:     sarama.StringEncoder(strconv.Itoa(event.OrderID)),
 		Value:   sarama.StringEncoder(eventJSON),
 		Headers: []sarama.RecordHeader{},
 	}
@@ -58,9 +99,15 @@ func PublishOrderEvent(ctx context.Context, producer sarama.SyncProducer, topic
 		traceID = span.SpanContext().TraceID().String()
 	}
 
+	if offset < 0 {
+		// Queued by the async producer; delivery failures go to the outbox
+		logger.Info("Event queued", zap.String("trace_id", traceID), zap.String("topic", topic), zap.String("event_id", event.EventID))
+		return nil
+	}
 	logger.Info("Event published",
 		zap.String("trace_id", traceID),
 		zap.String("topic", topic),
+		zap.String("event_id", event.EventID),
 		zap.Int32("partition", partition),
 		zap.Int64("offset", offset),
 	)
@@ -68,6 +115,14 @@ func PublishOrderEvent(ctx context.Context, producer sarama.SyncProducer, topic
 	return nil
 }
 
+// SagaPublisher publishes the saga's events, like stock_release, on OrdersTopic through
+// producer
+func SagaPublisher(producer Producer, logger *zap.Logger) saga.Publisher {
+	return func(ctx context.Context, event models.OrderEvent) error {
+		return PublishOrderEvent(ctx, producer, OrdersTopic, event, logger)
+	}
+}
+
 // saramaHeaderCarrier implements the TextMapCarrier interface for Kafka headers (for producer)
 type saramaHeaderCarrier []sarama.RecordHeader
 
@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-svc/middleware"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers added to retry copies, alongside the source position headers a DLQ copy gets
+const (
+	headerRetryError     = "x-retry-error"
+	headerRetryNotBefore = "x-retry-not-before"
+)
+
+// retryTier is a retry topic and how long a message waits on it before it is handled
+// again
+type retryTier struct {
+	topic string
+	delay time.Duration
+}
+
+// retryTiers are tried in order. An event that fails handling with an error that may be
+// transient, such as the database being down, moves to the next tier instead of
+// blocking its partition; one that fails on the last tier is dead-lettered.
+var retryTiers = []retryTier{
+	{topic: "order_events_retry_1m", delay: time.Minute},
+	{topic: "order_events_retry_10m", delay: 10 * time.Minute},
+}
+
+// retryTopics returns the topics the order consumer reads retry copies from
+func retryTopics() []string {
+	topics := make([]string, len(retryTiers))
+	for i, tier := range retryTiers {
+		topics[i] = tier.topic
+	}
+	return topics
+}
+
+// nextRetryTier returns the tier a message that failed on topic moves to, or false once
+// the last tier has failed too
+func nextRetryTier(topic string) (retryTier, bool) {
+	for i, tier := range retryTiers {
+		if tier.topic == topic {
+			if i+1 < len(retryTiers) {
+				return retryTiers[i+1], true
+			}
+			return retryTier{}, false
+		}
+	}
+	return retryTiers[0], true
+}
+
+// retryable reports whether handling may succeed later. Malformed events and events
+// from a newer schema version fail the same way every time, so they go straight to
+// the DLQ.
+func retryable(err error) bool {
+	return !errors.Is(err, errMalformedEvent) && !errors.Is(err, errUnsupportedEventVersion)
+}
+
+// scheduleRetry publishes a copy of message to tier's topic, due once the tier's delay
+// has passed. Like a DLQ copy, it keeps the original headers and source position, so
+// the inbox recognises the event however many times it is retried.
+func scheduleRetry(producer sarama.SyncProducer, message *sarama.ConsumerMessage, tier retryTier, cause error) error {
+	headers := append(forwardedHeaders(message),
+		sarama.RecordHeader{Key: []byte(headerRetryError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(headerRetryNotBefore), Value: []byte(time.Now().Add(tier.delay).UTC().Format(time.RFC3339Nano))},
+	)
+
+	_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   tier.topic,
+		Key:     sarama.ByteEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		middleware.RecordEventRetry(tier.topic, "publish_failed")
+		return fmt.Errorf("failed to publish to %s: %w", tier.topic, err)
+	}
+	middleware.RecordEventRetry(tier.topic, "published")
+	return nil
+}
+
+// waitUntilDue blocks until a retry copy's delay has passed, returning false if ctx
+// ends first. Messages without a due time, on payments or the DLQ, are due now.
+// Every copy on a retry topic waits the same delay, so the ones behind it are due later
+// still and waiting holds nothing up.
+func waitUntilDue(ctx context.Context, message *sarama.ConsumerMessage) bool {
+	notBefore, err := time.Parse(time.RFC3339Nano, saramaHeaderCarrierConsumer(message.Headers).Get(headerRetryNotBefore))
+	if err != nil {
+		return true
+	}
+	wait := time.Until(notBefore)
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-svc/saga"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNextRetryTier(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"payments", "order_events_retry_1m"},
+		{"order_events_retry_1m", "order_events_retry_10m"},
+		{"order_events_retry_10m", ""},
+	}
+	for _, tt := range tests {
+		tier, ok := nextRetryTier(tt.topic)
+		if tier.topic != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: expected next tier %q, got %q (ok=%v)", tt.topic, tt.want, tier.topic, ok)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	if !retryable(fmt.Errorf("failed to update order status: %w", errors.New("connection refused"))) {
+		t.Error("Expected a database failure to be retried")
+	}
+	if retryable(fmt.Errorf("%w: unexpected end of JSON input", errMalformedEvent)) {
+		t.Error("Expected a malformed event not to be retried")
+	}
+	if retryable(fmt.Errorf("%w 2 for payment_failed", errUnsupportedEventVersion)) {
+		t.Error("Expected an unsupported version not to be retried")
+	}
+}
+
+func TestWaitUntilDue(t *testing.T) {
+	due := func(at time.Time) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{
+			{Key: []byte(headerRetryNotBefore), Value: []byte(at.UTC().Format(time.RFC3339Nano))},
+		}}
+	}
+
+	if !waitUntilDue(context.Background(), &sarama.ConsumerMessage{}) {
+		t.Error("Expected a message without a due time to be due")
+	}
+	if !waitUntilDue(context.Background(), due(time.Now().Add(-time.Second))) {
+		t.Error("Expected an overdue retry to be due")
+	}
+
+	start := time.Now()
+	if !waitUntilDue(context.Background(), due(start.Add(50*time.Millisecond))) || time.Since(start) < 50*time.Millisecond {
+		t.Error("Expected to wait until the retry is due")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if waitUntilDue(ctx, due(time.Now().Add(time.Hour))) {
+		t.Error("Expected waiting to stop when the session ends")
+	}
+}
+
+func TestConsume_RetriesTransientFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	var sent []*sarama.ProducerMessage
+	capture := func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	logger := zaptest.NewLogger(t)
+	handler := &orderConsumerGroupHandler{saga: saga.New(db, nil, nil, nil, logger), producer: producer, logger: logger}
+	session := &fakeSession{ctx: context.Background()}
+
+	// The database is down on every attempt, so the event moves through both tiers and
+	// ends up on the DLQ, keeping its original position throughout
+	message := &sarama.ConsumerMessage{
+		Topic:     "payments",
+		Partition: 1,
+		Offset:    10,
+		Value:     []byte(`{"event_id":"payment-7","event_type":"payment_failed","order_id":1}`),
+		Headers:   []*sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("00-abc-def-01")}},
+	}
+	for _, want := range []string{"order_events_retry_1m", "order_events_retry_10m", dlqTopic} {
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM processed_events WHERE event_id = \\$1\\)").
+			WithArgs("payment-7").
+			WillReturnError(errors.New("connection refused"))
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+
+		handler.consume(session, message)
+
+		forwarded := sent[len(sent)-1]
+		if forwarded.Topic != want {
+			t.Fatalf("Expected the event on %s, got %s", want, forwarded.Topic)
+		}
+		if got := headerValue(forwarded.Headers, headerDLQOffset); got != "10" {
+			t.Errorf("%s: expected original offset 10, got %q", want, got)
+		}
+		if got := headerValue(forwarded.Headers, "traceparent"); got != "00-abc-def-01" {
+			t.Errorf("%s: expected the trace context to be kept, got %q", want, got)
+		}
+
+		next := &sarama.ConsumerMessage{Topic: forwarded.Topic, Offset: int64(len(sent)), Value: message.Value}
+		for _, h := range forwarded.Headers {
+			h := h
+			next.Headers = append(next.Headers, &h)
+		}
+		message = next
+	}
+	if got := headerValue(sent[0].Headers, headerRetryNotBefore); got == "" {
+		t.Error("Expected the retry copy to carry its due time")
+	}
+	if got := headerValue(sent[2].Headers, headerRetryNotBefore); got != "" {
+		t.Errorf("Expected the DLQ copy to drop the retry headers, got due time %q", got)
+	}
+
+	// A malformed event can't succeed later, so it skips the retry tiers
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+	handler.consume(session, &sarama.ConsumerMessage{Topic: "payments", Offset: 11, Value: []byte(`{`)})
+	if got := sent[len(sent)-1].Topic; got != dlqTopic {
+		t.Errorf("Expected a malformed event to go straight to %s, got %s", dlqTopic, got)
+	}
+
+	if len(session.marked) != 4 {
+		t.Errorf("Expected every forwarded message to be marked, got offsets %v", session.marked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
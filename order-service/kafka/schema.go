@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// supportedEventVersion is the newest order_events schema version this consumer reads.
+// The schema is shared by every service in contracts/schemas/order_events.json.
+const supportedEventVersion = 1
+
+var (
+	errUnsupportedEventVersion = errors.New("unsupported event version")
+	errMalformedEvent          = errors.New("failed to unmarshal event")
+)
+
+// eventHeader is the envelope every order and payment event carries. Its fields keep
+// their names and types in every version, so it can be read before the version is
+// known.
+type eventHeader struct {
+	EventID      string `json:"event_id"`
+	EventVersion int    `json:"event_version"`
+	EventType    string `json:"event_type"`
+	OrderID      int    `json:"order_id"`
+}
+
+func readEventHeader(value []byte) (eventHeader, error) {
+	var header eventHeader
+	if err := json.Unmarshal(value, &header); err != nil {
+		return header, fmt.Errorf("%w: %w", errMalformedEvent, err)
+	}
+	// Events published before versioning have the version 1 layout
+	if header.EventVersion == 0 {
+		header.EventVersion = 1
+	}
+	return header, nil
+}
+
+// checkVersion refuses an event from a newer version than this consumer reads, rather
+// than misreading it. Decoding ignores fields the consumer doesn't know, so additive
+// changes need no new version.
+func (h eventHeader) checkVersion() error {
+	if h.EventVersion > supportedEventVersion {
+		return fmt.Errorf("%w %d for %s, newest supported is %d",
+			errUnsupportedEventVersion, h.EventVersion, h.EventType, supportedEventVersion)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleAdmin is the JWT "role" claim user-service issues to administrators
+const RoleAdmin = "admin"
+
+// jwtSecret verifies tokens issued by user-service's login, so JWT_SECRET must match
+// the one user-service signs with
+var jwtSecret = []byte(jwtSecretFromEnv())
+
+// devJWTSecret is the key used without JWT_SECRET in DEV_MODE, the one docker-compose sets
+const devJWTSecret = "dev-jwt-secret"
+
+// errNoJWTSecret rejects every token while no key is configured
+var errNoJWTSecret = errors.New("JWT_SECRET is not set; set it, or DEV_MODE=true to use a development key")
+
+func jwtSecretFromEnv() string {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return secret
+	}
+	if DevMode() {
+		return devJWTSecret
+	}
+	return ""
+}
+
+// CheckJWTSecret fails when JWT_SECRET is unset outside DEV_MODE. serve exits on it rather
+// than verify tokens with a key anyone could guess.
+func CheckJWTSecret() error {
+	if len(jwtSecret) == 0 {
+		return errNoJWTSecret
+	}
+	return nil
+}
+
+// AuthMiddleware verifies the bearer token user-service issued at login and sets its
+// user_id, email and role claims on the context. Requests without a valid token get 401.
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware verifies the bearer token like AuthMiddleware when one is sent,
+// and lets requests without an Authorization header through anonymously. Handlers that
+// serve more to some users check the claims themselves.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" && !authenticate(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate verifies the bearer token and sets its claims on the context. On failure
+// it responds 401, aborts and returns false.
+func authenticate(c *gin.Context) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		return false
+	}
+
+	token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		if len(jwtSecret) == 0 {
+			return nil, errNoJWTSecret
+		}
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return false
+	}
+
+	c.Set("user_id", claims["user_id"])
+	c.Set("email", claims["email"])
+	c.Set("role", claims["role"])
+	return true
+}
+
+// RequireRole rejects authenticated users without role with 403. It must run after
+// AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if got, _ := c.Get("role"); got != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
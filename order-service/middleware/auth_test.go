@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestMain gives the tests a key to sign tokens with, as JWT_SECRET would
+func TestMain(m *testing.M) {
+	jwtSecret = []byte("test-jwt-secret")
+	os.Exit(m.Run())
+}
+
+func signedToken(t *testing.T, secret []byte) string {
+	t.Helper()
+	return signedTokenWithRole(t, secret, "customer")
+}
+
+func signedTokenWithRole(t *testing.T, secret []byte, role string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7,
+		"email":   "customer@example.com",
+		"role":    role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", AuthMiddleware(), func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		c.JSON(http.StatusCreated, gin.H{"user_id": userID})
+	})
+
+	for name, tc := range map[string]struct {
+		authorization string
+		want          int
+	}{
+		"no token":     {"", http.StatusUnauthorized},
+		"not bearer":   {"Basic " + signedToken(t, jwtSecret), http.StatusUnauthorized},
+		"wrong secret": {"Bearer " + signedToken(t, []byte("another-secret")), http.StatusUnauthorized},
+		"valid token":  {"Bearer " + signedToken(t, jwtSecret), http.StatusCreated},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/orders", AuthMiddleware(), RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"customer", "Bearer " + signedTokenWithRole(t, jwtSecret, "customer"), http.StatusForbidden},
+		{"admin", "Bearer " + signedTokenWithRole(t, jwtSecret, RoleAdmin), http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/orders", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/orders/1", OptionalAuthMiddleware(), func(c *gin.Context) {
+		_, authenticated := c.Get("user_id")
+		c.JSON(http.StatusOK, gin.H{"authenticated": authenticated})
+	})
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"anonymous", "", http.StatusOK},
+		{"wrong secret", "Bearer " + signedToken(t, []byte("another-secret")), http.StatusUnauthorized},
+		{"valid token", "Bearer " + signedToken(t, jwtSecret), http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
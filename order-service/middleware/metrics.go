@@ -26,11 +26,197 @@ var (
 		},
 		[]string{"method", "endpoint"},
 	)
+
+	orderCacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_cache_requests_total",
+			Help: "Total number of order cache lookups",
+		},
+		[]string{"result"},
+	)
+
+	circuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"breaker"},
+	)
+
+	circuitBreakerOpensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_opens_total",
+			Help: "Total number of times a circuit breaker opened",
+		},
+		[]string{"breaker"},
+	)
+
+	circuitBreakerShortCircuitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_short_circuited_total",
+			Help: "Total number of calls rejected without being attempted because the circuit breaker was open",
+		},
+		[]string{"breaker"},
+	)
+
+	grpcRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_grpc_retries_total",
+			Help: "Total number of product-service gRPC calls retried after a transient error, by method and status code",
+		},
+		[]string{"method", "code"},
+	)
+
+	deadLettersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_dead_lettered_total",
+			Help: "Total number of order events that failed handling and were moved to the DLQ topic, by whether the DLQ publish succeeded",
+		},
+		[]string{"result"},
+	)
+
+	eventRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_retried_total",
+			Help: "Total number of order events that failed handling and were moved to a retry topic, by topic and whether the publish succeeded",
+		},
+		[]string{"topic", "result"},
+	)
+
+	deadLetterReplaysTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_dlq_replayed_total",
+			Help: "Total number of dead-lettered order events handled again by dlq-replay, by outcome",
+		},
+		[]string{"result"},
+	)
+
+	duplicateEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_duplicates_total",
+			Help: "Total number of payment events skipped because they were already processed, by event type",
+		},
+		[]string{"event_type"},
+	)
+
+	webhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts, by result: delivered, retrying or failed",
+		},
+		[]string{"result"},
+	)
+
+	publishFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_publish_failed_total",
+			Help: "Total number of order events the async producer failed to deliver, by whether they were saved to the outbox or dropped",
+		},
+		[]string{"result"},
+	)
+
+	outboxPublishLag = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "outbox_publish_lag_seconds",
+			Help: "Age of the oldest outbox row still waiting to be published, 0 when none is pending",
+		},
+	)
+
+	outboxRows = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outbox_rows",
+			Help: "Number of outbox rows not yet published, by status: pending or dead",
+		},
+		[]string{"status"},
+	)
+
+	outboxRetriesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "outbox_relay_retries_total",
+			Help: "Total number of outbox publishes that failed and were scheduled to be retried",
+		},
+	)
+
+	outboxRelayedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbox_relayed_total",
+			Help: "Total number of outbox rows the relay finished with, by result: published or dead",
+		},
+		[]string{"result"},
+	)
+
+	sagaRecoveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_saga_recoveries_total",
+			Help: "Total number of timed-out saga steps the orchestrator retried, by step and result: advanced or failed",
+		},
+		[]string{"step", "result"},
+	)
+
+	ordersExpiredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "orders_expired_total",
+			Help: "Total number of pending orders cancelled because their payment never arrived",
+		},
+	)
+
+	ordersArchivedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "orders_archived_total",
+			Help: "Total number of settled orders moved to orders_archive by the archiver",
+		},
+	)
+
+	lockAcquisitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_acquisitions_total",
+			Help: "Total number of distributed lock acquisition attempts",
+		},
+		[]string{"lock", "result"},
+	)
+
+	lockHeldDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "distributed_lock_held_seconds",
+			Help:    "How long distributed locks were held in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lock"},
+	)
+
+	lockRenewalFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_renewal_failures_total",
+			Help: "Total number of distributed locks lost because TTL renewal failed",
+		},
+		[]string{"lock"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(orderCacheRequestsTotal)
+	prometheus.MustRegister(circuitBreakerState)
+	prometheus.MustRegister(circuitBreakerOpensTotal)
+	prometheus.MustRegister(circuitBreakerShortCircuitsTotal)
+	prometheus.MustRegister(grpcRetriesTotal)
+	prometheus.MustRegister(deadLettersTotal)
+	prometheus.MustRegister(eventRetriesTotal)
+	prometheus.MustRegister(deadLetterReplaysTotal)
+	prometheus.MustRegister(duplicateEventsTotal)
+	prometheus.MustRegister(ordersExpiredTotal)
+	prometheus.MustRegister(ordersArchivedTotal)
+	prometheus.MustRegister(webhookDeliveriesTotal)
+	prometheus.MustRegister(publishFailuresTotal)
+	prometheus.MustRegister(outboxPublishLag)
+	prometheus.MustRegister(outboxRows)
+	prometheus.MustRegister(outboxRetriesTotal)
+	prometheus.MustRegister(outboxRelayedTotal)
+	prometheus.MustRegister(sagaRecoveriesTotal)
+	prometheus.MustRegister(lockAcquisitionsTotal)
+	prometheus.MustRegister(lockHeldDuration)
+	prometheus.MustRegister(lockRenewalFailuresTotal)
 }
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -54,3 +240,109 @@ func MetricsMiddleware() gin.HandlerFunc {
 func PrometheusHandler() gin.HandlerFunc {
 	return gin.WrapH(promhttp.Handler())
 }
+
+// RecordGRPCRetry counts a product-service call retried after failing with code
+func RecordGRPCRetry(method, code string) {
+	grpcRetriesTotal.WithLabelValues(method, code).Inc()
+}
+
+func RecordDeadLetter(result string) {
+	deadLettersTotal.WithLabelValues(result).Inc()
+}
+
+// RecordEventRetry counts an order event moved to the retry topic for a later attempt
+func RecordEventRetry(topic, result string) {
+	eventRetriesTotal.WithLabelValues(topic, result).Inc()
+}
+
+// RecordDeadLetterReplay counts a DLQ message handled again by the replay command
+func RecordDeadLetterReplay(succeeded bool) {
+	result := "succeeded"
+	if !succeeded {
+		result = "failed"
+	}
+	deadLetterReplaysTotal.WithLabelValues(result).Inc()
+}
+
+// RecordDuplicateEvent counts a redelivered event the consumer skipped
+func RecordDuplicateEvent(eventType string) {
+	duplicateEventsTotal.WithLabelValues(eventType).Inc()
+}
+
+// RecordOrderExpired counts a pending order cancelled by the expiry job
+func RecordOrderExpired() {
+	ordersExpiredTotal.Inc()
+}
+
+// RecordOrdersArchived counts settled orders moved to the archive
+func RecordOrdersArchived(count int) {
+	ordersArchivedTotal.Add(float64(count))
+}
+
+// RecordPublishFailure counts an event the async producer couldn't deliver, by result:
+// outboxed or dropped
+func RecordPublishFailure(result string) {
+	publishFailuresTotal.WithLabelValues(result).Inc()
+}
+
+// SetOutboxBacklog reports the rows waiting in the outbox and how long the oldest
+// pending one has waited
+func SetOutboxBacklog(pending, dead int, lag time.Duration) {
+	outboxRows.WithLabelValues("pending").Set(float64(pending))
+	outboxRows.WithLabelValues("dead").Set(float64(dead))
+	outboxPublishLag.Set(lag.Seconds())
+}
+
+// RecordOutboxRetry counts a failed outbox publish that will be retried
+func RecordOutboxRetry() {
+	outboxRetriesTotal.Inc()
+}
+
+// RecordOutboxRelayed counts an outbox row the relay is done with, by result: published
+// or dead
+func RecordOutboxRelayed(result string) {
+	outboxRelayedTotal.WithLabelValues(result).Inc()
+}
+
+// RecordSagaRecovery counts a timed-out saga step the orchestrator retried, by result:
+// advanced or failed
+func RecordSagaRecovery(step, result string) {
+	sagaRecoveriesTotal.WithLabelValues(step, result).Inc()
+}
+
+// RecordWebhookDelivery counts one webhook delivery attempt by its result
+func RecordWebhookDelivery(result string) {
+	webhookDeliveriesTotal.WithLabelValues(result).Inc()
+}
+
+func RecordCacheHit() {
+	orderCacheRequestsTotal.WithLabelValues("hit").Inc()
+}
+
+func RecordCacheMiss() {
+	orderCacheRequestsTotal.WithLabelValues("miss").Inc()
+}
+
+func SetCircuitBreakerState(breaker string, state int) {
+	circuitBreakerState.WithLabelValues(breaker).Set(float64(state))
+}
+
+func RecordCircuitBreakerOpen(breaker string) {
+	circuitBreakerOpensTotal.WithLabelValues(breaker).Inc()
+}
+
+func RecordCircuitBreakerShortCircuit(breaker string) {
+	circuitBreakerShortCircuitsTotal.WithLabelValues(breaker).Inc()
+}
+
+func RecordLockAcquisition(lock, result string) {
+	lockAcquisitionsTotal.WithLabelValues(lock, result).Inc()
+}
+
+func ObserveLockHeld(lock string, d time.Duration) {
+	lockHeldDuration.WithLabelValues(lock).Observe(d.Seconds())
+}
+
+func RecordLockRenewalFailure(lock string) {
+	lockRenewalFailuresTotal.WithLabelValues(lock).Inc()
+}
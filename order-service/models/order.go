@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
 
 type OrderStatus string
 
@@ -9,31 +12,258 @@ const (
 	OrderStatusPaid      OrderStatus = "paid"
 	OrderStatusFailed    OrderStatus = "failed"
 	OrderStatusCancelled OrderStatus = "cancelled"
+	// OrderStatusRefundPending is a paid order waiting for payment-service to refund it
+	OrderStatusRefundPending OrderStatus = "refund_pending"
+	OrderStatusRefunded      OrderStatus = "refunded"
 )
 
+// orderTransitions lists the statuses an order may move to from each status. Failed,
+// cancelled and refunded orders are final, so a late or out-of-order event can't move
+// them again. A refund that fails returns the order to paid.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:       {OrderStatusPaid, OrderStatusFailed, OrderStatusCancelled},
+	OrderStatusPaid:          {OrderStatusRefundPending},
+	OrderStatusRefundPending: {OrderStatusRefunded, OrderStatusPaid},
+}
+
+// CanTransitionTo reports whether an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether s is a known order status
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusFailed, OrderStatusCancelled,
+		OrderStatusRefundPending, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// NextStatuses lists the statuses an order in status s may move to
+func (s OrderStatus) NextStatuses() []OrderStatus {
+	return append([]OrderStatus{}, orderTransitions[s]...)
+}
+
+// Settled reports whether the order isn't waiting on payment-service, either for the
+// payment or for a refund
+func (s OrderStatus) Settled() bool {
+	return s != OrderStatusPending && s != OrderStatusRefundPending
+}
+
 type Order struct {
 	ID         int         `json:"id"`
 	UserID     int         `json:"user_id"`
 	ProductID  int         `json:"product_id"`
+	VariantID  *int        `json:"variant_id,omitempty"`
 	Quantity   int         `json:"quantity"`
 	Status     OrderStatus `json:"status"`
 	TotalPrice float64     `json:"total_price"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
+	Region     string      `json:"region"`
+	// Snapshot is nil for orders placed before product data was snapshotted
+	Snapshot *ProductSnapshot `json:"product_snapshot,omitempty"`
+	// Pricing is nil for orders placed before totals were broken down
+	Pricing *PriceBreakdown `json:"pricing,omitempty"`
+	// Payment is only looked up for GET /orders/:id?include=payment and is never cached
+	Payment *PaymentDetails `json:"payment,omitempty"`
+	// Metadata holds the integrator's references, e.g. a cart or campaign ID
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Notes     string            `json:"notes,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// SearchOrdersQuery holds the filters and pagination for GET /admin/orders. Every
+// filter is optional; From is inclusive and To exclusive.
+type SearchOrdersQuery struct {
+	Page      int         `form:"page" binding:"omitempty,gte=1"`
+	Limit     int         `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Status    OrderStatus `form:"status"`
+	UserID    int         `form:"user_id" binding:"omitempty,gte=1"`
+	ProductID int         `form:"product_id" binding:"omitempty,gte=1"`
+	Region    string      `form:"region"`
+	From      time.Time   `form:"from"`
+	To        time.Time   `form:"to"`
+	MinTotal  *float64    `form:"min_total" binding:"omitempty,gte=0"`
+	MaxTotal  *float64    `form:"max_total" binding:"omitempty,gte=0"`
+}
+
+// OrderListResponse is the paginated envelope returned by GET /admin/orders
+type OrderListResponse struct {
+	Data       []Order `json:"data"`
+	Page       int     `json:"page"`
+	Limit      int     `json:"limit"`
+	Total      int     `json:"total"`
+	TotalPages int     `json:"total_pages"`
+}
+
+// ProductSnapshot is the product data an order was placed with. It is stored on the
+// order so later product edits or deletions don't change historical orders.
+type ProductSnapshot struct {
+	ProductName string  `json:"product_name"`
+	UnitPrice   float64 `json:"unit_price"`
+	TaxRate     float64 `json:"tax_rate"`
+}
+
+// PriceBreakdown is how an order's total_price was computed: the discount comes off the
+// subtotal and tax is charged on the rest
+type PriceBreakdown struct {
+	Subtotal     float64 `json:"subtotal"`
+	DiscountCode string  `json:"discount_code,omitempty"`
+	Discount     float64 `json:"discount"`
+	TaxRate      float64 `json:"tax_rate"`
+	Tax          float64 `json:"tax"`
+	Total        float64 `json:"total"`
+}
+
+// PaymentDetails is the latest payment payment-service recorded for an order
+type PaymentDetails struct {
+	PaymentID     int       `json:"payment_id"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Status        string    `json:"status"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// OrderStatusChange is one entry in an order's status history
+type OrderStatusChange struct {
+	ID          int         `json:"id"`
+	OrderID     int         `json:"order_id"`
+	FromStatus  OrderStatus `json:"from_status"`
+	ToStatus    OrderStatus `json:"to_status"`
+	SourceEvent string      `json:"source_event"`
+	TraceID     string      `json:"trace_id,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// CreateOrderRequest orders a product, or one of its variants when VariantID is set.
+// A variant is priced and stocked on its own.
 type CreateOrderRequest struct {
-	UserID    int `json:"user_id" binding:"required"`
+	// UserID is taken from the login token when creating an order; if sent, it must match
+	UserID    int `json:"user_id"`
 	ProductID int `json:"product_id" binding:"required"`
+	VariantID int `json:"variant_id" binding:"omitempty,gt=0"`
 	Quantity  int `json:"quantity" binding:"required,gt=0"`
+	// DiscountCode is optional; unknown codes fail validation
+	DiscountCode string `json:"discount_code,omitempty"`
+	// Metadata and Notes are optional and stored on the order as given
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Notes    string            `json:"notes,omitempty"`
+	// PaymentMethodID optionally picks one of the user's saved payment methods in
+	// payment-service; the user's default is charged otherwise. It is passed on to
+	// payment-service in order_created, not stored on the order.
+	PaymentMethodID int `json:"payment_method_id,omitempty" binding:"omitempty,gte=1"`
+	// Currency is optional; when set it must be the ISO 4217 code of the currency the
+	// product is priced in, so a customer is never charged in a currency they didn't see
+	Currency string `json:"currency,omitempty" binding:"omitempty,iso4217"`
+	// MerchantID optionally names the merchant the order is paid to, whose gateway
+	// payment-service charges it through; the default merchant otherwise. Like
+	// PaymentMethodID it is passed on in order_created, not stored on the order.
+	MerchantID string `json:"merchant_id,omitempty"`
+}
+
+var merchantID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidMerchantID reports whether id is shaped like a payment-service merchant ID: up
+// to 64 lowercase letters, digits, dashes and underscores, e.g. "acme-eu"
+func ValidMerchantID(id string) bool {
+	return merchantID.MatchString(id)
 }
 
+// EventVersion is the order_events schema version published by this service. It only
+// changes when a field is renamed, removed or changes type; see
+// contracts/schemas/order_events.json.
+const EventVersion = 1
+
 type OrderEvent struct {
-	OrderID    int         `json:"order_id"`
-	UserID     int         `json:"user_id"`
-	ProductID  int         `json:"product_id"`
-	Quantity   int         `json:"quantity"`
-	Status     OrderStatus `json:"status"`
-	TotalPrice float64     `json:"total_price"`
-	EventType  string      `json:"event_type"` // order_created, order_paid, order_failed
+	// EventID identifies the event; the consumer records it so redeliveries are skipped.
+	// Events published before every event carried one are keyed by their Kafka position.
+	EventID string `json:"event_id,omitempty"`
+	// EventVersion is the schema version the event was written with; 0 means 1
+	EventVersion int         `json:"event_version"`
+	OrderID      int         `json:"order_id"`
+	UserID       int         `json:"user_id"`
+	ProductID    int         `json:"product_id"`
+	Quantity     int         `json:"quantity"`
+	Status       OrderStatus `json:"status"`
+	TotalPrice   float64     `json:"total_price"`
+	// Pricing breaks TotalPrice down on order_created
+	Pricing *PriceBreakdown `json:"pricing,omitempty"`
+	Region  string          `json:"region"`
+	// Metadata and Notes are copied from the order
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Notes    string            `json:"notes,omitempty"`
+	// PaymentMethodID is the saved payment method chosen at checkout, set on
+	// order_created when the customer picked one
+	PaymentMethodID int `json:"payment_method_id,omitempty"`
+	// Currency is the ISO 4217 code of the currency TotalPrice is in, set on
+	// order_created
+	Currency string `json:"currency,omitempty"`
+	// MerchantID is the merchant the order is paid to, set on order_created when
+	// the order was created for one
+	MerchantID string `json:"merchant_id,omitempty"`
+	// PreviousStatus and Reason are set on order_status_overridden
+	PreviousStatus OrderStatus `json:"previous_status,omitempty"`
+	Reason         string      `json:"reason,omitempty"`
+	// ReservationID is the stock reservation product-service gives back on stock_release
+	ReservationID string `json:"reservation_id,omitempty"`
+	// EventType is order_created, order_confirmed, order_expired, refund_requested,
+	// order_status_overridden or stock_release when published by order-service. Payment and refund
+	// outcomes come only from payment-service (payment_success, payment_failed,
+	// payment_timeout, refund_completed, refund_failed); the consumer still accepts the
+	// older order_paid and order_failed names.
+	EventType string `json:"event_type"`
+}
+
+// UpdateOrderStatusRequest is a support override of an order's status. The reason is
+// carried on the emitted event.
+type UpdateOrderStatusRequest struct {
+	Status OrderStatus `json:"status" binding:"required"`
+	Reason string      `json:"reason" binding:"required"`
+}
+
+// OrderValidation is the outcome of the checkout validation pipeline. Totals are
+// filled in whenever the product could be priced, even if other checks failed.
+type OrderValidation struct {
+	Valid       bool              `json:"valid"`
+	UserID      int               `json:"user_id"`
+	ProductID   int               `json:"product_id"`
+	VariantID   int               `json:"variant_id,omitempty"`
+	Quantity    int               `json:"quantity"`
+	ProductName string            `json:"product_name,omitempty"`
+	UnitPrice   float64           `json:"unit_price"`
+	Currency    string            `json:"currency,omitempty"`
+	TaxRate     float64           `json:"tax_rate"`
+	TotalPrice  float64           `json:"total_price"`
+	Pricing     *PriceBreakdown   `json:"pricing,omitempty"`
+	Stock       *int              `json:"stock,omitempty"`
+	Errors      []ValidationError `json:"errors"`
+}
+
+// ValidationError is a blocking problem found while validating an order
+type ValidationError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (v *OrderValidation) AddError(code, message string) {
+	v.Errors = append(v.Errors, ValidationError{Code: code, Message: message})
+}
+
+// HasError reports whether a check failed with code
+func (v *OrderValidation) HasError(code string) bool {
+	for _, e := range v.Errors {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
 }
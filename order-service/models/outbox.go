@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"
+	OutboxPublished OutboxStatus = "published"
+	// OutboxDead rows ran out of relay attempts and wait for an admin to requeue them
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is a Kafka message the async producer couldn't publish, kept until the
+// relay publishes it
+type OutboxMessage struct {
+	ID            int64             `json:"id"`
+	Topic         string            `json:"topic"`
+	Key           *string           `json:"key,omitempty"`
+	Payload       string            `json:"payload"`
+	Headers       map[string]string `json:"headers"`
+	Status        OutboxStatus      `json:"status"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	PublishedAt   *time.Time        `json:"published_at,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// SagaStep is where an order's saga is. The happy path is reserve_stock →
+// charge_payment → confirm_stock → completed; a refused, failed or expired step
+// compensates through release_stock → compensated.
+type SagaStep string
+
+const (
+	SagaStepReserveStock  SagaStep = "reserve_stock"
+	SagaStepChargePayment SagaStep = "charge_payment"
+	SagaStepConfirmStock  SagaStep = "confirm_stock"
+	SagaStepReleaseStock  SagaStep = "release_stock"
+	SagaStepCompleted     SagaStep = "completed"
+	SagaStepCompensated   SagaStep = "compensated"
+)
+
+// sagaSteps lists the steps a saga may move to from each step. A reservation that was
+// refused has nothing to release, and the expiry job releases stock before it cancels
+// an order, so both go straight to compensated.
+var sagaSteps = map[SagaStep][]SagaStep{
+	SagaStepReserveStock:  {SagaStepChargePayment, SagaStepReleaseStock, SagaStepCompensated},
+	SagaStepChargePayment: {SagaStepConfirmStock, SagaStepReleaseStock, SagaStepCompensated},
+	SagaStepConfirmStock:  {SagaStepCompleted},
+	SagaStepReleaseStock:  {SagaStepCompensated},
+}
+
+// CanAdvanceTo reports whether a saga at step s may move to next
+func (s SagaStep) CanAdvanceTo(next SagaStep) bool {
+	for _, allowed := range sagaSteps[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Done reports whether the saga has finished, either way
+func (s SagaStep) Done() bool {
+	return s == SagaStepCompleted || s == SagaStepCompensated
+}
+
+// OrderSaga is the saga state of one order. OrderID is nil until the order has been
+// created; DeadlineAt is when the current step times out.
+type OrderSaga struct {
+	ID            int        `json:"id"`
+	OrderID       *int       `json:"order_id,omitempty"`
+	ReservationID string     `json:"reservation_id"`
+	Step          SagaStep   `json:"step"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	DeadlineAt    *time.Time `json:"deadline_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
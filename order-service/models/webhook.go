@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Webhook is a merchant endpoint that is POSTed a signed payload whenever an order
+// changes status. Secret is only returned when the webhook is created.
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Statuses limits deliveries to changes into these statuses; empty means all
+	Statuses  []OrderStatus `json:"statuses"`
+	Active    bool          `json:"active"`
+	Secret    string        `json:"secret,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// WebhookRequest creates or replaces a webhook. Active defaults to true.
+type WebhookRequest struct {
+	URL      string        `json:"url" binding:"required,url"`
+	Statuses []OrderStatus `json:"statuses"`
+	Active   *bool         `json:"active"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery log of one status change to one webhook
+type WebhookDelivery struct {
+	ID             int                   `json:"id"`
+	WebhookID      int                   `json:"webhook_id"`
+	OrderID        int                   `json:"order_id"`
+	ToStatus       OrderStatus           `json:"to_status"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	Event       string      `json:"event"`
+	OrderID     int         `json:"order_id"`
+	FromStatus  OrderStatus `json:"from_status"`
+	ToStatus    OrderStatus `json:"to_status"`
+	SourceEvent string      `json:"source_event"`
+	ChangedAt   time.Time   `json:"changed_at"`
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// Save stores a message Kafka didn't accept in the outbox, with its key, headers and
+// the delivery error, so it can be published again later
+func Save(ctx context.Context, db *sql.DB, msg *sarama.ProducerMessage, cause error) error {
+	var payload []byte
+	if msg.Value != nil {
+		var err error
+		if payload, err = msg.Value.Encode(); err != nil {
+			return fmt.Errorf("failed to encode message value: %w", err)
+		}
+	}
+
+	var key sql.NullString
+	if msg.Key != nil {
+		encoded, err := msg.Key.Encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode message key: %w", err)
+		}
+		key = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message headers: %w", err)
+	}
+
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO outbox (topic, message_key, payload, headers, last_error) VALUES ($1, $2, $3, $4, NULLIF($5, ''))",
+		msg.Topic, key, string(payload), string(headersJSON), lastError,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message to outbox: %w", err)
+	}
+	return nil
+}
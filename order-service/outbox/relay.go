@@ -0,0 +1,252 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// interval is how often due outbox rows are looked for
+	interval = getEnvDuration("OUTBOX_POLL_INTERVAL", 5*time.Second)
+	// maxAttempts is how many times a row is published before it is marked dead
+	maxAttempts = getEnvInt("OUTBOX_MAX_ATTEMPTS", 10)
+	// baseBackoff is the wait after the first failed publish; it doubles per attempt
+	baseBackoff = getEnvDuration("OUTBOX_RETRY_BACKOFF", 5*time.Second)
+	// batchSize bounds how many rows one poll claims
+	batchSize = getEnvInt("OUTBOX_BATCH_SIZE", 100)
+)
+
+const (
+	maxBackoff = 10 * time.Minute
+	// claimLease is how long claimed rows are hidden from other relays; it outlasts a
+	// batch, so a relay that dies mid-batch leaves its rows to be retried
+	claimLease = 5 * time.Minute
+)
+
+// Relay republishes outbox rows to Kafka in the order they were saved, retrying failures
+// with exponential backoff until they run out of attempts
+type Relay struct {
+	db       *sql.DB
+	producer sarama.SyncProducer
+	logger   *zap.Logger
+}
+
+func NewRelay(db *sql.DB, producer sarama.SyncProducer, logger *zap.Logger) *Relay {
+	return &Relay{db: db, producer: producer, logger: logger}
+}
+
+// Start relays due rows and refreshes the backlog metrics on every tick until ctx is
+// cancelled. Rows are claimed with FOR UPDATE SKIP LOCKED, so replicas and the
+// outbox-relay command can run it side by side.
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RelayDue(ctx); err != nil {
+				r.logger.Error("Failed to relay outbox", zap.Error(err))
+			}
+			if err := r.RecordBacklog(ctx); err != nil {
+				r.logger.Error("Failed to measure outbox backlog", zap.Error(err))
+			}
+		}
+	}
+}
+
+// RelayDue publishes one batch of due rows and returns how many were attempted
+func (r *Relay) RelayDue(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "RelayOutbox")
+	defer span.End()
+
+	messages, err := r.claim(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	for _, msg := range messages {
+		if err := r.relay(ctx, msg); err != nil {
+			span.RecordError(err)
+			return 0, err
+		}
+	}
+
+	span.SetAttributes(attribute.Int("outbox.attempted", len(messages)))
+	return len(messages), nil
+}
+
+// RecordBacklog reports the pending and dead rows and the publish lag, the age of the
+// oldest pending row
+func (r *Relay) RecordBacklog(ctx context.Context) error {
+	var pending, dead int
+	var lagSeconds float64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FILTER (WHERE status = $1),
+			COUNT(*) FILTER (WHERE status = $2),
+			COALESCE(EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - MIN(created_at) FILTER (WHERE status = $1)), 0)
+		FROM outbox WHERE status IN ($1, $2)`,
+		models.OutboxPending, models.OutboxDead,
+	).Scan(&pending, &dead, &lagSeconds)
+	if err != nil {
+		return err
+	}
+
+	middleware.SetOutboxBacklog(pending, dead, time.Duration(lagSeconds*float64(time.Second)))
+	return nil
+}
+
+// claim leases a batch of due rows, oldest first
+func (r *Relay) claim(ctx context.Context) ([]models.OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE outbox
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, message_key, payload, headers, attempts, created_at`,
+		claimLease.Seconds(), models.OutboxPending, batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		var key sql.NullString
+		var headers []byte
+		if err := rows.Scan(&msg.ID, &msg.Topic, &key, &msg.Payload, &headers, &msg.Attempts, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		if key.Valid {
+			msg.Key = &key.String
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING doesn't keep the subquery's order
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+// relay publishes one row and records the outcome. Only a failure to record it is
+// returned; publish failures are logged on the row.
+func (r *Relay) relay(ctx context.Context, msg models.OutboxMessage) error {
+	attempts := msg.Attempts + 1
+	_, _, sendErr := r.producer.SendMessage(producerMessage(msg))
+
+	if sendErr == nil {
+		middleware.RecordOutboxRelayed("published")
+		r.logger.Info("Outbox message published",
+			zap.Int64("outbox_id", msg.ID),
+			zap.String("topic", msg.Topic),
+			zap.Duration("lag", time.Since(msg.CreatedAt)),
+		)
+		_, err := r.db.ExecContext(ctx,
+			`UPDATE outbox
+			SET status = $1, attempts = $2, last_error = NULL, next_attempt_at = NULL, published_at = CURRENT_TIMESTAMP
+			WHERE id = $3`,
+			models.OutboxPublished, attempts, msg.ID,
+		)
+		return err
+	}
+
+	status := models.OutboxPending
+	var nextAttempt sql.NullTime
+	if attempts >= maxAttempts {
+		status = models.OutboxDead
+		middleware.RecordOutboxRelayed("dead")
+		r.logger.Error("Outbox message ran out of attempts, requeue it through /admin/outbox",
+			zap.Int64("outbox_id", msg.ID),
+			zap.String("topic", msg.Topic),
+			zap.Int("attempts", attempts),
+			zap.Error(sendErr),
+		)
+	} else {
+		nextAttempt = sql.NullTime{Time: time.Now().Add(backoff(attempts)), Valid: true}
+		middleware.RecordOutboxRetry()
+		r.logger.Warn("Failed to publish outbox message, will retry",
+			zap.Int64("outbox_id", msg.ID),
+			zap.String("topic", msg.Topic),
+			zap.Int("attempts", attempts),
+			zap.Error(sendErr),
+		)
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $5`,
+		status, attempts, sendErr.Error(), nextAttempt, msg.ID,
+	)
+	return err
+}
+
+// producerMessage rebuilds the Kafka message saved in a row, with its key and headers
+func producerMessage(msg models.OutboxMessage) *sarama.ProducerMessage {
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.StringEncoder(msg.Payload),
+	}
+	if msg.Key != nil {
+		pm.Key = sarama.StringEncoder(*msg.Key)
+	}
+	for key, value := range msg.Headers {
+		pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return pm
+}
+
+// backoff is the wait before retrying after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
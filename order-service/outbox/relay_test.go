@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+var claimColumns = []string{"id", "topic", "message_key", "payload", "headers", "attempts", "created_at"}
+
+func TestRelayDue_PublishesInOrderWithHeaders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Rows come back from RETURNING out of order
+	mock.ExpectQuery("UPDATE outbox SET next_attempt_at .* FOR UPDATE SKIP LOCKED").
+		WithArgs(sqlmock.AnyArg(), models.OutboxPending, batchSize).
+		WillReturnRows(sqlmock.NewRows(claimColumns).
+			AddRow(8, "orders", nil, `{"order_id":2}`, `{}`, 0, time.Now()).
+			AddRow(7, "orders", nil, `{"order_id":1}`, `{"traceparent":"00-abc"}`, 0, time.Now()))
+	mock.ExpectExec("UPDATE outbox SET status = \\$1").
+		WithArgs(models.OutboxPublished, 1, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET status = \\$1").
+		WithArgs(models.OutboxPublished, 1, int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, _ := msg.Value.Encode()
+		if string(value) != `{"order_id":1}` {
+			return errors.New("expected the oldest row first, got " + string(value))
+		}
+		if len(msg.Headers) != 1 || string(msg.Headers[0].Value) != "00-abc" {
+			return errors.New("expected the saved trace header")
+		}
+		return nil
+	})
+	producer.ExpectSendMessageAndSucceed()
+
+	attempted, err := NewRelay(db, producer, zaptest.NewLogger(t)).RelayDue(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if attempted != 2 {
+		t.Errorf("Expected 2 attempted rows, got %d", attempted)
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Errorf("Producer expectations were not met: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestRelayDue_FailureIsRetriedThenDead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// The first failure schedules a retry; the last allowed attempt marks the row dead
+	mock.ExpectQuery("UPDATE outbox SET next_attempt_at").
+		WillReturnRows(sqlmock.NewRows(claimColumns).
+			AddRow(3, "orders", "5", "{}", "{}", 0, time.Now()).
+			AddRow(4, "orders", nil, "{}", "{}", maxAttempts-1, time.Now()))
+	mock.ExpectExec("UPDATE outbox SET status = \\$1").
+		WithArgs(models.OutboxPending, 1, "broker unavailable", sqlmock.AnyArg(), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET status = \\$1").
+		WithArgs(models.OutboxDead, maxAttempts, "broker unavailable", nil, int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(func(msg *sarama.ProducerMessage) error {
+		if msg.Key == nil {
+			return errors.New("expected the saved key")
+		}
+		return nil
+	}, errors.New("broker unavailable"))
+	producer.ExpectSendMessageAndFail(errors.New("broker unavailable"))
+
+	if _, err := NewRelay(db, producer, zaptest.NewLogger(t)).RelayDue(context.Background()); err != nil {
+		t.Fatalf("Expected publish failures to be recorded, not returned, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(1); got != baseBackoff {
+		t.Errorf("Expected %v after the first failure, got %v", baseBackoff, got)
+	}
+	if got := backoff(3); got != 4*baseBackoff {
+		t.Errorf("Expected %v after the third failure, got %v", 4*baseBackoff, got)
+	}
+	if got := backoff(50); got != maxBackoff {
+		t.Errorf("Expected the backoff to be capped at %v, got %v", maxBackoff, got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"order-svc/database"
+	"order-svc/kafka"
+	"order-svc/middleware"
+	"order-svc/outbox"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newOutboxRelayCmd() *cobra.Command {
+	var metricsAddr string
+	cmd := &cobra.Command{
+		Use:   "outbox-relay",
+		Short: "Run only the relay that republishes order events saved to the outbox",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return outboxRelay(logger, metricsAddr)
+		}),
+	}
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", ":9102", "address serving /metrics with the relay's lag and backlog; empty disables it")
+	return cmd
+}
+
+// outboxRelay runs the relay without the APIs until SIGINT/SIGTERM. serve runs the same
+// relay, so this is for draining the outbox while the APIs are down or scaled apart.
+func outboxRelay(logger *zap.Logger, metricsAddr string) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	shutdownTracing, err := middleware.InitTracing("order-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsSrv := &http.Server{Addr: metricsAddr, Handler: mux}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Metrics server stopped", zap.Error(err))
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			metricsSrv.Shutdown(shutdownCtx)
+		}()
+		logger.Info("Outbox relay metrics served", zap.String("addr", metricsAddr))
+	}
+
+	logger.Info("Outbox relay started")
+	outbox.NewRelay(db, producer, logger).Start(ctx)
+	logger.Info("Outbox relay stopped")
+	return nil
+}
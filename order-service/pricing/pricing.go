@@ -0,0 +1,133 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"order-svc/models"
+)
+
+// ErrUnknownDiscount is returned for a discount code that isn't configured
+var ErrUnknownDiscount = errors.New("unknown discount code")
+
+// Discount is a configured discount code: either a percentage of the subtotal or a fixed
+// amount off it
+type Discount struct {
+	Percent float64
+	Amount  float64
+}
+
+// Pricer computes order totals from the configured tax rates and discount codes
+type Pricer struct {
+	defaultTaxRate float64
+	regionTaxRates map[string]float64
+	discounts      map[string]Discount
+}
+
+func New(defaultTaxRate float64, regionTaxRates map[string]float64, discounts map[string]Discount) *Pricer {
+	normalized := make(map[string]Discount, len(discounts))
+	for code, d := range discounts {
+		normalized[normalizeCode(code)] = d
+	}
+	return &Pricer{
+		defaultTaxRate: defaultTaxRate,
+		regionTaxRates: regionTaxRates,
+		discounts:      normalized,
+	}
+}
+
+// Load reads ORDER_TAX_RATE (the rate for regions without their own), ORDER_REGION_TAX_RATES
+// (e.g. "eu-west-1=0.2,us-east-1=0.08") and ORDER_DISCOUNT_CODES (e.g. "SAVE10=10%,FIVEOFF=5",
+// a percentage or a fixed amount). Malformed entries are skipped.
+func Load() *Pricer {
+	defaultTaxRate, err := strconv.ParseFloat(os.Getenv("ORDER_TAX_RATE"), 64)
+	if err != nil || defaultTaxRate < 0 {
+		defaultTaxRate = 0
+	}
+
+	regionTaxRates := map[string]float64{}
+	for region, value := range parsePairs(os.Getenv("ORDER_REGION_TAX_RATES")) {
+		if rate, err := strconv.ParseFloat(value, 64); err == nil && rate >= 0 {
+			regionTaxRates[region] = rate
+		}
+	}
+
+	discounts := map[string]Discount{}
+	for code, value := range parsePairs(os.Getenv("ORDER_DISCOUNT_CODES")) {
+		if percent, ok := strings.CutSuffix(value, "%"); ok {
+			if p, err := strconv.ParseFloat(percent, 64); err == nil && p > 0 && p <= 100 {
+				discounts[code] = Discount{Percent: p}
+			}
+			continue
+		}
+		if amount, err := strconv.ParseFloat(value, 64); err == nil && amount > 0 {
+			discounts[code] = Discount{Amount: amount}
+		}
+	}
+
+	return New(defaultTaxRate, regionTaxRates, discounts)
+}
+
+// TaxRate returns the rate charged on orders stored in region
+func (p *Pricer) TaxRate(region string) float64 {
+	if rate, ok := p.regionTaxRates[region]; ok {
+		return rate
+	}
+	return p.defaultTaxRate
+}
+
+// Price computes an order's total. The discount comes off the subtotal and tax is charged
+// on what remains; a fixed discount never takes the subtotal below zero. Every amount is
+// rounded to cents, and the total is the sum of the rounded parts. An unknown discount
+// code returns ErrUnknownDiscount along with the undiscounted breakdown.
+func (p *Pricer) Price(unitPrice float64, quantity int, region, discountCode string) (models.PriceBreakdown, error) {
+	breakdown := models.PriceBreakdown{
+		Subtotal: roundCents(unitPrice * float64(quantity)),
+		TaxRate:  p.TaxRate(region),
+	}
+
+	var err error
+	if discountCode != "" {
+		code := normalizeCode(discountCode)
+		if discount, ok := p.discounts[code]; !ok {
+			err = ErrUnknownDiscount
+		} else {
+			breakdown.DiscountCode = code
+			if discount.Percent > 0 {
+				breakdown.Discount = roundCents(breakdown.Subtotal * discount.Percent / 100)
+			} else {
+				breakdown.Discount = math.Min(roundCents(discount.Amount), breakdown.Subtotal)
+			}
+		}
+	}
+
+	taxable := breakdown.Subtotal - breakdown.Discount
+	breakdown.Tax = roundCents(taxable * breakdown.TaxRate)
+	breakdown.Total = roundCents(taxable + breakdown.Tax)
+	return breakdown, err
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// normalizeCode makes discount codes case-insensitive
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// parsePairs parses a comma-separated list of key=value pairs
+func parsePairs(raw string) map[string]string {
+	pairs := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(entry, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if ok && key != "" && value != "" {
+			pairs[key] = value
+		}
+	}
+	return pairs
+}
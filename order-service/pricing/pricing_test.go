@@ -0,0 +1,82 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"order-svc/models"
+)
+
+func TestPrice(t *testing.T) {
+	pricer := New(0.1, map[string]float64{"eu-west-1": 0.2}, map[string]Discount{
+		"save10":  {Percent: 10},
+		"FIVEOFF": {Amount: 5},
+	})
+
+	tests := []struct {
+		name     string
+		region   string
+		code     string
+		expected models.PriceBreakdown
+	}{
+		{
+			name:     "default tax rate",
+			region:   "us-east-1",
+			expected: models.PriceBreakdown{Subtotal: 21.98, TaxRate: 0.1, Tax: 2.2, Total: 24.18},
+		},
+		{
+			name:     "regional tax rate",
+			region:   "eu-west-1",
+			expected: models.PriceBreakdown{Subtotal: 21.98, TaxRate: 0.2, Tax: 4.4, Total: 26.38},
+		},
+		{
+			name:     "percentage discount before tax, case-insensitive",
+			region:   "us-east-1",
+			code:     "Save10",
+			expected: models.PriceBreakdown{Subtotal: 21.98, DiscountCode: "SAVE10", Discount: 2.2, TaxRate: 0.1, Tax: 1.98, Total: 21.76},
+		},
+		{
+			name:     "fixed discount",
+			region:   "us-east-1",
+			code:     "fiveoff",
+			expected: models.PriceBreakdown{Subtotal: 21.98, DiscountCode: "FIVEOFF", Discount: 5, TaxRate: 0.1, Tax: 1.7, Total: 18.68},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pricer.Price(10.99, 2, tt.region, tt.code)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrice_FixedDiscountCappedAtSubtotal(t *testing.T) {
+	pricer := New(0.1, nil, map[string]Discount{"BIG": {Amount: 50}})
+
+	got, err := pricer.Price(10, 1, "us-east-1", "BIG")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Discount != 10 || got.Total != 0 {
+		t.Errorf("Expected a 10 discount and a zero total, got %+v", got)
+	}
+}
+
+func TestPrice_UnknownDiscount(t *testing.T) {
+	pricer := New(0, nil, nil)
+
+	got, err := pricer.Price(10, 1, "us-east-1", "NOPE")
+	if !errors.Is(err, ErrUnknownDiscount) {
+		t.Errorf("Expected ErrUnknownDiscount, got %v", err)
+	}
+	// The undiscounted total is still computed for the checkout summary
+	if got.Total != 10 || got.DiscountCode != "" {
+		t.Errorf("Expected an undiscounted total of 10, got %+v", got)
+	}
+}
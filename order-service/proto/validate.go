@@ -0,0 +1,55 @@
+package order
+
+import (
+	"errors"
+
+	"order-svc/models"
+)
+
+// Validate methods are picked up by the gRPC validation interceptor
+
+func (r *CreateOrderRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	if r.GetProductId() <= 0 {
+		return errors.New("product_id must be positive")
+	}
+	if r.GetQuantity() <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if r.GetPaymentMethodId() < 0 {
+		return errors.New("payment_method_id must not be negative")
+	}
+	if r.GetMerchantId() != "" && !models.ValidMerchantID(r.GetMerchantId()) {
+		return errors.New("invalid merchant_id")
+	}
+	return nil
+}
+
+func (r *GetOrderRequest) Validate() error {
+	if r.GetOrderId() <= 0 {
+		return errors.New("order_id must be positive")
+	}
+	return nil
+}
+
+func (r *ListOrdersRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	if r.GetPageSize() < 0 || r.GetPageSize() > 100 {
+		return errors.New("page_size must be between 1 and 100")
+	}
+	if r.GetBeforeId() < 0 {
+		return errors.New("before_id must not be negative")
+	}
+	return nil
+}
+
+func (r *WatchOrderRequest) Validate() error {
+	if r.GetOrderId() <= 0 {
+		return errors.New("order_id must be positive")
+	}
+	return nil
+}
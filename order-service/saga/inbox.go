@@ -0,0 +1,26 @@
+package saga
+
+import (
+	"context"
+)
+
+// Processed reports whether the event with eventID was already handled
+func (s *Saga) Processed(ctx context.Context, eventID string) (bool, error) {
+	var processed bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM processed_events WHERE event_id = $1)",
+		eventID,
+	).Scan(&processed)
+	return processed, err
+}
+
+// MarkProcessed records a handled event in the inbox. It is called only once the event's
+// side effects succeeded, so a failed release or confirm is retried on redelivery; the
+// transition itself is guarded by the order's status.
+func (s *Saga) MarkProcessed(ctx context.Context, eventID, eventType string, orderID int) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO processed_events (event_id, event_type, order_id) VALUES ($1, $2, $3) ON CONFLICT (event_id) DO NOTHING",
+		eventID, eventType, orderID,
+	)
+	return err
+}
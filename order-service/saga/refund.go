@@ -0,0 +1,75 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"order-svc/models"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotRefundable means the order isn't paid, so there is nothing to refund
+var ErrNotRefundable = errors.New("only paid orders can be refunded")
+
+// RequestRefund moves a paid order to refund_pending. The caller then asks
+// payment-service for the refund; Refund or RefundFailed settles it. sql.ErrNoRows
+// means the order doesn't exist.
+func (s *Saga) RequestRefund(ctx context.Context, orderID int, traceID string) (Outcome, error) {
+	outcome, err := s.transition(ctx, orderID, models.OrderStatusPaid, models.OrderStatusRefundPending, "refund_requested", traceID)
+	if err != nil {
+		return outcome, err
+	}
+	if outcome.Change == nil {
+		return outcome, ErrNotRefundable
+	}
+	return outcome, nil
+}
+
+// Refund marks a refund_pending order refunded once payment-service has refunded it.
+// Stock isn't returned, since refunded goods may already have shipped.
+func (s *Saga) Refund(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	outcome, err := s.transition(ctx, orderID, models.OrderStatusRefundPending, models.OrderStatusRefunded, sourceEvent, traceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if outcome.Change == nil {
+		s.logger.Warn("Order not awaiting a refund, ignoring refund",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("status", string(outcome.Status)),
+		)
+		return nil
+	}
+	s.logger.Info("Order status updated to refunded", zap.String("trace_id", traceID), zap.Int("order_id", orderID))
+	return nil
+}
+
+// RefundFailed returns a refund_pending order to paid when payment-service couldn't
+// refund it, so the refund can be requested again
+func (s *Saga) RefundFailed(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	outcome, err := s.transition(ctx, orderID, models.OrderStatusRefundPending, models.OrderStatusPaid, sourceEvent, traceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if outcome.Change == nil {
+		s.logger.Warn("Order not awaiting a refund, ignoring refund failure",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("status", string(outcome.Status)),
+		)
+		return nil
+	}
+	s.logger.Warn("Refund failed, order is paid again", zap.String("trace_id", traceID), zap.Int("order_id", orderID))
+	return nil
+}
@@ -0,0 +1,181 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"order-svc/cache"
+	"order-svc/grpc"
+	"order-svc/models"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Saga settles orders against their stock reservations in product-service. CreateOrder
+// reserves stock before the order exists; Pay confirms the reservation once the payment
+// succeeds, and Fail asks product-service to release it with a stock_release event when
+// the payment fails. Each order's progress is kept in order_sagas, so a step that times
+// out is retried by the orchestrator (see Start).
+type Saga struct {
+	db            *sql.DB
+	redisClient   *redis.Client
+	productClient *grpc.ProductClient
+	publish       Publisher
+	logger        *zap.Logger
+}
+
+// Publisher publishes an order event on the orders topic; see kafka.SagaPublisher
+type Publisher func(ctx context.Context, event models.OrderEvent) error
+
+func New(db *sql.DB, redisClient *redis.Client, productClient *grpc.ProductClient, publish Publisher, logger *zap.Logger) *Saga {
+	return &Saga{
+		db:            db,
+		redisClient:   redisClient,
+		productClient: productClient,
+		publish:       publish,
+		logger:        logger,
+	}
+}
+
+// Pay marks a pending order paid and confirms its reservation. Confirming is idempotent,
+// so a redelivered payment confirms again harmlessly.
+func (s *Saga) Pay(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	outcome, err := s.transition(ctx, orderID, models.OrderStatusPending, models.OrderStatusPaid, sourceEvent, traceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	reservationID, status := outcome.ReservationID, outcome.Status
+
+	if status == models.OrderStatusRefundPending || status == models.OrderStatusRefunded {
+		// A redelivered payment for an order that has since been paid and refunded
+		return nil
+	}
+	if status != models.OrderStatusPaid {
+		// The order expired, or failed when its payment timed out, and its stock was
+		// released; the payment needs a refund
+		s.logger.Error("Payment succeeded for an order that is no longer pending",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("status", string(status)),
+		)
+		return nil
+	}
+	s.logger.Info("Order status updated to paid", zap.String("trace_id", traceID), zap.Int("order_id", orderID))
+
+	// Orders created before reservations have none
+	if !reservationID.Valid {
+		return nil
+	}
+	confirmed, err := s.productClient.ConfirmStock(ctx, reservationID.String)
+	if err != nil {
+		return fmt.Errorf("failed to confirm stock reservation: %w", err)
+	}
+	if !confirmed {
+		s.logger.Error("Paid order's stock reservation was already released",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("reservation_id", reservationID.String),
+		)
+	} else {
+		s.logger.Info("Stock reservation confirmed",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("reservation_id", reservationID.String),
+		)
+	}
+	s.advance(ctx, reservationID.String, models.SagaStepConfirmStock, models.SagaStepCompleted, traceID)
+	return nil
+}
+
+// Fail marks a pending order failed and gives its reserved stock back. The stock_release
+// event is published again on every redelivery of a failure, since product-service
+// releases idempotently and an earlier attempt may not have gone through.
+func (s *Saga) Fail(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	return s.abandon(ctx, orderID, models.OrderStatusFailed, sourceEvent, traceID)
+}
+
+// Cancel marks a pending order cancelled and gives its reserved stock back, like Fail
+func (s *Saga) Cancel(ctx context.Context, orderID int, sourceEvent, traceID string) error {
+	return s.abandon(ctx, orderID, models.OrderStatusCancelled, sourceEvent, traceID)
+}
+
+// abandon moves a pending order to status and releases its reservation
+func (s *Saga) abandon(ctx context.Context, orderID int, status models.OrderStatus, sourceEvent, traceID string) error {
+	outcome, err := s.transition(ctx, orderID, models.OrderStatusPending, status, sourceEvent, traceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	reservationID := outcome.ReservationID
+
+	if outcome.Status != status {
+		s.logger.Warn("Order already settled, not moving it",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.String("status", string(outcome.Status)),
+			zap.String("to_status", string(status)),
+		)
+		return nil
+	}
+	s.logger.Info("Order status updated", zap.String("trace_id", traceID), zap.Int("order_id", orderID), zap.String("status", string(status)))
+
+	// Compensate: give the reserved stock back. Orders created before reservations have none.
+	if !reservationID.Valid {
+		return nil
+	}
+	// One event ID per order, so product-service's consumer and the redeliveries of this
+	// event agree on what was released. The saga stays in release_stock until the
+	// orchestrator's ReleaseStock finds the reservation settled, so a release event that
+	// is lost is still carried out.
+	event := models.OrderEvent{
+		EventID:       fmt.Sprintf("stock-release-%d", orderID),
+		OrderID:       orderID,
+		Status:        status,
+		ReservationID: reservationID.String,
+		EventType:     "stock_release",
+	}
+	if err := s.publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish stock_release event: %w", err)
+	}
+	s.logger.Info("Stock release requested",
+		zap.String("trace_id", traceID),
+		zap.Int("order_id", orderID),
+		zap.String("reservation_id", reservationID.String),
+	)
+	return nil
+}
+
+// transition applies Transition, then drops the cached order so the next read reflects
+// the new status and tells the order's watchers about the change
+func (s *Saga) transition(ctx context.Context, orderID int, from, status models.OrderStatus, sourceEvent, traceID string) (Outcome, error) {
+	outcome, err := Transition(ctx, s.db, orderID, from, status, sourceEvent, traceID)
+	if err != nil {
+		return outcome, err
+	}
+
+	if err := cache.DeleteOrder(ctx, s.redisClient, orderID); err != nil {
+		s.logger.Warn("Failed to invalidate order cache",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+		)
+	}
+	if outcome.Change != nil {
+		if err := cache.PublishOrderStatus(ctx, s.redisClient, orderID, outcome.Change); err != nil {
+			s.logger.Warn("Failed to publish order status change",
+				zap.String("trace_id", traceID),
+				zap.Int("order_id", orderID),
+				zap.Error(err),
+			)
+		}
+	}
+	return outcome, nil
+}
@@ -0,0 +1,327 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// StepTimeout is how long reserve_stock, confirm_stock and release_stock may take
+	// before the orchestrator retries them (SAGA_STEP_TIMEOUT)
+	StepTimeout = getEnvDuration("SAGA_STEP_TIMEOUT", time.Minute)
+	// PaymentTimeout is how long an order may wait in charge_payment before the expiry
+	// job cancels it and releases its stock (ORDER_RESERVATION_TIMEOUT)
+	PaymentTimeout = getEnvDuration("ORDER_RESERVATION_TIMEOUT", 15*time.Minute)
+	// interval is how often timed-out steps are looked for
+	interval = getEnvDuration("SAGA_POLL_INTERVAL", 30*time.Second)
+	// batchSize bounds how many sagas one poll claims
+	batchSize = getEnvInt("SAGA_BATCH_SIZE", 50)
+)
+
+// maxBackoff caps the wait between retries of a step that keeps failing
+const maxBackoff = 10 * time.Minute
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Advance moves the saga of a reservation from step from to step to. Nothing happens
+// when the saga isn't at from, so a redelivered event or a step the orchestrator already
+// retried doesn't move it twice, nor for orders created before sagas, which have none.
+func Advance(ctx context.Context, db execer, reservationID string, from, to models.SagaStep) error {
+	if !from.CanAdvanceTo(to) {
+		return fmt.Errorf("saga can't advance from %s to %s", from, to)
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE order_sagas
+		SET step = $1, deadline_at = $2, attempts = 0, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE reservation_id = $3 AND step = $4`,
+		to, deadline(to), reservationID, from,
+	)
+	return err
+}
+
+// deadline is when a saga entering step times out, or NULL once it is done
+func deadline(step models.SagaStep) sql.NullTime {
+	switch {
+	case step.Done():
+		return sql.NullTime{}
+	case step == models.SagaStepChargePayment:
+		return sql.NullTime{Time: time.Now().Add(PaymentTimeout), Valid: true}
+	default:
+		return sql.NullTime{Time: time.Now().Add(StepTimeout), Valid: true}
+	}
+}
+
+// paymentStep is the step a saga moves to when its order leaves pending for status
+func paymentStep(from, status models.OrderStatus) (models.SagaStep, bool) {
+	if from != models.OrderStatusPending {
+		return "", false
+	}
+	switch status {
+	case models.OrderStatusPaid:
+		return models.SagaStepConfirmStock, true
+	case models.OrderStatusFailed, models.OrderStatusCancelled:
+		return models.SagaStepReleaseStock, true
+	}
+	return "", false
+}
+
+// Begin records the saga of a new order in reserve_stock, before its stock is reserved,
+// so a reservation whose order never gets created is found and released
+func (s *Saga) Begin(ctx context.Context, reservationID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO order_sagas (reservation_id, step, deadline_at) VALUES ($1, $2, $3)",
+		reservationID, models.SagaStepReserveStock, deadline(models.SagaStepReserveStock),
+	)
+	return err
+}
+
+// Reserved links the saga to the order created for its reservation and moves it on to
+// charge_payment. Failures are only logged; the orchestrator links it later.
+func (s *Saga) Reserved(ctx context.Context, reservationID string, orderID int) {
+	if err := s.link(ctx, reservationID, orderID); err != nil {
+		s.logger.Error("Failed to record saga step",
+			zap.String("trace_id", middleware.GetTraceID(ctx)),
+			zap.Int("order_id", orderID),
+			zap.String("reservation_id", reservationID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Rejected ends the saga of a reservation product-service refused; nothing was
+// reserved, so there is nothing to release
+func (s *Saga) Rejected(ctx context.Context, reservationID string) {
+	s.advance(ctx, reservationID, models.SagaStepReserveStock, models.SagaStepCompensated, middleware.GetTraceID(ctx))
+}
+
+// Abort compensates a stock reservation whose order could not be created: the stock is
+// released and the saga compensated. A failed release leaves the saga in release_stock
+// for the orchestrator to retry; ReleaseStock is idempotent.
+func (s *Saga) Abort(ctx context.Context, reservationID string) {
+	// Detach from the request so a cancelled or timed-out request still compensates
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	traceID := middleware.GetTraceID(ctx)
+	s.advance(ctx, reservationID, models.SagaStepReserveStock, models.SagaStepReleaseStock, traceID)
+	if _, err := s.productClient.ReleaseStock(ctx, reservationID); err != nil {
+		s.logger.Error("Failed to release stock reservation, the saga orchestrator will retry",
+			zap.String("trace_id", traceID),
+			zap.String("reservation_id", reservationID),
+			zap.Error(err),
+		)
+		return
+	}
+	s.logger.Info("Stock reservation released", zap.String("trace_id", traceID), zap.String("reservation_id", reservationID))
+	s.advance(ctx, reservationID, models.SagaStepReleaseStock, models.SagaStepCompensated, traceID)
+}
+
+// advance applies Advance, logging failures: the step already happened, so a saga left
+// behind is retried by the orchestrator, which is harmless since every step is idempotent
+func (s *Saga) advance(ctx context.Context, reservationID string, from, to models.SagaStep, traceID string) {
+	if err := Advance(ctx, s.db, reservationID, from, to); err != nil {
+		s.logger.Error("Failed to record saga step",
+			zap.String("trace_id", traceID),
+			zap.String("reservation_id", reservationID),
+			zap.String("step", string(to)),
+			zap.Error(err),
+		)
+	}
+}
+
+// link sets the saga's order and moves it from reserve_stock to charge_payment
+func (s *Saga) link(ctx context.Context, reservationID string, orderID int) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE order_sagas
+		SET order_id = $1, step = $2, deadline_at = $3, attempts = 0, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE reservation_id = $4 AND step = $5`,
+		orderID, models.SagaStepChargePayment, deadline(models.SagaStepChargePayment), reservationID, models.SagaStepReserveStock,
+	)
+	return err
+}
+
+// Start retries timed-out saga steps on every tick until ctx is cancelled. Sagas are
+// claimed with FOR UPDATE SKIP LOCKED, so every replica may run it.
+func (s *Saga) Start(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RecoverStalled(ctx); err != nil {
+				s.logger.Error("Failed to recover stalled sagas", zap.Error(err))
+			}
+		}
+	}
+}
+
+// RecoverStalled retries one batch of steps that outlived StepTimeout and returns how
+// many it retried. charge_payment is left to the expiry job, which cancels the order.
+func (s *Saga) RecoverStalled(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "RecoverSagas")
+	defer span.End()
+
+	sagas, err := s.claimStalled(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	for _, orderSaga := range sagas {
+		stepErr := s.recover(ctx, orderSaga)
+		if stepErr == nil {
+			middleware.RecordSagaRecovery(string(orderSaga.Step), "advanced")
+			s.logger.Info("Recovered stalled saga step",
+				zap.String("reservation_id", orderSaga.ReservationID),
+				zap.String("step", string(orderSaga.Step)),
+			)
+			continue
+		}
+
+		middleware.RecordSagaRecovery(string(orderSaga.Step), "failed")
+		s.logger.Warn("Failed to recover saga step, will retry",
+			zap.String("reservation_id", orderSaga.ReservationID),
+			zap.String("step", string(orderSaga.Step)),
+			zap.Int("attempts", orderSaga.Attempts),
+			zap.Error(stepErr),
+		)
+		if _, err := s.db.ExecContext(ctx,
+			"UPDATE order_sagas SET last_error = $1, deadline_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+			stepErr.Error(), time.Now().Add(backoff(orderSaga.Attempts)), orderSaga.ID,
+		); err != nil {
+			span.RecordError(err)
+			return 0, err
+		}
+	}
+
+	span.SetAttributes(attribute.Int("sagas.recovered", len(sagas)))
+	return len(sagas), nil
+}
+
+// recover runs the step a saga timed out in again
+func (s *Saga) recover(ctx context.Context, orderSaga models.OrderSaga) error {
+	reservationID := orderSaga.ReservationID
+
+	switch orderSaga.Step {
+	case models.SagaStepReserveStock:
+		// CreateOrder died between reserving and recording; if the order exists it
+		// only missed linking the saga, otherwise the reservation is orphaned
+		var orderID int
+		err := s.db.QueryRowContext(ctx, "SELECT id FROM orders WHERE reservation_id = $1", reservationID).Scan(&orderID)
+		if err == nil {
+			return s.link(ctx, reservationID, orderID)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := Advance(ctx, s.db, reservationID, models.SagaStepReserveStock, models.SagaStepReleaseStock); err != nil {
+			return err
+		}
+		return s.release(ctx, reservationID)
+
+	case models.SagaStepConfirmStock:
+		confirmed, err := s.productClient.ConfirmStock(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			s.logger.Error("Paid order's stock reservation was already released", zap.String("reservation_id", reservationID))
+		}
+		return Advance(ctx, s.db, reservationID, models.SagaStepConfirmStock, models.SagaStepCompleted)
+
+	case models.SagaStepReleaseStock:
+		return s.release(ctx, reservationID)
+	}
+	return fmt.Errorf("saga step %s is not retried", orderSaga.Step)
+}
+
+// release gives a reservation's stock back and compensates its saga
+func (s *Saga) release(ctx context.Context, reservationID string) error {
+	if _, err := s.productClient.ReleaseStock(ctx, reservationID); err != nil {
+		return err
+	}
+	return Advance(ctx, s.db, reservationID, models.SagaStepReleaseStock, models.SagaStepCompensated)
+}
+
+// claimStalled leases a batch of sagas whose step timed out, hiding them from other
+// replicas for StepTimeout
+func (s *Saga) claimStalled(ctx context.Context) ([]models.OrderSaga, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE order_sagas
+		SET deadline_at = CURRENT_TIMESTAMP + make_interval(secs => $1), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM order_sagas
+			WHERE step IN ($2, $3, $4) AND deadline_at <= CURRENT_TIMESTAMP
+			ORDER BY deadline_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, order_id, reservation_id, step, attempts`,
+		StepTimeout.Seconds(), models.SagaStepReserveStock, models.SagaStepConfirmStock, models.SagaStepReleaseStock, batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sagas []models.OrderSaga
+	for rows.Next() {
+		var orderSaga models.OrderSaga
+		var orderID sql.NullInt64
+		if err := rows.Scan(&orderSaga.ID, &orderID, &orderSaga.ReservationID, &orderSaga.Step, &orderSaga.Attempts); err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			id := int(orderID.Int64)
+			orderSaga.OrderID = &id
+		}
+		sagas = append(sagas, orderSaga)
+	}
+	return sagas, rows.Err()
+}
+
+// backoff is the wait before retrying a step after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := StepTimeout
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
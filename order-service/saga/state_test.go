@@ -0,0 +1,91 @@
+package saga
+
+import (
+	"context"
+	"testing"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAdvance_RejectsSkippedSteps(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Stock can't be confirmed before the payment is charged
+	if err := Advance(context.Background(), db, "res-1", models.SagaStepReserveStock, models.SagaStepConfirmStock); err == nil {
+		t.Error("Expected an error advancing reserve_stock to confirm_stock")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestRecoverStalled_LinksCreatedOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// CreateOrder inserted the order but died before recording it on the saga
+	mock.ExpectQuery("UPDATE order_sagas .* FOR UPDATE SKIP LOCKED").
+		WithArgs(StepTimeout.Seconds(), models.SagaStepReserveStock, models.SagaStepConfirmStock, models.SagaStepReleaseStock, batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "reservation_id", "step", "attempts"}).
+			AddRow(4, nil, "res-1", models.SagaStepReserveStock, 1))
+	mock.ExpectQuery("SELECT id FROM orders WHERE reservation_id = \\$1").
+		WithArgs("res-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("UPDATE order_sagas SET order_id = \\$1").
+		WithArgs(9, models.SagaStepChargePayment, sqlmock.AnyArg(), "res-1", models.SagaStepReserveStock).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	recovered, err := New(db, nil, nil, nil, zaptest.NewLogger(t)).RecoverStalled(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("Expected 1 recovered saga, got %d", recovered)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestBackoff_DoublesUpToCap(t *testing.T) {
+	if got := backoff(1); got != StepTimeout {
+		t.Errorf("Expected first retry after %v, got %v", StepTimeout, got)
+	}
+	if got := backoff(2); got != 2*StepTimeout {
+		t.Errorf("Expected second retry after %v, got %v", 2*StepTimeout, got)
+	}
+	if got := backoff(50); got != maxBackoff {
+		t.Errorf("Expected backoff capped at %v, got %v", maxBackoff, got)
+	}
+}
+
+func TestSagaStep_Transitions(t *testing.T) {
+	tests := []struct {
+		from, to models.SagaStep
+		want     bool
+	}{
+		{models.SagaStepReserveStock, models.SagaStepChargePayment, true},
+		{models.SagaStepChargePayment, models.SagaStepConfirmStock, true},
+		{models.SagaStepChargePayment, models.SagaStepCompensated, true},
+		{models.SagaStepConfirmStock, models.SagaStepCompleted, true},
+		{models.SagaStepConfirmStock, models.SagaStepReleaseStock, false},
+		{models.SagaStepCompleted, models.SagaStepReleaseStock, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanAdvanceTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+
+	"order-svc/models"
+	"order-svc/webhook"
+)
+
+// Outcome is what Transition found and did
+type Outcome struct {
+	ReservationID sql.NullString
+	// Status is the order's status after the call
+	Status models.OrderStatus
+	// Change is the recorded history entry, or nil if the order didn't move
+	Change *models.OrderStatusChange
+}
+
+// Transition moves an order from status from to status, appends the change to
+// order_status_history, queues its webhook deliveries and advances the order's saga in
+// the same transaction, so none of them can miss or invent a transition. Only moves allowed
+// by OrderStatus.CanTransitionTo happen, and only out of from, so a refund outcome can't
+// move a pending order to paid: a redelivered or out-of-order event, or a payment that
+// lands after the order expired, records nothing. sql.ErrNoRows means the order doesn't
+// exist.
+func Transition(ctx context.Context, db *sql.DB, orderID int, from, status models.OrderStatus, sourceEvent, traceID string) (Outcome, error) {
+	var outcome Outcome
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return outcome, err
+	}
+	defer tx.Rollback()
+
+	// Lock the row so concurrent events record the status they actually replaced
+	var current models.OrderStatus
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, reservation_id FROM orders WHERE id = $1 FOR UPDATE",
+		orderID,
+	).Scan(&current, &outcome.ReservationID)
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Status = current
+	if current != from || !current.CanTransitionTo(status) {
+		return outcome, nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		status, orderID,
+	); err != nil {
+		return outcome, err
+	}
+	change := models.OrderStatusChange{
+		OrderID:     orderID,
+		FromStatus:  current,
+		ToStatus:    status,
+		SourceEvent: sourceEvent,
+		TraceID:     traceID,
+	}
+	if err := tx.QueryRowContext(ctx,
+		"INSERT INTO order_status_history (order_id, from_status, to_status, source_event, trace_id) VALUES ($1, $2, $3, $4, NULLIF($5, '')) RETURNING id, created_at",
+		orderID, current, status, sourceEvent, traceID,
+	).Scan(&change.ID, &change.CreatedAt); err != nil {
+		return outcome, err
+	}
+	if err := webhook.Enqueue(ctx, tx, change); err != nil {
+		return outcome, err
+	}
+	// Settling a pending order moves its saga on to confirming or releasing the stock
+	if step, ok := paymentStep(current, status); ok && outcome.ReservationID.Valid {
+		if err := Advance(ctx, tx, outcome.ReservationID.String, models.SagaStepChargePayment, step); err != nil {
+			return outcome, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return outcome, err
+	}
+
+	outcome.Status = status
+	outcome.Change = &change
+	return outcome, nil
+}
@@ -0,0 +1,137 @@
+package saga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTransition_RecordsHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, "res-1"))
+	mock.ExpectExec("UPDATE orders SET status = \\$1").
+		WithArgs(models.OrderStatusFailed, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO order_status_history").
+		WithArgs(1, models.OrderStatusPending, models.OrderStatusFailed, "payment_failed", "trace-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs(1, models.OrderStatusFailed, sqlmock.AnyArg(), "failed").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The failed payment hands the reservation to the release step
+	mock.ExpectExec("UPDATE order_sagas").
+		WithArgs(models.SagaStepReleaseStock, sqlmock.AnyArg(), "res-1", models.SagaStepChargePayment).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	outcome, err := Transition(context.Background(), db, 1, models.OrderStatusPending, models.OrderStatusFailed, "payment_failed", "trace-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if outcome.ReservationID.String != "res-1" {
+		t.Errorf("Expected reservation res-1, got %q", outcome.ReservationID.String)
+	}
+	if outcome.Status != models.OrderStatusFailed {
+		t.Errorf("Expected status failed, got %q", outcome.Status)
+	}
+	if outcome.Change == nil || outcome.Change.FromStatus != models.OrderStatusPending || outcome.Change.ID != 1 {
+		t.Errorf("Expected the recorded change, got %+v", outcome.Change)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestTransition_RedeliveryRecordsNothing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// The order is already paid, so neither the order nor its history changes
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPaid, nil))
+	mock.ExpectRollback()
+
+	outcome, err := Transition(context.Background(), db, 1, models.OrderStatusPending, models.OrderStatusPaid, "payment_success", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if outcome.Change != nil {
+		t.Errorf("Expected no change, got %+v", outcome.Change)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestTransition_SettledOrderDoesNotMove(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// The order expired; a late payment must not mark it paid
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusCancelled, "res-1"))
+	mock.ExpectRollback()
+
+	outcome, err := Transition(context.Background(), db, 1, models.OrderStatusPending, models.OrderStatusPaid, "payment_success", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if outcome.Status != models.OrderStatusCancelled {
+		t.Errorf("Expected order to stay cancelled, got %q", outcome.Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestTransition_RefundOutcomeOnlyMovesRefundPendingOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	// pending -> paid is allowed, but not for a refund failure
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, reservation_id FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reservation_id"}).AddRow(models.OrderStatusPending, "res-1"))
+	mock.ExpectRollback()
+
+	outcome, err := Transition(context.Background(), db, 1, models.OrderStatusRefundPending, models.OrderStatusPaid, "refund_failed", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if outcome.Status != models.OrderStatusPending || outcome.Change != nil {
+		t.Errorf("Expected order to stay pending, got %+v", outcome)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
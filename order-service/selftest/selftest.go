@@ -0,0 +1,382 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"order-svc/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+
+	// pollInterval is how often asynchronous outcomes (payment, notification) are checked
+	pollInterval = 500 * time.Millisecond
+)
+
+var errSkipped = errors.New("skipped after an earlier failure")
+
+// StepResult is the outcome of one step of the drill
+type StepResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the step-by-step result of a self-test run
+type Report struct {
+	Status     string       `json:"status"`
+	TraceID    string       `json:"trace_id,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMs int64        `json:"duration_ms"`
+	Steps      []StepResult `json:"steps"`
+}
+
+// Runner drives a synthetic order through the public REST APIs of every service:
+// register and log in a user, create a product as the admin account, place an order, wait for the payment outcome,
+// check the notification was delivered, then delete the product.
+type Runner struct {
+	httpClient      *http.Client
+	userURL         string
+	productURL      string
+	orderURL        string
+	notificationURL string
+	adminEmail      string
+	adminPassword   string
+	timeout         time.Duration
+	logger          *zap.Logger
+}
+
+func NewRunner(logger *zap.Logger) *Runner {
+	return &Runner{
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+		userURL:         getEnv("USER_SERVICE_URL", "http://localhost:8080"),
+		productURL:      getEnv("PRODUCT_SERVICE_URL", "http://localhost:8081"),
+		orderURL:        getEnv("SELFTEST_ORDER_URL", "http://localhost:8082"),
+		notificationURL: getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8084"),
+		adminEmail:      getEnv("SELFTEST_ADMIN_EMAIL", "admin@mini-shop.local"),
+		adminPassword:   os.Getenv("SELFTEST_ADMIN_PASSWORD"),
+		timeout:         getEnvDuration("SELFTEST_STEP_TIMEOUT", 30*time.Second),
+		logger:          logger,
+	}
+}
+
+// run state shared between steps
+type run struct {
+	userID     int
+	userToken  string
+	adminToken string
+	productID  int
+	orderID    int
+	payment    string
+}
+
+func (r *Runner) Run(ctx context.Context) Report {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "SelfTest")
+	defer span.End()
+
+	report := Report{
+		StartedAt: time.Now(),
+		TraceID:   span.SpanContext().TraceID().String(),
+	}
+	state := &run{}
+	failed := false
+
+	step := func(name string, fn func(ctx context.Context, state *run) (string, error)) {
+		result := StepResult{Name: name}
+		if failed {
+			result.Status = StatusSkipped
+			result.Error = errSkipped.Error()
+			report.Steps = append(report.Steps, result)
+			return
+		}
+
+		start := time.Now()
+		detail, err := fn(ctx, state)
+		result.DurationMs = time.Since(start).Milliseconds()
+		result.Detail = detail
+		if err != nil {
+			failed = true
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			span.RecordError(err)
+			r.logger.Warn("Self-test step failed", zap.String("step", name), zap.Error(err))
+		} else {
+			result.Status = StatusPassed
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	step("register_test_user", r.registerUser)
+	step("create_test_product", r.createProduct)
+	step("create_order", r.createOrder)
+	step("await_payment_outcome", r.awaitPayment)
+	step("verify_notification", r.verifyNotification)
+
+	// Always clean up the product, even when an earlier step failed or the caller went away
+	if state.productID != 0 {
+		ctx = context.WithoutCancel(ctx)
+		wasFailed := failed
+		failed = false
+		step("delete_test_product", r.deleteProduct)
+		failed = failed || wasFailed
+	}
+
+	report.Status = StatusPassed
+	if failed {
+		report.Status = StatusFailed
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	span.SetAttributes(attribute.String("selftest.status", report.Status))
+
+	r.logger.Info("Self-test finished",
+		zap.String("trace_id", report.TraceID),
+		zap.String("status", report.Status),
+		zap.Int64("duration_ms", report.DurationMs),
+	)
+	return report
+}
+
+func (r *Runner) registerUser(ctx context.Context, state *run) (string, error) {
+	suffix := time.Now().UnixNano()
+	var user struct {
+		ID int `json:"id"`
+	}
+	email := fmt.Sprintf("selftest+%d@example.com", suffix)
+	password := fmt.Sprintf("selftest-%d", suffix)
+	err := r.doJSON(ctx, http.MethodPost, r.userURL+"/api/v1/register", map[string]interface{}{
+		"name":     fmt.Sprintf("selftest-%d", suffix),
+		"email":    email,
+		"password": password,
+	}, http.StatusCreated, &user)
+	if err != nil {
+		return "", err
+	}
+	state.userID = user.ID
+
+	// Orders are placed for the user in the token, so the order step needs one
+	var login struct {
+		Token string `json:"token"`
+	}
+	err = r.doJSON(ctx, http.MethodPost, r.userURL+"/api/v1/login", map[string]interface{}{
+		"email":    email,
+		"password": password,
+	}, http.StatusOK, &login)
+	if err != nil {
+		return "", fmt.Errorf("test user login failed: %w", err)
+	}
+	state.userToken = login.Token
+	return fmt.Sprintf("user_id=%d", user.ID), nil
+}
+
+// createProduct logs in as the admin account first, since product mutations need an admin JWT
+func (r *Runner) createProduct(ctx context.Context, state *run) (string, error) {
+	if r.adminPassword == "" {
+		return "", errors.New("SELFTEST_ADMIN_PASSWORD is not set")
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	err := r.doJSON(ctx, http.MethodPost, r.userURL+"/api/v1/login", map[string]interface{}{
+		"email":    r.adminEmail,
+		"password": r.adminPassword,
+	}, http.StatusOK, &login)
+	if err != nil {
+		return "", fmt.Errorf("admin login failed: %w", err)
+	}
+	state.adminToken = login.Token
+
+	var product struct {
+		ID int `json:"id"`
+	}
+	err = r.doAuthJSON(ctx, http.MethodPost, r.productURL+"/api/v1/products", state.adminToken, map[string]interface{}{
+		"name":  fmt.Sprintf("selftest-product-%d", time.Now().UnixNano()),
+		"price": 1.00,
+		"stock": 1,
+		"tags":  []string{"selftest"},
+	}, http.StatusCreated, &product)
+	if err != nil {
+		return "", err
+	}
+	state.productID = product.ID
+	return fmt.Sprintf("product_id=%d", product.ID), nil
+}
+
+func (r *Runner) createOrder(ctx context.Context, state *run) (string, error) {
+	var order struct {
+		ID int `json:"id"`
+	}
+	err := r.doAuthJSON(ctx, http.MethodPost, r.orderURL+"/api/v1/orders", state.userToken, map[string]interface{}{
+		"user_id":    state.userID,
+		"product_id": state.productID,
+		"quantity":   1,
+	}, http.StatusCreated, &order)
+	if err != nil {
+		return "", err
+	}
+	state.orderID = order.ID
+	return fmt.Sprintf("order_id=%d", order.ID), nil
+}
+
+// awaitPayment polls the order until payment-service has moved it out of pending.
+// A failed payment is a valid outcome; the drill checks that the saga completes.
+func (r *Runner) awaitPayment(ctx context.Context, state *run) (string, error) {
+	url := fmt.Sprintf("%s/api/v1/orders/%d", r.orderURL, state.orderID)
+	err := r.poll(ctx, func() (bool, error) {
+		var order struct {
+			Status string `json:"status"`
+		}
+		if err := r.doJSON(ctx, http.MethodGet, url, nil, http.StatusOK, &order); err != nil {
+			return false, err
+		}
+		state.payment = order.Status
+		return order.Status != string(models.OrderStatusPending), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("order %d still %q: %w", state.orderID, state.payment, err)
+	}
+	return "order status=" + state.payment, nil
+}
+
+func (r *Runner) verifyNotification(ctx context.Context, state *run) (string, error) {
+	want := "payment_success"
+	if state.payment != string(models.OrderStatusPaid) {
+		want = "payment_failed"
+	}
+
+	url := fmt.Sprintf("%s/api/v1/orders/%d/notifications", r.notificationURL, state.orderID)
+	err := r.poll(ctx, func() (bool, error) {
+		var body struct {
+			Notifications []struct {
+				EventType string `json:"event_type"`
+			} `json:"notifications"`
+		}
+		if err := r.doAuthJSON(ctx, http.MethodGet, url, state.adminToken, nil, http.StatusOK, &body); err != nil {
+			return false, err
+		}
+		for _, n := range body.Notifications {
+			if n.EventType == want {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("no %s notification for order %d: %w", want, state.orderID, err)
+	}
+	return want + " notification delivered", nil
+}
+
+func (r *Runner) deleteProduct(ctx context.Context, state *run) (string, error) {
+	url := fmt.Sprintf("%s/api/v1/products/%d", r.productURL, state.productID)
+	if err := r.doAuthJSON(ctx, http.MethodDelete, url, state.adminToken, nil, http.StatusOK, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("product_id=%d", state.productID), nil
+}
+
+// poll calls check until it reports done, returns an error, or the step timeout passes
+func (r *Runner) poll(ctx context.Context, check func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		done, err := check()
+		if err == nil && done {
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("timed out after %s: %w", r.timeout, lastErr)
+			}
+			return fmt.Errorf("timed out after %s", r.timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) doJSON(ctx context.Context, method, url string, body interface{}, wantStatus int, out interface{}) error {
+	return r.doAuthJSON(ctx, method, url, "", body, wantStatus, out)
+}
+
+// doAuthJSON is doJSON with a bearer token, sent when token is not empty
+func (r *Runner) doAuthJSON(ctx context.Context, method, url, token string, body interface{}, wantStatus int, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	// Propagate the trace so the whole drill shows up as one trace in Jaeger
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: expected status %d, got %d: %s", method, url, wantStatus, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: failed to decode response: %w", method, url, err)
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
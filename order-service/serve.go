@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"order-svc/archive"
+	"order-svc/cache"
+	"order-svc/database"
+	"order-svc/expiry"
+	"order-svc/grpc"
+	"order-svc/handlers"
+	"order-svc/kafka"
+	"order-svc/lock"
+	"order-svc/middleware"
+	"order-svc/outbox"
+	order "order-svc/proto"
+	"order-svc/saga"
+	"order-svc/selftest"
+	"order-svc/webhook"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	grpcLib "google.golang.org/grpc"
+)
+
+// serve runs the REST and gRPC APIs together with the Kafka consumer until SIGINT/SIGTERM
+func serve(logger *zap.Logger) error {
+	if err := middleware.CheckJWTSecret(); err != nil {
+		logger.Fatal("Invalid auth configuration", zap.Error(err))
+	}
+
+	// Initialize database
+	db, err := database.InitDB(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Initialize Redis cache
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis", zap.Error(err))
+	}
+	defer redisClient.Close()
+
+	// Initialize Kafka producer
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
+	}
+	defer producer.Close()
+
+	// Order events go through the batched async producer when KAFKA_PRODUCER_MODE=async;
+	// the consumer's replies and dead letters always use the sync producer
+	events, err := kafka.InitEventProducer(db, producer, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka event producer", zap.Error(err))
+	}
+
+	// Initialize Kafka consumer
+	consumerGroup, err := kafka.InitConsumer(logger, false)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
+	}
+	defer consumerGroup.Close()
+
+	// Initialize OpenTelemetry
+	shutdown, err := middleware.InitTracing("order-service")
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer shutdown()
+
+	// Initialize gRPC client for Product Service
+	productClient, err := grpc.InitProductClient(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Product gRPC client", zap.Error(err))
+	}
+	defer productClient.Close()
+
+	// Initialize gRPC client for User Service (fraud pre-check)
+	userClient, err := grpc.InitUserClient(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize User gRPC client", zap.Error(err))
+	}
+	defer userClient.Close()
+
+	// Initialize gRPC client for Payment Service (GET /orders/:id?include=payment)
+	paymentClient, err := grpc.InitPaymentClient(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Payment gRPC client", zap.Error(err))
+	}
+	defer paymentClient.Close()
+
+	// Kafka shutdown context; the consumer confirms stock reservations for successful
+	// payments and releases them for failed ones
+	orderSaga := saga.New(db, redisClient, productClient, kafka.SagaPublisher(producer, logger), logger)
+	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	var consumerWG sync.WaitGroup
+	consumerWG.Add(1)
+	go func() {
+		defer consumerWG.Done()
+		if err := kafka.StartConsumer(consumerCtx, consumerGroup, orderSaga, producer, logger); err != nil {
+			logger.Error("Kafka consumer stopped", zap.Error(err))
+		}
+	}()
+
+	// Cancel orders whose payment never arrives, so their reserved stock is released.
+	// Stopped together with the consumer.
+	go expiry.NewExpirer(db, redisClient, productClient, events, lock.NewLocker(redisClient, logger), logger).Start(consumerCtx)
+
+	// Move old settled orders to the archive tables; reads by ID fall back to them
+	go archive.NewArchiver(db, logger).Start(consumerCtx)
+
+	// POST queued status changes to merchant webhooks, retrying failed deliveries
+	go webhook.NewDispatcher(db, logger).Start(consumerCtx)
+
+	// Retry saga steps that timed out: orphaned reservations, unconfirmed and unreleased stock
+	go orderSaga.Start(consumerCtx)
+
+	// Republish order events the async producer saved to the outbox
+	go outbox.NewRelay(db, producer, logger).Start(consumerCtx)
+
+	// Setup REST API with Gin
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// OpenTelemetry middleware must be first to extract trace context
+	router.Use(otelgin.Middleware("order-service"))
+	router.Use(middleware.LoggerMiddleware(logger))
+	router.Use(middleware.MetricsMiddleware())
+
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// Metrics endpoint
+	router.GET("/metrics", middleware.PrometheusHandler())
+
+	// Order endpoints
+	orderHandler := handlers.NewOrderHandler(db, redisClient, events, productClient, userClient, paymentClient, logger)
+	router.POST("/api/v1/orders", middleware.AuthMiddleware(), orderHandler.CreateOrder)
+	router.POST("/api/v1/orders/validate", middleware.AuthMiddleware(), orderHandler.ValidateOrder)
+	router.GET("/api/v1/orders/:id", middleware.OptionalAuthMiddleware(), orderHandler.GetOrder)
+	router.GET("/api/v1/orders/:id/history", middleware.AuthMiddleware(), orderHandler.GetOrderHistory)
+	router.GET("/api/v1/orders/:id/events", middleware.AuthMiddleware(), orderHandler.StreamOrderEvents)
+	router.POST("/api/v1/orders/:id/refund", middleware.AuthMiddleware(), orderHandler.RefundOrder)
+
+	// Admin endpoints, for users with the admin role
+	admin := router.Group("/admin", middleware.AuthMiddleware(), middleware.RequireRole(middleware.RoleAdmin))
+	selfTestHandler := handlers.NewSelfTestHandler(selftest.NewRunner(logger), logger)
+	admin.POST("/selftest", selfTestHandler.RunSelfTest)
+	admin.GET("/orders", orderHandler.SearchOrders)
+	admin.GET("/orders/export", orderHandler.ExportOrders)
+	admin.PATCH("/orders/:id/status", orderHandler.UpdateOrderStatus)
+	admin.POST("/orders/:id/confirm", orderHandler.ConfirmOrder)
+	outboxHandler := handlers.NewOutboxHandler(db, logger)
+	admin.GET("/outbox", outboxHandler.ListOutbox)
+	admin.POST("/outbox/requeue", outboxHandler.RequeueDeadOutbox)
+	admin.POST("/outbox/:id/requeue", outboxHandler.RequeueOutboxMessage)
+
+	// Webhook management, guarded like the admin endpoints
+	webhookHandler := handlers.NewWebhookHandler(db, logger)
+	webhooks := router.Group("/api/v1/webhooks", middleware.AuthMiddleware(), middleware.RequireRole(middleware.RoleAdmin))
+	webhooks.POST("", webhookHandler.CreateWebhook)
+	webhooks.GET("", webhookHandler.ListWebhooks)
+	webhooks.GET("/:id", webhookHandler.GetWebhook)
+	webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+	webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", webhookHandler.ListWebhookDeliveries)
+
+	// Start REST server
+	restSrv := &http.Server{
+		Addr:    ":8082",
+		Handler: router,
+	}
+
+	go func() {
+		if err := restSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start REST server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Order Service REST API started on :8082")
+
+	// Start gRPC server
+	grpcListener, err := net.Listen("tcp", ":50051")
+	if err != nil {
+		logger.Fatal("Failed to listen on gRPC port", zap.Error(err))
+	}
+
+	grpcServer := grpcLib.NewServer(
+		grpcLib.StatsHandler(otelgrpc.NewServerHandler()),
+		middleware.GRPCServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+		middleware.GRPCStreamServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+	)
+	orderService := handlers.NewOrderService(db, redisClient, events, productClient, userClient, logger)
+	order.RegisterOrderServiceServer(grpcServer, orderService)
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Order Service gRPC server started on :50051")
+
+	// Call graceful shutdown function
+	gracefulShutdown(restSrv, grpcServer, consumerCancel, &consumerWG, consumerGroup, producer, events, productClient, userClient, paymentClient, db, redisClient, shutdown, logger)
+	return nil
+}
+
+func gracefulShutdown(restSrv *http.Server, grpcServer *grpcLib.Server, consumerCancel context.CancelFunc, consumerWG *sync.WaitGroup, consumerGroup sarama.ConsumerGroup, producer sarama.SyncProducer, events kafka.Producer, productClient *grpc.ProductClient, userClient *grpc.UserClient, paymentClient *grpc.PaymentClient, db *sql.DB, redisClient *redis.Client, shutdownTracing func(), logger *zap.Logger) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutdown signal received. Exiting...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Stop REST server
+	if err := restSrv.Shutdown(ctx); err != nil {
+		logger.Error("REST server forced to shutdown", zap.Error(err))
+	} else {
+		logger.Info("REST server stopped gracefully")
+	}
+
+	// Stop gRPC server
+	grpcServer.GracefulStop()
+	logger.Info("gRPC server stopped gracefully")
+
+	// Stop Kafka consumer: it stops claiming messages, finishes the one in flight and
+	// commits its offsets before the group is closed, so nothing is lost or redelivered
+	consumerCancel() // signals the goroutine to exit
+	drained := make(chan struct{})
+	go func() {
+		consumerWG.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		logger.Info("Kafka consumer drained")
+	case <-ctx.Done():
+		logger.Warn("Timed out draining Kafka consumer, in-flight messages will be redelivered")
+	}
+	if err := consumerGroup.Close(); err != nil {
+		logger.Error("Failed to close Kafka consumer", zap.Error(err))
+	} else {
+		logger.Info("Kafka consumer stopped gracefully")
+	}
+
+	// Flush queued order events before the database they fail over to is closed
+	if events != producer {
+		if err := events.Close(); err != nil {
+			logger.Error("Failed to close async Kafka producer", zap.Error(err))
+		} else {
+			logger.Info("Async Kafka producer flushed and stopped gracefully")
+		}
+	}
+
+	// Close Kafka producer
+	if err := producer.Close(); err != nil {
+		logger.Error("Failed to close Kafka producer", zap.Error(err))
+	} else {
+		logger.Info("Kafka producer stopped gracefully")
+	}
+
+	// Close gRPC clients
+	if err := productClient.Close(); err != nil {
+		logger.Error("Failed to close Product gRPC client", zap.Error(err))
+	} else {
+		logger.Info("Product gRPC client closed gracefully")
+	}
+	if err := userClient.Close(); err != nil {
+		logger.Error("Failed to close User gRPC client", zap.Error(err))
+	} else {
+		logger.Info("User gRPC client closed gracefully")
+	}
+	if err := paymentClient.Close(); err != nil {
+		logger.Error("Failed to close Payment gRPC client", zap.Error(err))
+	} else {
+		logger.Info("Payment gRPC client closed gracefully")
+	}
+
+	// Close DB connection
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database", zap.Error(err))
+	} else {
+		logger.Info("Database connection closed gracefully")
+	}
+
+	// Close Redis cache
+	if err := redisClient.Close(); err != nil {
+		logger.Error("Failed to close Redis cache", zap.Error(err))
+	} else {
+		logger.Info("Redis cache closed gracefully")
+	}
+
+	// Shutdown tracing
+	shutdownTracing()
+	logger.Info("Order Service exited gracefully")
+}
@@ -0,0 +1,114 @@
+package startup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeClock makes sleep advance now instead of blocking, and records each sleep
+type fakeClock struct {
+	current time.Time
+	sleeps  []time.Duration
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+	clock := &fakeClock{current: time.Unix(0, 0)}
+	now = func() time.Time { return clock.current }
+	sleep = func(d time.Duration) {
+		clock.sleeps = append(clock.sleeps, d)
+		clock.current = clock.current.Add(d)
+	}
+	t.Cleanup(func() {
+		now = time.Now
+		sleep = time.Sleep
+	})
+	return clock
+}
+
+// failingCheck fails its first failures calls and succeeds after that
+func failingCheck(failures int) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= failures {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, &calls
+}
+
+func TestWait_SucceedsAfterRetries(t *testing.T) {
+	clock := useFakeClock(t)
+	check, calls := failingCheck(2)
+
+	if err := Wait(zap.NewNop(), "postgres", check); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *calls)
+	}
+	if want := []time.Duration{500 * time.Millisecond, time.Second}; !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("Expected backoffs %v, got %v", want, clock.sleeps)
+	}
+}
+
+func TestWait_GivesUpAtTimeout(t *testing.T) {
+	t.Setenv("STARTUP_TIMEOUT", "20s")
+	clock := useFakeClock(t)
+	check, calls := failingCheck(100)
+
+	err := Wait(zap.NewNop(), "kafka", check)
+	if err == nil {
+		t.Fatal("Expected an error once the timeout passed")
+	}
+
+	// The backoff doubles up to 5s, and no sleep would end past the 20s timeout
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	if !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("Expected backoffs %v, got %v", want, clock.sleeps)
+	}
+	if *calls != len(want)+1 {
+		t.Errorf("Expected %d attempts, got %d", len(want)+1, *calls)
+	}
+}
+
+func TestWait_FailFast(t *testing.T) {
+	t.Setenv("STARTUP_FAIL_FAST", "true")
+	clock := useFakeClock(t)
+	check, calls := failingCheck(1)
+
+	if err := Wait(zap.NewNop(), "redis", check); err == nil {
+		t.Fatal("Expected the first failure to be returned")
+	}
+	if *calls != 1 || len(clock.sleeps) != 0 {
+		t.Errorf("Expected a single attempt without waiting, got %d attempts and sleeps %v", *calls, clock.sleeps)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		failFast string
+		timeout  string
+		want     Config
+	}{
+		{"defaults", "", "", Config{FailFast: false, Timeout: 60 * time.Second}},
+		{"set", "true", "90s", Config{FailFast: true, Timeout: 90 * time.Second}},
+		{"invalid values", "maybe", "soon", Config{FailFast: false, Timeout: 60 * time.Second}},
+		{"non-positive timeout", "false", "-5s", Config{FailFast: false, Timeout: 60 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STARTUP_FAIL_FAST", tt.failFast)
+			t.Setenv("STARTUP_TIMEOUT", tt.timeout)
+			if got := Load(); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"order-svc/middleware"
+	"order-svc/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// interval is how often due deliveries are looked for
+	interval = getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second)
+	// timeout bounds each POST to a webhook
+	timeout = getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second)
+	// maxAttempts is how many times a delivery is tried before it is marked failed
+	maxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	// baseBackoff is the wait after the first failed attempt; it doubles per attempt
+	baseBackoff = getEnvDuration("WEBHOOK_RETRY_BACKOFF", 30*time.Second)
+	// batchSize bounds how many deliveries one poll claims
+	batchSize = getEnvInt("WEBHOOK_BATCH_SIZE", 20)
+)
+
+const maxBackoff = time.Hour
+
+// Dispatcher POSTs queued deliveries to their webhooks and retries failures with
+// exponential backoff
+type Dispatcher struct {
+	db     *sql.DB
+	client *http.Client
+	logger *zap.Logger
+}
+
+func NewDispatcher(db *sql.DB, logger *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: timeout},
+		logger: logger,
+	}
+}
+
+// Start delivers due webhooks on every tick until ctx is cancelled. Every replica may
+// run it: deliveries are claimed with FOR UPDATE SKIP LOCKED and leased, so replicas
+// never send the same attempt twice.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx); err != nil {
+				d.logger.Error("Failed to deliver webhooks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// claimedDelivery is a due delivery together with where and how to send it
+type claimedDelivery struct {
+	id       int
+	attempts int
+	payload  string
+	url      string
+	secret   string
+}
+
+// DeliverDue attempts one batch of due deliveries and returns how many were attempted
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("order-service").Start(ctx, "DeliverWebhooks")
+	defer span.End()
+
+	deliveries, err := d.claim(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err := d.deliver(ctx, delivery); err != nil {
+			span.RecordError(err)
+			return 0, err
+		}
+	}
+
+	span.SetAttributes(attribute.Int("webhooks.attempted", len(deliveries)))
+	return len(deliveries), nil
+}
+
+// claim leases a batch of due deliveries by pushing their next attempt past the time
+// the batch can take, so a replica that dies mid-batch leaves them to be retried
+func (d *Dispatcher) claim(ctx context.Context) ([]claimedDelivery, error) {
+	lease := time.Duration(batchSize) * timeout
+	rows, err := d.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $1)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.attempts, d.payload, w.url, w.secret`,
+		lease.Seconds(), models.WebhookDeliveryPending, batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []claimedDelivery
+	for rows.Next() {
+		var delivery claimedDelivery
+		if err := rows.Scan(&delivery.id, &delivery.attempts, &delivery.payload, &delivery.url, &delivery.secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// deliver POSTs one delivery and records the outcome. Only a failure to record it is
+// returned; the webhook's own failures are logged on the delivery.
+func (d *Dispatcher) deliver(ctx context.Context, delivery claimedDelivery) error {
+	attempts := delivery.attempts + 1
+	statusCode, sendErr := d.send(ctx, delivery)
+
+	var code sql.NullInt64
+	if statusCode != 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+
+	if sendErr == nil {
+		middleware.RecordWebhookDelivery("delivered")
+		_, err := d.db.ExecContext(ctx,
+			`UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, last_status_code = $3, last_error = NULL, next_attempt_at = NULL, delivered_at = CURRENT_TIMESTAMP
+			WHERE id = $4`,
+			models.WebhookDeliveryDelivered, attempts, code, delivery.id,
+		)
+		return err
+	}
+
+	status, result := models.WebhookDeliveryPending, "retrying"
+	var nextAttempt sql.NullTime
+	if attempts >= maxAttempts {
+		status, result = models.WebhookDeliveryFailed, "failed"
+	} else {
+		nextAttempt = sql.NullTime{Time: time.Now().Add(backoff(attempts)), Valid: true}
+	}
+	middleware.RecordWebhookDelivery(result)
+	d.logger.Warn("Webhook delivery failed",
+		zap.Int("delivery_id", delivery.id),
+		zap.String("url", delivery.url),
+		zap.Int("attempts", attempts),
+		zap.String("result", result),
+		zap.Error(sendErr),
+	)
+
+	_, err := d.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $6`,
+		status, attempts, code, sendErr.Error(), nextAttempt, delivery.id,
+	)
+	return err
+}
+
+// send POSTs the signed payload and returns the response status, if there was one
+func (d *Dispatcher) send(ctx context.Context, delivery claimedDelivery) (int, error) {
+	body := []byte(delivery.payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, strconv.Itoa(delivery.id))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait before retrying after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
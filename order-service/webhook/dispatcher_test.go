@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"order-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDeliverDue_SignsAndMarksDelivered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	payload := `{"event":"order.status_changed","order_id":7,"from_status":"pending","to_status":"paid"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if got := r.Header.Get(HeaderSignature); got != Sign("whsec_test", timestamp, body) {
+			t.Errorf("Unexpected signature %q", got)
+		}
+		if r.Header.Get(HeaderDeliveryID) != "3" {
+			t.Errorf("Expected delivery ID 3, got %q", r.Header.Get(HeaderDeliveryID))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	mock.ExpectQuery("UPDATE webhook_deliveries d SET next_attempt_at .* FOR UPDATE SKIP LOCKED").
+		WithArgs(sqlmock.AnyArg(), models.WebhookDeliveryPending, batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attempts", "payload", "url", "secret"}).
+			AddRow(3, 0, payload, server.URL, "whsec_test"))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$1").
+		WithArgs(models.WebhookDeliveryDelivered, 1, http.StatusNoContent, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	attempted, err := NewDispatcher(db, zaptest.NewLogger(t)).DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if attempted != 1 {
+		t.Errorf("Expected 1 attempted delivery, got %d", attempted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestDeliverDue_FailureIsRetriedThenGivesUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	// The first failure schedules a retry; the last allowed attempt marks it failed
+	mock.ExpectQuery("UPDATE webhook_deliveries d").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attempts", "payload", "url", "secret"}).
+			AddRow(3, 0, "{}", server.URL, "s").
+			AddRow(4, maxAttempts-1, "{}", server.URL, "s"))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$1").
+		WithArgs(models.WebhookDeliveryPending, 1, http.StatusInternalServerError, "webhook responded 500", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$1").
+		WithArgs(models.WebhookDeliveryFailed, maxAttempts, http.StatusInternalServerError, "webhook responded 500", nil, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := NewDispatcher(db, zaptest.NewLogger(t)).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestBackoff_DoublesUpToCap(t *testing.T) {
+	if got := backoff(1); got != baseBackoff {
+		t.Errorf("Expected %v after the first attempt, got %v", baseBackoff, got)
+	}
+	if got := backoff(3); got != 4*baseBackoff {
+		t.Errorf("Expected %v after the third attempt, got %v", 4*baseBackoff, got)
+	}
+	if got := backoff(50); got != maxBackoff {
+		t.Errorf("Expected the cap %v, got %v", maxBackoff, got)
+	}
+}
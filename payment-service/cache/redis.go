@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"payment-svc/startup"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// InitRedis connects to the Redis that holds the background jobs' distributed locks
+func InitRedis(logger *zap.Logger) (*redis.Client, error) {
+	host := getEnv("REDIS_HOST", "localhost")
+	port := getEnv("REDIS_PORT", "6379")
+	password := getEnv("REDIS_PASSWORD", "")
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Password: password,
+		DB:       0,
+	})
+
+	err := startup.Wait(logger, "redis", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return rdb.Ping(ctx).Err()
+	})
+	if err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	logger.Info("Redis connection established")
+	return rdb, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"payment-svc/database"
+	"payment-svc/gateway"
+	"payment-svc/grpc"
+	"payment-svc/kafka"
+	"payment-svc/middleware"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newConsumeCmd() *cobra.Command {
+	var replay bool
+	cmd := &cobra.Command{
+		Use:   "consume",
+		Short: "Run only the Kafka consumer that processes payments for new orders",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return consume(logger, replay)
+		}),
+	}
+	cmd.Flags().BoolVar(&replay, "replay", false, "consume every retained event in a throwaway group; orders already charged republish their outcome")
+	return cmd
+}
+
+// chargeOnceMigration is the schema version that allows one payment per order. Before
+// it, replaying order_created events charges every order again.
+const chargeOnceMigration = 5
+
+// checkReplaySchema refuses a replay until the schema charges each order at most once
+func checkReplaySchema(version uint, dirty bool) error {
+	if dirty {
+		return fmt.Errorf("refusing to replay: database schema version %d is dirty; fix it with migrate --force first", version)
+	}
+	if version < chargeOnceMigration {
+		return fmt.Errorf("refusing to replay: database schema version %d would charge orders again; run migrate to reach version %d first", version, chargeOnceMigration)
+	}
+	return nil
+}
+
+// consume runs the order event consumer without the HTTP endpoints until SIGINT/SIGTERM
+func consume(logger *zap.Logger, replay bool) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	if replay {
+		version, dirty, err := database.MigrationVersion(db)
+		if err != nil {
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if err := checkReplaySchema(version, dirty); err != nil {
+			return err
+		}
+	}
+
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	// A replay's throwaway group has no offsets worth committing in transactions
+	if !replay {
+		txnProducer, err := kafka.InitTransactionalProducer(logger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize transactional Kafka producer: %w", err)
+		}
+		if txnProducer != nil {
+			defer txnProducer.Close()
+			producer = txnProducer
+		}
+	}
+
+	merchants, err := gateway.NewMerchants()
+	if err != nil {
+		return fmt.Errorf("failed to initialize payment gateways: %w", err)
+	}
+	logger.Info("Using payment gateways",
+		zap.String("gateway", merchants.Default().Name()),
+		zap.Any("merchant_gateways", merchants.Configured()),
+	)
+
+	orderClient, err := grpc.InitOrderClient(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Order gRPC client: %w", err)
+	}
+	defer orderClient.Close()
+
+	consumerGroup, err := kafka.InitConsumer(logger, replay)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	shutdownTracing, err := middleware.InitTracing("payment-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = kafka.StartConsumer(ctx, consumerGroup, db, producer, merchants, orderClient, kafka.NewConsumerState(), logger)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
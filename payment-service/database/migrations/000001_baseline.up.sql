@@ -0,0 +1,20 @@
+-- Baseline: the schema as services created it on startup before versioned migrations.
+-- Every statement is idempotent, so databases created that way adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS payments (
+	id SERIAL PRIMARY KEY,
+	order_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	amount DECIMAL(10, 2) NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'pending',
+	transaction_id VARCHAR(255),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Data residency region, copied from the order
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS region VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_payments_region ON payments (region, created_at);
+
+-- Refunds look up the payment of an order
+CREATE INDEX IF NOT EXISTS idx_payments_order ON payments (order_id);
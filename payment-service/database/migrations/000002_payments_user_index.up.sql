@@ -0,0 +1,2 @@
+-- Backs GET /users/:id/payments, sorted the way results are returned
+CREATE INDEX IF NOT EXISTS idx_payments_user ON payments (user_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS failure_reason;
+ALTER TABLE payments DROP COLUMN IF EXISTS gateway_reference;
+ALTER TABLE payments DROP COLUMN IF EXISTS gateway;
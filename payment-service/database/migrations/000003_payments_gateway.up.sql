@@ -0,0 +1,5 @@
+-- The provider that handled each payment, its reference for the payment (kept for
+-- declines too) and why a payment failed
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway VARCHAR(32);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_reference VARCHAR(255);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS failure_reason TEXT;
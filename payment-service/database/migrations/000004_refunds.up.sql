@@ -0,0 +1,18 @@
+-- Every attempt to refund a payment through its gateway, with the outcome
+CREATE TABLE IF NOT EXISTS refunds (
+	id SERIAL PRIMARY KEY,
+	payment_id INTEGER NOT NULL REFERENCES payments (id),
+	order_id INTEGER NOT NULL,
+	amount DECIMAL(10, 2) NOT NULL,
+	status VARCHAR(50) NOT NULL DEFAULT 'pending',
+	gateway VARCHAR(32) NOT NULL,
+	gateway_reference VARCHAR(255),
+	failure_reason TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds (payment_id);
+
+-- A payment is refunded at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_payment_succeeded ON refunds (payment_id) WHERE status = 'succeeded';
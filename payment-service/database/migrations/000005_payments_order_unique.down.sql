@@ -0,0 +1,2 @@
+-- Payments marked duplicate stay marked; their original status was not kept
+DROP INDEX IF EXISTS idx_payments_order_unique;
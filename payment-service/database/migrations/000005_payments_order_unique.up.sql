@@ -0,0 +1,12 @@
+-- One payment per order. Replays used to charge orders again, so first mark the extra
+-- payments as duplicates, keeping the one that settled the order: its latest successful
+-- or refunded payment, otherwise its latest. Duplicates were charged at the gateway, so
+-- they and their refunds are kept for reconciliation and for refunding the customer.
+UPDATE payments SET status = 'duplicate', updated_at = CURRENT_TIMESTAMP
+WHERE id NOT IN (
+	SELECT DISTINCT ON (order_id) id
+	FROM payments
+	ORDER BY order_id, status IN ('success', 'refunded') DESC, id DESC
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_order_unique ON payments (order_id) WHERE status <> 'duplicate';
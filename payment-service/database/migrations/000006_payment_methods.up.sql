@@ -0,0 +1,23 @@
+-- Payment methods customers saved for paying orders. Only the gateway's token for the
+-- method and masked details for display are stored, never full card or account numbers.
+CREATE TABLE IF NOT EXISTS payment_methods (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	type VARCHAR(32) NOT NULL,
+	brand VARCHAR(32),
+	last4 VARCHAR(4) NOT NULL,
+	exp_month INTEGER,
+	exp_year INTEGER,
+	gateway_token VARCHAR(255) NOT NULL,
+	is_default BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_methods_user ON payment_methods (user_id);
+
+-- A user has at most one default payment method
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_methods_user_default ON payment_methods (user_id) WHERE is_default;
+
+-- The method a payment was charged with; deleting a method keeps its payments
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS payment_method_id INTEGER REFERENCES payment_methods (id) ON DELETE SET NULL;
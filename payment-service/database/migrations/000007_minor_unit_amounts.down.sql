@@ -0,0 +1,13 @@
+-- The decimal amounts have two places, so this assumes every amount is in a currency
+-- with cents
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS amount DECIMAL(10, 2);
+UPDATE refunds SET amount = amount_minor / 100.0;
+ALTER TABLE refunds ALTER COLUMN amount SET NOT NULL;
+ALTER TABLE refunds DROP COLUMN IF EXISTS amount_minor;
+ALTER TABLE refunds DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount DECIMAL(10, 2);
+UPDATE payments SET amount = amount_minor / 100.0;
+ALTER TABLE payments ALTER COLUMN amount SET NOT NULL;
+ALTER TABLE payments DROP COLUMN IF EXISTS amount_minor;
+ALTER TABLE payments DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,15 @@
+-- Amounts are stored as integer counts of their currency's minor unit, e.g. cents, so
+-- they add up exactly. Payments and refunds recorded so far were all in US dollars.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount_minor BIGINT;
+UPDATE payments SET amount_minor = ROUND(amount * 100) WHERE amount_minor IS NULL;
+ALTER TABLE payments ALTER COLUMN amount_minor SET NOT NULL;
+ALTER TABLE payments ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE payments DROP COLUMN IF EXISTS amount;
+
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS amount_minor BIGINT;
+UPDATE refunds SET amount_minor = ROUND(amount * 100) WHERE amount_minor IS NULL;
+ALTER TABLE refunds ALTER COLUMN amount_minor SET NOT NULL;
+ALTER TABLE refunds ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE refunds DROP COLUMN IF EXISTS amount;
@@ -0,0 +1,33 @@
+-- Double-entry ledger of the money payments and refunds move. Each journal, e.g.
+-- payment-5, is a set of entries whose debits equal its credits.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	id BIGSERIAL PRIMARY KEY,
+	journal_id VARCHAR(64) NOT NULL,
+	kind VARCHAR(20) NOT NULL,
+	account VARCHAR(40) NOT NULL,
+	direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+	amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+	currency CHAR(3) NOT NULL,
+	payment_id INTEGER REFERENCES payments (id),
+	refund_id INTEGER REFERENCES refunds (id),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_entries_journal ON ledger_entries (journal_id, account, direction);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_created_at ON ledger_entries (created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_payment ON ledger_entries (payment_id);
+
+-- Movements from before the ledger. Their gateway fees weren't known, so none are booked.
+INSERT INTO ledger_entries (journal_id, kind, account, direction, amount_minor, currency, payment_id, created_at)
+SELECT 'payment-' || p.id, 'payment', e.account, e.direction, p.amount_minor, p.currency, p.id, p.updated_at
+FROM payments p
+CROSS JOIN (VALUES ('gateway_receivable', 'debit'), ('revenue', 'credit')) AS e (account, direction)
+WHERE p.status IN ('success', 'refunded') AND p.amount_minor > 0
+ON CONFLICT (journal_id, account, direction) DO NOTHING;
+
+INSERT INTO ledger_entries (journal_id, kind, account, direction, amount_minor, currency, payment_id, refund_id, created_at)
+SELECT 'refund-' || r.id, 'refund', e.account, e.direction, r.amount_minor, r.currency, r.payment_id, r.id, r.updated_at
+FROM refunds r
+CROSS JOIN (VALUES ('refunds', 'debit'), ('gateway_receivable', 'credit')) AS e (account, direction)
+WHERE r.status = 'succeeded' AND r.amount_minor > 0
+ON CONFLICT (journal_id, account, direction) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Payments the fraud check flagged or declined, with the score and the signals behind it
+CREATE TABLE IF NOT EXISTS payment_fraud_checks (
+	payment_id INTEGER PRIMARY KEY REFERENCES payments (id),
+	score NUMERIC(4, 3) NOT NULL,
+	decision VARCHAR(10) NOT NULL CHECK (decision IN ('flag', 'decline')),
+	reasons TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_fraud_checks_created_at ON payment_fraud_checks (created_at);
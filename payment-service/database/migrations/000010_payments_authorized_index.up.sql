@@ -0,0 +1,2 @@
+-- Authorizations waiting for their order to be confirmed, swept once they expire
+CREATE INDEX IF NOT EXISTS idx_payments_authorized ON payments (gateway, updated_at) WHERE status = 'authorized';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payments_pending;
+ALTER TABLE payments DROP COLUMN IF EXISTS trace_id;
@@ -0,0 +1,6 @@
+-- Trace of the order_created event that recorded each payment, for investigating
+-- payments swept while still pending
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS trace_id VARCHAR(32);
+
+-- Pending payments, swept once they outlive PAYMENT_PENDING_TIMEOUT
+CREATE INDEX IF NOT EXISTS idx_payments_pending ON payments (updated_at) WHERE status = 'pending';
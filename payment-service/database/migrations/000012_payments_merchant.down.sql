@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payments_merchant;
+ALTER TABLE payments DROP COLUMN IF EXISTS merchant_id;
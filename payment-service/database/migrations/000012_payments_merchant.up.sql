@@ -0,0 +1,5 @@
+-- Merchant each payment is taken for; earlier payments belong to the default merchant
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS merchant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- A merchant's payments, newest first, for listing and settling them
+CREATE INDEX IF NOT EXISTS idx_payments_merchant ON payments (merchant_id, created_at);
@@ -0,0 +1,22 @@
+-- Consumed messages that couldn't be decoded or handled, kept as received so they can be
+-- replayed once the cause is fixed
+CREATE TABLE IF NOT EXISTS failed_events (
+	id SERIAL PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	partition INTEGER NOT NULL,
+	"offset" BIGINT NOT NULL,
+	message_key BYTEA,
+	payload BYTEA NOT NULL,
+	headers JSONB NOT NULL DEFAULT '{}',
+	event_type VARCHAR(64),
+	error TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'failed' CHECK (status IN ('failed', 'replayed')),
+	attempts INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	replayed_at TIMESTAMP,
+	-- A message failing again on redelivery updates its row
+	UNIQUE (topic, partition, "offset")
+);
+
+CREATE INDEX IF NOT EXISTS idx_failed_events_status ON failed_events (status, created_at);
@@ -0,0 +1,17 @@
+-- Each call to the gateway to authorize or capture a payment, retries included, with the
+-- gateway's response code and how long it took
+CREATE TABLE IF NOT EXISTS payment_attempts (
+	id SERIAL PRIMARY KEY,
+	payment_id INTEGER NOT NULL REFERENCES payments (id),
+	operation VARCHAR(20) NOT NULL CHECK (operation IN ('authorize', 'capture')),
+	gateway VARCHAR(50) NOT NULL,
+	outcome VARCHAR(20) NOT NULL CHECK (outcome IN ('approved', 'declined', 'pending', 'error')),
+	response_code VARCHAR(100),
+	gateway_reference VARCHAR(255),
+	-- The decline reason, or the error of a call the gateway didn't answer
+	error TEXT,
+	latency_ms INTEGER NOT NULL,
+	attempted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_attempts_payment ON payment_attempts (payment_id, attempted_at);
@@ -0,0 +1,177 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"payment-svc/models"
+)
+
+// Decision is what a fraud check makes of a payment
+type Decision string
+
+const (
+	// DecisionAllow charges the payment as usual
+	DecisionAllow Decision = "allow"
+	// DecisionFlag charges the payment but reports it for review
+	DecisionFlag Decision = "flag"
+	// DecisionDecline fails the payment without charging it
+	DecisionDecline Decision = "decline"
+)
+
+// Request is the payment about to be charged
+type Request struct {
+	PaymentID int
+	UserID    int
+	// AmountMinor is the total in Currency's minor unit
+	AmountMinor int64
+	Currency    string
+}
+
+// Assessment is a fraud check's verdict on a payment: its risk Score, from 0 to 1, the
+// Decision made of it and the signals that raised it, e.g. high_amount
+type Assessment struct {
+	Score    float64
+	Decision Decision
+	Reasons  []string
+}
+
+// FraudChecker scores a payment before it is charged. An error means the check couldn't
+// be made; the payment is left uncharged until it can.
+type FraudChecker interface {
+	Check(ctx context.Context, req Request) (Assessment, error)
+}
+
+// Config is how payments are scored and what is done with the scores
+type Config struct {
+	// AmountLimit is the amount, in major units, that scores 1 in currencies without
+	// their own limit in AmountLimits
+	AmountLimit  float64
+	AmountLimits map[string]float64
+	// VelocityLimit is the number of other payments by the user within VelocityWindow
+	// that scores 1
+	VelocityLimit  int
+	VelocityWindow time.Duration
+	// Payments scoring FlagScore or more are flagged, DeclineScore or more declined
+	FlagScore    float64
+	DeclineScore float64
+}
+
+// LoadConfig reads FRAUD_AMOUNT_LIMIT (default: 1000), FRAUD_AMOUNT_LIMITS, per-currency
+// limits such as "JPY:150000,KRW:1500000" (the default), FRAUD_VELOCITY_LIMIT (default:
+// 5), FRAUD_VELOCITY_WINDOW (default: 1h), FRAUD_FLAG_SCORE (default: 0.7) and
+// FRAUD_DECLINE_SCORE (default: 0.95). Invalid values fall back to the defaults.
+func LoadConfig() Config {
+	config := Config{
+		AmountLimit:    1000,
+		AmountLimits:   map[string]float64{"JPY": 150000, "KRW": 1500000},
+		VelocityLimit:  5,
+		VelocityWindow: time.Hour,
+		FlagScore:      0.7,
+		DeclineScore:   0.95,
+	}
+	if limit, err := strconv.ParseFloat(getEnv("FRAUD_AMOUNT_LIMIT"), 64); err == nil && limit > 0 {
+		config.AmountLimit = limit
+	}
+	if limits := getEnv("FRAUD_AMOUNT_LIMITS"); limits != "" {
+		config.AmountLimits = map[string]float64{}
+		for _, pair := range strings.Split(limits, ",") {
+			currency, value, _ := strings.Cut(strings.TrimSpace(pair), ":")
+			if limit, err := strconv.ParseFloat(value, 64); err == nil && limit > 0 && models.ValidCurrency(currency) {
+				config.AmountLimits[currency] = limit
+			}
+		}
+	}
+	if limit, err := strconv.Atoi(getEnv("FRAUD_VELOCITY_LIMIT")); err == nil && limit > 0 {
+		config.VelocityLimit = limit
+	}
+	if window, err := time.ParseDuration(getEnv("FRAUD_VELOCITY_WINDOW")); err == nil && window > 0 {
+		config.VelocityWindow = window
+	}
+	if score, err := strconv.ParseFloat(getEnv("FRAUD_FLAG_SCORE"), 64); err == nil && score > 0 {
+		config.FlagScore = score
+	}
+	if score, err := strconv.ParseFloat(getEnv("FRAUD_DECLINE_SCORE"), 64); err == nil && score > 0 {
+		config.DeclineScore = score
+	}
+	return config
+}
+
+// amountLimit returns the amount that scores 1 in currency
+func (c Config) amountLimit(currency string) float64 {
+	if limit, ok := c.AmountLimits[currency]; ok {
+		return limit
+	}
+	return c.AmountLimit
+}
+
+// Decide returns the decision for a payment scoring score. A threshold above 1 is never
+// reached, which turns that decision off.
+func (c Config) Decide(score float64) Decision {
+	switch {
+	case score >= c.DeclineScore:
+		return DecisionDecline
+	case score >= c.FlagScore:
+		return DecisionFlag
+	default:
+		return DecisionAllow
+	}
+}
+
+// RuleChecker scores payments by their amount and by how many payments the user made
+// shortly before, taking the higher of the two
+type RuleChecker struct {
+	db     *sql.DB
+	config Config
+}
+
+func NewRuleChecker(db *sql.DB, config Config) *RuleChecker {
+	return &RuleChecker{
+		db:     db,
+		config: config,
+	}
+}
+
+// Check scores the payment against the user's other payments within the velocity window
+func (r *RuleChecker) Check(ctx context.Context, req Request) (Assessment, error) {
+	var recent int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM payments
+		WHERE user_id = $1 AND id <> $2 AND created_at > CURRENT_TIMESTAMP - $3 * INTERVAL '1 second'`,
+		req.UserID, req.PaymentID, int64(r.config.VelocityWindow/time.Second),
+	).Scan(&recent)
+	if err != nil {
+		return Assessment{}, fmt.Errorf("failed to count recent payments: %w", err)
+	}
+
+	signals := []struct {
+		reason string
+		score  float64
+	}{
+		{"high_amount", models.FromMinorUnits(req.AmountMinor, req.Currency) / r.config.amountLimit(req.Currency)},
+		{"high_velocity", float64(recent) / float64(r.config.VelocityLimit)},
+	}
+
+	var assessment Assessment
+	for _, s := range signals {
+		score := math.Min(s.score, 1)
+		if score >= r.config.FlagScore {
+			assessment.Reasons = append(assessment.Reasons, s.reason)
+		}
+		assessment.Score = math.Max(assessment.Score, score)
+	}
+	// Stored with three decimals
+	assessment.Score = math.Round(assessment.Score*1000) / 1000
+	assessment.Decision = r.config.Decide(assessment.Score)
+	return assessment, nil
+}
+
+func getEnv(key string) string {
+	return strings.TrimSpace(os.Getenv(key))
+}
@@ -0,0 +1,91 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRuleChecker_Check(t *testing.T) {
+	config := Config{
+		AmountLimit:    1000,
+		AmountLimits:   map[string]float64{"JPY": 150000},
+		VelocityLimit:  5,
+		VelocityWindow: 30 * time.Minute,
+		FlagScore:      0.7,
+		DeclineScore:   0.95,
+	}
+
+	tests := []struct {
+		name        string
+		amountMinor int64
+		currency    string
+		recent      int
+		want        Assessment
+	}{
+		{"everyday order", 1998, "USD", 1, Assessment{Score: 0.2, Decision: DecisionAllow}},
+		{"large order", 80000, "USD", 0, Assessment{Score: 0.8, Decision: DecisionFlag, Reasons: []string{"high_amount"}}},
+		{"order above the limit", 250000, "USD", 0, Assessment{Score: 1, Decision: DecisionDecline, Reasons: []string{"high_amount"}}},
+		{"yen have their own limit", 1500, "JPY", 0, Assessment{Score: 0.01, Decision: DecisionAllow}},
+		{"many recent orders", 1998, "USD", 4, Assessment{Score: 0.8, Decision: DecisionFlag, Reasons: []string{"high_velocity"}}},
+		{"both", 90000, "EUR", 5, Assessment{Score: 1, Decision: DecisionDecline, Reasons: []string{"high_amount", "high_velocity"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE user_id = \\$1 AND id <> \\$2").
+				WithArgs(3, 5, 1800).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.recent))
+
+			got, err := NewRuleChecker(db, config).Check(context.Background(), Request{PaymentID: 5, UserID: 3, AmountMinor: tt.amountMinor, Currency: tt.currency})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got.Score != tt.want.Score || got.Decision != tt.want.Decision || len(got.Reasons) != len(tt.want.Reasons) {
+				t.Fatalf("Expected %+v, got %+v", tt.want, got)
+			}
+			for i := range got.Reasons {
+				if got.Reasons[i] != tt.want.Reasons[i] {
+					t.Errorf("Expected %+v, got %+v", tt.want, got)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_Decide_ThresholdAboveOneTurnsDecisionOff(t *testing.T) {
+	config := Config{FlagScore: 0.5, DeclineScore: 1.1}
+	if got := config.Decide(1); got != DecisionFlag {
+		t.Errorf("Expected a maximum score to be flagged only, got %s", got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("FRAUD_AMOUNT_LIMIT", "500")
+	t.Setenv("FRAUD_AMOUNT_LIMITS", "JPY:80000, kwd:100,KRW:-1")
+	t.Setenv("FRAUD_VELOCITY_LIMIT", "0")
+	t.Setenv("FRAUD_VELOCITY_WINDOW", "10m")
+	t.Setenv("FRAUD_FLAG_SCORE", "0.5")
+	t.Setenv("FRAUD_DECLINE_SCORE", "invalid")
+
+	config := LoadConfig()
+	if config.AmountLimit != 500 || config.VelocityLimit != 5 || config.VelocityWindow != 10*time.Minute ||
+		config.FlagScore != 0.5 || config.DeclineScore != 0.95 {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	// Malformed currencies and limits are dropped
+	if len(config.AmountLimits) != 1 || config.AmountLimits["JPY"] != 80000 {
+		t.Errorf("Unexpected amount limits %v", config.AmountLimits)
+	}
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Gateway is a payment service provider. A declined payment or refund is a Result, not
+// an error; an error means the provider couldn't be reached or rejected the request
+// itself. Errors matching ErrUnavailable are worth retrying; others will fail again.
+type Gateway interface {
+	// Name identifies the provider in logs and on spans
+	Name() string
+	// Authorize places a hold for the order's total
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	// Capture collects a hold placed by Authorize, given the authorization's
+	// TransactionID. Amounts are in the minor unit of the authorized currency.
+	Capture(ctx context.Context, transactionID string, amountMinor int64) (Result, error)
+	// Void releases a hold placed by Authorize that won't be captured, given the
+	// authorization's TransactionID
+	Void(ctx context.Context, transactionID string) (Result, error)
+	// Refund returns a captured amount, given the TransactionID Capture returned
+	Refund(ctx context.Context, transactionID string, amountMinor int64) (Result, error)
+}
+
+// AuthorizeRequest is the order being paid for
+type AuthorizeRequest struct {
+	OrderID int
+	UserID  int
+	// AmountMinor is the total in Currency's minor unit, e.g. cents
+	AmountMinor int64
+	// Currency is the ISO 4217 code of the order's currency
+	Currency string
+	// PaymentMethod is the provider's token for the customer's saved payment method;
+	// empty charges the provider's default
+	PaymentMethod string
+	// Metadata is the integrator's references on the order, passed on where the
+	// provider stores them
+	Metadata map[string]string
+	// IdempotencyKey identifies the payment, so the provider answers a repeated
+	// authorization with the first one's result instead of charging again
+	IdempotencyKey string
+}
+
+// Result is the provider's answer to a call
+type Result struct {
+	Approved bool
+	// Pending means the provider hasn't decided yet and will report the outcome to the
+	// gateway webhook later
+	Pending bool
+	// TransactionID is the provider's reference for the payment or refund
+	TransactionID string
+	// DeclineReason says why a call wasn't approved
+	DeclineReason string
+	// ResponseCode is the provider's own code for its answer, such as Stripe's payment
+	// intent status or decline code
+	ResponseCode string
+}
+
+// ErrorCode returns the provider's code for an error it answered a call with, or "" for
+// a call it never answered, such as one that timed out
+func ErrorCode(err error) string {
+	var coded interface{ ResponseCode() string }
+	if errors.As(err, &coded) {
+		return coded.ResponseCode()
+	}
+	return ""
+}
+
+var (
+	// ErrUnknownGateway is returned by New for an unsupported PAYMENT_GATEWAY
+	ErrUnknownGateway = errors.New("unknown payment gateway")
+	// ErrUnavailable matches errors from a provider that couldn't be reached, timed out
+	// or was overloaded, so the same call may succeed later
+	ErrUnavailable = errors.New("payment gateway unavailable")
+)
+
+// New returns the gateway PAYMENT_GATEWAY names: simulated, the default, approves
+// PAYMENT_SUCCESS_RATE of payments after a short delay (see LoadSimulatedConfig), and
+// stripe charges through Stripe's test mode with STRIPE_SECRET_KEY
+func New() (Gateway, error) {
+	return newGateway(getEnv("PAYMENT_GATEWAY", "simulated"), "")
+}
+
+// newGateway returns the gateway called name for merchantID, whose own Stripe keys are
+// used when set; "" uses the shared ones
+func newGateway(name, merchantID string) (Gateway, error) {
+	switch name {
+	case "simulated":
+		return NewSimulatedFromConfig(LoadSimulatedConfig()), nil
+	case "stripe":
+		s, err := NewStripe(merchantEnv("STRIPE_SECRET_KEY", merchantID))
+		if err != nil {
+			return nil, err
+		}
+		s.webhookSecret = merchantEnv("STRIPE_WEBHOOK_SECRET", merchantID)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%w %q, supported: simulated, stripe", ErrUnknownGateway, name)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"payment-svc/models"
+)
+
+// Merchants holds the gateway each merchant's payments go through. Merchants without a
+// gateway of their own share the default one.
+type Merchants struct {
+	fallback Gateway
+	gateways map[string]Gateway
+}
+
+// Single returns Merchants whose payments all go through gw
+func Single(gw Gateway) *Merchants {
+	return WithGateways(gw, nil)
+}
+
+// WithGateways returns Merchants whose payments go through their gateway in gateways,
+// keyed by merchant ID, or else fallback
+func WithGateways(fallback Gateway, gateways map[string]Gateway) *Merchants {
+	m := &Merchants{fallback: fallback, gateways: map[string]Gateway{}}
+	for merchantID, gw := range gateways {
+		m.gateways[merchantID] = gw
+	}
+	return m
+}
+
+// NewMerchants returns the default gateway PAYMENT_GATEWAY names (see New), and the
+// merchants' own gateways from PAYMENT_MERCHANT_GATEWAYS, a comma-separated list of
+// merchant:gateway pairs, e.g. "acme:stripe,globex:simulated". A merchant on stripe
+// authenticates with STRIPE_SECRET_KEY_<MERCHANT> and verifies its webhooks with
+// STRIPE_WEBHOOK_SECRET_<MERCHANT> when they are set, <MERCHANT> being its ID in upper
+// case with dashes as underscores, and with the shared keys otherwise.
+func NewMerchants() (*Merchants, error) {
+	fallback, err := New()
+	if err != nil {
+		return nil, err
+	}
+	m := Single(fallback)
+
+	for _, pair := range strings.Split(os.Getenv("PAYMENT_MERCHANT_GATEWAYS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		merchantID, name, ok := strings.Cut(pair, ":")
+		merchantID, name = strings.TrimSpace(merchantID), strings.TrimSpace(name)
+		if !ok || !models.ValidMerchantID(merchantID) {
+			return nil, fmt.Errorf("invalid PAYMENT_MERCHANT_GATEWAYS entry %q, want merchant:gateway", pair)
+		}
+		gw, err := newGateway(name, merchantID)
+		if err != nil {
+			return nil, fmt.Errorf("merchant %s: %w", merchantID, err)
+		}
+		m.gateways[merchantID] = gw
+	}
+	return m, nil
+}
+
+// For returns the gateway merchantID's payments go through
+func (m *Merchants) For(merchantID string) Gateway {
+	if gw, ok := m.gateways[merchantID]; ok {
+		return gw
+	}
+	return m.fallback
+}
+
+// Default returns the gateway of merchants without one of their own
+func (m *Merchants) Default() Gateway {
+	return m.fallback
+}
+
+// Configured returns the name of each merchant's own gateway, by merchant ID
+func (m *Merchants) Configured() map[string]string {
+	names := make(map[string]string, len(m.gateways))
+	for merchantID, gw := range m.gateways {
+		names[merchantID] = gw.Name()
+	}
+	return names
+}
+
+// Names returns the names of the gateways in use, sorted and without duplicates
+func (m *Merchants) Names() []string {
+	seen := map[string]bool{m.fallback.Name(): true}
+	for _, gw := range m.gateways {
+		seen[gw.Name()] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// merchantEnv returns merchantID's own value of the environment variable key, read
+// from key_<MERCHANT>, or else the shared one
+func merchantEnv(key, merchantID string) string {
+	if merchantID != "" {
+		suffix := strings.ToUpper(strings.ReplaceAll(merchantID, "-", "_"))
+		if value := os.Getenv(key + "_" + suffix); value != "" {
+			return value
+		}
+	}
+	return os.Getenv(key)
+}
@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewMerchants(t *testing.T) {
+	t.Setenv("PAYMENT_GATEWAY", "simulated")
+	t.Setenv("PAYMENT_MERCHANT_GATEWAYS", "acme-eu:stripe, globex:simulated")
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_shared")
+	t.Setenv("STRIPE_SECRET_KEY_ACME_EU", "sk_test_acme")
+	t.Setenv("STRIPE_WEBHOOK_SECRET_ACME_EU", "whsec_acme")
+
+	m, err := NewMerchants()
+	if err != nil {
+		t.Fatalf("Failed to load merchants: %v", err)
+	}
+	if m.For("default") != m.Default() || m.For("unconfigured") != m.Default() {
+		t.Error("Expected merchants without a gateway to share the default one")
+	}
+	stripe, ok := m.For("acme-eu").(*Stripe)
+	if !ok {
+		t.Fatalf("Expected acme-eu on stripe, got %T", m.For("acme-eu"))
+	}
+	if stripe.secretKey != "sk_test_acme" || stripe.webhookSecret != "whsec_acme" {
+		t.Errorf("Expected acme-eu's own keys, got %q and %q", stripe.secretKey, stripe.webhookSecret)
+	}
+	if want := map[string]string{"acme-eu": "stripe", "globex": "simulated"}; !reflect.DeepEqual(m.Configured(), want) {
+		t.Errorf("Expected %v configured, got %v", want, m.Configured())
+	}
+	if want := []string{"simulated", "stripe"}; !reflect.DeepEqual(m.Names(), want) {
+		t.Errorf("Expected gateways %v, got %v", want, m.Names())
+	}
+}
+
+func TestNewMerchants_SharedStripeKeys(t *testing.T) {
+	t.Setenv("PAYMENT_MERCHANT_GATEWAYS", "acme:stripe")
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_shared")
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_shared")
+
+	m, err := NewMerchants()
+	if err != nil {
+		t.Fatalf("Failed to load merchants: %v", err)
+	}
+	stripe := m.For("acme").(*Stripe)
+	if stripe.secretKey != "sk_test_shared" || stripe.webhookSecret != "whsec_shared" {
+		t.Errorf("Expected the shared keys, got %q and %q", stripe.secretKey, stripe.webhookSecret)
+	}
+}
+
+func TestNewMerchants_InvalidEntries(t *testing.T) {
+	for _, entries := range []string{"acme", "Acme:simulated", ":simulated", "acme:paypal"} {
+		t.Run(entries, func(t *testing.T) {
+			t.Setenv("PAYMENT_MERCHANT_GATEWAYS", entries)
+			if _, err := NewMerchants(); err == nil {
+				t.Errorf("Expected an error for %q", entries)
+			}
+		})
+	}
+}
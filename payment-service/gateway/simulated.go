@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Simulated approves a share of payments after a random processing delay, standing in
+// for a real provider in the demo. Captures, voids and refunds of approved payments
+// always succeed. In deterministic mode the outcome and delay depend only on the order,
+// so tests and demos see the same results on every run.
+type Simulated struct {
+	successRate        float64
+	minProcessingDelay time.Duration
+	maxAdditionalDelay time.Duration
+	deterministic      bool
+	seed               int64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// SimulatedConfig is how the simulated gateway decides payments
+type SimulatedConfig struct {
+	// SuccessRate is the share of payments approved, between 0 and 1. Deterministic mode
+	// ignores it.
+	SuccessRate float64
+	// Payments take between MinDelay and MaxDelay
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Seed makes the random draws repeatable; 0 seeds from the clock
+	Seed int64
+	// Deterministic declines amounts ending in .99, i.e. whose minor units end in 99,
+	// and approves the rest, with a delay drawn from the order ID and Seed
+	Deterministic bool
+}
+
+// DefaultSimulatedConfig approves 80% of payments after 200ms to 1s
+func DefaultSimulatedConfig() SimulatedConfig {
+	return SimulatedConfig{
+		SuccessRate: 0.8,
+		MinDelay:    200 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+}
+
+// LoadSimulatedConfig reads PAYMENT_SIMULATED_MODE (random, the default, or
+// deterministic), PAYMENT_SUCCESS_RATE, PAYMENT_SIMULATED_MIN_DELAY,
+// PAYMENT_SIMULATED_MAX_DELAY and PAYMENT_SIMULATED_SEED. Invalid values fall back to
+// the defaults.
+func LoadSimulatedConfig() SimulatedConfig {
+	config := DefaultSimulatedConfig()
+	config.SuccessRate = loadSuccessRate()
+	config.Deterministic = getEnv("PAYMENT_SIMULATED_MODE", "random") == "deterministic"
+	if delay, err := time.ParseDuration(getEnv("PAYMENT_SIMULATED_MIN_DELAY", "")); err == nil && delay >= 0 {
+		config.MinDelay = delay
+	}
+	if delay, err := time.ParseDuration(getEnv("PAYMENT_SIMULATED_MAX_DELAY", "")); err == nil && delay >= 0 {
+		config.MaxDelay = delay
+	}
+	if seed, err := strconv.ParseInt(getEnv("PAYMENT_SIMULATED_SEED", ""), 10, 64); err == nil {
+		config.Seed = seed
+	}
+	return config
+}
+
+// NewSimulated returns a simulated gateway approving successRate, between 0 and 1, of
+// payments
+func NewSimulated(successRate float64) *Simulated {
+	config := DefaultSimulatedConfig()
+	config.SuccessRate = successRate
+	return NewSimulatedFromConfig(config)
+}
+
+func NewSimulatedFromConfig(config SimulatedConfig) *Simulated {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Simulated{
+		successRate:        config.SuccessRate,
+		minProcessingDelay: config.MinDelay,
+		maxAdditionalDelay: max(config.MaxDelay-config.MinDelay, 0),
+		deterministic:      config.Deterministic,
+		seed:               config.Seed,
+		rng:                rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (s *Simulated) Name() string {
+	return "simulated"
+}
+
+func (s *Simulated) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	if req.AmountMinor <= 0 {
+		return Result{DeclineReason: "invalid payment amount", ResponseCode: "invalid_amount"}, nil
+	}
+
+	var delay time.Duration
+	var approved bool
+	var transactionID string
+	if s.deterministic {
+		delay, approved = s.decide(req)
+		transactionID = fmt.Sprintf("TXN-%d", req.OrderID)
+	} else {
+		delay, approved = s.roll()
+		transactionID = fmt.Sprintf("TXN-%d-%d", req.OrderID, time.Now().UnixNano())
+	}
+
+	select {
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	case <-time.After(delay):
+	}
+
+	if !approved {
+		return Result{DeclineReason: "payment authorization declined", ResponseCode: "declined"}, nil
+	}
+	return Result{
+		Approved:      true,
+		TransactionID: transactionID,
+		ResponseCode:  "approved",
+	}, nil
+}
+
+func (s *Simulated) Capture(_ context.Context, transactionID string, _ int64) (Result, error) {
+	return Result{Approved: true, TransactionID: transactionID, ResponseCode: "approved"}, nil
+}
+
+func (s *Simulated) Void(_ context.Context, transactionID string) (Result, error) {
+	return Result{Approved: true, TransactionID: transactionID}, nil
+}
+
+func (s *Simulated) Refund(_ context.Context, transactionID string, _ int64) (Result, error) {
+	return Result{Approved: true, TransactionID: "RFND-" + transactionID}, nil
+}
+
+// roll draws the processing delay and whether the payment is approved. The source isn't
+// safe for concurrent use, and partitions are consumed concurrently.
+func (s *Simulated) roll() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.minProcessingDelay
+	if s.maxAdditionalDelay > 0 {
+		delay += time.Duration(s.rng.Int63n(int64(s.maxAdditionalDelay)))
+	}
+	return delay, s.rng.Float64() <= s.successRate
+}
+
+// decide is the deterministic outcome for the order: declined if the amount ends in .99,
+// after a delay that depends only on the order ID and seed
+func (s *Simulated) decide(req AuthorizeRequest) (time.Duration, bool) {
+	delay := s.minProcessingDelay
+	if s.maxAdditionalDelay > 0 {
+		delay += time.Duration(rand.New(rand.NewSource(s.seed ^ int64(req.OrderID))).Int63n(int64(s.maxAdditionalDelay)))
+	}
+	return delay, req.AmountMinor%100 != 99
+}
+
+// loadSuccessRate reads PAYMENT_SUCCESS_RATE, clamped to [0, 1] (default: 0.8)
+func loadSuccessRate() float64 {
+	raw := getEnv("PAYMENT_SUCCESS_RATE", "")
+	if raw == "" {
+		return 0.8
+	}
+
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0.8
+	}
+
+	if rate < 0 {
+		return 0
+	}
+
+	if rate > 1 {
+		return 1
+	}
+
+	return rate
+}
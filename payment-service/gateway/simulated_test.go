@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newInstantSimulated(successRate float64) *Simulated {
+	s := NewSimulated(successRate)
+	s.minProcessingDelay, s.maxAdditionalDelay = 0, 0
+	return s
+}
+
+func TestSimulated_Authorize(t *testing.T) {
+	ctx := context.Background()
+	req := AuthorizeRequest{OrderID: 7, UserID: 3, AmountMinor: 1998, Currency: "USD"}
+
+	result, err := newInstantSimulated(1).Authorize(ctx, req)
+	if err != nil || !result.Approved || !strings.HasPrefix(result.TransactionID, "TXN-7-") {
+		t.Errorf("Expected an approved authorization, got %+v and %v", result, err)
+	}
+
+	result, err = newInstantSimulated(0).Authorize(ctx, req)
+	if err != nil || result.Approved || result.DeclineReason == "" {
+		t.Errorf("Expected a declined authorization with a reason, got %+v and %v", result, err)
+	}
+
+	req.AmountMinor = 0
+	result, err = newInstantSimulated(1).Authorize(ctx, req)
+	if err != nil || result.Approved || result.DeclineReason != "invalid payment amount" {
+		t.Errorf("Expected an invalid amount to be declined, got %+v and %v", result, err)
+	}
+}
+
+func TestSimulated_AuthorizeStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewSimulated(1).Authorize(ctx, AuthorizeRequest{OrderID: 7, AmountMinor: 1000, Currency: "USD"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("PAYMENT_GATEWAY", "")
+	gw, err := New()
+	if err != nil || gw.Name() != "simulated" {
+		t.Errorf("Expected the simulated gateway by default, got %v and %v", gw, err)
+	}
+
+	t.Setenv("PAYMENT_GATEWAY", "paypal")
+	if _, err := New(); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("Expected ErrUnknownGateway, got %v", err)
+	}
+}
+
+func TestSimulated_DeterministicMode(t *testing.T) {
+	config := SimulatedConfig{MinDelay: 0, MaxDelay: 0, Deterministic: true}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		amountMinor int64
+		currency    string
+		approved    bool
+	}{
+		{"ordinary amount", 1998, "USD", true},
+		{"amount ending in .99", 1999, "USD", false},
+		{"whole yen ending in 99", 1099, "JPY", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A success rate of 0 would decline everything in random mode
+			for run := 0; run < 2; run++ {
+				result, err := NewSimulatedFromConfig(config).Authorize(ctx, AuthorizeRequest{OrderID: 7, AmountMinor: tt.amountMinor, Currency: tt.currency})
+				if err != nil || result.Approved != tt.approved {
+					t.Fatalf("Expected approved=%v, got %+v and %v", tt.approved, result, err)
+				}
+				if tt.approved && result.TransactionID != "TXN-7" {
+					t.Errorf("Expected transaction ID TXN-7, got %q", result.TransactionID)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulated_DeterministicDelayDependsOnOrderAndSeed(t *testing.T) {
+	config := SimulatedConfig{MinDelay: 10 * time.Millisecond, MaxDelay: time.Second, Seed: 42, Deterministic: true}
+	req := AuthorizeRequest{OrderID: 7, AmountMinor: 1998, Currency: "USD"}
+
+	first, _ := NewSimulatedFromConfig(config).decide(req)
+	second, _ := NewSimulatedFromConfig(config).decide(req)
+	if first != second || first < config.MinDelay || first >= config.MaxDelay {
+		t.Errorf("Expected the same delay within range on every run, got %v and %v", first, second)
+	}
+}
+
+func TestLoadSimulatedConfig(t *testing.T) {
+	t.Setenv("PAYMENT_SIMULATED_MODE", "deterministic")
+	t.Setenv("PAYMENT_SUCCESS_RATE", "0.5")
+	t.Setenv("PAYMENT_SIMULATED_MIN_DELAY", "0s")
+	t.Setenv("PAYMENT_SIMULATED_MAX_DELAY", "invalid")
+	t.Setenv("PAYMENT_SIMULATED_SEED", "42")
+
+	config := LoadSimulatedConfig()
+	if !config.Deterministic || config.SuccessRate != 0.5 || config.MinDelay != 0 || config.MaxDelay != time.Second || config.Seed != 42 {
+		t.Errorf("Unexpected config %+v", config)
+	}
+}
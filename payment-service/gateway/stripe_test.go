@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestStripe returns a Stripe gateway talking to a fake API answering every request
+// with status and body
+func newTestStripe(t *testing.T, status int, body string) (*Stripe, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse form: %v", err)
+		}
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("STRIPE_API_URL", server.URL)
+	s, err := NewStripe("sk_test_123")
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	return s, &requests
+}
+
+func TestStripe_AuthorizeCreatesManualCaptureIntent(t *testing.T) {
+	s, requests := newTestStripe(t, http.StatusOK, `{"id":"pi_123","status":"requires_capture"}`)
+
+	result, err := s.Authorize(context.Background(), AuthorizeRequest{
+		OrderID:     7,
+		UserID:      3,
+		AmountMinor: 1999,
+		Currency:    "EUR",
+		Metadata:    map[string]string{"cart_id": "c-1", "order_id": "spoofed"},
+	})
+	if err != nil || !result.Approved || result.TransactionID != "pi_123" {
+		t.Fatalf("Expected an approved intent, got %+v and %v", result, err)
+	}
+
+	req := (*requests)[0]
+	if req.URL.Path != "/v1/payment_intents" {
+		t.Errorf("Expected a payment intent to be created, got %s", req.URL.Path)
+	}
+	if key, _, _ := req.BasicAuth(); key != "sk_test_123" {
+		t.Errorf("Expected the secret key as the username, got %q", key)
+	}
+	for field, want := range map[string]string{
+		"amount":             "1999",
+		"currency":           "eur",
+		"capture_method":     "manual",
+		"confirm":            "true",
+		"metadata[order_id]": "7",
+		"metadata[cart_id]":  "c-1",
+	} {
+		if got := req.PostForm.Get(field); got != want {
+			t.Errorf("Expected %s=%q, got %q", field, want, got)
+		}
+	}
+}
+
+func TestStripe_CardErrorIsDecline(t *testing.T) {
+	s, _ := newTestStripe(t, http.StatusPaymentRequired,
+		`{"error":{"type":"card_error","code":"card_declined","decline_code":"insufficient_funds","message":"Your card has insufficient funds.","payment_intent":{"id":"pi_456","status":"requires_payment_method"}}}`)
+
+	result, err := s.Authorize(context.Background(), AuthorizeRequest{OrderID: 7, AmountMinor: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Expected a decline, got error %v", err)
+	}
+	if result.Approved || result.DeclineReason != "insufficient_funds" || result.ResponseCode != "insufficient_funds" || result.TransactionID != "pi_456" {
+		t.Errorf("Expected a decline keeping the intent, got %+v", result)
+	}
+}
+
+func TestStripe_APIErrorIsError(t *testing.T) {
+	s, _ := newTestStripe(t, http.StatusInternalServerError,
+		`{"error":{"type":"api_error","message":"Something went wrong"}}`)
+
+	_, err := s.Capture(context.Background(), "pi_123", 1000)
+	var stripeErr *StripeError
+	if !errors.As(err, &stripeErr) || stripeErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected a StripeError with status 500, got %v", err)
+	}
+	if code := ErrorCode(err); code != "500" {
+		t.Errorf("Expected response code 500, got %q", code)
+	}
+}
+
+func TestStripe_Refund(t *testing.T) {
+	s, requests := newTestStripe(t, http.StatusOK, `{"id":"re_123","status":"succeeded"}`)
+
+	result, err := s.Refund(context.Background(), "pi_123", 1999)
+	if err != nil || !result.Approved || result.TransactionID != "re_123" {
+		t.Fatalf("Expected an approved refund, got %+v and %v", result, err)
+	}
+	req := (*requests)[0]
+	if req.PostForm.Get("payment_intent") != "pi_123" || req.Header.Get("Idempotency-Key") != "refund-pi_123" {
+		t.Errorf("Expected an idempotent refund of pi_123, got %v", req.PostForm)
+	}
+}
+
+func TestStripe_Void(t *testing.T) {
+	s, requests := newTestStripe(t, http.StatusOK, `{"id":"pi_123","status":"canceled"}`)
+
+	result, err := s.Void(context.Background(), "pi_123")
+	if err != nil || !result.Approved || result.TransactionID != "pi_123" {
+		t.Fatalf("Expected the hold to be released, got %+v and %v", result, err)
+	}
+	req := (*requests)[0]
+	if req.URL.Path != "/v1/payment_intents/pi_123/cancel" || req.Header.Get("Idempotency-Key") != "void-pi_123" {
+		t.Errorf("Expected an idempotent cancel of pi_123, got %s %v", req.URL.Path, req.Header)
+	}
+}
+
+func TestNewStripe_RejectsLiveKeys(t *testing.T) {
+	for _, key := range []string{"", "sk_live_123", "rk_test_123"} {
+		if _, err := NewStripe(key); !errors.Is(err, ErrLiveKey) {
+			t.Errorf("%q: expected ErrLiveKey, got %v", key, err)
+		}
+	}
+}
+
+func TestStripe_ErrorsWorthRetrying(t *testing.T) {
+	tests := []struct {
+		status    int
+		body      string
+		retryable bool
+	}{
+		{http.StatusInternalServerError, `{"error":{"type":"api_error","message":"Something went wrong"}}`, true},
+		{http.StatusTooManyRequests, `{"error":{"type":"invalid_request_error","code":"rate_limit"}}`, true},
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, true},
+		{http.StatusUnauthorized, `{"error":{"type":"invalid_request_error","message":"Invalid API Key provided"}}`, false},
+	}
+	for _, tt := range tests {
+		s, _ := newTestStripe(t, tt.status, tt.body)
+		_, err := s.Authorize(context.Background(), AuthorizeRequest{OrderID: 7, AmountMinor: 1000, Currency: "USD"})
+		if err == nil || errors.Is(err, ErrUnavailable) != tt.retryable {
+			t.Errorf("Status %d: expected an error with retryable=%v, got %v", tt.status, tt.retryable, err)
+		}
+	}
+
+	s, _ := newTestStripe(t, http.StatusOK, `{}`)
+	s.baseURL = "http://127.0.0.1:1"
+	if _, err := s.Refund(context.Background(), "pi_123", 1000); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected an unreachable API to be retryable, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// stripeSignature signs payload the way Stripe does at signedAt
+func stripeSignature(secret string, signedAt time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", signedAt.Unix(), payload)
+	return fmt.Sprintf("t=%d,v1=%s", signedAt.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestStripe_ParseWebhook(t *testing.T) {
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_123")
+	s, _ := newTestStripe(t, http.StatusOK, `{}`)
+
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.payment_failed","data":{"object":{"id":"pi_123","status":"requires_payment_method","metadata":{"order_id":"7"},"last_payment_error":{"type":"card_error","decline_code":"insufficient_funds"}}}}`)
+	header := http.Header{"Stripe-Signature": {stripeSignature("whsec_123", time.Now(), payload)}}
+
+	n, err := s.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Expected a verified event, got %v", err)
+	}
+	want := Notification{EventID: "evt_1", Kind: NotificationFailed, OrderID: 7, Reference: "pi_123", DeclineReason: "insufficient_funds"}
+	if n != want {
+		t.Errorf("Expected %+v, got %+v", want, n)
+	}
+
+	for name, header := range map[string]string{
+		"no signature":    "",
+		"wrong secret":    stripeSignature("whsec_other", time.Now(), payload),
+		"replayed":        stripeSignature("whsec_123", time.Now().Add(-time.Hour), payload),
+		"no v1 signature": fmt.Sprintf("t=%d", time.Now().Unix()),
+	} {
+		if _, err := s.ParseWebhook(payload, http.Header{"Stripe-Signature": {header}}); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestStripe_ParseWebhookNeedsSecret(t *testing.T) {
+	s, _ := newTestStripe(t, http.StatusOK, `{}`)
+
+	if _, err := s.ParseWebhook([]byte(`{}`), http.Header{}); !errors.Is(err, ErrWebhookNotConfigured) {
+		t.Errorf("Expected ErrWebhookNotConfigured, got %v", err)
+	}
+}
+
+func TestStripe_ProcessingIntentIsPending(t *testing.T) {
+	s, _ := newTestStripe(t, http.StatusOK, `{"id":"pi_123","status":"processing"}`)
+
+	result, err := s.Authorize(context.Background(), AuthorizeRequest{OrderID: 7, UserID: 3, AmountMinor: 1999, Currency: "USD"})
+	if err != nil || !result.Pending || result.Approved || result.TransactionID != "pi_123" {
+		t.Errorf("Expected a pending result, got %+v and %v", result, err)
+	}
+}
@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrInvalidSignature is returned by ParseWebhook for a call the provider didn't sign,
+	// or signed too long ago to rule out a replay
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrWebhookNotConfigured is returned by ParseWebhook when the provider's webhook
+	// secret isn't set, so no call can be verified
+	ErrWebhookNotConfigured = errors.New("webhook secret not configured")
+)
+
+// Webhooks is implemented by gateways that confirm payments out-of-band, by calling the
+// gateway webhook once they have decided
+type Webhooks interface {
+	// ParseWebhook verifies the provider's signature on a webhook call and returns what
+	// it reports
+	ParseWebhook(payload []byte, header http.Header) (Notification, error)
+}
+
+type NotificationKind string
+
+const (
+	// NotificationAuthorized reports a hold placed on the customer's funds, waiting to
+	// be captured
+	NotificationAuthorized NotificationKind = "authorized"
+	// NotificationCaptured reports a payment collected
+	NotificationCaptured NotificationKind = "captured"
+	// NotificationFailed reports a payment declined or cancelled
+	NotificationFailed NotificationKind = "failed"
+)
+
+// Notification is a payment outcome a provider sent to the webhook
+type Notification struct {
+	// EventID is the provider's ID for the webhook event
+	EventID string
+	// Kind is empty for events that aren't about a payment's outcome
+	Kind NotificationKind
+	// OrderID is the order the payment is for, 0 for payments this service didn't make
+	OrderID int
+	// Reference is the provider's ID for the payment, the TransactionID Authorize
+	// returned
+	Reference string
+	// DeclineReason says why a failed payment failed
+	DeclineReason string
+}
@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"payment-svc/middleware"
+	"payment-svc/proto/order"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOrderNotFound is returned by GetOrder when order-service has no order with the ID
+var ErrOrderNotFound = errors.New("order not found")
+
+// OrderClient reads orders from order-service, to check order_created events against
+// the orders they claim to be for
+type OrderClient struct {
+	conn   *grpc.ClientConn
+	client order.OrderServiceClient
+	logger *zap.Logger
+}
+
+func InitOrderClient(logger *zap.Logger) (*OrderClient, error) {
+	address := getEnv("ORDER_SERVICE_GRPC", "localhost:50051")
+
+	conn, err := grpc.NewClient(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(middleware.UnaryServiceTokenClientInterceptor(getEnv("GRPC_SERVICE_TOKEN", ""))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Order Service: %w", err)
+	}
+
+	return &OrderClient{
+		conn:   conn,
+		client: order.NewOrderServiceClient(conn),
+		logger: logger,
+	}, nil
+}
+
+// GetOrder looks up an order, live or archived. An unknown order is returned as
+// ErrOrderNotFound.
+func (oc *OrderClient) GetOrder(ctx context.Context, orderID int) (*order.GetOrderResponse, error) {
+	resp, err := oc.client.GetOrder(ctx, &order.GetOrderRequest{OrderId: int32(orderID)})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrOrderNotFound
+	}
+	return resp, err
+}
+
+func (oc *OrderClient) Close() error {
+	return oc.conn.Close()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"payment-svc/gateway"
+	"payment-svc/kafka"
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxFailedEvents bounds the failed events returned by one listing
+const maxFailedEvents = 100
+
+// failedEventColumns is the column list scanned by scanFailedEvent
+const failedEventColumns = `id, topic, partition, "offset", message_key, payload, headers, COALESCE(event_type, ''), error, status, attempts, created_at, updated_at, replayed_at`
+
+// FailedEventHandler lets admins inspect the messages the consumer couldn't handle and
+// replay them once the cause is fixed
+type FailedEventHandler struct {
+	db        *sql.DB
+	producer  sarama.SyncProducer
+	merchants *gateway.Merchants
+	orders    kafka.OrderLookup
+	logger    *zap.Logger
+}
+
+func NewFailedEventHandler(db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, orders kafka.OrderLookup, logger *zap.Logger) *FailedEventHandler {
+	return &FailedEventHandler{
+		db:        db,
+		producer:  producer,
+		merchants: merchants,
+		orders:    orders,
+		logger:    logger,
+	}
+}
+
+// ListFailedEvents returns the oldest failed events waiting to be replayed, or only
+// those with ?status=
+func (h *FailedEventHandler) ListFailedEvents(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "ListFailedEvents")
+	defer span.End()
+
+	status := models.FailedEventStatus(c.DefaultQuery("status", string(models.FailedEventFailed)))
+	if status != models.FailedEventFailed && status != models.FailedEventReplayed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT "+failedEventColumns+" FROM failed_events WHERE status = $1 ORDER BY id LIMIT $2",
+		status, maxFailedEvents,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list failed events", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	events := []models.FailedEvent{}
+	for rows.Next() {
+		var event models.FailedEvent
+		if err := scanFailedEvent(rows, &event); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan failed event", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list failed events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("failed_events.count", len(events)))
+	c.JSON(http.StatusOK, gin.H{"data": events})
+}
+
+// ReplayFailedEvent handles a failed event again and returns it marked replayed. Events
+// already replayed return 409; a replay that fails again returns 422 with the new error,
+// which is also stored on the event.
+func (h *FailedEventHandler) ReplayFailedEvent(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "ReplayFailedEvent")
+	defer span.End()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid failed event ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int64("failed_event.id", id))
+
+	err = kafka.ReplayFailedEvent(ctx, h.db, h.producer, h.merchants, h.orders, id, h.logger)
+	switch {
+	case errors.Is(err, kafka.ErrFailedEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed event not found"})
+		return
+	case errors.Is(err, kafka.ErrFailedEventReplayed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed event already replayed", "status": models.FailedEventReplayed})
+		return
+	case errors.Is(err, kafka.ErrReplayFailed):
+		span.RecordError(err)
+		h.logger.Warn("Failed event replay failed", zap.Int64("failed_event_id", id), zap.Error(err))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		span.RecordError(err)
+		h.logger.Error("Failed to replay failed event", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var event models.FailedEvent
+	if err := scanFailedEvent(h.db.QueryRowContext(ctx, "SELECT "+failedEventColumns+" FROM failed_events WHERE id = $1", id), &event); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get failed event", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Failed event replayed", zap.Int64("failed_event_id", id), zap.String("event_type", event.EventType))
+	c.JSON(http.StatusOK, event)
+}
+
+func scanFailedEvent(row rowScanner, event *models.FailedEvent) error {
+	var key sql.NullString
+	var payload, headers []byte
+	var replayedAt sql.NullTime
+	err := row.Scan(&event.ID, &event.Topic, &event.Partition, &event.Offset, &key, &payload, &headers, &event.EventType,
+		&event.Error, &event.Status, &event.Attempts, &event.CreatedAt, &event.UpdatedAt, &replayedAt)
+	if err != nil {
+		return err
+	}
+	event.Payload = string(payload)
+	if key.Valid {
+		event.Key = &key.String
+	}
+	if replayedAt.Valid {
+		event.ReplayedAt = &replayedAt.Time
+	}
+	return json.Unmarshal(headers, &event.Headers)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+var failedEventRowColumns = []string{"id", "topic", "partition", "offset", "message_key", "payload", "headers", "event_type", "error", "status", "attempts", "created_at", "updated_at", "replayed_at"}
+
+func setupFailedEventTest(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	handler := NewFailedEventHandler(db, nil, gateway.Single(gateway.NewSimulated(1)), nil, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/failed-events", handler.ListFailedEvents)
+	router.POST("/failed-events/:id/replay", handler.ReplayFailedEvent)
+	return mock, router
+}
+
+func TestFailedEventHandler_ListFailedEvents(t *testing.T) {
+	mock, router := setupFailedEventTest(t)
+
+	mock.ExpectQuery("SELECT .* FROM failed_events WHERE status = \\$1 ORDER BY id LIMIT \\$2").
+		WithArgs(models.FailedEventFailed, maxFailedEvents).
+		WillReturnRows(sqlmock.NewRows(failedEventRowColumns).
+			AddRow(3, "orders", 0, 42, nil, []byte(`{"event_type":`), []byte(`{"traceparent":"00-abc"}`), "", "failed to unmarshal event", "failed", 1, time.Now(), time.Now(), nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failed-events", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"payload":"{\"event_type\":"`) || !strings.Contains(w.Body.String(), `"error":"failed to unmarshal event"`) {
+		t.Errorf("Expected the stored payload and error, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failed-events?status=lost", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestFailedEventHandler_ReplayFailedEvent(t *testing.T) {
+	mock, router := setupFailedEventTest(t)
+
+	// An event this service doesn't handle is done as soon as it's read
+	mock.ExpectQuery("SELECT topic, .* FROM failed_events WHERE id = \\$1").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"topic", "partition", "offset", "message_key", "payload", "headers", "status"}).
+			AddRow("orders", 0, 42, nil, []byte(`{"event_type":"stock_release","order_id":7}`), []byte(`{}`), "failed"))
+	mock.ExpectExec("UPDATE failed_events SET status = \\$1, replayed_at = CURRENT_TIMESTAMP").
+		WithArgs(models.FailedEventReplayed, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, .* FROM failed_events WHERE id = \\$1").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(failedEventRowColumns).
+			AddRow(3, "orders", 0, 42, nil, []byte(`{"event_type":"stock_release","order_id":7}`), []byte(`{}`), "stock_release", "db down", "replayed", 1, time.Now(), time.Now(), time.Now()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/failed-events/3/replay", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"status":"replayed"`) {
+		t.Errorf("Expected the event marked replayed, got %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestFailedEventHandler_ReplayErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       *sqlmock.Rows
+		wantStatus int
+	}{
+		{"unknown", sqlmock.NewRows([]string{"topic"}), http.StatusNotFound},
+		{"already replayed", sqlmock.NewRows([]string{"topic", "partition", "offset", "message_key", "payload", "headers", "status"}).
+			AddRow("orders", 0, 42, nil, []byte(`{}`), []byte(`{}`), "replayed"), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, router := setupFailedEventTest(t)
+			mock.ExpectQuery("SELECT topic, .* FROM failed_events WHERE id = \\$1").
+				WithArgs(int64(3)).
+				WillReturnRows(tt.rows)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/failed-events/3/replay", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
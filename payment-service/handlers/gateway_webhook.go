@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+
+	"payment-svc/gateway"
+	"payment-svc/kafka"
+	"payment-svc/ledger"
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBody bounds the webhook payloads read; provider events are a few KB
+const maxWebhookBody = 1 << 20
+
+// GatewayWebhookHandler settles payments the gateway confirms out-of-band. Providers
+// retry calls that don't get a 2xx answer, so failures worth retrying answer 5xx and
+// everything else 2xx.
+type GatewayWebhookHandler struct {
+	db        *sql.DB
+	producer  sarama.SyncProducer
+	merchants *gateway.Merchants
+	fees      ledger.Fees
+	logger    *zap.Logger
+}
+
+func NewGatewayWebhookHandler(db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) *GatewayWebhookHandler {
+	return &GatewayWebhookHandler{
+		db:        db,
+		producer:  producer,
+		merchants: merchants,
+		fees:      ledger.LoadFees(),
+		logger:    logger,
+	}
+}
+
+// HandleWebhook verifies a provider's webhook call and applies the payment outcome it
+// reports to the order's pending payment, publishing payment_success or payment_failed.
+// An authorization is captured first, or with deferred capture left authorized for
+// order_confirmed. Outcomes for payments already settled publish the settled outcome
+// again under its original event ID. Each merchant's gateway calls the webhook under the
+// merchant's ID; calls without one are for the default merchant.
+func (h *GatewayWebhookHandler) HandleWebhook(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "HandleGatewayWebhook")
+	defer span.End()
+
+	merchantID := c.Param("merchant_id")
+	if merchantID == "" {
+		merchantID = models.DefaultMerchant
+	}
+	span.SetAttributes(attribute.String("merchant.id", merchantID))
+	gw := h.merchants.For(merchantID)
+
+	webhooks, ok := gw.(gateway.Webhooks)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Gateway " + gw.Name() + " doesn't send webhooks"})
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	n, err := webhooks.ParseWebhook(payload, c.Request.Header)
+	if errors.Is(err, gateway.ErrWebhookNotConfigured) {
+		h.logger.Error("Gateway webhook called but not configured", zap.String("gateway", gw.Name()), zap.String("merchant_id", merchantID))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook not configured"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		middleware.RecordGatewayWebhook("", "rejected")
+		h.logger.Warn("Rejected gateway webhook", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("webhook.event_id", n.EventID),
+		attribute.String("webhook.kind", string(n.Kind)),
+		attribute.Int("order.id", n.OrderID),
+		attribute.String("payment.gateway_reference", n.Reference),
+	)
+	if n.Kind == "" || n.OrderID == 0 {
+		// Events about other objects, or payments made outside this service
+		middleware.RecordGatewayWebhook(string(n.Kind), "ignored")
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	var payment models.Payment
+	err = scanPayment(h.db.QueryRowContext(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE order_id = $1 AND gateway = $2 AND merchant_id = $3 AND status <> 'duplicate'", n.OrderID, gw.Name(), merchantID,
+	), &payment)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && payment.GatewayReference != "" && payment.GatewayReference != n.Reference) {
+		middleware.RecordGatewayWebhook(string(n.Kind), "ignored")
+		h.logger.Warn("Gateway webhook for an unknown payment",
+			zap.String("event_id", n.EventID),
+			zap.Int("order_id", n.OrderID),
+			zap.String("gateway_reference", n.Reference),
+		)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	if err != nil {
+		h.fail(c, span, n, "Failed to get payment", err)
+		return
+	}
+	span.SetAttributes(attribute.Int("payment.id", payment.ID))
+
+	// An authorized payment waits for order_confirmed, unless the provider settles it. The
+	// provider's answer also resolves a payment left for review after timing out.
+	settleable := payment.Status == models.PaymentStatusPending || payment.Status == models.PaymentStatusPendingReview ||
+		(payment.Status == models.PaymentStatusAuthorized && n.Kind != gateway.NotificationAuthorized)
+	if settleable {
+		applied, err := h.settle(ctx, gw, &payment, n)
+		if err != nil {
+			h.fail(c, span, n, "Failed to settle payment", err)
+			return
+		}
+		if payment.Status == models.PaymentStatusPending || payment.Status == models.PaymentStatusAuthorized {
+			middleware.RecordGatewayWebhook(string(n.Kind), "pending")
+			c.JSON(http.StatusOK, gin.H{"status": string(payment.Status)})
+			return
+		}
+		if applied {
+			// The consumer started charging when it recorded the payment
+			middleware.RecordPaymentProcessed(string(payment.Status), gw.Name(), payment.Currency, payment.UpdatedAt.Sub(payment.CreatedAt), payment.Amount)
+		}
+	}
+
+	if err := kafka.PublishPaymentOutcome(ctx, h.db, h.producer, payment, nil, h.logger); err != nil {
+		// The payment is settled; the provider's retry publishes its outcome again
+		h.fail(c, span, n, "Failed to publish payment event", err)
+		return
+	}
+
+	middleware.RecordGatewayWebhook(string(n.Kind), "applied")
+	h.logger.Info("Gateway webhook applied",
+		zap.String("trace_id", middleware.GetTraceID(ctx)),
+		zap.String("event_id", n.EventID),
+		zap.Int("payment_id", payment.ID),
+		zap.String("status", string(payment.Status)),
+	)
+	c.JSON(http.StatusOK, gin.H{"status": string(payment.Status)})
+}
+
+// settle records the outcome n reports on the pending, authorized or timed-out payment,
+// capturing an authorization through gw first unless capture is deferred, and booking a
+// captured payment in the ledger, and reports whether it did. A payment settled
+// meanwhile, by the consumer or a concurrent call, is reloaded as it stands.
+func (h *GatewayWebhookHandler) settle(ctx context.Context, gw gateway.Gateway, payment *models.Payment, n gateway.Notification) (applied bool, err error) {
+	status, transactionID, failureReason := models.PaymentStatusPending, "", ""
+	switch n.Kind {
+	case gateway.NotificationAuthorized:
+		if kafka.DeferredCapture() {
+			status = models.PaymentStatusAuthorized
+			break
+		}
+		captured, err := kafka.WithAttempts(gw, h.db, payment.ID, h.logger).Capture(ctx, n.Reference, payment.AmountMinor)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case captured.Pending:
+		case captured.Approved:
+			status, transactionID = models.PaymentStatusSuccess, captured.TransactionID
+		default:
+			status, failureReason = models.PaymentStatusFailed, captured.DeclineReason
+		}
+	case gateway.NotificationCaptured:
+		status, transactionID = models.PaymentStatusSuccess, n.Reference
+	case gateway.NotificationFailed:
+		status, failureReason = models.PaymentStatusFailed, n.DeclineReason
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = scanPayment(tx.QueryRowContext(ctx,
+		`UPDATE payments
+		SET status = $1, transaction_id = NULLIF($2, ''), gateway_reference = $3, failure_reason = NULLIF($4, ''),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND status IN ('pending', 'authorized', 'pending_review')
+		RETURNING `+paymentColumns,
+		status, transactionID, n.Reference, failureReason, payment.ID,
+	), payment)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return false, scanPayment(h.db.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payments WHERE id = $1", payment.ID), payment)
+	}
+	if err != nil {
+		return false, err
+	}
+	if payment.Status == models.PaymentStatusSuccess {
+		if err := ledger.Record(ctx, tx, ledger.PaymentJournals(*payment, h.fees)...); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// fail logs err and answers 500, so the provider calls again later
+func (h *GatewayWebhookHandler) fail(c *gin.Context, span trace.Span, n gateway.Notification, msg string, err error) {
+	span.RecordError(err)
+	middleware.RecordGatewayWebhook(string(n.Kind), "failed")
+	h.logger.Error(msg,
+		zap.String("trace_id", middleware.GetTraceID(c.Request.Context())),
+		zap.String("event_id", n.EventID),
+		zap.Int("order_id", n.OrderID),
+		zap.Error(err),
+	)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+}
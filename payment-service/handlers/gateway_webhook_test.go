@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+// notifyingGateway reports the same notification for every webhook call, and accepts
+// only calls signed "valid"
+type notifyingGateway struct {
+	gateway.Simulated
+	notification gateway.Notification
+}
+
+func (g *notifyingGateway) Name() string { return "stripe" }
+
+func (g *notifyingGateway) ParseWebhook(_ []byte, header http.Header) (gateway.Notification, error) {
+	if header.Get("Stripe-Signature") != "valid" {
+		return gateway.Notification{}, gateway.ErrInvalidSignature
+	}
+	return g.notification, nil
+}
+
+func setupGatewayWebhookTest(t *testing.T, merchants *gateway.Merchants) (sqlmock.Sqlmock, *mocks.SyncProducer, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	producer := mocks.NewSyncProducer(t, nil)
+	t.Cleanup(func() { producer.Close() })
+
+	handler := NewGatewayWebhookHandler(db, producer, merchants, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhooks/gateway", handler.HandleWebhook)
+	router.POST("/webhooks/gateway/:merchant_id", handler.HandleWebhook)
+	return mock, producer, router
+}
+
+func webhookRequest(signature string) *http.Request {
+	return merchantWebhookRequest("", signature)
+}
+
+// merchantWebhookRequest is a webhook call to merchantID's route, or the default
+// merchant's for ""
+func merchantWebhookRequest(merchantID, signature string) *http.Request {
+	path := "/webhooks/gateway"
+	if merchantID != "" {
+		path += "/" + merchantID
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+	req.Header.Set("Stripe-Signature", signature)
+	return req
+}
+
+func TestGatewayWebhook_CaptureSettlesPendingPayment(t *testing.T) {
+	gw := &notifyingGateway{notification: gateway.Notification{
+		EventID: "evt_1", Kind: gateway.NotificationCaptured, OrderID: 7, Reference: "pi_123",
+	}}
+	mock, producer, router := setupGatewayWebhookTest(t, gateway.Single(gw))
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND gateway = \\$2").
+		WithArgs(7, "stripe", "default").
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusPending, "", "us-east-1", "default", "stripe", "pi_123", "", 0, time.Now(), time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE payments\\s+SET status = \\$1.* WHERE id = \\$5 AND status IN \\('pending', 'authorized', 'pending_review'\\)").
+		WithArgs(models.PaymentStatusSuccess, "pi_123", "pi_123", "", 5).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusSuccess, "pi_123", "us-east-1", "default", "stripe", "pi_123", "", 0, time.Now(), time.Now()))
+	// The payment, and the default 2.9% + 30 fee taken out of it
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(
+			"payment-5", "payment", "gateway_receivable", "debit", int64(1998), "USD", 5, 0,
+			"payment-5", "payment", "revenue", "credit", int64(1998), "USD", 5, 0,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(
+			"fee-5", "fee", "gateway_fees", "debit", int64(88), "USD", 5, 0,
+			"fee-5", "fee", "gateway_receivable", "credit", int64(88), "USD", 5, 0,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, &event)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, webhookRequest("valid"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if event.EventType != "payment_success" || event.EventID != "payment-5" || event.TransactionID != "pi_123" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestGatewayWebhook_RejectsUnsignedCalls(t *testing.T) {
+	_, _, router := setupGatewayWebhookTest(t, gateway.Single(&notifyingGateway{}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, webhookRequest("forged"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGatewayWebhook_UnknownPaymentIgnored(t *testing.T) {
+	gw := &notifyingGateway{notification: gateway.Notification{
+		EventID: "evt_2", Kind: gateway.NotificationFailed, OrderID: 8, Reference: "pi_456",
+	}}
+	mock, _, router := setupGatewayWebhookTest(t, gateway.Single(gw))
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND gateway = \\$2").
+		WithArgs(8, "stripe", "default").
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, webhookRequest("valid"))
+	// Answered 2xx so the provider stops calling
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestGatewayWebhook_GatewayWithoutWebhooks(t *testing.T) {
+	_, _, router := setupGatewayWebhookTest(t, gateway.Single(gateway.NewSimulated(1)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, webhookRequest("valid"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGatewayWebhook_MerchantRoute(t *testing.T) {
+	gw := &notifyingGateway{notification: gateway.Notification{
+		EventID: "evt_3", Kind: gateway.NotificationFailed, OrderID: 9, Reference: "pi_789",
+	}}
+	mock, _, router := setupGatewayWebhookTest(t, gateway.WithGateways(gateway.NewSimulated(1), map[string]gateway.Gateway{"acme": gw}))
+
+	// Only the merchant's own payments are looked up
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND gateway = \\$2 AND merchant_id = \\$3 AND status <> 'duplicate'").
+		WithArgs(9, "stripe", "acme").
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, merchantWebhookRequest("acme", "valid"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	// The default merchant's gateway doesn't take webhooks
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, webhookRequest("valid"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for the default merchant, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
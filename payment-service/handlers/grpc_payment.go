@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"payment-svc/models"
+	payment "payment-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PaymentService struct {
+	payment.UnimplementedPaymentServiceServer
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewPaymentService(db *sql.DB, logger *zap.Logger) *PaymentService {
+	return &PaymentService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetPaymentByOrder returns the payment of an order, so order-service can show it
+// alongside the order. Orders have one payment each; it is pending while the gateway
+// is charging it.
+func (s *PaymentService) GetPaymentByOrder(ctx context.Context, req *payment.GetPaymentByOrderRequest) (*payment.GetPaymentByOrderResponse, error) {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "GetPaymentByOrder_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("order.id", int(req.GetOrderId())))
+
+	resp := &payment.GetPaymentByOrderResponse{OrderId: req.GetOrderId()}
+	var amountMinor int64
+	var createdAt, updatedAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, amount_minor, currency, status, COALESCE(transaction_id, ''), COALESCE(region, ''), merchant_id, created_at, updated_at
+		FROM payments WHERE order_id = $1 AND status <> 'duplicate'`,
+		req.GetOrderId(),
+	).Scan(&resp.PaymentId, &resp.UserId, &amountMinor, &resp.Currency, &resp.Status, &resp.TransactionId, &resp.Region, &resp.MerchantId, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "payment not found")
+	}
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to load payment", zap.Int32("order_id", req.GetOrderId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to load payment")
+	}
+	resp.Amount = models.FromMinorUnits(amountMinor, resp.Currency)
+	resp.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	resp.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+
+	span.SetAttributes(attribute.String("payment.status", resp.Status))
+	return resp, nil
+}
+
+// ListPaymentsByUser returns a page of a user's payments, newest first, so order-service
+// can show payments next to a user's orders without a call per order
+func (s *PaymentService) ListPaymentsByUser(ctx context.Context, req *payment.ListPaymentsByUserRequest) (*payment.ListPaymentsByUserResponse, error) {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "ListPaymentsByUser_gRPC")
+	defer span.End()
+
+	page, limit := int(req.GetPage()), int(req.GetLimit())
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = defaultListPageSize
+	}
+	span.SetAttributes(
+		attribute.Int("user.id", int(req.GetUserId())),
+		attribute.Int("page", page),
+		attribute.Int("limit", limit),
+	)
+
+	resp := &payment.ListPaymentsByUserResponse{Payments: []*payment.Payment{}}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM payments WHERE user_id = $1", req.GetUserId()).Scan(&resp.Total); err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to count payments", zap.Int32("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list payments")
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3",
+		req.GetUserId(), limit, (page-1)*limit,
+	)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to list payments", zap.Int32("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list payments")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Payment
+		if err := scanPayment(rows, &p); err != nil {
+			span.RecordError(err)
+			s.logger.Error("Failed to scan payment", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to list payments")
+		}
+		resp.Payments = append(resp.Payments, &payment.Payment{
+			PaymentId:     int32(p.ID),
+			OrderId:       int32(p.OrderID),
+			UserId:        int32(p.UserID),
+			Amount:        p.Amount,
+			Currency:      p.Currency,
+			Status:        string(p.Status),
+			TransactionId: p.TransactionID,
+			Region:        p.Region,
+			MerchantId:    p.MerchantID,
+			CreatedAt:     p.CreatedAt.UTC().Format(time.RFC3339),
+			UpdatedAt:     p.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to list payments", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list payments")
+	}
+
+	span.SetAttributes(attribute.Int("payments.count", len(resp.Payments)))
+	return resp, nil
+}
+
+// ListPaymentAttempts returns each call made to the gateway to authorize or capture a
+// payment, oldest first, for debugging declined payments
+func (s *PaymentService) ListPaymentAttempts(ctx context.Context, req *payment.ListPaymentAttemptsRequest) (*payment.ListPaymentAttemptsResponse, error) {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "ListPaymentAttempts_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("payment.id", int(req.GetPaymentId())))
+
+	attempts, err := listPaymentAttempts(ctx, s.db, int(req.GetPaymentId()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "payment not found")
+	}
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to list payment attempts", zap.Int32("payment_id", req.GetPaymentId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list payment attempts")
+	}
+
+	resp := &payment.ListPaymentAttemptsResponse{Attempts: make([]*payment.PaymentAttempt, 0, len(attempts))}
+	for _, a := range attempts {
+		resp.Attempts = append(resp.Attempts, &payment.PaymentAttempt{
+			Id:               int32(a.ID),
+			PaymentId:        int32(a.PaymentID),
+			Operation:        a.Operation,
+			Gateway:          a.Gateway,
+			Outcome:          string(a.Outcome),
+			ResponseCode:     a.ResponseCode,
+			GatewayReference: a.GatewayReference,
+			Error:            a.Error,
+			LatencyMs:        a.LatencyMs,
+			AttemptedAt:      a.AttemptedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	span.SetAttributes(attribute.Int("payment_attempts.count", len(resp.Attempts)))
+	return resp, nil
+}
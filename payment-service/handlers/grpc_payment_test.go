@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-svc/models"
+	payment "payment-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPaymentService_ListPaymentsByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	service := NewPaymentService(db, zaptest.NewLogger(t))
+
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE user_id = \\$1").
+		WithArgs(int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT .* FROM payments WHERE user_id = \\$1 ORDER BY created_at DESC, id DESC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(int32(3), 2, 2).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(4, 7, 3, int64(1998), "USD", models.PaymentStatusSuccess, "TXN-7", "us-east-1", "default", "simulated", "TXN-7", "", 2, created, created))
+
+	resp, err := service.ListPaymentsByUser(context.Background(), &payment.ListPaymentsByUserRequest{UserId: 3, Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.GetTotal() != 3 || len(resp.GetPayments()) != 1 {
+		t.Fatalf("Unexpected page: %+v", resp)
+	}
+	got := resp.GetPayments()[0]
+	if got.GetPaymentId() != 4 || got.GetOrderId() != 7 || got.GetStatus() != "success" || got.GetCreatedAt() != "2026-10-01T12:00:00Z" {
+		t.Errorf("Unexpected payment: %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentService_ListPaymentAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	service := NewPaymentService(db, zaptest.NewLogger(t))
+
+	expectPaymentAttempts(mock)
+
+	resp, err := service.ListPaymentAttempts(context.Background(), &payment.ListPaymentAttemptsRequest{PaymentId: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(resp.GetAttempts()) != 2 {
+		t.Fatalf("Expected 2 attempts, got %+v", resp)
+	}
+	got := resp.GetAttempts()[1]
+	if got.GetOutcome() != "declined" || got.GetResponseCode() != "insufficient_funds" || got.GetLatencyMs() != 240 ||
+		got.GetAttemptedAt() != "2026-10-01T12:00:01Z" {
+		t.Errorf("Unexpected attempt: %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestListPaymentsByUserRequest_Validate(t *testing.T) {
+	for _, req := range []*payment.ListPaymentsByUserRequest{
+		{UserId: 0},
+		{UserId: 3, Page: -1},
+		{UserId: 3, Limit: 500},
+	} {
+		if err := req.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", req)
+		}
+	}
+	if err := (&payment.ListPaymentsByUserRequest{UserId: 3}).Validate(); err != nil {
+		t.Errorf("Expected the defaults to be accepted, got %v", err)
+	}
+}
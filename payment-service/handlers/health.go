@@ -3,6 +3,8 @@ package handlers
 import (
 	"net/http"
 
+	"payment-svc/kafka"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,3 +14,22 @@ func HealthCheck(c *gin.Context) {
 		"service": "payment-service",
 	})
 }
+
+// ReadinessCheck reports ready only while this instance is a member of its
+// consumer group and owns at least one partition.
+func ReadinessCheck(state *kafka.ConsumerState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := http.StatusOK
+		status := "ready"
+		if !state.Ready() {
+			code = http.StatusServiceUnavailable
+			status = "unavailable"
+		}
+
+		c.JSON(code, gin.H{
+			"status":   status,
+			"service":  "payment-service",
+			"consumer": state.Status(),
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"payment-svc/kafka"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,3 +29,18 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected body %s, got %s", expectedBody, w.Body.String())
 	}
 }
+
+func TestReadinessCheck_NotJoined(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ready", ReadinessCheck(kafka.NewConsumerState()))
+
+	req := httptest.NewRequest("GET", "/ready", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
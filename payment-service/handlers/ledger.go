@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// LedgerHandler reports the payment ledger for reconciliation against the gateways'
+// settlement reports
+type LedgerHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewLedgerHandler(db *sql.DB, logger *zap.Logger) *LedgerHandler {
+	return &LedgerHandler{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetLedger returns each account's debits, credits and balance per currency over the
+// entries matching the query, and whether they balance. Filtered on a merchant, it
+// covers the merchant's payments, settling what the gateways owe that merchant.
+// Filtered on a payment, it lists the payment's entries too.
+func (h *LedgerHandler) GetLedger(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "GetLedger")
+	defer span.End()
+
+	var query models.LedgerQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if !query.From.IsZero() {
+		where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		where("created_at < ?", query.To)
+	}
+	if query.Currency != "" {
+		where("currency = ?", strings.ToUpper(query.Currency))
+	}
+	if query.PaymentID != 0 {
+		span.SetAttributes(attribute.Int("payment.id", query.PaymentID))
+		where("payment_id = ?", query.PaymentID)
+	}
+	if query.MerchantID != "" {
+		span.SetAttributes(attribute.String("merchant.id", query.MerchantID))
+		where("payment_id IN (SELECT id FROM payments WHERE merchant_id = ?)", query.MerchantID)
+	}
+	filter := ""
+	if len(conditions) > 0 {
+		filter = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT account, currency,
+			COALESCE(SUM(amount_minor) FILTER (WHERE direction = 'debit'), 0),
+			COALESCE(SUM(amount_minor) FILTER (WHERE direction = 'credit'), 0)
+		FROM ledger_entries`+filter+`
+		GROUP BY account, currency
+		ORDER BY currency, account`,
+		args...,
+	)
+	if err != nil {
+		h.internalError(c, span, "Failed to sum ledger entries", err)
+		return
+	}
+	defer rows.Close()
+
+	report := models.LedgerReport{Accounts: []models.LedgerAccountBalance{}, Balanced: true}
+	// Debits less credits per currency, which the journals keep at zero
+	net := map[string]int64{}
+	for rows.Next() {
+		var a models.LedgerAccountBalance
+		if err := rows.Scan(&a.Account, &a.Currency, &a.DebitMinor, &a.CreditMinor); err != nil {
+			h.internalError(c, span, "Failed to scan ledger balance", err)
+			return
+		}
+		a.BalanceMinor = a.DebitMinor - a.CreditMinor
+		net[a.Currency] += a.BalanceMinor
+		report.Accounts = append(report.Accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		h.internalError(c, span, "Failed to sum ledger entries", err)
+		return
+	}
+	for currency, balance := range net {
+		if balance != 0 {
+			report.Balanced = false
+			h.logger.Error("Ledger doesn't balance",
+				zap.String("trace_id", middleware.GetTraceID(ctx)),
+				zap.String("currency", currency),
+				zap.Int64("balance_minor", balance),
+			)
+		}
+	}
+
+	if query.PaymentID != 0 {
+		if report.Entries, err = h.listEntries(ctx, filter, args); err != nil {
+			h.internalError(c, span, "Failed to list ledger entries", err)
+			return
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("ledger.accounts", len(report.Accounts)),
+		attribute.Bool("ledger.balanced", report.Balanced),
+	)
+	c.JSON(http.StatusOK, report)
+}
+
+// listEntries returns the entries matching filter, oldest first
+func (h *LedgerHandler) listEntries(ctx context.Context, filter string, args []interface{}) ([]models.LedgerEntry, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT id, journal_id, kind, account, direction, amount_minor, currency,
+			COALESCE(payment_id, 0), COALESCE(refund_id, 0), created_at
+		FROM ledger_entries`+filter+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.LedgerEntry{}
+	for rows.Next() {
+		var e models.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.JournalID, &e.Kind, &e.Account, &e.Direction, &e.AmountMinor, &e.Currency,
+			&e.PaymentID, &e.RefundID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// internalError logs err and answers 500
+func (h *LedgerHandler) internalError(c *gin.Context, span trace.Span, msg string, err error) {
+	span.RecordError(err)
+	h.logger.Error(msg, zap.String("trace_id", middleware.GetTraceID(c.Request.Context())), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+var ledgerBalanceColumns = []string{"account", "currency", "debit_minor", "credit_minor"}
+
+func setupLedgerTest(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	handler := NewLedgerHandler(db, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ledger", handler.GetLedger)
+	return mock, router
+}
+
+func getLedger(t *testing.T, router *gin.Engine, url string) models.LedgerReport {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var report models.LedgerReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	return report
+}
+
+func TestLedgerHandler_GetLedger(t *testing.T) {
+	mock, router := setupLedgerTest(t)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT account, currency, .* FROM ledger_entries WHERE created_at >= \\$1 AND created_at < \\$2 AND currency = \\$3 GROUP BY account, currency").
+		WithArgs(from, to, "USD").
+		WillReturnRows(sqlmock.NewRows(ledgerBalanceColumns).
+			AddRow("gateway_fees", "USD", int64(88), int64(0)).
+			AddRow("gateway_receivable", "USD", int64(1998), int64(1086)).
+			AddRow("refunds", "USD", int64(998), int64(0)).
+			AddRow("revenue", "USD", int64(0), int64(1998)))
+
+	report := getLedger(t, router, "/ledger?from=2024-03-01T00:00:00Z&to=2024-04-01T00:00:00Z&currency=usd")
+	if !report.Balanced || len(report.Accounts) != 4 || report.Entries != nil {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if receivable := report.Accounts[1]; receivable.Account != "gateway_receivable" || receivable.BalanceMinor != 912 {
+		t.Errorf("Unexpected receivable balance: %+v", receivable)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestLedgerHandler_GetLedger_Payment(t *testing.T) {
+	mock, router := setupLedgerTest(t)
+
+	mock.ExpectQuery("SELECT account, currency, .* FROM ledger_entries WHERE payment_id = \\$1 GROUP BY account, currency").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(ledgerBalanceColumns).
+			AddRow("gateway_receivable", "USD", int64(1998), int64(0)).
+			AddRow("revenue", "USD", int64(0), int64(1998)))
+	mock.ExpectQuery("SELECT id, journal_id, .* FROM ledger_entries WHERE payment_id = \\$1 ORDER BY created_at, id").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "journal_id", "kind", "account", "direction", "amount_minor", "currency", "payment_id", "refund_id", "created_at"}).
+			AddRow(1, "payment-5", "payment", "gateway_receivable", "debit", int64(1998), "USD", 5, 0, time.Now()).
+			AddRow(2, "payment-5", "payment", "revenue", "credit", int64(1998), "USD", 5, 0, time.Now()))
+
+	report := getLedger(t, router, "/ledger?payment_id=5")
+	if !report.Balanced || len(report.Entries) != 2 || report.Entries[0].JournalID != "payment-5" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestLedgerHandler_GetLedger_Unbalanced(t *testing.T) {
+	mock, router := setupLedgerTest(t)
+
+	mock.ExpectQuery("SELECT account, currency, .* FROM ledger_entries GROUP BY account, currency").
+		WillReturnRows(sqlmock.NewRows(ledgerBalanceColumns).
+			AddRow("gateway_receivable", "EUR", int64(500), int64(0)).
+			AddRow("revenue", "EUR", int64(0), int64(500)).
+			AddRow("gateway_receivable", "USD", int64(1998), int64(0)))
+
+	if report := getLedger(t, router, "/ledger"); report.Balanced {
+		t.Errorf("Expected the USD ledger to be reported unbalanced: %+v", report)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestLedgerHandler_GetLedger_InvalidQuery(t *testing.T) {
+	_, router := setupLedgerTest(t)
+
+	for _, url := range []string{
+		"/ledger?from=yesterday",
+		"/ledger?from=2024-04-01T00:00:00Z&to=2024-03-01T00:00:00Z",
+		"/ledger?currency=US",
+		"/ledger?payment_id=0x5",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", url, http.StatusBadRequest, w.Code)
+		}
+	}
+}
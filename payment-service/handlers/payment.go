@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	paymentColumns = "id, order_id, user_id, amount_minor, currency, status, COALESCE(transaction_id, ''), COALESCE(region, ''), merchant_id, " +
+		"COALESCE(gateway, ''), COALESCE(gateway_reference, ''), COALESCE(failure_reason, ''), COALESCE(payment_method_id, 0), created_at, updated_at"
+
+	defaultListPageSize = 20
+)
+
+// PaymentHandler serves payment records over REST for support staff and other services
+type PaymentHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewPaymentHandler(db *sql.DB, logger *zap.Logger) *PaymentHandler {
+	return &PaymentHandler{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetPayment returns a single payment by ID
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "GetPayment")
+	defer span.End()
+
+	paymentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("payment.id", paymentID))
+
+	var payment models.Payment
+	err = scanPayment(h.db.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payments WHERE id = $1", paymentID), &payment)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get payment", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// ListPayments returns a page of payments, newest first, optionally only the one of the
+// order in order_id, or only those of the merchant in merchant_id
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	var query models.ListPaymentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var conditions []string
+	args := []interface{}{}
+	if query.OrderID != 0 {
+		args = append(args, query.OrderID)
+		conditions = append(conditions, "order_id = $"+strconv.Itoa(len(args)))
+	}
+	if query.MerchantID != "" {
+		args = append(args, query.MerchantID)
+		conditions = append(conditions, "merchant_id = $"+strconv.Itoa(len(args)))
+	}
+	if query.Status != "" {
+		args = append(args, query.Status)
+		conditions = append(conditions, "status = $"+strconv.Itoa(len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	h.listPayments(c, "ListPayments", query, where, args)
+}
+
+// ListUserPayments returns a page of a user's payments, newest first
+func (h *PaymentHandler) ListUserPayments(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var query models.ListPaymentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Only GET /payments filters by order, merchant and status
+	query.OrderID, query.MerchantID, query.Status = 0, "", ""
+
+	h.listPayments(c, "ListUserPayments", query, " WHERE user_id = $1", []interface{}{userID})
+}
+
+// listPayments writes the page of payments matching where, whose placeholders are
+// numbered from $1 and bound to args
+func (h *PaymentHandler) listPayments(c *gin.Context, spanName string, query models.ListPaymentsQuery, where string, args []interface{}) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), spanName)
+	defer span.End()
+
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListPageSize
+	}
+
+	span.SetAttributes(
+		attribute.Int("page", query.Page),
+		attribute.Int("limit", query.Limit),
+	)
+
+	var total int
+	if err := h.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM payments"+where, args...).Scan(&total); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to count payments", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	listQuery := "SELECT " + paymentColumns + " FROM payments" + where +
+		" ORDER BY created_at DESC, id DESC" +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	listArgs := append(args, query.Limit, (query.Page-1)*query.Limit)
+
+	rows, err := h.db.QueryContext(ctx, listQuery, listArgs...)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list payments", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	payments := []models.Payment{}
+	for rows.Next() {
+		var p models.Payment
+		if err := scanPayment(rows, &p); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan payment", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		payments = append(payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list payments", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("payments.count", len(payments)),
+		attribute.Int("payments.total", total),
+	)
+	c.JSON(http.StatusOK, models.PaymentListResponse{
+		Data:       payments,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Total:      total,
+		TotalPages: (total + query.Limit - 1) / query.Limit,
+	})
+}
+
+// ListPaymentAttempts returns each call made to the gateway to authorize or capture a
+// payment, oldest first, with the gateway's response code and latency, for working out
+// why it was declined
+func (h *PaymentHandler) ListPaymentAttempts(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "ListPaymentAttempts")
+	defer span.End()
+
+	paymentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("payment.id", paymentID))
+
+	attempts, err := listPaymentAttempts(ctx, h.db, paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list payment attempts", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("payment_attempts.count", len(attempts)))
+	c.JSON(http.StatusOK, gin.H{"data": attempts})
+}
+
+// listPaymentAttempts returns the payment's attempts oldest first, or sql.ErrNoRows for
+// an unknown payment
+func listPaymentAttempts(ctx context.Context, db *sql.DB, paymentID int) ([]models.PaymentAttempt, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM payments WHERE id = $1)", paymentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, payment_id, operation, gateway, outcome, COALESCE(response_code, ''), COALESCE(gateway_reference, ''),
+			COALESCE(error, ''), latency_ms, attempted_at
+		FROM payment_attempts WHERE payment_id = $1 ORDER BY attempted_at, id`,
+		paymentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.PaymentAttempt{}
+	for rows.Next() {
+		var a models.PaymentAttempt
+		if err := rows.Scan(&a.ID, &a.PaymentID, &a.Operation, &a.Gateway, &a.Outcome, &a.ResponseCode, &a.GatewayReference,
+			&a.Error, &a.LatencyMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment scans a row selected with paymentColumns
+func scanPayment(row rowScanner, p *models.Payment) error {
+	var amountMinor int64
+	var currency string
+	if err := row.Scan(&p.ID, &p.OrderID, &p.UserID, &amountMinor, &currency, &p.Status, &p.TransactionID, &p.Region, &p.MerchantID, &p.Gateway, &p.GatewayReference, &p.FailureReason, &p.PaymentMethodID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	p.SetAmount(amountMinor, currency)
+	return nil
+}
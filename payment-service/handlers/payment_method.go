@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// paymentMethodColumns is the column list scanned by scanPaymentMethod
+const paymentMethodColumns = "id, user_id, type, COALESCE(brand, ''), last4, COALESCE(exp_month, 0), COALESCE(exp_year, 0), " +
+	"gateway_token, is_default, created_at, updated_at"
+
+// PaymentMethodHandler manages the payment methods customers save for their orders.
+// Every route acts on the methods of the user in the bearer token.
+type PaymentMethodHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewPaymentMethodHandler(db *sql.DB, logger *zap.Logger) *PaymentMethodHandler {
+	return &PaymentMethodHandler{db: db, logger: logger}
+}
+
+// CreatePaymentMethod saves a payment method. It becomes the default when asked to or
+// when it's the user's first.
+func (h *PaymentMethodHandler) CreatePaymentMethod(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "CreatePaymentMethod")
+	defer span.End()
+
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePaymentMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var method models.PaymentMethod
+	err := h.inTx(ctx, func(tx *sql.Tx) error {
+		makeDefault := req.IsDefault
+		if !makeDefault {
+			if err := tx.QueryRowContext(ctx,
+				"SELECT NOT EXISTS (SELECT 1 FROM payment_methods WHERE user_id = $1)", userID,
+			).Scan(&makeDefault); err != nil {
+				return err
+			}
+		}
+		if makeDefault {
+			if err := clearDefault(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		return scanPaymentMethod(tx.QueryRowContext(ctx,
+			`INSERT INTO payment_methods (user_id, type, brand, last4, exp_month, exp_year, gateway_token, is_default)
+			VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, 0), NULLIF($6, 0), $7, $8)
+			RETURNING `+paymentMethodColumns,
+			userID, req.Type, req.Brand, req.Last4, req.ExpMonth, req.ExpYear, req.GatewayToken, makeDefault,
+		), &method)
+	})
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to create payment method", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	span.SetAttributes(attribute.Int("payment_method.id", method.ID))
+	h.logger.Info("Payment method created",
+		zap.Int("payment_method_id", method.ID),
+		zap.Int("user_id", userID),
+		zap.String("type", string(method.Type)),
+	)
+	c.JSON(http.StatusCreated, method)
+}
+
+// ListPaymentMethods returns the user's payment methods, the default first
+func (h *PaymentMethodHandler) ListPaymentMethods(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "ListPaymentMethods")
+	defer span.End()
+
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT "+paymentMethodColumns+" FROM payment_methods WHERE user_id = $1 ORDER BY is_default DESC, id",
+		userID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to list payment methods", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	methods := []models.PaymentMethod{}
+	for rows.Next() {
+		var method models.PaymentMethod
+		if err := scanPaymentMethod(rows, &method); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan payment method", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		methods = append(methods, method)
+	}
+
+	span.SetAttributes(attribute.Int("payment_methods.count", len(methods)))
+	c.JSON(http.StatusOK, gin.H{"data": methods})
+}
+
+func (h *PaymentMethodHandler) GetPaymentMethod(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "GetPaymentMethod")
+	defer span.End()
+
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := paymentMethodID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("payment_method.id", id))
+
+	var method models.PaymentMethod
+	err := scanPaymentMethod(h.db.QueryRowContext(ctx,
+		"SELECT "+paymentMethodColumns+" FROM payment_methods WHERE id = $1 AND user_id = $2", id, userID,
+	), &method)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment method not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to get payment method", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, method)
+}
+
+// UpdatePaymentMethod changes a method's expiry or makes it the user's default. A
+// default is replaced by making another method the default, not unset.
+func (h *PaymentMethodHandler) UpdatePaymentMethod(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "UpdatePaymentMethod")
+	defer span.End()
+
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := paymentMethodID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("payment_method.id", id))
+
+	var req models.UpdatePaymentMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.IsDefault != nil && !*req.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "is_default can only be set; make another payment method the default instead"})
+		return
+	}
+	makeDefault := req.IsDefault != nil
+
+	var method models.PaymentMethod
+	err := h.inTx(ctx, func(tx *sql.Tx) error {
+		if makeDefault {
+			if err := clearDefault(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+		return scanPaymentMethod(tx.QueryRowContext(ctx,
+			`UPDATE payment_methods
+			SET exp_month = COALESCE($1, exp_month), exp_year = COALESCE($2, exp_year), is_default = is_default OR $3,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $4 AND user_id = $5
+			RETURNING `+paymentMethodColumns,
+			req.ExpMonth, req.ExpYear, makeDefault, id, userID,
+		), &method)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment method not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to update payment method", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Payment method updated", zap.Int("payment_method_id", id), zap.Int("user_id", userID))
+	c.JSON(http.StatusOK, method)
+}
+
+// DeletePaymentMethod removes a method. Payments charged with it keep their records, and
+// when it was the default the user's newest remaining method takes over.
+func (h *PaymentMethodHandler) DeletePaymentMethod(c *gin.Context) {
+	ctx, span := otel.Tracer("payment-service").Start(c.Request.Context(), "DeletePaymentMethod")
+	defer span.End()
+
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := paymentMethodID(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("payment_method.id", id))
+
+	err := h.inTx(ctx, func(tx *sql.Tx) error {
+		var wasDefault bool
+		if err := tx.QueryRowContext(ctx,
+			"DELETE FROM payment_methods WHERE id = $1 AND user_id = $2 RETURNING is_default", id, userID,
+		).Scan(&wasDefault); err != nil || !wasDefault {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`UPDATE payment_methods SET is_default = TRUE, updated_at = CURRENT_TIMESTAMP
+			WHERE id = (SELECT id FROM payment_methods WHERE user_id = $1 ORDER BY id DESC LIMIT 1)`,
+			userID,
+		)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment method not found"})
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to delete payment method", zap.String("trace_id", middleware.GetTraceID(ctx)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Payment method deleted", zap.Int("payment_method_id", id), zap.Int("user_id", userID))
+	c.JSON(http.StatusOK, gin.H{"message": "Payment method deleted"})
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func (h *PaymentMethodHandler) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// clearDefault unsets the user's default method, so another can take its place
+func clearDefault(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx,
+		"UPDATE payment_methods SET is_default = FALSE, updated_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND is_default",
+		userID,
+	)
+	return err
+}
+
+// authenticatedUserID returns the user_id claim AuthMiddleware put on the context,
+// responding 401 when it's missing
+func authenticatedUserID(c *gin.Context) (int, bool) {
+	if value, exists := c.Get("user_id"); exists {
+		if id, ok := value.(float64); ok && id > 0 {
+			return int(id), true
+		}
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+	return 0, false
+}
+
+func paymentMethodID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func scanPaymentMethod(row rowScanner, m *models.PaymentMethod) error {
+	return row.Scan(&m.ID, &m.UserID, &m.Type, &m.Brand, &m.Last4, &m.ExpMonth, &m.ExpYear, &m.GatewayToken, &m.IsDefault, &m.CreatedAt, &m.UpdatedAt)
+}
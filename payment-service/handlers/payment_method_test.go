@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+var paymentMethodRowColumns = []string{"id", "user_id", "type", "brand", "last4", "exp_month", "exp_year", "gateway_token", "is_default", "created_at", "updated_at"}
+
+// setupPaymentMethodTest routes to the handler as the user 3, standing in for AuthMiddleware
+func setupPaymentMethodTest(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	handler := NewPaymentMethodHandler(db, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", float64(3))
+		c.Next()
+	})
+	router.POST("/payment-methods", handler.CreatePaymentMethod)
+	router.GET("/payment-methods", handler.ListPaymentMethods)
+	router.GET("/payment-methods/:id", handler.GetPaymentMethod)
+	router.PATCH("/payment-methods/:id", handler.UpdatePaymentMethod)
+	router.DELETE("/payment-methods/:id", handler.DeletePaymentMethod)
+	return mock, router
+}
+
+func TestPaymentMethodHandler_CreateFirstBecomesDefault(t *testing.T) {
+	mock, router := setupPaymentMethodTest(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT NOT EXISTS \\(SELECT 1 FROM payment_methods WHERE user_id = \\$1\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"not_exists"}).AddRow(true))
+	mock.ExpectExec("UPDATE payment_methods SET is_default = FALSE").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO payment_methods").
+		WithArgs(3, models.PaymentMethodTypeCard, "visa", "4242", 12, 2030, "pm_card_visa", true).
+		WillReturnRows(sqlmock.NewRows(paymentMethodRowColumns).
+			AddRow(1, 3, "card", "visa", "4242", 12, 2030, "pm_card_visa", true, time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	body := `{"type":"card","brand":"visa","last4":"4242","exp_month":12,"exp_year":2030,"gateway_token":"pm_card_visa"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/payment-methods", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "pm_card_visa") {
+		t.Errorf("Expected the gateway token to stay out of the response, got %s", w.Body.String())
+	}
+	var got models.PaymentMethod
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.ID != 1 || !got.IsDefault || got.Last4 != "4242" {
+		t.Errorf("Unexpected payment method: %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentMethodHandler_CreateRejectsInvalid(t *testing.T) {
+	_, router := setupPaymentMethodTest(t)
+
+	for name, body := range map[string]string{
+		"full card number": `{"type":"card","last4":"4242424242424242","gateway_token":"pm_card_visa"}`,
+		"unknown type":     `{"type":"cash","last4":"4242","gateway_token":"pm_card_visa"}`,
+		"no token":         `{"type":"card","last4":"4242"}`,
+		"bad expiry":       `{"type":"card","last4":"4242","exp_month":13,"gateway_token":"pm_card_visa"}`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/payment-methods", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestPaymentMethodHandler_GetOtherUsersMethod(t *testing.T) {
+	mock, router := setupPaymentMethodTest(t)
+
+	mock.ExpectQuery("SELECT .* FROM payment_methods WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(9, 3).
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payment-methods/9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentMethodHandler_MakeDefault(t *testing.T) {
+	mock, router := setupPaymentMethodTest(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payment_methods SET is_default = FALSE").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE payment_methods\\s+SET exp_month").
+		WithArgs(nil, nil, true, 2, 3).
+		WillReturnRows(sqlmock.NewRows(paymentMethodRowColumns).
+			AddRow(2, 3, "wallet", "", "0005", 0, 0, "pm_wallet", true, time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/payment-methods/2", strings.NewReader(`{"is_default":true}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/payment-methods/2", strings.NewReader(`{"is_default":false}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unsetting the default to be rejected, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentMethodHandler_DeleteDefaultPromotesNewest(t *testing.T) {
+	mock, router := setupPaymentMethodTest(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM payment_methods WHERE id = \\$1 AND user_id = \\$2 RETURNING is_default").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(true))
+	mock.ExpectExec("UPDATE payment_methods SET is_default = TRUE").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/payment-methods/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
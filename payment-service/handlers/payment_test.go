@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zaptest"
+)
+
+var paymentRowColumns = []string{"id", "order_id", "user_id", "amount_minor", "currency", "status", "transaction_id", "region", "merchant_id", "gateway", "gateway_reference", "failure_reason", "payment_method_id", "created_at", "updated_at"}
+
+func setupPaymentTest(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	handler := NewPaymentHandler(db, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/payments", handler.ListPayments)
+	router.GET("/payments/:id", handler.GetPayment)
+	router.GET("/payments/:id/attempts", handler.ListPaymentAttempts)
+	router.GET("/users/:id/payments", handler.ListUserPayments)
+	return mock, router
+}
+
+var paymentAttemptRowColumns = []string{"id", "payment_id", "operation", "gateway", "outcome", "response_code", "gateway_reference", "error", "latency_ms", "attempted_at"}
+
+// expectPaymentAttempts expects payment 5's attempts to be listed: an authorization the
+// gateway didn't answer, then one it declined
+func expectPaymentAttempts(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM payments WHERE id = \\$1\\)").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT .* FROM payment_attempts WHERE payment_id = \\$1 ORDER BY attempted_at, id").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(paymentAttemptRowColumns).
+			AddRow(1, 5, "authorize", "stripe", models.PaymentAttemptError, "500", "", "stripe api_error (status 500): Something went wrong", 812, time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)).
+			AddRow(2, 5, "authorize", "stripe", models.PaymentAttemptDeclined, "insufficient_funds", "pi_456", "insufficient_funds", 240, time.Date(2026, 10, 1, 12, 0, 1, 0, time.UTC)))
+}
+
+func TestPaymentHandler_GetPayment(t *testing.T) {
+	mock, router := setupPaymentTest(t)
+
+	mock.ExpectQuery("SELECT id, order_id, user_id, amount_minor, currency, status, .* FROM payments WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusSuccess, "TXN-7", "us-east-1", "default", "simulated", "TXN-7", "", 2, time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, order_id, user_id, amount_minor, currency, status, .* FROM payments WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got models.Payment
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.ID != 5 || got.OrderID != 7 || got.Status != models.PaymentStatusSuccess || got.TransactionID != "TXN-7" {
+		t.Errorf("Unexpected payment: %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/999", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentHandler_ListPayments_ByOrder(t *testing.T) {
+	mock, router := setupPaymentTest(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE order_id = \\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 ORDER BY created_at DESC, id DESC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(7, 2, 2).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(4, 7, 3, int64(1998), "USD", models.PaymentStatusFailed, "", "", "default", "simulated", "", "payment authorization declined", 0, time.Now(), time.Now()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments?order_id=7&page=2&limit=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got models.PaymentListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.Page != 2 || got.Limit != 2 || got.Total != 3 || got.TotalPages != 2 || len(got.Data) != 1 {
+		t.Errorf("Unexpected page: %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentHandler_ListPayments_Duplicates(t *testing.T) {
+	mock, router := setupPaymentTest(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE order_id = \\$1 AND status = \\$2").
+		WithArgs(7, "duplicate").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND status = \\$2 ORDER BY created_at DESC, id DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(7, "duplicate", 20, 0).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(3, 7, 3, int64(1998), "USD", models.PaymentStatusDuplicate, "TXN-7-1", "", "default", "simulated", "", "", 0, time.Now(), time.Now()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments?order_id=7&status=duplicate", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got models.PaymentListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(got.Data) != 1 || got.Data[0].Status != models.PaymentStatusDuplicate {
+		t.Errorf("Expected the order's duplicate payment, got %s", w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentHandler_ListUserPayments(t *testing.T) {
+	mock, router := setupPaymentTest(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE user_id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT .* FROM payments WHERE user_id = \\$1 ORDER BY created_at DESC, id DESC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(3, defaultListPageSize, 0).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/3/payments", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	// An empty page is a list, not null
+	var got struct {
+		Data []models.Payment `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Data == nil {
+		t.Errorf("Expected an empty list, got %s", w.Body.String())
+	}
+
+	// Pagination is validated before anything is queried
+	for _, path := range []string{"/users/3/payments?limit=500", "/users/3/payments?page=-1", "/users/abc/payments", "/payments?order_id=-1"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected status %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPaymentHandler_ListPaymentAttempts(t *testing.T) {
+	mock, router := setupPaymentTest(t)
+
+	expectPaymentAttempts(mock)
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM payments WHERE id = \\$1\\)").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/5/attempts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got struct {
+		Data []models.PaymentAttempt `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(got.Data) != 2 || got.Data[0].ResponseCode != "500" || got.Data[1].Outcome != models.PaymentAttemptDeclined ||
+		got.Data[1].ResponseCode != "insufficient_funds" || got.Data[1].LatencyMs != 240 {
+		t.Errorf("Unexpected attempts: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments/999/attempts", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
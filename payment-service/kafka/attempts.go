@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"go.uber.org/zap"
+)
+
+// WithAttempts wraps gw so each authorization and capture of the payment paymentID is
+// recorded in payment_attempts, with the gateway's response code and how long it took.
+// A failure to record one is logged and leaves the call's result as it was.
+func WithAttempts(gw gateway.Gateway, db *sql.DB, paymentID int, logger *zap.Logger) gateway.Gateway {
+	return &attemptRecorder{Gateway: gw, db: db, paymentID: paymentID, logger: logger}
+}
+
+type attemptRecorder struct {
+	gateway.Gateway
+	db        *sql.DB
+	paymentID int
+	logger    *zap.Logger
+}
+
+func (g *attemptRecorder) Authorize(ctx context.Context, req gateway.AuthorizeRequest) (gateway.Result, error) {
+	start := time.Now()
+	result, err := g.Gateway.Authorize(ctx, req)
+	g.record(ctx, "authorize", start, result, err)
+	return result, err
+}
+
+func (g *attemptRecorder) Capture(ctx context.Context, transactionID string, amountMinor int64) (gateway.Result, error) {
+	start := time.Now()
+	result, err := g.Gateway.Capture(ctx, transactionID, amountMinor)
+	g.record(ctx, "capture", start, result, err)
+	return result, err
+}
+
+// record stores the attempt operation started at start, which returned result and err
+func (g *attemptRecorder) record(ctx context.Context, operation string, start time.Time, result gateway.Result, err error) {
+	attempt := models.PaymentAttempt{
+		PaymentID:        g.paymentID,
+		Operation:        operation,
+		Gateway:          g.Name(),
+		ResponseCode:     result.ResponseCode,
+		GatewayReference: result.TransactionID,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		AttemptedAt:      start,
+	}
+	switch {
+	case err != nil:
+		attempt.Outcome, attempt.ResponseCode, attempt.Error = models.PaymentAttemptError, gateway.ErrorCode(err), err.Error()
+	case result.Pending:
+		attempt.Outcome = models.PaymentAttemptPending
+	case result.Approved:
+		attempt.Outcome = models.PaymentAttemptApproved
+	default:
+		attempt.Outcome, attempt.Error = models.PaymentAttemptDeclined, result.DeclineReason
+	}
+
+	// Attempts cut short by the processing timeout are the ones most worth keeping
+	_, dbErr := g.db.ExecContext(context.WithoutCancel(ctx),
+		`INSERT INTO payment_attempts (payment_id, operation, gateway, outcome, response_code, gateway_reference, error, latency_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9)`,
+		attempt.PaymentID, attempt.Operation, attempt.Gateway, attempt.Outcome, attempt.ResponseCode, attempt.GatewayReference,
+		attempt.Error, attempt.LatencyMs, attempt.AttemptedAt,
+	)
+	if dbErr != nil {
+		g.logger.Warn("Failed to record payment attempt",
+			zap.Int("payment_id", g.paymentID),
+			zap.String("operation", operation),
+			zap.Error(dbErr),
+		)
+	}
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+// decliningGateway declines every authorization the way a card issuer would
+type decliningGateway struct {
+	approvingGateway
+}
+
+func (g *decliningGateway) Authorize(_ context.Context, _ gateway.AuthorizeRequest) (gateway.Result, error) {
+	return gateway.Result{TransactionID: "pi_9", DeclineReason: "insufficient_funds", ResponseCode: "insufficient_funds"}, nil
+}
+
+func TestWithAttempts(t *testing.T) {
+	tests := []struct {
+		name      string
+		gw        gateway.Gateway
+		operation string
+		call      func(gw gateway.Gateway)
+		args      []driver.Value
+	}{
+		{"approved capture", &approvingGateway{}, "capture",
+			func(gw gateway.Gateway) { gw.Capture(context.Background(), "TXN-1", 1998) },
+			[]driver.Value{models.PaymentAttemptApproved, "", "TXN-1", ""}},
+		{"declined", &decliningGateway{}, "authorize",
+			func(gw gateway.Gateway) { gw.Authorize(context.Background(), gateway.AuthorizeRequest{}) },
+			[]driver.Value{models.PaymentAttemptDeclined, "insufficient_funds", "pi_9", "insufficient_funds"}},
+		{"unanswered", &unavailableGateway{}, "authorize",
+			func(gw gateway.Gateway) { gw.Authorize(context.Background(), gateway.AuthorizeRequest{}) },
+			[]driver.Value{models.PaymentAttemptError, "", "", "payment gateway unavailable: connection refused"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			args := append([]driver.Value{5, tt.operation, "simulated"}, tt.args...)
+			mock.ExpectExec("INSERT INTO payment_attempts").
+				WithArgs(append(args, sqlmock.AnyArg(), sqlmock.AnyArg())...).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			tt.call(WithAttempts(tt.gw, db, 5, zaptest.NewLogger(t)))
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAttempts_RecordFailureKeepsResult(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT INTO payment_attempts").WillReturnError(errors.New("connection refused"))
+
+	result, err := WithAttempts(&approvingGateway{}, db, 5, zaptest.NewLogger(t)).Authorize(context.Background(), gateway.AuthorizeRequest{})
+	if err != nil || !result.Approved {
+		t.Errorf("Expected the approval despite the failed record, got %+v, %v", result, err)
+	}
+}
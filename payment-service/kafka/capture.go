@@ -0,0 +1,390 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/ledger"
+	"payment-svc/lock"
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// deferCapture holds authorized payments until their order is confirmed, when
+// PAYMENT_CAPTURE_MODE is deferred, instead of capturing them at once
+var deferCapture = strings.TrimSpace(getEnv("PAYMENT_CAPTURE_MODE", "immediate")) == "deferred"
+
+// authorizationTimeout is how long an authorization waits for its order to be confirmed
+// before it is voided
+var authorizationTimeout = getEnvDuration("PAYMENT_AUTHORIZATION_TIMEOUT", 24*time.Hour)
+
+const (
+	authorizationSweepInterval = time.Minute
+	authorizationSweepBatch    = 100
+
+	// authorizationSweepLock keeps the sweep to one replica per tick
+	authorizationSweepLock    = "payment-authorization-sweep"
+	authorizationSweepLockTTL = 30 * time.Second
+)
+
+// DeferredCapture reports whether authorized payments wait for order_confirmed before
+// they are captured
+func DeferredCapture() bool {
+	return deferCapture
+}
+
+const paymentColumns = "id, order_id, user_id, amount_minor, currency, status, COALESCE(transaction_id, ''), COALESCE(region, ''), merchant_id, " +
+	"COALESCE(gateway, 'simulated'), COALESCE(gateway_reference, ''), COALESCE(failure_reason, ''), COALESCE(payment_method_id, 0), created_at, updated_at"
+
+type orderConfirmedEvent struct {
+	EventType string `json:"event_type"`
+	OrderID   int    `json:"order_id"`
+	// Metadata is the integrator's references on the order, echoed on the payment event
+	Metadata map[string]string `json:"metadata"`
+}
+
+// processCapture captures the authorized payment of an order_confirmed order through its
+// merchant's gateway and publishes payment_success, or payment_failed when the gateway
+// declines the capture. A redelivered confirmation publishes the settled outcome again.
+func processCapture(ctx context.Context, value []byte, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) error {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "CapturePayment")
+	defer span.End()
+	traceID := middleware.GetTraceID(ctx)
+
+	var confirmation orderConfirmedEvent
+	if err := json.Unmarshal(value, &confirmation); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	span.SetAttributes(attribute.Int("order.id", confirmation.OrderID))
+
+	var payment models.Payment
+	err := retry(ctx, "load_payment", transientDBError, logger, func() (err error) {
+		payment, err = orderPayment(ctx, db, confirmation.OrderID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Warn("No payment to capture for confirmed order", zap.String("trace_id", traceID), zap.Int("order_id", confirmation.OrderID))
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to load payment: %w", err)
+	}
+	span.SetAttributes(
+		attribute.Int("payment.id", payment.ID),
+		attribute.String("payment.status", string(payment.Status)),
+	)
+
+	switch payment.Status {
+	case models.PaymentStatusAuthorized:
+	case models.PaymentStatusSuccess, models.PaymentStatusFailed:
+		// Captured, or declined, before; order-service skips the outcome if it has it
+		return PublishPaymentOutcome(ctx, db, producer, payment, confirmation.Metadata, logger)
+	case models.PaymentStatusPending:
+		// Redelivered once the authorization is back
+		return fmt.Errorf("payment %d of confirmed order %d isn't authorized yet", payment.ID, payment.OrderID)
+	default:
+		logger.Warn("Confirmed order's payment can't be captured",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", payment.ID),
+			zap.String("status", string(payment.Status)),
+		)
+		return nil
+	}
+	gw := merchants.For(payment.MerchantID)
+	if payment.Gateway != gw.Name() {
+		logger.Error("Authorization was made through another gateway",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", payment.ID),
+			zap.String("gateway", payment.Gateway),
+		)
+		return nil
+	}
+
+	var captured gateway.Result
+	attempts := WithAttempts(gw, db, payment.ID, logger)
+	err = retry(ctx, "capture", transientGatewayError, logger, func() (err error) {
+		captured, err = attempts.Capture(ctx, payment.GatewayReference, payment.AmountMinor)
+		return err
+	})
+	if err != nil && (transientGatewayError(err) || ctx.Err() != nil) {
+		span.RecordError(err)
+		return fmt.Errorf("failed to capture payment %d: %w", payment.ID, err)
+	}
+	var outcome chargeOutcome
+	switch {
+	case err != nil:
+		outcome = chargeOutcome{status: models.PaymentStatusFailed, failure: err}
+	case captured.Pending:
+		// The gateway webhook settles it once the provider decides
+		logger.Info("Capture awaiting gateway confirmation", zap.String("trace_id", traceID), zap.Int("payment_id", payment.ID))
+		return nil
+	case captured.Approved:
+		outcome = chargeOutcome{status: models.PaymentStatusSuccess, transactionID: captured.TransactionID}
+	default:
+		outcome = chargeOutcome{status: models.PaymentStatusFailed, failure: errors.New(captured.DeclineReason)}
+	}
+
+	var updated bool
+	if err := retry(ctx, "complete_payment", transientDBError, logger, func() (err error) {
+		updated, err = settleAuthorization(ctx, db, payment, outcome)
+		return err
+	}); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update payment record: %w", err)
+	}
+	if !updated {
+		logger.Info("Payment already settled by the gateway", zap.String("trace_id", traceID), zap.Int("payment_id", payment.ID))
+		return nil
+	}
+
+	payment.Status, payment.TransactionID = outcome.status, outcome.transactionID
+	if outcome.failure != nil {
+		payment.FailureReason = outcome.failure.Error()
+		span.RecordError(outcome.failure)
+	}
+	middleware.RecordPaymentProcessed(string(payment.Status), gw.Name(), payment.Currency, time.Since(payment.CreatedAt), payment.Amount)
+	logger.Info("Authorized payment captured",
+		zap.String("trace_id", traceID),
+		zap.Int("payment_id", payment.ID),
+		zap.String("status", string(payment.Status)),
+		zap.String("failure_reason", payment.FailureReason),
+	)
+
+	if err := PublishPaymentOutcome(ctx, db, producer, payment, confirmation.Metadata, logger); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to publish payment event: %w", err)
+	}
+	return nil
+}
+
+// processOrderExpired voids the authorized payment of an order_expired order and
+// cancels it. The order is cancelled already, so nothing is published.
+func processOrderExpired(ctx context.Context, value []byte, db *sql.DB, _ sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) error {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "VoidExpiredOrderPayment")
+	defer span.End()
+
+	header, err := readEventHeader(value)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(attribute.Int("order.id", header.OrderID))
+
+	var payment models.Payment
+	err = retry(ctx, "load_payment", transientDBError, logger, func() (err error) {
+		payment, err = orderPayment(ctx, db, header.OrderID)
+		return err
+	})
+	gw := merchants.For(payment.MerchantID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (payment.Status != models.PaymentStatusAuthorized || payment.Gateway != gw.Name())) {
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to load payment: %w", err)
+	}
+	span.SetAttributes(attribute.Int("payment.id", payment.ID))
+
+	if _, err := voidAuthorization(ctx, db, gw, &payment, models.PaymentStatusCancelled, "order expired", logger); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// voidAuthorization releases the payment's hold at gw and settles it with status and
+// reason. A hold the gateway won't release, or one placed through a gateway the
+// merchant has since left, lapses on its own, so the payment is settled either way;
+// updated is false when it was settled meanwhile.
+func voidAuthorization(ctx context.Context, db *sql.DB, gw gateway.Gateway, payment *models.Payment, status models.PaymentStatus, reason string, logger *zap.Logger) (updated bool, err error) {
+	var result gateway.Result
+	if payment.Gateway != gw.Name() {
+		err = fmt.Errorf("payment was authorized through the %s gateway, not %s", payment.Gateway, gw.Name())
+	} else {
+		err = retry(ctx, "void", transientGatewayError, logger, func() (err error) {
+			result, err = gw.Void(ctx, payment.GatewayReference)
+			return err
+		})
+	}
+	if err != nil && (transientGatewayError(err) || ctx.Err() != nil) {
+		return false, fmt.Errorf("failed to void payment %d: %w", payment.ID, err)
+	}
+	if err != nil || !result.Approved {
+		logger.Warn("Gateway didn't void authorization",
+			zap.Int("payment_id", payment.ID),
+			zap.String("gateway_reference", payment.GatewayReference),
+			zap.String("decline_reason", result.DeclineReason),
+			zap.Error(err),
+		)
+	}
+
+	outcome := chargeOutcome{status: status, failure: errors.New(reason)}
+	if err := retry(ctx, "complete_payment", transientDBError, logger, func() (err error) {
+		updated, err = settleAuthorization(ctx, db, *payment, outcome)
+		return err
+	}); err != nil {
+		return false, fmt.Errorf("failed to update payment record: %w", err)
+	}
+	if updated {
+		payment.Status, payment.FailureReason = status, reason
+		logger.Info("Authorization voided",
+			zap.String("trace_id", middleware.GetTraceID(ctx)),
+			zap.Int("payment_id", payment.ID),
+			zap.String("status", string(status)),
+			zap.String("reason", reason),
+		)
+	}
+	return updated, nil
+}
+
+// settleAuthorization records the outcome of an authorized payment, booking a captured
+// one in the ledger along with it. updated is false when it was settled meanwhile.
+func settleAuthorization(ctx context.Context, db *sql.DB, payment models.Payment, outcome chargeOutcome) (updated bool, err error) {
+	var failureReason string
+	if outcome.failure != nil {
+		failureReason = outcome.failure.Error()
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE payments
+		SET status = $1, transaction_id = NULLIF($2, ''), failure_reason = NULLIF($3, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND status = 'authorized'`,
+		outcome.status, outcome.transactionID, failureReason, payment.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if outcome.status == models.PaymentStatusSuccess {
+		if err := ledger.Record(ctx, tx, ledger.PaymentJournals(payment, fees)...); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// orderPayment returns the order's payment. Payments recorded before gateways were
+// stored were simulated.
+func orderPayment(ctx context.Context, db *sql.DB, orderID int) (models.Payment, error) {
+	return scanPayment(db.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payments WHERE order_id = $1 AND status <> 'duplicate'", orderID))
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment scans a row selected with paymentColumns, followed by any extra columns
+// into extra
+func scanPayment(row rowScanner, extra ...interface{}) (models.Payment, error) {
+	var p models.Payment
+	var amountMinor int64
+	var currency string
+	dest := []interface{}{&p.ID, &p.OrderID, &p.UserID, &amountMinor, &currency, &p.Status, &p.TransactionID, &p.Region, &p.MerchantID,
+		&p.Gateway, &p.GatewayReference, &p.FailureReason, &p.PaymentMethodID, &p.CreatedAt, &p.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	p.SetAmount(amountMinor, currency)
+	return p, err
+}
+
+// VoidExpiredAuthorizations voids payments authorized through their merchant's gateway
+// more than PAYMENT_AUTHORIZATION_TIMEOUT ago whose orders were never confirmed, failing
+// them with "authorization expired" so order-service releases their stock. It returns
+// how many it voided.
+func VoidExpiredAuthorizations(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) (int, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments
+		WHERE status = 'authorized' AND updated_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+		ORDER BY updated_at
+		LIMIT $2`,
+		int64(authorizationTimeout/time.Second), authorizationSweepBatch,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired authorizations: %w", err)
+	}
+	var expired []models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		expired = append(expired, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list expired authorizations: %w", err)
+	}
+
+	voided := 0
+	for i := range expired {
+		payment := &expired[i]
+		gw := merchants.For(payment.MerchantID)
+		updated, err := voidAuthorization(ctx, db, gw, payment, models.PaymentStatusFailed, "authorization expired", logger)
+		if err != nil {
+			return voided, err
+		}
+		if !updated {
+			continue
+		}
+		voided++
+		middleware.RecordPaymentProcessed(string(payment.Status), gw.Name(), payment.Currency, time.Since(payment.CreatedAt), payment.Amount)
+		if err := PublishPaymentOutcome(ctx, db, producer, *payment, nil, logger); err != nil {
+			// order-service's own expiry cancels the order in the end
+			logger.Error("Failed to publish payment event", zap.Int("payment_id", payment.ID), zap.Error(err))
+		}
+	}
+	return voided, nil
+}
+
+// RunAuthorizationSweeper voids expired authorizations every minute until ctx is done.
+// Each sweep runs under a distributed lock, so only one replica sweeps per tick.
+func RunAuthorizationSweeper(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, locker *lock.Locker, logger *zap.Logger) {
+	ticker := time.NewTicker(authorizationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		voided := 0
+		err := locker.WithLock(ctx, authorizationSweepLock, authorizationSweepLockTTL, func(ctx context.Context) error {
+			var err error
+			voided, err = VoidExpiredAuthorizations(ctx, db, producer, merchants, logger)
+			return err
+		})
+		switch {
+		case errors.Is(err, lock.ErrNotAcquired):
+			logger.Debug("Authorization sweep already running on another replica")
+		case err != nil && ctx.Err() == nil:
+			logger.Error("Failed to void expired authorizations", zap.Error(err))
+		}
+		if voided > 0 {
+			logger.Info("Voided expired authorizations", zap.Int("count", voided))
+		}
+	}
+}
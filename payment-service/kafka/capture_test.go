@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+var kafkaPaymentColumns = []string{"id", "order_id", "user_id", "amount_minor", "currency", "status", "transaction_id", "region", "merchant_id", "gateway", "gateway_reference", "failure_reason", "payment_method_id", "created_at", "updated_at"}
+
+// deferCaptureForTest switches to deferred capture for the test
+func deferCaptureForTest(t *testing.T) {
+	t.Helper()
+	previous := deferCapture
+	deferCapture = true
+	t.Cleanup(func() { deferCapture = previous })
+}
+
+// authorizedPaymentRow is payment 5 of order 7, authorized as TXN-1
+func authorizedPaymentRow(status models.PaymentStatus) *sqlmock.Rows {
+	return sqlmock.NewRows(kafkaPaymentColumns).
+		AddRow(5, 7, 3, int64(1998), "USD", status, "", "us-east-1", "default", "simulated", "TXN-1", "", 0, time.Now(), time.Now())
+}
+
+func TestProcessPayment_DeferredCaptureOnlyAuthorizes(t *testing.T) {
+	deferCaptureForTest(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, "", "TXN-1", "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 1 || len(gw.captured) != 0 {
+		t.Errorf("Expected an authorization without a capture, got %+v and %v", gw.authorized, gw.captured)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessCapture_CapturesAuthorizedPayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND status <> 'duplicate'").
+		WithArgs(7).
+		WillReturnRows(authorizedPaymentRow(models.PaymentStatusAuthorized))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments .* WHERE id = \\$4 AND status = 'authorized'").
+		WithArgs(models.PaymentStatusSuccess, "TXN-1", "", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerJournals(mock, 2)
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := `{"event_type":"order_confirmed","order_id":7,"metadata":{"po":"PO-1"}}`
+	if err := processCapture(context.Background(), []byte(value), db, producer, gateway.Single(gw), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.captured) != 1 || gw.captured[0] != "TXN-1" {
+		t.Errorf("Expected TXN-1 to be captured, got %v", gw.captured)
+	}
+	if event.EventType != "payment_success" || event.EventID != "payment-5" || event.TransactionID != "TXN-1" || event.Metadata["po"] != "PO-1" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessCapture_PendingAuthorizationIsRetried(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND status <> 'duplicate'").
+		WithArgs(7).
+		WillReturnRows(authorizedPaymentRow(models.PaymentStatusPending))
+
+	value := `{"event_type":"order_confirmed","order_id":7}`
+	if err := processCapture(context.Background(), []byte(value), db, nil, gateway.Single(gw), zaptest.NewLogger(t)); err == nil {
+		t.Error("Expected the confirmation to be left for redelivery")
+	}
+	if len(gw.captured) != 0 {
+		t.Errorf("Expected nothing to be captured, got %v", gw.captured)
+	}
+}
+
+func TestProcessOrderExpired_VoidsAuthorization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND status <> 'duplicate'").
+		WithArgs(7).
+		WillReturnRows(authorizedPaymentRow(models.PaymentStatusAuthorized))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments .* WHERE id = \\$4 AND status = 'authorized'").
+		WithArgs(models.PaymentStatusCancelled, "", "order expired", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The order is cancelled already, so nothing is published
+	value := `{"event_type":"order_expired","order_id":7}`
+	if err := processOrderExpired(context.Background(), []byte(value), db, nil, gateway.Single(gw), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.voided) != 1 || gw.voided[0] != "TXN-1" {
+		t.Errorf("Expected TXN-1 to be voided, got %v", gw.voided)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestVoidExpiredAuthorizations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE status = 'authorized' AND updated_at < .* LIMIT \\$2").
+		WithArgs(int64(authorizationTimeout/time.Second), authorizationSweepBatch).
+		WillReturnRows(authorizedPaymentRow(models.PaymentStatusAuthorized))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments .* WHERE id = \\$4 AND status = 'authorized'").
+		WithArgs(models.PaymentStatusFailed, "", "authorization expired", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	voided, err := VoidExpiredAuthorizations(context.Background(), db, producer, gateway.Single(gw), zaptest.NewLogger(t))
+	if err != nil || voided != 1 {
+		t.Fatalf("Expected one authorization voided, got %d and %v", voided, err)
+	}
+	// order-service fails the order and releases its stock
+	if event.EventType != "payment_failed" || event.FailureReason != "authorization expired" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
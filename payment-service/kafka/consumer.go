@@ -6,11 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
-	"strconv"
 	"time"
 
+	"payment-svc/fraud"
+	"payment-svc/gateway"
+	"payment-svc/ledger"
+	"payment-svc/middleware"
 	"payment-svc/models"
+	"payment-svc/region"
+	"payment-svc/startup"
 
 	"github.com/IBM/sarama"
 	"go.opentelemetry.io/otel"
@@ -20,11 +24,21 @@ import (
 	"go.uber.org/zap"
 )
 
-var (
-	rng                = rand.New(rand.NewSource(time.Now().UnixNano()))
-	paymentSuccessRate = loadSuccessRate()
-	minProcessingDelay = 200 * time.Millisecond
-	maxAdditionalDelay = 800 * time.Millisecond
+var homeRegion = region.Load().Home
+
+// ordersTopic is where order-service publishes the order events this service consumes
+var ordersTopic = getEnv("KAFKA_ORDERS_TOPIC", "orders")
+
+// fees is what the gateway takes from each captured payment, booked in the ledger
+var fees = ledger.LoadFees()
+
+// processingTimeout bounds charging a payment, retries included, so a gateway that never
+// answers can't hold up the consumer
+var processingTimeout = getEnvDuration("PAYMENT_PROCESSING_TIMEOUT", 30*time.Second)
+
+const (
+	minRejoinBackoff = 1 * time.Second
+	maxRejoinBackoff = 30 * time.Second
 )
 
 type orderCreatedEvent struct {
@@ -34,9 +48,27 @@ type orderCreatedEvent struct {
 	ProductID  int     `json:"product_id"`
 	Quantity   int     `json:"quantity"`
 	TotalPrice float64 `json:"total_price"`
+	// Currency is the ISO 4217 code of the currency TotalPrice is in
+	Currency string `json:"currency"`
+	Region   string `json:"region"`
+	// PaymentMethodID is the saved payment method the customer chose, 0 for their default
+	PaymentMethodID int `json:"payment_method_id"`
+	// MerchantID is the merchant the order is paid to, whose gateway charges it
+	MerchantID string `json:"merchant_id"`
+	// Metadata is the integrator's references on the order, echoed on the payment event
+	Metadata map[string]string `json:"metadata"`
 }
 
-func InitConsumer(logger *zap.Logger) (sarama.ConsumerGroup, error) {
+// errPaymentMethodNotFound declines orders naming a payment method their user hasn't saved
+var errPaymentMethodNotFound = errors.New("payment method not found")
+
+// errProcessingTimeout puts a payment the gateway didn't answer for in time up for review
+var errProcessingTimeout = errors.New("payment processing timed out")
+
+// InitConsumer joins the payment consumer group. With replay it joins a fresh, throwaway
+// group instead, which starts from the oldest retained event without moving the live
+// group's committed offsets.
+func InitConsumer(logger *zap.Logger, replay bool) (sarama.ConsumerGroup, error) {
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_8_0_0
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
@@ -44,9 +76,16 @@ func InitConsumer(logger *zap.Logger) (sarama.ConsumerGroup, error) {
 	config.Consumer.Return.Errors = true
 
 	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
-	groupID := getEnv("KAFKA_CONSUMER_GROUP", "payment-service")
+	groupID := consumerGroupID()
+	if replay {
+		groupID = fmt.Sprintf("%s-replay-%d", groupID, time.Now().Unix())
+	}
 
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, groupID, config)
+	var consumerGroup sarama.ConsumerGroup
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumerGroup, err = sarama.NewConsumerGroup(brokers, groupID, config)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
 	}
@@ -54,18 +93,29 @@ func InitConsumer(logger *zap.Logger) (sarama.ConsumerGroup, error) {
 	logger.Info("Kafka consumer group initialized",
 		zap.Strings("brokers", brokers),
 		zap.String("group_id", groupID),
-		zap.Float64("payment_success_rate", paymentSuccessRate),
 	)
 
 	return consumerGroup, nil
 }
 
-func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, db *sql.DB, producer sarama.SyncProducer, logger *zap.Logger) error {
-	topics := []string{getEnv("KAFKA_TOPIC", "order_events")}
+// consumerGroupID is the live consumer group, whose offsets transactions commit
+func consumerGroupID() string {
+	return getEnv("KAFKA_CONSUMER_GROUP", "payment-service")
+}
+
+// StartConsumer charges new orders, once orders confirms them, and refunds refund
+// requests through each merchant's gateway until ctx is cancelled, rejoining the group
+// after rebalances and broker errors. With a transactional producer, see
+// InitTransactionalProducer, each message is handled in a transaction of its own.
+func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, orders OrderLookup, state *ConsumerState, logger *zap.Logger) error {
+	topics := []string{ordersTopic}
 	handler := &paymentConsumerGroupHandler{
-		db:       db,
-		producer: producer,
-		logger:   logger,
+		db:        db,
+		producer:  producer,
+		merchants: merchants,
+		orders:    orders,
+		state:     state,
+		logger:    logger,
 	}
 
 	logger.Info("Kafka consumer loop started", zap.Strings("topics", topics))
@@ -73,49 +123,157 @@ func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, db *
 	// Handle errors in a separate goroutine
 	go func() {
 		for err := range consumerGroup.Errors() {
+			state.setError(err)
 			logger.Error("Kafka consumer group error", zap.Error(err))
 		}
 	}()
 
+	backoff := minRejoinBackoff
 	for {
-		if err := consumerGroup.Consume(ctx, topics, handler); err != nil {
+		err := consumerGroup.Consume(ctx, topics, handler)
+		if ctx.Err() != nil {
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		}
+
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
 			return fmt.Errorf("failed to consume topic: %w", err)
 		}
 
-		if ctx.Err() != nil {
+		if err == nil {
+			// Session ended normally (rebalance); rejoin immediately
+			backoff = minRejoinBackoff
+			continue
+		}
+
+		// Rebalance or broker error: rejoin the group with exponential backoff
+		state.setError(err)
+		logger.Warn("Kafka consumer session failed, rejoining group",
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
 			logger.Info("Kafka consumer context cancelled")
 			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRejoinBackoff {
+			backoff = maxRejoinBackoff
 		}
 	}
 }
 
 type paymentConsumerGroupHandler struct {
-	db       *sql.DB
-	producer sarama.SyncProducer
-	logger   *zap.Logger
+	db        *sql.DB
+	producer  sarama.SyncProducer
+	merchants *gateway.Merchants
+	orders    OrderLookup
+	state     *ConsumerState
+	logger    *zap.Logger
+	// pool handles the session's messages, started in Setup and stopped in Cleanup
+	pool *workerPool
 }
 
-func (h *paymentConsumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (h *paymentConsumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+func (h *paymentConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	workers, handle := consumerWorkers, h.handle
+	if h.producer.IsTransactional() {
+		// A producer has one transaction open at a time
+		workers, handle = 1, h.handleInTransaction
+	}
+	h.pool = newWorkerPool(workers, handle)
+	h.state.setJoined(session.MemberID(), session.GenerationID(), session.Claims())
+	h.logger.Info("Joined Kafka consumer group",
+		zap.String("member_id", session.MemberID()),
+		zap.Int32("generation_id", session.GenerationID()),
+		zap.Any("partitions", session.Claims()),
+		zap.Int("workers", workers),
+		zap.Bool("transactional", h.producer.IsTransactional()),
+	)
+	return nil
+}
 
+func (h *paymentConsumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error {
+	// Every claim has waited for its messages, so the queues are empty
+	h.pool.stop()
+	h.state.setLeft()
+	return nil
+}
+
+// ConsumeClaim hands the partition's messages to the worker pool, so orders on the same
+// partition are charged in parallel. It returns once the messages it dispatched have
+// finished, so none is still being handled after the partition moves to another member.
+// Messages whose handling fails are kept in failed_events for replay.
 func (h *paymentConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tracker := newOffsetTracker()
+	lag := newPartitionLag(claim.Topic(), claim.Partition())
+	defer lag.clear()
 	for message := range claim.Messages() {
-		if err := handleMessage(message, h.db, h.producer, h.logger); err != nil {
-			h.logger.Error("Failed to handle message", zap.Error(err))
-		} else {
-			session.MarkMessage(message, "")
-		}
+		middleware.RecordConsumerMessage(message.Topic)
+		lag.received(message.Offset, claim.HighWaterMarkOffset())
+		tracker.dispatch(message.Offset)
+		h.pool.submit(message, func(err error) {
+			if err != nil {
+				middleware.RecordConsumerFailure(message.Topic)
+				h.logger.Error("Failed to handle message", zap.Int64("offset", message.Offset), zap.Error(err))
+				h.storeFailed(message, err)
+			}
+			// Marks never move the offset back, so racing workers can't undo each other
+			if offset := tracker.finish(message.Offset, err == nil); offset >= 0 {
+				session.MarkOffset(message.Topic, message.Partition, offset, "")
+				lag.marked(offset)
+			}
+		})
 	}
+	tracker.wait()
 
 	return nil
 }
 
-func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, producer sarama.SyncProducer, logger *zap.Logger) error {
+func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, orders OrderLookup, logger *zap.Logger) error {
 	// Extract trace context from Kafka message headers
 	var propagator propagation.TextMapPropagator = otel.GetTextMapPropagator()
 	carrier := saramaHeaderCarrierConsumer(message.Headers)
 	ctx := propagator.Extract(context.Background(), carrier)
 
+	header, err := readEventHeader(message.Value)
+	if err != nil {
+		return err
+	}
+
+	var process func(ctx context.Context, value []byte, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) error
+	switch header.EventType {
+	case "order_created":
+		process = func(ctx context.Context, value []byte, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) error {
+			return processPayment(ctx, value, db, producer, merchants, orders, logger)
+		}
+	case "refund_requested":
+		process = processRefund
+	case "order_confirmed":
+		process = processCapture
+	case "order_expired":
+		process = processOrderExpired
+	default:
+		// Order events this service has no part in, like stock_release
+		return nil
+	}
+
+	// A newer version is left unhandled until this service reads it, then replayed
+	if err := header.checkVersion(); err != nil {
+		return err
+	}
+	return process(ctx, message.Value, db, producer, merchants, logger)
+}
+
+// processPayment charges an order_created event's total through its merchant's gateway
+// and publishes the outcome. Each order is charged once: a redelivered or replayed event
+// finds the order's payment and publishes its outcome again instead. An event that
+// doesn't match its order in orders is rejected with payment_rejected before anything is
+// recorded.
+func processPayment(ctx context.Context, value []byte, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, orders OrderLookup, logger *zap.Logger) error {
 	var tracer trace.Tracer = otel.Tracer("payment-service")
 	ctx, span := tracer.Start(ctx, "ProcessPayment")
 	defer span.End()
@@ -127,14 +285,32 @@ func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, producer sarama.
 	}
 
 	var orderEvent orderCreatedEvent
-	if err := json.Unmarshal(message.Value, &orderEvent); err != nil {
+	if err := json.Unmarshal(value, &orderEvent); err != nil {
 		span.RecordError(err)
 		return fmt.Errorf("failed to unmarshal event: %w", err)
 	}
 
-	if orderEvent.EventType != "order_created" {
-		// Skip non-order_created events
-		return nil
+	// Orders published before region tagging carry none; they belong to the home region
+	if orderEvent.Region == "" {
+		orderEvent.Region = homeRegion
+	}
+	// Likewise, orders published before currencies were priced in the default
+	if orderEvent.Currency == "" {
+		orderEvent.Currency = models.DefaultCurrency
+	}
+	if !models.ValidCurrency(orderEvent.Currency) {
+		err := fmt.Errorf("invalid currency %q on order %d", orderEvent.Currency, orderEvent.OrderID)
+		span.RecordError(err)
+		return err
+	}
+	// And orders published before merchants were, or not naming one, to the default one
+	if orderEvent.MerchantID == "" {
+		orderEvent.MerchantID = models.DefaultMerchant
+	}
+	if !models.ValidMerchantID(orderEvent.MerchantID) {
+		err := fmt.Errorf("invalid merchant %q on order %d", orderEvent.MerchantID, orderEvent.OrderID)
+		span.RecordError(err)
+		return err
 	}
 
 	span.SetAttributes(
@@ -144,6 +320,9 @@ func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, producer sarama.
 		attribute.Int("product.id", orderEvent.ProductID),
 		attribute.Int("order.quantity", orderEvent.Quantity),
 		attribute.Float64("amount", orderEvent.TotalPrice),
+		attribute.String("currency", orderEvent.Currency),
+		attribute.String("region", orderEvent.Region),
+		attribute.String("merchant.id", orderEvent.MerchantID),
 	)
 
 	logger.Info("Processing payment for order",
@@ -151,49 +330,217 @@ func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, producer sarama.
 		zap.Int("order_id", orderEvent.OrderID),
 		zap.Int("user_id", orderEvent.UserID),
 		zap.Float64("amount", orderEvent.TotalPrice),
+		zap.String("currency", orderEvent.Currency),
+		zap.String("merchant_id", orderEvent.MerchantID),
 	)
 
-	status, transactionID, processingDelay, simErr := simulatePayment(orderEvent.OrderID, orderEvent.TotalPrice)
-	span.SetAttributes(attribute.Bool("payment.success", status == models.PaymentStatusSuccess))
-	if simErr != nil {
-		span.RecordError(simErr)
+	// Tampered or stale events never reach the gateway
+	reason, err := verifyOrder(ctx, orders, orderEvent, logger)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to verify order %d: %w", orderEvent.OrderID, err)
+	}
+	if reason != "" {
+		span.SetAttributes(attribute.String("payment.rejected", reason))
+		return rejectPayment(ctx, producer, orderEvent, reason, traceID, logger)
 	}
 
-	paymentID, err := persistPayment(ctx, db, orderEvent, status, transactionID)
+	var payment models.Payment
+	var created bool
+	err = retry(ctx, "reserve_payment", transientDBError, logger, func() (err error) {
+		payment, created, err = reservePayment(ctx, db, orderEvent, merchants.For(orderEvent.MerchantID).Name(), traceID)
+		return err
+	})
 	if err != nil {
 		span.RecordError(err)
 		return fmt.Errorf("failed to create payment record: %w", err)
 	}
-
+	// A resumed payment stays with the merchant it was recorded for
+	gw := merchants.For(payment.MerchantID)
+	paymentID := payment.ID
 	span.SetAttributes(attribute.Int("payment.id", paymentID))
 
+	if payment.Status != models.PaymentStatusPending {
+		span.SetAttributes(attribute.Bool("payment.duplicate", true))
+		middleware.RecordDuplicatePayment()
+		logger.Info("Order already charged, republishing outcome",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+			zap.Int("order_id", orderEvent.OrderID),
+			zap.String("status", string(payment.Status)),
+		)
+		if err := PublishPaymentOutcome(ctx, db, producer, payment, orderEvent.Metadata, logger); err != nil {
+			logger.Error("Failed to republish payment event", zap.Int("payment_id", paymentID), zap.Error(err))
+		}
+		return nil
+	}
+	if !created {
+		// The gateway's idempotency key keeps a resumed charge from being taken twice
+		logger.Warn("Resuming unfinished payment",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+			zap.Int("order_id", orderEvent.OrderID),
+		)
+	}
+
+	var method *models.PaymentMethod
+	transient := func(err error) bool { return !errors.Is(err, errPaymentMethodNotFound) && transientDBError(err) }
+	err = retry(ctx, "payment_method", transient, logger, func() (err error) {
+		method, err = paymentMethodFor(ctx, db, orderEvent)
+		return err
+	})
+	if err != nil && !errors.Is(err, errPaymentMethodNotFound) {
+		span.RecordError(err)
+		return fmt.Errorf("failed to look up payment method: %w", err)
+	}
+
+	start := time.Now()
+	if err == nil {
+		var assessment fraud.Assessment
+		assessment, err = screenPayment(ctx, db, producer, fraud.NewRuleChecker(db, fraudConfig), payment, orderEvent.Metadata, logger)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to check payment %d for fraud: %w", paymentID, err)
+		}
+		span.SetAttributes(
+			attribute.Float64("payment.risk_score", assessment.Score),
+			attribute.String("payment.risk_decision", string(assessment.Decision)),
+		)
+		if assessment.Decision == fraud.DecisionDecline {
+			err = errFraudDeclined
+		}
+	}
+
+	var outcome chargeOutcome
+	if err == nil {
+		chargeCtx, cancel := context.WithTimeout(ctx, processingTimeout)
+		attempts := WithAttempts(gw, db, paymentID, logger)
+		err = retry(chargeCtx, "charge", transientGatewayError, logger, func() (err error) {
+			outcome, err = charge(chargeCtx, attempts, payment, orderEvent.Metadata, method)
+			return err
+		})
+		if err != nil && errors.Is(chargeCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			// The gateway may yet have charged it, so it is neither retried nor failed
+			outcome = chargeOutcome{status: models.PaymentStatusPendingReview, reference: outcome.reference, failure: errProcessingTimeout}
+			err = nil
+		}
+		cancel()
+	}
+	if err != nil && (transientGatewayError(err) || ctx.Err() != nil) {
+		// Not a decline: the payment stays pending and the order waits rather than fail
+		span.RecordError(err)
+		return fmt.Errorf("failed to charge payment %d: %w", paymentID, err)
+	}
+	if err != nil {
+		// The gateway refused the request itself; asking again won't change that
+		outcome = chargeOutcome{status: models.PaymentStatusFailed, reference: outcome.reference, failure: err}
+	}
+	status, transactionID := outcome.status, outcome.transactionID
+	processingDelay := time.Since(start)
+	span.SetAttributes(
+		attribute.String("payment.gateway", gw.Name()),
+		attribute.String("payment.gateway_reference", outcome.reference),
+		attribute.Bool("payment.success", status == models.PaymentStatusSuccess),
+	)
+	if outcome.failure != nil {
+		span.RecordError(outcome.failure)
+	}
+
+	var updated bool
+	if err := retry(ctx, "complete_payment", transientDBError, logger, func() (err error) {
+		updated, err = completePayment(ctx, db, payment, method, outcome)
+		return err
+	}); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update payment record: %w", err)
+	}
+	if !updated {
+		// The gateway webhook settled it first and published the outcome
+		logger.Info("Payment already settled by the gateway",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+		)
+		return nil
+	}
+	if status == models.PaymentStatusAuthorized {
+		// order_confirmed captures it, or the order's expiry voids it
+		logger.Info("Payment authorized, awaiting order confirmation",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+			zap.String("gateway_reference", outcome.reference),
+		)
+		return nil
+	}
+	if status == models.PaymentStatusPending {
+		// The gateway webhook publishes and records the outcome once the provider decides
+		logger.Info("Payment awaiting gateway confirmation",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+			zap.String("gateway_reference", outcome.reference),
+		)
+		return nil
+	}
+	middleware.RecordPaymentProcessed(string(status), gw.Name(), payment.Currency, processingDelay, payment.Amount)
+
 	paymentEvent := models.PaymentEvent{
+		EventID:       fmt.Sprintf("payment-%d", paymentID),
 		PaymentID:     paymentID,
 		OrderID:       orderEvent.OrderID,
 		UserID:        orderEvent.UserID,
-		Amount:        orderEvent.TotalPrice,
+		Amount:        payment.Amount,
 		Status:        status,
 		TransactionID: transactionID,
+		AmountMinor:   payment.AmountMinor,
+		Currency:      payment.Currency,
+		Region:        orderEvent.Region,
+		MerchantID:    payment.MerchantID,
+		Metadata:      orderEvent.Metadata,
+	}
+	if method != nil {
+		paymentEvent.PaymentMethod = method.Summary()
 	}
 
-	if status == models.PaymentStatusSuccess {
+	switch status {
+	case models.PaymentStatusSuccess:
 		paymentEvent.EventType = "payment_success"
+		middleware.RecordBusinessEvent(ctx, middleware.EventPaymentAuthorized,
+			attribute.Int("payment.id", paymentID),
+			attribute.Int("order.id", orderEvent.OrderID),
+			attribute.Int("user.id", orderEvent.UserID),
+			attribute.Float64("amount", payment.Amount),
+			attribute.String("currency", payment.Currency),
+			attribute.String("transaction.id", transactionID),
+			attribute.String("region", orderEvent.Region),
+		)
 		logger.Info("Payment successful",
 			zap.String("trace_id", traceID),
 			zap.Int("payment_id", paymentID),
 			zap.String("transaction_id", transactionID),
 			zap.Duration("processing_time", processingDelay),
 		)
-	} else {
+	case models.PaymentStatusPendingReview:
+		paymentEvent.EventID = fmt.Sprintf("timeout-%d", paymentID)
+		paymentEvent.EventType = "payment_timeout"
+		paymentEvent.FailureReason = outcome.failure.Error()
+		logger.Error("Payment timed out, left for review",
+			zap.String("trace_id", traceID),
+			zap.Int("payment_id", paymentID),
+			zap.String("gateway_reference", outcome.reference),
+			zap.Duration("processing_time", processingDelay),
+		)
+	default:
 		paymentEvent.EventType = "payment_failed"
+		paymentEvent.FailureReason = outcome.failure.Error()
 		logger.Warn("Payment failed",
 			zap.String("trace_id", traceID),
 			zap.Int("payment_id", paymentID),
+			zap.String("gateway_reference", outcome.reference),
 			zap.Duration("processing_time", processingDelay),
+			zap.Error(outcome.failure),
 		)
 	}
 
-	if err := PublishPaymentEvent(ctx, producer, "order_events", paymentEvent, logger); err != nil {
+	if err := PublishPaymentEvent(ctx, producer, paymentsTopic, paymentEvent, logger); err != nil {
 		span.RecordError(err)
 		logger.Error("Failed to publish payment event", zap.String("trace_id", traceID), zap.Error(err))
 	}
@@ -224,60 +571,213 @@ func (c saramaHeaderCarrierConsumer) Set(key, value string) {
 	// Not needed for extraction
 }
 
-func persistPayment(ctx context.Context, db *sql.DB, evt orderCreatedEvent, status models.PaymentStatus, transactionID string) (int, error) {
-	var paymentID int
-	err := db.QueryRowContext(ctx,
-		"INSERT INTO payments (order_id, user_id, amount, status, transaction_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		evt.OrderID, evt.UserID, evt.TotalPrice, status, transactionID,
-	).Scan(&paymentID)
+// reservePayment records a pending payment for the order, or returns the order's existing
+// payment; created reports which. order_id is unique apart from duplicates, so concurrent
+// deliveries, e.g. from a replay group, share one payment. The total is stored in the
+// currency's minor unit, and traceID is kept for when the payment is swept while pending.
+func reservePayment(ctx context.Context, db *sql.DB, evt orderCreatedEvent, gatewayName, traceID string) (payment models.Payment, created bool, err error) {
+	amountMinor := models.ToMinorUnits(evt.TotalPrice, evt.Currency)
+	err = db.QueryRowContext(ctx,
+		`INSERT INTO payments (order_id, user_id, amount_minor, currency, status, region, gateway, trace_id, merchant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+		ON CONFLICT (order_id) WHERE status <> 'duplicate' DO NOTHING
+		RETURNING id`,
+		evt.OrderID, evt.UserID, amountMinor, evt.Currency, models.PaymentStatusPending, evt.Region, gatewayName, traceID, evt.MerchantID,
+	).Scan(&payment.ID)
+	if err == nil {
+		payment.OrderID, payment.UserID = evt.OrderID, evt.UserID
+		payment.SetAmount(amountMinor, evt.Currency)
+		payment.Status, payment.Region, payment.Gateway = models.PaymentStatusPending, evt.Region, gatewayName
+		payment.MerchantID = evt.MerchantID
+		return payment, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return payment, false, err
+	}
 
-	if err != nil {
-		return 0, err
+	var currency string
+	err = db.QueryRowContext(ctx,
+		`SELECT id, order_id, user_id, amount_minor, currency, status, COALESCE(transaction_id, ''), COALESCE(region, ''),
+			merchant_id, COALESCE(failure_reason, ''), COALESCE(payment_method_id, 0)
+		FROM payments WHERE order_id = $1 AND status <> 'duplicate'`,
+		evt.OrderID,
+	).Scan(&payment.ID, &payment.OrderID, &payment.UserID, &amountMinor, &currency, &payment.Status, &payment.TransactionID, &payment.Region,
+		&payment.MerchantID, &payment.FailureReason, &payment.PaymentMethodID)
+	payment.SetAmount(amountMinor, currency)
+	return payment, false, err
+}
+
+const paymentMethodColumns = "id, user_id, type, COALESCE(brand, ''), last4, COALESCE(exp_month, 0), COALESCE(exp_year, 0), gateway_token, is_default, created_at, updated_at"
+
+// paymentMethodFor returns the saved method the order is paid with: the one it names,
+// or else its user's default. It returns nil for a user without a default, leaving the
+// choice to the gateway, and errPaymentMethodNotFound for a method the user hasn't saved.
+func paymentMethodFor(ctx context.Context, db *sql.DB, evt orderCreatedEvent) (*models.PaymentMethod, error) {
+	query, args := "SELECT "+paymentMethodColumns+" FROM payment_methods WHERE user_id = $1 AND is_default", []interface{}{evt.UserID}
+	if evt.PaymentMethodID != 0 {
+		query, args = "SELECT "+paymentMethodColumns+" FROM payment_methods WHERE id = $1 AND user_id = $2", []interface{}{evt.PaymentMethodID, evt.UserID}
 	}
 
-	return paymentID, nil
+	var m models.PaymentMethod
+	err := db.QueryRowContext(ctx, query, args...).Scan(&m.ID, &m.UserID, &m.Type, &m.Brand, &m.Last4, &m.ExpMonth, &m.ExpYear, &m.GatewayToken, &m.IsDefault, &m.CreatedAt, &m.UpdatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && evt.PaymentMethodID != 0:
+		return nil, errPaymentMethodNotFound
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &m, nil
 }
 
-func simulatePayment(orderID int, amount float64) (models.PaymentStatus, string, time.Duration, error) {
-	delay := minProcessingDelay
-	if maxAdditionalDelay > 0 {
-		delay += time.Duration(rng.Int63n(int64(maxAdditionalDelay)))
+// completePayment records how charging the pending payment with method went, booking a
+// captured payment in the ledger along with it. updated is false when the gateway
+// webhook settled the payment first.
+func completePayment(ctx context.Context, db *sql.DB, payment models.Payment, method *models.PaymentMethod, outcome chargeOutcome) (updated bool, err error) {
+	var failureReason string
+	if outcome.failure != nil {
+		failureReason = outcome.failure.Error()
+	}
+	var methodID int
+	if method != nil {
+		methodID = method.ID
 	}
 
-	if amount <= 0 {
-		return models.PaymentStatusFailed, "", delay, errors.New("invalid payment amount")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE payments
+		SET status = $1, transaction_id = $2, gateway_reference = NULLIF($3, ''), failure_reason = NULLIF($4, ''),
+			payment_method_id = NULLIF($5, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND status = 'pending'`,
+		outcome.status, outcome.transactionID, outcome.reference, failureReason, methodID, payment.ID,
+	)
+	if err != nil {
+		return false, err
 	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if outcome.status == models.PaymentStatusSuccess {
+		if err := ledger.Record(ctx, tx, ledger.PaymentJournals(payment, fees)...); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
 
-	time.Sleep(delay)
+// PublishPaymentOutcome publishes the outcome of an order's settled payment under the
+// payment's event ID, payment-<id>, or payment_timeout under timeout-<id> for one left
+// for review. Publishing it again is safe: an outcome that never reached order-service
+// gets there and one that did is skipped as a duplicate. Nothing is published for a
+// payment still pending, or for a refunded one, whose order has moved on.
+func PublishPaymentOutcome(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, payment models.Payment, metadata map[string]string, logger *zap.Logger) error {
+	event := models.PaymentEvent{
+		EventID:       fmt.Sprintf("payment-%d", payment.ID),
+		PaymentID:     payment.ID,
+		OrderID:       payment.OrderID,
+		UserID:        payment.UserID,
+		Amount:        payment.Amount,
+		Status:        payment.Status,
+		TransactionID: payment.TransactionID,
+		AmountMinor:   payment.AmountMinor,
+		Currency:      payment.Currency,
+		Region:        payment.Region,
+		MerchantID:    payment.MerchantID,
+		Metadata:      metadata,
+	}
+	switch payment.Status {
+	case models.PaymentStatusSuccess:
+		event.EventType = "payment_success"
+	case models.PaymentStatusFailed:
+		event.EventType = "payment_failed"
+		event.FailureReason = payment.FailureReason
+	case models.PaymentStatusPendingReview:
+		event.EventID = fmt.Sprintf("timeout-%d", payment.ID)
+		event.EventType = "payment_timeout"
+		event.FailureReason = payment.FailureReason
+	default:
+		return nil
+	}
 
-	if rng.Float64() <= paymentSuccessRate {
-		transactionID := fmt.Sprintf("TXN-%d-%d", orderID, time.Now().UnixNano())
-		return models.PaymentStatusSuccess, transactionID, delay, nil
+	if payment.PaymentMethodID != 0 {
+		// The method may have been deleted since; the outcome still goes out without it
+		var m models.PaymentMethod
+		err := db.QueryRowContext(ctx,
+			"SELECT id, type, COALESCE(brand, ''), last4 FROM payment_methods WHERE id = $1",
+			payment.PaymentMethodID,
+		).Scan(&m.ID, &m.Type, &m.Brand, &m.Last4)
+		if err == nil {
+			event.PaymentMethod = m.Summary()
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Failed to look up payment method", zap.Int("payment_id", payment.ID), zap.Error(err))
+		}
 	}
 
-	return models.PaymentStatusFailed, "", delay, errors.New("payment authorization declined")
+	return PublishPaymentEvent(ctx, producer, paymentsTopic, event, logger)
+}
+
+// chargeOutcome is what charging an order left behind
+type chargeOutcome struct {
+	status models.PaymentStatus
+	// transactionID is set once the payment is captured
+	transactionID string
+	// reference is the gateway's ID for the payment, set for declines when the gateway
+	// keeps one
+	reference string
+	// failure says why a failed payment was declined
+	failure error
 }
 
-func loadSuccessRate() float64 {
-	raw := getEnv("PAYMENT_SUCCESS_RATE", "")
-	if raw == "" {
-		return 0.8
+// charge authorizes and captures the payment's amount with method, or the gateway's
+// default method when it's nil. With deferred capture an approved authorization is left
+// authorized, for order_confirmed to capture. A payment the gateway declines fails, and
+// one it hasn't decided on stays pending until the gateway webhook hears back. An error
+// means the gateway didn't answer, and the outcome holds the reference of an
+// authorization that wasn't captured.
+func charge(ctx context.Context, gw gateway.Gateway, payment models.Payment, metadata map[string]string, method *models.PaymentMethod) (chargeOutcome, error) {
+	req := gateway.AuthorizeRequest{
+		OrderID:        payment.OrderID,
+		UserID:         payment.UserID,
+		AmountMinor:    payment.AmountMinor,
+		Currency:       payment.Currency,
+		Metadata:       metadata,
+		IdempotencyKey: fmt.Sprintf("payment-%d", payment.ID),
+	}
+	if method != nil {
+		req.PaymentMethod = method.GatewayToken
 	}
 
-	rate, err := strconv.ParseFloat(raw, 64)
+	auth, err := gw.Authorize(ctx, req)
 	if err != nil {
-		return 0.8
+		return chargeOutcome{}, fmt.Errorf("failed to authorize payment: %w", err)
 	}
-
-	if rate < 0 {
-		return 0
+	if auth.Pending {
+		return chargeOutcome{status: models.PaymentStatusPending, reference: auth.TransactionID}, nil
 	}
-
-	if rate > 1 {
-		return 1
+	if !auth.Approved {
+		return chargeOutcome{status: models.PaymentStatusFailed, reference: auth.TransactionID, failure: errors.New(auth.DeclineReason)}, nil
+	}
+	if deferCapture {
+		return chargeOutcome{status: models.PaymentStatusAuthorized, reference: auth.TransactionID}, nil
 	}
 
-	return rate
+	captured, err := gw.Capture(ctx, auth.TransactionID, payment.AmountMinor)
+	if err != nil {
+		return chargeOutcome{reference: auth.TransactionID}, fmt.Errorf("failed to capture payment: %w", err)
+	}
+	if captured.Pending {
+		return chargeOutcome{status: models.PaymentStatusPending, reference: auth.TransactionID}, nil
+	}
+	if !captured.Approved {
+		return chargeOutcome{status: models.PaymentStatusFailed, reference: auth.TransactionID, failure: errors.New(captured.DeclineReason)}, nil
+	}
+	return chargeOutcome{status: models.PaymentStatusSuccess, transactionID: captured.TransactionID, reference: auth.TransactionID}, nil
 }
 
 func (c saramaHeaderCarrierConsumer) Keys() []string {
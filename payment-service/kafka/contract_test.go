@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"payment-svc/models"
+)
+
+// eventContract declares the fields of an event a consumer relies on. The provider
+// verifies the same file against its event models: payment-service reads the
+// contracts of order-service events and verifies those of its own.
+type eventContract struct {
+	Provider  string            `json:"provider"`
+	Consumer  string            `json:"consumer"`
+	EventType string            `json:"event_type"`
+	Fields    map[string]string `json:"fields"`
+}
+
+func loadContract(t *testing.T, name string) eventContract {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "contracts", "order_events", name))
+	if err != nil {
+		t.Fatalf("Failed to read contract: %v", err)
+	}
+
+	var contract eventContract
+	if err := json.Unmarshal(data, &contract); err != nil {
+		t.Fatalf("Failed to parse contract: %v", err)
+	}
+	return contract
+}
+
+// samplePayload builds the smallest event the provider may send under the contract:
+// only the declared fields, each with a non-zero value of its declared type
+func samplePayload(t *testing.T, contract eventContract) []byte {
+	t.Helper()
+
+	event := map[string]interface{}{}
+	for field, kind := range contract.Fields {
+		switch kind {
+		case "string":
+			event[field] = "sample"
+		case "number":
+			event[field] = 7
+		case "boolean":
+			event[field] = true
+		case "object":
+			event[field] = map[string]string{"sample": "sample"}
+		default:
+			t.Fatalf("Unsupported type %q for field %s", kind, field)
+		}
+	}
+	event["event_type"] = contract.EventType
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal sample event: %v", err)
+	}
+	return data
+}
+
+func TestContract_OrderCreated(t *testing.T) {
+	contract := loadContract(t, "order_created.payment-service.json")
+
+	// Every field the consumer decodes must be declared...
+	eventType := reflect.TypeOf(orderCreatedEvent{})
+	for i := 0; i < eventType.NumField(); i++ {
+		tag := strings.Split(eventType.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := contract.Fields[tag]; !ok {
+			t.Errorf("orderCreatedEvent reads %q, which the contract doesn't declare", tag)
+		}
+	}
+
+	// ...and decode from a payload holding only the declared fields
+	var event orderCreatedEvent
+	if err := json.Unmarshal(samplePayload(t, contract), &event); err != nil {
+		t.Fatalf("Contract payload doesn't decode: %v", err)
+	}
+	value := reflect.ValueOf(event)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).IsZero() {
+			t.Errorf("orderCreatedEvent.%s is empty after decoding the contract payload", eventType.Field(i).Name)
+		}
+	}
+}
+
+func TestContract_RefundRequested(t *testing.T) {
+	contract := loadContract(t, "refund_requested.payment-service.json")
+
+	eventType := reflect.TypeOf(refundRequestedEvent{})
+	for i := 0; i < eventType.NumField(); i++ {
+		tag := strings.Split(eventType.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := contract.Fields[tag]; !ok {
+			t.Errorf("refundRequestedEvent reads %q, which the contract doesn't declare", tag)
+		}
+	}
+
+	var event refundRequestedEvent
+	if err := json.Unmarshal(samplePayload(t, contract), &event); err != nil {
+		t.Fatalf("Contract payload doesn't decode: %v", err)
+	}
+	value := reflect.ValueOf(event)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).IsZero() {
+			t.Errorf("refundRequestedEvent.%s is empty after decoding the contract payload", eventType.Field(i).Name)
+		}
+	}
+}
+
+// providedEvents are the events payment-service publishes, as built by the consumer
+var providedEvents = map[string]models.PaymentEvent{
+	"refund_completed": {
+		EventID:       "refund-5",
+		PaymentID:     5,
+		RefundID:      11,
+		OrderID:       1,
+		UserID:        2,
+		Amount:        99.99,
+		Status:        models.PaymentStatusRefunded,
+		EventType:     "refund_completed",
+		TransactionID: "TXN-1",
+		Region:        "eu-west",
+	},
+	"refund_failed": {
+		PaymentID:     5,
+		RefundID:      12,
+		OrderID:       1,
+		UserID:        2,
+		Status:        models.PaymentStatusFailed,
+		EventType:     "refund_failed",
+		Region:        "eu-west",
+		FailureReason: "insufficient_funds",
+	},
+}
+
+func TestContracts_PaymentEvents(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "contracts", "order_events", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list contracts: %v", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+
+		var contract eventContract
+		if err := json.Unmarshal(data, &contract); err != nil {
+			t.Fatalf("Failed to parse %s: %v", path, err)
+		}
+		if contract.Provider != "payment-service" {
+			continue
+		}
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			event, ok := providedEvents[contract.EventType]
+			if !ok {
+				t.Fatalf("%s expects %s events, which payment-service doesn't publish", contract.Consumer, contract.EventType)
+			}
+
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				t.Fatalf("Failed to unmarshal event: %v", err)
+			}
+
+			for field, kind := range contract.Fields {
+				value, ok := fields[field]
+				if !ok {
+					t.Errorf("%s relies on %q, which the %s event no longer has", contract.Consumer, field, contract.EventType)
+					continue
+				}
+				if got := jsonKind(value); got != kind {
+					t.Errorf("%s expects %q to be a %s, got %s", contract.Consumer, field, kind, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-svc/lock"
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// pendingTimeout is how long a payment may stay pending, waiting on the gateway or on
+// a charge that keeps failing, before it is failed
+var pendingTimeout = getEnvDuration("PAYMENT_PENDING_TIMEOUT", time.Hour)
+
+const (
+	pendingSweepInterval = time.Minute
+	pendingSweepBatch    = 100
+	// pendingExpiredReason is the failure reason of payments failed by the sweep
+	pendingExpiredReason = "payment expired"
+
+	// pendingSweepLock keeps the sweep to one replica per tick
+	pendingSweepLock    = "payment-pending-sweep"
+	pendingSweepLockTTL = 30 * time.Second
+)
+
+// ExpireStalePayments fails payments pending for longer than PAYMENT_PENDING_TIMEOUT
+// and publishes payment_failed for each, so order-service fails the order and releases
+// its stock. Each one is logged with the trace of the order_created event that recorded
+// it. Payments are claimed with FOR UPDATE SKIP LOCKED, so replicas sweep disjoint
+// batches. It returns how many it failed.
+func ExpireStalePayments(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, logger *zap.Logger) (int, error) {
+	rows, err := db.QueryContext(ctx,
+		`UPDATE payments
+		SET status = $1, failure_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM payments
+			WHERE status = 'pending' AND updated_at < CURRENT_TIMESTAMP - $3 * INTERVAL '1 second'
+			ORDER BY updated_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		) AND status = 'pending'
+		RETURNING `+paymentColumns+`, COALESCE(trace_id, '')`,
+		models.PaymentStatusFailed, pendingExpiredReason, int64(pendingTimeout/time.Second), pendingSweepBatch,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire pending payments: %w", err)
+	}
+	var expired []models.Payment
+	var traceIDs []string
+	for rows.Next() {
+		var traceID string
+		p, err := scanPayment(rows, &traceID)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		expired = append(expired, p)
+		traceIDs = append(traceIDs, traceID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to expire pending payments: %w", err)
+	}
+
+	for i, payment := range expired {
+		logger.Warn("Pending payment expired",
+			zap.String("trace_id", traceIDs[i]),
+			zap.Int("payment_id", payment.ID),
+			zap.Int("order_id", payment.OrderID),
+			zap.String("gateway", payment.Gateway),
+			zap.String("gateway_reference", payment.GatewayReference),
+			zap.Duration("age", time.Since(payment.CreatedAt)),
+		)
+		middleware.RecordPaymentProcessed(string(payment.Status), payment.Gateway, payment.Currency, time.Since(payment.CreatedAt), payment.Amount)
+		if err := PublishPaymentOutcome(ctx, db, producer, payment, nil, logger); err != nil {
+			// order-service's own expiry cancels the order in the end
+			logger.Error("Failed to publish payment event",
+				zap.String("trace_id", traceIDs[i]),
+				zap.Int("payment_id", payment.ID),
+				zap.Error(err),
+			)
+		}
+	}
+	if len(expired) > 0 {
+		logger.Warn("Expired stale pending payments", zap.Int("count", len(expired)), zap.Strings("trace_ids", traceIDs))
+	}
+	return len(expired), nil
+}
+
+// RunPendingSweeper expires stale pending payments every minute until ctx is done. Each
+// sweep runs under a distributed lock, so only one replica sweeps per tick.
+func RunPendingSweeper(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, locker *lock.Locker, logger *zap.Logger) {
+	ticker := time.NewTicker(pendingSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := locker.WithLock(ctx, pendingSweepLock, pendingSweepLockTTL, func(ctx context.Context) error {
+			_, err := ExpireStalePayments(ctx, db, producer, logger)
+			return err
+		})
+		switch {
+		case errors.Is(err, lock.ErrNotAcquired):
+			logger.Debug("Pending payment sweep already running on another replica")
+		case err != nil && ctx.Err() == nil:
+			logger.Error("Failed to expire stale pending payments", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestExpireStalePayments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("UPDATE payments SET status = \\$1, failure_reason = \\$2.* WHERE status = 'pending' AND updated_at < .* FOR UPDATE SKIP LOCKED.* RETURNING .*trace_id").
+		WithArgs(models.PaymentStatusFailed, "payment expired", int64(pendingTimeout/time.Second), pendingSweepBatch).
+		WillReturnRows(sqlmock.NewRows(append(kafkaPaymentColumns, "trace_id")).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusFailed, "", "us-east-1", "default", "simulated", "REF-1", "payment expired", 0, time.Now().Add(-2*time.Hour), time.Now(), "4bf92f3577b34da6a3ce929d0e0e4736"))
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	expired, err := ExpireStalePayments(context.Background(), db, producer, zaptest.NewLogger(t))
+	if err != nil || expired != 1 {
+		t.Fatalf("Expected one payment expired, got %d and %v", expired, err)
+	}
+	// order-service fails the order and releases its stock
+	if event.EventType != "payment_failed" || event.EventID != "payment-5" || event.FailureReason != "payment expired" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestExpireStalePayments_NoneStale(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("UPDATE payments").
+		WillReturnRows(sqlmock.NewRows(append(kafkaPaymentColumns, "trace_id")))
+
+	expired, err := ExpireStalePayments(context.Background(), db, producer, zaptest.NewLogger(t))
+	if err != nil || expired != 0 {
+		t.Fatalf("Expected nothing expired, got %d and %v", expired, err)
+	}
+}
@@ -0,0 +1,116 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"payment-svc/gateway"
+	"payment-svc/middleware"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrFailedEventNotFound is returned by ReplayFailedEvent for an unknown ID
+	ErrFailedEventNotFound = errors.New("failed event not found")
+	// ErrFailedEventReplayed is returned by ReplayFailedEvent for an event a replay
+	// already handled
+	ErrFailedEventReplayed = errors.New("failed event already replayed")
+	// ErrReplayFailed matches errors from handling a replayed event
+	ErrReplayFailed = errors.New("replay failed")
+)
+
+// StoreFailedEvent keeps message, whose decoding or handling failed with cause, in
+// failed_events with its key, headers and payload as received. A message failing again
+// on redelivery updates its row with the latest error.
+func StoreFailedEvent(ctx context.Context, db *sql.DB, message *sarama.ConsumerMessage, cause error) error {
+	headers := map[string]string{}
+	for _, h := range message.Headers {
+		if h != nil {
+			headers[string(h.Key)] = string(h.Value)
+		}
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	// Undecodable payloads are stored without an event type
+	header, _ := readEventHeader(message.Value)
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO failed_events (topic, partition, "offset", message_key, payload, headers, event_type, error)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+		 ON CONFLICT (topic, partition, "offset") DO UPDATE
+		 SET error = EXCLUDED.error, status = 'failed', attempts = failed_events.attempts + 1, updated_at = CURRENT_TIMESTAMP`,
+		message.Topic, message.Partition, message.Offset, message.Key, message.Value, headersJSON, header.EventType, cause.Error(),
+	)
+	return err
+}
+
+// storeFailed keeps message, whose handling failed with cause, for replay
+func (h *paymentConsumerGroupHandler) storeFailed(message *sarama.ConsumerMessage, cause error) {
+	if err := StoreFailedEvent(context.Background(), h.db, message, cause); err != nil {
+		h.logger.Error("Failed to store failed event",
+			zap.String("topic", message.Topic),
+			zap.Int32("partition", message.Partition),
+			zap.Int64("offset", message.Offset),
+			zap.Error(err),
+		)
+		return
+	}
+	middleware.RecordFailedEventStored(message.Topic)
+}
+
+// ReplayFailedEvent handles the failed event id again as if it had just been consumed,
+// and marks it replayed once handled. A replay failing again records the new error and
+// returns it wrapped in ErrReplayFailed. Handling is idempotent, so replaying an event
+// the consumer has since handled changes nothing.
+func ReplayFailedEvent(ctx context.Context, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, orders OrderLookup, id int64, logger *zap.Logger) error {
+	message := &sarama.ConsumerMessage{}
+	var headersJSON []byte
+	var status models.FailedEventStatus
+	err := db.QueryRowContext(ctx,
+		`SELECT topic, partition, "offset", message_key, payload, headers, status FROM failed_events WHERE id = $1`, id,
+	).Scan(&message.Topic, &message.Partition, &message.Offset, &message.Key, &message.Value, &headersJSON, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrFailedEventNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == models.FailedEventReplayed {
+		return ErrFailedEventReplayed
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal(headersJSON, &headers); err != nil {
+		return fmt.Errorf("failed to decode headers: %w", err)
+	}
+	// The trace context travels in the headers, so the replay joins the original trace
+	for key, value := range headers {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+
+	if handleErr := handleMessage(message, db, producer, merchants, orders, logger); handleErr != nil {
+		middleware.RecordFailedEventReplay("failed")
+		if _, err := db.ExecContext(ctx,
+			"UPDATE failed_events SET error = $1, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			handleErr.Error(), id,
+		); err != nil {
+			logger.Error("Failed to record replay failure", zap.Int64("failed_event_id", id), zap.Error(err))
+		}
+		return fmt.Errorf("%w: %w", ErrReplayFailed, handleErr)
+	}
+
+	middleware.RecordFailedEventReplay("replayed")
+	_, err = db.ExecContext(ctx,
+		"UPDATE failed_events SET status = $1, replayed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		models.FailedEventReplayed, id,
+	)
+	return err
+}
@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStoreFailedEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	message := &sarama.ConsumerMessage{
+		Topic: "orders", Partition: 2, Offset: 42, Key: []byte("7"), Value: []byte(`{"event_type":`),
+		Headers: []*sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("00-abc")}},
+	}
+	cause := errors.New("failed to unmarshal event: unexpected end of JSON input")
+
+	// Undecodable payloads are kept byte for byte, without an event type
+	mock.ExpectExec(`INSERT INTO failed_events .* ON CONFLICT \(topic, partition, "offset"\) DO UPDATE`).
+		WithArgs("orders", int32(2), int64(42), []byte("7"), []byte(`{"event_type":`), []byte(`{"traceparent":"00-abc"}`), "", cause.Error()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := StoreFailedEvent(context.Background(), db, message, cause); err != nil {
+		t.Fatalf("Expected the message stored, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+var failedEventMessageColumns = []string{"topic", "partition", "offset", "message_key", "payload", "headers", "status"}
+
+func TestReplayFailedEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		status  models.FailedEventStatus
+		wantErr error
+	}{
+		// Events this service doesn't handle are done as soon as they are read
+		{"handled", `{"event_type":"stock_release","order_id":7}`, models.FailedEventFailed, nil},
+		{"failing again", `{"event_type":`, models.FailedEventFailed, ErrReplayFailed},
+		{"already replayed", `{"event_type":"stock_release","order_id":7}`, models.FailedEventReplayed, ErrFailedEventReplayed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery(`SELECT topic, partition, "offset", message_key, payload, headers, status FROM failed_events WHERE id = \$1`).
+				WithArgs(int64(3)).
+				WillReturnRows(sqlmock.NewRows(failedEventMessageColumns).
+					AddRow("orders", 0, 42, []byte("7"), []byte(tt.payload), []byte(`{"traceparent":"00-abc"}`), tt.status))
+			switch tt.wantErr {
+			case nil:
+				mock.ExpectExec("UPDATE failed_events SET status = \\$1, replayed_at = CURRENT_TIMESTAMP").
+					WithArgs(models.FailedEventReplayed, int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			case ErrReplayFailed:
+				// The new error is kept for the next attempt
+				mock.ExpectExec("UPDATE failed_events SET error = \\$1, attempts = attempts \\+ 1").
+					WithArgs(sqlmock.AnyArg(), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			err = ReplayFailedEvent(context.Background(), db, nil, gateway.Single(&approvingGateway{}), matchingOrder(19.98), 3, zaptest.NewLogger(t))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplayFailedEvent_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM failed_events WHERE id = \\$1").
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(failedEventMessageColumns))
+
+	err = ReplayFailedEvent(context.Background(), db, nil, gateway.Single(&approvingGateway{}), matchingOrder(19.98), 9, zaptest.NewLogger(t))
+	if !errors.Is(err, ErrFailedEventNotFound) {
+		t.Fatalf("Expected ErrFailedEventNotFound, got %v", err)
+	}
+}
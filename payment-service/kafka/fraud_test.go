@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"payment-svc/fraud"
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProcessPayment_FlaggedPaymentIsCharged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	// Four other payments within the hour score 0.8
+	expectFraudCheck(mock, 4)
+	mock.ExpectExec("INSERT INTO payment_fraud_checks .* ON CONFLICT \\(payment_id\\) DO NOTHING").
+		WithArgs(5, 0.8, fraud.DecisionFlag, "high_velocity").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusSuccess, "TXN-1", "TXN-1", "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerJournals(mock, 2)
+	mock.ExpectCommit()
+
+	var flagged, outcome models.PaymentEvent
+	expectPaymentEvent(producer, &flagged)
+	expectPaymentEvent(producer, &outcome)
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if flagged.EventType != "payment_flagged" || flagged.EventID != "flagged-5" || flagged.RiskScore != 0.8 ||
+		flagged.RiskDecision != "flag" || len(flagged.RiskReasons) != 1 || flagged.RiskReasons[0] != "high_velocity" {
+		t.Errorf("Unexpected flagged event: %+v", flagged)
+	}
+	if len(gw.authorized) != 1 || outcome.EventType != "payment_success" {
+		t.Errorf("Expected the flagged payment to be charged, got %+v", outcome)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_DeclinedPaymentIsNotCharged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 5)
+	mock.ExpectExec("INSERT INTO payment_fraud_checks .* ON CONFLICT \\(payment_id\\) DO NOTHING").
+		WithArgs(5, 1.0, fraud.DecisionDecline, "high_velocity").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusFailed, "", "", "declined by fraud check", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var flagged, outcome models.PaymentEvent
+	expectPaymentEvent(producer, &flagged)
+	expectPaymentEvent(producer, &outcome)
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 0 {
+		t.Errorf("Expected nothing to be charged, got %+v", gw.authorized)
+	}
+	if flagged.EventType != "payment_flagged" || flagged.RiskDecision != "decline" {
+		t.Errorf("Unexpected flagged event: %+v", flagged)
+	}
+	if outcome.EventType != "payment_failed" || outcome.FailureReason != "declined by fraud check" {
+		t.Errorf("Unexpected payment event: %+v", outcome)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"sync"
+
+	"payment-svc/middleware"
+)
+
+// partitionLag reports a claimed partition's consumer lag: its high-water mark minus the
+// offset marked for commit. It follows the messages received and the offsets marked, so
+// a backlog shows while payments are slow even though nothing is failing.
+type partitionLag struct {
+	topic     string
+	partition int32
+
+	mu sync.Mutex
+	// committed is the next offset to consume once marks are committed, -1 until the
+	// first message arrives
+	committed int64
+	highWater int64
+}
+
+func newPartitionLag(topic string, partition int32) *partitionLag {
+	return &partitionLag{topic: topic, partition: partition, committed: -1}
+}
+
+// received records a message at offset and the partition's high-water mark when it was
+// fetched
+func (l *partitionLag) received(offset, highWater int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.committed < 0 {
+		// The claim resumes from the committed offset
+		l.committed = offset
+	}
+	l.highWater = highWater
+	middleware.SetConsumerLag(l.topic, l.partition, l.lag())
+}
+
+// marked records that offset was marked for commit, counting the messages it moved past
+func (l *partitionLag) marked(offset int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if offset <= l.committed {
+		return
+	}
+	middleware.RecordConsumerMarked(l.topic, offset-l.committed)
+	l.committed = offset
+	middleware.SetConsumerLag(l.topic, l.partition, l.lag())
+}
+
+func (l *partitionLag) lag() int64 {
+	return max(l.highWater-l.committed, 0)
+}
+
+// clear drops the partition's lag once the claim ends, as it may move to another member
+func (l *partitionLag) clear() {
+	middleware.ClearConsumerLag(l.topic, l.partition)
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import "testing"
+
+func TestPartitionLag(t *testing.T) {
+	lag := newPartitionLag("orders", 0)
+
+	// Resumed at offset 10 with 15 messages produced so far
+	lag.received(10, 15)
+	if got := lag.lag(); got != 5 {
+		t.Fatalf("Expected a lag of 5, got %d", got)
+	}
+
+	// More messages were produced while 10 and 11 were handled
+	lag.received(11, 20)
+	lag.marked(12)
+	if got := lag.lag(); got != 8 {
+		t.Errorf("Expected a lag of 8, got %d", got)
+	}
+
+	// A stale mark never moves the committed offset back
+	lag.marked(11)
+	if got := lag.lag(); got != 8 {
+		t.Errorf("Expected a lag of 8 after a stale mark, got %d", got)
+	}
+
+	lag.marked(20)
+	if got := lag.lag(); got != 0 {
+		t.Errorf("Expected no lag once caught up, got %d", got)
+	}
+	lag.clear()
+}
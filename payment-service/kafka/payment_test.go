@@ -0,0 +1,390 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+// approvingGateway approves everything at once, remembering the authorizations, and
+// the references captured and voided
+type approvingGateway struct {
+	authorized []gateway.AuthorizeRequest
+	captured   []string
+	voided     []string
+}
+
+func (g *approvingGateway) Name() string { return "simulated" }
+
+func (g *approvingGateway) Authorize(_ context.Context, req gateway.AuthorizeRequest) (gateway.Result, error) {
+	g.authorized = append(g.authorized, req)
+	return gateway.Result{Approved: true, TransactionID: "TXN-1"}, nil
+}
+
+func (g *approvingGateway) Capture(_ context.Context, transactionID string, _ int64) (gateway.Result, error) {
+	g.captured = append(g.captured, transactionID)
+	return gateway.Result{Approved: true, TransactionID: transactionID}, nil
+}
+
+func (g *approvingGateway) Void(_ context.Context, transactionID string) (gateway.Result, error) {
+	g.voided = append(g.voided, transactionID)
+	return gateway.Result{Approved: true, TransactionID: transactionID}, nil
+}
+
+func (g *approvingGateway) Refund(_ context.Context, transactionID string, _ int64) (gateway.Result, error) {
+	return gateway.Result{Approved: true, TransactionID: "RFND-" + transactionID}, nil
+}
+
+const orderCreatedValue = `{"event_type":"order_created","order_id":7,"user_id":3,"total_price":19.98,"region":"us-east-1"}`
+
+func TestProcessPayment_ChargesOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WithArgs(7, 3, int64(1998), "USD", models.PaymentStatusPending, "us-east-1", "simulated", sqlmock.AnyArg(), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusSuccess, "TXN-1", "TXN-1", "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerJournals(mock, 2)
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 1 || gw.authorized[0].IdempotencyKey != "payment-5" {
+		t.Errorf("Expected one authorization keyed by the payment, got %+v", gw.authorized)
+	}
+	if event.EventType != "payment_success" || event.EventID != "payment-5" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_DuplicateRepublishesOutcome(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1 AND status <> 'duplicate'").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "user_id", "amount_minor", "currency", "status", "transaction_id", "region", "merchant_id", "failure_reason", "payment_method_id"}).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusFailed, "", "us-east-1", "default", "insufficient_funds", 2))
+	mock.ExpectQuery("SELECT id, type, COALESCE\\(brand, ''\\), last4 FROM payment_methods WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "brand", "last4"}).AddRow(2, "card", "visa", "4242"))
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 0 {
+		t.Errorf("Expected the order not to be charged again, got %+v", gw.authorized)
+	}
+	// The same event ID lets order-service skip it if the first copy arrived
+	if event.EventType != "payment_failed" || event.EventID != "payment-5" || event.FailureReason != "insufficient_funds" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+	if event.PaymentMethod == nil || event.PaymentMethod.ID != 2 || event.PaymentMethod.Last4 != "4242" {
+		t.Errorf("Expected the event to name the payment method, got %+v", event.PaymentMethod)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// expectLedgerJournals expects n journals to be booked in the ledger
+func expectLedgerJournals(mock sqlmock.Sqlmock, n int) {
+	for i := 0; i < n; i++ {
+		mock.ExpectExec("INSERT INTO ledger_entries .* ON CONFLICT \\(journal_id, account, direction\\) DO NOTHING").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+}
+
+// expectFraudCheck expects payment 5's fraud check to find user 3's recent other
+// payments
+func expectFraudCheck(mock sqlmock.Sqlmock, recent int) {
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM payments WHERE user_id = \\$1 AND id <> \\$2").
+		WithArgs(3, 5, 3600).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(recent))
+}
+
+var paymentMethodRowColumns = []string{"id", "user_id", "type", "brand", "last4", "exp_month", "exp_year", "gateway_token", "is_default", "created_at", "updated_at"}
+
+// expectNoDefaultPaymentMethod expects the lookup of user 3's default payment method,
+// finding none
+func expectNoDefaultPaymentMethod(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT .* FROM payment_methods WHERE user_id = \\$1 AND is_default").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(paymentMethodRowColumns))
+}
+
+func TestProcessPayment_ChargesChosenPaymentMethod(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("SELECT .* FROM payment_methods WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(2, 3).
+		WillReturnRows(sqlmock.NewRows(paymentMethodRowColumns).
+			AddRow(2, 3, "card", "visa", "4242", 12, 2030, "pm_card_visa", false, time.Now(), time.Now()))
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusSuccess, "TXN-1", "TXN-1", "", 2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerJournals(mock, 2)
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := `{"event_type":"order_created","order_id":7,"user_id":3,"total_price":19.98,"region":"us-east-1","payment_method_id":2}`
+	if err := processPayment(context.Background(), []byte(value), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 1 || gw.authorized[0].PaymentMethod != "pm_card_visa" {
+		t.Errorf("Expected the method's token to be charged, got %+v", gw.authorized)
+	}
+	want := models.PaymentMethodSummary{ID: 2, Type: models.PaymentMethodTypeCard, Brand: "visa", Last4: "4242"}
+	if event.PaymentMethod == nil || *event.PaymentMethod != want {
+		t.Errorf("Expected the event to carry %+v, got %+v", want, event.PaymentMethod)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_UnknownPaymentMethodFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	// Another user's method isn't found either
+	mock.ExpectQuery("SELECT .* FROM payment_methods WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(9, 3).
+		WillReturnRows(sqlmock.NewRows(paymentMethodRowColumns))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusFailed, "", "", "payment method not found", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := `{"event_type":"order_created","order_id":7,"user_id":3,"total_price":19.98,"region":"us-east-1","payment_method_id":9}`
+	if err := processPayment(context.Background(), []byte(value), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 0 {
+		t.Errorf("Expected nothing to be charged, got %+v", gw.authorized)
+	}
+	if event.EventType != "payment_failed" || event.FailureReason != "payment method not found" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// pendingGateway leaves every authorization for the webhook to settle
+type pendingGateway struct {
+	approvingGateway
+}
+
+func (g *pendingGateway) Authorize(context.Context, gateway.AuthorizeRequest) (gateway.Result, error) {
+	return gateway.Result{Pending: true, TransactionID: "pi_123"}, nil
+}
+
+func TestProcessPayment_PendingWaitsForWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	// No event is expected: the gateway webhook publishes the outcome
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments .* WHERE id = \\$6 AND status = 'pending'").
+		WithArgs(models.PaymentStatusPending, "", "pi_123", "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(&pendingGateway{}), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// hangingGateway never answers an authorization
+type hangingGateway struct {
+	approvingGateway
+}
+
+func (g *hangingGateway) Authorize(ctx context.Context, _ gateway.AuthorizeRequest) (gateway.Result, error) {
+	<-ctx.Done()
+	return gateway.Result{}, ctx.Err()
+}
+
+func TestProcessPayment_TimeoutLeavesPaymentForReview(t *testing.T) {
+	previous := processingTimeout
+	processingTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { processingTimeout = previous })
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	// The authorization the timeout cut short is recorded all the same
+	mock.ExpectExec("INSERT INTO payment_attempts").
+		WithArgs(5, "authorize", "simulated", models.PaymentAttemptError, "", "", context.DeadlineExceeded.Error(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments .* WHERE id = \\$6 AND status = 'pending'").
+		WithArgs(models.PaymentStatusPendingReview, "", "", "payment processing timed out", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(&hangingGateway{}), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if event.EventType != "payment_timeout" || event.EventID != "timeout-5" || event.Status != models.PaymentStatusPendingReview ||
+		event.FailureReason != "payment processing timed out" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_ChargesInOrderCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &approvingGateway{}
+
+	// Yen have no minor unit
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WithArgs(7, 3, int64(1500), "JPY", models.PaymentStatusPending, "us-east-1", "simulated", sqlmock.AnyArg(), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusSuccess, "TXN-1", "TXN-1", "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerJournals(mock, 2)
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := `{"event_type":"order_created","order_id":7,"user_id":3,"total_price":1500,"currency":"JPY","region":"us-east-1"}`
+	if err := processPayment(context.Background(), []byte(value), db, producer, gateway.Single(gw), matchingOrder(1500), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(gw.authorized) != 1 || gw.authorized[0].AmountMinor != 1500 || gw.authorized[0].Currency != "JPY" {
+		t.Errorf("Expected 1500 JPY to be authorized, got %+v", gw.authorized)
+	}
+	if event.Amount != 1500 || event.AmountMinor != 1500 || event.Currency != "JPY" {
+		t.Errorf("Unexpected payment event amount: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_RejectsInvalidCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	value := `{"event_type":"order_created","order_id":7,"user_id":3,"total_price":19.98,"currency":"dollars","region":"us-east-1"}`
+	if err := processPayment(context.Background(), []byte(value), db, producer, gateway.Single(&approvingGateway{}), matchingOrder(19.98), zaptest.NewLogger(t)); err == nil {
+		t.Fatal("Expected an invalid currency to be rejected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// consumerWorkers is how many messages are handled at once across all claimed partitions
+var consumerWorkers = getEnvInt("PAYMENT_CONSUMER_WORKERS", 8)
+
+// workerQueueSize is how many messages wait for each worker before dispatch blocks
+const workerQueueSize = 16
+
+type job struct {
+	message *sarama.ConsumerMessage
+	done    func(err error)
+}
+
+// workerPool handles messages on a fixed number of goroutines. Messages with the same
+// key, the order ID, always go to the same worker, so an order's events are still
+// handled one at a time and in partition order.
+type workerPool struct {
+	queues []chan job
+	wg     sync.WaitGroup
+}
+
+func newWorkerPool(size int, handle func(*sarama.ConsumerMessage) error) *workerPool {
+	p := &workerPool{queues: make([]chan job, max(size, 1))}
+	for i := range p.queues {
+		p.queues[i] = make(chan job, workerQueueSize)
+		p.wg.Add(1)
+		go func(queue <-chan job) {
+			defer p.wg.Done()
+			for j := range queue {
+				j.done(handle(j.message))
+			}
+		}(p.queues[i])
+	}
+	return p
+}
+
+// submit queues message on its key's worker and calls done with the handling error.
+// It blocks while that worker's queue is full.
+func (p *workerPool) submit(message *sarama.ConsumerMessage, done func(err error)) {
+	p.queues[p.worker(message)] <- job{message: message, done: done}
+}
+
+func (p *workerPool) worker(message *sarama.ConsumerMessage) int {
+	if len(message.Key) == 0 {
+		// Unkeyed messages carry no order to keep, so spread them by offset
+		return int(message.Offset % int64(len(p.queues)))
+	}
+	h := fnv.New32a()
+	h.Write(message.Key)
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// stop lets the workers finish their queues and waits for them
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// offsetTracker works out which offset of a partition can be marked while its messages
+// finish out of order. As when they were handled one by one, the marked offset follows
+// the last message handled successfully, and never passes a message still being handled.
+type offsetTracker struct {
+	mu sync.Mutex
+	// dispatched holds the offsets being handled, oldest first. Offsets can have gaps,
+	// e.g. after compaction.
+	dispatched []int64
+	// finished holds the outcome of finished messages still behind an unfinished one:
+	// true if handled
+	finished map[int64]bool
+	wg       sync.WaitGroup
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{finished: map[int64]bool{}}
+}
+
+// dispatch records that offset is being handled
+func (t *offsetTracker) dispatch(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dispatched = append(t.dispatched, offset)
+	t.wg.Add(1)
+}
+
+// finish records offset's outcome and returns the offset that can now be marked, or -1
+// if it hasn't moved
+func (t *offsetTracker) finish(offset int64, handled bool) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.wg.Done()
+
+	t.finished[offset] = handled
+	mark := int64(-1)
+	for len(t.dispatched) > 0 {
+		oldest := t.dispatched[0]
+		handled, ok := t.finished[oldest]
+		if !ok {
+			break
+		}
+		delete(t.finished, oldest)
+		t.dispatched = t.dispatched[1:]
+		if handled {
+			mark = oldest + 1
+		}
+	}
+	return mark
+}
+
+// wait blocks until every dispatched message has finished
+func (t *offsetTracker) wait() {
+	t.wg.Wait()
+}
@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+func TestOffsetTracker_MarksOnlyBehindUnfinishedMessages(t *testing.T) {
+	tracker := newOffsetTracker()
+	for _, offset := range []int64{10, 11, 13, 14} {
+		tracker.dispatch(offset)
+	}
+
+	if got := tracker.finish(11, true); got != -1 {
+		t.Errorf("Expected no mark while 10 is being handled, got %d", got)
+	}
+	if got := tracker.finish(10, true); got != 12 {
+		t.Errorf("Expected 12 once 10 and 11 finished, got %d", got)
+	}
+	// 13 failed: like a serial consumer, it is only passed by a later success
+	if got := tracker.finish(13, false); got != -1 {
+		t.Errorf("Expected a failure not to be marked, got %d", got)
+	}
+	if got := tracker.finish(14, true); got != 15 {
+		t.Errorf("Expected 15 once 14 succeeded, got %d", got)
+	}
+	tracker.wait()
+}
+
+func TestWorkerPool_KeepsKeyOrderAndRunsKeysInParallel(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[string][]int64{}
+	release := make(chan struct{})
+
+	pool := newWorkerPool(4, func(message *sarama.ConsumerMessage) error {
+		if string(message.Key) == "slow" {
+			<-release
+		}
+		mu.Lock()
+		handled[string(message.Key)] = append(handled[string(message.Key)], message.Offset)
+		mu.Unlock()
+		if message.Offset == 3 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	// Pick a fast key on another worker than the slow one
+	slow := &sarama.ConsumerMessage{Key: []byte("slow")}
+	fastKey := ""
+	for _, key := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		if pool.worker(&sarama.ConsumerMessage{Key: []byte(key)}) != pool.worker(slow) {
+			fastKey = key
+			break
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	messages := []*sarama.ConsumerMessage{
+		{Key: []byte("slow"), Offset: 0},
+		{Key: []byte(fastKey), Offset: 1},
+		{Key: []byte("slow"), Offset: 2},
+		{Key: []byte(fastKey), Offset: 3},
+	}
+	fastDone := make(chan struct{})
+	for i, message := range messages {
+		wg.Add(1)
+		pool.submit(message, func(err error) {
+			errs[i] = err
+			if i == 3 {
+				close(fastDone)
+			}
+			wg.Done()
+		})
+	}
+
+	select {
+	case <-fastDone:
+	case <-time.After(time.Second):
+		t.Fatal("Expected another key to be handled while the slow one waits")
+	}
+	close(release)
+	wg.Wait()
+	pool.stop()
+
+	if got := handled["slow"]; len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("Expected the slow key in offset order, got %v", got)
+	}
+	if got := handled[fastKey]; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("Expected the fast key in offset order, got %v", got)
+	}
+	if errs[3] == nil || errs[1] != nil {
+		t.Errorf("Expected only offset 3 to fail, got %v", errs)
+	}
+}
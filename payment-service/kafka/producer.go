@@ -5,15 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 
 	"payment-svc/models"
+	"payment-svc/startup"
 
 	"github.com/IBM/sarama"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// paymentsTopic is where this service publishes its payment events
+var paymentsTopic = getEnv("KAFKA_PAYMENTS_TOPIC", "payments")
+
 func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
@@ -22,7 +28,11 @@ func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 
 	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	var producer sarama.SyncProducer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		producer, err = sarama.NewSyncProducer(brokers, config)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
 	}
@@ -31,7 +41,48 @@ func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
 	return producer, nil
 }
 
+// InitTransactionalProducer returns the consumer's producer for KAFKA_TRANSACTIONAL_ID,
+// whose sends only go out with the transaction that commits the consumed message's
+// offset. It returns nil when KAFKA_TRANSACTIONAL_ID is unset.
+func InitTransactionalProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
+	transactionalID := getEnv("KAFKA_TRANSACTIONAL_ID", "")
+	if transactionalID == "" {
+		return nil, nil
+	}
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Idempotent = true
+	config.Producer.Transaction.ID = transactionalID
+	config.Net.MaxOpenRequests = 1
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+
+	var producer sarama.SyncProducer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		producer, err = sarama.NewSyncProducer(brokers, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transactional Kafka producer: %w", err)
+	}
+
+	logger.Info("Transactional Kafka producer initialized", zap.String("transactional_id", transactionalID))
+	return producer, nil
+}
+
+// PublishPaymentEvent publishes event keyed by its order ID, the same key order-service
+// uses, so a payment outcome lands on the partition of the order_created it answers. It
+// stamps the current schema version and gives events without an ID a random one.
 func PublishPaymentEvent(ctx context.Context, producer sarama.SyncProducer, topic string, event models.PaymentEvent, logger *zap.Logger) error {
+	event.EventVersion = models.EventVersion
+	if event.EventID == "" {
+		event.EventID = uuid.NewString()
+	}
+
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
@@ -39,6 +90,7 @@ func PublishPaymentEvent(ctx context.Context, producer sarama.SyncProducer, topi
 
 	msg := &sarama.ProducerMessage{
 		Topic:   topic,
+		Key:     sarama.StringEncoder(strconv.Itoa(event.OrderID)),
 		Value:   sarama.StringEncoder(eventJSON),
 		Headers: []sarama.RecordHeader{},
 	}
@@ -65,6 +117,7 @@ func PublishPaymentEvent(ctx context.Context, producer sarama.SyncProducer, topi
 		zap.String("trace_id", traceID),
 		zap.String("topic", topic),
 		zap.String("event_type", event.EventType),
+		zap.String("event_id", event.EventID),
 		zap.Int32("partition", partition),
 		zap.Int64("offset", offset),
 	)
@@ -0,0 +1,33 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPublishPaymentEvent_KeyedByOrderID(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Key == nil {
+			return fmt.Errorf("expected the message to be keyed by order ID")
+		}
+		if key, _ := msg.Key.Encode(); string(key) != "42" {
+			return fmt.Errorf("expected message key 42, got %q", key)
+		}
+		return nil
+	})
+
+	event := models.PaymentEvent{OrderID: 42, EventType: "payment_success"}
+	if err := PublishPaymentEvent(context.Background(), producer, paymentsTopic, event, zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"payment-svc/gateway"
+	"payment-svc/ledger"
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type refundRequestedEvent struct {
+	EventType string `json:"event_type"`
+	OrderID   int    `json:"order_id"`
+	UserID    int    `json:"user_id"`
+	Region    string `json:"region"`
+}
+
+// processRefund refunds the successful payment of a refund_requested order through its
+// merchant's gateway and answers with refund_completed, or refund_failed when the order
+// has no successful payment or the gateway doesn't refund it. A redelivered request finds
+// the payment already refunded and answers the same way without refunding it again.
+func processRefund(ctx context.Context, value []byte, db *sql.DB, producer sarama.SyncProducer, merchants *gateway.Merchants, logger *zap.Logger) error {
+	ctx, span := otel.Tracer("payment-service").Start(ctx, "ProcessRefund")
+	defer span.End()
+
+	// Extract trace ID for logging
+	traceID := ""
+	if span.SpanContext().IsValid() {
+		traceID = span.SpanContext().TraceID().String()
+	}
+
+	var request refundRequestedEvent
+	if err := json.Unmarshal(value, &request); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	if request.Region == "" {
+		request.Region = homeRegion
+	}
+
+	span.SetAttributes(
+		attribute.String("event.type", request.EventType),
+		attribute.Int("order.id", request.OrderID),
+		attribute.String("region", request.Region),
+	)
+
+	var payment models.Payment
+	err := retry(ctx, "load_payment", transientDBError, logger, func() (err error) {
+		payment, err = refundablePayment(ctx, db, request.OrderID)
+		return err
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		return fmt.Errorf("failed to load payment: %w", err)
+	}
+
+	// Left without an event ID: each failed request is answered on its own
+	event := models.PaymentEvent{
+		OrderID:   request.OrderID,
+		UserID:    request.UserID,
+		Status:    models.PaymentStatusFailed,
+		EventType: "refund_failed",
+		Region:    request.Region,
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		event.FailureReason = "no successful payment"
+		logger.Warn("No successful payment to refund",
+			zap.String("trace_id", traceID),
+			zap.Int("order_id", request.OrderID),
+		)
+	} else {
+		gw := merchants.For(payment.MerchantID)
+		span.SetAttributes(
+			attribute.Int("payment.id", payment.ID),
+			attribute.String("payment.gateway", gw.Name()),
+			attribute.String("merchant.id", payment.MerchantID),
+		)
+
+		event.MerchantID = payment.MerchantID
+		refund, err := refundThroughGateway(ctx, db, gw, &payment, logger)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+		span.SetAttributes(
+			attribute.Int("refund.id", refund.ID),
+			attribute.String("refund.status", string(refund.Status)),
+		)
+
+		if refund.Status == models.RefundStatusFailed {
+			event.PaymentID = payment.ID
+			event.RefundID = refund.ID
+			event.FailureReason = refund.FailureReason
+			logger.Warn("Gateway didn't refund payment",
+				zap.String("trace_id", traceID),
+				zap.Int("payment_id", payment.ID),
+				zap.Int("refund_id", refund.ID),
+				zap.Int("order_id", payment.OrderID),
+				zap.String("reason", refund.FailureReason),
+			)
+		} else {
+			event = models.PaymentEvent{
+				EventID:       fmt.Sprintf("refund-%d", payment.ID),
+				PaymentID:     payment.ID,
+				RefundID:      refund.ID,
+				OrderID:       payment.OrderID,
+				UserID:        payment.UserID,
+				Amount:        payment.Amount,
+				Status:        payment.Status,
+				EventType:     "refund_completed",
+				TransactionID: payment.TransactionID,
+				AmountMinor:   payment.AmountMinor,
+				Currency:      payment.Currency,
+				Region:        payment.Region,
+				MerchantID:    payment.MerchantID,
+			}
+			logger.Info("Payment refunded",
+				zap.String("trace_id", traceID),
+				zap.Int("payment_id", payment.ID),
+				zap.Int("refund_id", refund.ID),
+				zap.Int("order_id", payment.OrderID),
+				zap.Float64("amount", payment.Amount),
+				zap.String("currency", payment.Currency),
+			)
+		}
+	}
+
+	if err := PublishPaymentEvent(ctx, producer, paymentsTopic, event, logger); err != nil {
+		// Unlike a payment, nothing else settles the order, so the failure is surfaced
+		span.RecordError(err)
+		return fmt.Errorf("failed to publish %s event: %w", event.EventType, err)
+	}
+	return nil
+}
+
+// refundThroughGateway records a refund of payment's captured amount, asks the gateway
+// for it and, once approved, marks the payment refunded. The returned refund is failed,
+// with the reason, when the gateway didn't refund the payment; the error is for
+// failures to record the outcome. A payment refunded already isn't refunded again: its
+// succeeded refund is returned, which has no ID for payments refunded before refunds
+// were recorded.
+func refundThroughGateway(ctx context.Context, db *sql.DB, gw gateway.Gateway, payment *models.Payment, logger *zap.Logger) (models.Refund, error) {
+	if payment.Status == models.PaymentStatusRefunded {
+		refund, err := succeededRefund(ctx, db, payment.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Refund{PaymentID: payment.ID, Status: models.RefundStatusSucceeded}, nil
+		}
+		return refund, err
+	}
+
+	refund := models.Refund{
+		PaymentID:   payment.ID,
+		OrderID:     payment.OrderID,
+		Amount:      payment.Amount,
+		AmountMinor: payment.AmountMinor,
+		Currency:    payment.Currency,
+		Status:      models.RefundStatusPending,
+		Gateway:     gw.Name(),
+	}
+	if err := db.QueryRowContext(ctx,
+		"INSERT INTO refunds (payment_id, order_id, amount_minor, currency, status, gateway) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		refund.PaymentID, refund.OrderID, refund.AmountMinor, refund.Currency, refund.Status, refund.Gateway,
+	).Scan(&refund.ID); err != nil {
+		return refund, fmt.Errorf("failed to create refund record: %w", err)
+	}
+
+	// Only the provider that took the payment can return it
+	var result gateway.Result
+	var failure error
+	if payment.Gateway != gw.Name() {
+		failure = fmt.Errorf("payment was taken through the %s gateway, not %s", payment.Gateway, gw.Name())
+	} else {
+		failure = retry(ctx, "refund", transientGatewayError, logger, func() (err error) {
+			result, err = gw.Refund(ctx, payment.TransactionID, payment.AmountMinor)
+			return err
+		})
+		if failure == nil && !result.Approved {
+			failure = errors.New(result.DeclineReason)
+		}
+	}
+	refund.GatewayReference = result.TransactionID
+
+	if failure != nil {
+		refund.Status = models.RefundStatusFailed
+		refund.FailureReason = failure.Error()
+		if _, err := db.ExecContext(ctx,
+			`UPDATE refunds SET status = $1, gateway_reference = NULLIF($2, ''), failure_reason = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $4`,
+			refund.Status, refund.GatewayReference, refund.FailureReason, refund.ID,
+		); err != nil {
+			return refund, fmt.Errorf("failed to record refund failure: %w", err)
+		}
+		return refund, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return refund, err
+	}
+	defer tx.Rollback()
+
+	refund.Status = models.RefundStatusSucceeded
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE refunds SET status = $1, gateway_reference = NULLIF($2, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`,
+		refund.Status, refund.GatewayReference, refund.ID,
+	); err != nil {
+		return refund, fmt.Errorf("failed to record refund: %w", err)
+	}
+	if err := ledger.Record(ctx, tx, ledger.RefundJournal(refund)); err != nil {
+		return refund, err
+	}
+	// A concurrent redelivery may have marked it already; either way it is refunded
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE payments SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3",
+		models.PaymentStatusRefunded, payment.ID, models.PaymentStatusSuccess,
+	); err != nil {
+		return refund, fmt.Errorf("failed to mark payment refunded: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return refund, err
+	}
+
+	payment.Status = models.PaymentStatusRefunded
+	return refund, nil
+}
+
+// succeededRefund returns the refund that refunded the payment
+func succeededRefund(ctx context.Context, db *sql.DB, paymentID int) (models.Refund, error) {
+	var refund models.Refund
+	err := db.QueryRowContext(ctx,
+		`SELECT id, payment_id, order_id, amount_minor, currency, status, gateway, COALESCE(gateway_reference, '')
+		FROM refunds WHERE payment_id = $1 AND status = $2`,
+		paymentID, models.RefundStatusSucceeded,
+	).Scan(&refund.ID, &refund.PaymentID, &refund.OrderID, &refund.AmountMinor, &refund.Currency, &refund.Status, &refund.Gateway, &refund.GatewayReference)
+	refund.Amount = models.FromMinorUnits(refund.AmountMinor, refund.Currency)
+	return refund, err
+}
+
+// refundablePayment returns the order's payment if it is successful, or already
+// refunded. Payments recorded before gateways were stored were simulated. sql.ErrNoRows
+// means the order has none.
+func refundablePayment(ctx context.Context, db *sql.DB, orderID int) (models.Payment, error) {
+	var payment models.Payment
+	var amountMinor int64
+	var currency string
+	err := db.QueryRowContext(ctx,
+		`SELECT id, order_id, user_id, amount_minor, currency, status, COALESCE(transaction_id, ''), COALESCE(region, ''),
+			COALESCE(gateway, 'simulated'), merchant_id
+		FROM payments WHERE order_id = $1 AND status IN ($2, $3)`,
+		orderID, models.PaymentStatusSuccess, models.PaymentStatusRefunded,
+	).Scan(&payment.ID, &payment.OrderID, &payment.UserID, &amountMinor, &currency, &payment.Status, &payment.TransactionID, &payment.Region,
+		&payment.Gateway, &payment.MerchantID)
+	payment.SetAmount(amountMinor, currency)
+	return payment, err
+}
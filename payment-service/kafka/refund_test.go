@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+var refundablePaymentColumns = []string{"id", "order_id", "user_id", "amount_minor", "currency", "status", "transaction_id", "region", "gateway", "merchant_id"}
+
+// expectPaymentEvent makes producer capture the next event into event
+func expectPaymentEvent(producer *mocks.SyncProducer, event *models.PaymentEvent) {
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		payload, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, event)
+	})
+}
+
+func TestProcessRefund_RecordsRefund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1").
+		WithArgs(7, models.PaymentStatusSuccess, models.PaymentStatusRefunded).
+		WillReturnRows(sqlmock.NewRows(refundablePaymentColumns).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusSuccess, "TXN-7", "us-east-1", "simulated", "default"))
+	mock.ExpectQuery("INSERT INTO refunds").
+		WithArgs(5, 7, int64(1998), "USD", models.RefundStatusPending, "simulated").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refunds SET status = \\$1, gateway_reference").
+		WithArgs(models.RefundStatusSucceeded, "RFND-TXN-7", 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Paid back out of what the gateway holds
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(
+			"refund-11", "refund", "refunds", "debit", int64(1998), "USD", 5, 11,
+			"refund-11", "refund", "gateway_receivable", "credit", int64(1998), "USD", 5, 11,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE payments SET status = \\$1").
+		WithArgs(models.PaymentStatusRefunded, 5, models.PaymentStatusSuccess).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := []byte(`{"event_type":"refund_requested","order_id":7,"user_id":3}`)
+	if err := processRefund(context.Background(), value, db, producer, gateway.Single(gateway.NewSimulated(1)), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if event.EventType != "refund_completed" || event.RefundID != 11 || event.EventID != "refund-5" || event.Status != models.PaymentStatusRefunded {
+		t.Errorf("Unexpected refund event: %+v", event)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessRefund_OtherGatewayFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("SELECT .* FROM payments WHERE order_id = \\$1").
+		WillReturnRows(sqlmock.NewRows(refundablePaymentColumns).
+			AddRow(5, 7, 3, int64(1998), "USD", models.PaymentStatusSuccess, "pi_123", "us-east-1", "stripe", "default"))
+	mock.ExpectQuery("INSERT INTO refunds").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectExec("UPDATE refunds SET status = \\$1, gateway_reference = NULLIF\\(\\$2, ''\\), failure_reason = \\$3").
+		WithArgs(models.RefundStatusFailed, "", sqlmock.AnyArg(), 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	value := []byte(`{"event_type":"refund_requested","order_id":7,"user_id":3}`)
+	if err := processRefund(context.Background(), value, db, producer, gateway.Single(gateway.NewSimulated(1)), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The payment stays successful, so the refund can be requested again
+	if event.EventType != "refund_failed" || event.RefundID != 12 || event.FailureReason == "" {
+		t.Errorf("Unexpected refund event: %+v", event)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
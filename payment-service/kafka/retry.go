@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/middleware"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// retryAttempts is how many times an operation failing transiently is tried
+	retryAttempts = getEnvInt("PAYMENT_RETRY_ATTEMPTS", 5)
+	// retryBackoff is the wait after the first failure, doubled per attempt up to
+	// maxRetryBackoff
+	retryBackoff = getEnvDuration("PAYMENT_RETRY_BACKOFF", 500*time.Millisecond)
+)
+
+const maxRetryBackoff = 30 * time.Second
+
+// retry runs op until it succeeds, fails with an error transient doesn't accept, or
+// retryAttempts are used up, backing off exponentially between attempts. It returns
+// op's last error, or ctx's once the consumer is shutting down.
+func retry(ctx context.Context, operation string, transient func(error) bool, logger *zap.Logger, op func() error) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !transient(err) || attempt >= retryAttempts {
+			return err
+		}
+
+		middleware.RecordPaymentRetry(operation)
+		logger.Warn("Transient failure, retrying",
+			zap.String("operation", operation),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// transientDBError reports whether a database error may go away, like a lost
+// connection or a failover. Constraint violations and bad queries won't.
+func transientDBError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", "40", "53", "57": // connection, rollback, resources, operator intervention
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// transientGatewayError reports whether the gateway may answer a repeated call
+func transientGatewayError(err error) bool {
+	return errors.Is(err, gateway.ErrUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// transientOrderServiceError reports whether order-service may answer a repeated call
+func transientOrderServiceError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
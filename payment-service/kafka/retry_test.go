@@ -0,0 +1,178 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-svc/gateway"
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"github.com/lib/pq"
+	"go.uber.org/zap/zaptest"
+)
+
+// unavailableGateway fails every call as if the provider were down
+type unavailableGateway struct {
+	approvingGateway
+}
+
+func (g *unavailableGateway) Authorize(_ context.Context, req gateway.AuthorizeRequest) (gateway.Result, error) {
+	g.authorized = append(g.authorized, req)
+	return gateway.Result{}, fmt.Errorf("%w: connection refused", gateway.ErrUnavailable)
+}
+
+func withFastRetries(t *testing.T) {
+	t.Helper()
+	attempts, backoff := retryAttempts, retryBackoff
+	retryAttempts, retryBackoff = 3, time.Millisecond
+	t.Cleanup(func() { retryAttempts, retryBackoff = attempts, backoff })
+}
+
+func TestTransientDBError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("dial tcp: connection refused"), true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "23505"}, false},
+		{&pq.Error{Code: "42P01"}, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := transientDBError(tt.err); got != tt.want {
+			t.Errorf("%v: expected transient=%v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	withFastRetries(t)
+	logger := zaptest.NewLogger(t)
+
+	calls := 0
+	err := retry(context.Background(), "test", transientDBError, logger, func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Expected success on the second attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), "test", transientDBError, logger, func() error {
+		calls++
+		return &pq.Error{Code: "23505"}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected a constraint violation not to be retried, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), "test", transientDBError, logger, func() error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil || calls != retryAttempts {
+		t.Errorf("Expected %d attempts before giving up, got %v after %d calls", retryAttempts, err, calls)
+	}
+}
+
+func TestProcessPayment_GatewayOutageLeavesPaymentPending(t *testing.T) {
+	withFastRetries(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	gw := &unavailableGateway{}
+
+	// The database is briefly down too
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnError(errors.New("connection refused"))
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+
+	// Neither completed nor published: no payment_failed for an outage
+	err = processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t))
+	if !errors.Is(err, gateway.ErrUnavailable) {
+		t.Errorf("Expected the outage to be returned, got %v", err)
+	}
+	if len(gw.authorized) != retryAttempts {
+		t.Errorf("Expected %d authorization attempts, got %d", retryAttempts, len(gw.authorized))
+	}
+	for _, req := range gw.authorized {
+		if req.IdempotencyKey != "payment-5" {
+			t.Errorf("Expected every attempt keyed by the payment, got %q", req.IdempotencyKey)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProcessPayment_RefusedRequestFails(t *testing.T) {
+	withFastRetries(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(order_id\\) WHERE status <> 'duplicate' DO NOTHING").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	expectNoDefaultPaymentMethod(mock)
+	expectFraudCheck(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusFailed, "", "", sqlmock.AnyArg(), 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var event models.PaymentEvent
+	expectPaymentEvent(producer, &event)
+
+	gw := &refusingGateway{}
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), matchingOrder(19.98), zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gw.calls != 1 {
+		t.Errorf("Expected a refused request not to be retried, got %d calls", gw.calls)
+	}
+	if event.EventType != "payment_failed" || event.FailureReason == "" {
+		t.Errorf("Unexpected payment event: %+v", event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// refusingGateway rejects every authorization as an invalid request
+type refusingGateway struct {
+	approvingGateway
+	calls int
+}
+
+func (g *refusingGateway) Authorize(context.Context, gateway.AuthorizeRequest) (gateway.Result, error) {
+	g.calls++
+	return gateway.Result{}, errors.New("invalid API key")
+}
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"payment-svc/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+// eventSchema is the order_events schema shared by every service
+type eventSchema struct {
+	// Topics maps each topic to the service publishing on it
+	Topics         map[string]string `json:"topics"`
+	CurrentVersion int               `json:"current_version"`
+	Envelope       map[string]string `json:"envelope"`
+	Events         map[string]string `json:"events"`
+}
+
+func TestSchema_PaymentEvents(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "contracts", "schemas", "order_events.json"))
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var schema eventSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	if models.EventVersion != schema.CurrentVersion {
+		t.Errorf("payment-service publishes version %d, the schema is at %d", models.EventVersion, schema.CurrentVersion)
+	}
+	if supportedEventVersion < schema.CurrentVersion {
+		t.Errorf("payment-service reads up to version %d, the schema is at %d", supportedEventVersion, schema.CurrentVersion)
+	}
+	if publisher := schema.Topics[paymentsTopic]; publisher != "payment-service" {
+		t.Errorf("payment-service publishes on %s, which the schema lists as %q's", paymentsTopic, publisher)
+	}
+	if publisher := schema.Topics[ordersTopic]; publisher != "order-service" {
+		t.Errorf("payment-service consumes %s, which the schema lists as %q's", ordersTopic, publisher)
+	}
+
+	for _, eventType := range []string{"payment_success", "payment_failed", "payment_flagged", "payment_timeout", "payment_rejected", "refund_completed", "refund_failed"} {
+		if provider := schema.Events[eventType]; provider != "payment-service" {
+			t.Errorf("The schema lists %s as published by %q", eventType, provider)
+		}
+
+		// The envelope is stamped on publish, so check what reaches Kafka
+		var fields map[string]interface{}
+		producer := mocks.NewSyncProducer(t, nil)
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			payload, err := msg.Value.Encode()
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(payload, &fields)
+		})
+		event := models.PaymentEvent{OrderID: 7, EventType: eventType}
+		if err := PublishPaymentEvent(context.Background(), producer, paymentsTopic, event, zaptest.NewLogger(t)); err != nil {
+			t.Fatalf("Failed to publish %s: %v", eventType, err)
+		}
+		producer.Close()
+
+		for field, kind := range schema.Envelope {
+			if got := jsonKind(fields[field]); got != kind {
+				t.Errorf("%s: envelope field %q should be a %s, got %s", eventType, field, kind, got)
+			}
+		}
+		if id, _ := fields["event_id"].(string); id == "" {
+			t.Errorf("%s: expected an event_id", eventType)
+		}
+	}
+}
+
+func TestHandleMessage_NewerEventVersionIsRefused(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// Refused before the payment is touched
+	message := &sarama.ConsumerMessage{Value: []byte(`{"event_version":2,"event_type":"order_created","order_id":1}`)}
+	if err := handleMessage(message, nil, nil, nil, nil, logger); !errors.Is(err, errUnsupportedEventVersion) {
+		t.Errorf("Expected errUnsupportedEventVersion, got %v", err)
+	}
+
+	// Events this service ignores are skipped whatever their version
+	message.Value = []byte(`{"event_version":2,"event_type":"order_status_overridden","order_id":1}`)
+	if err := handleMessage(message, nil, nil, nil, nil, logger); err != nil {
+		t.Errorf("Expected an ignored event to be skipped, got %v", err)
+	}
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case nil:
+		return "missing"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+)
+
+// ConsumerState tracks consumer-group membership so readiness reflects whether
+// this instance is actually consuming.
+type ConsumerState struct {
+	mu           sync.RWMutex
+	joined       bool
+	memberID     string
+	generationID int32
+	claims       map[string][]int32
+	lastError    string
+	lastErrorAt  time.Time
+}
+
+// ConsumerStatus is a point-in-time snapshot of ConsumerState
+type ConsumerStatus struct {
+	Joined       bool               `json:"joined"`
+	MemberID     string             `json:"member_id,omitempty"`
+	GenerationID int32              `json:"generation_id,omitempty"`
+	Partitions   map[string][]int32 `json:"partitions"`
+	LastError    string             `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time         `json:"last_error_at,omitempty"`
+}
+
+func NewConsumerState() *ConsumerState {
+	return &ConsumerState{}
+}
+
+func (s *ConsumerState) setJoined(memberID string, generationID int32, claims map[string][]int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joined = true
+	s.memberID = memberID
+	s.generationID = generationID
+	s.claims = claims
+}
+
+func (s *ConsumerState) setLeft() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joined = false
+	s.claims = nil
+}
+
+func (s *ConsumerState) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+// Ready reports whether the consumer is in the group and owns at least one partition
+func (s *ConsumerState) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.joined {
+		return false
+	}
+	for _, partitions := range s.claims {
+		if len(partitions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ConsumerState) Status() ConsumerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := ConsumerStatus{
+		Joined:       s.joined,
+		MemberID:     s.memberID,
+		GenerationID: s.generationID,
+		Partitions:   make(map[string][]int32, len(s.claims)),
+		LastError:    s.lastError,
+	}
+	for topic, partitions := range s.claims {
+		status.Partitions[topic] = append([]int32(nil), partitions...)
+	}
+	if !s.lastErrorAt.IsZero() {
+		lastErrorAt := s.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+func (h *paymentConsumerGroupHandler) handle(message *sarama.ConsumerMessage) error {
+	return handleMessage(message, h.db, h.producer, h.merchants, h.orders, h.logger)
+}
+
+// handleInTransaction handles message in a Kafka transaction that also commits its
+// offset, so the events it publishes go out if and only if the message is consumed. The
+// payment row is written before the transaction commits; a message whose transaction is
+// aborted is redelivered, finds the payment and publishes its outcome again.
+func (h *paymentConsumerGroupHandler) handleInTransaction(message *sarama.ConsumerMessage) error {
+	if err := h.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err := h.handle(message)
+	if err == nil {
+		err = h.producer.AddMessageToTxn(message, consumerGroupID(), nil)
+	}
+	if err == nil {
+		err = h.producer.CommitTxn()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if abortErr := h.producer.AbortTxn(); abortErr != nil {
+		h.logger.Error("Failed to abort transaction",
+			zap.Int64("offset", message.Offset),
+			zap.Bool("fatal", h.producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0),
+			zap.Error(abortErr),
+		)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+// txnRecorder records how each transaction ended
+type txnRecorder struct {
+	*mocks.SyncProducer
+	offsets []int64
+	groupID string
+	commits int
+	aborts  int
+}
+
+func (r *txnRecorder) AddMessageToTxn(msg *sarama.ConsumerMessage, groupID string, metadata *string) error {
+	r.offsets = append(r.offsets, msg.Offset)
+	r.groupID = groupID
+	return r.SyncProducer.AddMessageToTxn(msg, groupID, metadata)
+}
+
+func (r *txnRecorder) CommitTxn() error {
+	r.commits++
+	return r.SyncProducer.CommitTxn()
+}
+
+func (r *txnRecorder) AbortTxn() error {
+	r.aborts++
+	return r.SyncProducer.AbortTxn()
+}
+
+func newTxnRecorder(t *testing.T) *txnRecorder {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Transaction.ID = "payment-service-test"
+	config.Net.MaxOpenRequests = 1
+	producer := mocks.NewSyncProducer(t, config)
+	t.Cleanup(func() { producer.Close() })
+	return &txnRecorder{SyncProducer: producer}
+}
+
+func TestHandleInTransaction_CommitsOffsetWithEvents(t *testing.T) {
+	t.Setenv("KAFKA_CONSUMER_GROUP", "payment-service-test")
+	producer := newTxnRecorder(t)
+	h := &paymentConsumerGroupHandler{producer: producer, logger: zaptest.NewLogger(t)}
+
+	// An event this service ignores is still consumed in a transaction of its own
+	message := &sarama.ConsumerMessage{Topic: "orders", Offset: 41, Value: []byte(`{"event_type":"stock_release","order_id":7}`)}
+	if err := h.handleInTransaction(message); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if producer.commits != 1 || producer.aborts != 0 {
+		t.Errorf("Expected one committed transaction, got %d commits and %d aborts", producer.commits, producer.aborts)
+	}
+	if len(producer.offsets) != 1 || producer.offsets[0] != 41 || producer.groupID != "payment-service-test" {
+		t.Errorf("Expected offset 41 committed for the live group, got %v for %q", producer.offsets, producer.groupID)
+	}
+}
+
+func TestHandleInTransaction_AbortsFailedMessage(t *testing.T) {
+	producer := newTxnRecorder(t)
+	h := &paymentConsumerGroupHandler{producer: producer, logger: zaptest.NewLogger(t)}
+
+	message := &sarama.ConsumerMessage{Topic: "orders", Offset: 42, Value: []byte(`not json`)}
+	if err := h.handleInTransaction(message); err == nil {
+		t.Fatal("Expected the malformed event to fail")
+	}
+	// Its offset isn't committed, so the message is redelivered
+	if producer.commits != 0 || producer.aborts != 1 || len(producer.offsets) != 0 {
+		t.Errorf("Expected an aborted transaction without offsets, got %d commits, %d aborts and %v", producer.commits, producer.aborts, producer.offsets)
+	}
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-svc/grpc"
+	"payment-svc/middleware"
+	"payment-svc/models"
+	"payment-svc/proto/order"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// orderLookupTimeout bounds each call to order-service
+const orderLookupTimeout = 5 * time.Second
+
+// OrderLookup reads orders from order-service; see grpc.OrderClient
+type OrderLookup interface {
+	GetOrder(ctx context.Context, orderID int) (*order.GetOrderResponse, error)
+}
+
+// verifyOrder checks an order_created event against the order order-service holds. It
+// returns why the event should be rejected, or "" when it matches: the order must exist
+// and its total must be the event's. An error means order-service couldn't answer.
+func verifyOrder(ctx context.Context, orders OrderLookup, evt orderCreatedEvent, logger *zap.Logger) (reason string, err error) {
+	var o *order.GetOrderResponse
+	err = retry(ctx, "verify_order", transientOrderServiceError, logger, func() (err error) {
+		callCtx, cancel := context.WithTimeout(ctx, orderLookupTimeout)
+		defer cancel()
+		o, err = orders.GetOrder(callCtx, evt.OrderID)
+		return err
+	})
+	if errors.Is(err, grpc.ErrOrderNotFound) {
+		return "order not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// order-service sends totals as float32, so the event's is compared at that precision
+	if o.GetTotalPrice() != float32(evt.TotalPrice) {
+		return fmt.Sprintf("total %.2f doesn't match the order's %.2f", evt.TotalPrice, o.GetTotalPrice()), nil
+	}
+	return "", nil
+}
+
+// rejectPayment publishes payment_rejected for an order_created event that doesn't match
+// its order. No payment was recorded, so the event ID is keyed by the order,
+// rejected-<order_id>.
+func rejectPayment(ctx context.Context, producer sarama.SyncProducer, evt orderCreatedEvent, reason, traceID string, logger *zap.Logger) error {
+	middleware.RecordPaymentRejected()
+	logger.Warn("Payment rejected",
+		zap.String("trace_id", traceID),
+		zap.Int("order_id", evt.OrderID),
+		zap.Float64("amount", evt.TotalPrice),
+		zap.String("reason", reason),
+	)
+
+	event := models.PaymentEvent{
+		EventID:       fmt.Sprintf("rejected-%d", evt.OrderID),
+		OrderID:       evt.OrderID,
+		UserID:        evt.UserID,
+		Amount:        evt.TotalPrice,
+		EventType:     "payment_rejected",
+		FailureReason: reason,
+		AmountMinor:   models.ToMinorUnits(evt.TotalPrice, evt.Currency),
+		Currency:      evt.Currency,
+		Region:        evt.Region,
+		MerchantID:    evt.MerchantID,
+		Metadata:      evt.Metadata,
+	}
+	if err := PublishPaymentEvent(ctx, producer, paymentsTopic, event, logger); err != nil {
+		return fmt.Errorf("failed to publish payment_rejected event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"payment-svc/gateway"
+	"payment-svc/grpc"
+	"payment-svc/models"
+	"payment-svc/proto/order"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stubOrders answers GetOrder with a fixed total, or fails every call with err
+type stubOrders struct {
+	total float32
+	err   error
+	calls int
+}
+
+func (s *stubOrders) GetOrder(_ context.Context, orderID int) (*order.GetOrderResponse, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &order.GetOrderResponse{Id: int32(orderID), TotalPrice: s.total}, nil
+}
+
+// matchingOrder is an order-service whose order has the given total
+func matchingOrder(total float32) *stubOrders {
+	return &stubOrders{total: total}
+}
+
+func TestProcessPayment_RejectsOrderMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		orders *stubOrders
+		reason string
+	}{
+		{"unknown order", &stubOrders{err: grpc.ErrOrderNotFound}, "order not found"},
+		{"different total", matchingOrder(1.99), "total 19.98 doesn't match the order's 1.99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			gw := &approvingGateway{}
+
+			var event models.PaymentEvent
+			expectPaymentEvent(producer, &event)
+
+			if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(gw), tt.orders, zaptest.NewLogger(t)); err != nil {
+				t.Fatalf("Expected the rejection to be handled, got %v", err)
+			}
+			if event.EventType != "payment_rejected" || event.EventID != "rejected-7" || event.FailureReason != tt.reason {
+				t.Errorf("Unexpected payment event: %+v", event)
+			}
+			if event.OrderID != 7 || event.AmountMinor != 1998 || event.Currency != "USD" {
+				t.Errorf("Expected the event's order and amount, got %+v", event)
+			}
+			if len(gw.authorized) != 0 {
+				t.Errorf("Expected nothing charged, got %+v", gw.authorized)
+			}
+			// No payment row is reserved for a rejected event
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessPayment_OrderServiceUnavailable(t *testing.T) {
+	withFastRetries(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	orders := &stubOrders{err: status.Error(codes.Unavailable, "connection refused")}
+
+	// The offset stays unmarked so the event is verified again on redelivery
+	if err := processPayment(context.Background(), []byte(orderCreatedValue), db, producer, gateway.Single(&approvingGateway{}), orders, zaptest.NewLogger(t)); err == nil {
+		t.Fatal("Expected an error while order-service is unavailable")
+	}
+	if orders.calls != retryAttempts {
+		t.Errorf("Expected %d lookups, got %d", retryAttempts, orders.calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
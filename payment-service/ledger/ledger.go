@@ -0,0 +1,180 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"payment-svc/models"
+)
+
+// Account is a ledger account money moves between
+type Account string
+
+const (
+	// AccountGatewayReceivable is what the gateways hold for the shop: captured
+	// payments less their fees and the refunds paid out of them
+	AccountGatewayReceivable Account = "gateway_receivable"
+	// AccountRevenue is what customers paid for their orders
+	AccountRevenue Account = "revenue"
+	// AccountRefunds is what was returned to customers, offsetting revenue
+	AccountRefunds Account = "refunds"
+	// AccountGatewayFees is what the gateways charged for processing payments
+	AccountGatewayFees Account = "gateway_fees"
+)
+
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// Entry is one side of a movement: an amount, in its currency's minor unit, debited
+// from or credited to an account
+type Entry struct {
+	Account     Account   `json:"account"`
+	Direction   Direction `json:"direction"`
+	AmountMinor int64     `json:"amount_minor"`
+}
+
+// Journal is one movement of money, recorded as entries whose debits and credits
+// balance. ID names what caused it, e.g. payment-5, so it is recorded once.
+type Journal struct {
+	ID        string
+	Kind      string
+	PaymentID int
+	RefundID  int
+	Currency  string
+	Entries   []Entry
+}
+
+// Balanced reports whether the journal's debits equal its credits, with no empty or
+// negative entries
+func (j Journal) Balanced() bool {
+	var debits, credits int64
+	for _, e := range j.Entries {
+		if e.AmountMinor <= 0 {
+			return false
+		}
+		switch e.Direction {
+		case Debit:
+			debits += e.AmountMinor
+		case Credit:
+			credits += e.AmountMinor
+		default:
+			return false
+		}
+	}
+	return len(j.Entries) > 0 && debits == credits
+}
+
+// Fees is what the gateway charges per captured payment: Rate of the amount plus
+// FixedMinor, in the payment currency's minor unit
+type Fees struct {
+	Rate       float64
+	FixedMinor int64
+}
+
+// LoadFees reads GATEWAY_FEE_RATE (default: 0.029) and GATEWAY_FEE_FIXED_MINOR
+// (default: 30), the fee the gateway takes from each captured payment
+func LoadFees() Fees {
+	fees := Fees{Rate: 0.029, FixedMinor: 30}
+	if rate, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("GATEWAY_FEE_RATE")), 64); err == nil && rate >= 0 && rate < 1 {
+		fees.Rate = rate
+	}
+	if fixed, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("GATEWAY_FEE_FIXED_MINOR")), 10, 64); err == nil && fixed >= 0 {
+		fees.FixedMinor = fixed
+	}
+	return fees
+}
+
+// On returns the fee on a payment of amountMinor, never more than the payment
+func (f Fees) On(amountMinor int64) int64 {
+	fee := int64(math.Round(float64(amountMinor)*f.Rate)) + f.FixedMinor
+	if fee > amountMinor {
+		return amountMinor
+	}
+	return fee
+}
+
+// PaymentJournals are the movements of a captured payment: the customer's payment,
+// held by the gateway as revenue, and the gateway's fee taken out of it
+func PaymentJournals(payment models.Payment, fees Fees) []Journal {
+	journals := []Journal{{
+		ID:        fmt.Sprintf("payment-%d", payment.ID),
+		Kind:      "payment",
+		PaymentID: payment.ID,
+		Currency:  payment.Currency,
+		Entries: []Entry{
+			{Account: AccountGatewayReceivable, Direction: Debit, AmountMinor: payment.AmountMinor},
+			{Account: AccountRevenue, Direction: Credit, AmountMinor: payment.AmountMinor},
+		},
+	}}
+	if fee := fees.On(payment.AmountMinor); fee > 0 {
+		journals = append(journals, Journal{
+			ID:        fmt.Sprintf("fee-%d", payment.ID),
+			Kind:      "fee",
+			PaymentID: payment.ID,
+			Currency:  payment.Currency,
+			Entries: []Entry{
+				{Account: AccountGatewayFees, Direction: Debit, AmountMinor: fee},
+				{Account: AccountGatewayReceivable, Direction: Credit, AmountMinor: fee},
+			},
+		})
+	}
+	return journals
+}
+
+// RefundJournal is the movement of a succeeded refund, paid back out of what the
+// gateway holds. The gateway keeps its fee on the payment.
+func RefundJournal(refund models.Refund) Journal {
+	return Journal{
+		ID:        fmt.Sprintf("refund-%d", refund.ID),
+		Kind:      "refund",
+		PaymentID: refund.PaymentID,
+		RefundID:  refund.ID,
+		Currency:  refund.Currency,
+		Entries: []Entry{
+			{Account: AccountRefunds, Direction: Debit, AmountMinor: refund.AmountMinor},
+			{Account: AccountGatewayReceivable, Direction: Credit, AmountMinor: refund.AmountMinor},
+		},
+	}
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx, so journals can be recorded in the
+// transaction that settles the payment or refund
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Record writes the journals' entries. A journal recorded already is left as it is, so
+// redelivered events don't count a movement twice. Unbalanced journals are refused.
+func Record(ctx context.Context, db Execer, journals ...Journal) error {
+	for _, j := range journals {
+		if !j.Balanced() {
+			return fmt.Errorf("journal %s doesn't balance", j.ID)
+		}
+
+		query := "INSERT INTO ledger_entries (journal_id, kind, account, direction, amount_minor, currency, payment_id, refund_id) VALUES "
+		args := make([]interface{}, 0, len(j.Entries)*8)
+		for i, e := range j.Entries {
+			if i > 0 {
+				query += ", "
+			}
+			n := len(args)
+			query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, 0), NULLIF($%d, 0))", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+			args = append(args, j.ID, j.Kind, e.Account, e.Direction, e.AmountMinor, j.Currency, j.PaymentID, j.RefundID)
+		}
+		query += " ON CONFLICT (journal_id, account, direction) DO NOTHING"
+
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to record journal %s: %w", j.ID, err)
+		}
+	}
+	return nil
+}
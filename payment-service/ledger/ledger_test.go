@@ -0,0 +1,118 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	"payment-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// balances nets the journals' entries per account, debits less credits
+func balances(journals []Journal) map[Account]int64 {
+	net := map[Account]int64{}
+	for _, j := range journals {
+		for _, e := range j.Entries {
+			if e.Direction == Debit {
+				net[e.Account] += e.AmountMinor
+			} else {
+				net[e.Account] -= e.AmountMinor
+			}
+		}
+	}
+	return net
+}
+
+func TestPaymentJournals_Balance(t *testing.T) {
+	for _, fees := range []Fees{{}, {Rate: 0.029, FixedMinor: 30}, {Rate: 0.5, FixedMinor: 1000}} {
+		for _, amount := range []int64{1, 29, 1998, 1500, 123456789} {
+			payment := models.Payment{ID: 5, Currency: "USD", AmountMinor: amount}
+			journals := PaymentJournals(payment, fees)
+			for _, j := range journals {
+				if !j.Balanced() {
+					t.Errorf("Journal %s of %d with fees %+v doesn't balance: %+v", j.ID, amount, fees, j.Entries)
+				}
+				if j.Currency != "USD" || j.PaymentID != 5 {
+					t.Errorf("Journal %s lost the payment's currency or ID: %+v", j.ID, j)
+				}
+			}
+
+			fee := fees.On(amount)
+			if fee < 0 || fee > amount {
+				t.Errorf("Fee %d on %d is out of range", fee, amount)
+			}
+			net := balances(journals)
+			if net[AccountRevenue] != -amount || net[AccountGatewayFees] != fee || net[AccountGatewayReceivable] != amount-fee {
+				t.Errorf("Unexpected balances for %d with fees %+v: %v", amount, fees, net)
+			}
+		}
+	}
+}
+
+func TestRefundJournal_ReversesRevenueNotFees(t *testing.T) {
+	fees := Fees{Rate: 0.029, FixedMinor: 30}
+	payment := models.Payment{ID: 5, Currency: "EUR", AmountMinor: 1998}
+	refund := RefundJournal(models.Refund{ID: 11, PaymentID: 5, Currency: "EUR", AmountMinor: 1998})
+	if !refund.Balanced() {
+		t.Fatalf("Refund journal doesn't balance: %+v", refund.Entries)
+	}
+
+	net := balances(append(PaymentJournals(payment, fees), refund))
+	// The customer got everything back; the shop is out the gateway's fee
+	if revenue := -net[AccountRevenue] - net[AccountRefunds]; revenue != 0 {
+		t.Errorf("Expected no net revenue after a full refund, got %d", revenue)
+	}
+	if net[AccountGatewayReceivable] != -88 || net[AccountGatewayFees] != 88 {
+		t.Errorf("Expected the gateway to keep its 88 fee, got %v", net)
+	}
+}
+
+func TestJournal_Balanced(t *testing.T) {
+	tests := map[string][]Entry{
+		"empty":        nil,
+		"one-sided":    {{Account: AccountRevenue, Direction: Credit, AmountMinor: 10}},
+		"uneven":       {{Account: AccountGatewayReceivable, Direction: Debit, AmountMinor: 10}, {Account: AccountRevenue, Direction: Credit, AmountMinor: 9}},
+		"zero":         {{Account: AccountGatewayReceivable, Direction: Debit, AmountMinor: 0}, {Account: AccountRevenue, Direction: Credit, AmountMinor: 0}},
+		"negative":     {{Account: AccountGatewayReceivable, Direction: Debit, AmountMinor: -5}, {Account: AccountRevenue, Direction: Credit, AmountMinor: -5}},
+		"no direction": {{Account: AccountGatewayReceivable, AmountMinor: 5}, {Account: AccountRevenue, AmountMinor: 5}},
+	}
+	for name, entries := range tests {
+		if (Journal{ID: name, Entries: entries}).Balanced() {
+			t.Errorf("%s: expected the journal not to balance", name)
+		}
+	}
+}
+
+func TestRecord_RefusesUnbalancedJournal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	journal := Journal{ID: "payment-5", Kind: "payment", PaymentID: 5, Currency: "USD", Entries: []Entry{
+		{Account: AccountGatewayReceivable, Direction: Debit, AmountMinor: 1998},
+	}}
+	if err := Record(context.Background(), db, journal); err == nil {
+		t.Error("Expected an unbalanced journal to be refused")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestLoadFees(t *testing.T) {
+	t.Setenv("GATEWAY_FEE_RATE", "0.015")
+	t.Setenv("GATEWAY_FEE_FIXED_MINOR", "0")
+	if fees := LoadFees(); fees != (Fees{Rate: 0.015}) {
+		t.Errorf("Unexpected fees %+v", fees)
+	}
+
+	t.Setenv("GATEWAY_FEE_RATE", "2")
+	t.Setenv("GATEWAY_FEE_FIXED_MINOR", "-1")
+	if fees := LoadFees(); fees != (Fees{Rate: 0.029, FixedMinor: 30}) {
+		t.Errorf("Expected out-of-range fees to fall back to the defaults, got %+v", fees)
+	}
+}
@@ -1,118 +1,52 @@
 package main
 
 import (
-	"context"
-	"errors"
-	"log"
-	"net/http"
+	"fmt"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
-	"time"
 
-	"payment-svc/database"
-	"payment-svc/handlers"
-	"payment-svc/kafka"
-	"payment-svc/middleware"
-
-	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func main() {
-	// Initialize logger
-	logger, err := zap.NewProduction()
-	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer logger.Sync()
-
-	// Initialize database
-	db, err := database.InitDB(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize database", zap.Error(err))
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
-	defer db.Close()
-
-	// Initialize Kafka producer
-	producer, err := kafka.InitProducer(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
-	}
-	defer producer.Close()
-
-	// Initialize Kafka consumer
-	consumerGroup, err := kafka.InitConsumer(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
-	}
-	defer consumerGroup.Close()
+}
 
-	// Initialize OpenTelemetry
-	shutdown, err := middleware.InitTracing("payment-service")
-	if err != nil {
-		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+// newRootCmd builds the payment-service CLI. Running it without a subcommand serves, so
+// existing deployments and `go run .` keep working.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "payment-service",
+		Short:        "Payment service Kafka consumer and operational tasks",
+		SilenceUsage: true,
+		RunE:         withLogger(serve),
 	}
-	defer shutdown()
-
-	// Start Kafka consumer in background
-	consumerCtx, consumerCancel := context.WithCancel(context.Background())
-	defer consumerCancel()
-
-	var consumerWG sync.WaitGroup
-	consumerWG.Add(1)
-	go func() {
-		defer consumerWG.Done()
-		if err := kafka.StartConsumer(consumerCtx, consumerGroup, db, producer, logger); err != nil && !errors.Is(err, context.Canceled) {
-			logger.Error("Kafka consumer error", zap.Error(err))
-		}
-	}()
 
-	// Setup REST API with Gin
-	router := gin.New()
-	router.Use(gin.Recovery())
-	// OpenTelemetry middleware must be first to extract trace context
-	router.Use(otelgin.Middleware("payment-service"))
-	router.Use(middleware.LoggerMiddleware(logger))
-	router.Use(middleware.MetricsMiddleware())
-
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-
-	// Metrics endpoint
-	router.GET("/metrics", middleware.PrometheusHandler())
-
-	// Start REST server
-	srv := &http.Server{
-		Addr:    ":8083",
-		Handler: router,
-	}
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the Kafka consumer with health, readiness and metrics endpoints",
+			Args:  cobra.NoArgs,
+			RunE:  withLogger(serve),
+		},
+		newMigrateCmd(),
+		newConsumeCmd(),
+	)
+	return root
+}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Failed to start REST server", zap.Error(err))
+// withLogger gives a command the production logger every subcommand shares. Config is
+// otherwise read from the environment by each package, exactly as when serving.
+func withLogger(run func(logger *zap.Logger) error) func(*cobra.Command, []string) error {
+	return func(_ *cobra.Command, _ []string) error {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
 		}
-	}()
+		defer logger.Sync()
 
-	logger.Info("Payment Service started on :8083")
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	logger.Info("Shutting down server...")
-
-	consumerCancel()
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
-
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		logger.Warn("Failed to shutdown REST server gracefully", zap.Error(err))
+		return run(logger)
 	}
-
-	consumerWG.Wait()
-	logger.Info("Server exited")
 }
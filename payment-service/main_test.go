@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRootCmd_Subcommands(t *testing.T) {
+	root := newRootCmd()
+	for _, name := range []string{"serve", "migrate", "consume"} {
+		cmd, _, err := root.Find([]string{name})
+		if err != nil || cmd.Name() != name {
+			t.Errorf("Expected subcommand %q, got %v (err %v)", name, cmd, err)
+		}
+	}
+	if root.RunE == nil {
+		t.Error("Expected the root command to serve when run without a subcommand")
+	}
+}
+
+// Each case fails while cobra parses the command line, before anything connects
+func TestRootCmd_RejectsInvalidUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"serve with arguments", []string{"serve", "extra"}, "unknown command"},
+		{"consume with arguments", []string{"consume", "extra"}, "unknown command"},
+		{"unknown consume flag", []string{"consume", "--from-start"}, "unknown flag"},
+		{"migrate down and status", []string{"migrate", "--down", "1", "--status"}, "none of the others can be"},
+		{"migrate down and force", []string{"migrate", "--down", "1", "--force", "3"}, "none of the others can be"},
+		{"migrate non-numeric down", []string{"migrate", "--down", "all"}, "invalid argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newRootCmd()
+			root.SetArgs(tt.args)
+			root.SetOut(&bytes.Buffer{})
+			root.SetErr(&bytes.Buffer{})
+
+			err := root.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConsumeCmd_ReplayFlag(t *testing.T) {
+	cmd := newConsumeCmd()
+	flag := cmd.Flags().Lookup("replay")
+	if flag == nil {
+		t.Fatal("Expected a --replay flag")
+	}
+	if flag.DefValue != "false" {
+		t.Errorf("Expected --replay to default to false, got %s", flag.DefValue)
+	}
+}
+
+func TestCheckReplaySchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint
+		dirty   bool
+		wantErr bool
+	}{
+		{"before one payment per order", chargeOnceMigration - 1, false, true},
+		{"no migrations applied", 0, false, true},
+		{"one payment per order", chargeOnceMigration, false, false},
+		{"later schema", chargeOnceMigration + 3, false, false},
+		{"dirty schema", chargeOnceMigration, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReplaySchema(tt.version, tt.dirty)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
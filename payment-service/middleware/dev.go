@@ -0,0 +1,13 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+)
+
+// DevMode reports whether DEV_MODE is set to true. It relaxes checks that would otherwise
+// stop a local run without production configuration.
+func DevMode() bool {
+	devMode, _ := strconv.ParseBool(os.Getenv("DEV_MODE"))
+	return devMode
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceTokenHeader carries the shared token services use to authenticate gRPC calls
+const ServiceTokenHeader = "x-service-token"
+
+// validator is implemented by request messages that can check their own fields
+type validator interface {
+	Validate() error
+}
+
+// GRPCServerInterceptors returns the unary interceptor chain for the gRPC server.
+// Logging wraps recovery so recovered panics are logged with their Internal code.
+func GRPCServerInterceptors(logger *zap.Logger, serviceToken string, devMode bool) grpc.ServerOption {
+	switch {
+	case serviceToken == "" && devMode:
+		logger.Warn("GRPC_SERVICE_TOKEN not set in dev mode, gRPC service-token auth is disabled")
+	case serviceToken == "":
+		logger.Error("GRPC_SERVICE_TOKEN not set, every gRPC call will be rejected")
+	}
+	return grpc.ChainUnaryInterceptor(
+		UnaryLoggingInterceptor(logger),
+		UnaryRecoveryInterceptor(logger),
+		UnaryAuthInterceptor(serviceToken, devMode),
+		UnaryValidationInterceptor(),
+	)
+}
+
+// UnaryRecoveryInterceptor turns handler panics into codes.Internal instead of crashing the server
+func UnaryRecoveryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("gRPC handler panic",
+					zap.String("trace_id", GetTraceID(ctx)),
+					zap.String("method", info.FullMethod),
+					zap.Any("panic", r),
+					zap.ByteString("stack", debug.Stack()),
+				)
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// UnaryLoggingInterceptor logs every call with its status code, latency and trace ID
+func UnaryLoggingInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		fields := []zap.Field{
+			zap.String("trace_id", GetTraceID(ctx)),
+			zap.String("method", info.FullMethod),
+			zap.String("code", code.String()),
+			zap.Duration("latency", time.Since(start)),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+
+		switch code {
+		case codes.OK:
+			logger.Info("gRPC Request", fields...)
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			logger.Error("gRPC Request", fields...)
+		default:
+			logger.Warn("gRPC Request", fields...)
+		}
+
+		return resp, err
+	}
+}
+
+// UnaryAuthInterceptor rejects calls whose x-service-token metadata doesn't match. Without
+// a configured token every call is rejected, unless devMode turns the check off for local
+// development.
+func UnaryAuthInterceptor(serviceToken string, devMode bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if serviceToken == "" && devMode {
+			return handler(ctx, req)
+		}
+
+		if err := checkServiceToken(ctx, serviceToken); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func checkServiceToken(ctx context.Context, serviceToken string) error {
+	if serviceToken == "" {
+		return status.Error(codes.Unauthenticated, "service token auth is not configured")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ServiceTokenHeader)
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing service token")
+	}
+	if subtle.ConstantTimeCompare([]byte(values[0]), []byte(serviceToken)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid service token")
+	}
+	return nil
+}
+
+// UnaryValidationInterceptor rejects requests whose Validate method fails with codes.InvalidArgument
+func UnaryValidationInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if v, ok := req.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// UnaryServiceTokenClientInterceptor attaches the service token to outgoing calls
+func UnaryServiceTokenClientInterceptor(serviceToken string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if serviceToken != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, ServiceTokenHeader, serviceToken)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -32,7 +32,137 @@ var (
 			Name: "payment_processed_total",
 			Help: "Total number of payments processed",
 		},
-		[]string{"status"},
+		[]string{"status", "gateway"},
+	)
+
+	paymentProcessingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "payment_processing_duration_seconds",
+			Help: "Time from charging an order to the payment's outcome in seconds",
+			// Gateways answer within seconds; outcomes confirmed by webhook take longer
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 1800},
+		},
+		[]string{"status", "gateway"},
+	)
+
+	paymentAmount = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "payment_amount",
+			Help:    "Amount of processed payments, in units of their currency",
+			Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+		},
+		[]string{"status", "gateway", "currency"},
+	)
+
+	paymentRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_retries_total",
+			Help: "Total number of retries after transient database or gateway failures",
+		},
+		[]string{"operation"},
+	)
+
+	gatewayWebhooksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_gateway_webhooks_total",
+			Help: "Total number of gateway webhook calls by notification kind and result",
+		},
+		[]string{"kind", "result"},
+	)
+
+	fraudChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_fraud_checks_total",
+			Help: "Total number of pre-charge fraud checks by decision",
+		},
+		[]string{"decision"},
+	)
+
+	paymentRejectedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "payment_rejected_total",
+			Help: "Total number of order_created events rejected for not matching their order in order-service",
+		},
+	)
+
+	paymentDuplicatesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "payment_duplicates_total",
+			Help: "Total number of order_created events for orders already charged, answered with the existing outcome",
+		},
+	)
+
+	consumerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "payment_consumer_lag",
+			Help: "Messages between a claimed partition's high-water mark and the offset marked for commit",
+		},
+		[]string{"topic", "partition"},
+	)
+
+	consumerMessagesConsumed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_consumer_messages_consumed_total",
+			Help: "Total number of messages the consumer received",
+		},
+		[]string{"topic"},
+	)
+
+	consumerMessagesFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_consumer_messages_failed_total",
+			Help: "Total number of messages whose handling failed, left unmarked",
+		},
+		[]string{"topic"},
+	)
+
+	consumerMessagesMarked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_consumer_messages_marked_total",
+			Help: "Total number of messages whose offsets were marked for commit",
+		},
+		[]string{"topic"},
+	)
+
+	failedEventsStored = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_failed_events_stored_total",
+			Help: "Total number of failed messages stored in failed_events for replay",
+		},
+		[]string{"topic"},
+	)
+
+	failedEventReplays = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_failed_event_replays_total",
+			Help: "Total number of failed event replays, by whether the event was handled",
+		},
+		[]string{"result"},
+	)
+
+	lockAcquisitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_acquisitions_total",
+			Help: "Total number of distributed lock acquisition attempts",
+		},
+		[]string{"lock", "result"},
+	)
+
+	lockHeldDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "distributed_lock_held_seconds",
+			Help:    "How long distributed locks were held in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lock"},
+	)
+
+	lockRenewalFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_renewal_failures_total",
+			Help: "Total number of distributed locks lost because TTL renewal failed",
+		},
+		[]string{"lock"},
 	)
 )
 
@@ -40,6 +170,22 @@ func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
 	prometheus.MustRegister(paymentProcessedTotal)
+	prometheus.MustRegister(paymentProcessingDuration)
+	prometheus.MustRegister(paymentAmount)
+	prometheus.MustRegister(paymentRetriesTotal)
+	prometheus.MustRegister(paymentDuplicatesTotal)
+	prometheus.MustRegister(paymentRejectedTotal)
+	prometheus.MustRegister(gatewayWebhooksTotal)
+	prometheus.MustRegister(fraudChecksTotal)
+	prometheus.MustRegister(consumerLag)
+	prometheus.MustRegister(consumerMessagesConsumed)
+	prometheus.MustRegister(consumerMessagesFailed)
+	prometheus.MustRegister(consumerMessagesMarked)
+	prometheus.MustRegister(failedEventsStored)
+	prometheus.MustRegister(failedEventReplays)
+	prometheus.MustRegister(lockAcquisitionsTotal)
+	prometheus.MustRegister(lockHeldDuration)
+	prometheus.MustRegister(lockRenewalFailuresTotal)
 }
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -64,6 +210,84 @@ func PrometheusHandler() gin.HandlerFunc {
 	return gin.WrapH(promhttp.Handler())
 }
 
-func RecordPaymentProcessed(status string) {
-	paymentProcessedTotal.WithLabelValues(status).Inc()
+// RecordPaymentProcessed counts a payment that reached status through gateway, with how
+// long it took and the amount charged or declined in currency
+func RecordPaymentProcessed(status, gateway, currency string, duration time.Duration, amount float64) {
+	paymentProcessedTotal.WithLabelValues(status, gateway).Inc()
+	paymentProcessingDuration.WithLabelValues(status, gateway).Observe(duration.Seconds())
+	paymentAmount.WithLabelValues(status, gateway, currency).Observe(amount)
+}
+
+// RecordDuplicatePayment counts an order_created event for an order already charged
+func RecordDuplicatePayment() {
+	paymentDuplicatesTotal.Inc()
+}
+
+// RecordPaymentRejected counts an order_created event rejected before charging
+func RecordPaymentRejected() {
+	paymentRejectedTotal.Inc()
+}
+
+// RecordPaymentRetry counts a retry of operation after a transient failure
+func RecordPaymentRetry(operation string) {
+	paymentRetriesTotal.WithLabelValues(operation).Inc()
+}
+
+// RecordGatewayWebhook counts a gateway webhook call reporting kind, by whether it was
+// applied, left the payment pending, was ignored, rejected or failed
+func RecordGatewayWebhook(kind, result string) {
+	gatewayWebhooksTotal.WithLabelValues(kind, result).Inc()
+}
+
+// RecordFraudCheck counts a fraud check that allowed, flagged or declined a payment
+func RecordFraudCheck(decision string) {
+	fraudChecksTotal.WithLabelValues(decision).Inc()
+}
+
+// SetConsumerLag sets how many messages the offset marked on topic's partition trails
+// its high-water mark
+func SetConsumerLag(topic string, partition int32, lag int64) {
+	consumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// ClearConsumerLag drops the lag of a partition this member no longer claims
+func ClearConsumerLag(topic string, partition int32) {
+	consumerLag.DeleteLabelValues(topic, strconv.Itoa(int(partition)))
+}
+
+// RecordConsumerMessage counts a message received from topic
+func RecordConsumerMessage(topic string) {
+	consumerMessagesConsumed.WithLabelValues(topic).Inc()
+}
+
+// RecordConsumerFailure counts a message from topic whose handling failed
+func RecordConsumerFailure(topic string) {
+	consumerMessagesFailed.WithLabelValues(topic).Inc()
+}
+
+// RecordConsumerMarked counts messages from topic whose offsets were marked for commit
+func RecordConsumerMarked(topic string, messages int64) {
+	consumerMessagesMarked.WithLabelValues(topic).Add(float64(messages))
+}
+
+// RecordFailedEventStored counts a failed message from topic stored for replay
+func RecordFailedEventStored(topic string) {
+	failedEventsStored.WithLabelValues(topic).Inc()
+}
+
+// RecordFailedEventReplay counts a replay of a failed event, replayed or failed
+func RecordFailedEventReplay(result string) {
+	failedEventReplays.WithLabelValues(result).Inc()
+}
+
+func RecordLockAcquisition(lock, result string) {
+	lockAcquisitionsTotal.WithLabelValues(lock, result).Inc()
+}
+
+func ObserveLockHeld(lock string, d time.Duration) {
+	lockHeldDuration.WithLabelValues(lock).Observe(d.Seconds())
+}
+
+func RecordLockRenewalFailure(lock string) {
+	lockRenewalFailuresTotal.WithLabelValues(lock).Inc()
 }
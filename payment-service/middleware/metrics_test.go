@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordPaymentProcessed(t *testing.T) {
+	// The vectors live on the default registry, so start from empty series
+	paymentProcessedTotal.Reset()
+	paymentProcessingDuration.Reset()
+	paymentAmount.Reset()
+
+	RecordPaymentProcessed("succeeded", "metrics-test", "USD", 1500*time.Millisecond, 42)
+	RecordPaymentProcessed("succeeded", "metrics-test", "USD", 200*time.Millisecond, 7.5)
+	RecordPaymentProcessed("failed", "metrics-test", "EUR", 40*time.Second, 300)
+
+	if got := testutil.ToFloat64(paymentProcessedTotal.WithLabelValues("succeeded", "metrics-test")); got != 2 {
+		t.Errorf("Expected 2 succeeded payments counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(paymentProcessedTotal.WithLabelValues("failed", "metrics-test")); got != 1 {
+		t.Errorf("Expected 1 failed payment counted, got %v", got)
+	}
+
+	// Each payment lands in the buckets for its duration and amount
+	durations := `
+# HELP payment_processing_duration_seconds Time from charging an order to the payment's outcome in seconds
+# TYPE payment_processing_duration_seconds histogram
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="0.1"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="0.25"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="0.5"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="1"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="2.5"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="5"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="10"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="30"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="60"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="300"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="1800"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="failed",le="+Inf"} 1
+payment_processing_duration_seconds_sum{gateway="metrics-test",status="failed"} 40
+payment_processing_duration_seconds_count{gateway="metrics-test",status="failed"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="0.1"} 0
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="0.25"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="0.5"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="1"} 1
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="2.5"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="5"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="10"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="30"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="60"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="300"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="1800"} 2
+payment_processing_duration_seconds_bucket{gateway="metrics-test",status="succeeded",le="+Inf"} 2
+payment_processing_duration_seconds_sum{gateway="metrics-test",status="succeeded"} 1.7
+payment_processing_duration_seconds_count{gateway="metrics-test",status="succeeded"} 2
+`
+	if err := testutil.CollectAndCompare(paymentProcessingDuration, strings.NewReader(durations)); err != nil {
+		t.Errorf("Unexpected processing durations: %v", err)
+	}
+
+	amounts := `
+# HELP payment_amount Amount of processed payments, in units of their currency
+# TYPE payment_amount histogram
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="1"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="5"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="10"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="25"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="50"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="100"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="250"} 0
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="500"} 1
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="1000"} 1
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="2500"} 1
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="5000"} 1
+payment_amount_bucket{currency="EUR",gateway="metrics-test",status="failed",le="+Inf"} 1
+payment_amount_sum{currency="EUR",gateway="metrics-test",status="failed"} 300
+payment_amount_count{currency="EUR",gateway="metrics-test",status="failed"} 1
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="1"} 0
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="5"} 0
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="10"} 1
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="25"} 1
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="50"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="100"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="250"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="500"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="1000"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="2500"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="5000"} 2
+payment_amount_bucket{currency="USD",gateway="metrics-test",status="succeeded",le="+Inf"} 2
+payment_amount_sum{currency="USD",gateway="metrics-test",status="succeeded"} 49.5
+payment_amount_count{currency="USD",gateway="metrics-test",status="succeeded"} 2
+`
+	if err := testutil.CollectAndCompare(paymentAmount, strings.NewReader(amounts)); err != nil {
+		t.Errorf("Unexpected payment amounts: %v", err)
+	}
+}
+
+func TestPaymentCounters(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  func()
+		counter prometheus.Collector
+	}{
+		{"duplicate", RecordDuplicatePayment, paymentDuplicatesTotal},
+		{"rejected", RecordPaymentRejected, paymentRejectedTotal},
+		{"retry", func() { RecordPaymentRetry("charge") }, paymentRetriesTotal.WithLabelValues("charge")},
+		{"fraud check", func() { RecordFraudCheck("flagged") }, fraudChecksTotal.WithLabelValues("flagged")},
+		{"webhook", func() { RecordGatewayWebhook("charge.succeeded", "applied") }, gatewayWebhooksTotal.WithLabelValues("charge.succeeded", "applied")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(tt.counter)
+			tt.record()
+			tt.record()
+			if got := testutil.ToFloat64(tt.counter) - before; got != 2 {
+				t.Errorf("Expected the counter to go up by 2, got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"payment-svc/database"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newMigrateCmd() *cobra.Command {
+	var down, force int
+	var status bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending database migrations and exit",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return migrate(logger, down, force, status)
+		}),
+	}
+	cmd.Flags().IntVar(&down, "down", 0, "roll back this many migrations instead of applying pending ones")
+	cmd.Flags().IntVar(&force, "force", -1, "record this version as applied without running it, to clear a dirty schema")
+	cmd.Flags().BoolVar(&status, "status", false, "print the applied version without changing anything")
+	cmd.MarkFlagsMutuallyExclusive("down", "force", "status")
+	return cmd
+}
+
+// migrate changes the schema without starting anything else, e.g. as a deploy step
+func migrate(logger *zap.Logger, down, force int, status bool) error {
+	db, err := database.Open(logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch {
+	case status:
+	case down > 0:
+		if err := database.MigrateDown(db, down); err != nil {
+			return err
+		}
+	case force >= 0:
+		if err := database.ForceMigrationVersion(db, force); err != nil {
+			return err
+		}
+	default:
+		if err := database.Migrate(db); err != nil {
+			return err
+		}
+	}
+
+	version, dirty, err := database.MigrationVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		logger.Warn("Database schema is dirty; fix the failed migration, then run migrate --force", zap.Uint("version", version))
+		return nil
+	}
+	logger.Info("Database schema version", zap.Uint("version", version))
+	return nil
+}
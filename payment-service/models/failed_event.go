@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type FailedEventStatus string
+
+const (
+	// FailedEventFailed rows wait for an admin to replay them
+	FailedEventFailed FailedEventStatus = "failed"
+	// FailedEventReplayed rows were handled by a replay
+	FailedEventReplayed FailedEventStatus = "replayed"
+)
+
+// FailedEvent is a consumed message that couldn't be decoded or handled, kept as it was
+// received until it is replayed
+type FailedEvent struct {
+	ID        int64             `json:"id"`
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       *string           `json:"key,omitempty"`
+	Payload   string            `json:"payload"`
+	Headers   map[string]string `json:"headers"`
+	// EventType is empty for payloads that couldn't be decoded
+	EventType string            `json:"event_type,omitempty"`
+	Error     string            `json:"error"`
+	Status    FailedEventStatus `json:"status"`
+	// Attempts counts the failed deliveries and replays
+	Attempts   int        `json:"attempts"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// LedgerQuery holds the filters of GET /ledger: entries recorded from From, inclusive,
+// to To, exclusive, in Currency, for PaymentID, for the payments of MerchantID
+type LedgerQuery struct {
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Currency   string    `form:"currency" binding:"omitempty,len=3,alpha"`
+	PaymentID  int       `form:"payment_id" binding:"omitempty,gte=1"`
+	MerchantID string    `form:"merchant_id" binding:"omitempty,max=64"`
+}
+
+// LedgerEntry is one recorded side of a ledger journal
+type LedgerEntry struct {
+	ID          int       `json:"id"`
+	JournalID   string    `json:"journal_id"`
+	Kind        string    `json:"kind"`
+	Account     string    `json:"account"`
+	Direction   string    `json:"direction"`
+	AmountMinor int64     `json:"amount_minor"`
+	Currency    string    `json:"currency"`
+	PaymentID   int       `json:"payment_id,omitempty"`
+	RefundID    int       `json:"refund_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LedgerAccountBalance totals an account's entries in one currency. BalanceMinor is
+// its debits less its credits.
+type LedgerAccountBalance struct {
+	Account      string `json:"account"`
+	Currency     string `json:"currency"`
+	DebitMinor   int64  `json:"debit_minor"`
+	CreditMinor  int64  `json:"credit_minor"`
+	BalanceMinor int64  `json:"balance_minor"`
+}
+
+// LedgerReport is returned by GET /ledger. Balanced reports whether debits equal
+// credits in every currency; Entries lists the entries of the payment filtered on.
+type LedgerReport struct {
+	Accounts []LedgerAccountBalance `json:"accounts"`
+	Balanced bool                   `json:"balanced"`
+	Entries  []LedgerEntry          `json:"entries,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "regexp"
+
+// DefaultMerchant is the merchant of orders that don't name one, and of payments
+// recorded before payments had merchants
+const DefaultMerchant = "default"
+
+var merchantID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidMerchantID reports whether id is shaped like a merchant ID: up to 64 lowercase
+// letters, digits, dashes and underscores, e.g. "acme-eu"
+func ValidMerchantID(id string) bool {
+	return merchantID.MatchString(id)
+}
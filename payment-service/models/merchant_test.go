@@ -0,0 +1,11 @@
+package models
+
+import "testing"
+
+func TestValidMerchantID(t *testing.T) {
+	for id, want := range map[string]bool{"default": true, "acme-eu": true, "shop_2": true, "": false, "Acme": false, "-acme": false, "acme eu": false} {
+		if got := ValidMerchantID(id); got != want {
+			t.Errorf("ValidMerchantID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
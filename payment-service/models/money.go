@@ -0,0 +1,43 @@
+package models
+
+import (
+	"math"
+	"regexp"
+)
+
+// DefaultCurrency is the currency of orders published before order events carried one
+const DefaultCurrency = "USD"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// minorUnitExponents lists the ISO 4217 currencies whose minor unit isn't a hundredth;
+// every other currency has two decimals
+var minorUnitExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// ValidCurrency reports whether code is shaped like an ISO 4217 code, e.g. "EUR"
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// ToMinorUnits converts amount to an integer count of the currency's minor unit, e.g.
+// cents, rounding half away from zero
+func ToMinorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * minorUnitScale(currency)))
+}
+
+// FromMinorUnits converts a count of the currency's minor unit back to an amount
+func FromMinorUnits(minor int64, currency string) float64 {
+	return float64(minor) / minorUnitScale(currency)
+}
+
+func minorUnitScale(currency string) float64 {
+	exponent, ok := minorUnitExponents[currency]
+	if !ok {
+		exponent = 2
+	}
+	return math.Pow10(exponent)
+}
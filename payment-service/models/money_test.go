@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestToMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{19.99, "USD", 1999},
+		// 0.1 + 0.2 isn't 0.3 in binary floating point
+		{0.1 + 0.2, "EUR", 30},
+		{1500, "JPY", 1500},
+		{1.2345, "KWD", 1235},
+	}
+	for _, tt := range tests {
+		if got := ToMinorUnits(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("ToMinorUnits(%v, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+		if got := ToMinorUnits(FromMinorUnits(tt.want, tt.currency), tt.currency); got != tt.want {
+			t.Errorf("Round trip of %d %s gave %d", tt.want, tt.currency, got)
+		}
+	}
+}
+
+func TestValidCurrency(t *testing.T) {
+	for code, want := range map[string]bool{"USD": true, "JPY": true, "usd": false, "US": false, "": false, "EURO": false} {
+		if got := ValidCurrency(code); got != want {
+			t.Errorf("ValidCurrency(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -5,10 +5,21 @@ import "time"
 type PaymentStatus string
 
 const (
-	PaymentStatusPending   PaymentStatus = "pending"
-	PaymentStatusSuccess   PaymentStatus = "success"
-	PaymentStatusFailed    PaymentStatus = "failed"
-	PaymentStatusCancelled PaymentStatus = "cancelled"
+	PaymentStatusPending PaymentStatus = "pending"
+	// PaymentStatusAuthorized is a payment whose hold waits for its order to be
+	// confirmed before it is captured
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusSuccess    PaymentStatus = "success"
+	PaymentStatusFailed     PaymentStatus = "failed"
+	PaymentStatusCancelled  PaymentStatus = "cancelled"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+	// PaymentStatusPendingReview is a payment the gateway didn't answer for in time. It
+	// may or may not have been charged, so it waits for the gateway webhook or support.
+	PaymentStatusPendingReview PaymentStatus = "pending_review"
+	// PaymentStatusDuplicate is an extra charge for an order that already had a payment,
+	// recorded before orders were charged once. It is kept for reconciliation and for
+	// refunding at the gateway, and never counts as the order's payment.
+	PaymentStatusDuplicate PaymentStatus = "duplicate"
 )
 
 type Payment struct {
@@ -18,16 +29,111 @@ type Payment struct {
 	Amount        float64       `json:"amount"`
 	Status        PaymentStatus `json:"status"`
 	TransactionID string        `json:"transaction_id"`
-	CreatedAt     time.Time     `json:"created_at"`
-	UpdatedAt     time.Time     `json:"updated_at"`
+	Region        string        `json:"region"`
+	// MerchantID is the merchant the payment was taken for, whose gateway handled it
+	MerchantID string `json:"merchant_id"`
+	// AmountMinor is Amount in Currency's minor unit, e.g. cents, as stored; Amount is
+	// derived from it
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+	// Gateway is the provider that handled the payment, and GatewayReference its ID for
+	// it, kept for declined payments too
+	Gateway          string `json:"gateway,omitempty"`
+	GatewayReference string `json:"gateway_reference,omitempty"`
+	// FailureReason says why a failed payment was declined or couldn't be charged
+	FailureReason string `json:"failure_reason,omitempty"`
+	// PaymentMethodID is the saved payment method charged, unset for the gateway's
+	// default or a method deleted since
+	PaymentMethodID int       `json:"payment_method_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// SetAmount sets the payment's amount, given in currency's minor unit
+func (p *Payment) SetAmount(minor int64, currency string) {
+	p.AmountMinor, p.Currency, p.Amount = minor, currency, FromMinorUnits(minor, currency)
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund is one attempt to refund a payment through its gateway
+type Refund struct {
+	ID               int          `json:"id"`
+	PaymentID        int          `json:"payment_id"`
+	OrderID          int          `json:"order_id"`
+	Amount           float64      `json:"amount"`
+	AmountMinor      int64        `json:"amount_minor"`
+	Currency         string       `json:"currency"`
+	Status           RefundStatus `json:"status"`
+	Gateway          string       `json:"gateway"`
+	GatewayReference string       `json:"gateway_reference,omitempty"`
+	FailureReason    string       `json:"failure_reason,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// ListPaymentsQuery holds the pagination for the payment list endpoints, and the
+// order, merchant and status filters of GET /payments
+type ListPaymentsQuery struct {
+	Page       int    `form:"page" binding:"omitempty,gte=1"`
+	Limit      int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	OrderID    int    `form:"order_id" binding:"omitempty,gte=1"`
+	MerchantID string `form:"merchant_id" binding:"omitempty,max=64"`
+	Status     string `form:"status" binding:"omitempty,max=50"`
+}
+
+// PaymentListResponse is the paginated envelope returned by the payment list endpoints
+type PaymentListResponse struct {
+	Data       []Payment `json:"data"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	Total      int       `json:"total"`
+	TotalPages int       `json:"total_pages"`
+}
+
+// EventVersion is the order_events schema version published by this service. It only
+// changes when a field is renamed, removed or changes type; see
+// contracts/schemas/order_events.json.
+const EventVersion = 1
+
 type PaymentEvent struct {
+	// EventID identifies the event for consumers that deduplicate redeliveries
+	EventID string `json:"event_id"`
+	// EventVersion is the schema version the event was written with
+	EventVersion  int           `json:"event_version"`
 	PaymentID     int           `json:"payment_id"`
 	OrderID       int           `json:"order_id"`
 	UserID        int           `json:"user_id"`
 	Amount        float64       `json:"amount"`
 	Status        PaymentStatus `json:"status"`
-	EventType     string        `json:"event_type"` // payment_success, payment_failed
+	EventType     string        `json:"event_type"` // payment_success, payment_failed, payment_flagged, payment_timeout, payment_rejected, refund_completed, refund_failed
 	TransactionID string        `json:"transaction_id"`
+	// RefundID is the refund record a refund outcome is about, unset when no refund was
+	// attempted
+	RefundID int `json:"refund_id,omitempty"`
+	// FailureReason says why a payment or refund failed
+	FailureReason string `json:"failure_reason,omitempty"`
+	// PaymentMethod is the saved payment method charged, unset when the gateway's
+	// default was
+	PaymentMethod *PaymentMethodSummary `json:"payment_method,omitempty"`
+	// RiskScore, RiskDecision (flag or decline) and RiskReasons are the fraud check's
+	// verdict on a payment_flagged payment
+	RiskScore    float64  `json:"risk_score,omitempty"`
+	RiskDecision string   `json:"risk_decision,omitempty"`
+	RiskReasons  []string `json:"risk_reasons,omitempty"`
+	// AmountMinor and Currency give Amount exactly, in the currency's minor unit
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+	Region      string `json:"region"`
+	// MerchantID is the merchant the payment was taken for
+	MerchantID string `json:"merchant_id,omitempty"`
+	// Metadata is copied from order_created onto payment outcomes, so consumers can match
+	// them to the integrator's own references
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type PaymentAttemptOutcome string
+
+const (
+	PaymentAttemptApproved PaymentAttemptOutcome = "approved"
+	PaymentAttemptDeclined PaymentAttemptOutcome = "declined"
+	// PaymentAttemptPending is a call the gateway will answer through its webhook
+	PaymentAttemptPending PaymentAttemptOutcome = "pending"
+	// PaymentAttemptError is a call the gateway didn't answer or rejected outright
+	PaymentAttemptError PaymentAttemptOutcome = "error"
+)
+
+// PaymentAttempt is one call to the gateway to authorize or capture a payment
+type PaymentAttempt struct {
+	ID        int                   `json:"id"`
+	PaymentID int                   `json:"payment_id"`
+	Operation string                `json:"operation"`
+	Gateway   string                `json:"gateway"`
+	Outcome   PaymentAttemptOutcome `json:"outcome"`
+	// ResponseCode is the gateway's own code for its answer, such as a decline code;
+	// empty for a call it never answered
+	ResponseCode     string `json:"response_code,omitempty"`
+	GatewayReference string `json:"gateway_reference,omitempty"`
+	// Error is the decline reason, or the error of a call the gateway didn't answer
+	Error       string    `json:"error,omitempty"`
+	LatencyMs   int64     `json:"latency_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+type PaymentMethodType string
+
+const (
+	PaymentMethodTypeCard        PaymentMethodType = "card"
+	PaymentMethodTypeBankAccount PaymentMethodType = "bank_account"
+	PaymentMethodTypeWallet      PaymentMethodType = "wallet"
+)
+
+// PaymentMethod is a way of paying a user saved for their orders. Only masked details
+// are kept for display; the gateway charges the method through GatewayToken, which is
+// never returned by the API.
+type PaymentMethod struct {
+	ID           int               `json:"id"`
+	UserID       int               `json:"user_id"`
+	Type         PaymentMethodType `json:"type"`
+	Brand        string            `json:"brand,omitempty"`
+	Last4        string            `json:"last4"`
+	ExpMonth     int               `json:"exp_month,omitempty"`
+	ExpYear      int               `json:"exp_year,omitempty"`
+	GatewayToken string            `json:"-"`
+	IsDefault    bool              `json:"is_default"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Summary is what payment events say about the method a payment was charged with
+func (m PaymentMethod) Summary() *PaymentMethodSummary {
+	return &PaymentMethodSummary{ID: m.ID, Type: m.Type, Brand: m.Brand, Last4: m.Last4}
+}
+
+// CreatePaymentMethodRequest saves a payment method. GatewayToken is the token the
+// client got from tokenizing the card or account with the gateway, so raw numbers never
+// reach this service. The user's first method becomes their default.
+type CreatePaymentMethodRequest struct {
+	Type         PaymentMethodType `json:"type" binding:"required,oneof=card bank_account wallet"`
+	Brand        string            `json:"brand" binding:"omitempty,max=32"`
+	Last4        string            `json:"last4" binding:"required,len=4,numeric"`
+	ExpMonth     int               `json:"exp_month" binding:"omitempty,gte=1,lte=12"`
+	ExpYear      int               `json:"exp_year" binding:"omitempty,gte=2000,lte=2100"`
+	GatewayToken string            `json:"gateway_token" binding:"required,max=255"`
+	IsDefault    bool              `json:"is_default"`
+}
+
+// UpdatePaymentMethodRequest changes a saved method's expiry or makes it the default;
+// unset fields are left alone. A default can't be unset, only replaced.
+type UpdatePaymentMethodRequest struct {
+	ExpMonth  *int  `json:"exp_month" binding:"omitempty,gte=1,lte=12"`
+	ExpYear   *int  `json:"exp_year" binding:"omitempty,gte=2000,lte=2100"`
+	IsDefault *bool `json:"is_default"`
+}
+
+// PaymentMethodSummary identifies a payment method in payment events without its token
+type PaymentMethodSummary struct {
+	ID    int               `json:"id"`
+	Type  PaymentMethodType `json:"type"`
+	Brand string            `json:"brand,omitempty"`
+	Last4 string            `json:"last4"`
+}
@@ -0,0 +1,98 @@
+package order
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// orderServiceProto is the source this package is copied from
+const orderServiceProto = "../../../order-service/proto/order.proto"
+
+// TestGetOrderResponse_DecodesOrderServiceWire decodes a GetOrderResponse encoded with
+// order-service's field numbers, as its gRPC server sends it
+func TestGetOrderResponse_DecodesOrderServiceWire(t *testing.T) {
+	longValue := strings.Repeat("x", 200)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType) // id
+	b = protowire.AppendVarint(b, 42)
+	b = protowire.AppendTag(b, 8, protowire.VarintType) // variant_id
+	b = protowire.AppendVarint(b, 7)
+	for key, value := range map[string]string{"cart_id": "c-1", "note": longValue} {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, value)
+		b = protowire.AppendTag(b, 12, protowire.BytesType) // metadata
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = protowire.AppendTag(b, 13, protowire.BytesType) // notes
+	b = protowire.AppendString(b, "leave at the door")
+
+	var got GetOrderResponse
+	if err := proto.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Failed to unmarshal order-service's GetOrderResponse: %v", err)
+	}
+	if got.Id != 42 || got.VariantId != 7 {
+		t.Errorf("Expected id 42 and variant 7, got %d and %d", got.Id, got.VariantId)
+	}
+	if got.Metadata["cart_id"] != "c-1" || got.Metadata["note"] != longValue {
+		t.Errorf("Expected the metadata to round-trip, got %v", got.Metadata)
+	}
+	if got.Notes != "leave at the door" {
+		t.Errorf("Expected notes %q, got %q", "leave at the door", got.Notes)
+	}
+}
+
+var (
+	messagePattern = regexp.MustCompile(`^message (\w+) \{`)
+	fieldPattern   = regexp.MustCompile(`^\s+(?:repeated |optional )?(?:map<[^>]+>|[\w.]+) (\w+) = (\d+);`)
+)
+
+// TestFieldNumbers_MatchOrderService compares every field number with order-service's
+// proto, so the copy can't drift from it again
+func TestFieldNumbers_MatchOrderService(t *testing.T) {
+	src, err := os.ReadFile(orderServiceProto)
+	if err != nil {
+		t.Skipf("order-service's proto is not checked out next to this service: %v", err)
+	}
+
+	var message string
+	fields := 0
+	for _, line := range strings.Split(string(src), "\n") {
+		if m := messagePattern.FindStringSubmatch(line); m != nil {
+			message = m[1]
+			continue
+		}
+		m := fieldPattern.FindStringSubmatch(line)
+		if m == nil || message == "" {
+			continue
+		}
+		want, _ := strconv.Atoi(m[2])
+		desc := File_proto_order_order_proto.Messages().ByName(protoreflect.Name(message))
+		if desc == nil {
+			t.Errorf("Message %s is missing", message)
+			continue
+		}
+		field := desc.Fields().ByName(protoreflect.Name(m[1]))
+		if field == nil {
+			t.Errorf("Field %s.%s is missing", message, m[1])
+			continue
+		}
+		if int(field.Number()) != want {
+			t.Errorf("Expected %s.%s to be field %d, got %d", message, m[1], want, field.Number())
+		}
+		fields++
+	}
+	if fields == 0 {
+		t.Fatal("Expected to find fields in order-service's proto")
+	}
+}
@@ -0,0 +1,32 @@
+package payment
+
+import "errors"
+
+// Validate methods are picked up by the gRPC validation interceptor
+
+func (r *GetPaymentByOrderRequest) Validate() error {
+	if r.GetOrderId() <= 0 {
+		return errors.New("order_id must be positive")
+	}
+	return nil
+}
+
+func (r *ListPaymentsByUserRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	if r.GetPage() < 0 {
+		return errors.New("page must not be negative")
+	}
+	if r.GetLimit() < 0 || r.GetLimit() > 100 {
+		return errors.New("limit must be between 1 and 100")
+	}
+	return nil
+}
+
+func (r *ListPaymentAttemptsRequest) Validate() error {
+	if r.GetPaymentId() <= 0 {
+		return errors.New("payment_id must be positive")
+	}
+	return nil
+}
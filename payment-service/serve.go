@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"payment-svc/cache"
+	"payment-svc/database"
+	"payment-svc/gateway"
+	"payment-svc/grpc"
+	"payment-svc/handlers"
+	"payment-svc/kafka"
+	"payment-svc/lock"
+	"payment-svc/middleware"
+	payment "payment-svc/proto"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	grpcLib "google.golang.org/grpc"
+)
+
+// serve runs the Kafka consumer together with the health, readiness and metrics
+// endpoints and the payment lookup gRPC API until SIGINT/SIGTERM
+func serve(logger *zap.Logger) error {
+	if err := middleware.CheckJWTSecret(); err != nil {
+		logger.Fatal("Invalid auth configuration", zap.Error(err))
+	}
+
+	// Initialize database
+	db, err := database.InitDB(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Initialize Redis, which holds the background jobs' locks
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis", zap.Error(err))
+	}
+	defer redisClient.Close()
+	locker := lock.NewLocker(redisClient, logger)
+
+	// Initialize Kafka producer
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
+	}
+	defer producer.Close()
+
+	// With KAFKA_TRANSACTIONAL_ID the consumer publishes through its own transactional
+	// producer; the webhook and background jobs keep the plain one
+	consumerProducer := producer
+	txnProducer, err := kafka.InitTransactionalProducer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize transactional Kafka producer", zap.Error(err))
+	}
+	if txnProducer != nil {
+		defer txnProducer.Close()
+		consumerProducer = txnProducer
+	}
+
+	// Initialize the default payment gateway and the merchants' own
+	merchants, err := gateway.NewMerchants()
+	if err != nil {
+		logger.Fatal("Failed to initialize payment gateways", zap.Error(err))
+	}
+	logger.Info("Using payment gateways",
+		zap.String("gateway", merchants.Default().Name()),
+		zap.Any("merchant_gateways", merchants.Configured()),
+	)
+
+	// Initialize gRPC client for Order Service (order_created verification)
+	orderClient, err := grpc.InitOrderClient(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Order gRPC client", zap.Error(err))
+	}
+	defer orderClient.Close()
+
+	// Initialize Kafka consumer
+	consumerGroup, err := kafka.InitConsumer(logger, false)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
+	}
+	defer consumerGroup.Close()
+
+	// Initialize OpenTelemetry
+	shutdown, err := middleware.InitTracing("payment-service")
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer shutdown()
+
+	// Start Kafka consumer in background
+	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	defer consumerCancel()
+
+	consumerState := kafka.NewConsumerState()
+
+	var consumerWG sync.WaitGroup
+	consumerWG.Add(1)
+	go func() {
+		defer consumerWG.Done()
+		if err := kafka.StartConsumer(consumerCtx, consumerGroup, db, consumerProducer, merchants, orderClient, consumerState, logger); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Kafka consumer error", zap.Error(err))
+		}
+	}()
+
+	// Void authorizations whose orders were never confirmed
+	consumerWG.Add(1)
+	go func() {
+		defer consumerWG.Done()
+		kafka.RunAuthorizationSweeper(consumerCtx, db, producer, merchants, locker, logger)
+	}()
+
+	// Fail payments left pending for longer than PAYMENT_PENDING_TIMEOUT
+	consumerWG.Add(1)
+	go func() {
+		defer consumerWG.Done()
+		kafka.RunPendingSweeper(consumerCtx, db, producer, locker, logger)
+	}()
+
+	// Setup REST API with Gin
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// OpenTelemetry middleware must be first to extract trace context
+	router.Use(otelgin.Middleware("payment-service"))
+	router.Use(middleware.LoggerMiddleware(logger))
+	router.Use(middleware.MetricsMiddleware())
+
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// Readiness endpoint (consumer-group membership)
+	router.GET("/ready", handlers.ReadinessCheck(consumerState))
+
+	// Metrics endpoint
+	router.GET("/metrics", middleware.PrometheusHandler())
+
+	// Payment records for support staff, for users with the admin role
+	paymentHandler := handlers.NewPaymentHandler(db, logger)
+	payments := router.Group("/api/v1", middleware.AuthMiddleware(), middleware.RequireRole(middleware.RoleAdmin))
+	payments.GET("/payments", paymentHandler.ListPayments)
+	payments.GET("/payments/:id", paymentHandler.GetPayment)
+	payments.GET("/payments/:id/attempts", paymentHandler.ListPaymentAttempts)
+	payments.GET("/users/:id/payments", paymentHandler.ListUserPayments)
+
+	// Debits and credits of payments, fees and refunds, for reconciliation
+	ledgerHandler := handlers.NewLedgerHandler(db, logger)
+	payments.GET("/ledger", ledgerHandler.GetLedger)
+
+	// Messages the consumer couldn't decode or handle, replayed once the cause is fixed
+	failedEventHandler := handlers.NewFailedEventHandler(db, producer, merchants, orderClient, logger)
+	payments.GET("/failed-events", failedEventHandler.ListFailedEvents)
+	payments.POST("/failed-events/:id/replay", failedEventHandler.ReplayFailedEvent)
+
+	// Asynchronous payment outcomes from the gateway, authenticated by its signature
+	gatewayWebhookHandler := handlers.NewGatewayWebhookHandler(db, producer, merchants, logger)
+	router.POST("/api/v1/webhooks/gateway", gatewayWebhookHandler.HandleWebhook)
+	router.POST("/api/v1/webhooks/gateway/:merchant_id", gatewayWebhookHandler.HandleWebhook)
+
+	// Customers' saved payment methods, guarded by the user-service JWT
+	paymentMethodHandler := handlers.NewPaymentMethodHandler(db, logger)
+	paymentMethods := router.Group("/api/v1/payment-methods", middleware.AuthMiddleware())
+	paymentMethods.POST("", paymentMethodHandler.CreatePaymentMethod)
+	paymentMethods.GET("", paymentMethodHandler.ListPaymentMethods)
+	paymentMethods.GET("/:id", paymentMethodHandler.GetPaymentMethod)
+	paymentMethods.PATCH("/:id", paymentMethodHandler.UpdatePaymentMethod)
+	paymentMethods.DELETE("/:id", paymentMethodHandler.DeletePaymentMethod)
+
+	// Start REST server
+	srv := &http.Server{
+		Addr:    ":8083",
+		Handler: router,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start REST server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Payment Service started on :8083")
+
+	// Start gRPC server; order-service reads payments through it
+	grpcListener, err := net.Listen("tcp", ":50054")
+	if err != nil {
+		logger.Fatal("Failed to listen on gRPC port", zap.Error(err))
+	}
+
+	grpcServer := grpcLib.NewServer(
+		grpcLib.StatsHandler(otelgrpc.NewServerHandler()),
+		middleware.GRPCServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+	)
+	payment.RegisterPaymentServiceServer(grpcServer, handlers.NewPaymentService(db, logger))
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Payment Service gRPC server started on :50054")
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down server...")
+
+	consumerCancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Failed to shutdown REST server gracefully", zap.Error(err))
+	}
+	grpcServer.GracefulStop()
+
+	consumerWG.Wait()
+	logger.Info("Server exited")
+	return nil
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// AvailabilityCache is an in-process cache of product and variant stock for CheckAvailability.
+// Entries live for a very short TTL so checkout bursts hit Postgres once per product
+// per window, and stock mutations on this replica invalidate them immediately. Writes on
+// other replicas arrive as product_updated events shortly after; if those are lost, the
+// TTL still bounds staleness. A nil cache disables caching.
+type AvailabilityCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[availabilityKey]availabilityEntry
+	// generations counts invalidations per product so a load that raced a stock change
+	// doesn't store the stale value it read
+	generations map[int]uint64
+}
+
+// availabilityKey identifies a product's own stock (variantID 0) or one of its variants
+type availabilityKey struct {
+	productID int
+	variantID int
+}
+
+type availabilityEntry struct {
+	stock     int
+	expiresAt time.Time
+}
+
+// NewAvailabilityCache returns a cache with AVAILABILITY_CACHE_TTL (default 500ms), or nil
+// when the TTL is set to "0" to turn caching off.
+func NewAvailabilityCache() *AvailabilityCache {
+	if getEnv("AVAILABILITY_CACHE_TTL", "") == "0" {
+		return nil
+	}
+	return &AvailabilityCache{
+		ttl:         getEnvDuration("AVAILABILITY_CACHE_TTL", 500*time.Millisecond),
+		entries:     make(map[availabilityKey]availabilityEntry),
+		generations: make(map[int]uint64),
+	}
+}
+
+// Get returns the cached stock and whether it was fresh. The generation is passed back
+// to Set after loading from the database on a miss.
+func (c *AvailabilityCache) Get(productID, variantID int) (stock int, generation uint64, ok bool) {
+	if c == nil {
+		return 0, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[availabilityKey{productID, variantID}]
+	if found && time.Now().Before(entry.expiresAt) {
+		return entry.stock, 0, true
+	}
+	return 0, c.generations[productID], false
+}
+
+// Set caches stock loaded at generation, unless the product was invalidated since
+func (c *AvailabilityCache) Set(productID, variantID, stock int, generation uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[productID] != generation {
+		return
+	}
+	c.entries[availabilityKey{productID, variantID}] = availabilityEntry{stock: stock, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// Invalidate drops the cached stock of a product and its variants after a stock mutation
+func (c *AvailabilityCache) Invalidate(productID int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.productID == productID {
+			delete(c.entries, key)
+		}
+	}
+	c.generations[productID]++
+}
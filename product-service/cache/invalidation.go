@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"product-svc/middleware"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// InvalidationStrategy decides how a committed write reaches the product cache
+type InvalidationStrategy string
+
+const (
+	// StrategyDelete drops the cached product once the write has committed
+	StrategyDelete InvalidationStrategy = "delete"
+	// StrategyDoubleDelete drops it again after DoubleDeleteDelay, clearing a stale copy
+	// that a read which started before the commit cached after the first delete
+	StrategyDoubleDelete InvalidationStrategy = "double-delete"
+	// StrategyWriteThrough replaces the cached product with the committed row, so the
+	// next read is a hit. Writes are version-guarded, so a slower fill can't undo it.
+	StrategyWriteThrough InvalidationStrategy = "write-through"
+)
+
+var (
+	// Invalidation is the strategy used after product writes (PRODUCT_CACHE_INVALIDATION)
+	Invalidation = invalidationStrategy()
+	// DoubleDeleteDelay is how long the second delete of StrategyDoubleDelete waits. It
+	// should exceed the time a cache fill takes from its database read to its write.
+	DoubleDeleteDelay = getEnvDuration("CACHE_DOUBLE_DELETE_DELAY", 500*time.Millisecond)
+	// invalidationAttempts bounds how often a failed delete is tried before giving up
+	invalidationAttempts = getEnvInt("CACHE_INVALIDATION_ATTEMPTS", 3)
+)
+
+const invalidationBackoff = 50 * time.Millisecond
+
+// InvalidateProduct deletes a cached product after a write. Unlike other cache calls,
+// any failure is retried: an entry left behind serves stale data for a whole TTL.
+func InvalidateProduct(ctx context.Context, rdb *redis.Client, id string) error {
+	return invalidate(ctx, "delete", func(ctx context.Context) error {
+		return DeleteProduct(ctx, rdb, id)
+	})
+}
+
+// WriteThroughProduct stores the committed product, retrying like InvalidateProduct
+func WriteThroughProduct(ctx context.Context, rdb *redis.Client, id string, version int, product interface{}) error {
+	return invalidate(ctx, "write_through", func(ctx context.Context) error {
+		return SetProduct(ctx, rdb, id, version, product, ProductTTL)
+	})
+}
+
+// ScheduleInvalidation deletes a cached product again after delay, in the background.
+// The delete outlives the request that scheduled it.
+func ScheduleInvalidation(ctx context.Context, rdb *redis.Client, id string, delay time.Duration, logger *zap.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(delay, func() {
+		err := invalidate(ctx, "delayed_delete", func(ctx context.Context) error {
+			return DeleteProduct(ctx, rdb, id)
+		})
+		if err != nil {
+			logger.Warn("Delayed product cache invalidation failed", zap.String("product_id", id), zap.Error(err))
+		}
+	})
+}
+
+func invalidate(ctx context.Context, step string, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < invalidationAttempts; attempt++ {
+		if attempt > 0 {
+			middleware.RecordCacheRetry("invalidate")
+			select {
+			case <-ctx.Done():
+				middleware.RecordCacheInvalidation(step, "error")
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * invalidationBackoff):
+			}
+		}
+		if err = fn(ctx); err == nil {
+			middleware.RecordCacheInvalidation(step, "ok")
+			return nil
+		}
+	}
+	middleware.RecordCacheInvalidation(step, "error")
+	return err
+}
+
+func invalidationStrategy() InvalidationStrategy {
+	switch strategy := InvalidationStrategy(os.Getenv("PRODUCT_CACHE_INVALIDATION")); strategy {
+	case StrategyDoubleDelete, StrategyWriteThrough:
+		return strategy
+	default:
+		return StrategyDelete
+	}
+}
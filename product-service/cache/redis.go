@@ -3,10 +3,18 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"product-svc/middleware"
+	"product-svc/startup"
+
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -22,10 +30,13 @@ func InitRedis(logger *zap.Logger) (*redis.Client, error) {
 		DB:       0,
 	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := rdb.Ping(ctx).Err(); err != nil {
+	err := startup.Wait(logger, "redis", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return rdb.Ping(ctx).Err()
+	})
+	if err != nil {
+		rdb.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
@@ -33,23 +44,280 @@ func InitRedis(logger *zap.Logger) (*redis.Client, error) {
 	return rdb, nil
 }
 
+// ErrMiss is returned by GetProduct when the product isn't cached. Any other error
+// means the cache itself failed and callers should fall back to the database.
+var ErrMiss = errors.New("cache miss")
+
+// ErrNotFound is returned by GetProduct when the product is cached as nonexistent
+var ErrNotFound = errors.New("product cached as not found")
+
+// notFoundMarker is stored in place of a product that doesn't exist; it is never valid
+// product JSON
+const notFoundMarker = "!not-found"
+
+var (
+	// opTimeout bounds each attempt so a slow Redis can't hold up a request
+	opTimeout = getEnvDuration("CACHE_OP_TIMEOUT", 100*time.Millisecond)
+	// maxRetries is how many times a transient failure is retried
+	maxRetries = getEnvInt("CACHE_MAX_RETRIES", 1)
+)
+
+// TTLs for each cached entity
+var (
+	// ProductTTL is how long a product read from Postgres is served from Redis
+	ProductTTL = getEnvDuration("PRODUCT_CACHE_TTL", 5*time.Minute)
+	// NotFoundTTL is how long a missing product is remembered; 0 disables negative caching
+	NotFoundTTL = notFoundTTL()
+	// RatingTTL bounds how stale a product's rating summary gets if an invalidation is lost
+	RatingTTL = getEnvDuration("RATING_CACHE_TTL", 10*time.Minute)
+)
+
+const retryBackoff = 10 * time.Millisecond
+
 func GetProduct(ctx context.Context, rdb *redis.Client, id string) ([]byte, error) {
 	key := fmt.Sprintf("product:%s", id)
-	return rdb.Get(ctx, key).Bytes()
+	var data []byte
+	err := do(ctx, "get", func(ctx context.Context) error {
+		var err error
+		data, err = rdb.Get(ctx, key).Bytes()
+		return err
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	if err == nil && string(data) == notFoundMarker {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// GetProducts looks several products up in one round trip. The result maps each cached
+// ID to its JSON, or to nil when it is cached as not found; uncached IDs are left out.
+func GetProducts(ctx context.Context, rdb *redis.Client, ids []int) (map[int][]byte, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf("product:%d", id)
+	}
+
+	var values []interface{}
+	err := do(ctx, "mget", func(ctx context.Context) error {
+		var err error
+		values, err = rdb.MGet(ctx, keys...).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[int][]byte, len(ids))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if data == notFoundMarker {
+			found[ids[i]] = nil
+			continue
+		}
+		found[ids[i]] = []byte(data)
+	}
+	return found, nil
 }
 
-func SetProduct(ctx context.Context, rdb *redis.Client, id string, product interface{}, ttl time.Duration) error {
+// setIfNewer stores a product unless the cached copy has a higher version. Without it a
+// cache fill that read the row before a write could overwrite the write's fresh entry.
+var setIfNewer = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and current ~= ARGV[4] then
+	local ok, cached = pcall(cjson.decode, current)
+	if ok and type(cached) == 'table' and tonumber(cached.version) and tonumber(cached.version) > tonumber(ARGV[2]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+return 1
+`)
+
+// SetProduct caches a product read at the given version. A newer cached version is
+// kept, so concurrent fills and writes can land in any order.
+func SetProduct(ctx context.Context, rdb *redis.Client, id string, version int, product interface{}, ttl time.Duration) error {
 	key := fmt.Sprintf("product:%s", id)
 	data, err := json.Marshal(product)
 	if err != nil {
 		return err
 	}
-	return rdb.Set(ctx, key, data, ttl).Err()
+	return do(ctx, "set", func(ctx context.Context) error {
+		return setIfNewer.Run(ctx, rdb, []string{key}, data, version, ttl.Milliseconds(), notFoundMarker).Err()
+	})
+}
+
+// SetProductNotFound remembers that a product doesn't exist, so repeated lookups of
+// the same missing ID (typical of scrapers) are answered without a query. Deleting the
+// product key, as every write does, clears it.
+func SetProductNotFound(ctx context.Context, rdb *redis.Client, id string, ttl time.Duration) error {
+	key := fmt.Sprintf("product:%s", id)
+	return do(ctx, "set", func(ctx context.Context) error {
+		return rdb.Set(ctx, key, notFoundMarker, ttl).Err()
+	})
 }
 
 func DeleteProduct(ctx context.Context, rdb *redis.Client, id string) error {
 	key := fmt.Sprintf("product:%s", id)
-	return rdb.Del(ctx, key).Err()
+	return do(ctx, "delete", func(ctx context.Context) error {
+		return rdb.Del(ctx, key).Err()
+	})
+}
+
+// do runs a cache operation with a per-attempt timeout, retrying transient failures,
+// and records its latency and outcome (hit, miss, ok or error).
+func do(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Duration(attempt) * retryBackoff):
+			}
+			// The caller gave up during the backoff; still recorded below as an error
+			if ctx.Err() != nil {
+				err = ctx.Err()
+				break
+			}
+			middleware.RecordCacheRetry(operation)
+		}
+
+		opCtx, cancel := context.WithTimeout(ctx, opTimeout)
+		err = fn(opCtx)
+		cancel()
+
+		// Stop once it worked, failed for good, or the caller gave up
+		if err == nil || !isTransient(err) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	middleware.ObserveCacheOperation(operation, cacheResult(operation, err), time.Since(start))
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("cache %s failed: %w", operation, err)
+	}
+	return err
+}
+
+func cacheResult(operation string, err error) string {
+	switch {
+	case err == nil && operation == "get":
+		return "hit"
+	case err == nil:
+		return "ok"
+	case errors.Is(err, redis.Nil):
+		return "miss"
+	default:
+		return "error"
+	}
+}
+
+// isTransient reports whether err is worth retrying: timeouts, dropped connections and
+// Redis busy replies. Misses and command errors are not.
+func isTransient(err error) bool {
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "LOADING") || strings.HasPrefix(msg, "TRYAGAIN") || strings.HasPrefix(msg, "BUSY")
+}
+
+// GetRatingSummary returns the cached rating summary JSON, or ErrMiss
+func GetRatingSummary(ctx context.Context, rdb *redis.Client, productID int) ([]byte, error) {
+	key := fmt.Sprintf("product_rating:%d", productID)
+	var data []byte
+	err := do(ctx, "get", func(ctx context.Context) error {
+		var err error
+		data, err = rdb.Get(ctx, key).Bytes()
+		return err
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return data, err
+}
+
+func SetRatingSummary(ctx context.Context, rdb *redis.Client, productID int, summary interface{}, ttl time.Duration) error {
+	key := fmt.Sprintf("product_rating:%d", productID)
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return do(ctx, "set", func(ctx context.Context) error {
+		return rdb.Set(ctx, key, data, ttl).Err()
+	})
+}
+
+// DeleteRatingSummary drops the cached summary after a new review
+func DeleteRatingSummary(ctx context.Context, rdb *redis.Client, productID int) error {
+	key := fmt.Sprintf("product_rating:%d", productID)
+	return do(ctx, "delete", func(ctx context.Context) error {
+		return rdb.Del(ctx, key).Err()
+	})
+}
+
+// featuredProductsKey holds the merchandising ranking as a sorted set of product IDs
+const featuredProductsKey = "products:featured"
+
+// ReplaceFeaturedProducts atomically swaps the featured ranking for the given scores
+func ReplaceFeaturedProducts(ctx context.Context, rdb *redis.Client, scores map[int]float64) error {
+	if len(scores) == 0 {
+		return rdb.Del(ctx, featuredProductsKey).Err()
+	}
+
+	members := make([]redis.Z, 0, len(scores))
+	for id, score := range scores {
+		members = append(members, redis.Z{Score: score, Member: strconv.Itoa(id)})
+	}
+
+	// Build the new ranking under a temporary key and rename it so readers never see a partial set
+	tmpKey := featuredProductsKey + ":tmp"
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, tmpKey)
+	pipe.ZAdd(ctx, tmpKey, members...)
+	pipe.Rename(ctx, tmpKey, featuredProductsKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetFeaturedProducts returns the top ranked product IDs with their scores, highest first
+func GetFeaturedProducts(ctx context.Context, rdb *redis.Client, limit int) ([]redis.Z, error) {
+	return rdb.ZRevRangeWithScores(ctx, featuredProductsKey, 0, int64(limit-1)).Result()
+}
+
+func notFoundTTL() time.Duration {
+	if getEnv("PRODUCT_NOT_FOUND_CACHE_TTL", "") == "0" {
+		return 0
+	}
+	return getEnvDuration("PRODUCT_NOT_FOUND_CACHE_TTL", 30*time.Second)
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheMetric reads a product cache series from the default registry: the histogram's
+// sample count, or the counter's value
+func cacheMetric(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			if h := m.GetHistogram(); h != nil {
+				return float64(h.GetSampleCount())
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func withMaxRetries(t *testing.T, n int) {
+	t.Helper()
+	previous := maxRetries
+	maxRetries = n
+	t.Cleanup(func() { maxRetries = previous })
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"miss", redis.Nil, false},
+		{"attempt timeout", context.DeadlineExceeded, true},
+		{"wrapped timeout", fmt.Errorf("read: %w", context.DeadlineExceeded), true},
+		{"dropped connection", io.EOF, true},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"loading dataset", errors.New("LOADING Redis is loading the dataset in memory"), true},
+		{"cluster retry", errors.New("TRYAGAIN multiple keys request during rehashing"), true},
+		{"busy script", errors.New("BUSY Redis is busy running a script"), true},
+		{"command error", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"caller cancelled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: expected isTransient=%v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDo_Retries(t *testing.T) {
+	withMaxRetries(t, 2)
+
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+		wantResult   string
+	}{
+		{"success", nil, 1, "ok"},
+		{"miss", redis.Nil, 1, "miss"},
+		{"command error", errors.New("WRONGTYPE wrong kind of value"), 1, "error"},
+		{"timeout", context.DeadlineExceeded, 3, "error"},
+		{"dropped connection", io.EOF, 3, "error"},
+		{"busy", errors.New("BUSY running a script"), 3, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation := "test-retries-" + tt.name
+			retries := map[string]string{"operation": operation}
+			outcome := map[string]string{"operation": operation, "result": tt.wantResult}
+			retriesBefore := cacheMetric(t, "product_cache_retries_total", retries)
+			outcomesBefore := cacheMetric(t, "product_cache_operation_duration_seconds", outcome)
+
+			attempts := 0
+			err := do(context.Background(), operation, func(ctx context.Context) error {
+				attempts++
+				return tt.err
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			if (err == nil) != (tt.err == nil) || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+			if got := cacheMetric(t, "product_cache_retries_total", retries) - retriesBefore; got != float64(tt.wantAttempts-1) {
+				t.Errorf("Expected %d retries recorded, got %v", tt.wantAttempts-1, got)
+			}
+			if got := cacheMetric(t, "product_cache_operation_duration_seconds", outcome) - outcomesBefore; got != 1 {
+				t.Errorf("Expected one %s observation, got %v", tt.wantResult, got)
+			}
+		})
+	}
+}
+
+func TestDo_RecordsOutcomeWhenCancelledDuringBackoff(t *testing.T) {
+	withMaxRetries(t, 2)
+	operation := "test-cancelled"
+	retries := map[string]string{"operation": operation}
+	outcome := map[string]string{"operation": operation, "result": "error"}
+	retriesBefore := cacheMetric(t, "product_cache_retries_total", retries)
+	outcomesBefore := cacheMetric(t, "product_cache_operation_duration_seconds", outcome)
+
+	// The caller's deadline passes during the first backoff
+	ctx, cancel := context.WithTimeout(context.Background(), retryBackoff/2)
+	defer cancel()
+	attempts := 0
+	err := do(ctx, operation, func(context.Context) error {
+		attempts++
+		return io.EOF
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no retry after cancellation, got %d attempts", attempts)
+	}
+	if got := cacheMetric(t, "product_cache_retries_total", retries) - retriesBefore; got != 0 {
+		t.Errorf("Expected no retries recorded, got %v", got)
+	}
+	if got := cacheMetric(t, "product_cache_operation_duration_seconds", outcome) - outcomesBefore; got != 1 {
+		t.Errorf("Expected the cancelled operation recorded as an error, got %v", got)
+	}
+}
+
+func TestSetProductNotFound(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	if err := SetProductNotFound(ctx, rdb, "404", 30*time.Second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got, err := mr.Get("product:404"); err != nil || got != notFoundMarker {
+		t.Errorf("Expected the not-found marker stored, got %q (%v)", got, err)
+	}
+	if ttl := mr.TTL("product:404"); ttl != 30*time.Second {
+		t.Errorf("Expected a 30s TTL, got %s", ttl)
+	}
+	if _, err := GetProduct(ctx, rdb, "404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Batch lookups report the product as cached and missing
+	found, err := GetProducts(ctx, rdb, []int{404})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, ok := found[404]; !ok || data != nil {
+		t.Errorf("Expected 404 cached as not found, got %q (cached: %v)", data, ok)
+	}
+
+	mr.FastForward(31 * time.Second)
+	if _, err := GetProduct(ctx, rdb, "404"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Expected a miss once the marker expired, got %v", err)
+	}
+}
@@ -3,8 +3,14 @@ package circuitbreaker
 import (
 	"context"
 	"errors"
+	"os"
+	"strconv"
 	"sync"
 	"time"
+
+	"product-svc/middleware"
+
+	"go.uber.org/zap"
 )
 
 type State int
@@ -15,69 +21,223 @@ const (
 	StateHalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// defaultHalfOpenProbes is how many trial calls a half-open breaker lets through unless
+// WithHalfOpenProbes says otherwise
+var defaultHalfOpenProbes = getEnvInt("CIRCUIT_BREAKER_HALF_OPEN_PROBES", 3)
+
+// StateChangeFunc is called after a breaker changes state, outside the breaker's lock.
+// Changes made by concurrent calls may be reported out of order; GetState is current.
+type StateChangeFunc func(name string, from, to State)
+
+// Option configures a CircuitBreaker
+type Option func(*CircuitBreaker)
+
+// WithHalfOpenProbes sets the half-open probe budget: how many trial calls are let
+// through after the reset timeout. The breaker closes once a majority of them succeed
+// and opens again as soon as a majority can no longer succeed, so a budget of 1 is the
+// classic single trial call.
+func WithHalfOpenProbes(probes int) Option {
+	return func(cb *CircuitBreaker) {
+		if probes > 0 {
+			cb.halfOpenProbes = probes
+		}
+	}
+}
+
+// WithStateChange registers fn to be called on every state change, e.g. to log it or
+// feed further metrics. The circuit_breaker_* metrics are kept up to date regardless.
+func WithStateChange(fn StateChangeFunc) Option {
+	return func(cb *CircuitBreaker) {
+		cb.onStateChange = append(cb.onStateChange, fn)
+	}
+}
+
 type CircuitBreaker struct {
-	maxFailures     int
-	resetTimeout    time.Duration
-	failureCount    int
-	lastFailureTime time.Time
-	state           State
-	mu              sync.RWMutex
+	// name labels the breaker's metrics
+	name           string
+	maxFailures    int
+	resetTimeout   time.Duration
+	halfOpenProbes int
+	onStateChange  []StateChangeFunc
+
+	mu    sync.Mutex
+	state State
+	// generation changes with every state change, so results of calls admitted under an
+	// earlier state are ignored
+	generation   uint64
+	failureCount int
+	openedAt     time.Time
+	// Probes admitted, and their outcomes, in the current half-open round
+	probes         int
+	probeSuccesses int
+	probeFailures  int
 }
 
 var (
 	ErrCircuitOpen = errors.New("circuit breaker is open")
 )
 
-func NewCircuitBreaker(maxFailures int, resetTimeout time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
-		maxFailures:  maxFailures,
-		resetTimeout: resetTimeout,
-		state:        StateClosed,
+// transition is a state change to report once the lock is released
+type transition struct {
+	from, to State
+}
+
+func NewCircuitBreaker(name string, maxFailures int, resetTimeout time.Duration, opts ...Option) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:           name,
+		maxFailures:    maxFailures,
+		resetTimeout:   resetTimeout,
+		halfOpenProbes: defaultHalfOpenProbes,
+		state:          StateClosed,
 	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	middleware.SetCircuitBreakerState(name, int(StateClosed))
+	return cb
 }
 
+// Execute runs fn unless the breaker is open or its half-open probe budget is spent, in
+// which case ErrCircuitOpen is returned. fn runs without holding the breaker's lock, so
+// concurrent callers don't wait on each other.
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
+	generation, change, err := cb.admit()
+	cb.notify(change)
+	if err != nil {
+		middleware.RecordCircuitBreakerShortCircuit(cb.name)
+		return err
+	}
+
+	err = fn()
+
+	cb.notify(cb.record(generation, err == nil))
+	return err
+}
+
+// admit decides whether a call may run, moving an open breaker to half-open once the
+// reset timeout has passed
+func (cb *CircuitBreaker) admit() (uint64, *transition, error) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	// Check if we should transition from Open to HalfOpen
+	var change *transition
 	if cb.state == StateOpen {
-		if time.Since(cb.lastFailureTime) > cb.resetTimeout {
-			cb.state = StateHalfOpen
-			cb.failureCount = 0
-		} else {
-			return ErrCircuitOpen
+		if time.Since(cb.openedAt) <= cb.resetTimeout {
+			return 0, nil, ErrCircuitOpen
+		}
+		change = cb.setState(StateHalfOpen)
+	}
+
+	if cb.state == StateHalfOpen {
+		if cb.probes >= cb.halfOpenProbes {
+			return 0, change, ErrCircuitOpen
 		}
+		cb.probes++
 	}
+	return cb.generation, change, nil
+}
 
-	// Execute the function
-	err := fn()
+// record counts the outcome of a call admitted in generation
+func (cb *CircuitBreaker) record(generation uint64, succeeded bool) *transition {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
-	if err != nil {
-		cb.failureCount++
-		cb.lastFailureTime = time.Now()
+	if generation != cb.generation {
+		return nil
+	}
 
+	switch cb.state {
+	case StateClosed:
+		if succeeded {
+			cb.failureCount = 0
+			return nil
+		}
+		cb.failureCount++
 		if cb.failureCount >= cb.maxFailures {
-			cb.state = StateOpen
-		} else if cb.state == StateHalfOpen {
-			cb.state = StateOpen
+			return cb.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		majority := cb.halfOpenProbes/2 + 1
+		if succeeded {
+			cb.probeSuccesses++
+		} else {
+			cb.probeFailures++
+		}
+		switch {
+		case cb.probeSuccesses >= majority:
+			return cb.setState(StateClosed)
+		case cb.probeFailures > cb.halfOpenProbes-majority:
+			return cb.setState(StateOpen)
 		}
-		return err
 	}
+	return nil
+}
 
-	// Success - reset if in HalfOpen state
-	if cb.state == StateHalfOpen {
-		cb.state = StateClosed
-		cb.failureCount = 0
-	} else if cb.state == StateClosed {
-		cb.failureCount = 0
+// setState moves the breaker to state, reports it and starts a new generation. The
+// metrics are set under the lock so they follow the actual order of changes; the caller
+// holds cb.mu and passes the returned transition to notify after releasing it.
+func (cb *CircuitBreaker) setState(state State) *transition {
+	if state == cb.state {
+		return nil
+	}
+	change := &transition{from: cb.state, to: state}
+	cb.state = state
+	cb.generation++
+	cb.failureCount = 0
+	cb.probes, cb.probeSuccesses, cb.probeFailures = 0, 0, 0
+	middleware.SetCircuitBreakerState(cb.name, int(state))
+	if state == StateOpen {
+		cb.openedAt = time.Now()
+		middleware.RecordCircuitBreakerOpen(cb.name)
 	}
+	return change
+}
 
-	return nil
+func (cb *CircuitBreaker) notify(change *transition) {
+	if change == nil {
+		return
+	}
+	for _, fn := range cb.onStateChange {
+		fn(cb.name, change.from, change.to)
+	}
+}
+
+// LogStateChanges returns a StateChangeFunc that logs every change, warning when a
+// breaker opens
+func LogStateChanges(logger *zap.Logger) StateChangeFunc {
+	return func(name string, from, to State) {
+		fields := []zap.Field{zap.String("breaker", name), zap.Stringer("from", from), zap.Stringer("to", to)}
+		if to == StateOpen {
+			logger.Warn("Circuit breaker opened", fields...)
+			return
+		}
+		logger.Info("Circuit breaker state changed", fields...)
+	}
 }
 
 func (cb *CircuitBreaker) GetState() State {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	return cb.state
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
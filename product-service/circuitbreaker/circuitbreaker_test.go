@@ -0,0 +1,169 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricValue reads a breaker's gauge or counter from the default registry
+func metricValue(t *testing.T, name, breaker string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "breaker" && label.GetValue() == breaker {
+					if m.GetGauge() != nil {
+						return m.GetGauge().GetValue()
+					}
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestCircuitBreaker_Metrics(t *testing.T) {
+	cb := NewCircuitBreaker("test_metrics", 2, 20*time.Millisecond, WithHalfOpenProbes(1))
+	failing := func() error { return errors.New("boom") }
+	ctx := context.Background()
+
+	cb.Execute(ctx, failing)
+	cb.Execute(ctx, failing)
+	if cb.GetState() != StateOpen {
+		t.Fatalf("Expected breaker to be open, got %v", cb.GetState())
+	}
+	if got := metricValue(t, "circuit_breaker_state", "test_metrics"); got != float64(StateOpen) {
+		t.Errorf("Expected state gauge %d, got %v", StateOpen, got)
+	}
+	if got := metricValue(t, "circuit_breaker_opens_total", "test_metrics"); got != 1 {
+		t.Errorf("Expected 1 open transition, got %v", got)
+	}
+
+	if err := cb.Execute(ctx, func() error { return nil }); err != ErrCircuitOpen {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if got := metricValue(t, "circuit_breaker_short_circuited_total", "test_metrics"); got != 1 {
+		t.Errorf("Expected 1 short-circuited call, got %v", got)
+	}
+
+	// After the reset timeout a successful trial call closes the breaker
+	time.Sleep(30 * time.Millisecond)
+	if err := cb.Execute(ctx, func() error { return nil }); err != nil {
+		t.Fatalf("Expected trial call to succeed, got %v", err)
+	}
+	if got := metricValue(t, "circuit_breaker_state", "test_metrics"); got != float64(StateClosed) {
+		t.Errorf("Expected state gauge %d, got %v", StateClosed, got)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbeBudget(t *testing.T) {
+	var changes []string
+	cb := NewCircuitBreaker("test_probes", 1, 10*time.Millisecond,
+		WithHalfOpenProbes(3),
+		WithStateChange(func(_ string, from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	)
+	ctx := context.Background()
+	failing := func() error { return errors.New("boom") }
+	succeeding := func() error { return nil }
+
+	cb.Execute(ctx, failing)
+	time.Sleep(20 * time.Millisecond)
+
+	// One failed probe out of three doesn't reopen the breaker...
+	if err := cb.Execute(ctx, failing); err == ErrCircuitOpen {
+		t.Fatal("Expected the first probe to be let through")
+	}
+	if cb.GetState() != StateHalfOpen {
+		t.Fatalf("Expected half-open after one failed probe, got %v", cb.GetState())
+	}
+	// ...and two successful ones close it
+	cb.Execute(ctx, succeeding)
+	cb.Execute(ctx, succeeding)
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Expected closed after a majority of probes succeeded, got %v", cb.GetState())
+	}
+
+	// Two failed probes out of three reopen it
+	cb.Execute(ctx, failing)
+	time.Sleep(20 * time.Millisecond)
+	cb.Execute(ctx, failing)
+	cb.Execute(ctx, failing)
+	if cb.GetState() != StateOpen {
+		t.Fatalf("Expected open after a majority of probes failed, got %v", cb.GetState())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed", "closed->open", "open->half-open", "half-open->open"}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected state changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Expected state changes %v, got %v", want, changes)
+			break
+		}
+	}
+}
+
+func TestCircuitBreaker_ConcurrentCallsAndProbeLimit(t *testing.T) {
+	cb := NewCircuitBreaker("test_concurrent", 1, 10*time.Millisecond, WithHalfOpenProbes(2))
+	ctx := context.Background()
+
+	// A slow call doesn't hold up the others
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cb.Execute(ctx, func() error { <-release; return nil })
+	}()
+	time.Sleep(5 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- cb.Execute(ctx, func() error { return nil }) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected the concurrent call to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Concurrent call blocked behind a slow one")
+	}
+	close(release)
+	wg.Wait()
+
+	// Once half-open, calls beyond the probe budget are short-circuited while probes run
+	cb.Execute(ctx, func() error { return errors.New("boom") })
+	time.Sleep(20 * time.Millisecond)
+	probing := make(chan struct{})
+	finish := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.Execute(ctx, func() error { probing <- struct{}{}; <-finish; return nil })
+		}()
+		<-probing
+	}
+	if err := cb.Execute(ctx, func() error { return nil }); err != ErrCircuitOpen {
+		t.Errorf("Expected ErrCircuitOpen beyond the probe budget, got %v", err)
+	}
+	close(finish)
+	wg.Wait()
+	if cb.GetState() != StateClosed {
+		t.Errorf("Expected closed after both probes succeeded, got %v", cb.GetState())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"product-svc/cache"
+	"product-svc/database"
+	"product-svc/handlers"
+	"product-svc/kafka"
+	"product-svc/lock"
+	"product-svc/middleware"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newConsumeCmd() *cobra.Command {
+	var replay bool
+	cmd := &cobra.Command{
+		Use:   "consume",
+		Short: "Run only the Kafka consumer that records product sales and releases stock",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return consume(logger, replay)
+		}),
+	}
+	cmd.Flags().BoolVar(&replay, "replay", false, "consume every retained event in a throwaway group; sales already recorded are skipped")
+	return cmd
+}
+
+// consume runs the sales consumer without the APIs or ranking job until SIGINT/SIGTERM.
+// Releases go through the same ProductService as the gRPC API, so they invalidate the
+// caches and publish product_updated as when serving.
+func consume(logger *zap.Logger, replay bool) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	defer redisClient.Close()
+
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	productService := handlers.NewProductService(db, nil, redisClient,
+		kafka.NewLowStockPublisher(producer, logger), cache.NewAvailabilityCache(),
+		kafka.NewProductEventPublisher(producer, logger), lock.NewLocker(redisClient, logger), logger)
+
+	consumerGroup, err := kafka.InitConsumer(logger, replay)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	shutdownTracing, err := middleware.InitTracing("product-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return kafka.StartConsumer(ctx, consumerGroup, db, productService.Release, logger)
+}
@@ -4,17 +4,58 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"time"
+
+	"product-svc/startup"
 
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
+// InitDB opens the database and applies pending migrations, as the service does on startup
 func InitDB(logger *zap.Logger) (*sql.DB, error) {
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "postgres")
+	db, err := Open(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrateOnStart {
+		if err := Migrate(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else {
+		logger.Info("Skipping database migrations on start", zap.String("MIGRATE_ON_START", "false"))
+	}
+
+	logger.Info("Database connection established")
+	return db, nil
+}
+
+// Open connects to the database without touching the schema, waiting for it to
+// accept connections
+func Open(logger *zap.Logger) (*sql.DB, error) {
+	db, err := connect(
+		getEnv("DB_HOST", "localhost"),
+		getEnv("DB_PORT", "5432"),
+		getEnv("DB_USER", "postgres"),
+		getEnv("DB_PASSWORD", "postgres"),
+		false,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := startup.Wait(logger, "postgres", db.Ping); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// connect opens a connection pool to the product database on host; it does not dial yet.
+// replica tells the read replica's pool apart from the primary's in the go_sql_* metrics.
+func connect(host, port, user, password string, replica bool) (*sql.DB, error) {
 	dbname := getEnv("DB_NAME", "productdb")
 
 	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -25,36 +66,21 @@ func InitDB(logger *zap.Logger) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(25)                 // max number of open connections
-	db.SetMaxIdleConns(10)                 // max number of idle connections
-	db.SetConnMaxLifetime(5 * time.Minute) // how long a connection can be reused
-	db.SetConnMaxIdleTime(1 * time.Minute) // how long an idle connection stays in pool
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	// Create products table if it doesn't exist
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS products (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		price DECIMAL(10, 2) NOT NULL,
-		stock INTEGER NOT NULL DEFAULT 0,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	if _, err := db.Exec(createTableQuery); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+	stats := dbname
+	if replica {
+		stats += "_replica"
 	}
+	configurePool(db, stats)
 
-	logger.Info("Database connection established")
 	return db, nil
 }
 
+// Migrate applies the pending migrations in migrations/. It runs on each start unless
+// MIGRATE_ON_START is false, and from the migrate command.
+func Migrate(db *sql.DB) error {
+	return applyMigrations(db)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
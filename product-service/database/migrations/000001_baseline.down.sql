@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trg_product_price_history ON products;
+DROP FUNCTION IF EXISTS record_product_price_change();
+DROP TABLE IF EXISTS product_reviews;
+DROP TABLE IF EXISTS stock_adjustments;
+DROP TABLE IF EXISTS product_price_history;
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS stock_reservations;
+DROP TABLE IF EXISTS product_images;
+DROP TABLE IF EXISTS product_sales;
+DROP TABLE IF EXISTS products;
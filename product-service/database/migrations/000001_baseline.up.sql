@@ -0,0 +1,133 @@
+-- Baseline: the schema as services created it on startup before versioned migrations.
+-- Every statement is idempotent, so databases created that way adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	price DECIMAL(10, 2) NOT NULL,
+	stock INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Unit cost, used to compute margin for the featured ranking
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cost DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- Sales recorded from order_created events, one row per order
+CREATE TABLE IF NOT EXISTS product_sales (
+	order_id INTEGER PRIMARY KEY,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	sold_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_sales_sold_at ON product_sales (sold_at, product_id);
+
+-- Image metadata; the image bytes live in S3-compatible object storage
+CREATE TABLE IF NOT EXISTS product_images (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	object_key VARCHAR(512) NOT NULL UNIQUE,
+	content_type VARCHAR(100) NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id ON product_images (product_id);
+
+-- Free-form tags and typed key-value attributes, indexed for containment filters
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_products_tags ON products USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN (attributes);
+
+-- Bumped on every write; stock mutations only apply at the version they read (optimistic locking)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Stock held for orders; reservation_id is chosen by order-service and makes reserve/release idempotent
+CREATE TABLE IF NOT EXISTS stock_reservations (
+	reservation_id VARCHAR(64) PRIMARY KEY,
+	product_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'reserved',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	released_at TIMESTAMP
+);
+ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP;
+
+-- Sellable variants of a product (size, color), each with its own SKU, price and stock
+CREATE TABLE IF NOT EXISTS product_variants (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	sku VARCHAR(64) NOT NULL UNIQUE,
+	size VARCHAR(50) NOT NULL DEFAULT '',
+	color VARCHAR(50) NOT NULL DEFAULT '',
+	price DECIMAL(10, 2) NOT NULL,
+	stock INTEGER NOT NULL DEFAULT 0,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (product_id, size, color)
+);
+
+-- Set when a reservation holds a variant's stock instead of the product's
+ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS variant_id INTEGER;
+
+-- Stock keeping unit from external catalogs; optional, but unique when set
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+
+-- Every price a product has had, written by a trigger so no write path can skip it.
+-- No foreign key: history outlives the product for auditing.
+CREATE TABLE IF NOT EXISTS product_price_history (
+	id BIGSERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL,
+	old_price DECIMAL(10, 2),
+	new_price DECIMAL(10, 2) NOT NULL,
+	changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_price_history_product ON product_price_history (product_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_product_price_change() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		INSERT INTO product_price_history (product_id, old_price, new_price) VALUES (NEW.id, NULL, NEW.price);
+	ELSIF NEW.price IS DISTINCT FROM OLD.price THEN
+		INSERT INTO product_price_history (product_id, old_price, new_price) VALUES (NEW.id, OLD.price, NEW.price);
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER trg_product_price_history
+	AFTER INSERT OR UPDATE OF price ON products
+	FOR EACH ROW EXECUTE FUNCTION record_product_price_change();
+
+-- Ledger of manual stock adjustments. Like price history, it has no foreign key so the
+-- audit trail outlives the product.
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+	id BIGSERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL,
+	delta INTEGER NOT NULL,
+	reason VARCHAR(32) NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	stock_after INTEGER NOT NULL,
+	adjusted_by VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_product ON stock_adjustments (product_id, created_at);
+
+-- Customer reviews, one per user and product
+CREATE TABLE IF NOT EXISTS product_reviews (
+	id BIGSERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL,
+	rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	comment TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (product_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_reviews_product ON product_reviews (product_id, created_at);
@@ -0,0 +1,3 @@
+-- ISO 4217 code of the currency a product is priced in. Existing products were priced
+-- in US dollars.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
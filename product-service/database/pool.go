@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Connection pool settings. The defaults suit one replica of the service against the
+// demo Postgres; lower DB_MAX_OPEN_CONNS when many replicas share a server, since
+// Postgres refuses connections past its max_connections.
+var (
+	maxOpenConns    = getEnvInt("DB_MAX_OPEN_CONNS", 25)
+	maxIdleConns    = getEnvInt("DB_MAX_IDLE_CONNS", 10)
+	connMaxLifetime = getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute)
+	connMaxIdleTime = getEnvDuration("DB_CONN_MAX_IDLE_TIME", time.Minute)
+)
+
+var (
+	statsMu sync.Mutex
+	// statsCollectors holds the registered sql.DBStats collector per database name
+	statsCollectors = map[string]prometheus.Collector{}
+)
+
+// configurePool applies the pool settings to db and exports its sql.DBStats as the
+// go_sql_* metrics, labelled db_name=dbName. Opening a pool under the same name again
+// replaces the earlier pool's collector.
+func configurePool(db *sql.DB, dbName string) {
+	db.SetMaxOpenConns(maxOpenConns)       // max number of open connections
+	db.SetMaxIdleConns(maxIdleConns)       // max number of idle connections, capped at the above
+	db.SetConnMaxLifetime(connMaxLifetime) // how long a connection can be reused
+	db.SetConnMaxIdleTime(connMaxIdleTime) // how long an idle connection stays in pool
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if old, ok := statsCollectors[dbName]; ok {
+		prometheus.Unregister(old)
+	}
+	collector := collectors.NewDBStatsCollector(db, dbName)
+	prometheus.MustRegister(collector)
+	statsCollectors[dbName] = collector
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"product-svc/middleware"
+
+	"go.uber.org/zap"
+)
+
+// Replica routes read-only queries to a read replica while it passes health checks,
+// and to the primary while it doesn't
+type Replica struct {
+	primary  *sql.DB
+	replica  *sql.DB
+	healthy  atomic.Bool
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+// OpenReplica connects to the read replica at DB_READ_HOST, or returns nil when none is
+// configured. Unlike the primary, a replica that is down doesn't hold up startup:
+// reads use the primary until a health check finds the replica up.
+func OpenReplica(logger *zap.Logger, primary *sql.DB) (*Replica, error) {
+	host := getEnv("DB_READ_HOST", "")
+	if host == "" {
+		return nil, nil
+	}
+
+	db, err := connect(
+		host,
+		getEnv("DB_READ_PORT", getEnv("DB_PORT", "5432")),
+		getEnv("DB_READ_USER", getEnv("DB_USER", "postgres")),
+		getEnv("DB_READ_PASSWORD", getEnv("DB_PASSWORD", "postgres")),
+		true,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replica{
+		primary:  primary,
+		replica:  db,
+		interval: getEnvDuration("DB_READ_HEALTH_INTERVAL", 5*time.Second),
+		logger:   logger,
+	}
+	r.check(context.Background())
+	logger.Info("Read replica configured", zap.String("host", host), zap.Bool("healthy", r.healthy.Load()))
+	return r, nil
+}
+
+// Reader returns the pool for read-only queries: the replica while it is healthy,
+// otherwise the primary
+func (r *Replica) Reader() *sql.DB {
+	if r.healthy.Load() {
+		return r.replica
+	}
+	return r.primary
+}
+
+// Monitor pings the replica every DB_READ_HEALTH_INTERVAL until ctx is done, switching
+// reads to the primary while it is down and back once it recovers
+func (r *Replica) Monitor(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+func (r *Replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	err := r.replica.PingContext(ctx)
+	healthy := err == nil
+	if was := r.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			r.logger.Info("Read replica is up, routing reads to it")
+		} else {
+			r.logger.Warn("Read replica is down, routing reads to the primary", zap.Error(err))
+		}
+	}
+	middleware.SetReplicaHealthy(healthy)
+}
+
+func (r *Replica) Close() error {
+	return r.replica.Close()
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReplica_FallsBackToPrimary(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock primary: %v", err)
+	}
+	defer primary.Close()
+	replicaDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Failed to create mock replica: %v", err)
+	}
+	defer replicaDB.Close()
+
+	r := &Replica{primary: primary, replica: replicaDB, logger: zaptest.NewLogger(t)}
+
+	mock.ExpectPing()
+	r.check(context.Background())
+	if r.Reader() != replicaDB {
+		t.Error("Expected reads to go to the healthy replica")
+	}
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	r.check(context.Background())
+	if r.Reader() != primary {
+		t.Error("Expected reads to fall back to the primary while the replica is down")
+	}
+
+	mock.ExpectPing()
+	r.check(context.Background())
+	if r.Reader() != replicaDB {
+		t.Error("Expected reads to return to the replica once it recovers")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ping expectations were not met: %v", err)
+	}
+}
+
+func TestOpenReplica_NotConfigured(t *testing.T) {
+	t.Setenv("DB_READ_HOST", "")
+
+	r, err := OpenReplica(zaptest.NewLogger(t), nil)
+	if err != nil || r != nil {
+		t.Errorf("Expected no replica without DB_READ_HOST, got %v, %v", r, err)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"product-svc/models"
+	product "product-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+const defaultListBatchSize = 100
+
+// ListProducts streams every product with an id above req.AfterId in id order. The
+// catalog is read in keyset-paginated batches, so a resumed stream neither repeats nor
+// skips products, and no query stays open while the client is slow to receive.
+func (s *ProductService) ListProducts(req *product.ListProductsRequest, stream grpc.ServerStreamingServer[product.GetProductResponse]) error {
+	ctx, span := otel.Tracer("product-service").Start(stream.Context(), "ListProducts_gRPC")
+	defer span.End()
+
+	batchSize := int(req.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = defaultListBatchSize
+	}
+	cursor := int(req.GetAfterId())
+	span.SetAttributes(attribute.Int("list.after_id", cursor), attribute.Int("list.batch_size", batchSize))
+
+	sent := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := s.listProductsAfter(ctx, cursor, batchSize)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		for _, p := range batch {
+			resp, err := productToProto(p)
+			if err != nil {
+				s.logger.Error("Failed to encode product attributes", zap.Int("product_id", p.ID), zap.Error(err))
+				return err
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			cursor = p.ID
+			sent++
+		}
+
+		if len(batch) < batchSize {
+			span.SetAttributes(attribute.Int("list.sent", sent))
+			return nil
+		}
+	}
+}
+
+// listProductsAfter reads the next batch of products after the cursor id
+func (s *ProductService) listProductsAfter(ctx context.Context, afterID, limit int) ([]models.Product, error) {
+	rows, err := s.reader().QueryContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id > $1 ORDER BY id LIMIT $2",
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		batch = append(batch, p)
+	}
+	return batch, rows.Err()
+}
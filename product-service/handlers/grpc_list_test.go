@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	product "product-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"google.golang.org/grpc"
+)
+
+// listStream collects what ListProducts sends
+type listStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*product.GetProductResponse
+}
+
+func (s *listStream) Context() context.Context { return s.ctx }
+
+func (s *listStream) Send(p *product.GetProductResponse) error {
+	s.sent = append(s.sent, p)
+	return nil
+}
+
+func listRows(ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"})
+	for _, id := range ids {
+		rows.AddRow(id, "Product", nil, 9.99, "USD", 10, 1, "{}", []byte("{}"), time.Now(), time.Now())
+	}
+	return rows
+}
+
+func TestProductService_ListProducts_ResumesAfterCursorInBatches(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	query := "SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id > \\$1 ORDER BY id LIMIT \\$2"
+	mock.ExpectQuery(query).WithArgs(3, 2).WillReturnRows(listRows(4, 5))
+	mock.ExpectQuery(query).WithArgs(5, 2).WillReturnRows(listRows(7))
+
+	stream := &listStream{ctx: context.Background()}
+	if err := service.ListProducts(&product.ListProductsRequest{AfterId: 3, BatchSize: 2}, stream); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(stream.sent) != 3 {
+		t.Fatalf("Expected 3 products, got %d", len(stream.sent))
+	}
+	for i, id := range []int32{4, 5, 7} {
+		if stream.sent[i].GetId() != id {
+			t.Errorf("Expected product %d at position %d, got %d", id, i, stream.sent[i].GetId())
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
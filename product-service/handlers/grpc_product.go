@@ -4,23 +4,45 @@ import (
 	"context"
 	"database/sql"
 
+	"product-svc/cache"
+	"product-svc/database"
+	"product-svc/kafka"
+	"product-svc/lock"
+	"product-svc/middleware"
 	"product-svc/models"
 	product "product-svc/proto"
 
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type ProductService struct {
 	product.UnimplementedProductServiceServer
-	db     *sql.DB
-	logger *zap.Logger
+	db            *sql.DB
+	replica       *database.Replica
+	redisClient   *redis.Client
+	lowStock      *kafka.LowStockPublisher
+	availability  *cache.AvailabilityCache
+	productEvents *kafka.ProductEventPublisher
+	locker        *lock.Locker
+	logger        *zap.Logger
 }
 
-func NewProductService(db *sql.DB, logger *zap.Logger) *ProductService {
+func NewProductService(db *sql.DB, replica *database.Replica, redisClient *redis.Client, lowStock *kafka.LowStockPublisher, availability *cache.AvailabilityCache, productEvents *kafka.ProductEventPublisher, locker *lock.Locker, logger *zap.Logger) *ProductService {
 	return &ProductService{
-		db:     db,
-		logger: logger,
+		db:            db,
+		replica:       replica,
+		redisClient:   redisClient,
+		lowStock:      lowStock,
+		availability:  availability,
+		productEvents: productEvents,
+		locker:        locker,
+		logger:        logger,
 	}
 }
 
@@ -28,24 +50,66 @@ func (s *ProductService) GetProduct(ctx context.Context, req *product.GetProduct
 	ctx, span := otel.Tracer("product-service").Start(ctx, "GetProduct_gRPC")
 	defer span.End()
 
+	return s.getProduct(ctx, "id", req.ProductId)
+}
+
+// GetProductBySKU lets integrations with external catalogs resolve products by SKU
+func (s *ProductService) GetProductBySKU(ctx context.Context, req *product.GetProductBySKURequest) (*product.GetProductResponse, error) {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "GetProductBySKU_gRPC")
+	defer span.End()
+
+	sku := models.NormalizeSKU(req.GetSku())
+	span.SetAttributes(attribute.String("product.sku", sku))
+	return s.getProduct(ctx, "sku", sku)
+}
+
+// getProduct loads the product whose column (id or sku) equals value
+func (s *ProductService) getProduct(ctx context.Context, column string, value interface{}) (*product.GetProductResponse, error) {
 	var p models.Product
-	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, price, stock FROM products WHERE id = $1",
-		req.ProductId,
-	).Scan(&p.ID, &p.Name, &p.Price, &p.Stock)
+	err := scanProduct(s.reader().QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE "+column+" = $1",
+		value,
+	), &p)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, err
+			return nil, status.Error(codes.NotFound, "product not found")
 		}
 		return nil, err
 	}
 
+	resp, err := productToProto(p)
+	if err != nil {
+		s.logger.Error("Failed to encode product attributes", zap.Int("product_id", p.ID), zap.Error(err))
+		return nil, err
+	}
+	return resp, nil
+}
+
+// reader returns the pool for read-only queries, like ProductHandler.reader
+func (s *ProductService) reader() *sql.DB {
+	if s.replica == nil {
+		return s.db
+	}
+	return s.replica.Reader()
+}
+
+// productToProto converts a product for gRPC and protobuf REST responses
+func productToProto(p models.Product) (*product.GetProductResponse, error) {
+	attributes, err := structpb.NewStruct(p.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
 	return &product.GetProductResponse{
-		Id:    int32(p.ID),
-		Name:  p.Name,
-		Price: float32(p.Price),
-		Stock: int32(p.Stock),
+		Id:         int32(p.ID),
+		Name:       p.Name,
+		Sku:        p.SKU,
+		Price:      float32(p.Price),
+		Currency:   p.Currency,
+		Stock:      int32(p.Stock),
+		Tags:       p.Tags,
+		Attributes: attributes,
 	}, nil
 }
 
@@ -53,11 +117,33 @@ func (s *ProductService) CheckAvailability(ctx context.Context, req *product.Che
 	ctx, span := otel.Tracer("product-service").Start(ctx, "CheckAvailability_gRPC")
 	defer span.End()
 
-	var stock int
-	err := s.db.QueryRowContext(ctx,
-		"SELECT stock FROM products WHERE id = $1",
-		req.ProductId,
-	).Scan(&stock)
+	productID, variantID := int(req.GetProductId()), int(req.GetVariantId())
+	span.SetAttributes(attribute.Int("product.id", productID))
+	if variantID != 0 {
+		span.SetAttributes(attribute.Int("variant.id", variantID))
+	}
+
+	// Checkout bursts ask about the same products repeatedly; a very short-lived cache
+	// answers most of them without a query
+	stock, generation, cached := s.availability.Get(productID, variantID)
+	span.SetAttributes(attribute.Bool("cache.hit", cached))
+	if cached {
+		middleware.RecordAvailabilityCache("hit")
+		return &product.CheckAvailabilityResponse{
+			Available: stock >= int(req.Quantity),
+			Stock:     int32(stock),
+		}, nil
+	}
+	middleware.RecordAvailabilityCache("miss")
+
+	query, args := "SELECT stock FROM products WHERE id = $1", []interface{}{req.ProductId}
+	if variantID != 0 {
+		// A variant of another product is as unavailable as a missing one
+		query, args = "SELECT stock FROM product_variants WHERE id = $1 AND product_id = $2", []interface{}{req.GetVariantId(), req.ProductId}
+	}
+
+	// A replica may lag by a moment, like the cache; ReserveStock re-checks stock on the primary
+	err := s.reader().QueryRowContext(ctx, query, args...).Scan(&stock)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -69,9 +155,58 @@ func (s *ProductService) CheckAvailability(ctx context.Context, req *product.Che
 		return nil, err
 	}
 
+	s.availability.Set(productID, variantID, stock, generation)
+
 	available := stock >= int(req.Quantity)
 	return &product.CheckAvailabilityResponse{
 		Available: available,
 		Stock:     int32(stock),
 	}, nil
 }
+
+// GetVariant returns a variant with its own price and stock, for pricing orders of it
+func (s *ProductService) GetVariant(ctx context.Context, req *product.GetVariantRequest) (*product.ProductVariant, error) {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "GetVariant_gRPC")
+	defer span.End()
+
+	var v models.ProductVariant
+	err := scanVariant(s.reader().QueryRowContext(ctx,
+		"SELECT "+variantColumns+" FROM product_variants WHERE id = $1",
+		req.GetVariantId(),
+	), &v)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "variant not found")
+		}
+		return nil, err
+	}
+
+	return &product.ProductVariant{
+		Id:        int32(v.ID),
+		ProductId: int32(v.ProductID),
+		Sku:       v.SKU,
+		Size:      v.Size,
+		Color:     v.Color,
+		Price:     float32(v.Price),
+		Stock:     int32(v.Stock),
+	}, nil
+}
+
+// productListToProto converts a REST product list page for protobuf responses
+func productListToProto(list models.ProductListResponse) (*product.ProductListResponse, error) {
+	resp := &product.ProductListResponse{
+		Data:       make([]*product.GetProductResponse, 0, len(list.Data)),
+		Page:       int32(list.Page),
+		Limit:      int32(list.Limit),
+		Total:      int32(list.Total),
+		TotalPages: int32(list.TotalPages),
+	}
+	for _, p := range list.Data {
+		msg, err := productToProto(p)
+		if err != nil {
+			return nil, err
+		}
+		resp.Data = append(resp.Data, msg)
+	}
+	return resp, nil
+}
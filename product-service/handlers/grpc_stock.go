@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"product-svc/lock"
+	"product-svc/middleware"
+	product "product-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	reservationReserved  = "reserved"
+	reservationReleased  = "released"
+	reservationConfirmed = "confirmed"
+
+	// maxStockUpdateAttempts bounds optimistic-lock retries when the version keeps moving
+	maxStockUpdateAttempts = 3
+
+	// stockLockTTL and stockLockWait configure the per-product lock held around stock
+	// mutations: how long it lives without renewal, and how long a caller queues for it
+	stockLock     = "stock"
+	stockLockTTL  = 5 * time.Second
+	stockLockWait = 2 * time.Second
+)
+
+var errVersionConflict = errors.New("product version changed concurrently")
+
+// ReserveStock atomically takes quantity units out of stock and records the reservation.
+// Retrying with the same reservation_id returns the original outcome without reserving twice.
+func (s *ProductService) ReserveStock(ctx context.Context, req *product.ReserveStockRequest) (*product.ReserveStockResponse, error) {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "ReserveStock_gRPC")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("reservation.id", req.GetReservationId()),
+		attribute.Int("product.id", int(req.GetProductId())),
+		attribute.Int("variant.id", int(req.GetVariantId())),
+		attribute.Int("reservation.quantity", int(req.GetQuantity())),
+	)
+
+	// Replicas take the product's stock lock so reservations run one at a time instead
+	// of burning their optimistic retries against each other
+	var resp *product.ReserveStockResponse
+	err := s.locker.Guard(ctx, stockLock, strconv.Itoa(int(req.GetProductId())), stockLockTTL, stockLockWait, func(ctx context.Context) error {
+		var err error
+		resp, err = s.reserveStock(ctx, req)
+		return err
+	})
+	if errors.Is(err, lock.ErrNotAcquired) {
+		span.SetAttributes(attribute.Bool("lock.contended", true))
+		return nil, status.Error(codes.Aborted, "product stock is busy, retry")
+	}
+	return resp, err
+}
+
+func (s *ProductService) reserveStock(ctx context.Context, req *product.ReserveStockRequest) (*product.ReserveStockResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO stock_reservations (reservation_id, product_id, quantity, variant_id) VALUES ($1, $2, $3, NULLIF($4, 0)) ON CONFLICT (reservation_id) DO NOTHING",
+		req.GetReservationId(), req.GetProductId(), req.GetQuantity(), req.GetVariantId(),
+	)
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "failed to record reservation")
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		// A retry of a reservation that already went through
+		var reservationStatus string
+		var stock int
+		err := tx.QueryRowContext(ctx,
+			"SELECT r.status, COALESCE(v.stock, p.stock) FROM stock_reservations r JOIN products p ON p.id = r.product_id LEFT JOIN product_variants v ON v.id = r.variant_id WHERE r.reservation_id = $1",
+			req.GetReservationId(),
+		).Scan(&reservationStatus, &stock)
+		if err != nil {
+			span.RecordError(err)
+			return nil, status.Error(codes.Internal, "failed to load reservation")
+		}
+		return &product.ReserveStockResponse{
+			Reserved: reservationStatus != reservationReleased,
+			Stock:    int32(stock),
+		}, nil
+	}
+
+	stock, reserved, err := s.decrementStock(ctx, tx, req.GetProductId(), req.GetVariantId(), int(req.GetQuantity()))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			if req.GetVariantId() != 0 {
+				return nil, status.Error(codes.NotFound, "variant not found")
+			}
+			return nil, status.Error(codes.NotFound, "product not found")
+		}
+		if errors.Is(err, errVersionConflict) {
+			return nil, status.Error(codes.Aborted, "product stock is changing too quickly, retry")
+		}
+		return nil, status.Error(codes.Internal, "failed to reserve stock")
+	}
+	if !reserved {
+		// Rolling back drops the reservation row
+		span.SetAttributes(attribute.Bool("reserved", false))
+		return &product.ReserveStockResponse{Reserved: false, Stock: int32(stock)}, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "failed to commit reservation")
+	}
+
+	invalidateProduct(ctx, s.db, s.redisClient, s.logger, strconv.Itoa(int(req.GetProductId())))
+	s.availability.Invalidate(int(req.GetProductId()))
+	s.productEvents.Updated(ctx, int(req.GetProductId()))
+	// Low-stock alerts track the product's own stock, not its variants'
+	if req.GetVariantId() == 0 {
+		s.lowStock.StockChanged(ctx, int(req.GetProductId()), "", stock+int(req.GetQuantity()), stock)
+	}
+
+	span.SetAttributes(attribute.Bool("reserved", true))
+	middleware.RecordBusinessEvent(ctx, middleware.EventStockReserved,
+		attribute.String("reservation.id", req.GetReservationId()),
+		attribute.Int("product.id", int(req.GetProductId())),
+		attribute.Int("order.quantity", int(req.GetQuantity())),
+		attribute.Int("stock.remaining", stock),
+	)
+	s.logger.Info("Stock reserved",
+		zap.String("reservation_id", req.GetReservationId()),
+		zap.Int32("product_id", req.GetProductId()),
+		zap.Int32("variant_id", req.GetVariantId()),
+		zap.Int32("quantity", req.GetQuantity()),
+		zap.Int("stock", stock),
+	)
+	return &product.ReserveStockResponse{Reserved: true, Stock: int32(stock)}, nil
+}
+
+// ReleaseStock returns a reservation's units to stock. Releasing an unknown or
+// already released reservation is a no-op that reports released=false. Releases only
+// add stock in a single statement, so they can't oversell and don't take the stock lock.
+func (s *ProductService) ReleaseStock(ctx context.Context, req *product.ReleaseStockRequest) (*product.ReleaseStockResponse, error) {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "ReleaseStock_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("reservation.id", req.GetReservationId()))
+
+	released, err := s.Release(ctx, req.GetReservationId())
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "failed to release reservation")
+	}
+	span.SetAttributes(attribute.Bool("released", released))
+	return &product.ReleaseStockResponse{Released: released}, nil
+}
+
+// Release gives reservationID's units back to stock and reports whether it did. It
+// backs ReleaseStock and the sales consumer's stock_release events, so a release
+// requested both ways returns the units once.
+func (s *ProductService) Release(ctx context.Context, reservationID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID, quantity int
+	var variantID sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		"UPDATE stock_reservations SET status = $1, released_at = CURRENT_TIMESTAMP WHERE reservation_id = $2 AND status = $3 RETURNING product_id, variant_id, quantity",
+		reservationReleased, reservationID, reservationReserved,
+	).Scan(&productID, &variantID, &quantity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to release reservation: %w", err)
+	}
+
+	restore, target := "UPDATE products SET stock = stock + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", int64(productID)
+	if variantID.Valid {
+		restore, target = "UPDATE product_variants SET stock = stock + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", variantID.Int64
+	}
+	if _, err := tx.ExecContext(ctx, restore, quantity, target); err != nil {
+		return false, fmt.Errorf("failed to restore stock: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit release: %w", err)
+	}
+
+	invalidateProduct(ctx, s.db, s.redisClient, s.logger, strconv.Itoa(productID))
+	s.availability.Invalidate(productID)
+	s.productEvents.Updated(ctx, productID)
+
+	s.logger.Info("Stock released",
+		zap.String("reservation_id", reservationID),
+		zap.Int("product_id", productID),
+		zap.Int("quantity", quantity),
+	)
+	return true, nil
+}
+
+// ConfirmStock settles a reservation once its order is paid. The units stay out of stock
+// and a later ReleaseStock no longer returns them. Confirming twice reports
+// confirmed=true again; a released or unknown reservation reports confirmed=false.
+func (s *ProductService) ConfirmStock(ctx context.Context, req *product.ConfirmStockRequest) (*product.ConfirmStockResponse, error) {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "ConfirmStock_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("reservation.id", req.GetReservationId()))
+
+	var reservationStatus string
+	err := s.db.QueryRowContext(ctx,
+		"UPDATE stock_reservations SET status = $1, confirmed_at = CURRENT_TIMESTAMP WHERE reservation_id = $2 AND status = $3 RETURNING status",
+		reservationConfirmed, req.GetReservationId(), reservationReserved,
+	).Scan(&reservationStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already settled one way or the other, or never reserved
+		err = s.db.QueryRowContext(ctx,
+			"SELECT status FROM stock_reservations WHERE reservation_id = $1",
+			req.GetReservationId(),
+		).Scan(&reservationStatus)
+		if errors.Is(err, sql.ErrNoRows) {
+			span.SetAttributes(attribute.Bool("confirmed", false))
+			return &product.ConfirmStockResponse{Confirmed: false}, nil
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "failed to confirm reservation")
+	}
+
+	confirmed := reservationStatus == reservationConfirmed
+	span.SetAttributes(attribute.Bool("confirmed", confirmed))
+	if !confirmed {
+		s.logger.Warn("Cannot confirm a released stock reservation", zap.String("reservation_id", req.GetReservationId()))
+		return &product.ConfirmStockResponse{Confirmed: false}, nil
+	}
+	s.logger.Info("Stock reservation confirmed", zap.String("reservation_id", req.GetReservationId()))
+	return &product.ConfirmStockResponse{Confirmed: true}, nil
+}
+
+// decrementStock takes quantity units out of stock using optimistic locking: it reads the
+// current version and only writes if the row is still at that version and has enough stock.
+// Stock comes from the variant when variantID is set, and from the product otherwise.
+// reserved is false when there isn't enough stock; sql.ErrNoRows means the product, or the
+// variant of that product, is missing.
+func (s *ProductService) decrementStock(ctx context.Context, tx *sql.Tx, productID, variantID int32, quantity int) (int, bool, error) {
+	read, readArgs := "SELECT stock, version FROM products WHERE id = $1", []interface{}{productID}
+	write, target := "UPDATE products SET stock = stock - $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND version = $3 AND stock >= $1 RETURNING stock", productID
+	if variantID != 0 {
+		read, readArgs = "SELECT stock, version FROM product_variants WHERE id = $1 AND product_id = $2", []interface{}{variantID, productID}
+		write, target = "UPDATE product_variants SET stock = stock - $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND version = $3 AND stock >= $1 RETURNING stock", variantID
+	}
+
+	for attempt := 1; attempt <= maxStockUpdateAttempts; attempt++ {
+		var stock, version int
+		if err := tx.QueryRowContext(ctx, read, readArgs...).Scan(&stock, &version); err != nil {
+			return 0, false, err
+		}
+		if stock < quantity {
+			return stock, false, nil
+		}
+
+		err := tx.QueryRowContext(ctx, write, quantity, target, version).Scan(&stock)
+		if err == nil {
+			return stock, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, false, err
+		}
+
+		// Someone else wrote the product between our read and write
+		middleware.RecordStockConflict("reserve")
+		s.logger.Debug("Stock version conflict, retrying",
+			zap.Int32("product_id", productID),
+			zap.Int32("variant_id", variantID),
+			zap.Int("attempt", attempt),
+		)
+	}
+	return 0, false, errVersionConflict
+}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"product-svc/cache"
+	"product-svc/kafka"
+	"product-svc/middleware"
+	product "product-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama/mocks"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func setupStockTest(t *testing.T) (*ProductService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+
+	logger := zaptest.NewLogger(t, zaptest.Level(zap.InfoLevel))
+	return NewProductService(db, nil, redisClient, nil, nil, nil, nil, logger), mock
+}
+
+func TestProductService_ReserveStock_Success(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs("res-1", int32(1), int32(3), int32(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(10, 4))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1, version = version \\+ 1, updated_at = CURRENT_TIMESTAMP WHERE id = \\$2 AND version = \\$3 AND stock >= \\$1").
+		WithArgs(3, int32(1), 4).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(7))
+	mock.ExpectCommit()
+
+	resp, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-1",
+		ProductId:     1,
+		Quantity:      3,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetReserved() || resp.GetStock() != 7 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+// expectReserve sets up a successful reservation taking stock from before to before-quantity
+func expectReserve(mock sqlmock.Sqlmock, reservationID string, before, quantity int) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs(reservationID, int32(1), int32(quantity), int32(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(before, 1))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(before - quantity))
+	mock.ExpectCommit()
+}
+
+func TestProductService_ReserveStock_PublishesLowStockOnce(t *testing.T) {
+	t.Setenv("LOW_STOCK_THRESHOLD", "5")
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+		var event kafka.LowStockEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		if event.EventType != "product_low_stock" || event.ProductID != 1 || event.Stock != 3 || event.Threshold != 5 {
+			return fmt.Errorf("unexpected event: %+v", event)
+		}
+		return nil
+	})
+	service.lowStock = kafka.NewLowStockPublisher(producer, service.logger)
+
+	// 6 -> 3 crosses the threshold and alerts; 3 -> 2 is already low and doesn't
+	expectReserve(mock, "res-low-1", 6, 3)
+	expectReserve(mock, "res-low-2", 3, 1)
+
+	for _, req := range []*product.ReserveStockRequest{
+		{ReservationId: "res-low-1", ProductId: 1, Quantity: 3},
+		{ReservationId: "res-low-2", ProductId: 1, Quantity: 1},
+	} {
+		if _, err := service.ReserveStock(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Errorf("Producer expectations were not met: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_RecordsStockReservedEvent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	expectReserve(mock, "res-event", 10, 2)
+	if _, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-event",
+		ProductId:     1,
+		Quantity:      2,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var found bool
+	for _, span := range recorder.Ended() {
+		for _, event := range span.Events() {
+			if event.Name != middleware.EventStockReserved {
+				continue
+			}
+			found = true
+			attrs := attribute.NewSet(event.Attributes...)
+			if v, _ := attrs.Value("reservation.id"); v.AsString() != "res-event" {
+				t.Errorf("Expected reservation.id res-event, got %q", v.AsString())
+			}
+			if v, _ := attrs.Value("stock.remaining"); v.AsInt64() != 8 {
+				t.Errorf("Expected stock.remaining 8, got %d", v.AsInt64())
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected a %s span event", middleware.EventStockReserved)
+	}
+}
+
+func TestProductService_CheckAvailability_CachesUntilStockChanges(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+	service.availability = cache.NewAvailabilityCache()
+
+	// Repeated checks share one query until a reservation invalidates the entry
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+	expectReserve(mock, "res-cache", 10, 4)
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(6))
+
+	check := func(quantity int32) *product.CheckAvailabilityResponse {
+		resp, err := service.CheckAvailability(context.Background(), &product.CheckAvailabilityRequest{ProductId: 1, Quantity: quantity})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp := check(8); !resp.GetAvailable() || resp.GetStock() != 10 {
+			t.Fatalf("Unexpected response: %+v", resp)
+		}
+	}
+
+	if _, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-cache",
+		ProductId:     1,
+		Quantity:      4,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp := check(8); resp.GetAvailable() || resp.GetStock() != 6 {
+		t.Errorf("Expected fresh stock after reservation, got %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_InsufficientStock(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs("res-2", int32(1), int32(10), int32(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(4, 2))
+	mock.ExpectRollback()
+
+	resp, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-2",
+		ProductId:     1,
+		Quantity:      10,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetReserved() || resp.GetStock() != 4 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_VersionConflictRetries(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// First attempt loses the race to a concurrent writer
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(5, 1))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(2, int32(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+
+	// Second attempt re-reads the new version and succeeds
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(4, 2))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(2, int32(1), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+	mock.ExpectCommit()
+
+	resp, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-4",
+		ProductId:     1,
+		Quantity:      2,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetReserved() || resp.GetStock() != 2 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_Retry(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs("res-1", int32(1), int32(3), int32(0)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT r.status, COALESCE\\(v.stock, p.stock\\) FROM stock_reservations r").
+		WithArgs("res-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "stock"}).AddRow("reserved", 7))
+	mock.ExpectRollback()
+
+	resp, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-1",
+		ProductId:     1,
+		Quantity:      3,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetReserved() {
+		t.Errorf("Expected retried reservation to report reserved")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_ProductNotFound(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock, version FROM products WHERE id = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}))
+	mock.ExpectRollback()
+
+	_, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-3",
+		ProductId:     999,
+		Quantity:      1,
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestProductService_ReleaseStock_AlreadyReleased(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1").
+		WithArgs("released", "res-1", "reserved").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}))
+	mock.ExpectRollback()
+
+	resp, err := service.ReleaseStock(context.Background(), &product.ReleaseStockRequest{ReservationId: "res-1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetReleased() {
+		t.Errorf("Expected released=false for an already released reservation")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ConfirmStock(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1, confirmed_at = CURRENT_TIMESTAMP").
+		WithArgs("confirmed", "res-1", "reserved").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("confirmed"))
+
+	resp, err := service.ConfirmStock(context.Background(), &product.ConfirmStockRequest{ReservationId: "res-1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetConfirmed() {
+		t.Errorf("Expected confirmed=true")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ConfirmStock_AlreadyReleased(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1, confirmed_at = CURRENT_TIMESTAMP").
+		WithArgs("confirmed", "res-1", "reserved").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectQuery("SELECT status FROM stock_reservations WHERE reservation_id = \\$1").
+		WithArgs("res-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("released"))
+
+	resp, err := service.ConfirmStock(context.Background(), &product.ConfirmStockRequest{ReservationId: "res-1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetConfirmed() {
+		t.Errorf("Expected confirmed=false for a released reservation")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPriceHistoryLimit = 100
+	maxPriceHistoryLimit     = 1000
+)
+
+// GetPriceHistory returns a product's price changes, newest first. History is kept after
+// a product is deleted, so it is only a 404 when the product never existed.
+func (h *ProductHandler) GetPriceHistory(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetPriceHistory")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("product.id", productID))
+
+	limit := defaultPriceHistoryLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPriceHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+
+	reads := h.reader()
+	rows, err := reads.QueryContext(ctx,
+		"SELECT id, product_id, old_price, new_price, changed_at FROM product_price_history WHERE product_id = $1 ORDER BY changed_at DESC, id DESC LIMIT $2",
+		productID, limit,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to fetch price history", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	history := []models.PriceChange{}
+	for rows.Next() {
+		var change models.PriceChange
+		var oldPrice sql.NullFloat64
+		if err := rows.Scan(&change.ID, &change.ProductID, &oldPrice, &change.NewPrice, &change.ChangedAt); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan price change", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if oldPrice.Valid {
+			change.OldPrice = &oldPrice.Float64
+		}
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to read price history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if len(history) == 0 {
+		var exists bool
+		if err := reads.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to check product", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "data": history})
+}
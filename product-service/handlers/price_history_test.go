@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestProductHandler_GetPriceHistory(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, product_id, old_price, new_price, changed_at FROM product_price_history WHERE product_id = \\$1").
+		WithArgs(3, defaultPriceHistoryLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "old_price", "new_price", "changed_at"}).
+			AddRow(2, 3, 19.99, 14.99, now).
+			AddRow(1, 3, nil, 19.99, now.Add(-time.Hour)))
+
+	req := httptest.NewRequest("GET", "/products/3/price-history", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp struct {
+		Data []struct {
+			OldPrice *float64 `json:"old_price"`
+			NewPrice float64  `json:"new_price"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("Expected 2 price changes, got %d", len(resp.Data))
+	}
+	if resp.Data[0].OldPrice == nil || *resp.Data[0].OldPrice != 19.99 || resp.Data[0].NewPrice != 14.99 {
+		t.Errorf("Unexpected latest change: %+v", resp.Data[0])
+	}
+	// The initial price has no previous value
+	if resp.Data[1].OldPrice != nil {
+		t.Errorf("Expected no old price for the initial price, got %v", *resp.Data[1].OldPrice)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetPriceHistory_NotFound(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT id, product_id, old_price, new_price, changed_at FROM product_price_history").
+		WithArgs(999, defaultPriceHistoryLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "old_price", "new_price", "changed_at"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	req := httptest.NewRequest("GET", "/products/999/price-history", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -1,44 +1,202 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"product-svc/cache"
 	"product-svc/circuitbreaker"
+	"product-svc/database"
+	"product-svc/kafka"
+	"product-svc/lock"
+	"product-svc/middleware"
 	"product-svc/models"
+	"product-svc/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
 )
 
 type ProductHandler struct {
 	db             *sql.DB
+	replica        *database.Replica
 	redisClient    *redis.Client
+	storage        *storage.Storage
+	lowStock       *kafka.LowStockPublisher
+	availability   *cache.AvailabilityCache
+	productEvents  *kafka.ProductEventPublisher
+	locker         *lock.Locker
 	logger         *zap.Logger
 	circuitBreaker *circuitbreaker.CircuitBreaker
+	productLoads   singleflight.Group
 }
 
-func NewProductHandler(db *sql.DB, redisClient *redis.Client, logger *zap.Logger) *ProductHandler {
+func NewProductHandler(db *sql.DB, replica *database.Replica, redisClient *redis.Client, storage *storage.Storage, lowStock *kafka.LowStockPublisher, availability *cache.AvailabilityCache, productEvents *kafka.ProductEventPublisher, locker *lock.Locker, logger *zap.Logger) *ProductHandler {
 	return &ProductHandler{
 		db:             db,
+		replica:        replica,
 		redisClient:    redisClient,
+		storage:        storage,
+		lowStock:       lowStock,
+		availability:   availability,
+		productEvents:  productEvents,
+		locker:         locker,
 		logger:         logger,
-		circuitBreaker: circuitbreaker.NewCircuitBreaker(5, 30*time.Second),
+		circuitBreaker: circuitbreaker.NewCircuitBreaker("product_db", 5, 30*time.Second, circuitbreaker.WithStateChange(circuitbreaker.LogStateChanges(logger))),
 	}
 }
 
+const (
+	defaultPageSize      = 20
+	defaultFeaturedLimit = 10
+	maxFeaturedLimit     = 50
+)
+
+// productColumns is the column list scanned by scanProduct
+const productColumns = "id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at"
+
+// attributeFilterPrefix marks attribute filters in the query string, e.g. ?attr.color=red
+const attributeFilterPrefix = "attr."
+
+// productSortColumns maps the accepted sort values to ORDER BY clauses.
+// A leading "-" sorts descending.
+var productSortColumns = map[string]string{
+	"id":          "id ASC",
+	"-id":         "id DESC",
+	"name":        "name ASC",
+	"-name":       "name DESC",
+	"price":       "price ASC",
+	"-price":      "price DESC",
+	"stock":       "stock ASC",
+	"-stock":      "stock DESC",
+	"created_at":  "created_at ASC",
+	"-created_at": "created_at DESC",
+}
+
 func (h *ProductHandler) GetProducts(c *gin.Context) {
+	// ?ids=1,2,3 fetches specific products instead of listing a page
+	if raw := c.Query("ids"); raw != "" {
+		h.getProductsByIDs(c, raw)
+		return
+	}
+
 	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetProducts")
 	defer span.End()
 
-	rows, err := h.db.QueryContext(ctx, "SELECT id, name, price, stock, created_at, updated_at FROM products ORDER BY id")
+	var query models.ListProductsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultPageSize
+	}
+	if query.Sort == "" {
+		query.Sort = "id"
+	}
+	orderBy, ok := productSortColumns[query.Sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+		return
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_price cannot be greater than max_price"})
+		return
+	}
+
+	// Build filter clause dynamically
+	conditions := []string{}
+	args := []interface{}{}
+	argPos := 1
+
+	if query.MinPrice != nil {
+		conditions = append(conditions, "price >= $"+strconv.Itoa(argPos))
+		args = append(args, *query.MinPrice)
+		argPos++
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "price <= $"+strconv.Itoa(argPos))
+		args = append(args, *query.MaxPrice)
+		argPos++
+	}
+	if query.InStock != nil {
+		if *query.InStock {
+			conditions = append(conditions, "stock > 0")
+		} else {
+			conditions = append(conditions, "stock = 0")
+		}
+	}
+	if tags := models.NormalizeTags(query.Tags); len(tags) > 0 {
+		// Products must carry every requested tag
+		conditions = append(conditions, "tags @> $"+strconv.Itoa(argPos))
+		args = append(args, pq.Array(tags))
+		argPos++
+	}
+
+	// Attribute filters compare the JSONB value as text, so attr.size=42 and attr.organic=true both work
+	attrKeys := []string{}
+	for param := range c.Request.URL.Query() {
+		if strings.HasPrefix(param, attributeFilterPrefix) {
+			attrKeys = append(attrKeys, param)
+		}
+	}
+	sort.Strings(attrKeys)
+	for _, param := range attrKeys {
+		key := strings.TrimPrefix(param, attributeFilterPrefix)
+		if !models.IsValidAttributeKey(key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute filter " + param})
+			return
+		}
+		conditions = append(conditions, "attributes ->> $"+strconv.Itoa(argPos)+" = $"+strconv.Itoa(argPos+1))
+		args = append(args, key, c.Query(param))
+		argPos += 2
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	span.SetAttributes(
+		attribute.Int("page", query.Page),
+		attribute.Int("limit", query.Limit),
+		attribute.String("sort", query.Sort),
+	)
+
+	reads := h.reader()
+
+	var total int
+	if err := reads.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+where, args...).Scan(&total); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to count products", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	listQuery := "SELECT " + productColumns + " FROM products" + where +
+		" ORDER BY " + orderBy +
+		" LIMIT $" + strconv.Itoa(argPos) + " OFFSET $" + strconv.Itoa(argPos+1)
+	listArgs := append(args, query.Limit, (query.Page-1)*query.Limit)
+
+	rows, err := reads.QueryContext(ctx, listQuery, listArgs...)
 	if err != nil {
 		span.RecordError(err)
 		h.logger.Error("Failed to fetch products", zap.Error(err))
@@ -47,10 +205,10 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	products := []models.Product{}
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			span.RecordError(err)
 			h.logger.Error("Failed to scan product", zap.Error(err))
 			continue
@@ -58,8 +216,31 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		products = append(products, p)
 	}
 
-	span.SetAttributes(attribute.Int("products.count", len(products)))
-	c.JSON(http.StatusOK, products)
+	h.attachImages(ctx, reads, products)
+	for i := range products {
+		h.signImages(ctx, products[i].Images)
+	}
+
+	totalPages := (total + query.Limit - 1) / query.Limit
+
+	span.SetAttributes(
+		attribute.Int("products.count", len(products)),
+		attribute.Int("products.total", total),
+	)
+	list := models.ProductListResponse{
+		Data:       products,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+	respond(c, http.StatusOK, list, func() (proto.Message, error) {
+		msg, err := productListToProto(list)
+		if err != nil {
+			h.logger.Error("Failed to encode product list", zap.Error(err))
+		}
+		return msg, err
+	})
 }
 
 func (h *ProductHandler) GetProduct(c *gin.Context) {
@@ -67,29 +248,52 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	defer span.End()
 
 	id := c.Param("id")
-	span.SetAttributes(attribute.String("product.id", id))
-
-	// Try to get from cache first
-	cachedData, err := cache.GetProduct(ctx, h.redisClient, id)
+	skipCache := middleware.SkipCache(c)
+	span.SetAttributes(attribute.String("product.id", id), attribute.Bool("cache.skipped", skipCache))
+
+	// Try to get from cache first, unless an admin asked to bypass it
+	var cachedData []byte
+	err := cache.ErrMiss
+	if !skipCache {
+		cachedData, err = cache.GetProduct(ctx, h.redisClient, id)
+	}
+	if errors.Is(err, cache.ErrNotFound) {
+		span.SetAttributes(attribute.Bool("cache.hit", true), attribute.Bool("cache.not_found", true))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		// A broken cache falls through to the database, but shouldn't go unnoticed
+		span.SetAttributes(attribute.Bool("cache.error", true))
+		h.logger.Warn("Product cache read failed", zap.String("product_id", id), zap.Error(err))
+	}
 	if err == nil {
 		var product models.Product
 		if err := json.Unmarshal(cachedData, &product); err == nil {
 			span.SetAttributes(attribute.Bool("cache.hit", true))
 			h.logger.Info("Cache hit", zap.String("product_id", id))
-			c.JSON(http.StatusOK, product)
+			h.signImages(ctx, product.Images)
+			setETag(c, product.Version)
+			respond(c, http.StatusOK, product, h.productMessage(product))
 			return
 		}
 	}
 	span.SetAttributes(attribute.Bool("cache.hit", false))
 
-	// Get from database with circuit breaker
-	var product models.Product
-	dbErr := h.circuitBreaker.Execute(ctx, func() error {
-		return h.db.QueryRowContext(ctx,
-			"SELECT id, name, price, stock, created_at, updated_at FROM products WHERE id = $1",
-			id,
-		).Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CreatedAt, &product.UpdatedAt)
-	})
+	var result interface{}
+	var dbErr error
+	if skipCache {
+		// Read Postgres directly and leave the cache as it is, so the two can be compared
+		result, dbErr = h.fetchProduct(ctx, id)
+	} else {
+		// Concurrent misses for the same product share one database load. The load runs
+		// detached from this request so one caller giving up doesn't fail the others.
+		var shared bool
+		result, dbErr, shared = h.productLoads.Do(id, func() (interface{}, error) {
+			return h.loadProduct(context.WithoutCancel(ctx), id)
+		})
+		span.SetAttributes(attribute.Bool("cache.load_shared", shared))
+	}
 
 	if dbErr != nil {
 		if dbErr == circuitbreaker.ErrCircuitOpen {
@@ -107,10 +311,252 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 		return
 	}
 
-	// Cache the product for 5 minutes
-	cache.SetProduct(ctx, h.redisClient, id, product, 5*time.Minute)
+	// Waiters get the same value; copy the images before signing URLs into them
+	product := result.(models.Product)
+	product.Images = append([]models.ProductImage(nil), product.Images...)
+
+	h.signImages(ctx, product.Images)
+	setETag(c, product.Version)
+	respond(c, http.StatusOK, product, h.productMessage(product))
+}
+
+// GetProductBySKU looks a product up by its external catalog identifier. Lookups by SKU
+// bypass the product cache, which is keyed by ID.
+func (h *ProductHandler) GetProductBySKU(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetProductBySKU")
+	defer span.End()
+
+	sku := models.NormalizeSKU(c.Param("sku"))
+	span.SetAttributes(attribute.String("product.sku", sku))
+
+	reads := h.reader()
+
+	var product models.Product
+	err := h.circuitBreaker.Execute(ctx, func() error {
+		return scanProduct(reads.QueryRowContext(ctx,
+			"SELECT "+productColumns+" FROM products WHERE sku = $1",
+			sku,
+		), &product)
+	})
+	if err != nil {
+		if err == circuitbreaker.ErrCircuitOpen {
+			span.SetAttributes(attribute.String("circuit.state", "open"))
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		span.RecordError(err)
+		h.logger.Error("Failed to fetch product by SKU", zap.String("sku", sku), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	products := []models.Product{product}
+	h.attachImages(ctx, reads, products)
+	product = products[0]
+
+	h.signImages(ctx, product.Images)
+	setETag(c, product.Version)
+	respond(c, http.StatusOK, product, h.productMessage(product))
+}
+
+// loadProduct reads a product from the database and populates the cache
+func (h *ProductHandler) loadProduct(ctx context.Context, id string) (models.Product, error) {
+	product, err := h.fetchProduct(ctx, id)
+	if err == sql.ErrNoRows && cache.NotFoundTTL > 0 {
+		if err := cache.SetProductNotFound(ctx, h.redisClient, id, cache.NotFoundTTL); err != nil {
+			h.logger.Warn("Product cache write failed", zap.String("product_id", id), zap.Error(err))
+		}
+	}
+	if err != nil {
+		return product, err
+	}
+
+	if err := cache.SetProduct(ctx, h.redisClient, id, product.Version, product, cache.ProductTTL); err != nil {
+		h.logger.Warn("Product cache write failed", zap.String("product_id", id), zap.Error(err))
+	}
+	return product, nil
+}
+
+// fetchProduct reads a product and its image metadata from the database, through the
+// circuit breaker. It reads the primary: a lagging replica would put stale data in the
+// cache for a whole TTL.
+func (h *ProductHandler) fetchProduct(ctx context.Context, id string) (models.Product, error) {
+	var product models.Product
+	err := h.circuitBreaker.Execute(ctx, func() error {
+		return scanProduct(h.db.QueryRowContext(ctx,
+			"SELECT "+productColumns+" FROM products WHERE id = $1",
+			id,
+		), &product)
+	})
+	if err != nil {
+		return product, err
+	}
+
+	// Image metadata is cached with the product; signed URLs are added per response
+	products := []models.Product{product}
+	h.attachImages(ctx, h.db, products)
+	return products[0], nil
+}
+
+// reader returns the pool for read-only queries: the read replica when one is
+// configured and healthy, otherwise the primary
+func (h *ProductHandler) reader() *sql.DB {
+	if h.replica == nil {
+		return h.db
+	}
+	return h.replica.Reader()
+}
+
+// invalidateProduct brings the cached product in line with a committed write, using the
+// configured cache.Invalidation strategy. Failures are retried; one that still fails
+// leaves a stale entry until its TTL expires, so it is logged rather than ignored.
+func invalidateProduct(ctx context.Context, db *sql.DB, rdb *redis.Client, logger *zap.Logger, id string) {
+	if cache.Invalidation == cache.StrategyWriteThrough {
+		product, err := readProduct(ctx, db, id)
+		if err == nil {
+			err = cache.WriteThroughProduct(ctx, rdb, id, product.Version, product)
+			if err == nil {
+				return
+			}
+		}
+		if err != sql.ErrNoRows {
+			logger.Warn("Product cache write-through failed, deleting instead", zap.String("product_id", id), zap.Error(err))
+		}
+	}
+
+	if err := cache.InvalidateProduct(ctx, rdb, id); err != nil {
+		logger.Warn("Product cache invalidation failed", zap.String("product_id", id), zap.Error(err))
+	}
+	// A deleted product has nothing to write through, so it gets the second delete too
+	if cache.Invalidation != cache.StrategyDelete {
+		cache.ScheduleInvalidation(ctx, rdb, id, cache.DoubleDeleteDelay, logger)
+	}
+}
+
+// readProduct reads a product and its image metadata from the primary for a
+// write-through. It skips the circuit breaker: the write it follows just succeeded.
+func readProduct(ctx context.Context, db *sql.DB, id string) (models.Product, error) {
+	var product models.Product
+	if err := scanProduct(db.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id = $1",
+		id,
+	), &product); err != nil {
+		return product, err
+	}
+
+	images, err := loadImages(ctx, db, []int64{int64(product.ID)})
+	if err != nil {
+		return product, err
+	}
+	product.Images = images[product.ID]
+	return product, nil
+}
+
+// GetFeaturedProducts returns products ordered by the merchandising ranking kept in Redis.
+// Until the first recompute, or while Redis is down, it falls back to the newest in-stock
+// products with a zero score.
+func (h *ProductHandler) GetFeaturedProducts(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetFeaturedProducts")
+	defer span.End()
+
+	limit := defaultFeaturedLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxFeaturedLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxFeaturedLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	ranked, err := cache.GetFeaturedProducts(ctx, h.redisClient, limit)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("Failed to read featured ranking, falling back to newest products", zap.Error(err))
+	}
+	if len(ranked) == 0 {
+		span.SetAttributes(attribute.Bool("featured.fallback", true))
+		h.getFeaturedFallback(ctx, c, limit)
+		return
+	}
+
+	featured := []models.FeaturedProduct{}
+
+	ids := make([]int64, 0, len(ranked))
+	for _, z := range ranked {
+		id, err := strconv.ParseInt(fmt.Sprint(z.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	rows, err := h.reader().QueryContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id = ANY($1)",
+		pq.Array(ids),
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to fetch featured products", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	byID := make(map[int]models.Product, len(ids))
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan product", zap.Error(err))
+			continue
+		}
+		byID[p.ID] = p
+	}
+
+	// Preserve ranking order; products deleted since the last recompute are skipped
+	for _, z := range ranked {
+		id, err := strconv.Atoi(fmt.Sprint(z.Member))
+		if err != nil {
+			continue
+		}
+		if p, ok := byID[id]; ok {
+			featured = append(featured, models.FeaturedProduct{Product: p, Score: z.Score})
+		}
+	}
 
-	c.JSON(http.StatusOK, product)
+	span.SetAttributes(attribute.Int("products.count", len(featured)))
+	c.JSON(http.StatusOK, gin.H{"data": featured})
+}
+
+// getFeaturedFallback answers GetFeaturedProducts with the newest in-stock products when
+// there is no ranking to read
+func (h *ProductHandler) getFeaturedFallback(ctx context.Context, c *gin.Context, limit int) {
+	rows, err := h.reader().QueryContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE stock > 0 ORDER BY created_at DESC, id DESC LIMIT $1",
+		limit,
+	)
+	if err != nil {
+		h.logger.Error("Failed to fetch fallback featured products", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	featured := []models.FeaturedProduct{}
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			h.logger.Error("Failed to scan product", zap.Error(err))
+			continue
+		}
+		featured = append(featured, models.FeaturedProduct{Product: p})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": featured})
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
@@ -123,13 +569,33 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	if err := req.Attributes.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Products without a SKU store NULL, which the unique index ignores
+	var sku interface{}
+	if normalized := models.NormalizeSKU(req.SKU); normalized != "" {
+		sku = normalized
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
 	var product models.Product
-	err := h.db.QueryRowContext(ctx,
-		"INSERT INTO products (name, price, stock) VALUES ($1, $2, $3) RETURNING id, name, price, stock, created_at, updated_at",
-		req.Name, req.Price, req.Stock,
-	).Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CreatedAt, &product.UpdatedAt)
+	err := scanProduct(h.db.QueryRowContext(ctx,
+		"INSERT INTO products (name, sku, price, currency, stock, cost, tags, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+productColumns,
+		req.Name, sku, req.Price, currency, req.Stock, req.Cost, pq.Array(models.NormalizeTags(req.Tags)), req.Attributes,
+	), &product)
 
 	if err != nil {
+		if isDuplicateSKU(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A product with this SKU already exists"})
+			return
+		}
 		span.RecordError(err)
 		h.logger.Error("Failed to create product", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
@@ -137,8 +603,13 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	}
 
 	span.SetAttributes(attribute.Int("product.id", product.ID))
+
+	// The new ID may have been looked up, and cached as not found, before it existed
+	invalidateProduct(ctx, h.db, h.redisClient, h.logger, strconv.Itoa(product.ID))
+
 	h.logger.Info("Product created", zap.Int("product_id", product.ID))
-	c.JSON(http.StatusCreated, product)
+	setETag(c, product.Version)
+	respond(c, http.StatusCreated, product, h.productMessage(product))
 }
 
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
@@ -153,9 +624,28 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.Attributes.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// If-Match carries the version from a previous ETag, as an alternative to "version"
+	ifMatch, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ifMatch != nil {
+		if req.Version != nil && *req.Version != *ifMatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match and version refer to different versions"})
+			return
+		}
+		req.Version = ifMatch
+	}
 
 	// Build update query dynamically
-	query := "UPDATE products SET updated_at = CURRENT_TIMESTAMP"
+	// Every write bumps the version so concurrent writers can detect each other
+	query := "UPDATE products SET updated_at = CURRENT_TIMESTAMP, version = version + 1"
 	args := []interface{}{}
 	argPos := 1
 
@@ -164,28 +654,62 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		args = append(args, req.Name)
 		argPos++
 	}
+	if sku := models.NormalizeSKU(req.SKU); sku != "" {
+		query += ", sku = $" + strconv.Itoa(argPos)
+		args = append(args, sku)
+		argPos++
+	}
 	if req.Price > 0 {
 		query += ", price = $" + strconv.Itoa(argPos)
 		args = append(args, req.Price)
 		argPos++
 	}
-	if req.Stock >= 0 {
+	if req.Currency != "" {
+		query += ", currency = $" + strconv.Itoa(argPos)
+		args = append(args, req.Currency)
+		argPos++
+	}
+	if req.Stock != nil {
 		query += ", stock = $" + strconv.Itoa(argPos)
-		args = append(args, req.Stock)
+		args = append(args, *req.Stock)
+		argPos++
+	}
+	if req.Cost > 0 {
+		query += ", cost = $" + strconv.Itoa(argPos)
+		args = append(args, req.Cost)
+		argPos++
+	}
+	if req.Tags != nil {
+		query += ", tags = $" + strconv.Itoa(argPos)
+		args = append(args, pq.Array(models.NormalizeTags(req.Tags)))
+		argPos++
+	}
+	if req.Attributes != nil {
+		query += ", attributes = $" + strconv.Itoa(argPos)
+		args = append(args, req.Attributes)
 		argPos++
 	}
 
-	query += " WHERE id = $" + strconv.Itoa(argPos) + " RETURNING id, name, price, stock, created_at, updated_at"
+	query += " WHERE id = $" + strconv.Itoa(argPos)
 	args = append(args, id)
+	argPos++
+	if req.Version != nil {
+		query += " AND version = $" + strconv.Itoa(argPos)
+		args = append(args, *req.Version)
+		argPos++
+	}
+	query += " RETURNING " + productColumns
 
 	var product models.Product
-	err := h.db.QueryRowContext(ctx, query, args...).Scan(
-		&product.ID, &product.Name, &product.Price, &product.Stock, &product.CreatedAt, &product.UpdatedAt,
-	)
+	err = scanProduct(h.db.QueryRowContext(ctx, query, args...), &product)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			h.respondUpdateMiss(ctx, c, id, req.Version != nil)
+			return
+		}
+		if isDuplicateSKU(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A product with this SKU already exists"})
 			return
 		}
 		span.RecordError(err)
@@ -195,10 +719,73 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	}
 
 	// Invalidate cache
-	cache.DeleteProduct(ctx, h.redisClient, id)
+	invalidateProduct(ctx, h.db, h.redisClient, h.logger, id)
+	h.availability.Invalidate(product.ID)
+	h.productEvents.Updated(ctx, product.ID)
+
+	// The previous stock isn't read back, so an explicit low stock value always alerts
+	if req.Stock != nil {
+		h.lowStock.StockChanged(ctx, product.ID, product.Name, -1, product.Stock)
+	}
 
 	h.logger.Info("Product updated", zap.String("product_id", id))
-	c.JSON(http.StatusOK, product)
+	setETag(c, product.Version)
+	respond(c, http.StatusOK, product, h.productMessage(product))
+}
+
+// productMessage returns the protobuf encoder for a single product response
+func (h *ProductHandler) productMessage(p models.Product) func() (proto.Message, error) {
+	return func() (proto.Message, error) {
+		msg, err := productToProto(p)
+		if err != nil {
+			h.logger.Error("Failed to encode product", zap.Int("product_id", p.ID), zap.Error(err))
+		}
+		return msg, err
+	}
+}
+
+// respondUpdateMiss tells a missing product apart from a stale version after an UPDATE matched no rows
+func (h *ProductHandler) respondUpdateMiss(ctx context.Context, c *gin.Context, id string, versioned bool) {
+	if versioned {
+		var version int
+		err := h.db.QueryRowContext(ctx, "SELECT version FROM products WHERE id = $1", id).Scan(&version)
+		if err == nil {
+			middleware.RecordStockConflict("update")
+			setETag(c, version)
+			c.JSON(http.StatusConflict, gin.H{
+				"error":           "Product was modified by another request",
+				"current_version": version,
+			})
+			return
+		}
+		if err != sql.ErrNoRows {
+			h.logger.Error("Failed to load product version", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+}
+
+// setETag exposes the product version so clients can send it back in If-Match
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// parseIfMatch reads the version from an If-Match header set to an ETag returned
+// earlier. It returns nil when the header is absent or "*" (any current version).
+func parseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return nil, errors.New("If-Match must be a single product ETag")
+	}
+	return &version, nil
 }
 
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
@@ -223,8 +810,36 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	}
 
 	// Invalidate cache
-	cache.DeleteProduct(ctx, h.redisClient, id)
+	invalidateProduct(ctx, h.db, h.redisClient, h.logger, id)
+	if productID, err := strconv.Atoi(id); err == nil {
+		h.availability.Invalidate(productID)
+		h.productEvents.Deleted(ctx, productID)
+	}
 
 	h.logger.Info("Product deleted", zap.String("product_id", id))
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// isDuplicateSKU reports whether err is a unique violation on the SKU index
+func isDuplicateSKU(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_products_sku"
+}
+
+// scanProduct scans a row selected with productColumns
+func scanProduct(row rowScanner, p *models.Product) error {
+	var sku sql.NullString
+	if err := row.Scan(&p.ID, &p.Name, &sku, &p.Price, &p.Currency, &p.Stock, &p.Version, pq.Array(&p.Tags), &p.Attributes, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	p.SKU = sku.String
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	return nil
+}
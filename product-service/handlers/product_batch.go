@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"product-svc/cache"
+	"product-svc/circuitbreaker"
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const maxBatchIDs = 100
+
+// getProductsByIDs serves GET /products?ids=1,2,3. Each ID is looked up in the cache
+// first; the misses are read from the database in one query and cached for next time.
+func (h *ProductHandler) getProductsByIDs(c *gin.Context, raw string) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetProductsByIDs")
+	defer span.End()
+
+	ids, err := parseProductIDs(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Int("products.requested", len(ids)))
+
+	cached, err := cache.GetProducts(ctx, h.redisClient, ids)
+	if err != nil {
+		// A broken cache falls through to the database for every ID
+		span.SetAttributes(attribute.Bool("cache.error", true))
+		h.logger.Warn("Product cache batch read failed", zap.Error(err))
+		cached = map[int][]byte{}
+	}
+
+	byID := make(map[int]models.Product, len(ids))
+	var misses []int
+	for _, id := range ids {
+		data, ok := cached[id]
+		if !ok {
+			misses = append(misses, id)
+			continue
+		}
+		if data == nil {
+			// Cached as not found
+			continue
+		}
+		var product models.Product
+		if err := json.Unmarshal(data, &product); err != nil {
+			misses = append(misses, id)
+			continue
+		}
+		byID[id] = product
+	}
+	span.SetAttributes(attribute.Int("cache.hits", len(ids)-len(misses)))
+
+	if len(misses) > 0 {
+		loaded, err := h.loadProducts(ctx, misses)
+		if err != nil {
+			if err == circuitbreaker.ErrCircuitOpen {
+				span.SetAttributes(attribute.String("circuit.state", "open"))
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
+				return
+			}
+			span.RecordError(err)
+			h.logger.Error("Failed to fetch products", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		for id, product := range loaded {
+			byID[id] = product
+		}
+	}
+
+	// Respond in request order, listing IDs that don't exist separately
+	response := models.ProductBatchResponse{Data: []models.Product{}, MissingIDs: []int{}}
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok {
+			response.MissingIDs = append(response.MissingIDs, id)
+			continue
+		}
+		h.signImages(ctx, product.Images)
+		response.Data = append(response.Data, product)
+	}
+
+	span.SetAttributes(attribute.Int("products.missing", len(response.MissingIDs)))
+	c.JSON(http.StatusOK, response)
+}
+
+// loadProducts reads the given products from the primary, like fetchProduct, and
+// populates the cache. IDs that don't exist are cached as not found.
+func (h *ProductHandler) loadProducts(ctx context.Context, ids []int) (map[int]models.Product, error) {
+	products := []models.Product{}
+	err := h.circuitBreaker.Execute(ctx, func() error {
+		rows, err := h.db.QueryContext(ctx,
+			"SELECT "+productColumns+" FROM products WHERE id = ANY($1)",
+			pq.Array(ids),
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p models.Product
+			if err := scanProduct(rows, &p); err != nil {
+				return err
+			}
+			products = append(products, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.attachImages(ctx, h.db, products)
+
+	byID := make(map[int]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		if err := cache.SetProduct(ctx, h.redisClient, strconv.Itoa(p.ID), p.Version, p, cache.ProductTTL); err != nil {
+			h.logger.Warn("Product cache write failed", zap.Int("product_id", p.ID), zap.Error(err))
+		}
+	}
+	if cache.NotFoundTTL > 0 {
+		for _, id := range ids {
+			if _, ok := byID[id]; ok {
+				continue
+			}
+			if err := cache.SetProductNotFound(ctx, h.redisClient, strconv.Itoa(id), cache.NotFoundTTL); err != nil {
+				h.logger.Warn("Product cache write failed", zap.Int("product_id", id), zap.Error(err))
+			}
+		}
+	}
+	return byID, nil
+}
+
+// parseProductIDs parses a comma-separated ID list, dropping duplicates but keeping
+// the order of first appearance
+func parseProductIDs(raw string) ([]int, error) {
+	parts := strings.Split(raw, ",")
+	ids := make([]int, 0, len(parts))
+	seen := make(map[int]bool, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id < 1 {
+			return nil, errInvalidBatchIDs
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > maxBatchIDs {
+		return nil, errTooManyBatchIDs
+	}
+	return ids, nil
+}
+
+var (
+	errInvalidBatchIDs = errors.New("ids must be a comma-separated list of positive integers")
+	errTooManyBatchIDs = errors.New("ids cannot list more than " + strconv.Itoa(maxBatchIDs) + " products")
+)
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// maxImageSize caps a single upload at 10 MiB
+const maxImageSize = 10 << 20
+
+// allowedImageTypes maps accepted content types to the extension used in the object key
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// UploadProductImage streams a multipart "image" field to object storage and records its metadata.
+// The part is piped to storage as it is read instead of being spooled to a temp file first.
+func (h *ProductHandler) UploadProductImage(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "UploadProductImage")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("product.id", productID))
+
+	part, err := imagePart(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart field 'image' is required"})
+		return
+	}
+	defer part.Close()
+
+	contentType := part.Header.Get("Content-Type")
+	ext, ok := allowedImageTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported image type"})
+		return
+	}
+
+	var exists bool
+	if err := h.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to look up product", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	objectKey, err := newImageKey(productID, ext)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to generate image key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Stream the part straight to storage; read one byte past the limit to detect oversized uploads
+	body := &countingReader{r: io.LimitReader(part, maxImageSize+1)}
+	if err := h.storage.Upload(ctx, objectKey, body, -1, contentType); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to upload image", zap.String("object_key", objectKey), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if body.n > maxImageSize {
+		h.deleteImageObject(ctx, objectKey)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image exceeds 10MB limit"})
+		return
+	}
+
+	var image models.ProductImage
+	err = h.db.QueryRowContext(ctx,
+		"INSERT INTO product_images (product_id, object_key, content_type, size_bytes) VALUES ($1, $2, $3, $4) RETURNING id, product_id, object_key, content_type, size_bytes, created_at",
+		productID, objectKey, contentType, body.n,
+	).Scan(&image.ID, &image.ProductID, &image.ObjectKey, &image.ContentType, &image.SizeBytes, &image.CreatedAt)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to save image metadata", zap.Error(err))
+		// Don't leave an orphaned object behind
+		h.deleteImageObject(ctx, objectKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Invalidate cache so the next read includes the new image
+	invalidateProduct(ctx, h.db, h.redisClient, h.logger, strconv.Itoa(productID))
+	h.productEvents.Updated(ctx, productID)
+
+	images := []models.ProductImage{image}
+	h.signImages(ctx, images)
+
+	span.SetAttributes(attribute.Int("image.id", image.ID))
+	h.logger.Info("Product image uploaded",
+		zap.Int("product_id", productID),
+		zap.Int("image_id", image.ID),
+		zap.Int64("size_bytes", image.SizeBytes),
+	)
+	c.JSON(http.StatusCreated, images[0])
+}
+
+// loadImages fetches image metadata for the given products, keyed by product ID
+func loadImages(ctx context.Context, db *sql.DB, productIDs []int64) (map[int][]models.ProductImage, error) {
+	images := make(map[int][]models.ProductImage)
+	if len(productIDs) == 0 {
+		return images, nil
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images WHERE product_id = ANY($1) ORDER BY id",
+		pq.Array(productIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var img models.ProductImage
+		if err := rows.Scan(&img.ID, &img.ProductID, &img.ObjectKey, &img.ContentType, &img.SizeBytes, &img.CreatedAt); err != nil {
+			return nil, err
+		}
+		images[img.ProductID] = append(images[img.ProductID], img)
+	}
+	return images, rows.Err()
+}
+
+// attachImages loads image metadata from db onto products in place. Failures are
+// logged and leave the products without images rather than failing the request.
+func (h *ProductHandler) attachImages(ctx context.Context, db *sql.DB, products []models.Product) {
+	ids := make([]int64, len(products))
+	for i, p := range products {
+		ids[i] = int64(p.ID)
+	}
+
+	images, err := loadImages(ctx, db, ids)
+	if err != nil {
+		h.logger.Warn("Failed to load product images", zap.Error(err))
+		return
+	}
+
+	for i := range products {
+		products[i].Images = images[products[i].ID]
+	}
+}
+
+// signImages fills in short-lived signed URLs. Signing is local, so it is done
+// per response and never cached.
+func (h *ProductHandler) signImages(ctx context.Context, images []models.ProductImage) {
+	if h.storage == nil {
+		return
+	}
+	for i := range images {
+		url, err := h.storage.SignedURL(ctx, images[i].ObjectKey)
+		if err != nil {
+			h.logger.Warn("Failed to sign image URL", zap.String("object_key", images[i].ObjectKey), zap.Error(err))
+			continue
+		}
+		images[i].URL = url
+	}
+}
+
+func (h *ProductHandler) deleteImageObject(ctx context.Context, objectKey string) {
+	if err := h.storage.Delete(ctx, objectKey); err != nil {
+		h.logger.Warn("Failed to delete orphaned image", zap.String("object_key", objectKey), zap.Error(err))
+	}
+}
+
+// imagePart returns the "image" file part of a multipart request without buffering it
+func imagePart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newImageKey(productID int, ext string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate image key: %w", err)
+	}
+	return path.Join("products", strconv.Itoa(productID), hex.EncodeToString(buf)+ext), nil
+}
@@ -2,20 +2,30 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"product-svc/cache"
 	"product-svc/models"
+	product "product-svc/proto"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"google.golang.org/protobuf/proto"
 )
 
 func setupProductTest(t *testing.T) (*ProductHandler, sqlmock.Sqlmock, *gin.Engine) {
@@ -30,15 +40,28 @@ func setupProductTest(t *testing.T) (*ProductHandler, sqlmock.Sqlmock, *gin.Engi
 	})
 
 	logger := zaptest.NewLogger(t, zaptest.Level(zap.InfoLevel))
-	handler := NewProductHandler(db, redisClient, logger)
+	handler := NewProductHandler(db, nil, redisClient, nil, nil, nil, nil, nil, logger)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	router.GET("/products", handler.GetProducts)
 	router.GET("/products/:id", handler.GetProduct)
+	router.GET("/products/sku/:sku", handler.GetProductBySKU)
+	router.GET("/products/:id/price-history", handler.GetPriceHistory)
 	router.POST("/products", handler.CreateProduct)
 	router.PUT("/products/:id", handler.UpdateProduct)
 	router.DELETE("/products/:id", handler.DeleteProduct)
+	router.POST("/products/:id/images", handler.UploadProductImage)
+	router.POST("/products/:id/stock-adjustments", handler.AdjustStock)
+	router.GET("/products/:id/reviews", handler.GetReviews)
+	router.POST("/products/:id/reviews", func(c *gin.Context) {
+		// Stands in for AuthMiddleware, which sets the user_id claim
+		if id := c.GetHeader("X-Test-User-ID"); id != "" {
+			userID, _ := strconv.Atoi(id)
+			c.Set("user_id", float64(userID))
+		}
+		handler.CreateReview(c)
+	})
 
 	return handler, mock, router
 }
@@ -47,14 +70,23 @@ func TestProductHandler_GetProducts_Success(t *testing.T) {
 	handler, mock, router := setupProductTest(t)
 	defer handler.db.Close()
 
-	// Mock: Get all products
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(1, "Product 1", 10.99, 100, time.Now(), time.Now()).
-		AddRow(2, "Product 2", 20.99, 50, time.Now(), time.Now())
+	// Mock: Count products
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	// Mock: Get first page of products
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{}", []byte("{}"), time.Now(), time.Now()).
+		AddRow(2, "Product 2", nil, 20.99, "USD", 50, 1, "{}", []byte("{}"), time.Now(), time.Now())
 
-	mock.ExpectQuery("SELECT id, name, price, stock, created_at, updated_at FROM products ORDER BY id").
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products ORDER BY id ASC LIMIT \\$1 OFFSET \\$2").
+		WithArgs(20, 0).
 		WillReturnRows(rows)
 
+	// Mock: Load images for the page
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
 	req := httptest.NewRequest("GET", "/products", nil)
 	w := httptest.NewRecorder()
 
@@ -64,23 +96,148 @@ func TestProductHandler_GetProducts_Success(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
+	var resp models.ProductListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Total != 2 || len(resp.Data) != 2 || resp.TotalPages != 1 {
+		t.Errorf("Unexpected pagination envelope: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProducts_FiltersAndSort(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Mock: Count filtered products
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products WHERE price >= \\$1 AND price <= \\$2 AND stock > 0").
+		WithArgs(10.0, 50.0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(15))
+
+	// Mock: Get second page of filtered products
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(7, "Product 7", nil, 12.50, "USD", 3, 1, "{}", []byte("{}"), time.Now(), time.Now())
+
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE price >= \\$1 AND price <= \\$2 AND stock > 0 ORDER BY price DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(10.0, 50.0, 10, 10).
+		WillReturnRows(rows)
+
+	// Mock: Load images for the page
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/products?page=2&limit=10&sort=-price&min_price=10&max_price=50&in_stock=true", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp models.ProductListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Page != 2 || resp.Limit != 10 || resp.Total != 15 || resp.TotalPages != 2 {
+		t.Errorf("Unexpected pagination envelope: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProducts_InvalidSort(t *testing.T) {
+	handler, _, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/products?sort=password", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestProductHandler_GetProducts_TagAndAttributeFilters(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Mock: Count products matching the tag and attribute filters
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products WHERE tags @> \\$1 AND attributes ->> \\$2 = \\$3").
+		WithArgs(sqlmock.AnyArg(), "color", "red").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(3, "Red Shirt", nil, 19.99, "USD", 5, 1, "{sale}", []byte(`{"color":"red"}`), time.Now(), time.Now())
+
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE tags @> \\$1 AND attributes ->> \\$2 = \\$3 ORDER BY id ASC LIMIT \\$4 OFFSET \\$5").
+		WithArgs(sqlmock.AnyArg(), "color", "red", 20, 0).
+		WillReturnRows(rows)
+
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/products?tag=Sale&attr.color=red", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp models.ProductListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || len(resp.Data[0].Tags) != 1 || resp.Data[0].Attributes["color"] != "red" {
+		t.Errorf("Unexpected products: %+v", resp.Data)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Database expectations were not met: %v", err)
 	}
 }
 
+func TestProductHandler_GetProducts_InvalidAttributeFilter(t *testing.T) {
+	handler, _, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/products?attr.color%3B%20DROP=red", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestProductHandler_GetProduct_Success(t *testing.T) {
 	handler, mock, router := setupProductTest(t)
 	defer handler.db.Close()
 
 	// Mock: Get product by ID
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(1, "Product 1", 10.99, 100, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{}", []byte("{}"), time.Now(), time.Now())
 
-	mock.ExpectQuery("SELECT id, name, price, stock, created_at, updated_at FROM products WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = \\$1").
 		WithArgs("1").
 		WillReturnRows(rows)
 
+	// Mock: Load product images
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}).
+			AddRow(1, 1, "products/1/abc.png", "image/png", 1024, time.Now()))
+
 	req := httptest.NewRequest("GET", "/products/1", nil)
 	w := httptest.NewRecorder()
 
@@ -95,12 +252,90 @@ func TestProductHandler_GetProduct_Success(t *testing.T) {
 	}
 }
 
+func TestProductHandler_GetProduct_Protobuf(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = \\$1").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{blue}", []byte(`{"color":"blue"}`), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/products/1", nil)
+	req.Header.Set("Accept", "application/x-protobuf, application/json;q=0.5")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("Expected protobuf content type, got %q", ct)
+	}
+
+	var resp product.GetProductResponse
+	if err := proto.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode protobuf response: %v", err)
+	}
+	if resp.GetId() != 1 || resp.GetName() != "Product 1" || resp.GetStock() != 100 {
+		t.Errorf("Unexpected product: %+v", &resp)
+	}
+	if color := resp.GetAttributes().GetFields()["color"].GetStringValue(); color != "blue" {
+		t.Errorf("Expected color attribute blue, got %q", color)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProduct_ConcurrentMissesShareOneLoad(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Only one load is expected; the delay keeps it in flight while the other requests miss the cache
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = \\$1").
+		WithArgs("1").
+		WillDelayFor(500 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{}", []byte("{}"), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	const requests = 5
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Request %d: expected status %d, got %d", i, http.StatusOK, code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
 func TestProductHandler_GetProduct_NotFound(t *testing.T) {
 	handler, mock, router := setupProductTest(t)
 	defer handler.db.Close()
 
 	// Mock: Product not found
-	mock.ExpectQuery("SELECT id, name, price, stock, created_at, updated_at FROM products WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = \\$1").
 		WithArgs("999").
 		WillReturnError(sql.ErrNoRows)
 
@@ -123,11 +358,11 @@ func TestProductHandler_CreateProduct_Success(t *testing.T) {
 	defer handler.db.Close()
 
 	// Mock: Insert product
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(1, "New Product", 15.99, 200, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(1, "New Product", nil, 15.99, "USD", 200, 1, "{}", []byte("{}"), time.Now(), time.Now())
 
 	mock.ExpectQuery("INSERT INTO products").
-		WithArgs("New Product", 15.99, 200).
+		WithArgs("New Product", nil, 15.99, "USD", 200, 0.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(rows)
 
 	reqBody := models.CreateProductRequest{
@@ -152,22 +387,106 @@ func TestProductHandler_CreateProduct_Success(t *testing.T) {
 	}
 }
 
+func TestProductHandler_CreateProduct_UnknownCurrency(t *testing.T) {
+	handler, _, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	body, _ := json.Marshal(models.CreateProductRequest{
+		Name:     "New Product",
+		Price:    15.99,
+		Currency: "XYZ",
+	})
+	req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestProductHandler_CreateProduct_DuplicateSKU(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// SKUs are stored trimmed and upper-cased
+	mock.ExpectQuery("INSERT INTO products").
+		WithArgs("New Product", "ABC-123", 15.99, "USD", 200, 0.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_products_sku"})
+
+	body, _ := json.Marshal(models.CreateProductRequest{
+		Name:  "New Product",
+		SKU:   " abc-123 ",
+		Price: 15.99,
+		Stock: 200,
+	})
+	req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProductBySKU(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE sku = \\$1").
+		WithArgs("ABC-123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(4, "Catalog Item", "ABC-123", 9.99, "USD", 12, 1, "{}", []byte("{}"), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, product_id, object_key").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/products/sku/abc-123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var got models.Product
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if got.ID != 4 || got.SKU != "ABC-123" {
+		t.Errorf("Unexpected product: %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
 func TestProductHandler_UpdateProduct_Success(t *testing.T) {
 	handler, mock, router := setupProductTest(t)
 	defer handler.db.Close()
 
 	// Mock: Update product
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(1, "Updated Product", 25.99, 150, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(1, "Updated Product", nil, 25.99, "USD", 150, 1, "{}", []byte("{}"), time.Now(), time.Now())
 
 	mock.ExpectQuery("UPDATE products SET").
 		WithArgs("Updated Product", 25.99, 150, "1").
 		WillReturnRows(rows)
 
+	stock := 150
 	reqBody := models.UpdateProductRequest{
 		Name:  "Updated Product",
 		Price: 25.99,
-		Stock: 150,
+		Stock: &stock,
 	}
 
 	body, _ := json.Marshal(reqBody)
@@ -186,6 +505,129 @@ func TestProductHandler_UpdateProduct_Success(t *testing.T) {
 	}
 }
 
+func TestProductHandler_UpdateProduct_WriteThrough(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	previous := cache.Invalidation
+	cache.Invalidation = cache.StrategyWriteThrough
+	defer func() { cache.Invalidation = previous }()
+
+	mock.ExpectQuery("UPDATE products SET").
+		WithArgs("Updated Product", 25.99, "1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Updated Product", nil, 25.99, "USD", 150, 2, "{}", []byte("{}"), time.Now(), time.Now()))
+
+	// Mock: The committed row and its images are read back from the primary for the cache
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = \\$1").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Updated Product", nil, 25.99, "USD", 150, 2, "{}", []byte("{}"), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	body, _ := json.Marshal(models.UpdateProductRequest{Name: "Updated Product", Price: 25.99})
+	req := httptest.NewRequest("PUT", "/products/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_UpdateProduct_VersionConflict(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Mock: Update guarded by a stale version matches no rows
+	mock.ExpectQuery("UPDATE products SET updated_at = CURRENT_TIMESTAMP, version = version \\+ 1, stock = \\$1 WHERE id = \\$2 AND version = \\$3").
+		WithArgs(5, "1", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}))
+
+	// Mock: Product still exists at a newer version
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+
+	req := httptest.NewRequest("PUT", "/products/1", bytes.NewBufferString(`{"stock": 5, "version": 3}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"4"` {
+		t.Errorf("Expected the current version as ETag, got %q", etag)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_UpdateProduct_IfMatch(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Mock: Update guarded by the version from the If-Match ETag
+	mock.ExpectQuery("UPDATE products SET updated_at = CURRENT_TIMESTAMP, version = version \\+ 1, stock = \\$1 WHERE id = \\$2 AND version = \\$3").
+		WithArgs(5, "1", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Product", nil, 9.99, "USD", 5, 4, "{}", []byte("{}"), time.Now(), time.Now()))
+
+	req := httptest.NewRequest("PUT", "/products/1", bytes.NewBufferString(`{"stock": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"4"` {
+		t.Errorf("Expected ETag %q for the new version, got %q", `"4"`, etag)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_UpdateProduct_IfMatchInvalid(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	for name, tc := range map[string]struct{ ifMatch, body string }{
+		"not a version":       {`"abc"`, `{"stock": 5}`},
+		"disagrees with body": {`"3"`, `{"stock": 5, "version": 2}`},
+	} {
+		req := httptest.NewRequest("PUT", "/products/1", bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", tc.ifMatch)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
 func TestProductHandler_DeleteProduct_Success(t *testing.T) {
 	handler, mock, router := setupProductTest(t)
 	defer handler.db.Close()
@@ -231,3 +673,199 @@ func TestProductHandler_DeleteProduct_NotFound(t *testing.T) {
 		t.Errorf("Database expectations were not met: %v", err)
 	}
 }
+
+func TestProductHandler_UploadProductImage_MissingFile(t *testing.T) {
+	handler, _, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("POST", "/products/1/images", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestProductHandler_UploadProductImage_ProductNotFound(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Mock: Product does not exist
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="image"; filename="photo.png"`)
+	header.Set("Content-Type", "image/png")
+	part, _ := writer.CreatePart(header)
+	part.Write([]byte("fake png bytes"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/products/999/images", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProducts_ByIDs(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	// Redis isn't running in tests, so every ID is read from the database in one query
+	rows := sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+		AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{}", []byte("{}"), time.Now(), time.Now()).
+		AddRow(3, "Product 3", nil, 30.99, "USD", 5, 1, "{}", []byte("{}"), time.Now(), time.Now())
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]int{3, 1, 7})).
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, product_id, object_key, content_type, size_bytes, created_at FROM product_images").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "object_key", "content_type", "size_bytes", "created_at"}))
+
+	req := httptest.NewRequest("GET", "/products?ids=3,1,7,3", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp models.ProductBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].ID != 3 || resp.Data[1].ID != 1 {
+		t.Errorf("Expected products 3 and 1 in request order, got %+v", resp.Data)
+	}
+	if len(resp.MissingIDs) != 1 || resp.MissingIDs[0] != 7 {
+		t.Errorf("Expected missing_ids [7], got %v", resp.MissingIDs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetProducts_ByIDsInvalid(t *testing.T) {
+	handler, _, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	tooMany := "1"
+	for i := 2; i <= maxBatchIDs+1; i++ {
+		tooMany += "," + strconv.Itoa(i)
+	}
+
+	for _, ids := range []string{"1,abc", "0", "1,,2", tooMany} {
+		req := httptest.NewRequest("GET", "/products?ids="+ids, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ids=%.20s: expected status %d, got %d", ids, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func setupFeaturedTest(t *testing.T, rdb *redis.Client) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	handler := NewProductHandler(db, nil, rdb, nil, nil, nil, nil, nil, zaptest.NewLogger(t))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/products/featured", handler.GetFeaturedProducts)
+	return mock, router
+}
+
+func getFeatured(t *testing.T, router *gin.Engine) []models.FeaturedProduct {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/products/featured?limit=3", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data []models.FeaturedProduct `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.Data
+}
+
+func TestProductHandler_GetFeaturedProducts_RankingOrder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	mock, router := setupFeaturedTest(t, rdb)
+
+	if err := cache.ReplaceFeaturedProducts(context.Background(), rdb, map[int]float64{1: 0.2, 2: 0.9, 3: 0.5}); err != nil {
+		t.Fatalf("Failed to seed ranking: %v", err)
+	}
+	// Product 3 was deleted since the recompute; the database returns the rest in id order
+	mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE id = ANY\\(\\$1\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+			AddRow(1, "Product 1", nil, 10.99, "USD", 100, 1, "{}", []byte("{}"), time.Now(), time.Now()).
+			AddRow(2, "Product 2", nil, 20.99, "USD", 50, 1, "{}", []byte("{}"), time.Now(), time.Now()))
+
+	featured := getFeatured(t, router)
+	if len(featured) != 2 || featured[0].ID != 2 || featured[1].ID != 1 {
+		t.Fatalf("Expected products 2 then 1, got %+v", featured)
+	}
+	if featured[0].Score != 0.9 {
+		t.Errorf("Expected the ranking score 0.9, got %v", featured[0].Score)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetFeaturedProducts_Fallback(t *testing.T) {
+	mr := miniredis.RunT(t)
+	empty := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer empty.Close()
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer unreachable.Close()
+
+	for name, rdb := range map[string]*redis.Client{
+		"no ranking yet":    empty,
+		"redis unreachable": unreachable,
+	} {
+		t.Run(name, func(t *testing.T) {
+			mock, router := setupFeaturedTest(t, rdb)
+			mock.ExpectQuery("SELECT id, name, sku, price, currency, stock, version, tags, attributes, created_at, updated_at FROM products WHERE stock > 0 ORDER BY created_at DESC, id DESC LIMIT \\$1").
+				WithArgs(3).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sku", "price", "currency", "stock", "version", "tags", "attributes", "created_at", "updated_at"}).
+					AddRow(5, "Newest", nil, 10.99, "USD", 3, 1, "{}", []byte("{}"), time.Now(), time.Now()).
+					AddRow(4, "Older", nil, 20.99, "USD", 8, 1, "{}", []byte("{}"), time.Now(), time.Now()))
+
+			featured := getFeatured(t, router)
+			if len(featured) != 2 || featured[0].ID != 5 || featured[1].ID != 4 || featured[0].Score != 0 {
+				t.Errorf("Expected the newest in-stock products unscored, got %+v", featured)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
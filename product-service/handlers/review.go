@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-svc/cache"
+	"product-svc/middleware"
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const maxReviewPageSize = 100
+
+// CreateReview stores the authenticated user's review of a product. Each user can
+// review a product once.
+func (h *ProductHandler) CreateReview(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "CreateReview")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return
+	}
+
+	var req models.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("product.id", productID),
+		attribute.Int("user.id", userID),
+		attribute.Int("review.rating", req.Rating),
+	)
+
+	review := models.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Comment:   req.Comment,
+	}
+	err = h.db.QueryRowContext(ctx,
+		"INSERT INTO product_reviews (product_id, user_id, rating, comment) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		review.ProductID, review.UserID, review.Rating, review.Comment,
+	).Scan(&review.ID, &review.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this product"})
+		default:
+			span.RecordError(err)
+			h.logger.Error("Failed to create review", zap.Int("product_id", productID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if err := cache.DeleteRatingSummary(ctx, h.redisClient, productID); err != nil {
+		h.logger.Warn("Rating cache invalidation failed", zap.Int("product_id", productID), zap.Error(err))
+	}
+	h.productEvents.ReviewCreated(ctx, review.ID, productID, userID, review.Rating)
+
+	h.logger.Info("Review created",
+		zap.Int64("review_id", review.ID),
+		zap.Int("product_id", productID),
+		zap.Int("user_id", userID),
+		zap.Int("rating", review.Rating),
+	)
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetReviews returns a page of a product's reviews, newest first, with its average
+// rating and review count
+func (h *ProductHandler) GetReviews(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "GetReviews")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("product.id", productID))
+
+	page, limit := 1, defaultPageSize
+	if raw := c.Query("page"); raw != "" {
+		if page, err = strconv.Atoi(raw); err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxReviewPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	summary, err := h.ratingSummary(ctx, productID, middleware.SkipCache(c))
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to load rating summary", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if summary.ReviewCount == 0 {
+		var exists bool
+		if err := h.reader().QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to check product", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+	}
+
+	reviews := []models.Review{}
+	if summary.ReviewCount > 0 {
+		rows, err := h.reader().QueryContext(ctx,
+			"SELECT id, product_id, user_id, rating, comment, created_at FROM product_reviews WHERE product_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3",
+			productID, limit, (page-1)*limit,
+		)
+		if err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to fetch reviews", zap.Int("product_id", productID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r models.Review
+			if err := rows.Scan(&r.ID, &r.ProductID, &r.UserID, &r.Rating, &r.Comment, &r.CreatedAt); err != nil {
+				span.RecordError(err)
+				h.logger.Error("Failed to scan review", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+			reviews = append(reviews, r)
+		}
+		if err := rows.Err(); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to read reviews", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.ReviewListResponse{
+		ProductID:     productID,
+		RatingSummary: summary,
+		Data:          reviews,
+		Page:          page,
+		Limit:         limit,
+		TotalPages:    (summary.ReviewCount + limit - 1) / limit,
+	})
+}
+
+// ratingSummary reads the product's average rating from Redis, computing and caching
+// it on a miss. A cache failure falls back to the database. With skipCache it reads the
+// database and leaves the cache alone. Like product loads, it reads the primary so a
+// lagging replica can't put a stale summary in the cache.
+func (h *ProductHandler) ratingSummary(ctx context.Context, productID int, skipCache bool) (models.RatingSummary, error) {
+	var summary models.RatingSummary
+
+	if !skipCache {
+		data, err := cache.GetRatingSummary(ctx, h.redisClient, productID)
+		if err == nil {
+			if err := json.Unmarshal(data, &summary); err == nil {
+				return summary, nil
+			}
+		} else if !errors.Is(err, cache.ErrMiss) {
+			h.logger.Warn("Rating cache read failed, falling back to database", zap.Int("product_id", productID), zap.Error(err))
+		}
+	}
+
+	err := h.db.QueryRowContext(ctx,
+		"SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM product_reviews WHERE product_id = $1",
+		productID,
+	).Scan(&summary.AverageRating, &summary.ReviewCount)
+	if err != nil || skipCache {
+		return summary, err
+	}
+
+	if err := cache.SetRatingSummary(ctx, h.redisClient, productID, summary, cache.RatingTTL); err != nil {
+		h.logger.Warn("Rating cache write failed", zap.Int("product_id", productID), zap.Error(err))
+	}
+	return summary, nil
+}
+
+// authenticatedUserID reads the user ID set by AuthMiddleware. JWT numeric claims decode as float64.
+func authenticatedUserID(c *gin.Context) (int, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := value.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(id), true
+}
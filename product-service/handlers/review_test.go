@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"product-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func postReview(t *testing.T, router http.Handler, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("X-Test-User-ID", userID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProductHandler_CreateReview_Success(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("INSERT INTO product_reviews \\(product_id, user_id, rating, comment\\)").
+		WithArgs(1, 7, 4, "Solid keyboard").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+
+	w := postReview(t, router, "/products/1/reviews", "7", models.CreateReviewRequest{Rating: 4, Comment: "Solid keyboard"})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var review models.Review
+	if err := json.Unmarshal(w.Body.Bytes(), &review); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if review.ID != 3 || review.UserID != 7 || review.Rating != 4 {
+		t.Errorf("Unexpected review: %+v", review)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_CreateReview_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbErr  error
+		status int
+	}{
+		{"duplicate", &pq.Error{Code: "23505"}, http.StatusConflict},
+		{"unknown product", &pq.Error{Code: "23503"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, router := setupProductTest(t)
+			defer handler.db.Close()
+
+			mock.ExpectQuery("INSERT INTO product_reviews").
+				WithArgs(1, 7, 5, "").
+				WillReturnError(tt.dbErr)
+
+			w := postReview(t, router, "/products/1/reviews", "7", models.CreateReviewRequest{Rating: 5})
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestProductHandler_CreateReview_InvalidRequest(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	if w := postReview(t, router, "/products/1/reviews", "7", map[string]int{"rating": 6}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for rating 6, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := postReview(t, router, "/products/1/reviews", "", models.CreateReviewRequest{Rating: 3}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a user, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
+
+func TestProductHandler_GetReviews(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(AVG\\(rating\\), 0\\), COUNT\\(\\*\\) FROM product_reviews WHERE product_id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"avg", "count"}).AddRow(4.5, 2))
+	mock.ExpectQuery("SELECT id, product_id, user_id, rating, comment, created_at FROM product_reviews WHERE product_id = \\$1").
+		WithArgs(1, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "user_id", "rating", "comment", "created_at"}).
+			AddRow(2, 1, 8, 5, "Great", time.Now()).
+			AddRow(1, 1, 7, 4, "", time.Now().Add(-time.Hour)))
+
+	req := httptest.NewRequest("GET", "/products/1/reviews", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp models.ReviewListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.AverageRating != 4.5 || resp.ReviewCount != 2 || len(resp.Data) != 2 || resp.TotalPages != 1 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_GetReviews_NotFound(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(AVG\\(rating\\), 0\\), COUNT\\(\\*\\) FROM product_reviews").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"avg", "count"}).AddRow(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	req := httptest.NewRequest("GET", "/products/999/reviews", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-svc/lock"
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// AdjustStock applies a stock delta and records it in the stock_adjustments ledger in
+// the same transaction. Unlike setting stock through PUT, concurrent adjustments add up
+// instead of overwriting each other, and each one says why and who made it.
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "AdjustStock")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req models.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("product.id", productID),
+		attribute.Int("stock.delta", req.Delta),
+		attribute.String("stock.reason", req.Reason),
+	)
+
+	err = h.locker.Guard(ctx, stockLock, strconv.Itoa(productID), stockLockTTL, stockLockWait, func(ctx context.Context) error {
+		h.adjustStock(ctx, c, productID, req)
+		return nil
+	})
+	if errors.Is(err, lock.ErrNotAcquired) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Product stock is busy, retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
+	}
+}
+
+// adjustStock runs the adjustment under the product's stock lock and writes the response
+func (h *ProductHandler) adjustStock(ctx context.Context, c *gin.Context, productID int, req models.StockAdjustmentRequest) {
+	span := trace.SpanFromContext(ctx)
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var name string
+	var stock int
+	err = tx.QueryRowContext(ctx,
+		"UPDATE products SET stock = stock + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND stock + $1 >= 0 RETURNING name, stock",
+		req.Delta, productID,
+	).Scan(&name, &stock)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.respondAdjustmentMiss(c, tx, productID)
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to adjust stock", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	adjustment := models.StockAdjustment{
+		ProductID:  productID,
+		Delta:      req.Delta,
+		Reason:     req.Reason,
+		Note:       req.Note,
+		StockAfter: stock,
+		AdjustedBy: c.GetString("email"),
+	}
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO stock_adjustments (product_id, delta, reason, note, stock_after, adjusted_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		adjustment.ProductID, adjustment.Delta, adjustment.Reason, adjustment.Note, adjustment.StockAfter, adjustment.AdjustedBy,
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to record stock adjustment", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to commit stock adjustment", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	invalidateProduct(ctx, h.db, h.redisClient, h.logger, strconv.Itoa(productID))
+	h.availability.Invalidate(productID)
+	h.productEvents.Updated(ctx, productID)
+	h.lowStock.StockChanged(ctx, productID, name, stock-req.Delta, stock)
+
+	h.logger.Info("Stock adjusted",
+		zap.Int("product_id", productID),
+		zap.Int("delta", req.Delta),
+		zap.String("reason", req.Reason),
+		zap.Int("stock", stock),
+		zap.String("adjusted_by", adjustment.AdjustedBy),
+	)
+	c.JSON(http.StatusCreated, adjustment)
+}
+
+// respondAdjustmentMiss tells a missing product apart from an adjustment that would
+// take stock below zero
+func (h *ProductHandler) respondAdjustmentMiss(c *gin.Context, tx *sql.Tx, productID int) {
+	var stock int
+	err := tx.QueryRowContext(c.Request.Context(), "SELECT stock FROM products WHERE id = $1", productID).Scan(&stock)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to read stock", zap.Int("product_id", productID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Adjustment would take stock below zero", "stock": stock})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"product-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func postStockAdjustment(t *testing.T, router http.Handler, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProductHandler_AdjustStock_Success(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1, version = version \\+ 1.*WHERE id = \\$2 AND stock \\+ \\$1 >= 0 RETURNING name, stock").
+		WithArgs(-3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("Laptop", 7))
+	mock.ExpectQuery("INSERT INTO stock_adjustments").
+		WithArgs(1, -3, "damaged", "water damage in aisle 4", 7, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(12, time.Now()))
+	mock.ExpectCommit()
+
+	w := postStockAdjustment(t, router, "/products/1/stock-adjustments", models.StockAdjustmentRequest{
+		Delta:  -3,
+		Reason: "damaged",
+		Note:   "water damage in aisle 4",
+	})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var adjustment models.StockAdjustment
+	if err := json.Unmarshal(w.Body.Bytes(), &adjustment); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if adjustment.ID != 12 || adjustment.StockAfter != 7 || adjustment.Delta != -3 {
+		t.Errorf("Unexpected adjustment: %+v", adjustment)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_AdjustStock_BelowZero(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").
+		WithArgs(-5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}))
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+	mock.ExpectRollback()
+
+	w := postStockAdjustment(t, router, "/products/1/stock-adjustments", models.StockAdjustmentRequest{
+		Delta:  -5,
+		Reason: "lost",
+	})
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["stock"] != float64(2) {
+		t.Errorf("Expected current stock 2 in response, got %v", resp["stock"])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_AdjustStock_NotFound(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").
+		WithArgs(4, 999).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}))
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+	mock.ExpectRollback()
+
+	w := postStockAdjustment(t, router, "/products/999/stock-adjustments", models.StockAdjustmentRequest{
+		Delta:  4,
+		Reason: "restock",
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_AdjustStock_InvalidRequest(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+
+	cases := []map[string]interface{}{
+		{"delta": 0, "reason": "restock"},
+		{"delta": 2, "reason": "because"},
+		{"delta": 2},
+	}
+	for _, body := range cases {
+		w := postStockAdjustment(t, router, "/products/1/stock-adjustments", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %v, got %d", http.StatusBadRequest, body, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// variantColumns is the column list scanned by scanVariant
+const variantColumns = "id, product_id, sku, size, color, price, stock, version, created_at, updated_at"
+
+// ListVariants returns a product's variants in id order
+func (h *ProductHandler) ListVariants(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "ListVariants")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("product.id", productID))
+
+	rows, err := h.reader().QueryContext(ctx,
+		"SELECT "+variantColumns+" FROM product_variants WHERE product_id = $1 ORDER BY id",
+		productID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to fetch variants", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	variants := []models.ProductVariant{}
+	for rows.Next() {
+		var v models.ProductVariant
+		if err := scanVariant(rows, &v); err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to scan variant", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		variants = append(variants, v)
+	}
+
+	// A product without variants is only worth a 404 if the product itself is missing
+	if len(variants) == 0 {
+		exists, err := h.productExists(ctx, productID)
+		if err != nil {
+			span.RecordError(err)
+			h.logger.Error("Failed to look up product", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+	}
+
+	span.SetAttributes(attribute.Int("variants.count", len(variants)))
+	c.JSON(http.StatusOK, gin.H{"data": variants})
+}
+
+// CreateVariant adds a variant to a product. SKUs are unique across all variants, and a
+// product can't have two variants with the same size and color.
+func (h *ProductHandler) CreateVariant(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "CreateVariant")
+	defer span.End()
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	span.SetAttributes(attribute.Int("product.id", productID))
+
+	var req models.CreateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var variant models.ProductVariant
+	err = scanVariant(h.db.QueryRowContext(ctx,
+		"INSERT INTO product_variants (product_id, sku, size, color, price, stock) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+variantColumns,
+		productID, req.SKU, req.Size, req.Color, req.Price, req.Stock,
+	), &variant)
+	if err != nil {
+		switch pqErrorCode(err) {
+		case "23503":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case "23505":
+			c.JSON(http.StatusConflict, gin.H{"error": "A variant with this SKU, or this size and color, already exists"})
+		default:
+			span.RecordError(err)
+			h.logger.Error("Failed to create variant", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	span.SetAttributes(attribute.Int("variant.id", variant.ID))
+	h.logger.Info("Variant created", zap.Int("product_id", productID), zap.Int("variant_id", variant.ID))
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant changes a variant's size, color, price or stock
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "UpdateVariant")
+	defer span.End()
+
+	productID, variantID, ok := variantParams(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("product.id", productID),
+		attribute.Int("variant.id", variantID),
+	)
+
+	var req models.UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every write bumps the version, which stock reservations lock on
+	query := "UPDATE product_variants SET updated_at = CURRENT_TIMESTAMP, version = version + 1"
+	args := []interface{}{}
+	argPos := 1
+
+	if req.Size != nil {
+		query += ", size = $" + strconv.Itoa(argPos)
+		args = append(args, *req.Size)
+		argPos++
+	}
+	if req.Color != nil {
+		query += ", color = $" + strconv.Itoa(argPos)
+		args = append(args, *req.Color)
+		argPos++
+	}
+	if req.Price > 0 {
+		query += ", price = $" + strconv.Itoa(argPos)
+		args = append(args, req.Price)
+		argPos++
+	}
+	if req.Stock != nil {
+		query += ", stock = $" + strconv.Itoa(argPos)
+		args = append(args, *req.Stock)
+		argPos++
+	}
+
+	query += " WHERE id = $" + strconv.Itoa(argPos) + " AND product_id = $" + strconv.Itoa(argPos+1) + " RETURNING " + variantColumns
+	args = append(args, variantID, productID)
+
+	var variant models.ProductVariant
+	err := scanVariant(h.db.QueryRowContext(ctx, query, args...), &variant)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+			return
+		}
+		if pqErrorCode(err) == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A variant with this size and color already exists"})
+			return
+		}
+		span.RecordError(err)
+		h.logger.Error("Failed to update variant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Variant updated", zap.Int("product_id", productID), zap.Int("variant_id", variantID))
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteVariant removes a variant. Orders that already bought it keep their variant ID.
+func (h *ProductHandler) DeleteVariant(c *gin.Context) {
+	ctx, span := otel.Tracer("product-service").Start(c.Request.Context(), "DeleteVariant")
+	defer span.End()
+
+	productID, variantID, ok := variantParams(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("product.id", productID),
+		attribute.Int("variant.id", variantID),
+	)
+
+	result, err := h.db.ExecContext(ctx,
+		"DELETE FROM product_variants WHERE id = $1 AND product_id = $2",
+		variantID, productID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Error("Failed to delete variant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	h.logger.Info("Variant deleted", zap.Int("product_id", productID), zap.Int("variant_id", variantID))
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
+}
+
+func (h *ProductHandler) productExists(ctx context.Context, productID int) (bool, error) {
+	var exists bool
+	err := h.reader().QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists)
+	return exists, err
+}
+
+// variantParams parses the product and variant IDs from the path, answering 400 if either is invalid
+func variantParams(c *gin.Context) (int, int, bool) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return 0, 0, false
+	}
+	variantID, err := strconv.Atoi(c.Param("variant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return 0, 0, false
+	}
+	return productID, variantID, true
+}
+
+// pqErrorCode returns the SQLSTATE of a Postgres error, or "" for any other error
+func pqErrorCode(err error) pq.ErrorCode {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code
+	}
+	return ""
+}
+
+// scanVariant scans a row selected with variantColumns
+func scanVariant(row rowScanner, v *models.ProductVariant) error {
+	return row.Scan(&v.ID, &v.ProductID, &v.SKU, &v.Size, &v.Color, &v.Price, &v.Stock, &v.Version, &v.CreatedAt, &v.UpdatedAt)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"product-svc/cache"
+	"product-svc/models"
+	product "product-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var variantRowColumns = []string{"id", "product_id", "sku", "size", "color", "price", "stock", "version", "created_at", "updated_at"}
+
+func TestProductHandler_ListVariants(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+	router.GET("/products/:id/variants", handler.ListVariants)
+
+	mock.ExpectQuery("SELECT id, product_id, sku, size, color, price, stock, version, created_at, updated_at FROM product_variants WHERE product_id = \\$1 ORDER BY id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns).
+			AddRow(1, 1, "TEE-S-RED", "S", "red", 19.99, 4, 1, time.Now(), time.Now()).
+			AddRow(2, 1, "TEE-M-RED", "M", "red", 21.99, 0, 1, time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT id, product_id, sku, size, color, price, stock, version, created_at, updated_at FROM product_variants").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/products/1/variants", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got struct {
+		Data []models.ProductVariant `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(got.Data) != 2 || got.Data[1].SKU != "TEE-M-RED" || got.Data[1].Price != 21.99 {
+		t.Errorf("Unexpected variants: %+v", got.Data)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/products/999/variants", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing product, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_CreateVariant(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+	router.POST("/products/:id/variants", handler.CreateVariant)
+
+	mock.ExpectQuery("INSERT INTO product_variants").
+		WithArgs(1, "TEE-S-RED", "S", "red", 19.99, 4).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns).
+			AddRow(7, 1, "TEE-S-RED", "S", "red", 19.99, 4, 1, time.Now(), time.Now()))
+	mock.ExpectQuery("INSERT INTO product_variants").
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectQuery("INSERT INTO product_variants").
+		WillReturnError(&pq.Error{Code: "23503"})
+
+	for _, want := range []int{http.StatusCreated, http.StatusConflict, http.StatusNotFound} {
+		body, _ := json.Marshal(models.CreateVariantRequest{SKU: "TEE-S-RED", Size: "S", Color: "red", Price: 19.99, Stock: 4})
+		req := httptest.NewRequest("POST", "/products/1/variants", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Expected status %d, got %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductHandler_UpdateVariant(t *testing.T) {
+	handler, mock, router := setupProductTest(t)
+	defer handler.db.Close()
+	router.PUT("/products/:id/variants/:variant_id", handler.UpdateVariant)
+
+	mock.ExpectQuery("UPDATE product_variants SET updated_at = CURRENT_TIMESTAMP, version = version \\+ 1, price = \\$1, stock = \\$2 WHERE id = \\$3 AND product_id = \\$4").
+		WithArgs(24.99, 10, 7, 1).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns).
+			AddRow(7, 1, "TEE-S-RED", "S", "red", 24.99, 10, 2, time.Now(), time.Now()))
+	mock.ExpectQuery("UPDATE product_variants").
+		WithArgs(24.99, 10, 7, 2).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns))
+
+	// The second product doesn't own variant 7
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/products/1/variants/7", http.StatusOK},
+		{"/products/2/variants/7", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("PUT", tt.path, bytes.NewBufferString(`{"price": 24.99, "stock": 10}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.want, w.Code)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_CheckAvailability_Variant(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("SELECT stock FROM product_variants WHERE id = \\$1 AND product_id = \\$2").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+
+	resp, err := service.CheckAvailability(context.Background(), &product.CheckAvailabilityRequest{ProductId: 1, VariantId: 7, Quantity: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetAvailable() || resp.GetStock() != 2 {
+		t.Errorf("Expected 3 of the variant's 2 units to be unavailable, got %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_CheckAvailability_CachesVariantsSeparately(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+	service.availability = cache.NewAvailabilityCache()
+
+	// The product and its variant each take one query; invalidating the product drops both
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+	mock.ExpectQuery("SELECT stock FROM product_variants WHERE id = \\$1 AND product_id = \\$2").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+	mock.ExpectQuery("SELECT stock FROM product_variants WHERE id = \\$1 AND product_id = \\$2").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+
+	check := func(variantID int32) int32 {
+		resp, err := service.CheckAvailability(context.Background(), &product.CheckAvailabilityRequest{ProductId: 1, VariantId: variantID, Quantity: 1})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return resp.GetStock()
+	}
+
+	for i := 0; i < 2; i++ {
+		if stock := check(0); stock != 10 {
+			t.Errorf("Expected the product's stock 10, got %d", stock)
+		}
+		if stock := check(7); stock != 2 {
+			t.Errorf("Expected the variant's stock 2, got %d", stock)
+		}
+	}
+
+	service.availability.Invalidate(1)
+	if stock := check(7); stock != 1 {
+		t.Errorf("Expected fresh variant stock after invalidation, got %d", stock)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_GetVariant_NotFound(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("SELECT id, product_id, sku, size, color, price, stock, version, created_at, updated_at FROM product_variants WHERE id = \\$1").
+		WithArgs(int32(9)).
+		WillReturnRows(sqlmock.NewRows(variantRowColumns))
+
+	_, err := service.GetVariant(context.Background(), &product.GetVariantRequest{VariantId: 9})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestProductService_ReserveStock_Variant(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs("res-v", int32(1), int32(2), int32(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT stock, version FROM product_variants WHERE id = \\$1 AND product_id = \\$2").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "version"}).AddRow(5, 3))
+	mock.ExpectQuery("UPDATE product_variants SET stock = stock - \\$1, version = version \\+ 1, updated_at = CURRENT_TIMESTAMP WHERE id = \\$2 AND version = \\$3 AND stock >= \\$1").
+		WithArgs(2, int32(7), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(3))
+	mock.ExpectCommit()
+
+	resp, err := service.ReserveStock(context.Background(), &product.ReserveStockRequest{
+		ReservationId: "res-v",
+		ProductId:     1,
+		VariantId:     7,
+		Quantity:      2,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetReserved() || resp.GetStock() != 3 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestProductService_ReleaseStock_Variant(t *testing.T) {
+	service, mock := setupStockTest(t)
+	defer service.db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1").
+		WithArgs("released", "res-v", "reserved").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(1, 7, 2))
+	mock.ExpectExec("UPDATE product_variants SET stock = stock \\+ \\$1").
+		WithArgs(2, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp, err := service.ReleaseStock(context.Background(), &product.ReleaseStockRequest{ReservationId: "res-v"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.GetReleased() {
+		t.Errorf("Expected the variant reservation released")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
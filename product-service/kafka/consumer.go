@@ -0,0 +1,244 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"product-svc/startup"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// orderCreatedEvent is the subset of the order-service event used for sales analytics
+type orderCreatedEvent struct {
+	EventType string `json:"event_type"`
+	OrderID   int    `json:"order_id"`
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// stockReleaseEvent is order-service's request to give a failed or cancelled order's
+// reserved stock back
+type stockReleaseEvent struct {
+	OrderID       int    `json:"order_id"`
+	ReservationID string `json:"reservation_id"`
+}
+
+// ReleaseFunc gives a stock reservation's units back and reports whether it still held
+// any; handlers.ProductService.Release
+type ReleaseFunc func(ctx context.Context, reservationID string) (bool, error)
+
+// InitConsumer joins the sales consumer group. With replay it joins a fresh, throwaway
+// group instead, which starts from the oldest retained event without moving the live
+// group's committed offsets.
+func InitConsumer(logger *zap.Logger, replay bool) (sarama.ConsumerGroup, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+	groupID := getEnv("KAFKA_CONSUMER_GROUP", "product-service")
+	if replay {
+		groupID = fmt.Sprintf("%s-replay-%d", groupID, time.Now().Unix())
+	}
+
+	var consumerGroup sarama.ConsumerGroup
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumerGroup, err = sarama.NewConsumerGroup(brokers, groupID, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
+	}
+
+	logger.Info("Kafka consumer group initialized",
+		zap.Strings("brokers", brokers),
+		zap.String("group_id", groupID),
+	)
+
+	return consumerGroup, nil
+}
+
+// StartConsumer records order_created events as product sales used by the featured
+// ranking, and gives the stock of stock_release events back through release
+func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, db *sql.DB, release ReleaseFunc, logger *zap.Logger) error {
+	topics := []string{getEnv("KAFKA_ORDERS_TOPIC", "orders")}
+	handler := &salesConsumerGroupHandler{
+		db:      db,
+		release: release,
+		logger:  logger,
+	}
+
+	logger.Info("Kafka consumer loop started", zap.Strings("topics", topics))
+
+	// Handle errors in a separate goroutine
+	go func() {
+		for err := range consumerGroup.Errors() {
+			logger.Error("Kafka consumer group error", zap.Error(err))
+		}
+	}()
+
+	for {
+		if err := consumerGroup.Consume(ctx, topics, handler); err != nil {
+			return fmt.Errorf("failed to consume topic: %w", err)
+		}
+
+		if ctx.Err() != nil {
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		}
+	}
+}
+
+type salesConsumerGroupHandler struct {
+	db      *sql.DB
+	release ReleaseFunc
+	logger  *zap.Logger
+}
+
+func (h *salesConsumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (h *salesConsumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (h *salesConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		if err := handleMessage(message, h.db, h.release, h.logger); err != nil {
+			h.logger.Error("Failed to handle message", zap.Error(err))
+		} else {
+			session.MarkMessage(message, "")
+		}
+	}
+
+	return nil
+}
+
+func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, release ReleaseFunc, logger *zap.Logger) error {
+	// Extract trace context from Kafka message headers
+	var propagator propagation.TextMapPropagator = otel.GetTextMapPropagator()
+	carrier := saramaHeaderCarrierConsumer(message.Headers)
+	ctx := propagator.Extract(context.Background(), carrier)
+
+	header, err := readEventHeader(message.Value)
+	if err != nil {
+		return err
+	}
+	if header.EventType != "order_created" && header.EventType != "stock_release" {
+		// Only order creations count towards sales, and only releases move stock
+		return nil
+	}
+
+	// Skipped rather than misread; replay it once this service reads the version
+	if err := header.checkVersion(); err != nil {
+		logger.Error("Skipping order event", zap.String("event_id", header.EventID), zap.Int("order_id", header.OrderID), zap.Error(err))
+		return nil
+	}
+	if header.EventType == "stock_release" {
+		return handleStockRelease(ctx, message.Value, release, logger)
+	}
+
+	var event orderCreatedEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	var tracer trace.Tracer = otel.Tracer("product-service")
+	ctx, span := tracer.Start(ctx, "RecordProductSale")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("order.id", event.OrderID),
+		attribute.Int("product.id", event.ProductID),
+		attribute.Int("order.quantity", event.Quantity),
+	)
+
+	// order_id is unique so redelivered events are not counted twice
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO product_sales (order_id, product_id, quantity) VALUES ($1, $2, $3) ON CONFLICT (order_id) DO NOTHING",
+		event.OrderID, event.ProductID, event.Quantity,
+	)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to record product sale: %w", err)
+	}
+
+	logger.Debug("Product sale recorded",
+		zap.Int("order_id", event.OrderID),
+		zap.Int("product_id", event.ProductID),
+		zap.Int("quantity", event.Quantity),
+	)
+
+	return nil
+}
+
+// handleStockRelease gives a failed or cancelled order's reserved stock back. Release is
+// idempotent, so a redelivered event, or one for a reservation order-service already
+// released over gRPC, changes nothing.
+func handleStockRelease(ctx context.Context, value []byte, release ReleaseFunc, logger *zap.Logger) error {
+	var event stockReleaseEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	ctx, span := otel.Tracer("product-service").Start(ctx, "ReleaseStockEvent")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("order.id", event.OrderID),
+		attribute.String("reservation.id", event.ReservationID),
+	)
+
+	released, err := release(ctx, event.ReservationID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to release stock reservation: %w", err)
+	}
+	span.SetAttributes(attribute.Bool("released", released))
+
+	logger.Debug("Stock release handled",
+		zap.Int("order_id", event.OrderID),
+		zap.String("reservation_id", event.ReservationID),
+		zap.Bool("released", released),
+	)
+	return nil
+}
+
+// saramaHeaderCarrierConsumer implements the TextMapCarrier interface for Kafka headers (for consumer)
+type saramaHeaderCarrierConsumer []*sarama.RecordHeader
+
+func (c saramaHeaderCarrierConsumer) Get(key string) string {
+	for _, h := range c {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c saramaHeaderCarrierConsumer) Set(key, value string) {
+	// Not needed for extraction
+}
+
+func (c saramaHeaderCarrierConsumer) Keys() []string {
+	keys := make([]string, len(c))
+	for i, h := range c {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHandleMessage_StockReleaseReleasesReservation(t *testing.T) {
+	var released []string
+	release := func(_ context.Context, reservationID string) (bool, error) {
+		released = append(released, reservationID)
+		return len(released) == 1, nil
+	}
+
+	message := &sarama.ConsumerMessage{Value: []byte(`{"event_type":"stock_release","event_id":"stock-release-7","order_id":7,"reservation_id":"res-7"}`)}
+	// A redelivery finds the reservation already released and changes nothing
+	for range 2 {
+		if err := handleMessage(message, nil, release, zaptest.NewLogger(t)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if len(released) != 2 || released[0] != "res-7" {
+		t.Errorf("Expected res-7 released on each delivery, got %v", released)
+	}
+}
+
+func TestHandleMessage_StockReleaseFailureIsReturned(t *testing.T) {
+	release := func(context.Context, string) (bool, error) { return false, errors.New("db down") }
+
+	message := &sarama.ConsumerMessage{Value: []byte(`{"event_type":"stock_release","order_id":7,"reservation_id":"res-7"}`)}
+	if err := handleMessage(message, nil, release, zaptest.NewLogger(t)); err == nil {
+		t.Fatal("Expected the failed release to be returned so the offset isn't marked")
+	}
+}
+
+func TestHandleMessage_SkipsNewerStockReleaseVersion(t *testing.T) {
+	release := func(context.Context, string) (bool, error) {
+		t.Fatal("Expected a newer event version not to be released")
+		return false, nil
+	}
+
+	message := &sarama.ConsumerMessage{Value: []byte(`{"event_type":"stock_release","event_version":2,"order_id":7,"reservation_id":"res-7"}`)}
+	if err := handleMessage(message, nil, release, zaptest.NewLogger(t)); err != nil {
+		t.Fatalf("Expected the event to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"product-svc/startup"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// InitInvalidationConsumer creates the consumer for cache invalidation events. It is
+// not part of a consumer group: every replica must see every event.
+func InitInvalidationConsumer(logger *zap.Logger) (sarama.Consumer, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+
+	var consumer sarama.Consumer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumer, err = sarama.NewConsumer(brokers, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka invalidation consumer: %w", err)
+	}
+
+	logger.Info("Kafka invalidation consumer initialized")
+	return consumer, nil
+}
+
+// StartInvalidationConsumer calls invalidate for each product_updated or product_deleted
+// event until ctx is done, including this replica's own events. It reads every partition
+// from the newest offset; events published while the replica was down don't matter,
+// since its in-process caches started empty.
+func StartInvalidationConsumer(ctx context.Context, consumer sarama.Consumer, invalidate func(productID int), logger *zap.Logger) error {
+	topic := getEnv("KAFKA_PRODUCTS_TOPIC", "products")
+
+	partitions, err := consumer.Partitions(topic)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		partitionConsumer, err := consumer.ConsumePartition(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return fmt.Errorf("failed to consume partition %d: %w", partition, err)
+		}
+		defer partitionConsumer.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case message, ok := <-partitionConsumer.Messages():
+					if !ok {
+						return
+					}
+					handleInvalidation(message, invalidate, logger)
+				case err, ok := <-partitionConsumer.Errors():
+					if !ok {
+						return
+					}
+					logger.Error("Kafka invalidation consumer error", zap.Error(err))
+				}
+			}
+		}()
+	}
+
+	logger.Info("Kafka invalidation consumer started", zap.String("topic", topic), zap.Int("partitions", len(partitions)))
+	wg.Wait()
+	return nil
+}
+
+func handleInvalidation(message *sarama.ConsumerMessage, invalidate func(productID int), logger *zap.Logger) {
+	var event ProductChangedEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		logger.Debug("Skipping undecodable event", zap.Error(err))
+		return
+	}
+
+	switch event.EventType {
+	case eventProductUpdated, eventProductDeleted:
+		invalidate(event.ProductID)
+		logger.Debug("Product invalidated",
+			zap.String("event_type", event.EventType),
+			zap.Int("product_id", event.ProductID),
+		)
+	}
+}
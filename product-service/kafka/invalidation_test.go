@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStartInvalidationConsumer_InvalidatesOnProductEvents(t *testing.T) {
+	t.Setenv("KAFKA_PRODUCTS_TOPIC", "products")
+
+	consumer := mocks.NewConsumer(t, nil)
+	consumer.SetTopicMetadata(map[string][]int32{"products": {0, 1}})
+	p0 := consumer.ExpectConsumePartition("products", 0, sarama.OffsetNewest)
+	p1 := consumer.ExpectConsumePartition("products", 1, sarama.OffsetNewest)
+
+	send := func(pc *mocks.PartitionConsumer, event any) {
+		value, _ := json.Marshal(event)
+		pc.YieldMessage(&sarama.ConsumerMessage{Value: value})
+	}
+	send(p0, ProductChangedEvent{EventType: eventProductUpdated, ProductID: 3})
+	send(p0, map[string]any{"event_type": "order_created", "order_id": 9, "product_id": 4})
+	send(p1, ProductChangedEvent{EventType: eventProductDeleted, ProductID: 5})
+
+	invalidated := make(chan int, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- StartInvalidationConsumer(ctx, consumer, func(productID int) { invalidated <- productID }, zaptest.NewLogger(t))
+	}()
+
+	got := map[int]bool{}
+	for len(got) < 2 {
+		select {
+		case id := <-invalidated:
+			got[id] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for invalidations, got %v", got)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("consumer returned error: %v", err)
+	}
+
+	if !got[3] || !got[5] {
+		t.Errorf("expected products 3 and 5 invalidated, got %v", got)
+	}
+	select {
+	case id := <-invalidated:
+		t.Errorf("unexpected invalidation of product %d from a non-product event", id)
+	default:
+	}
+}
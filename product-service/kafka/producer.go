@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"product-svc/startup"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const eventProductLowStock = "product_low_stock"
+
+// LowStockEvent is published when a stock change takes a product below the low-stock threshold
+type LowStockEvent struct {
+	EventType string    `json:"event_type"`
+	ProductID int       `json:"product_id"`
+	Name      string    `json:"name,omitempty"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func InitProducer(logger *zap.Logger) (sarama.SyncProducer, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+
+	var producer sarama.SyncProducer
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		producer, err = sarama.NewSyncProducer(brokers, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+
+	logger.Info("Kafka producer initialized")
+	return producer, nil
+}
+
+// LowStockPublisher emits product_low_stock events. A nil publisher is valid and publishes nothing.
+type LowStockPublisher struct {
+	producer  sarama.SyncProducer
+	topic     string
+	threshold int
+	logger    *zap.Logger
+}
+
+func NewLowStockPublisher(producer sarama.SyncProducer, logger *zap.Logger) *LowStockPublisher {
+	return &LowStockPublisher{
+		producer:  producer,
+		topic:     getEnv("KAFKA_PRODUCTS_TOPIC", "products"),
+		threshold: getEnvInt("LOW_STOCK_THRESHOLD", 5),
+		logger:    logger,
+	}
+}
+
+// StockChanged publishes a low-stock event when stock moves from at or above the threshold
+// to below it, so each drop alerts once instead of on every later sale. A negative previous
+// value means the old stock is unknown and any stock below the threshold is reported.
+// Failures are logged only: the stock change has already been committed.
+func (p *LowStockPublisher) StockChanged(ctx context.Context, productID int, name string, previous, current int) {
+	if p == nil || current >= p.threshold || (previous >= 0 && previous < p.threshold) {
+		return
+	}
+
+	event := LowStockEvent{
+		EventType: eventProductLowStock,
+		ProductID: productID,
+		Name:      name,
+		Stock:     current,
+		Threshold: p.threshold,
+		Timestamp: time.Now(),
+	}
+	if err := publish(ctx, p.producer, p.topic, strconv.Itoa(productID), event); err != nil {
+		p.logger.Error("Failed to publish low stock event", zap.Int("product_id", productID), zap.Error(err))
+		return
+	}
+	p.logger.Warn("Product stock is low",
+		zap.String("trace_id", traceIDFromContext(ctx)),
+		zap.Int("product_id", productID),
+		zap.Int("stock", current),
+		zap.Int("threshold", p.threshold),
+	)
+}
+
+func publish(ctx context.Context, producer sarama.SyncProducer, topic, key string, event any) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.StringEncoder(eventJSON),
+	}
+
+	// Inject trace context into Kafka message headers
+	carrier := make(saramaHeaderCarrier, 0)
+	otel.GetTextMapPropagator().Inject(ctx, &carrier)
+	msg.Headers = []sarama.RecordHeader(carrier)
+
+	if _, _, err := producer.SendMessage(msg); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+func traceIDFromContext(ctx context.Context) string {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		return span.SpanContext().TraceID().String()
+	}
+	return ""
+}
+
+// saramaHeaderCarrier implements the TextMapCarrier interface for Kafka headers (for producer)
+type saramaHeaderCarrier []sarama.RecordHeader
+
+func (c saramaHeaderCarrier) Get(key string) string {
+	for _, h := range c {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *saramaHeaderCarrier) Set(key, value string) {
+	*c = append(*c, sarama.RecordHeader{
+		Key:   []byte(key),
+		Value: []byte(value),
+	})
+}
+
+func (c saramaHeaderCarrier) Keys() []string {
+	keys := make([]string, len(c))
+	for i, h := range c {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+const (
+	eventProductUpdated = "product_updated"
+	eventProductDeleted = "product_deleted"
+	eventReviewCreated  = "review_created"
+)
+
+// ProductChangedEvent tells every product-service replica that a product changed, so
+// each one drops what it holds about it in process
+type ProductChangedEvent struct {
+	EventType string    `json:"event_type"`
+	ProductID int       `json:"product_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ReviewCreatedEvent is published when a customer reviews a product
+type ReviewCreatedEvent struct {
+	EventType string    `json:"event_type"`
+	ReviewID  int64     `json:"review_id"`
+	ProductID int       `json:"product_id"`
+	UserID    int       `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ProductEventPublisher emits product_updated, product_deleted and review_created events. A nil
+// publisher is valid and publishes nothing.
+type ProductEventPublisher struct {
+	producer sarama.SyncProducer
+	topic    string
+	logger   *zap.Logger
+}
+
+func NewProductEventPublisher(producer sarama.SyncProducer, logger *zap.Logger) *ProductEventPublisher {
+	return &ProductEventPublisher{
+		producer: producer,
+		topic:    getEnv("KAFKA_PRODUCTS_TOPIC", "products"),
+		logger:   logger,
+	}
+}
+
+// Updated publishes product_updated after a committed write to a product or its stock
+func (p *ProductEventPublisher) Updated(ctx context.Context, productID int) {
+	p.publish(ctx, eventProductUpdated, productID)
+}
+
+// Deleted publishes product_deleted after a product is removed
+func (p *ProductEventPublisher) Deleted(ctx context.Context, productID int) {
+	p.publish(ctx, eventProductDeleted, productID)
+}
+
+// ReviewCreated publishes review_created after a review is stored. Failures are only
+// logged, as for the other product events.
+func (p *ProductEventPublisher) ReviewCreated(ctx context.Context, reviewID int64, productID, userID, rating int) {
+	if p == nil {
+		return
+	}
+
+	event := ReviewCreatedEvent{
+		EventType: eventReviewCreated,
+		ReviewID:  reviewID,
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    rating,
+		Timestamp: time.Now(),
+	}
+	if err := publish(ctx, p.producer, p.topic, strconv.Itoa(productID), event); err != nil {
+		p.logger.Error("Failed to publish review event",
+			zap.Int64("review_id", reviewID),
+			zap.Int("product_id", productID),
+			zap.Error(err),
+		)
+	}
+}
+
+// publish only logs failures: the write has already been committed, and other replicas
+// fall back to their cache TTLs
+func (p *ProductEventPublisher) publish(ctx context.Context, eventType string, productID int) {
+	if p == nil {
+		return
+	}
+
+	event := ProductChangedEvent{
+		EventType: eventType,
+		ProductID: productID,
+		Timestamp: time.Now(),
+	}
+	if err := publish(ctx, p.producer, p.topic, strconv.Itoa(productID), event); err != nil {
+		p.logger.Error("Failed to publish product event",
+			zap.String("event_type", eventType),
+			zap.Int("product_id", productID),
+			zap.Error(err),
+		)
+	}
+}
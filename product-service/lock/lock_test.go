@@ -0,0 +1,164 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGuard_RunsWithoutLockWhenUnavailable(t *testing.T) {
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer unreachable.Close()
+
+	for name, locker := range map[string]*Locker{
+		"nil locker":        nil,
+		"redis unreachable": NewLocker(unreachable, zaptest.NewLogger(t)),
+	} {
+		ran := false
+		err := locker.Guard(context.Background(), "stock", "1", time.Second, time.Second, func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+		if !ran {
+			t.Errorf("%s: expected fn to run", name)
+		}
+	}
+}
+
+func newTestLocker(t *testing.T) (*Locker, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewLocker(rdb, zaptest.NewLogger(t)), m
+}
+
+func TestLocker_Contention(t *testing.T) {
+	locker, _ := newTestLocker(t)
+	ctx := context.Background()
+
+	held, err := locker.TryAcquire(ctx, "job", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	if _, err := locker.TryAcquire(ctx, "job", 10*time.Second); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("Expected ErrNotAcquired while the lock is held, got %v", err)
+	}
+	ran := false
+	err = locker.WithLock(ctx, "job", 10*time.Second, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, ErrNotAcquired) || ran {
+		t.Errorf("Expected WithLock to skip fn with ErrNotAcquired, got %v (ran: %v)", err, ran)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	_, err = locker.Acquire(waitCtx, "job", 10*time.Second)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Acquire to give up when its context expires, got %v", err)
+	}
+
+	// A waiter gets the lock once the holder lets go
+	acquired := make(chan error, 1)
+	go func() {
+		waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		lk, err := locker.Acquire(waitCtx, "job", 10*time.Second)
+		if err == nil {
+			err = lk.Release(ctx)
+		}
+		acquired <- err
+	}()
+	time.Sleep(2 * retryInterval)
+	if err := held.Release(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if err := <-acquired; err != nil {
+		t.Errorf("Expected the waiter to acquire the released lock, got %v", err)
+	}
+}
+
+func TestLock_Renewal(t *testing.T) {
+	locker, m := newTestLocker(t)
+	ctx := context.Background()
+
+	ttl := 300 * time.Millisecond
+	lk, err := locker.TryAcquire(ctx, "job", ttl)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lk.Release(ctx)
+
+	// Renewal runs every ttl/3 and resets the TTL to the full ttl
+	m.SetTTL("lock:job", 10*time.Millisecond)
+	time.Sleep(ttl / 2)
+	if got := m.TTL("lock:job"); got != ttl {
+		t.Errorf("Expected renewal to reset the TTL to %s, got %s", ttl, got)
+	}
+	select {
+	case <-lk.Lost():
+		t.Error("Expected a renewed lock not to be lost")
+	default:
+	}
+}
+
+func TestLock_ReleaseOnlyByOwner(t *testing.T) {
+	locker, m := newTestLocker(t)
+	ctx := context.Background()
+
+	first, err := locker.TryAcquire(ctx, "job", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	// The lock expires and another holder takes it
+	m.FastForward(10 * time.Second)
+	second, err := locker.TryAcquire(ctx, "job", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Expected the expired lock to be acquirable, got %v", err)
+	}
+
+	if err := first.Release(ctx); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("Expected ErrNotHeld releasing a lock held elsewhere, got %v", err)
+	}
+	if !m.Exists("lock:job") {
+		t.Fatal("Expected the first holder's release to leave the second holder's lock")
+	}
+	if err := second.Release(ctx); err != nil {
+		t.Errorf("Expected the owner's release to succeed, got %v", err)
+	}
+	if m.Exists("lock:job") {
+		t.Error("Expected the owner's release to delete the lock")
+	}
+}
+
+func TestWithLock_CancelsWhenLockLost(t *testing.T) {
+	locker, m := newTestLocker(t)
+
+	err := locker.WithLock(context.Background(), "job", 150*time.Millisecond, func(ctx context.Context) error {
+		// Someone else takes the key, so the next renewal fails
+		m.Set("lock:job", "another-token")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			return errors.New("fn was not cancelled")
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected fn's context to be cancelled when the lock is lost, got %v", err)
+	}
+	if got, _ := m.Get("lock:job"); got != "another-token" {
+		t.Errorf("Expected the lost lock's release to leave the new holder's key, got %q", got)
+	}
+}
@@ -1,163 +1,58 @@
 package main
 
 import (
-	"context"
-	"database/sql"
-	"log"
-	"net/http"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
-	"product-svc/cache"
-	"product-svc/database"
-	"product-svc/handlers"
-	"product-svc/middleware"
-	product "product-svc/proto"
-
-	"net"
-
-	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
 )
 
 func main() {
-	// Initialize logger
-	logger, err := zap.NewProduction()
-	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer logger.Sync()
-
-	// Initialize database
-	db, err := database.InitDB(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize database", zap.Error(err))
-	}
-	defer db.Close()
-
-	// Initialize Redis cache
-	redisClient, err := cache.InitRedis(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize Redis", zap.Error(err))
-	}
-	defer redisClient.Close()
-
-	// Initialize OpenTelemetry
-	shutdownTracing, err := middleware.InitTracing("product-service")
-	if err != nil {
-		logger.Fatal("Failed to initialize tracing", zap.Error(err))
-	}
-
-	// Setup Gin router
-	router := gin.New()
-	router.Use(gin.Recovery())
-	// OpenTelemetry middleware must be first to extract trace context
-	router.Use(otelgin.Middleware("product-service"))
-	router.Use(middleware.LoggerMiddleware(logger))
-	router.Use(middleware.MetricsMiddleware())
-
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-
-	// Metrics endpoint
-	router.GET("/metrics", middleware.PrometheusHandler())
-
-	// Product endpoints
-	productHandler := handlers.NewProductHandler(db, redisClient, logger)
-	router.GET("/api/v1/products", productHandler.GetProducts)
-	router.GET("/api/v1/products/:id", productHandler.GetProduct)
-	router.POST("/api/v1/products", productHandler.CreateProduct)
-	router.PUT("/api/v1/products/:id", productHandler.UpdateProduct)
-	router.DELETE("/api/v1/products/:id", productHandler.DeleteProduct)
-
-	// Start server
-	restSrv := &http.Server{
-		Addr:    ":8081",
-		Handler: router,
-	}
-
-	// Graceful shutdown
-	go func() {
-		if err := restSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Failed to start server", zap.Error(err))
-		}
-	}()
-
-	logger.Info("Product Service REST API started on :8081")
-
-	// Start gRPC server
-	grpcListener, err := net.Listen("tcp", ":50052")
-	if err != nil {
-		logger.Fatal("Failed to listen on gRPC port", zap.Error(err))
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
+}
 
-	grpcServer := grpc.NewServer(
-		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+// newRootCmd builds the product-service CLI. Running it without a subcommand serves, so
+// existing deployments and `go run .` keep working.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "product-service",
+		Short:        "Product service APIs, background workers and operational tasks",
+		SilenceUsage: true,
+		RunE:         withLogger(serve),
+	}
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the REST and gRPC APIs, the sales consumer and the ranking job",
+			Args:  cobra.NoArgs,
+			RunE:  withLogger(serve),
+		},
+		newMigrateCmd(),
+		&cobra.Command{
+			Use:   "seed",
+			Short: "Insert demo products, skipping SKUs that already exist",
+			Args:  cobra.NoArgs,
+			RunE:  withLogger(seed),
+		},
+		newConsumeCmd(),
 	)
-	productService := handlers.NewProductService(db, logger)
-	product.RegisterProductServiceServer(grpcServer, productService)
-
-	go func() {
-		if err := grpcServer.Serve(grpcListener); err != nil {
-			logger.Fatal("Failed to start gRPC server", zap.Error(err))
-		}
-	}()
-
-	logger.Info("Product Service gRPC server started on :50052")
-
-	// Call graceful shutdown function
-	gracefulShutdown(restSrv, grpcServer, db, redisClient, shutdownTracing, logger)
+	return root
 }
 
-// gracefulShutdown handles SIGINT/SIGTERM and shuts down all services gracefully
-func gracefulShutdown(
-	restSrv *http.Server,
-	grpcServer *grpc.Server,
-	db *sql.DB,
-	redisClient *redis.Client,
-	shutdownTracing func(),
-	logger *zap.Logger,
-) {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	logger.Info("Shutdown signal received. Exiting...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Stop REST server
-	if err := restSrv.Shutdown(ctx); err != nil {
-		logger.Error("REST server forced to shutdown", zap.Error(err))
-	} else {
-		logger.Info("REST server stopped gracefully")
-	}
-
-	// Stop gRPC server
-	grpcServer.GracefulStop()
-	logger.Info("gRPC server stopped gracefully")
-
-	// Close database
-	if err := db.Close(); err != nil {
-		logger.Error("Failed to close database", zap.Error(err))
-	} else {
-		logger.Info("Database connection closed gracefully")
-	}
+// withLogger gives a command the production logger every subcommand shares. Config is
+// otherwise read from the environment by each package, exactly as when serving.
+func withLogger(run func(logger *zap.Logger) error) func(*cobra.Command, []string) error {
+	return func(_ *cobra.Command, _ []string) error {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer logger.Sync()
 
-	// Close Redis cache
-	if err := redisClient.Close(); err != nil {
-		logger.Error("Failed to close Redis cache", zap.Error(err))
-	} else {
-		logger.Info("Redis cache closed gracefully")
+		return run(logger)
 	}
-
-	// Shutdown tracing
-	shutdownTracing()
-	logger.Info("Product Service exited gracefully")
 }
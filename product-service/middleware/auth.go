@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleAdmin is the JWT "role" claim user-service issues to catalog administrators
+const RoleAdmin = "admin"
+
+// jwtSecret verifies tokens issued by user-service's login, so JWT_SECRET must match
+// the one user-service signs with
+var jwtSecret = []byte(jwtSecretFromEnv())
+
+// devJWTSecret is the key used without JWT_SECRET in DEV_MODE, the one docker-compose sets
+const devJWTSecret = "dev-jwt-secret"
+
+// errNoJWTSecret rejects every token while no key is configured
+var errNoJWTSecret = errors.New("JWT_SECRET is not set; set it, or DEV_MODE=true to use a development key")
+
+func jwtSecretFromEnv() string {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return secret
+	}
+	if DevMode() {
+		return devJWTSecret
+	}
+	return ""
+}
+
+// CheckJWTSecret fails when JWT_SECRET is unset outside DEV_MODE. serve exits on it rather
+// than verify tokens with a key anyone could guess.
+func CheckJWTSecret() error {
+	if len(jwtSecret) == 0 {
+		return errNoJWTSecret
+	}
+	return nil
+}
+
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate verifies the bearer token and sets its claims on the context. On failure
+// it responds 401, aborts and returns false.
+func authenticate(c *gin.Context) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		return false
+	}
+
+	tokenString := parts[1]
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		if len(jwtSecret) == 0 {
+			return nil, errNoJWTSecret
+		}
+		return jwtSecret, nil
+	})
+
+	if err != nil || !token.Valid {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return false
+	}
+
+	c.Set("user_id", claims["user_id"])
+	c.Set("email", claims["email"])
+	c.Set("role", claims["role"])
+	return true
+}
+
+// RequireRole rejects authenticated users without role with 403. It must run after
+// AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireRole(c, role) {
+			return
+		}
+		c.Next()
+	}
+}
+
+func requireRole(c *gin.Context, role string) bool {
+	if got, _ := c.Get("role"); got != role {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+	return true
+}
+
+// skipCacheKey marks a request allowed to bypass the cache
+const skipCacheKey = "skip_cache"
+
+// AdminCacheBypass lets admins add ?skip_cache=true to a read to get it straight from
+// Postgres, for debugging stale cache entries. The flag needs an admin token; requests
+// without it are public as before.
+func AdminCacheBypass() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("skip_cache") != "true" {
+			c.Next()
+			return
+		}
+		if !authenticate(c) || !requireRole(c, RoleAdmin) {
+			return
+		}
+		c.Set(skipCacheKey, true)
+		c.Next()
+	}
+}
+
+// SkipCache reports whether AdminCacheBypass allowed this request to bypass the cache
+func SkipCache(c *gin.Context) bool {
+	return c.GetBool(skipCacheKey)
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestMain gives the tests a key to sign tokens with, as JWT_SECRET would
+func TestMain(m *testing.M) {
+	jwtSecret = []byte("test-jwt-secret")
+	os.Exit(m.Run())
+}
+
+func signedToken(t *testing.T, role string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"email":   "admin@example.com",
+		"role":    role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestRequireRole_Admin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/products", AuthMiddleware(), RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	for name, tc := range map[string]struct {
+		authorization string
+		want          int
+	}{
+		"no token":       {"", http.StatusUnauthorized},
+		"bad signature":  {"Bearer " + signedToken(t, RoleAdmin) + "x", http.StatusUnauthorized},
+		"customer token": {"Bearer " + signedToken(t, "customer"), http.StatusForbidden},
+		"admin token":    {"Bearer " + signedToken(t, RoleAdmin), http.StatusCreated},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestAdminCacheBypass(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/products/:id", AdminCacheBypass(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"skip_cache": SkipCache(c)})
+	})
+
+	for name, tc := range map[string]struct {
+		query         string
+		authorization string
+		want          int
+		wantBody      string
+	}{
+		"no flag, no token":     {"", "", http.StatusOK, `{"skip_cache":false}`},
+		"flag, no token":        {"?skip_cache=true", "", http.StatusUnauthorized, ""},
+		"flag, customer":        {"?skip_cache=true", "Bearer " + signedToken(t, "customer"), http.StatusForbidden, ""},
+		"flag, admin":           {"?skip_cache=true", "Bearer " + signedToken(t, RoleAdmin), http.StatusOK, `{"skip_cache":true}`},
+		"other value, no token": {"?skip_cache=1", "", http.StatusOK, `{"skip_cache":false}`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/products/1"+tc.query, nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+		if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+			t.Errorf("%s: expected body %s, got %s", name, tc.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestJWTSecretFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		devMode string
+		want    string
+	}{
+		{"configured", "configured-secret", "", "configured-secret"},
+		{"configured in dev mode", "configured-secret", "true", "configured-secret"},
+		{"dev mode without secret", "", "true", devJWTSecret},
+		{"unset", "", "", ""},
+		{"unset with dev mode off", "", "false", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", tt.secret)
+			t.Setenv("DEV_MODE", tt.devMode)
+			if got := jwtSecretFromEnv(); got != tt.want {
+				t.Errorf("Expected secret %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckJWTSecret_Unset(t *testing.T) {
+	token := signedToken(t, RoleAdmin)
+	saved := jwtSecret
+	jwtSecret = nil
+	t.Cleanup(func() { jwtSecret = saved })
+
+	if err := CheckJWTSecret(); err == nil {
+		t.Error("Expected an error without a JWT secret")
+	}
+
+	// Tokens signed with an empty key must not verify either
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/me", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, authorization := range []string{"Bearer " + token, "Bearer " + signedWithEmptyKey(t)} {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+func signedWithEmptyKey(t *testing.T) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"role":    RoleAdmin,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte{})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Business milestone span events. The names and attribute keys are the same in every
+// service, so a trace waterfall reads as the order's timeline: created, stock reserved,
+// payment authorized, customer notified.
+const (
+	EventOrderCreated      = "order.created"
+	EventStockReserved     = "stock.reserved"
+	EventPaymentAuthorized = "payment.authorized"
+	EventNotificationSent  = "notification.sent"
+)
+
+// RecordBusinessEvent adds a milestone event to the span in ctx. It is a no-op when ctx
+// carries no recording span.
+func RecordBusinessEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testUnaryInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}
+
+// fakeServerStream carries only the context the interceptors read
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func withServiceToken(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(ServiceTokenHeader, token))
+}
+
+var authTests = []struct {
+	name       string
+	configured string
+	devMode    bool
+	provided   string
+	want       codes.Code
+}{
+	{"matching token", "secret", false, "secret", codes.OK},
+	{"missing token", "secret", false, "", codes.Unauthenticated},
+	{"wrong token", "secret", false, "other", codes.Unauthenticated},
+	{"unconfigured", "", false, "", codes.Unauthenticated},
+	{"unconfigured with a token", "", false, "secret", codes.Unauthenticated},
+	{"unconfigured in dev mode", "", true, "", codes.OK},
+	{"dev mode still checks a configured token", "secret", true, "other", codes.Unauthenticated},
+}
+
+func TestUnaryAuthInterceptor(t *testing.T) {
+	for _, tt := range authTests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			_, err := UnaryAuthInterceptor(tt.configured, tt.devMode)(withServiceToken(tt.provided), nil, testUnaryInfo,
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					return nil, nil
+				})
+			if code := status.Code(err); code != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, code)
+			}
+			if called != (tt.want == codes.OK) {
+				t.Errorf("Expected the handler called=%v, got %v", tt.want == codes.OK, called)
+			}
+		})
+	}
+}
+
+func TestStreamAuthInterceptor(t *testing.T) {
+	for _, tt := range authTests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			err := StreamAuthInterceptor(tt.configured, tt.devMode)(nil, &fakeServerStream{ctx: withServiceToken(tt.provided)},
+				&grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+				func(srv interface{}, ss grpc.ServerStream) error {
+					called = true
+					return nil
+				})
+			if code := status.Code(err); code != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, code)
+			}
+			if called != (tt.want == codes.OK) {
+				t.Errorf("Expected the handler called=%v, got %v", tt.want == codes.OK, called)
+			}
+		})
+	}
+}
+
+func TestUnaryRecoveryInterceptor(t *testing.T) {
+	_, err := UnaryRecoveryInterceptor(zap.NewNop())(context.Background(), nil, testUnaryInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("Expected a recovered panic to return %s, got %s", codes.Internal, code)
+	}
+}
+
+func TestStreamRecoveryInterceptor(t *testing.T) {
+	err := StreamRecoveryInterceptor(zap.NewNop())(nil, &fakeServerStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			panic("boom")
+		})
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("Expected a recovered panic to return %s, got %s", codes.Internal, code)
+	}
+}
@@ -26,11 +26,120 @@ var (
 		},
 		[]string{"method", "endpoint"},
 	)
+
+	lockAcquisitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_acquisitions_total",
+			Help: "Total number of distributed lock acquisition attempts",
+		},
+		[]string{"lock", "result"},
+	)
+
+	lockHeldDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "distributed_lock_held_seconds",
+			Help:    "How long distributed locks were held in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lock"},
+	)
+
+	lockRenewalFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distributed_lock_renewal_failures_total",
+			Help: "Total number of distributed locks lost because TTL renewal failed",
+		},
+		[]string{"lock"},
+	)
+
+	stockConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_version_conflicts_total",
+			Help: "Total number of product writes rejected or retried because the version changed",
+		},
+		[]string{"operation"},
+	)
+
+	cacheOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "product_cache_operation_duration_seconds",
+			Help:    "Product cache operation latency in seconds, including retries",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+		},
+		[]string{"operation", "result"},
+	)
+
+	availabilityCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_availability_cache_requests_total",
+			Help: "Total number of CheckAvailability calls answered from (hit) or past (miss) the in-process cache",
+		},
+		[]string{"result"},
+	)
+
+	cacheRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_cache_retries_total",
+			Help: "Total number of product cache operations retried after a transient failure",
+		},
+		[]string{"operation"},
+	)
+
+	cacheInvalidationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_cache_invalidations_total",
+			Help: "Total number of product cache invalidation steps (delete, delayed_delete, write_through) by result, after retries",
+		},
+		[]string{"step", "result"},
+	)
+
+	circuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"breaker"},
+	)
+
+	circuitBreakerOpensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_opens_total",
+			Help: "Total number of times a circuit breaker opened",
+		},
+		[]string{"breaker"},
+	)
+
+	circuitBreakerShortCircuitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_short_circuited_total",
+			Help: "Total number of calls rejected without being attempted because the circuit breaker was open",
+		},
+		[]string{"breaker"},
+	)
+
+	replicaHealthy = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "product_db_replica_healthy",
+			Help: "1 while reads are routed to the read replica, 0 while they fall back to the primary",
+		},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(lockAcquisitionsTotal)
+	prometheus.MustRegister(lockHeldDuration)
+	prometheus.MustRegister(lockRenewalFailuresTotal)
+	prometheus.MustRegister(stockConflictsTotal)
+	prometheus.MustRegister(cacheOperationDuration)
+	prometheus.MustRegister(cacheRetriesTotal)
+	prometheus.MustRegister(availabilityCacheTotal)
+	prometheus.MustRegister(cacheInvalidationsTotal)
+	prometheus.MustRegister(circuitBreakerState)
+	prometheus.MustRegister(circuitBreakerOpensTotal)
+	prometheus.MustRegister(circuitBreakerShortCircuitsTotal)
+	prometheus.MustRegister(replicaHealthy)
 }
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -54,3 +163,55 @@ func MetricsMiddleware() gin.HandlerFunc {
 func PrometheusHandler() gin.HandlerFunc {
 	return gin.WrapH(promhttp.Handler())
 }
+
+func RecordLockAcquisition(lock, result string) {
+	lockAcquisitionsTotal.WithLabelValues(lock, result).Inc()
+}
+
+func ObserveLockHeld(lock string, d time.Duration) {
+	lockHeldDuration.WithLabelValues(lock).Observe(d.Seconds())
+}
+
+func RecordLockRenewalFailure(lock string) {
+	lockRenewalFailuresTotal.WithLabelValues(lock).Inc()
+}
+
+func RecordStockConflict(operation string) {
+	stockConflictsTotal.WithLabelValues(operation).Inc()
+}
+
+func ObserveCacheOperation(operation, result string, d time.Duration) {
+	cacheOperationDuration.WithLabelValues(operation, result).Observe(d.Seconds())
+}
+
+func RecordCacheRetry(operation string) {
+	cacheRetriesTotal.WithLabelValues(operation).Inc()
+}
+
+func RecordCacheInvalidation(step, result string) {
+	cacheInvalidationsTotal.WithLabelValues(step, result).Inc()
+}
+
+func RecordAvailabilityCache(result string) {
+	availabilityCacheTotal.WithLabelValues(result).Inc()
+}
+
+func SetCircuitBreakerState(breaker string, state int) {
+	circuitBreakerState.WithLabelValues(breaker).Set(float64(state))
+}
+
+func RecordCircuitBreakerOpen(breaker string) {
+	circuitBreakerOpensTotal.WithLabelValues(breaker).Inc()
+}
+
+func RecordCircuitBreakerShortCircuit(breaker string) {
+	circuitBreakerShortCircuitsTotal.WithLabelValues(breaker).Inc()
+}
+
+func SetReplicaHealthy(healthy bool) {
+	if healthy {
+		replicaHealthy.Set(1)
+	} else {
+		replicaHealthy.Set(0)
+	}
+}
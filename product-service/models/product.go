@@ -1,24 +1,235 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
 
 type Product struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	Stock     int       `json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	SKU        string         `json:"sku,omitempty"`
+	Price      float64        `json:"price"`
+	Currency   string         `json:"currency"`
+	Stock      int            `json:"stock"`
+	Version    int            `json:"version"`
+	Tags       []string       `json:"tags"`
+	Attributes Attributes     `json:"attributes"`
+	Images     []ProductImage `json:"images,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// ProductImage is the metadata of an image stored in object storage.
+// URL is a short-lived signed URL filled in when the product is served.
+type ProductImage struct {
+	ID          int       `json:"id"`
+	ProductID   int       `json:"product_id"`
+	ObjectKey   string    `json:"object_key"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	URL         string    `json:"url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
+// DefaultCurrency is the currency products are priced in when none is given
+const DefaultCurrency = "USD"
+
+// CreateProductRequest creates a product priced in Currency, an ISO 4217 code,
+// DefaultCurrency when empty
 type CreateProductRequest struct {
-	Name  string  `json:"name" binding:"required"`
-	Price float64 `json:"price" binding:"required,gt=0"`
-	Stock int     `json:"stock" binding:"gte=0"`
+	Name       string     `json:"name" binding:"required"`
+	SKU        string     `json:"sku" binding:"omitempty,max=64"`
+	Price      float64    `json:"price" binding:"required,gt=0"`
+	Currency   string     `json:"currency" binding:"omitempty,iso4217"`
+	Stock      int        `json:"stock" binding:"gte=0"`
+	Cost       float64    `json:"cost" binding:"gte=0"`
+	Tags       []string   `json:"tags" binding:"max=20"`
+	Attributes Attributes `json:"attributes"`
 }
 
+// UpdateProductRequest only changes stock/tags/attributes when they are present in
+// the body; send an empty list/object to clear tags/attributes. When Version is set
+// the update only applies if the product is still at that version.
 type UpdateProductRequest struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price" binding:"omitempty,gt=0"`
-	Stock int     `json:"stock" binding:"omitempty,gte=0"`
+	Name       string     `json:"name"`
+	SKU        string     `json:"sku" binding:"omitempty,max=64"`
+	Price      float64    `json:"price" binding:"omitempty,gt=0"`
+	Currency   string     `json:"currency" binding:"omitempty,iso4217"`
+	Stock      *int       `json:"stock" binding:"omitempty,gte=0"`
+	Cost       float64    `json:"cost" binding:"omitempty,gte=0"`
+	Tags       []string   `json:"tags" binding:"omitempty,max=20"`
+	Attributes Attributes `json:"attributes"`
+	Version    *int       `json:"version" binding:"omitempty,gte=1"`
+}
+
+// PriceChange is one entry in a product's price history. OldPrice is nil for the
+// price the product was created with.
+type PriceChange struct {
+	ID        int64     `json:"id"`
+	ProductID int       `json:"product_id"`
+	OldPrice  *float64  `json:"old_price"`
+	NewPrice  float64   `json:"new_price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// StockAdjustmentRequest changes stock by Delta units, which may be negative, for one
+// of a fixed set of reasons
+type StockAdjustmentRequest struct {
+	Delta  int    `json:"delta" binding:"required"`
+	Reason string `json:"reason" binding:"required,oneof=restock return damaged lost correction"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
+// StockAdjustment is one entry in the stock adjustment ledger
+type StockAdjustment struct {
+	ID         int64     `json:"id"`
+	ProductID  int       `json:"product_id"`
+	Delta      int       `json:"delta"`
+	Reason     string    `json:"reason"`
+	Note       string    `json:"note,omitempty"`
+	StockAfter int       `json:"stock_after"`
+	AdjustedBy string    `json:"adjusted_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateReviewRequest is a review by the authenticated user
+type CreateReviewRequest struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment" binding:"max=2000"`
+}
+
+type Review struct {
+	ID        int64     `json:"id"`
+	ProductID int       `json:"product_id"`
+	UserID    int       `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RatingSummary aggregates a product's reviews; it is what gets cached in Redis
+type RatingSummary struct {
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+}
+
+// ReviewListResponse is the paginated envelope returned by GET /products/:id/reviews
+type ReviewListResponse struct {
+	ProductID int `json:"product_id"`
+	RatingSummary
+	Data       []Review `json:"data"`
+	Page       int      `json:"page"`
+	Limit      int      `json:"limit"`
+	TotalPages int      `json:"total_pages"`
+}
+
+// ListProductsQuery holds the pagination, sorting and filter parameters for GET /products
+type ListProductsQuery struct {
+	Page     int      `form:"page" binding:"omitempty,gte=1"`
+	Limit    int      `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Sort     string   `form:"sort"`
+	MinPrice *float64 `form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice *float64 `form:"max_price" binding:"omitempty,gte=0"`
+	InStock  *bool    `form:"in_stock"`
+	Tags     []string `form:"tag"`
+}
+
+// ProductListResponse is the paginated envelope returned by GET /products
+type ProductListResponse struct {
+	Data       []Product `json:"data"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	Total      int       `json:"total"`
+	TotalPages int       `json:"total_pages"`
+}
+
+// ProductBatchResponse is returned by GET /products?ids=..., with products in the order
+// their IDs were requested
+type ProductBatchResponse struct {
+	Data       []Product `json:"data"`
+	MissingIDs []int     `json:"missing_ids"`
+}
+
+// FeaturedProduct is a product together with its merchandising score
+type FeaturedProduct struct {
+	Product
+	Score float64 `json:"score"`
+}
+
+// attributeKeyPattern restricts attribute keys so they are safe to use in filters
+var attributeKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Attributes are typed key-value metadata stored as JSONB. Values must be
+// strings, numbers or booleans.
+type Attributes map[string]interface{}
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*a = Attributes{}
+		return nil
+	default:
+		return fmt.Errorf("unsupported attributes type %T", src)
+	}
+	return json.Unmarshal(data, a)
+}
+
+// Validate checks attribute keys and that every value is a scalar
+func (a Attributes) Validate() error {
+	if len(a) > 50 {
+		return errors.New("at most 50 attributes are allowed")
+	}
+	for key, value := range a {
+		if !IsValidAttributeKey(key) {
+			return fmt.Errorf("invalid attribute key %q", key)
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("attribute %q must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
+func IsValidAttributeKey(key string) bool {
+	return attributeKeyPattern.MatchString(key)
+}
+
+// NormalizeSKU trims and upper-cases a SKU so lookups and uniqueness ignore case
+func NormalizeSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
+
+// NormalizeTags lower-cases, trims and de-duplicates tags, dropping empty ones
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
 }
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ProductVariant is a sellable version of a product, such as a size and color, with its
+// own SKU, price and stock. Orders for a variant take stock from the variant only.
+type ProductVariant struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Size      string    `json:"size,omitempty"`
+	Color     string    `json:"color,omitempty"`
+	Price     float64   `json:"price"`
+	Stock     int       `json:"stock"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateVariantRequest struct {
+	SKU   string  `json:"sku" binding:"required,max=64"`
+	Size  string  `json:"size" binding:"max=50"`
+	Color string  `json:"color" binding:"max=50"`
+	Price float64 `json:"price" binding:"required,gt=0"`
+	Stock int     `json:"stock" binding:"gte=0"`
+}
+
+// UpdateVariantRequest only changes the fields present in the body
+type UpdateVariantRequest struct {
+	Size  *string `json:"size" binding:"omitempty,max=50"`
+	Color *string `json:"color" binding:"omitempty,max=50"`
+	Price float64 `json:"price" binding:"omitempty,gt=0"`
+	Stock *int    `json:"stock" binding:"omitempty,gte=0"`
+}
@@ -0,0 +1,79 @@
+package product
+
+import "errors"
+
+// Validate methods are picked up by the gRPC validation interceptor
+
+func (r *GetProductRequest) Validate() error {
+	if r.GetProductId() <= 0 {
+		return errors.New("product_id must be positive")
+	}
+	return nil
+}
+
+func (r *GetProductBySKURequest) Validate() error {
+	if sku := r.GetSku(); sku == "" || len(sku) > 64 {
+		return errors.New("sku must be 1-64 characters")
+	}
+	return nil
+}
+
+func (r *CheckAvailabilityRequest) Validate() error {
+	if r.GetProductId() <= 0 {
+		return errors.New("product_id must be positive")
+	}
+	if r.GetQuantity() <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if r.GetVariantId() < 0 {
+		return errors.New("variant_id must not be negative")
+	}
+	return nil
+}
+
+func (r *GetVariantRequest) Validate() error {
+	if r.GetVariantId() <= 0 {
+		return errors.New("variant_id must be positive")
+	}
+	return nil
+}
+
+func (r *ReserveStockRequest) Validate() error {
+	if r.GetReservationId() == "" || len(r.GetReservationId()) > 64 {
+		return errors.New("reservation_id must be 1-64 characters")
+	}
+	if r.GetProductId() <= 0 {
+		return errors.New("product_id must be positive")
+	}
+	if r.GetQuantity() <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if r.GetVariantId() < 0 {
+		return errors.New("variant_id must not be negative")
+	}
+	return nil
+}
+
+func (r *ReleaseStockRequest) Validate() error {
+	if r.GetReservationId() == "" || len(r.GetReservationId()) > 64 {
+		return errors.New("reservation_id must be 1-64 characters")
+	}
+	return nil
+}
+
+func (r *ConfirmStockRequest) Validate() error {
+	if r.GetReservationId() == "" || len(r.GetReservationId()) > 64 {
+		return errors.New("reservation_id must be 1-64 characters")
+	}
+	return nil
+}
+
+func (r *ListProductsRequest) Validate() error {
+	if r.GetAfterId() < 0 {
+		return errors.New("after_id must not be negative")
+	}
+	if r.GetBatchSize() < 0 || r.GetBatchSize() > 1000 {
+		return errors.New("batch_size must be between 1 and 1000")
+	}
+	return nil
+}
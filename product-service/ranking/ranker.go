@@ -0,0 +1,186 @@
+package ranking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"product-svc/cache"
+	"product-svc/lock"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// stockSaturation is the stock level at which a product gets the full availability score
+	stockSaturation = 10
+
+	// lockName guards the recompute so only one replica runs it per tick
+	lockName = "featured-ranking"
+	lockTTL  = 30 * time.Second
+)
+
+// Weights configures how much each signal contributes to a product's featured score.
+// Every signal is normalised to [0, 1] before weighting.
+type Weights struct {
+	Sales  float64
+	Stock  float64
+	Margin float64
+}
+
+// Ranker periodically recomputes the featured product ranking into Redis
+type Ranker struct {
+	db          *sql.DB
+	redisClient *redis.Client
+	locker      *lock.Locker
+	logger      *zap.Logger
+	weights     Weights
+	interval    time.Duration
+	salesWindow time.Duration
+}
+
+func NewRanker(db *sql.DB, redisClient *redis.Client, locker *lock.Locker, logger *zap.Logger) *Ranker {
+	return &Ranker{
+		db:          db,
+		redisClient: redisClient,
+		locker:      locker,
+		logger:      logger,
+		weights: Weights{
+			Sales:  getEnvFloat("FEATURED_WEIGHT_SALES", 0.6),
+			Stock:  getEnvFloat("FEATURED_WEIGHT_STOCK", 0.2),
+			Margin: getEnvFloat("FEATURED_WEIGHT_MARGIN", 0.2),
+		},
+		interval:    getEnvDuration("FEATURED_REFRESH_INTERVAL", 5*time.Minute),
+		salesWindow: getEnvDuration("FEATURED_SALES_WINDOW", 7*24*time.Hour),
+	}
+}
+
+// Start recomputes the ranking immediately and then on every tick until ctx is cancelled
+func (r *Ranker) Start(ctx context.Context) {
+	r.logger.Info("Featured product ranker started",
+		zap.Duration("interval", r.interval),
+		zap.Duration("sales_window", r.salesWindow),
+		zap.Float64("weight_sales", r.weights.Sales),
+		zap.Float64("weight_stock", r.weights.Stock),
+		zap.Float64("weight_margin", r.weights.Margin),
+	)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		err := r.locker.WithLock(ctx, lockName, lockTTL, r.Recompute)
+		switch {
+		case errors.Is(err, lock.ErrNotAcquired):
+			r.logger.Debug("Featured ranking already running on another replica")
+		case err != nil:
+			r.logger.Error("Failed to recompute featured products", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Featured product ranker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type productSignals struct {
+	id    int
+	price float64
+	cost  float64
+	stock int
+	sales int
+}
+
+// Recompute scores every product and replaces the ranking in Redis
+func (r *Ranker) Recompute(ctx context.Context) error {
+	ctx, span := otel.Tracer("product-service").Start(ctx, "RecomputeFeaturedProducts")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.id, p.price, p.cost, p.stock, COALESCE(SUM(s.quantity), 0)
+		FROM products p
+		LEFT JOIN product_sales s ON s.product_id = p.id AND s.sold_at >= $1
+		GROUP BY p.id, p.price, p.cost, p.stock`,
+		time.Now().Add(-r.salesWindow),
+	)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to load product signals: %w", err)
+	}
+	defer rows.Close()
+
+	var signals []productSignals
+	maxSales := 0
+	for rows.Next() {
+		var p productSignals
+		if err := rows.Scan(&p.id, &p.price, &p.cost, &p.stock, &p.sales); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to scan product signals: %w", err)
+		}
+		if p.sales > maxSales {
+			maxSales = p.sales
+		}
+		signals = append(signals, p)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to iterate product signals: %w", err)
+	}
+
+	scores := make(map[int]float64, len(signals))
+	for _, p := range signals {
+		scores[p.id] = r.score(p, maxSales)
+	}
+
+	if err := cache.ReplaceFeaturedProducts(ctx, r.redisClient, scores); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to store featured products: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("products.ranked", len(scores)))
+	r.logger.Info("Featured products recomputed", zap.Int("products", len(scores)))
+	return nil
+}
+
+func (r *Ranker) score(p productSignals, maxSales int) float64 {
+	salesScore := 0.0
+	if maxSales > 0 {
+		salesScore = float64(p.sales) / float64(maxSales)
+	}
+
+	stockScore := math.Min(float64(p.stock)/stockSaturation, 1)
+
+	marginScore := 0.0
+	if p.price > 0 {
+		marginScore = math.Max((p.price-p.cost)/p.price, 0)
+	}
+
+	return r.weights.Sales*salesScore + r.weights.Stock*stockScore + r.weights.Margin*marginScore
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package ranking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"product-svc/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var signalColumns = []string{"id", "price", "cost", "stock", "sales"}
+
+func setupRanker(t *testing.T) (*Ranker, sqlmock.Sqlmock, *redis.Client) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return &Ranker{
+		db:          db,
+		redisClient: rdb,
+		logger:      zap.NewNop(),
+		weights:     Weights{Sales: 0.6, Stock: 0.2, Margin: 0.2},
+		salesWindow: time.Hour,
+	}, mock, rdb
+}
+
+func TestRanker_Recompute_Order(t *testing.T) {
+	ranker, mock, rdb := setupRanker(t)
+
+	// 1: best seller with a thin margin and no stock, 0.6 + 0 + 0.02
+	// 2: no sales, full stock and a wide margin, 0 + 0.2 + 0.16
+	// 3: half the sales, half the stock and margin, 0.3 + 0.1 + 0.1
+	mock.ExpectQuery("SELECT p.id, p.price, p.cost, p.stock").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(signalColumns).
+			AddRow(1, 10.0, 9.0, 0, 10).
+			AddRow(2, 10.0, 2.0, 20, 0).
+			AddRow(3, 10.0, 5.0, 5, 5))
+
+	if err := ranker.Recompute(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ranked, err := cache.GetFeaturedProducts(context.Background(), rdb, 10)
+	if err != nil {
+		t.Fatalf("Failed to read ranking: %v", err)
+	}
+	want := []struct {
+		id    string
+		score float64
+	}{{"1", 0.62}, {"3", 0.5}, {"2", 0.36}}
+	if len(ranked) != len(want) {
+		t.Fatalf("Expected %d ranked products, got %d", len(want), len(ranked))
+	}
+	for i, w := range want {
+		if ranked[i].Member != w.id || ranked[i].Score < w.score-1e-9 || ranked[i].Score > w.score+1e-9 {
+			t.Errorf("Rank %d: expected product %s with %.2f, got %v with %.4f", i, w.id, w.score, ranked[i].Member, ranked[i].Score)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestRanker_Recompute_Empty(t *testing.T) {
+	ranker, mock, rdb := setupRanker(t)
+	ctx := context.Background()
+
+	// A stale ranking is dropped when there are no products left to rank
+	if err := cache.ReplaceFeaturedProducts(ctx, rdb, map[int]float64{7: 1}); err != nil {
+		t.Fatalf("Failed to seed ranking: %v", err)
+	}
+	mock.ExpectQuery("SELECT p.id, p.price, p.cost, p.stock").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(signalColumns))
+
+	if err := ranker.Recompute(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ranked, err := cache.GetFeaturedProducts(ctx, rdb, 10)
+	if err != nil {
+		t.Fatalf("Failed to read ranking: %v", err)
+	}
+	if len(ranked) != 0 {
+		t.Errorf("Expected an empty ranking, got %v", ranked)
+	}
+}
+
+func TestRanker_Score(t *testing.T) {
+	ranker := &Ranker{weights: Weights{Sales: 0.6, Stock: 0.2, Margin: 0.2}}
+
+	tests := []struct {
+		name     string
+		signals  productSignals
+		maxSales int
+		want     float64
+	}{
+		{"no sales anywhere", productSignals{price: 10, cost: 5, stock: 5}, 0, 0.2},
+		{"stock saturates", productSignals{price: 10, cost: 10, stock: 500}, 0, 0.2},
+		{"selling at a loss", productSignals{price: 10, cost: 15, sales: 2}, 4, 0.3},
+		{"no price", productSignals{stock: 10, sales: 4}, 4, 0.8},
+	}
+	for _, tt := range tests {
+		if got := ranker.score(tt.signals, tt.maxSales); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("%s: expected %.2f, got %.4f", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"product-svc/database"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// seedProducts is a small demo catalog. SKUs make the seed idempotent: rerunning it
+// skips products that already exist.
+var seedProducts = []struct {
+	name  string
+	sku   string
+	price float64
+	stock int
+	tags  []string
+}{
+	{"Mechanical Keyboard", "DEMO-KB-001", 89.99, 50, []string{"electronics", "peripherals"}},
+	{"Wireless Mouse", "DEMO-MS-001", 29.99, 120, []string{"electronics", "peripherals"}},
+	{"27-inch Monitor", "DEMO-MN-001", 249.00, 20, []string{"electronics", "displays"}},
+	{"USB-C Hub", "DEMO-HB-001", 39.50, 75, []string{"electronics", "accessories"}},
+	{"Laptop Stand", "DEMO-ST-001", 45.00, 8, []string{"accessories", "office"}},
+}
+
+// seed inserts the demo catalog into an already migrated database
+func seed(logger *zap.Logger) error {
+	db, err := database.Open(logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	inserted := 0
+	for _, p := range seedProducts {
+		res, err := db.Exec(
+			"INSERT INTO products (name, sku, price, stock, tags) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (sku) DO NOTHING",
+			p.name, p.sku, p.price, p.stock, pq.Array(p.tags),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed product %s: %w", p.sku, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+
+	logger.Info("Demo products seeded",
+		zap.Int("inserted", inserted),
+		zap.Int("skipped", len(seedProducts)-inserted),
+	)
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"product-svc/cache"
+	"product-svc/database"
+	"product-svc/handlers"
+	"product-svc/kafka"
+	"product-svc/lock"
+	"product-svc/middleware"
+	product "product-svc/proto"
+	"product-svc/ranking"
+	"product-svc/storage"
+
+	"net"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// serve runs the REST and gRPC APIs, the sales consumer and the featured ranking job
+// until SIGINT/SIGTERM
+func serve(logger *zap.Logger) error {
+	if err := middleware.CheckJWTSecret(); err != nil {
+		logger.Fatal("Invalid auth configuration", zap.Error(err))
+	}
+
+	// Initialize database
+	db, err := database.InitDB(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Optional read replica for read-only queries; reads fall back to the primary while it is down
+	replica, err := database.OpenReplica(logger, db)
+	if err != nil {
+		logger.Fatal("Failed to initialize read replica", zap.Error(err))
+	}
+	if replica != nil {
+		defer replica.Close()
+	}
+
+	// Initialize Redis cache
+	redisClient, err := cache.InitRedis(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Redis", zap.Error(err))
+	}
+	defer redisClient.Close()
+
+	// Initialize S3-compatible image storage
+	imageStorage, err := storage.InitStorage(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize image storage", zap.Error(err))
+	}
+
+	// Initialize Kafka consumer (sales analytics for the featured ranking, stock releases)
+	consumerGroup, err := kafka.InitConsumer(logger, false)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
+	}
+	// Closed once by gracefulShutdown, after the background workers are cancelled
+
+	// Initialize Kafka producer (low-stock alerts)
+	producer, err := kafka.InitProducer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
+	}
+	defer producer.Close()
+	lowStock := kafka.NewLowStockPublisher(producer, logger)
+	productEvents := kafka.NewProductEventPublisher(producer, logger)
+
+	// Short-lived stock cache for CheckAvailability, shared so REST stock edits invalidate it
+	availability := cache.NewAvailabilityCache()
+
+	// Invalidation consumer: product_updated/product_deleted from every replica, this one included
+	invalidationConsumer, err := kafka.InitInvalidationConsumer(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka invalidation consumer", zap.Error(err))
+	}
+	defer invalidationConsumer.Close()
+
+	// Initialize OpenTelemetry
+	shutdownTracing, err := middleware.InitTracing("product-service")
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+
+	locker := lock.NewLocker(redisClient, logger)
+	productService := handlers.NewProductService(db, replica, redisClient, lowStock, availability, productEvents, locker, logger)
+
+	// Start background workers: sales consumer, invalidation consumer and featured ranking job
+	backgroundCtx, backgroundCancel := context.WithCancel(context.Background())
+	go func() {
+		if err := kafka.StartConsumer(backgroundCtx, consumerGroup, db, productService.Release, logger); err != nil {
+			logger.Error("Kafka consumer stopped", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		if err := kafka.StartInvalidationConsumer(backgroundCtx, invalidationConsumer, availability.Invalidate, logger); err != nil {
+			logger.Error("Kafka invalidation consumer stopped", zap.Error(err))
+		}
+	}()
+
+	if replica != nil {
+		go replica.Monitor(backgroundCtx)
+	}
+
+	ranker := ranking.NewRanker(db, redisClient, locker, logger)
+	go ranker.Start(backgroundCtx)
+
+	// Setup Gin router
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// OpenTelemetry middleware must be first to extract trace context
+	router.Use(otelgin.Middleware("product-service"))
+	router.Use(middleware.LoggerMiddleware(logger))
+	router.Use(middleware.MetricsMiddleware())
+
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// Metrics endpoint
+	router.GET("/metrics", middleware.PrometheusHandler())
+
+	// Product endpoints
+	productHandler := handlers.NewProductHandler(db, replica, redisClient, imageStorage, lowStock, availability, productEvents, locker, logger)
+	router.GET("/api/v1/products", productHandler.GetProducts)
+	router.GET("/api/v1/products/featured", productHandler.GetFeaturedProducts)
+	router.GET("/api/v1/products/:id", middleware.AdminCacheBypass(), productHandler.GetProduct)
+	router.GET("/api/v1/products/sku/:sku", productHandler.GetProductBySKU)
+	router.GET("/api/v1/products/:id/price-history", productHandler.GetPriceHistory)
+	router.GET("/api/v1/products/:id/variants", productHandler.ListVariants)
+	router.GET("/api/v1/products/:id/reviews", middleware.AdminCacheBypass(), productHandler.GetReviews)
+
+	// Any signed-in user can review a product
+	router.POST("/api/v1/products/:id/reviews", middleware.AuthMiddleware(), productHandler.CreateReview)
+
+	// Catalog changes require a user-service token with the admin role; reads stay public
+	admin := router.Group("/api/v1/products")
+	admin.Use(middleware.AuthMiddleware(), middleware.RequireRole(middleware.RoleAdmin))
+	{
+		admin.POST("", productHandler.CreateProduct)
+		admin.PUT("/:id", productHandler.UpdateProduct)
+		admin.DELETE("/:id", productHandler.DeleteProduct)
+		admin.POST("/:id/images", productHandler.UploadProductImage)
+		admin.POST("/:id/variants", productHandler.CreateVariant)
+		admin.PUT("/:id/variants/:variant_id", productHandler.UpdateVariant)
+		admin.DELETE("/:id/variants/:variant_id", productHandler.DeleteVariant)
+
+		admin.POST("/:id/stock-adjustments", productHandler.AdjustStock)
+	}
+
+	// Start server
+	restSrv := &http.Server{
+		Addr:    ":8081",
+		Handler: router,
+	}
+
+	// Graceful shutdown
+	go func() {
+		if err := restSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Product Service REST API started on :8081")
+
+	// Start gRPC server
+	grpcListener, err := net.Listen("tcp", ":50052")
+	if err != nil {
+		logger.Fatal("Failed to listen on gRPC port", zap.Error(err))
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		middleware.GRPCServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+		middleware.GRPCStreamServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+	)
+	product.RegisterProductServiceServer(grpcServer, productService)
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Product Service gRPC server started on :50052")
+
+	// Call graceful shutdown function
+	gracefulShutdown(restSrv, grpcServer, backgroundCancel, consumerGroup, producer, db, redisClient, shutdownTracing, logger)
+	return nil
+}
+
+// gracefulShutdown handles SIGINT/SIGTERM and shuts down all services gracefully
+func gracefulShutdown(
+	restSrv *http.Server,
+	grpcServer *grpc.Server,
+	backgroundCancel context.CancelFunc,
+	consumerGroup sarama.ConsumerGroup,
+	producer sarama.SyncProducer,
+	db *sql.DB,
+	redisClient *redis.Client,
+	shutdownTracing func(),
+	logger *zap.Logger,
+) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutdown signal received. Exiting...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Stop REST server
+	if err := restSrv.Shutdown(ctx); err != nil {
+		logger.Error("REST server forced to shutdown", zap.Error(err))
+	} else {
+		logger.Info("REST server stopped gracefully")
+	}
+
+	// Stop gRPC server
+	grpcServer.GracefulStop()
+	logger.Info("gRPC server stopped gracefully")
+
+	// Stop background workers and Kafka consumer
+	backgroundCancel()
+	if err := consumerGroup.Close(); err != nil {
+		logger.Error("Failed to close Kafka consumer", zap.Error(err))
+	} else {
+		logger.Info("Kafka consumer stopped gracefully")
+	}
+
+	// Close Kafka producer
+	if err := producer.Close(); err != nil {
+		logger.Error("Failed to close Kafka producer", zap.Error(err))
+	} else {
+		logger.Info("Kafka producer stopped gracefully")
+	}
+
+	// Close database
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database", zap.Error(err))
+	} else {
+		logger.Info("Database connection closed gracefully")
+	}
+
+	// Close Redis cache
+	if err := redisClient.Close(); err != nil {
+		logger.Error("Failed to close Redis cache", zap.Error(err))
+	} else {
+		logger.Info("Redis cache closed gracefully")
+	}
+
+	// Shutdown tracing
+	shutdownTracing()
+	logger.Info("Product Service exited gracefully")
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"product-svc/startup"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+)
+
+// uploadPartSize bounds the memory used per upload when the size is not known up front
+const uploadPartSize = 5 << 20
+
+// Storage stores product images in an S3-compatible bucket (AWS S3, MinIO, ...)
+type Storage struct {
+	client    *minio.Client
+	bucket    string
+	urlExpiry time.Duration
+}
+
+func InitStorage(logger *zap.Logger) (*Storage, error) {
+	endpoint := getEnv("S3_ENDPOINT", "localhost:9000")
+	accessKey := getEnv("S3_ACCESS_KEY", "minioadmin")
+	secretKey := getEnv("S3_SECRET_KEY", "minioadmin")
+	bucket := getEnv("S3_BUCKET", "product-images")
+	region := getEnv("S3_REGION", "us-east-1")
+	useSSL, _ := strconv.ParseBool(getEnv("S3_USE_SSL", "false"))
+
+	urlExpiry, err := time.ParseDuration(getEnv("S3_URL_EXPIRY", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_URL_EXPIRY: %w", err)
+	}
+
+	// Region is set explicitly so presigning never needs a bucket-location round trip
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	var exists bool
+	err = startup.Wait(logger, "s3", func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		exists, err = client.BucketExists(ctx, bucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to S3: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+		logger.Info("S3 bucket created", zap.String("bucket", bucket))
+	}
+
+	logger.Info("S3 storage initialized", zap.String("endpoint", endpoint), zap.String("bucket", bucket))
+	return &Storage{
+		client:    client,
+		bucket:    bucket,
+		urlExpiry: urlExpiry,
+	}, nil
+}
+
+// Upload streams r to the bucket under key. size may be -1 if unknown.
+func (s *Storage) Upload(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    uploadPartSize,
+	})
+	return err
+}
+
+// Delete removes the object stored under key
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// SignedURL returns a time-limited GET URL for the object stored under key
+func (s *Storage) SignedURL(ctx context.Context, key string) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.urlExpiry, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -91,9 +91,18 @@ echo ""
 
 echo -e "${BLUE}Step 5: Create Products${NC}"
 echo "----------------------------------------"
+# Product mutations need an admin JWT; the account comes from `user-service seed`
+echo "Logging in as admin..."
+ADMIN_TOKEN=$(curl -s -X POST http://localhost:8080/login \
+  -H "Content-Type: application/json" \
+  -d '{
+    "email": "admin@mini-shop.local",
+    "password": "demo-password"
+  }' | jq -r '.token // empty')
 echo "Creating Product 1: Laptop..."
 PRODUCT1_RESPONSE=$(curl -s -X POST http://localhost:8081/products \
   -H "Content-Type: application/json" \
+  -H "Authorization: Bearer $ADMIN_TOKEN" \
   -d '{
     "name": "MacBook Pro",
     "price": 1999.99,
@@ -106,6 +115,7 @@ echo "$PRODUCT1_RESPONSE" | jq
 echo "Creating Product 2: Mouse..."
 PRODUCT2_RESPONSE=$(curl -s -X POST http://localhost:8081/products \
   -H "Content-Type: application/json" \
+  -H "Authorization: Bearer $ADMIN_TOKEN" \
   -d '{
     "name": "Wireless Mouse",
     "price": 29.99,
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"user-svc/database"
+	"user-svc/kafka"
+	"user-svc/middleware"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newConsumeCmd() *cobra.Command {
+	var replay bool
+	cmd := &cobra.Command{
+		Use:   "consume",
+		Short: "Run only the Kafka consumer that folds payment outcomes into risk scores",
+		Args:  cobra.NoArgs,
+		RunE: withLogger(func(logger *zap.Logger) error {
+			return consume(logger, replay)
+		}),
+	}
+	cmd.Flags().BoolVar(&replay, "replay", false, "consume every retained event in a throwaway group; payments already scored are skipped")
+	return cmd
+}
+
+// consume runs the risk-score consumer without the APIs until SIGINT/SIGTERM
+func consume(logger *zap.Logger, replay bool) error {
+	db, err := database.InitDB(logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	consumerGroup, err := kafka.InitConsumer(logger, replay)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	shutdownTracing, err := middleware.InitTracing("user-service")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return kafka.StartConsumer(ctx, consumerGroup, db, logger)
+}
@@ -4,13 +4,37 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"time"
+
+	"user-svc/region"
+	"user-svc/startup"
 
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
+// InitDB opens the database and applies pending migrations, as the service does on startup
 func InitDB(logger *zap.Logger) (*sql.DB, error) {
+	db, err := Open(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrateOnStart {
+		if err := Migrate(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else {
+		logger.Info("Skipping database migrations on start", zap.String("MIGRATE_ON_START", "false"))
+	}
+
+	logger.Info("Database connection established")
+	return db, nil
+}
+
+// Open connects to the database without touching the schema, waiting for it to
+// accept connections
+func Open(logger *zap.Logger) (*sql.DB, error) {
 	host := getEnv("DB_HOST", "localhost")
 	port := getEnv("DB_PORT", "5432")
 	user := getEnv("DB_USER", "postgres")
@@ -25,33 +49,29 @@ func InitDB(logger *zap.Logger) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(25)                 // max number of open connections
-	db.SetMaxIdleConns(10)                 // max number of idle connections
-	db.SetConnMaxLifetime(5 * time.Minute) // how long a connection can be reused
-	db.SetConnMaxIdleTime(1 * time.Minute) // how long an idle connection stays in pool
+	configurePool(db, dbname)
 
-	if err := db.Ping(); err != nil {
+	if err := startup.Wait(logger, "postgres", db.Ping); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Create users table if it doesn't exist
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password_hash VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	if _, err := db.Exec(createTableQuery); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+	return db, nil
+}
+
+// Migrate applies the pending migrations in migrations/. It runs on each start unless
+// MIGRATE_ON_START is false, and from the migrate command.
+func Migrate(db *sql.DB) error {
+	if err := applyMigrations(db); err != nil {
+		return err
+	}
+
+	// Users registered before region tagging belong to this deployment's home region
+	if _, err := db.Exec("UPDATE users SET region = $1 WHERE region IS NULL", region.Load().Home); err != nil {
+		return fmt.Errorf("failed to backfill user regions: %w", err)
 	}
 
-	logger.Info("Database connection established")
-	return db, nil
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS processed_payment_events;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,33 @@
+-- Baseline: the schema as services created it on startup before versioned migrations.
+-- Every statement is idempotent, so databases created that way adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) UNIQUE NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Notification preferences: quiet hours are local "HH:MM" times in the user's timezone
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quiet_hours_start VARCHAR(5);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quiet_hours_end VARCHAR(5);
+
+-- Payment outcomes from payment-service events, folded into a risk score
+ALTER TABLE users ADD COLUMN IF NOT EXISTS payment_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS payment_successes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Payment events already counted, so redelivered events don't skew the score
+CREATE TABLE IF NOT EXISTS processed_payment_events (
+	payment_id INTEGER PRIMARY KEY,
+	processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Data residency region the account is stored for
+ALTER TABLE users ADD COLUMN IF NOT EXISTS region VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_users_region ON users (region);
+
+-- Role carried in login tokens; admins may change the product catalog
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer';
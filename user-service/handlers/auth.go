@@ -7,6 +7,7 @@ import (
 
 	"user-svc/middleware"
 	"user-svc/models"
+	"user-svc/region"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,16 +16,16 @@ import (
 )
 
 type AuthHandler struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db      *sql.DB
+	regions region.Config
+	logger  *zap.Logger
 }
 
-var jwtSecret = []byte("your-secret-key-change-in-production")
-
 func NewAuthHandler(db *sql.DB, logger *zap.Logger) *AuthHandler {
 	return &AuthHandler{
-		db:     db,
-		logger: logger,
+		db:      db,
+		regions: region.Load(),
+		logger:  logger,
 	}
 }
 
@@ -45,9 +46,16 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// X-Region pins where the account is stored; the home region is used otherwise
+	userRegion, err := h.regions.Resolve(c.GetHeader("X-Region"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_regions": h.regions.Allowed})
+		return
+	}
+
 	// Check if user already exists
 	var existingID int
-	err := h.db.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&existingID)
+	err = h.db.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&existingID)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
@@ -70,9 +78,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	// Insert user
 	var user models.User
 	err = h.db.QueryRow(
-		"INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3) RETURNING id, name, email, created_at",
-		name, req.Email, string(hashedPassword),
-	).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+		"INSERT INTO users (name, email, password_hash, region) VALUES ($1, $2, $3, $4) RETURNING id, name, email, region, created_at",
+		name, req.Email, string(hashedPassword), userRegion,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Region, &user.CreatedAt)
 	if err != nil {
 		traceID := middleware.GetTraceID(c.Request.Context())
 		h.logger.Error("Failed to create user", zap.String("trace_id", traceID), zap.Error(err))
@@ -95,9 +103,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Get user from database
 	var user models.User
 	err := h.db.QueryRow(
-		"SELECT id, name, email, password_hash, created_at FROM users WHERE email = $1",
+		"SELECT id, name, email, password_hash, role, created_at FROM users WHERE email = $1",
 		req.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
@@ -119,10 +127,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
+		"role":    user.Role,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(),
 	})
 
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(middleware.JWTSecret)
 	if err != nil {
 		traceID := middleware.GetTraceID(c.Request.Context())
 		h.logger.Error("Failed to generate token", zap.String("trace_id", traceID), zap.Error(err))
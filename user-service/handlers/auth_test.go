@@ -46,9 +46,9 @@ func TestAuthHandler_Register_Success(t *testing.T) {
 
 	// Mock: Insert user
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs("testuser", "test@example.com", sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at"}).
-			AddRow(1, "testuser", "test@example.com", time.Now()))
+		WithArgs("testuser", "test@example.com", sqlmock.AnyArg(), "us-east-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "region", "created_at"}).
+			AddRow(1, "testuser", "test@example.com", "us-east-1", time.Now()))
 
 	reqBody := models.RegisterRequest{
 		Username: "testuser",
@@ -72,6 +72,33 @@ func TestAuthHandler_Register_Success(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_Register_RegionNotServed(t *testing.T) {
+	handler, mock, router := setupAuthTest(t)
+	defer handler.db.Close()
+
+	reqBody := models.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Region", "ap-south-1")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected database calls: %v", err)
+	}
+}
+
 func TestAuthHandler_Register_UserExists(t *testing.T) {
 	handler, mock, router := setupAuthTest(t)
 	defer handler.db.Close()
@@ -141,10 +168,10 @@ func TestAuthHandler_Login_Success(t *testing.T) {
 
 	// Mock: Get user from database
 	hashedPassword, _ := hashPassword("password123")
-	mock.ExpectQuery("SELECT id, name, email, password_hash, created_at FROM users WHERE email = \\$1").
+	mock.ExpectQuery("SELECT id, name, email, password_hash, role, created_at FROM users WHERE email = \\$1").
 		WithArgs("test@example.com").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "created_at"}).
-			AddRow(1, "testuser", "test@example.com", hashedPassword, time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "role", "created_at"}).
+			AddRow(1, "testuser", "test@example.com", hashedPassword, "customer", time.Now()))
 
 	reqBody := models.LoginRequest{
 		Email:    "test@example.com",
@@ -172,7 +199,7 @@ func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
 	defer handler.db.Close()
 
 	// Mock: User not found
-	mock.ExpectQuery("SELECT id, name, email, password_hash, created_at FROM users WHERE email = \\$1").
+	mock.ExpectQuery("SELECT id, name, email, password_hash, role, created_at FROM users WHERE email = \\$1").
 		WithArgs("test@example.com").
 		WillReturnError(sql.ErrNoRows)
 
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	user "user-svc/proto"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type UserService struct {
+	user.UnimplementedUserServiceServer
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewUserService(db *sql.DB, logger *zap.Logger) *UserService {
+	return &UserService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetRiskScore returns the payment-history risk score maintained by the Kafka consumer
+func (s *UserService) GetRiskScore(ctx context.Context, req *user.GetRiskScoreRequest) (*user.GetRiskScoreResponse, error) {
+	ctx, span := otel.Tracer("user-service").Start(ctx, "GetRiskScore_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", int(req.GetUserId())))
+
+	resp := &user.GetRiskScoreResponse{UserId: req.GetUserId()}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT risk_score, payment_failures, payment_successes FROM users WHERE id = $1",
+		req.GetUserId(),
+	).Scan(&resp.RiskScore, &resp.PaymentFailures, &resp.PaymentSuccesses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to load risk score", zap.Int32("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to load risk score")
+	}
+
+	span.SetAttributes(attribute.Float64("user.risk_score", resp.RiskScore))
+	return resp, nil
+}
+
+// GetUser returns the account with the given ID, so other services can check a user
+// exists before acting for them
+func (s *UserService) GetUser(ctx context.Context, req *user.GetUserRequest) (*user.GetUserResponse, error) {
+	ctx, span := otel.Tracer("user-service").Start(ctx, "GetUser_gRPC")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("user.id", int(req.GetUserId())))
+
+	resp := &user.GetUserResponse{UserId: req.GetUserId()}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT name, email, role, COALESCE(region, '') FROM users WHERE id = $1",
+		req.GetUserId(),
+	).Scan(&resp.Name, &resp.Email, &resp.Role, &resp.Region)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("Failed to load user", zap.Int32("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to load user")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	user "user-svc/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func setupUserServiceTest(t *testing.T) (*UserService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+
+	logger := zaptest.NewLogger(t, zaptest.Level(zap.InfoLevel))
+	return NewUserService(db, logger), mock
+}
+
+func TestUserService_GetRiskScore_Success(t *testing.T) {
+	service, mock := setupUserServiceTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("SELECT risk_score, payment_failures, payment_successes FROM users WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"risk_score", "payment_failures", "payment_successes"}).
+			AddRow(0.25, 3, 4))
+
+	resp, err := service.GetRiskScore(context.Background(), &user.GetRiskScoreRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetRiskScore() != 0.25 || resp.GetPaymentFailures() != 3 || resp.GetPaymentSuccesses() != 4 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestUserService_GetRiskScore_NotFound(t *testing.T) {
+	service, mock := setupUserServiceTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("SELECT risk_score, payment_failures, payment_successes FROM users WHERE id = \\$1").
+		WithArgs(int32(999)).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := service.GetRiskScore(context.Background(), &user.GetRiskScoreRequest{UserId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestUserService_GetUser(t *testing.T) {
+	service, mock := setupUserServiceTest(t)
+	defer service.db.Close()
+
+	mock.ExpectQuery("SELECT name, email, role, COALESCE\\(region, ''\\) FROM users WHERE id = \\$1").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "email", "role", "region"}).
+			AddRow("Ada", "ada@example.com", "customer", "eu-west-1"))
+	mock.ExpectQuery("SELECT name, email, role, COALESCE\\(region, ''\\) FROM users WHERE id = \\$1").
+		WithArgs(int32(999)).
+		WillReturnError(sql.ErrNoRows)
+
+	resp, err := service.GetUser(context.Background(), &user.GetUserRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.GetUserId() != 1 || resp.GetEmail() != "ada@example.com" || resp.GetRegion() != "eu-west-1" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	_, err = service.GetUser(context.Background(), &user.GetUserRequest{UserId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
@@ -1,14 +1,44 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// readinessTimeout bounds how long /readyz waits on the database ping
+const readinessTimeout = 2 * time.Second
+
 func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "healthy",
 		"service": "user-service",
 	})
 }
+
+// ReadinessCheck reports ready only when Postgres answers a ping, so
+// orchestrators stop routing traffic to an instance with a broken database.
+func ReadinessCheck(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := db.PingContext(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":   "unavailable",
+				"service":  "user-service",
+				"database": "unreachable",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":   "ready",
+			"service":  "user-service",
+			"database": "ok",
+		})
+	}
+}
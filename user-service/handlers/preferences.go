@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-svc/middleware"
+	"user-svc/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// quietHoursLayout is the local time-of-day format for quiet hours
+const quietHoursLayout = "15:04"
+
+type PreferencesHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewPreferencesHandler(db *sql.DB, logger *zap.Logger) *PreferencesHandler {
+	return &PreferencesHandler{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetMyPreferences returns the notification preferences of the authenticated user
+func (h *PreferencesHandler) GetMyPreferences(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	h.respondWithPreferences(c, userID)
+}
+
+// GetUserPreferences returns a user's notification preferences for internal callers
+// such as notification-service. It is not routed under the JWT-protected group.
+func (h *PreferencesHandler) GetUserPreferences(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	h.respondWithPreferences(c, userID)
+}
+
+// UpdateMyPreferences replaces the timezone and quiet hours of the authenticated user
+func (h *PreferencesHandler) UpdateMyPreferences(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+	if (req.QuietHoursStart == nil) != (req.QuietHoursEnd == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quiet_hours_start and quiet_hours_end must be set together"})
+		return
+	}
+	for _, t := range []*string{req.QuietHoursStart, req.QuietHoursEnd} {
+		if t == nil {
+			continue
+		}
+		if _, err := time.Parse(quietHoursLayout, *t); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quiet hours must use HH:MM format"})
+			return
+		}
+	}
+
+	prefs := models.NotificationPreferences{UserID: userID}
+	err := h.db.QueryRowContext(c.Request.Context(),
+		"UPDATE users SET timezone = $1, quiet_hours_start = $2, quiet_hours_end = $3 WHERE id = $4 RETURNING timezone, quiet_hours_start, quiet_hours_end",
+		req.Timezone, req.QuietHoursStart, req.QuietHoursEnd, userID,
+	).Scan(&prefs.Timezone, &prefs.QuietHoursStart, &prefs.QuietHoursEnd)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		traceID := middleware.GetTraceID(c.Request.Context())
+		h.logger.Error("Failed to update notification preferences", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.logger.Info("Notification preferences updated",
+		zap.Int("user_id", userID),
+		zap.String("timezone", prefs.Timezone),
+	)
+	c.JSON(http.StatusOK, prefs)
+}
+
+func (h *PreferencesHandler) respondWithPreferences(c *gin.Context, userID int) {
+	prefs := models.NotificationPreferences{UserID: userID}
+	err := h.db.QueryRowContext(c.Request.Context(),
+		"SELECT timezone, quiet_hours_start, quiet_hours_end FROM users WHERE id = $1",
+		userID,
+	).Scan(&prefs.Timezone, &prefs.QuietHoursStart, &prefs.QuietHoursEnd)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		traceID := middleware.GetTraceID(c.Request.Context())
+		h.logger.Error("Failed to fetch notification preferences", zap.String("trace_id", traceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// authenticatedUserID reads the user ID set by AuthMiddleware. JWT numeric claims decode as float64.
+func authenticatedUserID(c *gin.Context) (int, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := value.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(id), true
+}
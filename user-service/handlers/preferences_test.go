@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"user-svc/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func setupPreferencesTest(t *testing.T) (*PreferencesHandler, sqlmock.Sqlmock, *gin.Engine) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+
+	logger := zaptest.NewLogger(t, zaptest.Level(zap.InfoLevel))
+	handler := NewPreferencesHandler(db, logger)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// Stand in for AuthMiddleware, which stores the JWT user_id claim as float64
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", float64(1))
+		c.Next()
+	})
+	router.GET("/preferences", handler.GetMyPreferences)
+	router.PUT("/preferences", handler.UpdateMyPreferences)
+	router.GET("/internal/users/:id/notification-preferences", handler.GetUserPreferences)
+
+	return handler, mock, router
+}
+
+func TestPreferencesHandler_GetUserPreferences_Success(t *testing.T) {
+	handler, mock, router := setupPreferencesTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT timezone, quiet_hours_start, quiet_hours_end FROM users WHERE id = \\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"timezone", "quiet_hours_start", "quiet_hours_end"}).
+			AddRow("Asia/Dhaka", "22:00", "07:00"))
+
+	req := httptest.NewRequest("GET", "/internal/users/7/notification-preferences", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var prefs models.NotificationPreferences
+	if err := json.Unmarshal(w.Body.Bytes(), &prefs); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if prefs.UserID != 7 || prefs.Timezone != "Asia/Dhaka" || prefs.QuietHoursStart == nil || *prefs.QuietHoursStart != "22:00" {
+		t.Errorf("Unexpected preferences: %+v", prefs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPreferencesHandler_GetMyPreferences_NotFound(t *testing.T) {
+	handler, mock, router := setupPreferencesTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("SELECT timezone, quiet_hours_start, quiet_hours_end FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/preferences", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestPreferencesHandler_UpdateMyPreferences_Success(t *testing.T) {
+	handler, mock, router := setupPreferencesTest(t)
+	defer handler.db.Close()
+
+	mock.ExpectQuery("UPDATE users SET timezone = \\$1, quiet_hours_start = \\$2, quiet_hours_end = \\$3 WHERE id = \\$4").
+		WithArgs("Europe/Berlin", "22:00", "07:30", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"timezone", "quiet_hours_start", "quiet_hours_end"}).
+			AddRow("Europe/Berlin", "22:00", "07:30"))
+
+	body := []byte(`{"timezone": "Europe/Berlin", "quiet_hours_start": "22:00", "quiet_hours_end": "07:30"}`)
+	req := httptest.NewRequest("PUT", "/preferences", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestPreferencesHandler_UpdateMyPreferences_Invalid(t *testing.T) {
+	handler, _, router := setupPreferencesTest(t)
+	defer handler.db.Close()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown timezone", `{"timezone": "Mars/Olympus"}`},
+		{"bad time format", `{"timezone": "UTC", "quiet_hours_start": "10pm", "quiet_hours_end": "07:00"}`},
+		{"only start set", `{"timezone": "UTC", "quiet_hours_start": "22:00"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/preferences", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
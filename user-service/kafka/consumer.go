@@ -0,0 +1,245 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"user-svc/startup"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// riskPriorWeight damps the score for users with few payments, so one failed
+// payment from a new customer doesn't read as maximum risk
+const riskPriorWeight = 5
+
+// paymentEvent is the subset of the payment-service event used for risk scoring
+type paymentEvent struct {
+	EventType string `json:"event_type"`
+	PaymentID int    `json:"payment_id"`
+	OrderID   int    `json:"order_id"`
+	UserID    int    `json:"user_id"`
+}
+
+// InitConsumer joins the risk-score consumer group. With replay it joins a fresh,
+// throwaway group instead, which starts from the oldest retained event without moving
+// the live group's committed offsets.
+func InitConsumer(logger *zap.Logger, replay bool) (sarama.ConsumerGroup, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+	// Skip payment events from aborted payment-service transactions
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+
+	brokers := []string{getEnv("KAFKA_BROKER", "localhost:9092")}
+	groupID := getEnv("KAFKA_CONSUMER_GROUP", "user-service")
+	if replay {
+		groupID = fmt.Sprintf("%s-replay-%d", groupID, time.Now().Unix())
+	}
+
+	var consumerGroup sarama.ConsumerGroup
+	err := startup.Wait(logger, "kafka", func() (err error) {
+		consumerGroup, err = sarama.NewConsumerGroup(brokers, groupID, config)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
+	}
+
+	logger.Info("Kafka consumer group initialized",
+		zap.Strings("brokers", brokers),
+		zap.String("group_id", groupID),
+	)
+
+	return consumerGroup, nil
+}
+
+// StartConsumer folds payment_success/payment_failed events into each user's risk score
+func StartConsumer(ctx context.Context, consumerGroup sarama.ConsumerGroup, db *sql.DB, logger *zap.Logger) error {
+	topics := []string{getEnv("KAFKA_PAYMENTS_TOPIC", "payments")}
+	handler := &riskConsumerGroupHandler{
+		db:     db,
+		logger: logger,
+	}
+
+	logger.Info("Kafka consumer loop started", zap.Strings("topics", topics))
+
+	// Handle errors in a separate goroutine
+	go func() {
+		for err := range consumerGroup.Errors() {
+			logger.Error("Kafka consumer group error", zap.Error(err))
+		}
+	}()
+
+	for {
+		if err := consumerGroup.Consume(ctx, topics, handler); err != nil {
+			return fmt.Errorf("failed to consume topic: %w", err)
+		}
+
+		if ctx.Err() != nil {
+			logger.Info("Kafka consumer context cancelled")
+			return nil
+		}
+	}
+}
+
+type riskConsumerGroupHandler struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func (h *riskConsumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (h *riskConsumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (h *riskConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		if err := handleMessage(message, h.db, h.logger); err != nil {
+			h.logger.Error("Failed to handle message", zap.Error(err))
+		} else {
+			session.MarkMessage(message, "")
+		}
+	}
+
+	return nil
+}
+
+func handleMessage(message *sarama.ConsumerMessage, db *sql.DB, logger *zap.Logger) error {
+	// Extract trace context from Kafka message headers
+	var propagator propagation.TextMapPropagator = otel.GetTextMapPropagator()
+	carrier := saramaHeaderCarrierConsumer(message.Headers)
+	ctx := propagator.Extract(context.Background(), carrier)
+
+	header, err := readEventHeader(message.Value)
+	if err != nil {
+		return err
+	}
+
+	var failures, successes int
+	switch header.EventType {
+	case "payment_failed":
+		failures = 1
+	case "payment_success":
+		successes = 1
+	default:
+		// Only payment outcomes affect the risk score
+		return nil
+	}
+
+	// Skipped rather than misread; replay it once this service reads the version
+	if err := header.checkVersion(); err != nil {
+		logger.Error("Skipping payment event", zap.String("event_id", header.EventID), zap.Int("order_id", header.OrderID), zap.Error(err))
+		return nil
+	}
+	var event paymentEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	var tracer trace.Tracer = otel.Tracer("user-service")
+	ctx, span := tracer.Start(ctx, "UpdateRiskScore")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("event.type", event.EventType),
+		attribute.Int("payment.id", event.PaymentID),
+		attribute.Int("user.id", event.UserID),
+	)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO processed_payment_events (payment_id) VALUES ($1) ON CONFLICT (payment_id) DO NOTHING",
+		event.PaymentID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to record payment event: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		logger.Debug("Payment event already counted", zap.Int("payment_id", event.PaymentID))
+		return nil
+	}
+
+	// SET expressions see the old counters, so the score is computed from the incremented values
+	var riskScore float64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users SET
+			payment_failures = payment_failures + $1,
+			payment_successes = payment_successes + $2,
+			risk_score = (payment_failures + $1)::float8 / (payment_failures + payment_successes + $1 + $2 + $3)
+		WHERE id = $4
+		RETURNING risk_score`,
+		failures, successes, riskPriorWeight, event.UserID,
+	).Scan(&riskScore)
+	if err == sql.ErrNoRows {
+		// Unknown user (e.g. orders placed with a made-up user_id); keep the event marked as processed
+		logger.Warn("Payment event for unknown user", zap.Int("user_id", event.UserID))
+		return tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update risk score: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to commit risk score: %w", err)
+	}
+
+	span.SetAttributes(attribute.Float64("user.risk_score", riskScore))
+	logger.Info("User risk score updated",
+		zap.Int("user_id", event.UserID),
+		zap.Int("payment_id", event.PaymentID),
+		zap.String("event_type", event.EventType),
+		zap.Float64("risk_score", riskScore),
+	)
+
+	return nil
+}
+
+// saramaHeaderCarrierConsumer implements the TextMapCarrier interface for Kafka headers (for consumer)
+type saramaHeaderCarrierConsumer []*sarama.RecordHeader
+
+func (c saramaHeaderCarrierConsumer) Get(key string) string {
+	for _, h := range c {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c saramaHeaderCarrierConsumer) Set(key, value string) {
+	// Not needed for extraction
+}
+
+func (c saramaHeaderCarrierConsumer) Keys() []string {
+	keys := make([]string, len(c))
+	for i, h := range c {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
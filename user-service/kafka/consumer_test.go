@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+func paymentMessage(t *testing.T, eventType string, paymentID, userID int) *sarama.ConsumerMessage {
+	t.Helper()
+	value, err := json.Marshal(map[string]interface{}{
+		"event_id":      "evt-1",
+		"event_version": 1,
+		"event_type":    eventType,
+		"payment_id":    paymentID,
+		"order_id":      42,
+		"user_id":       userID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	return &sarama.ConsumerMessage{Value: value}
+}
+
+func setupConsumerTest(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func TestHandleMessage_RiskScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		failures  int
+		successes int
+	}{
+		{"failed payment", "payment_failed", 1, 0},
+		{"successful payment", "payment_success", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupConsumerTest(t)
+
+			// A first failure for a new user scores (0+1) / (0+0+1+5)
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO processed_payment_events").
+				WithArgs(10).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery("UPDATE users SET").
+				WithArgs(tt.failures, tt.successes, riskPriorWeight, 3).
+				WillReturnRows(sqlmock.NewRows([]string{"risk_score"}).AddRow(float64(tt.failures) / 6))
+			mock.ExpectCommit()
+
+			if err := handleMessage(paymentMessage(t, tt.eventType, 10, 3), db, zap.NewNop()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Database expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleMessage_DuplicateEventLeavesScore(t *testing.T) {
+	db, mock := setupConsumerTest(t)
+
+	// The payment is already in processed_payment_events, so the score isn't touched
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO processed_payment_events").
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := handleMessage(paymentMessage(t, "payment_failed", 10, 3), db, zap.NewNop()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestHandleMessage_MissingUser(t *testing.T) {
+	db, mock := setupConsumerTest(t)
+
+	// The event stays recorded as processed so it isn't retried forever
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO processed_payment_events").
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE users SET").
+		WithArgs(1, 0, riskPriorWeight, 99).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectCommit()
+
+	if err := handleMessage(paymentMessage(t, "payment_failed", 10, 99), db, zap.NewNop()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Database expectations were not met: %v", err)
+	}
+}
+
+func TestHandleMessage_IgnoresOtherEvents(t *testing.T) {
+	db, mock := setupConsumerTest(t)
+
+	for _, message := range []*sarama.ConsumerMessage{
+		paymentMessage(t, "payment_refunded", 10, 3),
+		{Value: []byte(`{"event_version": 2, "event_type": "payment_failed", "payment_id": 10, "user_id": 3}`)},
+	} {
+		if err := handleMessage(message, db, zap.NewNop()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected no database calls: %v", err)
+	}
+}
@@ -1,117 +1,58 @@
 package main
 
 import (
-	"context"
-	"database/sql"
-	"log"
-	"net/http"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
-	"user-svc/database"
-	"user-svc/handlers"
-	"user-svc/middleware"
-
-	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func main() {
-	// Initialize logger
-	logger, err := zap.NewProduction()
-	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer logger.Sync()
-
-	// Initialize database
-	db, err := database.InitDB(logger)
-	if err != nil {
-		logger.Fatal("Failed to initialize database", zap.Error(err))
-	}
-	defer db.Close()
-
-	// Initialize OpenTelemetry
-	shutdownTracing, err := middleware.InitTracing("user-service")
-	if err != nil {
-		logger.Fatal("Failed to initialize tracing", zap.Error(err))
-	}
-
-	defer shutdownTracing()
-
-	// Setup Gin router
-	router := gin.New()
-	router.Use(gin.Recovery())
-	// OpenTelemetry middleware must be first to extract trace context
-	router.Use(otelgin.Middleware("user-service"))
-	router.Use(middleware.LoggerMiddleware(logger))
-	router.Use(middleware.MetricsMiddleware())
-
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-
-	// Metrics endpoint
-	router.GET("/metrics", middleware.PrometheusHandler())
-
-	// Auth endpoints
-	authHandler := handlers.NewAuthHandler(db, logger)
-	router.POST("/api/v1/register", authHandler.Register)
-	router.POST("/api/v1/login", authHandler.Login)
-
-	// Protected endpoints
-	protected := router.Group("/api/v1")
-	protected.Use(middleware.AuthMiddleware())
-	{
-		protected.GET("/profile", handlers.GetProfile)
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
+}
 
-	// Start server
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: router,
+// newRootCmd builds the user-service CLI. Running it without a subcommand serves, so
+// existing deployments and `go run .` keep working.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "user-service",
+		Short:        "User service APIs, risk-score consumer and operational tasks",
+		SilenceUsage: true,
+		RunE:         withLogger(serve),
 	}
 
-	// Graceful shutdown
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Failed to start server", zap.Error(err))
-		}
-	}()
-
-	logger.Info("User Service started on :8080")
-
-	// Call graceful shutdown
-	gracefulShutdown(srv, db, shutdownTracing, logger)
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the REST and gRPC APIs and the risk-score consumer",
+			Args:  cobra.NoArgs,
+			RunE:  withLogger(serve),
+		},
+		newMigrateCmd(),
+		&cobra.Command{
+			Use:   "seed",
+			Short: "Insert demo users, skipping emails that already exist",
+			Args:  cobra.NoArgs,
+			RunE:  withLogger(seed),
+		},
+		newConsumeCmd(),
+	)
+	return root
 }
 
-// gracefulShutdown handles SIGINT/SIGTERM and shuts down all services gracefully
-func gracefulShutdown(srv *http.Server, db *sql.DB, shutdownTracing func(), logger *zap.Logger) {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	logger.Info("Shutdown signal received. Exiting...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Shutdown HTTP server
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Error("HTTP server forced to shutdown", zap.Error(err))
-	} else {
-		logger.Info("HTTP server stopped gracefully")
-	}
+// withLogger gives a command the production logger every subcommand shares. Config is
+// otherwise read from the environment by each package, exactly as when serving.
+func withLogger(run func(logger *zap.Logger) error) func(*cobra.Command, []string) error {
+	return func(_ *cobra.Command, _ []string) error {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer logger.Sync()
 
-	// Close database
-	if err := db.Close(); err != nil {
-		logger.Error("Failed to close database", zap.Error(err))
-	} else {
-		logger.Info("Database connection closed gracefully")
+		return run(logger)
 	}
-
-	// Shutdown tracing
-	shutdownTracing()
-	logger.Info("User Service exited gracefully")
 }
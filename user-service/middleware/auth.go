@@ -1,14 +1,43 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("cc049477996be5a7631c4a157051075d")
+// JWTSecret signs and verifies user tokens. Other services verifying these tokens must
+// be given the same JWT_SECRET.
+var JWTSecret = []byte(jwtSecretFromEnv())
+
+// devJWTSecret is the key used without JWT_SECRET in DEV_MODE, the one docker-compose sets
+const devJWTSecret = "dev-jwt-secret"
+
+// errNoJWTSecret rejects every token while no key is configured
+var errNoJWTSecret = errors.New("JWT_SECRET is not set; set it, or DEV_MODE=true to use a development key")
+
+func jwtSecretFromEnv() string {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return secret
+	}
+	if DevMode() {
+		return devJWTSecret
+	}
+	return ""
+}
+
+// CheckJWTSecret fails when JWT_SECRET is unset outside DEV_MODE. serve exits on it rather
+// than sign and verify tokens with a key anyone could guess.
+func CheckJWTSecret() error {
+	if len(JWTSecret) == 0 {
+		return errNoJWTSecret
+	}
+	return nil
+}
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -31,7 +60,10 @@ func AuthMiddleware() gin.HandlerFunc {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, jwt.ErrSignatureInvalid
 			}
-			return jwtSecret, nil
+			if len(JWTSecret) == 0 {
+				return nil, errNoJWTSecret
+			}
+			return JWTSecret, nil
 		})
 
 		if err != nil || !token.Valid {
@@ -49,6 +81,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims["user_id"])
 		c.Set("email", claims["email"])
+		c.Set("role", claims["role"])
 		c.Next()
 	}
 }
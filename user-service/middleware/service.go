@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceTokenHTTPHeader carries the shared token other services send on internal HTTP calls
+const ServiceTokenHTTPHeader = "X-Service-Token"
+
+// ServiceTokenMiddleware guards internal endpoints with the shared service token. Without a
+// configured token every request is refused, so the endpoints are never left open.
+func ServiceTokenMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(ServiceTokenHTTPHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServiceTokenMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		configured string
+		provided   string
+		want       int
+	}{
+		{"matching token", "secret", "secret", http.StatusOK},
+		{"wrong token", "secret", "other", http.StatusUnauthorized},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"unconfigured", "", "", http.StatusUnauthorized},
+		{"unconfigured with a token", "", "secret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/internal", ServiceTokenMiddleware(tt.configured), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+			if tt.provided != "" {
+				req.Header.Set(ServiceTokenHTTPHeader, tt.provided)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
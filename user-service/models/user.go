@@ -7,9 +7,17 @@ type User struct {
 	Name         string    `json:"name"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Region       string    `json:"region"`
+	Role         string    `json:"role,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// Roles carried in the JWT "role" claim. Admins may change the product catalog.
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"-"`
 	Username string `json:"username" binding:"-"`
@@ -26,3 +34,18 @@ type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
 }
+
+// NotificationPreferences controls when non-urgent notifications may be delivered.
+// Quiet hours are local "HH:MM" times in Timezone; both nil means no quiet hours.
+type NotificationPreferences struct {
+	UserID          int     `json:"user_id"`
+	Timezone        string  `json:"timezone"`
+	QuietHoursStart *string `json:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	Timezone        string  `json:"timezone" binding:"required"`
+	QuietHoursStart *string `json:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+}
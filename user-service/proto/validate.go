@@ -0,0 +1,19 @@
+package user
+
+import "errors"
+
+// Validate methods are picked up by the gRPC validation interceptor
+
+func (r *GetRiskScoreRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	return nil
+}
+
+func (r *GetUserRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package region
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// DefaultRegion is used when REGION is not set
+const DefaultRegion = "us-east-1"
+
+var ErrNotAllowed = errors.New("region is not served by this deployment")
+
+// Config is the data residency setup of this deployment: its home region, used when a
+// request names none, and the regions it may store data for.
+type Config struct {
+	Home    string
+	Allowed []string
+}
+
+// Load reads REGION and ALLOWED_REGIONS (comma-separated, defaults to the home region only)
+func Load() Config {
+	home := strings.TrimSpace(os.Getenv("REGION"))
+	if home == "" {
+		home = DefaultRegion
+	}
+
+	allowed := []string{home}
+	for _, r := range strings.Split(os.Getenv("ALLOWED_REGIONS"), ",") {
+		if r = strings.TrimSpace(r); r != "" && r != home {
+			allowed = append(allowed, r)
+		}
+	}
+	return Config{Home: home, Allowed: allowed}
+}
+
+// Allows reports whether data for region may be stored or queried here
+func (c Config) Allows(region string) bool {
+	for _, r := range c.Allowed {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the region to tag new data with: the requested one if this deployment
+// serves it, or the home region when none was requested.
+func (c Config) Resolve(requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return c.Home, nil
+	}
+	if !c.Allows(requested) {
+		return "", ErrNotAllowed
+	}
+	return requested, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"user-svc/database"
+	"user-svc/models"
+	"user-svc/region"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// seedPasswordBytes is the entropy of a generated seed password
+const seedPasswordBytes = 18
+
+var seedUsers = []struct {
+	name  string
+	email string
+	role  string
+}{
+	{"Demo Customer", "demo@mini-shop.local", models.RoleCustomer},
+	{"Demo Admin", "admin@mini-shop.local", models.RoleAdmin},
+}
+
+// seed inserts demo users into an already migrated database, in this deployment's home
+// region. Emails are unique, so rerunning it skips accounts that already exist.
+func seed(logger *zap.Logger) error {
+	db, err := database.Open(logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	password, generated, err := seedPassword()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	home := region.Load().Home
+	inserted := 0
+	for _, u := range seedUsers {
+		res, err := db.Exec(
+			"INSERT INTO users (name, email, password_hash, region, role) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (email) DO NOTHING",
+			u.name, u.email, string(hashedPassword), home, u.role,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed user %s: %w", u.email, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+
+	logger.Info("Demo users seeded",
+		zap.Int("inserted", inserted),
+		zap.Int("skipped", len(seedUsers)-inserted),
+	)
+	// A generated password is shown once, and only if an account was created with it
+	if generated && inserted > 0 {
+		logger.Info("Generated password for the new demo users; set SEED_PASSWORD to choose it",
+			zap.String("password", password),
+		)
+	}
+	return nil
+}
+
+// seedPassword is shared by every demo account so they can log in straight away. It is
+// SEED_PASSWORD, or a random one when that is unset, so no deployment ships a known
+// admin password.
+func seedPassword() (password string, generated bool, err error) {
+	if password := os.Getenv("SEED_PASSWORD"); password != "" {
+		return password, false, nil
+	}
+	buf := make([]byte, seedPasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, fmt.Errorf("failed to generate seed password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"user-svc/database"
+	"user-svc/handlers"
+	"user-svc/kafka"
+	"user-svc/middleware"
+	user "user-svc/proto"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// serve runs the REST and gRPC APIs together with the risk-score consumer until
+// SIGINT/SIGTERM
+func serve(logger *zap.Logger) error {
+	if err := middleware.CheckJWTSecret(); err != nil {
+		logger.Fatal("Invalid auth configuration", zap.Error(err))
+	}
+
+	// Initialize database
+	db, err := database.InitDB(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Initialize OpenTelemetry
+	shutdownTracing, err := middleware.InitTracing("user-service")
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+
+	defer shutdownTracing()
+
+	// Initialize Kafka consumer (payment outcomes for risk scoring)
+	consumerGroup, err := kafka.InitConsumer(logger, false)
+	if err != nil {
+		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
+	}
+	defer consumerGroup.Close()
+
+	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	go func() {
+		if err := kafka.StartConsumer(consumerCtx, consumerGroup, db, logger); err != nil {
+			logger.Error("Kafka consumer stopped", zap.Error(err))
+		}
+	}()
+
+	// Setup Gin router
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// OpenTelemetry middleware must be first to extract trace context
+	router.Use(otelgin.Middleware("user-service"))
+	router.Use(middleware.LoggerMiddleware(logger))
+	router.Use(middleware.MetricsMiddleware())
+
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// Readiness endpoint (checks database connectivity)
+	router.GET("/readyz", handlers.ReadinessCheck(db))
+
+	// Metrics endpoint
+	router.GET("/metrics", middleware.PrometheusHandler())
+
+	// Auth endpoints
+	authHandler := handlers.NewAuthHandler(db, logger)
+	router.POST("/api/v1/register", authHandler.Register)
+	router.POST("/api/v1/login", authHandler.Login)
+
+	// Internal endpoints for other services, behind the shared service token instead of a JWT
+	preferencesHandler := handlers.NewPreferencesHandler(db, logger)
+	internal := router.Group("/internal", middleware.ServiceTokenMiddleware(os.Getenv("SERVICE_TOKEN")))
+	internal.GET("/users/:id/notification-preferences", preferencesHandler.GetUserPreferences)
+
+	// Protected endpoints
+	protected := router.Group("/api/v1")
+	protected.Use(middleware.AuthMiddleware())
+	{
+		protected.GET("/profile", handlers.GetProfile)
+		protected.GET("/profile/notification-preferences", preferencesHandler.GetMyPreferences)
+		protected.PUT("/profile/notification-preferences", preferencesHandler.UpdateMyPreferences)
+	}
+
+	// Start server
+	srv := &http.Server{
+		Addr:    ":8080",
+		Handler: router,
+	}
+
+	// Graceful shutdown
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("User Service started on :8080")
+
+	// Start gRPC server
+	grpcListener, err := net.Listen("tcp", ":50053")
+	if err != nil {
+		logger.Fatal("Failed to listen on gRPC port", zap.Error(err))
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		middleware.GRPCServerInterceptors(logger, os.Getenv("GRPC_SERVICE_TOKEN"), middleware.DevMode()),
+	)
+	user.RegisterUserServiceServer(grpcServer, handlers.NewUserService(db, logger))
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("User Service gRPC server started on :50053")
+
+	// Call graceful shutdown
+	gracefulShutdown(srv, grpcServer, consumerCancel, consumerGroup, db, shutdownTracing, logger)
+	return nil
+}
+
+// gracefulShutdown handles SIGINT/SIGTERM and shuts down all services gracefully
+func gracefulShutdown(
+	srv *http.Server,
+	grpcServer *grpc.Server,
+	consumerCancel context.CancelFunc,
+	consumerGroup sarama.ConsumerGroup,
+	db *sql.DB,
+	shutdownTracing func(),
+	logger *zap.Logger,
+) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutdown signal received. Exiting...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Shutdown HTTP server
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server forced to shutdown", zap.Error(err))
+	} else {
+		logger.Info("HTTP server stopped gracefully")
+	}
+
+	// Stop gRPC server
+	grpcServer.GracefulStop()
+	logger.Info("gRPC server stopped gracefully")
+
+	// Stop Kafka consumer
+	consumerCancel()
+	if err := consumerGroup.Close(); err != nil {
+		logger.Error("Failed to close Kafka consumer", zap.Error(err))
+	} else {
+		logger.Info("Kafka consumer stopped gracefully")
+	}
+
+	// Close database
+	if err := db.Close(); err != nil {
+		logger.Error("Failed to close database", zap.Error(err))
+	} else {
+		logger.Info("Database connection closed gracefully")
+	}
+
+	// Shutdown tracing
+	shutdownTracing()
+	logger.Info("User Service exited gracefully")
+}
@@ -0,0 +1,82 @@
+package startup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// now and sleep are swapped in tests so Wait runs on a fake clock
+var (
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+// Config bounds how long a starting service waits for its dependencies
+type Config struct {
+	// FailFast makes a single attempt per dependency, so the service exits right away
+	// when one is down and the orchestrator restarts it
+	FailFast bool
+	// Timeout is how long to keep retrying one dependency before giving up
+	Timeout time.Duration
+}
+
+// Load reads STARTUP_FAIL_FAST (default false) and STARTUP_TIMEOUT (default 60s)
+func Load() Config {
+	failFast, _ := strconv.ParseBool(os.Getenv("STARTUP_FAIL_FAST"))
+
+	timeout := 60 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("STARTUP_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return Config{FailFast: failFast, Timeout: timeout}
+}
+
+// Wait calls check until it succeeds, backing off exponentially between attempts, so
+// the service survives dependencies that start after it (as in docker-compose). Each
+// failed attempt is logged with the dependency that is holding up startup.
+func Wait(logger *zap.Logger, dependency string, check func() error) error {
+	cfg := Load()
+	start := now()
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := check()
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("Dependency is ready",
+					zap.String("dependency", dependency),
+					zap.Int("attempts", attempt),
+					zap.Duration("waited", now().Sub(start)),
+				)
+			}
+			return nil
+		}
+
+		waited := now().Sub(start)
+		if cfg.FailFast || waited+backoff > cfg.Timeout {
+			return fmt.Errorf("%s not ready after %d attempt(s) in %s: %w", dependency, attempt, waited.Round(time.Millisecond), err)
+		}
+
+		logger.Warn("Waiting for dependency",
+			zap.String("dependency", dependency),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", backoff),
+			zap.Error(err),
+		)
+		sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}